@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"math/rand/v2"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/bucket"
+	"github.com/nickhildebrandt/ts-release/internal/bundle"
+	"github.com/nickhildebrandt/ts-release/internal/offline"
+	"github.com/nickhildebrandt/ts-release/internal/wallhaven"
+	"github.com/nickhildebrandt/ts-release/internal/wallpaper"
+)
+
+// errUnknownBundleCommand is returned for a missing or unknown bundle subcommand.
+var errUnknownBundleCommand = fmt.Errorf("bundle: want embed, create, or use: %w", errUsage)
+
+// runBundle dispatches the bundle subcommands.
+func runBundle(args []string) error {
+	if len(args) == 0 {
+		return errUnknownBundleCommand
+	}
+	switch args[0] {
+	case "embed":
+		return runBundleEmbed(args[1:])
+	case "create":
+		return runBundleCreate(args[1:])
+	case "use":
+		return runBundleUse(args[1:])
+	default:
+		return errUnknownBundleCommand
+	}
+}
+
+// embedOptions holds the parsed command line of the bundle embed subcommand.
+type embedOptions struct {
+	output string
+	binary string
+}
+
+// newEmbedFlagSet returns the flag set of the bundle embed subcommand.
+func newEmbedFlagSet(opts *embedOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release bundle embed", flag.ContinueOnError)
+	fs.StringVar(&opts.output, "o", "", "write the executable with the bundled backgrounds to this path (required)")
+	fs.StringVar(&opts.binary, "binary", "", "ts-release executable to bundle, e.g. one built for another platform; empty uses the running one")
+	return fs
+}
+
+// runBundleEmbed writes a copy of the ts-release executable that carries the given images, or the images of the
+// given directories, as its built-in backgrounds. A bundle already in the executable is replaced.
+func runBundleEmbed(args []string) error {
+	var opts embedOptions
+	fs := newEmbedFlagSet(&opts)
+	if err := parseSubcommand(fs, args); err != nil {
+		return err
+	}
+	if opts.output == "" || fs.NArg() == 0 {
+		return errUsage
+	}
+
+	exePath := opts.binary
+	if exePath == "" {
+		var err error
+		if exePath, err = os.Executable(); err != nil {
+			return fmt.Errorf("bundle: %w", err)
+		}
+	}
+	exe, err := os.ReadFile(exePath)
+	if err != nil {
+		return fmt.Errorf("bundle: %w", err)
+	}
+	images, err := bundleImages(fs.Args())
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := bundle.Write(&out, exe, images); err != nil {
+		return err
+	}
+	if err := os.WriteFile(opts.output, out.Bytes(), 0o755); err != nil {
+		return fmt.Errorf("bundle: %w", err)
+	}
+	fmt.Fprintf(os.Stdout, "bundled %d backgrounds into %s\n", len(images), opts.output)
+	return nil
+}
+
+// createOptions holds the parsed command line of the bundle create subcommand.
+type createOptions struct {
+	output  string
+	key     string
+	count   int
+	curated string
+	urlList string
+	fonts   []string
+	themes  []string
+	// net carries the download flags shared with generation: Wallhaven URL, rate limit, proxy, and CAs.
+	net options
+}
+
+// newCreateFlagSet returns the flag set of the bundle create subcommand.
+func newCreateFlagSet(opts *createOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release bundle create", flag.ContinueOnError)
+	fs.StringVar(&opts.output, "o", "", "write the signed archive to this path (required)")
+	fs.StringVar(&opts.key, "key", "", "PEM file with the Ed25519 private key that signs the archive (required)")
+	fs.IntVar(&opts.count, "count", 0, "number of approved backgrounds to draw at random from the lists (0 takes all)")
+	fs.StringVar(&opts.curated, "curated", "", "list file of approved Wallhaven wallpaper IDs or URLs to download")
+	fs.StringVar(&opts.urlList, "url-list", "", "file with <sha256> <https-url> lines of approved backgrounds to download and verify")
+	fs.Func("font", "font file to include, e.g. for --font-fallback or --emoji-font (repeatable)", func(path string) error {
+		opts.fonts = append(opts.fonts, path)
+		return nil
+	})
+	fs.Func("theme", "theme file to include (repeatable)", func(path string) error {
+		opts.themes = append(opts.themes, path)
+		return nil
+	})
+	addNetFlags(fs, &opts.net)
+	return fs
+}
+
+// addNetFlags adds the download flags that subcommands share with generation to fs: Wallhaven URL, rate limit,
+// proxy, and CAs.
+func addNetFlags(fs *flag.FlagSet, opts *options) {
+	fs.StringVar(&opts.wallhavenURL, "wallhaven-url", wallhaven.DefaultBaseURL, "Wallhaven API base URL, e.g. for a mirror or a local test server")
+	fs.IntVar(&opts.rateLimit, "rate-limit", wallhaven.DefaultRequestsPerMinute, "maximum Wallhaven API requests per minute")
+	addProxyFlags(fs, opts)
+}
+
+// addProxyFlags adds the proxy and CA flags of downloads to fs.
+func addProxyFlags(fs *flag.FlagSet, opts *options) {
+	fs.StringVar(&opts.proxy, "proxy", "", "proxy URL for downloads")
+	fs.StringVar(&opts.caBundle, "ca-bundle", "", "PEM file with the CA certificates to trust instead of the system pool")
+	fs.Func("ca-cert", "PEM file with additional trusted CA certificates (repeatable)", func(path string) error {
+		opts.caCerts = append(opts.caCerts, path)
+		return nil
+	})
+}
+
+// runBundleCreate downloads approved backgrounds and packs them with fonts and themes into a signed archive for
+// air-gapped build environments.
+func runBundleCreate(args []string) error {
+	var opts createOptions
+	fs := newCreateFlagSet(&opts)
+	if err := parseSubcommand(fs, args); err != nil {
+		return err
+	}
+	if opts.output == "" || opts.key == "" || fs.NArg() != 0 {
+		return errUsage
+	}
+	if opts.curated == "" && opts.urlList == "" {
+		return fmt.Errorf("bundle create: want --curated or --url-list")
+	}
+	if opts.count < 0 {
+		return fmt.Errorf("bundle create: --count %d must not be negative", opts.count)
+	}
+	key, err := offline.LoadPrivateKey(opts.key)
+	if err != nil {
+		return err
+	}
+
+	// Every approved background is a download; collect them all before drawing so the draw is uniform.
+	type download func() (offline.File, error)
+	var downloads []download
+	httpClient, err := newHTTPClient(opts.net)
+	if err != nil {
+		return err
+	}
+	if opts.curated != "" {
+		pool, err := wallpaper.LoadPool(opts.curated)
+		if err != nil {
+			return err
+		}
+		client := newWallhavenClient(opts.net, httpClient)
+		for _, entry := range pool {
+			downloads = append(downloads, func() (offline.File, error) {
+				w, err := client.Wallpaper(entry.ID)
+				if err != nil {
+					return offline.File{}, err
+				}
+				data, err := client.Download(w.Path)
+				if err != nil {
+					return offline.File{}, err
+				}
+				return offline.File{Kind: offline.KindBackground, Name: wallhavenFileName(w), Data: data, SourceURL: w.Path, WallpaperID: w.ID}, nil
+			})
+		}
+	}
+	if opts.urlList != "" {
+		entries, err := bucket.LoadList(opts.urlList)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			downloads = append(downloads, func() (offline.File, error) {
+				data, err := entry.Download(httpClient, 0)
+				if err != nil {
+					return offline.File{}, err
+				}
+				u, err := url.Parse(entry.URL)
+				if err != nil {
+					return offline.File{}, err
+				}
+				return offline.File{Kind: offline.KindBackground, Name: path.Base(u.Path), Data: data, SourceURL: entry.URL}, nil
+			})
+		}
+	}
+	if opts.count > 0 && opts.count < len(downloads) {
+		rand.Shuffle(len(downloads), func(i, j int) { downloads[i], downloads[j] = downloads[j], downloads[i] })
+		downloads = downloads[:opts.count]
+	}
+
+	var files []offline.File
+	for _, download := range downloads {
+		f, err := download()
+		if err != nil {
+			return fmt.Errorf("bundle create: %w", err)
+		}
+		if _, _, err := image.DecodeConfig(bytes.NewReader(f.Data)); err != nil {
+			return fmt.Errorf("bundle create: %s: %w", f.SourceURL, err)
+		}
+		files = append(files, f)
+	}
+	for _, group := range []struct {
+		kind  string
+		paths []string
+	}{{offline.KindFont, opts.fonts}, {offline.KindTheme, opts.themes}} {
+		for _, p := range group.paths {
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return fmt.Errorf("bundle create: %w", err)
+			}
+			files = append(files, offline.File{Kind: group.kind, Name: filepath.Base(p), Data: data})
+		}
+	}
+
+	var out bytes.Buffer
+	if err := offline.Create(&out, key, time.Now(), files); err != nil {
+		return err
+	}
+	if err := os.WriteFile(opts.output, out.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("bundle create: %w", err)
+	}
+	fmt.Fprintf(os.Stdout, "wrote %d backgrounds, %d fonts, and %d themes to %s\n", len(downloads), len(opts.fonts), len(opts.themes), opts.output)
+	return nil
+}
+
+// useOptions holds the parsed command line of the bundle use subcommand.
+type useOptions struct {
+	pubKey string
+	dir    string
+}
+
+// newUseFlagSet returns the flag set of the bundle use subcommand.
+func newUseFlagSet(opts *useOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release bundle use", flag.ContinueOnError)
+	fs.StringVar(&opts.pubKey, "pubkey", "", "PEM file with the Ed25519 public key the archive must be signed with (required)")
+	fs.StringVar(&opts.dir, "d", "ts-release-offline", "directory to unpack the archive into")
+	return fs
+}
+
+// runBundleUse verifies a signed archive and unpacks it for offline builds.
+func runBundleUse(args []string) error {
+	var opts useOptions
+	fs := newUseFlagSet(&opts)
+	if err := parseSubcommand(fs, args); err != nil {
+		return err
+	}
+	if opts.pubKey == "" || fs.NArg() != 1 {
+		return errUsage
+	}
+	pub, err := offline.LoadPublicKey(opts.pubKey)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("bundle use: %w", err)
+	}
+	defer f.Close()
+	manifest, err := offline.Extract(f, pub, opts.dir)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "verified %s, created %s\n", fs.Arg(0), manifest.Created.Format(time.RFC3339))
+	for _, file := range manifest.Files {
+		fmt.Fprintf(os.Stdout, "  %s\n", filepath.Join(opts.dir, filepath.FromSlash(file.Path())))
+	}
+	fmt.Fprintf(os.Stdout, "build with: --source %s --offline-dir %s\n", wallpaper.SourceOffline, opts.dir)
+	return nil
+}
+
+// bundleImages reads the image files among paths and the images directly inside directories among them.
+// Every image is decoded up front, so a broken file fails the bundle instead of a later fallback.
+func bundleImages(paths []string) ([]bundle.Image, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("bundle: %w", err)
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, fmt.Errorf("bundle: %w", err)
+		}
+		for _, e := range entries {
+			if !e.IsDir() && bundle.IsImageName(e.Name()) {
+				files = append(files, filepath.Join(p, e.Name()))
+			}
+		}
+	}
+	images := make([]bundle.Image, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("bundle: %w", err)
+		}
+		if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("bundle: %s: %w", file, err)
+		}
+		images = append(images, bundle.Image{Name: filepath.Base(file), Data: data})
+	}
+	return images, nil
+}
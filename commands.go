@@ -0,0 +1,444 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// command describes one invocation of the CLI. The usage text, the help of each subcommand, and the man page are all
+// generated from commands, so they list the same synopses, descriptions, and flags.
+type command struct {
+	// name is the words after ts-release that select the command, e.g. "bundle create"; empty for a build.
+	name string
+	// synopsis follows the name in the usage line.
+	synopsis string
+	// summary is a short phrase for lists of commands.
+	summary string
+	// description explains the command in one paragraph.
+	description string
+	// flagsTitle names the flag set of the command in the synopsis and the flag lists, e.g. "Create"; empty without
+	// flags of its own.
+	flagsTitle string
+	// flags returns the flag set of the command.
+	flags func() *flag.FlagSet
+	// buildFlags marks commands that also take the flags of a build.
+	buildFlags bool
+}
+
+// commands lists every invocation in the order of the usage text, starting with the build.
+var commands = []command{
+	{
+		synopsis:    "[flags] <target-name> <rootfs-dir>",
+		summary:     "generate a release wallpaper and install it into a rootfs",
+		description: "Fetches a background, draws the target name, build ID, and subtitle onto it, and installs the wallpaper, boot splash, and release files into the rootfs directory. Flags must come before the positional arguments.",
+		flags:       newMainFlagSet,
+	},
+	{
+		name:        "inspect",
+		synopsis:    "<file>...",
+		summary:     "print the format, metadata, and checksum state of generated artifacts",
+		description: "Prints the format, resolution, and embedded metadata of each file, and checks it against the nearest manifest of the rootfs it belongs to. Exits non-zero if a file cannot be decoded or its checksum does not match.",
+	},
+	{
+		name:        "diff",
+		synopsis:    "[diff flags] <a.png> <b.png>",
+		summary:     "compare a regenerated wallpaper with a reference image",
+		description: "Compares two images perceptually and prints the share of differing pixels and their SSIM. Exits non-zero if the images differ by more than the thresholds.",
+		flagsTitle:  "Diff",
+		flags:       func() *flag.FlagSet { return newDiffFlagSet(&diffOptions{}) },
+	},
+	{
+		name:        "bundle embed",
+		synopsis:    "[embed flags] <image-or-dir>...",
+		summary:     "build an executable with bundled fallback backgrounds",
+		description: "Writes a copy of the executable that carries the given images as built-in backgrounds for builds without network access.",
+		flagsTitle:  "Embed",
+		flags:       func() *flag.FlagSet { return newEmbedFlagSet(&embedOptions{}) },
+	},
+	{
+		name:        "bundle create",
+		synopsis:    "[create flags]",
+		summary:     "download approved backgrounds into a signed offline archive",
+		description: "Downloads the backgrounds of curated lists and URL lists, with fonts and themes, into an archive signed with an Ed25519 key for air-gapped builds.",
+		flagsTitle:  "Create",
+		flags:       func() *flag.FlagSet { return newCreateFlagSet(&createOptions{}) },
+	},
+	{
+		name:        "bundle use",
+		synopsis:    "[use flags] <archive>",
+		summary:     "verify and unpack an offline archive",
+		description: "Checks the signature of an archive of bundle create and unpacks it into a directory for --source offline.",
+		flagsTitle:  "Use",
+		flags:       func() *flag.FlagSet { return newUseFlagSet(&useOptions{}) },
+	},
+	{
+		name:        "pick",
+		synopsis:    "[pick flags]",
+		summary:     "download wallpaper candidates and approve one into a curated list",
+		description: "Downloads Wallhaven candidates with a contact sheet for review, or with --approve adds a reviewed candidate to a curated list.",
+		flagsTitle:  "Pick",
+		flags:       func() *flag.FlagSet { return newPickFlagSet(&pickOptions{}) },
+	},
+	{
+		name:        "tui",
+		synopsis:    "[tui flags] <target-name>",
+		summary:     "set up a target interactively with an inline preview",
+		description: "Reads commands that change the query, theme, layout, and subtitle, renders a preview in the terminal after each change, and saves the changed settings into the config file.",
+		flagsTitle:  "TUI",
+		flags:       func() *flag.FlagSet { return newTUIFlagSet(&tuiOptions{}) },
+	},
+	{
+		name:        "watch",
+		synopsis:    "[watch flags]",
+		summary:     "keep a rootfs up to date as a long-running service",
+		description: "Builds into a rootfs at start and again when the config file changes, on SIGHUP, and on a D-Bus signal. A failed build leaves the previous artifacts in place.",
+		flagsTitle:  "Watch",
+		flags:       func() *flag.FlagSet { return newWatchFlagSet(&watchOptions{}) },
+	},
+	{
+		name:        "serve",
+		synopsis:    "[serve flags]",
+		summary:     "render wallpapers on request over HTTP and gRPC",
+		description: "Serves an HTTP API that renders wallpapers from the settings of a config file and the request, and a gRPC API that also installs into and verifies rootfs trees.",
+		flagsTitle:  "Serve",
+		flags:       func() *flag.FlagSet { return newServeFlagSet(&serveOptions{}) },
+	},
+	{
+		name:        "hook",
+		synopsis:    "mkosi|debos [flags] <target-name> <image-definition-dir>",
+		summary:     "stage a build for an image build tool",
+		description: "Builds into a staging directory of an mkosi or debos image definition and writes the glue that copies the artifacts into the image.",
+		buildFlags:  true,
+	},
+	{
+		name:        "pack",
+		synopsis:    "[pack flags] <file.tsrelease> [flags] <target-name>",
+		summary:     "generate a build into a signed release bundle",
+		description: "Builds once and writes the files of the build into a bundle signed with an Ed25519 key, to be applied to rootfs trees later with unpack.",
+		flagsTitle:  "Pack",
+		flags:       func() *flag.FlagSet { return newPackFlagSet(&packOptions{}) },
+		buildFlags:  true,
+	},
+	{
+		name:        "unpack",
+		synopsis:    "[unpack flags] <file.tsrelease>",
+		summary:     "verify a release bundle and write it into a rootfs",
+		description: "Checks the signature of a bundle of pack and writes its files into a rootfs, or lists them without --rootfs.",
+		flagsTitle:  "Unpack",
+		flags:       func() *flag.FlagSet { return newUnpackFlagSet(&unpackOptions{}) },
+	},
+	{
+		name:        "restore",
+		synopsis:    "[restore flags] <rootfs-dir>",
+		summary:     "put back the files a build saved with --backup or --backup-dir",
+		description: "Restores the files a build with --backup or --backup-dir overwrote in the rootfs.",
+		flagsTitle:  "Restore",
+		flags:       func() *flag.FlagSet { return newRestoreFlagSet(&restoreOptions{}) },
+	},
+	{
+		name:        "generate",
+		synopsis:    "[generate flags and flags] <target-name>",
+		summary:     "write only the wallpaper, to a file or to stdout",
+		description: "Builds into a temporary directory and writes the wallpaper with its metadata as PNG or JPEG. With -o -, stdout carries nothing but the image.",
+		flagsTitle:  "Generate",
+		flags:       func() *flag.FlagSet { return newGenerateFlagSet(&generateOptions{}) },
+		buildFlags:  true,
+	},
+	{
+		name:        "config validate",
+		synopsis:    "<file>...",
+		summary:     "check config files",
+		description: "Checks config files like a build does and reports every problem with its line and column.",
+	},
+	{
+		name:        "config init",
+		synopsis:    "[config init flags]",
+		summary:     "write a commented default config file",
+		description: "Writes a config file that lists every setting as a comment with its description and default.",
+		flagsTitle:  "Config init",
+		flags:       func() *flag.FlagSet { return newConfigInitFlagSet(&configInitOptions{}) },
+	},
+	{
+		name:        "config schema",
+		summary:     "print the JSON Schema of config files",
+		description: "Prints a JSON Schema (draft 2020-12) with one property per setting.",
+	},
+	{
+		name:        "config show",
+		synopsis:    "[config show flags and flags]",
+		summary:     "print the settings a build would use and where they come from",
+		description: "Merges the defaults, the environment, the --config file, and the flags like a build does and prints each setting with its source.",
+		flagsTitle:  "Config show",
+		flags:       func() *flag.FlagSet { return newConfigShowFlagSet(&configShowOptions{}) },
+		buildFlags:  true,
+	},
+	{
+		name:        "config diff",
+		synopsis:    "<a.conf> <b.conf>",
+		summary:     "compare the effective settings of two config files",
+		description: "Prints the settings whose effective values differ between two config files.",
+	},
+	{
+		name:        "self-update",
+		synopsis:    "[self-update flags]",
+		summary:     "replace the executable with a newer signed release",
+		description: "Fetches the release of an endpoint, verifies its Ed25519 signature and the SHA-256 of the executable for this platform, and atomically replaces the running executable if the release is newer.",
+		flagsTitle:  "Self-update",
+		flags:       func() *flag.FlagSet { return newSelfUpdateFlagSet(&selfUpdateOptions{}) },
+	},
+	{
+		name:        "self-update sign",
+		synopsis:    "[sign flags] <GOOS>/<GOARCH>=<file>...",
+		summary:     "publish executables as a signed release",
+		description: "Writes a release endpoint for self-update into a directory: the executables, release.json with their platforms and checksums, and its signature.",
+		flagsTitle:  "Sign",
+		flags:       func() *flag.FlagSet { return newSelfUpdateSignFlagSet(&selfUpdateSignOptions{}) },
+	},
+	{
+		name:        "bugreport",
+		synopsis:    "[bugreport flags] [-- ts-release args]",
+		summary:     "write a diagnostic bundle for a bug report",
+		description: "Runs the ts-release command line after --, if any, and writes a ZIP file with the version, OS and architecture, the redacted command and config, the last HTTP statuses, stage timings, and the error or panic stack of the command. Passwords, tokens, and API keys are left out. A panic of any command writes the same bundle to the temporary directory.",
+		flagsTitle:  "Bugreport",
+		flags:       func() *flag.FlagSet { return newBugreportFlagSet(&bugreportOptions{}) },
+	},
+	{
+		name:        "man",
+		synopsis:    "[man flags]",
+		summary:     "write the man page",
+		description: "Writes the ts-release(1) man page in troff format, generated like this help from the commands and their flags.",
+		flagsTitle:  "Man",
+		flags:       func() *flag.FlagSet { return newManFlagSet(&manOptions{}) },
+	},
+}
+
+// line returns the usage line of c without the "Usage:" prefix.
+func (c command) line() string {
+	return strings.Join(strings.Fields("ts-release "+c.name+" "+c.synopsis), " ")
+}
+
+// findCommand returns the command with the most words that args select, or false if they select none, e.g. for a
+// build or an unknown subcommand of bundle or config.
+func findCommand(args []string) (command, bool) {
+	var found command
+	ok := false
+	for _, c := range commands {
+		words := strings.Fields(c.name)
+		if len(words) > len(strings.Fields(found.name)) && len(args) >= len(words) && strings.Join(args[:len(words)], " ") == c.name {
+			found, ok = c, true
+		}
+	}
+	return found, ok
+}
+
+// usage prints the usage lines of all commands and the flags of each to stderr.
+func usage() {
+	for i, c := range commands {
+		prefix := "       "
+		if i == 0 {
+			prefix = "Usage: "
+		}
+		fmt.Fprintln(os.Stderr, prefix+c.line())
+	}
+	for _, c := range commands {
+		if c.flags == nil {
+			continue
+		}
+		title := "Flags"
+		if c.flagsTitle != "" {
+			title = c.flagsTitle + " flags"
+		}
+		fmt.Fprintf(os.Stderr, "\n%s:\n", title)
+		fs := c.flags()
+		fs.SetOutput(os.Stderr)
+		fs.PrintDefaults()
+	}
+}
+
+// commandUsage prints the help of the command args select to stderr: its usage line, description, and flags, followed
+// by the build flags if it takes them. A group such as bundle without a known subcommand lists the commands of the
+// group with their summary; other args print the usage of all commands.
+func commandUsage(args []string) {
+	c, ok := findCommand(args)
+	if !ok && len(args) > 0 {
+		var group []command
+		for _, c := range commands {
+			if words := strings.Fields(c.name); len(words) > 1 && words[0] == args[0] {
+				group = append(group, c)
+			}
+		}
+		if len(group) > 0 {
+			for i, c := range group {
+				prefix := "       "
+				if i == 0 {
+					prefix = "Usage: "
+				}
+				fmt.Fprintln(os.Stderr, prefix+c.line())
+			}
+			fmt.Fprintln(os.Stderr)
+			tw := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+			for _, c := range group {
+				fmt.Fprintf(tw, "  %s\t%s\n", c.name, c.summary)
+			}
+			tw.Flush()
+			fmt.Fprintf(os.Stderr, "\nRun ts-release %s <command> --help for the flags of a command.\n", args[0])
+			return
+		}
+	}
+	if !ok {
+		usage()
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Usage: "+c.line())
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, wrapText(c.description, 100))
+	if c.flags != nil {
+		fmt.Fprintf(os.Stderr, "\n%s flags:\n", c.flagsTitle)
+		fs := c.flags()
+		fs.SetOutput(os.Stderr)
+		fs.PrintDefaults()
+	}
+	if c.buildFlags {
+		fmt.Fprintln(os.Stderr, "\nFlags:")
+		fs := newMainFlagSet()
+		fs.SetOutput(os.Stderr)
+		fs.PrintDefaults()
+	}
+}
+
+// wrapText breaks text into lines of at most width bytes at spaces; longer words get a line of their own.
+func wrapText(text string, width int) string {
+	var b strings.Builder
+	n := 0
+	for _, word := range strings.Fields(text) {
+		switch {
+		case n == 0:
+		case n+1+len(word) > width:
+			b.WriteByte('\n')
+			n = 0
+		default:
+			b.WriteByte(' ')
+			n++
+		}
+		b.WriteString(word)
+		n += len(word)
+	}
+	return b.String()
+}
+
+// manOptions holds the parsed command line of the man subcommand.
+type manOptions struct {
+	output string
+}
+
+// newManFlagSet returns the flag set of the man subcommand.
+func newManFlagSet(opts *manOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release man", flag.ContinueOnError)
+	fs.StringVar(&opts.output, "o", "-", "file to write the man page to, e.g. ts-release.1, or - for stdout")
+	return fs
+}
+
+// runMan writes the man page.
+func runMan(args []string) error {
+	var opts manOptions
+	fs := newManFlagSet(&opts)
+	if err := parseSubcommand(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || opts.output == "" {
+		return errUsage
+	}
+	if opts.output == "-" {
+		return writeManPage(os.Stdout)
+	}
+	f, err := os.Create(opts.output)
+	if err != nil {
+		return fmt.Errorf("man: %w", err)
+	}
+	if err := writeManPage(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("man: %w", err)
+	}
+	return nil
+}
+
+// writeManPage writes the ts-release(1) man page in troff with the man macros: the synopsis of every command, the
+// build and its flags, and a section per subcommand with its own flags. It carries no date, so it is reproducible.
+func writeManPage(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, ".TH TS-RELEASE 1 \"\" \"ts-release %s\" \"User Commands\"\n", roff(version))
+	b.WriteString(".SH NAME\n" + roff("ts-release") + " \\- " + roff(commands[0].summary) + "\n")
+	b.WriteString(".SH SYNOPSIS\n")
+	for i, c := range commands {
+		if i > 0 {
+			b.WriteString(".br\n")
+		}
+		b.WriteString(manSynopsis(c))
+	}
+	b.WriteString(".SH DESCRIPTION\n" + roff(commands[0].description) + "\n")
+	b.WriteString(".SH OPTIONS\n")
+	writeManFlags(&b, commands[0].flags())
+	b.WriteString(".SH COMMANDS\n")
+	for _, c := range commands[1:] {
+		b.WriteString(".SS " + roff(c.name) + "\n")
+		b.WriteString(manSynopsis(c))
+		b.WriteString(".PP\n" + roff(c.description))
+		if c.buildFlags {
+			b.WriteString(" It takes the flags of a build, see\n.BR OPTIONS .")
+		}
+		b.WriteString("\n")
+		if c.flags != nil {
+			b.WriteString(".PP\n" + roff(c.flagsTitle) + " flags:\n")
+			writeManFlags(&b, c.flags())
+		}
+	}
+	b.WriteString(".SH EXIT STATUS\n0 on success; 1 for invalid usage and any failure.\n")
+	b.WriteString(".SH SEE ALSO\nThe README of ts-release describes every feature in detail.\n")
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("man: %w", err)
+	}
+	return nil
+}
+
+// manSynopsis returns the usage line of c with the command in bold.
+func manSynopsis(c command) string {
+	line := ".B " + roff(strings.TrimSpace("ts-release "+c.name)) + "\n"
+	if c.synopsis != "" {
+		line += roff(c.synopsis) + "\n"
+	}
+	return line
+}
+
+// writeManFlags writes the flags of fs as a tagged paragraph each, with the type and default like PrintDefaults.
+func writeManFlags(b *strings.Builder, fs *flag.FlagSet) {
+	fs.VisitAll(func(f *flag.Flag) {
+		name, usage := flag.UnquoteUsage(f)
+		b.WriteString(".TP\n\\fB" + roff("-"+f.Name) + "\\fR")
+		if name != "" {
+			b.WriteString(" \\fI" + roff(name) + "\\fR")
+		}
+		b.WriteString("\n" + roff(usage))
+		switch f.DefValue {
+		case "", "0", "false", "0s":
+		default:
+			b.WriteString(" (default " + roff(fmt.Sprintf("%q", f.DefValue)) + ")")
+		}
+		b.WriteString("\n")
+	})
+}
+
+// roff escapes text for a troff line: backslashes and dashes, and a leading dot or quote that would start a request.
+func roff(text string) string {
+	text = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(text)
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = `\&` + text
+	}
+	return text
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nickhildebrandt/ts-release/internal/buildid"
+	"github.com/nickhildebrandt/ts-release/internal/config"
+	"github.com/nickhildebrandt/ts-release/internal/install"
+	"github.com/nickhildebrandt/ts-release/internal/locale"
+	"github.com/nickhildebrandt/ts-release/internal/release"
+	"github.com/nickhildebrandt/ts-release/internal/wallhaven"
+	"github.com/nickhildebrandt/ts-release/internal/wallpaper"
+)
+
+// configSchemaTitle is the title of the JSON Schema of config files.
+const configSchemaTitle = "ts-release config file"
+
+// configChoices are the allowed values of the settings that take one of a list of names.
+var configChoices = map[string][]string{
+	"build-id-format":    stringsOf(buildid.Formats()),
+	"text-policy":        release.TextPolicies(),
+	"compare":            stringsOf(buildid.Comparisons()),
+	"channel":            stringsOf(wallpaper.Channels()),
+	"direction":          stringsOf(wallpaper.Directions()),
+	"hinting":            stringsOf(wallpaper.Hintings()),
+	"layout":             wallpaper.Layouts(),
+	"dither":             stringsOf(wallpaper.Dithers()),
+	"crop":               stringsOf(wallpaper.Crops()),
+	"fit":                stringsOf(wallpaper.Fits()),
+	"shell":              stringsOf(wallpaper.Shells()),
+	"safe-area":          stringsOf(wallpaper.SafeAreaModes()),
+	"install-profile":    install.Profiles(),
+	"resolver":           install.Resolvers(),
+	"ownership-format":   install.OwnershipFormats(),
+	"board":              install.Boards(),
+	"boot-splash-format": install.BootSplashFormats(),
+	"splash-format":      install.SplashFormats(),
+	"source":             wallpaper.Sources(),
+	"pick":               stringsOf(wallpaper.Picks()),
+	"animated":           stringsOf(wallpaper.AnimatedPolicies()),
+}
+
+// configChecks validate the settings that the flag set only parses after all flags are set, so a config file reports
+// their errors at their line rather than after loading. Settings with configChoices are checked by name instead;
+// locale has no choices because it also accepts regional names such as de_DE.
+var configChecks = map[string]func(string) error{
+	"locale":       checkParse(locale.Load),
+	"target-size":  checkParse(parseByteSize),
+	"monitors":     checkParse(wallpaper.ParseMonitors),
+	"product":      checkParse(install.ParseProduct),
+	"psplash":      func(v string) error { _, _, err := parseSize(v); return err },
+	"wallpaper-id": checkParse(wallhaven.ParseWallpaperID),
+	"collection":   func(v string) error { _, _, err := wallhaven.ParseCollection(v); return err },
+}
+
+// stringsOf converts named string values, e.g. the layouts of a flag, to strings.
+func stringsOf[T ~string](values []T) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		out = append(out, string(v))
+	}
+	return out
+}
+
+// checkParse returns a check that only keeps the error of parse.
+func checkParse[T any](parse func(string) (T, error)) func(string) error {
+	return func(v string) error {
+		_, err := parse(v)
+		return err
+	}
+}
+
+// configSchema describes the settings of config files: every flag of the flag sets newFlagSet returns, except config.
+// Values are checked by setting them on a fresh flag set, by their configChoices, and by their configChecks.
+func configSchema(newFlagSet func() *flag.FlagSet) config.Schema {
+	var schema config.Schema
+	newFlagSet().VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+		p := config.Property{
+			Name:        f.Name,
+			Description: f.Usage,
+			Type:        config.TypeString,
+			Default:     f.DefValue,
+			Enum:        configChoices[f.Name],
+			Repeatable:  strings.Contains(f.Usage, "repeatable"),
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			p.Type = config.TypeBoolean
+		} else if g, ok := f.Value.(flag.Getter); ok {
+			switch g.Get().(type) {
+			case int, int64, uint, uint64:
+				p.Type = config.TypeInteger
+			case float64:
+				p.Type = config.TypeNumber
+			}
+		}
+		check := configChecks[f.Name]
+		p.Check = func(value string) error {
+			if err := newFlagSet().Set(f.Name, value); err != nil {
+				return err
+			}
+			if check != nil {
+				return check(value)
+			}
+			return nil
+		}
+		schema = append(schema, p)
+	})
+	return schema
+}
+
+// newMainFlagSet returns the flag set of a regular run for configSchema.
+func newMainFlagSet() *flag.FlagSet {
+	return newFlagSet(&options{}, &flagNames{})
+}
+
+// errUnknownConfigCommand is returned for a missing or unknown config subcommand.
+var errUnknownConfigCommand = fmt.Errorf("config: want validate, init, schema, show, or diff: %w", errUsage)
+
+// runConfig dispatches the config subcommands.
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return errUnknownConfigCommand
+	}
+	switch args[0] {
+	case "validate":
+		return runConfigValidate(args[1:])
+	case "init":
+		return runConfigInit(args[1:])
+	case "schema":
+		return runConfigSchema(args[1:])
+	case "show":
+		return runConfigShow(args[1:])
+	case "diff":
+		return runConfigDiff(args[1:])
+	default:
+		return errUnknownConfigCommand
+	}
+}
+
+// runConfigValidate checks config files against the schema of the flags and reports every problem with its line and
+// column, or that the file is valid.
+func runConfigValidate(args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	schema := configSchema(newMainFlagSet)
+	var errs []error
+	for _, path := range args {
+		settings, err := config.Load(path)
+		if err == nil {
+			err = validateConfig(schema, path, settings)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "%s: ok, %d settings\n", path, len(settings))
+	}
+	return errors.Join(errs...)
+}
+
+// validateConfig checks settings, loaded from the file at path, against schema. Config files cannot include other
+// config files.
+func validateConfig(schema config.Schema, path string, settings []config.Setting) error {
+	var includes []error
+	rest := make([]config.Setting, 0, len(settings))
+	for _, s := range settings {
+		if s.Name == "config" {
+			includes = append(includes, &config.PositionError{Path: path, Line: s.Line, Column: s.Column, Err: errors.New("config files cannot include other config files")})
+			continue
+		}
+		rest = append(rest, s)
+	}
+	return errors.Join(append(includes, schema.Validate(path, rest))...)
+}
+
+// configInitOptions holds the parsed command line of the config init subcommand.
+type configInitOptions struct {
+	output string
+	force  bool
+}
+
+// newConfigInitFlagSet returns the flag set of the config init subcommand.
+func newConfigInitFlagSet(opts *configInitOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release config init", flag.ContinueOnError)
+	fs.StringVar(&opts.output, "o", "ts-release.conf", "file to write the commented default config to, or - for stdout")
+	fs.BoolVar(&opts.force, "force", false, "overwrite an existing file")
+	return fs
+}
+
+// runConfigInit writes a config file that lists every setting with its description and default as a comment.
+func runConfigInit(args []string) error {
+	var opts configInitOptions
+	fs := newConfigInitFlagSet(&opts)
+	if err := parseSubcommand(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 || opts.output == "" {
+		return errUsage
+	}
+	text := configSchema(newMainFlagSet).Template("ts-release config file: one \"setting = value\" line per flag, without the dashes.\n" +
+		"Remove the '#' of a setting to change it; check the file with `ts-release config validate`.")
+	if opts.output == "-" {
+		_, err := os.Stdout.WriteString(text)
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !opts.force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(opts.output, flags, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("config: %s exists; pass --force to overwrite it", opts.output)
+	}
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return fmt.Errorf("config: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	fmt.Fprintf(os.Stdout, "wrote %s\n", opts.output)
+	return nil
+}
+
+// runConfigSchema prints the JSON Schema of config files.
+func runConfigSchema(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	data, err := configSchema(newMainFlagSet).JSON(configSchemaTitle)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+// configEnv lists the environment variables a build reads for a setting that is not set, in the order they are tried.
+var configEnv = map[string][]string{
+	"source-date-epoch": {"SOURCE_DATE_EPOCH"},
+	"proxy":             {"HTTPS_PROXY", "https_proxy"},
+}
+
+// configValue is one effective setting of a build and where it came from: "default", "environment <name>",
+// "<file>:<line>" of the config file, or "command line".
+type configValue struct {
+	name, value, source string
+}
+
+// display returns the value for printing, with the password of proxy URLs redacted.
+func (v configValue) display() string {
+	if v.name == "proxy" {
+		if u, err := url.Parse(v.value); err == nil && u.User != nil {
+			return u.Redacted()
+		}
+	}
+	return v.value
+}
+
+// configRecorder is a flag value that records every value given on the command line instead of parsing it, so the
+// flags can be merged with the config file in the order a build applies them.
+type configRecorder struct {
+	name    string
+	boolean bool
+	values  *[]config.Setting
+}
+
+func (r configRecorder) String() string   { return "" }
+func (r configRecorder) IsBoolFlag() bool { return r.boolean }
+
+func (r configRecorder) Set(value string) error {
+	*r.values = append(*r.values, config.Setting{Name: r.name, Value: value})
+	return nil
+}
+
+// recordBuildFlags adds every build flag to fs as a configRecorder that appends to given.
+func recordBuildFlags(fs *flag.FlagSet, given *[]config.Setting) {
+	newMainFlagSet().VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		fs.Var(configRecorder{name: f.Name, boolean: ok && b.IsBoolFlag(), values: given}, f.Name, f.Usage)
+	})
+}
+
+// effectiveConfig merges the defaults, the environment, the config file named by a config setting of given, and the
+// settings of given from the command line like a build does, and returns the resulting value of every flag in flag
+// order. Repeatable flags return one value per occurrence. The settings are checked like a build checks them.
+func effectiveConfig(given []config.Setting) ([]configValue, error) {
+	schema := configSchema(newMainFlagSet)
+	var path string
+	for _, s := range given {
+		if s.Name == "config" {
+			path = s.Value
+		}
+	}
+	var settings []config.Setting
+	if path != "" {
+		var err error
+		if settings, err = config.Load(path); err != nil {
+			return nil, err
+		}
+		if err := validateConfig(schema, path, settings); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range given {
+		for _, p := range schema {
+			if p.Name != s.Name {
+				continue
+			}
+			if err := p.Validate(s.Value); err != nil {
+				return nil, fmt.Errorf("--%s: %w", s.Name, err)
+			}
+		}
+	}
+
+	var values []configValue
+	newMainFlagSet().VisitAll(func(f *flag.Flag) {
+		var set []configValue
+		for _, s := range settings {
+			if s.Name == f.Name {
+				set = append(set, configValue{f.Name, s.Value, fmt.Sprintf("%s:%d", path, s.Line)})
+			}
+		}
+		for _, s := range given {
+			if s.Name == f.Name {
+				set = append(set, configValue{f.Name, s.Value, "command line"})
+			}
+		}
+		switch {
+		case len(set) > 0 && strings.Contains(f.Usage, "repeatable"):
+			values = append(values, set...)
+		case len(set) > 0:
+			values = append(values, set[len(set)-1])
+		default:
+			v := configValue{f.Name, f.DefValue, "default"}
+			for _, name := range configEnv[f.Name] {
+				if env := os.Getenv(name); env != "" {
+					v.value, v.source = env, "environment "+name
+					break
+				}
+			}
+			values = append(values, v)
+		}
+	})
+	return values, nil
+}
+
+// configShowOptions holds the parsed command line of the config show subcommand besides the build flags.
+type configShowOptions struct {
+	effective bool
+}
+
+// newConfigShowFlagSet returns the flag set of the config show subcommand; runConfigShow adds the build flags.
+func newConfigShowFlagSet(opts *configShowOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release config show", flag.ContinueOnError)
+	fs.BoolVar(&opts.effective, "effective", false, "list every setting, including the defaults, rather than only those the environment, config file, and flags set")
+	return fs
+}
+
+// runConfigShow prints the settings a build with the given flags would use, with the source of each value.
+func runConfigShow(args []string) error {
+	var opts configShowOptions
+	var given []config.Setting
+	fs := newConfigShowFlagSet(&opts)
+	recordBuildFlags(fs, &given)
+	if err := parseSubcommand(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+	values, err := effectiveConfig(given)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, v := range values {
+		if opts.effective || v.source != "default" {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", v.name, v.display(), v.source)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	return nil
+}
+
+// runConfigDiff prints the settings whose effective values differ between two config files, with repeatable settings
+// compared as their whole list. It prints only the header for files that build the same.
+func runConfigDiff(args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	var effective [2]map[string][]string
+	var names []string
+	for i, path := range args {
+		values, err := effectiveConfig([]config.Setting{{Name: "config", Value: path}})
+		if err != nil {
+			return err
+		}
+		effective[i] = map[string][]string{}
+		for _, v := range values {
+			if v.name == "config" {
+				continue
+			}
+			if i == 0 && effective[i][v.name] == nil {
+				names = append(names, v.name)
+			}
+			effective[i][v.name] = append(effective[i][v.name], v.display())
+		}
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "SETTING\t%s\t%s\n", args[0], args[1])
+	for _, name := range names {
+		a, b := effective[0][name], effective[1][name]
+		if !slices.Equal(a, b) {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", name, strings.Join(a, ", "), strings.Join(b, ", "))
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	return nil
+}
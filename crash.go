@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/nickhildebrandt/ts-release/internal/config"
+	"github.com/nickhildebrandt/ts-release/internal/diag"
+	"github.com/nickhildebrandt/ts-release/internal/trace"
+)
+
+// exitCrash is the exit status after an internal error, the status of an unrecovered panic.
+const exitCrash = 2
+
+// diagnosticEnv lists the environment variables a diagnostic bundle reports. Secret ones are only reported as set.
+var diagnosticEnv = []diag.EnvVar{
+	{Name: "SOURCE_DATE_EPOCH"},
+	{Name: "HTTPS_PROXY"},
+	{Name: "https_proxy"},
+	{Name: "HTTP_PROXY"},
+	{Name: "http_proxy"},
+	{Name: "NO_PROXY"},
+	{Name: "no_proxy"},
+	{Name: "TRACEPARENT"},
+	{Name: "OTEL_EXPORTER_OTLP_ENDPOINT"},
+	{Name: "OTEL_EXPORTER_OTLP_HEADERS", Secret: true},
+	{Name: "UNSPLASH_ACCESS_KEY", Secret: true},
+	{Name: "PEXELS_API_KEY", Secret: true},
+	{Name: "AWS_ACCESS_KEY_ID", Secret: true},
+	{Name: "AWS_SECRET_ACCESS_KEY", Secret: true},
+	{Name: "AWS_SESSION_TOKEN", Secret: true},
+}
+
+// The bugreport subcommand runs other subcommands, so it joins the map after its initialization.
+func init() {
+	subcommands["bugreport"] = runBugreport
+}
+
+// reportCrash turns a panic of main into a diagnostic bundle and exits with exitCrash; main defers it with its
+// arguments. Without a panic it does nothing.
+func reportCrash(args []string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "internal error: %v\n", r)
+	exitInternalError(fmt.Sprintf("panic: %v", r), allStacks(), args)
+}
+
+// exitInternalError writes the diagnostic bundle of an internal error while running args to a new temporary file,
+// prints its path, and exits with exitCrash. If the bundle cannot be written, it prints the stack instead.
+func exitInternalError(errText string, stack []byte, args []string) {
+	path, err := writeDiagnostics("", diagnosticReport(args, errText, stack))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n%s", err, stack)
+	} else {
+		fmt.Fprintf(os.Stderr, "A diagnostic bundle was written to %s; please attach it to a bug report.\n", path)
+	}
+	os.Exit(exitCrash)
+}
+
+// allStacks returns the stacks of all goroutines, the panicking one first when called while it unwinds.
+func allStacks() []byte {
+	buf := make([]byte, 1<<20)
+	return buf[:runtime.Stack(buf, true)]
+}
+
+// diagnosticReport returns the report of a bundle for the command line args, with the settings of its config file.
+func diagnosticReport(args []string, errText string, stack []byte) diag.Report {
+	report := diag.Report{Version: version, Command: args, Error: errText, Stack: stack, Env: diagnosticEnv}
+	if path := configArg(args); path != "" {
+		report.ConfigPath = path
+		settings, err := config.Load(path)
+		if err != nil {
+			report.Config = []string{"# " + err.Error()}
+		}
+		for _, s := range settings {
+			report.Config = append(report.Config, s.Name+" = "+s.Value)
+		}
+	}
+	return report
+}
+
+// configArg returns the value of the first --config flag in args, or "".
+func configArg(args []string) string {
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// writeDiagnostics writes the bundle of report and the recorded requests and timings to path, or to a new file in the
+// temporary directory if path is empty, and returns the path.
+func writeDiagnostics(path string, report diag.Report) (string, error) {
+	if path == "" {
+		f, err := os.CreateTemp("", "ts-release-diag-*.zip")
+		if err != nil {
+			return "", fmt.Errorf("diag: %w", err)
+		}
+		path = f.Name()
+		f.Close()
+	}
+	if err := diag.Default.Write(path, report); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// bugreportOptions holds the parsed command line of the bugreport subcommand.
+type bugreportOptions struct {
+	output string
+}
+
+// newBugreportFlagSet returns the flag set of the bugreport subcommand.
+func newBugreportFlagSet(opts *bugreportOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release bugreport", flag.ContinueOnError)
+	fs.StringVar(&opts.output, "o", "", "file to write the diagnostic bundle to (default: a new file in the temporary directory)")
+	return fs
+}
+
+// runBugreport writes a diagnostic bundle. Given a command line after --, it first runs it like ts-release would, so
+// the bundle holds its error, HTTP requests, and timings; the bundle is written even if the command fails or panics.
+func runBugreport(args []string) error {
+	var opts bugreportOptions
+	fs := newBugreportFlagSet(&opts)
+	if err := parseSubcommand(fs, args); err != nil {
+		return err
+	}
+	command := fs.Args()
+	if len(command) > 0 && command[0] == "bugreport" {
+		return errUsage
+	}
+	var errText string
+	var stack []byte
+	if len(command) > 0 {
+		err := runRecovered(command, &stack)
+		var panicErr *diag.PanicError
+		if errors.As(err, &panicErr) {
+			stack = panicErr.Stack
+		}
+		if err != nil {
+			errText = err.Error()
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+	path, err := writeDiagnostics(opts.output, diagnosticReport(command, errText, stack))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote diagnostic bundle %s\n", path)
+	return nil
+}
+
+// runRecovered runs the command line args like main does and returns its error. A panic is returned as an error,
+// with the goroutine stacks in stack.
+func runRecovered(args []string, stack *[]byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err, *stack = fmt.Errorf("panic: %v", r), allStacks()
+		}
+	}()
+	if run := subcommands[args[0]]; run != nil {
+		return run(args[1:])
+	}
+	opts, err := parseArgs(args)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(opts.rootFS); err != nil || !info.IsDir() {
+		return fmt.Errorf("rootfs directory does not exist: %s", opts.rootFS)
+	}
+	return run(trace.FromEnvironment(context.Background(), os.Getenv), opts)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickhildebrandt/ts-release/internal/trace"
+)
+
+// generateOptions holds the flags of the generate subcommand that it adds to the generation flags.
+type generateOptions struct {
+	format string
+	output string
+}
+
+// imageFormats are the encodings the generate subcommand writes, in a stable order for help output and validation.
+var imageFormats = []string{"png", "jpeg"}
+
+// addGenerateFlags adds the flags of the generate subcommand to fs.
+func addGenerateFlags(fs *flag.FlagSet, opts *generateOptions) {
+	fs.StringVar(&opts.format, "format", "png", "encoding of the written wallpaper: "+strings.Join(imageFormats, ", "))
+	fs.StringVar(&opts.output, "o", "-", "file to write the wallpaper to, or - for stdout")
+}
+
+// newGenerateFlagSet returns the flag set of the generate subcommand without the generation flags, for usage.
+func newGenerateFlagSet(opts *generateOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release generate", flag.ContinueOnError)
+	addGenerateFlags(fs, opts)
+	return fs
+}
+
+// runGenerate builds like a regular run, but into a temporary directory, and writes only the wallpaper, with its
+// metadata, to -o. Its flags mix with the generation flags before the target name. With -o -, stdout carries nothing
+// but the encoded image, so it can be piped into other tools; warnings and errors go to stderr as always.
+func runGenerate(args []string) error {
+	var genOpts generateOptions
+	opts, positional, err := parseFlagsWith(args, func(fs *flag.FlagSet) { addGenerateFlags(fs, &genOpts) })
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || positional[0] == "" || genOpts.output == "" {
+		return errUsage
+	}
+	name := ""
+	switch genOpts.format {
+	case "png":
+		name, opts.png = "background.png", true
+	case "jpeg":
+		name = "background.jpg"
+	default:
+		return fmt.Errorf("generate: unknown format %q", genOpts.format)
+	}
+	if genOpts.output == "-" && opts.dumpLayout == "-" {
+		return fmt.Errorf("generate: -o - writes the image to stdout; pass a file to --dump-layout")
+	}
+	if genOpts.output == "-" && opts.reportFile == "-" {
+		return fmt.Errorf("generate: -o - writes the image to stdout; pass a file to --report")
+	}
+	if genOpts.output == "-" && opts.compareBootSplash {
+		return fmt.Errorf("generate: -o - writes the image to stdout; leave out --compare-boot-splash")
+	}
+	if opts.targetName, err = checkText(opts, "target name", positional[0]); err != nil {
+		return err
+	}
+	staging, err := os.MkdirTemp("", "ts-release-generate-")
+	if err != nil {
+		return fmt.Errorf("generate: %w", err)
+	}
+	defer os.RemoveAll(staging)
+	opts.rootFS = staging
+
+	if err := run(trace.FromEnvironment(context.Background(), os.Getenv), opts); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(filepath.Join(staging, filepath.FromSlash(path.Join(opts.installLayout().BackgroundDir, name))))
+	if err != nil {
+		return fmt.Errorf("generate: %w", err)
+	}
+	if genOpts.output == "-" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = os.WriteFile(genOpts.output, data, 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("generate: %w", err)
+	}
+	return nil
+}
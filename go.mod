@@ -4,4 +4,8 @@ go 1.24.4
 
 require golang.org/x/image v0.18.0
 
-require golang.org/x/text v0.16.0 // indirect
+require golang.org/x/text v0.16.0
+
+require github.com/tetratelabs/wazero v1.9.0
+
+require golang.org/x/sync v0.7.0
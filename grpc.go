@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickhildebrandt/ts-release/internal/inspect"
+	"github.com/nickhildebrandt/ts-release/internal/install"
+	"github.com/nickhildebrandt/ts-release/internal/renderapi"
+	"github.com/nickhildebrandt/ts-release/internal/rpc"
+	"github.com/nickhildebrandt/ts-release/internal/wallpaper"
+)
+
+// rpcStages maps the stages of run and renderImage to the stages of the gRPC API.
+var rpcStages = map[string]renderapi.Stage{
+	stageFetch:   renderapi.StageFetch,
+	stageRender:  renderapi.StageRender,
+	stageEncode:  renderapi.StageEncode,
+	stageInstall: renderapi.StageInstall,
+}
+
+// rpcServer returns the gRPC RenderService of api/tsrelease/v1/render.proto, sharing the limits of the HTTP API.
+func (s *renderServer) rpcServer() *rpc.Server {
+	server := rpc.NewServer(int(s.opts.maxBody))
+	server.Handle(renderapi.MethodRender, s.rpcRender)
+	server.Handle(renderapi.MethodInstall, s.rpcInstall)
+	server.Handle(renderapi.MethodVerify, s.rpcVerify)
+	return server
+}
+
+// rpcRender streams the progress of a render and then the encoded wallpaper.
+func (s *renderServer) rpcRender(ctx context.Context, data []byte, send func([]byte) error) error {
+	var req renderapi.RenderRequest
+	if err := req.Unmarshal(data); err != nil {
+		return rpc.Errorf(rpc.InvalidArgument, "%v", err)
+	}
+	if !s.acquire() {
+		return rpc.Errorf(rpc.ResourceExhausted, "%d renders are running", s.opts.maxConcurrent)
+	}
+	defer s.release()
+	job, err := s.prepare(renderRequestOf(req), "")
+	if err != nil {
+		return rpc.Errorf(rpc.InvalidArgument, "%v", err)
+	}
+	var sendErr error
+	job.opts.progress = func(stage string) {
+		if sendErr == nil {
+			event := renderapi.RenderEvent{Progress: &renderapi.Progress{Stage: rpcStages[stage]}}
+			sendErr = send(event.Marshal())
+		}
+	}
+	out, err := renderImage(ctx, job)
+	if err != nil {
+		return rpcError(err)
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+	event := renderapi.RenderEvent{Result: &renderapi.RenderResult{
+		Image:            out.data,
+		ContentType:      out.contentType,
+		BuildID:          out.buildID,
+		WallpaperID:      out.background.WallpaperID,
+		BackgroundSource: out.background.Source,
+	}}
+	return send(event.Marshal())
+}
+
+// rpcInstall generates all artifacts into a rootfs below --install-root, streaming the progress of the build.
+func (s *renderServer) rpcInstall(ctx context.Context, data []byte, send func([]byte) error) error {
+	var req renderapi.InstallRequest
+	if err := req.Unmarshal(data); err != nil {
+		return rpc.Errorf(rpc.InvalidArgument, "%v", err)
+	}
+	if req.Render.Width != 0 || req.Render.Height != 0 || req.Render.Format != renderapi.FormatUnspecified {
+		return rpc.Errorf(rpc.InvalidArgument, "width, height, and format do not apply to Install")
+	}
+	rootFS, err := s.resolveRootFS(req.RootFS)
+	if err != nil {
+		return err
+	}
+	if !s.acquire() {
+		return rpc.Errorf(rpc.ResourceExhausted, "%d renders are running", s.opts.maxConcurrent)
+	}
+	defer s.release()
+	job, err := s.prepare(renderRequestOf(req.Render), rootFS)
+	if err != nil {
+		return rpc.Errorf(rpc.InvalidArgument, "%v", err)
+	}
+	var sendErr error
+	job.opts.progress = func(stage string) {
+		if sendErr == nil {
+			event := renderapi.InstallEvent{Progress: &renderapi.Progress{Stage: rpcStages[stage]}}
+			sendErr = send(event.Marshal())
+		}
+	}
+	if err := run(ctx, job.opts); err != nil {
+		return rpcError(err)
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+	checks, err := inspect.VerifyRootFS(rootFS)
+	if err != nil {
+		return rpc.Errorf(rpc.Internal, "%v", err)
+	}
+	result := &renderapi.InstallResult{
+		BuildID:     releaseValue(rootFS, job.opts.installLayout(), "TSSH_BUILD_ID"),
+		WallpaperID: releaseValue(rootFS, job.opts.installLayout(), "TSSH_WALLPAPER_ID"),
+	}
+	for _, c := range checks {
+		result.Files = append(result.Files, c.Path)
+	}
+	event := renderapi.InstallEvent{Result: result}
+	return send(event.Marshal())
+}
+
+// rpcVerify checks the files of a rootfs below --install-root against its manifest.
+func (s *renderServer) rpcVerify(ctx context.Context, data []byte, send func([]byte) error) error {
+	var req renderapi.VerifyRequest
+	if err := req.Unmarshal(data); err != nil {
+		return rpc.Errorf(rpc.InvalidArgument, "%v", err)
+	}
+	rootFS, err := s.resolveRootFS(req.RootFS)
+	if err != nil {
+		return err
+	}
+	checks, err := inspect.VerifyRootFS(rootFS)
+	if errors.Is(err, fs.ErrNotExist) {
+		return rpc.Errorf(rpc.NotFound, "%s has no manifest", req.RootFS)
+	}
+	if err != nil {
+		return rpc.Errorf(rpc.FailedPrecondition, "%v", err)
+	}
+	resp := renderapi.VerifyResponse{OK: true}
+	_, layout, _ := install.DetectLayout(rootFS)
+	if build, err := os.ReadFile(filepath.Join(rootFS, filepath.FromSlash(layout.Build()))); err == nil {
+		resp.BuildID = strings.TrimSpace(string(build))
+	}
+	states := map[string]renderapi.FileState{
+		inspect.ChecksumOK:       renderapi.FileStateOK,
+		inspect.ChecksumMismatch: renderapi.FileStateMismatch,
+		inspect.ChecksumMissing:  renderapi.FileStateMissing,
+	}
+	for _, c := range checks {
+		resp.OK = resp.OK && c.State == inspect.ChecksumOK
+		resp.Files = append(resp.Files, renderapi.FileCheck{Path: c.Path, State: states[c.State]})
+	}
+	return send(resp.Marshal())
+}
+
+// resolveRootFS returns the directory of a rootfs given relative to --install-root. Paths that leave the install
+// root are rejected.
+func (s *renderServer) resolveRootFS(rel string) (string, error) {
+	if s.opts.installRoot == "" {
+		return "", rpc.Errorf(rpc.FailedPrecondition, "serve runs without --install-root")
+	}
+	if rel == "" || !filepath.IsLocal(rel) {
+		return "", rpc.Errorf(rpc.InvalidArgument, "rootfs %q must be a relative path inside the install root", rel)
+	}
+	rootFS := filepath.Join(s.opts.installRoot, rel)
+	if info, err := os.Stat(rootFS); err != nil || !info.IsDir() {
+		return "", rpc.Errorf(rpc.NotFound, "rootfs %s does not exist", rel)
+	}
+	return rootFS, nil
+}
+
+// renderRequestOf converts a gRPC render request into the request of the HTTP API.
+func renderRequestOf(req renderapi.RenderRequest) renderRequest {
+	formats := map[renderapi.ImageFormat]string{renderapi.FormatPNG: "png", renderapi.FormatJPEG: "jpeg"}
+	format, ok := formats[req.Format]
+	if !ok && req.Format != renderapi.FormatUnspecified {
+		format = fmt.Sprintf("format %d", req.Format)
+	}
+	out := renderRequest{
+		TargetName:  req.TargetName,
+		BuildID:     req.BuildID,
+		Subtitle:    req.Subtitle,
+		Channel:     req.Channel,
+		Layout:      req.Layout,
+		Width:       int(req.Width),
+		Height:      int(req.Height),
+		Format:      format,
+		WallpaperID: req.WallpaperID,
+	}
+	if req.ThemeJSON != "" {
+		out.Theme = json.RawMessage(req.ThemeJSON)
+	}
+	return out
+}
+
+// rpcError maps a failed render or build to a status: text too long for the image is InvalidArgument, fetch errors
+// that may pass when retried are Unavailable, other fetch errors FailedPrecondition, and everything else Internal.
+func rpcError(err error) error {
+	if errors.Is(err, wallpaper.ErrTextTooLong) {
+		return rpc.Errorf(rpc.InvalidArgument, "%v", err)
+	}
+	var fetchErr *wallpaper.FetchError
+	if errors.As(err, &fetchErr) {
+		code := rpc.FailedPrecondition
+		if fetchErr.IsRetryable() {
+			code = rpc.Unavailable
+		}
+		return rpc.Errorf(code, "%v; hint (%s error): %s", err, fetchErr.Kind, fetchErr.Hint())
+	}
+	return rpc.Errorf(rpc.Internal, "%v", err)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/nickhildebrandt/ts-release/internal/hook"
+	"github.com/nickhildebrandt/ts-release/internal/install"
+	"github.com/nickhildebrandt/ts-release/internal/trace"
+)
+
+// errMissingHookTool is returned for a missing hook subcommand.
+var errMissingHookTool = fmt.Errorf("hook: want mkosi or debos: %w", errUsage)
+
+// runHook builds like a regular run, but into the staging directory that mkosi or debos copies into the image below
+// the given image definition directory, and writes the glue that runs in the image afterwards.
+func runHook(args []string) error {
+	if len(args) == 0 {
+		return errMissingHookTool
+	}
+	tool, err := hook.ParseTool(args[0])
+	if err != nil {
+		return err
+	}
+	opts, err := parseArgs(args[1:])
+	if err != nil {
+		return err
+	}
+	if opts.installProfile != install.ProfileLinux {
+		return fmt.Errorf("hook %s: images built with %s need --install-profile %s", tool, tool, install.ProfileLinux)
+	}
+	if info, err := os.Stat(opts.rootFS); err != nil || !info.IsDir() {
+		return fmt.Errorf("hook %s: image definition directory %s does not exist", tool, opts.rootFS)
+	}
+	dir := opts.rootFS
+	opts.rootFS = tool.RootFS(dir)
+	if err := os.MkdirAll(opts.rootFS, 0o755); err != nil {
+		return fmt.Errorf("hook %s: %w", tool, err)
+	}
+
+	if err := run(trace.FromEnvironment(context.Background(), os.Getenv), opts); err != nil {
+		return err
+	}
+	hookOpts := hook.Options{Manifest: opts.installLayout().Manifest()}
+	if opts.splashFrames > 0 {
+		hookOpts.PlymouthTheme = path.Base(opts.installLayout().SplashDir)
+	}
+	glue, err := hook.Write(dir, tool, hookOpts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "staged %s for %s\nwrote %s\n", opts.rootFS, tool, glue)
+	if tool == hook.ToolDebos {
+		fmt.Fprintf(os.Stdout, "add the actions of %s to the recipe after the packages are installed\n", glue)
+	}
+	return nil
+}
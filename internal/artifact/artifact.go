@@ -0,0 +1,184 @@
+// Package artifact packs the generated files of a build into a signed .tsrelease file and reads them back, so a
+// build can be generated once, archived and checked, and applied to many rootfs trees later.
+//
+// A .tsrelease file is a tar file whose first entry is manifest.json, followed by manifest.json.sig (the raw Ed25519
+// signature of the manifest) and the files the manifest lists with their SHA-256, named by their rootfs-relative
+// paths.
+package artifact
+
+import (
+	"archive/tar"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Extension is the file name extension of artifact bundles.
+const Extension = ".tsrelease"
+
+// ManifestName and SignatureName are the first two entries of a bundle.
+const (
+	ManifestName  = "manifest.json"
+	SignatureName = "manifest.json.sig"
+)
+
+// maxFileSize bounds each packed file, so a damaged bundle cannot exhaust memory while it is read.
+const maxFileSize = 256 << 20
+
+// ErrSignature is wrapped when a bundle's signature does not verify with the given public key.
+var ErrSignature = errors.New("invalid signature")
+
+// File is one generated file of a build.
+type File struct {
+	// Path is the clean, slash-separated location in the rootfs, e.g. "usr/share/backgrounds/tssh/background.jpg".
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+
+	// Data is the content; it is not part of the manifest.
+	Data []byte `json:"-"`
+}
+
+// Manifest describes the build a bundle holds and lists its files.
+type Manifest struct {
+	Created          time.Time `json:"created"`
+	TargetName       string    `json:"target"`
+	BuildID          string    `json:"build_id"`
+	GeneratorVersion string    `json:"generator_version"`
+	// Profile is the install profile whose layout the paths follow.
+	Profile string `json:"profile"`
+	// PackagesSHA256 is the hex SHA-256 of the package list given with the build, empty if none was.
+	PackagesSHA256 string `json:"packages_sha256,omitempty"`
+	Files          []File `json:"files"`
+}
+
+// Write writes a bundle of the build m and its files signed with key; the files of m are ignored. It returns an error
+// for paths that are not clean rootfs-relative paths, or duplicates.
+func Write(w io.Writer, key ed25519.PrivateKey, m Manifest, files []File) error {
+	m.Created = m.Created.UTC()
+	m.Files = nil
+	seen := map[string]bool{}
+	for _, f := range files {
+		if err := validatePath(f.Path); err != nil {
+			return err
+		}
+		if seen[f.Path] {
+			return fmt.Errorf("artifact: duplicate file %q", f.Path)
+		}
+		seen[f.Path] = true
+		sum := sha256.Sum256(f.Data)
+		f.SHA256 = hex.EncodeToString(sum[:])
+		m.Files = append(m.Files, File{Path: f.Path, SHA256: f.SHA256})
+	}
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("artifact: %w", err)
+	}
+
+	tw := tar.NewWriter(w)
+	entries := []File{{Path: ManifestName, Data: raw}, {Path: SignatureName, Data: ed25519.Sign(key, raw)}}
+	for _, e := range append(entries, files...) {
+		// The creation time keeps bundles of reproducible builds bit-identical.
+		header := &tar.Header{Name: e.Path, Mode: 0o644, Size: int64(len(e.Data)), ModTime: m.Created, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("artifact: write %s: %w", e.Path, err)
+		}
+		if _, err := tw.Write(e.Data); err != nil {
+			return fmt.Errorf("artifact: write %s: %w", e.Path, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("artifact: %w", err)
+	}
+	return nil
+}
+
+// Read verifies a bundle with pub and returns its manifest and files in bundle order. It returns an error wrapping
+// ErrSignature for bundles that were not signed with the matching key or were altered, and an error if a file is
+// missing, not listed, or does not match its checksum.
+func Read(r io.Reader, pub ed25519.PublicKey) (Manifest, []File, error) {
+	tr := tar.NewReader(r)
+	raw, err := readEntry(tr, ManifestName)
+	if err != nil {
+		return Manifest{}, nil, err
+	}
+	sig, err := readEntry(tr, SignatureName)
+	if err != nil {
+		return Manifest{}, nil, err
+	}
+	if !ed25519.Verify(pub, raw, sig) {
+		return Manifest{}, nil, fmt.Errorf("artifact: %w", ErrSignature)
+	}
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return Manifest{}, nil, fmt.Errorf("artifact: decode manifest: %w", err)
+	}
+	expected := map[string]File{}
+	for _, f := range m.Files {
+		if err := validatePath(f.Path); err != nil {
+			return Manifest{}, nil, err
+		}
+		expected[f.Path] = f
+	}
+
+	var files []File
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Manifest{}, nil, fmt.Errorf("artifact: read bundle: %w", err)
+		}
+		f, ok := expected[header.Name]
+		if !ok {
+			return Manifest{}, nil, fmt.Errorf("artifact: %s is not listed in the manifest", header.Name)
+		}
+		delete(expected, header.Name)
+		data, err := io.ReadAll(io.LimitReader(tr, maxFileSize+1))
+		if err != nil {
+			return Manifest{}, nil, fmt.Errorf("artifact: read %s: %w", header.Name, err)
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != f.SHA256 {
+			return Manifest{}, nil, fmt.Errorf("artifact: %s: checksum mismatch", header.Name)
+		}
+		f.Data = data
+		files = append(files, f)
+	}
+	for name := range expected {
+		return Manifest{}, nil, fmt.Errorf("artifact: %s is missing from the bundle", name)
+	}
+	return m, files, nil
+}
+
+// readEntry reads the next bundle entry, which must be called name.
+func readEntry(tr *tar.Reader, name string) ([]byte, error) {
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("artifact: read %s: %w", name, err)
+	}
+	if header.Name != name {
+		return nil, fmt.Errorf("artifact: bundle starts with %q, want %s", header.Name, name)
+	}
+	data, err := io.ReadAll(io.LimitReader(tr, maxFileSize))
+	if err != nil {
+		return nil, fmt.Errorf("artifact: read %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// validatePath checks that p is a clean rootfs-relative path that cannot leave the rootfs or clash with the manifest
+// and signature entries.
+func validatePath(p string) error {
+	if p == "" || p == ManifestName || p == SignatureName || path.IsAbs(p) || path.Clean(p) != p || p == ".." ||
+		strings.HasPrefix(p, "../") || strings.Contains(p, "\\") {
+		return fmt.Errorf("artifact: %q is not a clean rootfs-relative path", p)
+	}
+	return nil
+}
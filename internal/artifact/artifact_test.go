@@ -0,0 +1,55 @@
+package artifact
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWriteRead_VerifiesSignatureAndChecksums expects a bundle to read back with its manifest and files when the
+// public key matches, and to be rejected for another key, altered content, or paths leaving the rootfs.
+func TestWriteRead_VerifiesSignatureAndChecksums(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []File{
+		{Path: "boot/splash.bmp", Data: []byte("BMP-DATA")},
+		{Path: "etc/tssh.build", Data: []byte("42\n")},
+	}
+	build := Manifest{Created: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), TargetName: "t", BuildID: "42", Profile: "linux"}
+	var bundle bytes.Buffer
+	if err := Write(&bundle, key, build, files); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+
+	m, got, err := Read(bytes.NewReader(bundle.Bytes()), pub)
+	if err != nil {
+		t.Fatalf("Read error: %v", err)
+	}
+	if m.BuildID != "42" || len(m.Files) != 2 || len(got) != 2 || got[0].Path != "boot/splash.bmp" || string(got[1].Data) != "42\n" {
+		t.Fatalf("Read: got %+v, %+v", m, got)
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	if _, _, err := Read(bytes.NewReader(bundle.Bytes()), otherPub); !errors.Is(err, ErrSignature) {
+		t.Fatalf("other key: got %v, want ErrSignature", err)
+	}
+	altered := bytes.Replace(bundle.Bytes(), []byte("BMP-DATA"), []byte("BMP-D4TA"), 1)
+	if _, _, err := Read(bytes.NewReader(altered), pub); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("altered file: got %v", err)
+	}
+
+	for _, p := range []string{"../etc/passwd", "/etc/passwd", "etc/../boot", "", ManifestName} {
+		if err := Write(&bytes.Buffer{}, key, build, []File{{Path: p}}); err == nil {
+			t.Fatalf("Write accepted %q", p)
+		}
+	}
+	if err := Write(&bytes.Buffer{}, key, build, append(files, files[0])); err == nil {
+		t.Fatalf("Write accepted a duplicate file")
+	}
+}
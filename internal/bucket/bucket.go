@@ -0,0 +1,254 @@
+// Package bucket fetches pre-approved background images from an S3-compatible bucket or a list of HTTPS URLs.
+// Every download is verified against a SHA-256 checksum before it is used.
+package bucket
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// DefaultEndpoint is the AWS S3 endpoint; other S3-compatible stores (MinIO, Ceph, R2) have their own.
+const DefaultEndpoint = "https://s3.amazonaws.com"
+
+// DefaultRegion is used for signing when no region is configured.
+const DefaultRegion = "us-east-1"
+
+// maxListPages bounds how many pages of a prefix are listed, so a huge bucket cannot stall a build.
+const maxListPages = 20
+
+// ErrChecksumMismatch is wrapped when downloaded data does not match its expected SHA-256.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrNoChecksum is wrapped when an S3 object has neither a SHA-256 checksum nor a .sha256 sidecar object.
+var ErrNoChecksum = errors.New("no checksum")
+
+// ErrTooLarge is wrapped when an object or listed image exceeds its size limit.
+var ErrTooLarge = errors.New("too large")
+
+// DefaultMaxDownloadSize bounds object and image downloads unless a limit is given. 256 MiB is far above the files of
+// the largest backgrounds, yet keeps a server that sends an endless body from exhausting memory.
+const DefaultMaxDownloadSize = 256 << 20
+
+// imageExtensions are the object suffixes considered backgrounds when listing a prefix.
+var imageExtensions = []string{".jpg", ".jpeg", ".png"}
+
+// Location is an object or prefix in a bucket.
+type Location struct {
+	Bucket string
+	// Key is an object key, or a prefix ending in "/" (or empty) to choose among the images below it.
+	Key string
+}
+
+// IsPrefix reports whether the location names a prefix rather than a single object.
+func (l Location) IsPrefix() bool {
+	return l.Key == "" || strings.HasSuffix(l.Key, "/")
+}
+
+// String formats the location as an s3:// URL.
+func (l Location) String() string {
+	return "s3://" + l.Bucket + "/" + l.Key
+}
+
+// ParseLocation parses s3://bucket/key or s3://bucket/prefix/.
+// It returns an error for other schemes or a missing bucket.
+func ParseLocation(s string) (Location, error) {
+	rest, ok := strings.CutPrefix(s, "s3://")
+	bucket, key, _ := strings.Cut(rest, "/")
+	if !ok || bucket == "" {
+		return Location{}, fmt.Errorf("bucket: invalid location %q: want s3://bucket/key or s3://bucket/prefix/", s)
+	}
+	return Location{Bucket: bucket, Key: key}, nil
+}
+
+// Client reads objects from an S3-compatible store with path-style requests. It is safe for concurrent use.
+type Client struct {
+	// HTTPClient sends all requests; nil selects http.DefaultClient.
+	HTTPClient *http.Client
+	// Endpoint is the store's base URL; empty selects DefaultEndpoint.
+	Endpoint string
+	// Region is used for signing; empty selects DefaultRegion.
+	Region string
+	// Credentials sign the requests; zero credentials send anonymous requests to public buckets.
+	Credentials Credentials
+	// MaxDownloadSize bounds the bytes of an object Get returns; zero selects DefaultMaxDownloadSize.
+	MaxDownloadSize int64
+
+	// now returns the signing time; nil selects time.Now.
+	now func() time.Time
+}
+
+// Keys lists the image keys below prefix in sorted order, following continuation tokens.
+func (c *Client) Keys(bucket, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for page := 0; page < maxListPages; page++ {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := c.do("list", bucket, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("bucket: list: decode response: %w", err)
+		}
+		for _, object := range result.Contents {
+			if isImage(object.Key) {
+				keys = append(keys, object.Key)
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	return keys, nil
+}
+
+// Get downloads an object and verifies it against the store's SHA-256 checksum or, failing that, against a
+// "<key>.sha256" sidecar object in sha256sum syntax. It returns an error wrapping ErrNoChecksum if neither exists
+// and ErrChecksumMismatch if the data does not match.
+func (c *Client) Get(bucket, key string) ([]byte, error) {
+	resp, err := c.do("get", bucket, key, nil, http.Header{"X-Amz-Checksum-Mode": {"ENABLED"}})
+	if err != nil {
+		return nil, err
+	}
+	data, err := readBody(resp.Body, c.MaxDownloadSize)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("bucket: get %s: %w", key, err)
+	}
+
+	// Multipart uploads carry a checksum of checksums ("<base64>-<parts>"), which cannot be compared directly.
+	if sum := resp.Header.Get("X-Amz-Checksum-Sha256"); sum != "" && !strings.Contains(sum, "-") {
+		raw, err := base64.StdEncoding.DecodeString(sum)
+		if err != nil {
+			return nil, fmt.Errorf("bucket: get %s: invalid checksum header %q", key, sum)
+		}
+		if err := Verify(data, hex.EncodeToString(raw)); err != nil {
+			return nil, fmt.Errorf("bucket: get %s: %w", key, err)
+		}
+		return data, nil
+	}
+
+	sidecar, err := c.do("get", bucket, key+".sha256", nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("bucket: get %s: %w: the store sent no SHA-256 checksum and the sidecar failed: %w", key, ErrNoChecksum, err)
+	}
+	text, err := io.ReadAll(io.LimitReader(sidecar.Body, 1024))
+	sidecar.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("bucket: get %s.sha256: read body: %w", key, err)
+	}
+	fields := strings.Fields(string(text))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("bucket: get %s: %w: empty sidecar", key, ErrNoChecksum)
+	}
+	if err := Verify(data, fields[0]); err != nil {
+		return nil, fmt.Errorf("bucket: get %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// Verify returns an error wrapping ErrChecksumMismatch unless data hashes to the hex SHA-256 want.
+func Verify(data []byte, want string) error {
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, want) {
+		return fmt.Errorf("%w: got sha256 %s, want %s", ErrChecksumMismatch, got, strings.ToLower(want))
+	}
+	return nil
+}
+
+// do sends a signed GET request for a bucket or object and returns the response of a 2xx status.
+func (c *Client) do(op, bucket, key string, query url.Values, header http.Header) (*http.Response, error) {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	target := strings.TrimSuffix(endpoint, "/") + "/" + escape(bucket)
+	if key != "" {
+		target += escapePath("/" + key)
+	}
+	if len(query) > 0 {
+		target += "?" + canonicalQuery(query)
+	}
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("bucket: %s: %w", op, err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if c.Credentials.AccessKeyID != "" {
+		region := c.Region
+		if region == "" {
+			region = DefaultRegion
+		}
+		now := c.now
+		if now == nil {
+			now = time.Now
+		}
+		c.Credentials.sign(req, region, now())
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("bucket: %s: request failed: %w", op, err)
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		resp.Body.Close()
+		return nil, fmt.Errorf("bucket: %s %s: http %d", op, Location{Bucket: bucket, Key: key}, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// isImage reports whether key has one of the image extensions.
+func isImage(key string) bool {
+	ext := strings.ToLower(path.Ext(key))
+	for _, e := range imageExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// readBody reads body up to limit bytes, zero selecting DefaultMaxDownloadSize, and returns an error wrapping
+// ErrTooLarge for longer bodies.
+func readBody(body io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		limit = DefaultMaxDownloadSize
+	}
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: larger than %d bytes", ErrTooLarge, limit)
+	}
+	return data, nil
+}
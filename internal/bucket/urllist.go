@@ -0,0 +1,90 @@
+package bucket
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Entry is one approved image of a URL list.
+type Entry struct {
+	URL string
+	// SHA256 is the expected hex SHA-256 of the image.
+	SHA256 string
+}
+
+// LoadList reads a URL list file; see ParseList for the syntax.
+func LoadList(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("bucket: %w", err)
+	}
+	defer f.Close()
+	entries, err := ParseList(f)
+	if err != nil {
+		return nil, fmt.Errorf("bucket: %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// ParseList parses a URL list: one "<sha256> <https-url>" pair per line, like sha256sum output with URLs instead of
+// file names. Blank lines and lines starting with '#' are ignored. It returns an error for malformed lines, plain
+// HTTP URLs, or an empty list.
+func ParseList(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: want <sha256> <https-url>", line)
+		}
+		if sum, err := hex.DecodeString(fields[0]); err != nil || len(sum) != 32 {
+			return nil, fmt.Errorf("line %d: invalid sha256 %q", line, fields[0])
+		}
+		u, err := url.Parse(fields[1])
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("line %d: %q is not an https URL", line, fields[1])
+		}
+		entries = append(entries, Entry{URL: fields[1], SHA256: strings.ToLower(fields[0])})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no images listed")
+	}
+	return entries, nil
+}
+
+// Download fetches the entry's image with httpClient (nil selects http.DefaultClient) and verifies its checksum.
+// It returns an error for non-2xx responses, one wrapping ErrTooLarge for images of more than maxSize bytes (zero
+// selects DefaultMaxDownloadSize), and one wrapping ErrChecksumMismatch for altered images.
+func (e Entry) Download(httpClient *http.Client, maxSize int64) ([]byte, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Get(e.URL)
+	if err != nil {
+		return nil, fmt.Errorf("bucket: download: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("bucket: download %s: http %d", e.URL, resp.StatusCode)
+	}
+	data, err := readBody(resp.Body, maxSize)
+	if err != nil {
+		return nil, fmt.Errorf("bucket: download %s: %w", e.URL, err)
+	}
+	if err := Verify(data, e.SHA256); err != nil {
+		return nil, fmt.Errorf("bucket: download %s: %w", e.URL, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,111 @@
+package buildid
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/gitinfo"
+)
+
+// Format selects the strategy used to derive a build ID when none is given explicitly.
+type Format string
+
+const (
+	FormatRFC3339 Format = "rfc3339"
+	FormatDate    Format = "date"
+	FormatGit     Format = "git"
+	FormatCI      Format = "ci"
+	FormatCounter Format = "counter"
+)
+
+// ciEnvVars lists the environment variables checked (in order) for a CI build number.
+var ciEnvVars = []string{
+	"TSSH_BUILD_NUMBER",
+	"BUILD_NUMBER",
+	"GITHUB_RUN_NUMBER",
+	"CI_PIPELINE_IID",
+	"BUILDKITE_BUILD_NUMBER",
+}
+
+// Options carries the inputs the individual strategies need.
+type Options struct {
+	// Now is the reference time for time-based formats.
+	Now time.Time
+	// Counter is the content of the counter persisted in the rootfs, empty if there is none yet. The caller persists
+	// the ID of FormatCounter once the build is installed.
+	Counter string
+	// GitDir is the working tree used for git describe; empty means the current directory.
+	GitDir string
+}
+
+// Formats returns all supported formats in a stable order for help output and validation.
+func Formats() []Format {
+	return []Format{FormatRFC3339, FormatDate, FormatGit, FormatCI, FormatCounter}
+}
+
+// ParseFormat converts a user-supplied format name into a Format.
+// It returns an error for unknown names.
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats() {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("buildid: unknown format %q", name)
+}
+
+// Generate derives a build ID using the given format.
+// It returns an error if the selected source (git, CI environment, counter file) is unavailable or invalid.
+func Generate(format Format, opts Options) (string, error) {
+	switch format {
+	case FormatRFC3339:
+		return opts.Now.UTC().Format(time.RFC3339), nil
+	case FormatDate:
+		return opts.Now.UTC().Format(time.DateOnly), nil
+	case FormatGit:
+		return fromGitDescribe(opts.GitDir)
+	case FormatCI:
+		return fromCIEnv()
+	case FormatCounter:
+		return nextCounter(opts.Counter)
+	default:
+		return "", fmt.Errorf("buildid: unknown format %q", format)
+	}
+}
+
+// fromGitDescribe runs git describe in dir and returns the result without a leading "v".
+// It returns an error if git is missing, dir is not a repository, or the output is empty.
+func fromGitDescribe(dir string) (string, error) {
+	out, err := gitinfo.Describe(dir)
+	if err != nil {
+		return "", fmt.Errorf("buildid: %w", err)
+	}
+	return strings.TrimPrefix(out, "v"), nil
+}
+
+// fromCIEnv returns the first non-empty CI build number from the known environment variables.
+// It returns an error if none of them is set.
+func fromCIEnv() (string, error) {
+	for _, name := range ciEnvVars {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("buildid: no CI build number found in %s", strings.Join(ciEnvVars, ", "))
+}
+
+// nextCounter returns the counter after the persisted one. An empty counter starts at 1; non-numeric content is an
+// error.
+func nextCounter(counter string) (string, error) {
+	var current uint64
+	if counter = strings.TrimSpace(counter); counter != "" {
+		var err error
+		if current, err = strconv.ParseUint(counter, 10, 64); err != nil {
+			return "", fmt.Errorf("buildid: invalid counter %q: %w", counter, err)
+		}
+	}
+	return strconv.FormatUint(current+1, 10), nil
+}
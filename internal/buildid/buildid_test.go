@@ -0,0 +1,131 @@
+package buildid
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixedNow is a deterministic reference time for time-based formats.
+var fixedNow = time.Date(2026, 1, 4, 13, 35, 13, 0, time.UTC)
+
+// TestGenerate_TimeFormats verifies the RFC3339 and date-only formats against a fixed reference time.
+// The test fails if either format deviates from the documented layout.
+func TestGenerate_TimeFormats(t *testing.T) {
+	cases := []struct {
+		format Format
+		want   string
+	}{
+		{format: FormatRFC3339, want: "2026-01-04T13:35:13Z"},
+		{format: FormatDate, want: "2026-01-04"},
+	}
+	for _, c := range cases {
+		got, err := Generate(c.format, Options{Now: fixedNow})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.format, err)
+		}
+		if got != c.want {
+			t.Fatalf("%s: got %q want %q", c.format, got, c.want)
+		}
+	}
+}
+
+// TestGenerate_CI_UsesFirstSetVariable expects the CI format to pick up a build number from the environment.
+// It also checks that an error is returned when no known variable is set.
+func TestGenerate_CI_UsesFirstSetVariable(t *testing.T) {
+	for _, name := range ciEnvVars {
+		t.Setenv(name, "")
+	}
+	if _, err := Generate(FormatCI, Options{}); err == nil {
+		t.Fatalf("expected error without CI variables")
+	}
+
+	t.Setenv("GITHUB_RUN_NUMBER", "42")
+	got, err := Generate(FormatCI, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "42" {
+		t.Fatalf("got %q want %q", got, "42")
+	}
+}
+
+// TestGenerate_Counter_Increments expects the counter to start at 1 and to follow the persisted value.
+// The test fails if a value is skipped or invalid content is accepted.
+func TestGenerate_Counter_Increments(t *testing.T) {
+	for counter, want := range map[string]string{"": "1", "1\n": "2", " 41 ": "42"} {
+		got, err := Generate(FormatCounter, Options{Counter: counter})
+		if err != nil || got != want {
+			t.Fatalf("counter %q: got %q, %v; want %q", counter, got, err, want)
+		}
+	}
+	if _, err := Generate(FormatCounter, Options{Counter: "garbage"}); err == nil {
+		t.Fatalf("expected error for invalid counter content")
+	}
+}
+
+// TestParseFormat_UnknownName_Error expects known names to round-trip and unknown names to fail.
+// This documents the accepted values for --build-id-format.
+func TestParseFormat_UnknownName_Error(t *testing.T) {
+	for _, f := range Formats() {
+		got, err := ParseFormat(string(f))
+		if err != nil || got != f {
+			t.Fatalf("ParseFormat(%q) = %q, %v", f, got, err)
+		}
+	}
+	if _, err := ParseFormat("semver"); err == nil {
+		t.Fatalf("expected error")
+	} else if !strings.Contains(err.Error(), "unknown format") {
+		t.Fatalf("unexpected error: %q", err.Error())
+	}
+}
+
+// TestCompare_OrdersTimestampsVersionsAndNumbers verifies each comparison on older, equal, and newer IDs.
+// The test fails if git describe output, pre-releases, or mixed dates and time stamps are misordered, or if IDs of
+// another shape are accepted.
+func TestCompare_OrdersTimestampsVersionsAndNumbers(t *testing.T) {
+	cases := []struct {
+		c    Comparison
+		a, b string
+		want int
+	}{
+		{CompareTimestamp, "2026-01-04T13:35:13Z", "2026-01-04T14:35:13+02:00", 1},
+		{CompareTimestamp, "2026-01-04", "2026-01-04T00:00:00Z", 0},
+		{CompareTimestamp, "2026-01-03", "2026-01-04T13:35:13Z", -1},
+		{CompareSemver, "v1.10.0", "1.9.9", 1},
+		{CompareSemver, "1.2.3", "1.2.3+build.7", 0},
+		{CompareSemver, "1.2.3-rc.1", "1.2.3", -1},
+		{CompareSemver, "1.2.3-rc.2", "1.2.3-rc.10", -1},
+		{CompareSemver, "1.2.3-alpha", "1.2.3-1", 1},
+		{CompareSemver, "1.2.3-4-gabc1234", "1.2.3", 1},
+		{CompareSemver, "1.2.3-4-gabc1234-dirty", "1.2.3-12-gdef5678", -1},
+		{CompareSemver, "1.2.3-rc.1-2-gabc1234", "1.2.3", -1},
+		{CompareNumber, "10", "9", 1},
+		{CompareNumber, "7", "7", 0},
+	}
+	for _, c := range cases {
+		got, err := Compare(c.c, c.a, c.b)
+		if err != nil {
+			t.Fatalf("%s %q %q: unexpected error: %v", c.c, c.a, c.b, err)
+		}
+		if got != c.want {
+			t.Fatalf("%s %q %q: got %d want %d", c.c, c.a, c.b, got, c.want)
+		}
+	}
+
+	for _, c := range []struct {
+		c    Comparison
+		a, b string
+	}{
+		{CompareTimestamp, "build-42", "2026-01-04"},
+		{CompareSemver, "1.2", "1.2.0"},
+		{CompareNumber, "42", "-1"},
+	} {
+		if _, err := Compare(c.c, c.a, c.b); err == nil {
+			t.Fatalf("%s %q %q: expected error", c.c, c.a, c.b)
+		}
+	}
+	if _, err := ParseComparison("lexical"); err == nil {
+		t.Fatalf("expected error for an unknown comparison")
+	}
+}
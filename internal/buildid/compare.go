@@ -0,0 +1,177 @@
+package buildid
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Comparison selects how two build IDs are ordered, e.g. to tell whether a build is newer than the installed one.
+type Comparison string
+
+const (
+	// CompareTimestamp orders RFC 3339 time stamps and dates, as produced by FormatRFC3339 and FormatDate.
+	CompareTimestamp Comparison = "timestamp"
+	// CompareSemver orders semantic versions with an optional "v" prefix, including git describe output such as
+	// "1.2.3-4-gabcdef0" as produced by FormatGit.
+	CompareSemver Comparison = "semver"
+	// CompareNumber orders non-negative integers, as produced by FormatCI and FormatCounter.
+	CompareNumber Comparison = "number"
+)
+
+// Comparisons returns all supported comparisons in a stable order for help output and validation.
+func Comparisons() []Comparison {
+	return []Comparison{CompareTimestamp, CompareSemver, CompareNumber}
+}
+
+// ParseComparison converts a user-supplied comparison name into a Comparison.
+// It returns an error for unknown names.
+func ParseComparison(name string) (Comparison, error) {
+	for _, c := range Comparisons() {
+		if string(c) == name {
+			return c, nil
+		}
+	}
+	return "", fmt.Errorf("buildid: unknown comparison %q", name)
+}
+
+// ComparisonFor returns the comparison that orders the build IDs of format.
+func ComparisonFor(format Format) Comparison {
+	switch format {
+	case FormatGit:
+		return CompareSemver
+	case FormatCI, FormatCounter:
+		return CompareNumber
+	default:
+		return CompareTimestamp
+	}
+}
+
+// Compare returns -1 if build ID a is older than b, 0 if they are equally new, and +1 if a is newer, ordered by c.
+// It returns an error if either ID cannot be read as c expects.
+func Compare(c Comparison, a, b string) (int, error) {
+	switch c {
+	case CompareTimestamp:
+		return compareParsed(a, b, parseTimestamp, func(x, y time.Time) int { return x.Compare(y) })
+	case CompareSemver:
+		return compareParsed(a, b, parseSemver, compareSemver)
+	case CompareNumber:
+		return compareParsed(a, b, func(s string) (uint64, error) { return strconv.ParseUint(s, 10, 64) }, cmp.Compare[uint64])
+	default:
+		return 0, fmt.Errorf("buildid: unknown comparison %q", c)
+	}
+}
+
+// compareParsed parses a and b with parse and orders the results with order.
+func compareParsed[T any](a, b string, parse func(string) (T, error), order func(x, y T) int) (int, error) {
+	x, err := parse(a)
+	if err != nil {
+		return 0, fmt.Errorf("buildid: build ID %q: %w", a, err)
+	}
+	y, err := parse(b)
+	if err != nil {
+		return 0, fmt.Errorf("buildid: build ID %q: %w", b, err)
+	}
+	return order(x, y), nil
+}
+
+// parseTimestamp parses an RFC 3339 time stamp or a date, which stands for midnight UTC.
+func parseTimestamp(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("not an RFC 3339 time stamp or date")
+	}
+	return t, nil
+}
+
+// semver is a parsed semantic version. Build metadata does not take part in the order and is dropped.
+type semver struct {
+	core       [3]uint64
+	prerelease []string
+	// commits is the number of commits after the tag in git describe output.
+	commits uint64
+}
+
+// describeSuffix matches the "-<commits>-g<hash>" git describe appends for commits after the tag.
+var describeSuffix = regexp.MustCompile(`-([0-9]+)-g[0-9a-f]+$`)
+
+// parseSemver parses a semantic version with an optional "v" prefix and git describe suffixes. A "-dirty" suffix is
+// ignored, as a dirty tree has no place in the order.
+func parseSemver(s string) (semver, error) {
+	var v semver
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "v"), "-dirty")
+	if m := describeSuffix.FindStringSubmatch(s); m != nil {
+		commits, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			return semver{}, fmt.Errorf("not a semantic version")
+		}
+		v.commits = commits
+		s = s[:len(s)-len(m[0])]
+	}
+	s, _, _ = strings.Cut(s, "+")
+	core, prerelease, hasPrerelease := strings.Cut(s, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return semver{}, fmt.Errorf("not a semantic version, want MAJOR.MINOR.PATCH")
+	}
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return semver{}, fmt.Errorf("not a semantic version, want MAJOR.MINOR.PATCH")
+		}
+		v.core[i] = n
+	}
+	if hasPrerelease {
+		v.prerelease = strings.Split(prerelease, ".")
+	}
+	return v, nil
+}
+
+// compareSemver orders versions by semantic versioning precedence, and versions that only differ in the commits
+// after their tag by those commits.
+func compareSemver(a, b semver) int {
+	for i := range a.core {
+		if c := cmp.Compare(a.core[i], b.core[i]); c != 0 {
+			return c
+		}
+	}
+	// A version without pre-release identifiers is newer than any pre-release of it.
+	switch {
+	case len(a.prerelease) == 0 && len(b.prerelease) > 0:
+		return 1
+	case len(a.prerelease) > 0 && len(b.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.prerelease) && i < len(b.prerelease); i++ {
+		if c := comparePrerelease(a.prerelease[i], b.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	if c := cmp.Compare(len(a.prerelease), len(b.prerelease)); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.commits, b.commits)
+}
+
+// comparePrerelease orders two pre-release identifiers: numeric ones numerically and before alphanumeric ones, which
+// are ordered lexically.
+func comparePrerelease(a, b string) int {
+	x, errA := strconv.ParseUint(a, 10, 64)
+	y, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil:
+		return cmp.Compare(x, y)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
@@ -0,0 +1,163 @@
+// Package bundle appends a set of background images to a copy of the ts-release executable and reads it back, so
+// downstreams can ship their own built-in backgrounds without patching or rebuilding the source.
+//
+// A bundled executable is the original executable, followed by a zip archive of the images and a trailer of the
+// archive's size (8 bytes, big-endian) and the magic "TSSHBNDL". Executables ignore trailing data, so it still runs.
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// magic ends the trailer of a bundled executable.
+const magic = "TSSHBNDL"
+
+// trailerSize is the size of the archive size field plus the magic.
+const trailerSize = 8 + len(magic)
+
+// ErrNoBundle is wrapped when an executable carries no bundle.
+var ErrNoBundle = errors.New("no bundle")
+
+// Image is one background of a bundle.
+type Image struct {
+	// Name is a flat file name ending in .jpg, .jpeg, or .png.
+	Name string
+	Data []byte
+}
+
+// Write writes exe, without any bundle it already carries, followed by images as a new bundle.
+// It returns an error for an empty set, duplicate names, or names that are not flat image file names.
+func Write(w io.Writer, exe []byte, images []Image) error {
+	if len(images) == 0 {
+		return fmt.Errorf("bundle: no images")
+	}
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	seen := map[string]bool{}
+	for _, img := range images {
+		if !IsImageName(img.Name) || path.Base(img.Name) != img.Name {
+			return fmt.Errorf("bundle: %q is not a .jpg, .jpeg, or .png file name", img.Name)
+		}
+		if seen[img.Name] {
+			return fmt.Errorf("bundle: duplicate image %q", img.Name)
+		}
+		seen[img.Name] = true
+		// Images are compressed already, so they are stored as they are.
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: img.Name, Method: zip.Store})
+		if err != nil {
+			return fmt.Errorf("bundle: %w", err)
+		}
+		if _, err := f.Write(img.Data); err != nil {
+			return fmt.Errorf("bundle: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("bundle: %w", err)
+	}
+
+	trailer := binary.BigEndian.AppendUint64(nil, uint64(archive.Len()))
+	trailer = append(trailer, magic...)
+	for _, part := range [][]byte{Strip(exe), archive.Bytes(), trailer} {
+		if _, err := w.Write(part); err != nil {
+			return fmt.Errorf("bundle: write: %w", err)
+		}
+	}
+	return nil
+}
+
+// Strip returns exe without its bundle, or exe itself if it carries none.
+func Strip(exe []byte) []byte {
+	if start, ok := locate(exe); ok {
+		return exe[:start]
+	}
+	return exe
+}
+
+// Carry returns exe, without any bundle it carries, followed by the bundle of from, and reports whether from carries
+// one; without one it returns exe unchanged. Self-update uses it so that a new executable keeps the backgrounds
+// bundled into the one it replaces.
+func Carry(exe, from []byte) ([]byte, bool) {
+	start, ok := locate(from)
+	if !ok {
+		return exe, false
+	}
+	stripped := Strip(exe)
+	return append(stripped[:len(stripped):len(stripped)], from[start:]...), true
+}
+
+// Load reads the bundle of the executable at path and returns its images as a file system with the images at
+// its root. It returns an error wrapping ErrNoBundle if the executable carries none.
+func Load(path string) (fs.FS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %w", err)
+	}
+	if info.Size() < int64(trailerSize) {
+		return nil, fmt.Errorf("bundle: %s: %w", path, ErrNoBundle)
+	}
+	trailer := make([]byte, trailerSize)
+	if _, err := f.ReadAt(trailer, info.Size()-int64(trailerSize)); err != nil {
+		return nil, fmt.Errorf("bundle: %s: %w", path, err)
+	}
+	size, ok := archiveSize(trailer, info.Size())
+	if !ok {
+		return nil, fmt.Errorf("bundle: %s: %w", path, ErrNoBundle)
+	}
+	archive := make([]byte, size)
+	if _, err := f.ReadAt(archive, info.Size()-int64(trailerSize)-size); err != nil {
+		return nil, fmt.Errorf("bundle: %s: %w", path, err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(archive), size)
+	if err != nil {
+		return nil, fmt.Errorf("bundle: %s: damaged archive: %w", path, err)
+	}
+	return zr, nil
+}
+
+// IsImageName reports whether name has one of the image extensions a bundle accepts.
+func IsImageName(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	default:
+		return false
+	}
+}
+
+// locate returns where the bundle of exe starts.
+func locate(exe []byte) (int64, bool) {
+	if len(exe) < trailerSize {
+		return 0, false
+	}
+	size, ok := archiveSize(exe[len(exe)-trailerSize:], int64(len(exe)))
+	if !ok {
+		return 0, false
+	}
+	return int64(len(exe)) - int64(trailerSize) - size, true
+}
+
+// archiveSize decodes a trailer of a file of total bytes; it fails for a missing magic or an impossible size.
+func archiveSize(trailer []byte, total int64) (int64, bool) {
+	if string(trailer[8:]) != magic {
+		return 0, false
+	}
+	size := binary.BigEndian.Uint64(trailer[:8])
+	if size > uint64(total-int64(trailerSize)) {
+		return 0, false
+	}
+	return int64(size), true
+}
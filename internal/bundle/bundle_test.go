@@ -0,0 +1,84 @@
+package bundle
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestWriteLoad_RoundTripAndReplace expects images written after an executable to load back unchanged, a second
+// bundle to replace the first, and plain executables to report ErrNoBundle.
+// The test fails if the executable part is altered, bundles stack up, or invalid names are accepted.
+func TestWriteLoad_RoundTripAndReplace(t *testing.T) {
+	dir := t.TempDir()
+	exe := []byte("\x7fELF pretend executable")
+	write := func(name string, exe []byte, images ...Image) []byte {
+		t.Helper()
+		var out bytes.Buffer
+		if err := Write(&out, exe, images); err != nil {
+			t.Fatalf("Write error: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), out.Bytes(), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return out.Bytes()
+	}
+
+	first := write("first", exe, Image{Name: "brand.jpg", Data: []byte("jpeg")}, Image{Name: "wide.png", Data: []byte("png")})
+	if !bytes.HasPrefix(first, exe) || !bytes.Equal(Strip(first), exe) {
+		t.Fatalf("executable part altered")
+	}
+	fsys, err := Load(filepath.Join(dir, "first"))
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if data, err := fs.ReadFile(fsys, "wide.png"); err != nil || string(data) != "png" {
+		t.Fatalf("wide.png: got %q, %v", data, err)
+	}
+
+	second := write("second", first, Image{Name: "night.jpg", Data: []byte("night")})
+	if !bytes.Equal(Strip(second), exe) {
+		t.Fatalf("rebundling kept the old bundle")
+	}
+	fsys, err = Load(filepath.Join(dir, "second"))
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	entries, _ := fs.ReadDir(fsys, ".")
+	if len(entries) != 1 || entries[0].Name() != "night.jpg" {
+		t.Fatalf("entries after rebundling: %v", entries)
+	}
+
+	// A new executable carries the bundle of the old one over, in place of its own.
+	newExe := []byte("\x7fELF newer executable")
+	if carried, ok := Carry(write("new", newExe, Image{Name: "stale.jpg", Data: []byte("stale")}), second); !ok || !bytes.Equal(Strip(carried), newExe) || !bytes.Equal(carried[len(newExe):], second[len(exe):]) {
+		t.Fatalf("Carry: got %q, %v", carried, ok)
+	}
+	if carried, ok := Carry(newExe, exe); ok || !bytes.Equal(carried, newExe) {
+		t.Fatalf("Carry without a bundle: got %q, %v", carried, ok)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "plain"), exe, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(filepath.Join(dir, "plain")); !errors.Is(err, ErrNoBundle) {
+		t.Fatalf("plain executable: got %v, want ErrNoBundle", err)
+	}
+	for _, tc := range []struct {
+		images  []Image
+		wantErr string
+	}{
+		{nil, "no images"},
+		{[]Image{{Name: "notes.txt"}}, "not a .jpg"},
+		{[]Image{{Name: "art/brand.jpg"}}, "not a .jpg"},
+		{[]Image{{Name: "a.jpg"}, {Name: "a.jpg"}}, "duplicate"},
+	} {
+		if err := Write(&bytes.Buffer{}, exe, tc.images); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Fatalf("Write(%v): got %v, want error containing %q", tc.images, err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Package config reads and updates configuration files that hold command-line flags as "name = value" lines, so a
+// target's settings can be kept in one file instead of a long command line.
+//
+// Blank lines and lines starting with '#' are ignored. A name may appear several times for repeatable flags; the
+// value is everything after the first '=' with surrounding white space removed.
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Setting is one flag of a configuration file.
+type Setting struct {
+	// Name is the flag name without leading dashes, e.g. "layout".
+	Name  string
+	Value string
+	// Line, Column, and ValueColumn locate the setting in the file it was loaded from, counting from 1; Column is
+	// where the name starts. They are zero for settings that were not loaded.
+	Line, Column, ValueColumn int
+}
+
+// PositionError is an error at a line and column of a configuration file, counting from 1.
+type PositionError struct {
+	Path         string
+	Line, Column int
+	Err          error
+}
+
+// Error implements error.
+func (e *PositionError) Error() string {
+	return fmt.Sprintf("config: %s: line %d, column %d: %v", e.Path, e.Line, e.Column, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *PositionError) Unwrap() error {
+	return e.Err
+}
+
+// Load reads the settings of the configuration file at path in file order.
+// It returns a *PositionError for lines without '=' or with a name that is no flag name.
+func Load(path string) ([]Setting, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	var settings []Setting
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		setting, ok, err := parseLine(path, line, scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			settings = append(settings, setting)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("config: %s: %w", path, err)
+	}
+	return settings, nil
+}
+
+// Args returns settings as command-line arguments of the form --name=value, which also works for boolean flags.
+func Args(settings []Setting) []string {
+	args := make([]string, 0, len(settings))
+	for _, s := range settings {
+		args = append(args, "--"+s.Name+"="+s.Value)
+	}
+	return args
+}
+
+// Update writes changes into the configuration file at path, creating it if needed. A change replaces the first line
+// of its name in place and drops further lines of that name; an empty value removes the name. Names the file does
+// not have yet are appended. Comments and all other lines are kept unchanged.
+func Update(path string, changes []Setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("config: %w", err)
+	}
+	for _, c := range changes {
+		if err := validName(c.Name); err != nil {
+			return fmt.Errorf("config: %w", err)
+		}
+		if strings.ContainsAny(c.Value, "\r\n") {
+			return fmt.Errorf("config: value of %s spans several lines", c.Name)
+		}
+	}
+
+	var out bytes.Buffer
+	written := map[string]bool{}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+	for i, line := range lines {
+		setting, ok, err := parseLine(path, i+1, line)
+		if err != nil {
+			return err
+		}
+		change, changed := lookup(changes, setting.Name)
+		switch {
+		case !ok || !changed:
+			out.WriteString(line + "\n")
+		case !written[change.Name]:
+			written[change.Name] = true
+			if change.Value != "" {
+				out.WriteString(format(change))
+			}
+		}
+	}
+	for _, c := range changes {
+		if written[c.Name] {
+			continue
+		}
+		written[c.Name] = true
+		if change, _ := lookup(changes, c.Name); change.Value != "" {
+			out.WriteString(format(change))
+		}
+	}
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	return nil
+}
+
+// parseLine parses the line with number n of the file at path; ok is false for blank lines and comments.
+func parseLine(path string, n int, line string) (s Setting, ok bool, err error) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return Setting{}, false, nil
+	}
+	// column returns the column of the byte offset i of line.
+	column := func(i int) int { return utf8.RuneCountInString(line[:i]) + 1 }
+	start := strings.Index(line, trimmed)
+	name, value, found := strings.Cut(trimmed, "=")
+	if !found {
+		return Setting{}, false, &PositionError{Path: path, Line: n, Column: column(start), Err: fmt.Errorf("want name = value")}
+	}
+	s = Setting{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value), Line: n}
+	s.Column = column(start + strings.Index(name, s.Name))
+	s.ValueColumn = column(start + len(name) + 1 + len(value) - len(strings.TrimLeftFunc(value, unicode.IsSpace)))
+	if err := validName(s.Name); err != nil {
+		return Setting{}, false, &PositionError{Path: path, Line: n, Column: s.Column, Err: err}
+	}
+	return s, true, nil
+}
+
+// validName accepts flag names: lowercase letters, digits, and inner dashes.
+func validName(name string) error {
+	if name == "" || strings.HasPrefix(name, "-") || strings.HasSuffix(name, "-") ||
+		strings.IndexFunc(name, func(r rune) bool { return (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' }) >= 0 {
+		return fmt.Errorf("%q is no flag name", name)
+	}
+	return nil
+}
+
+// lookup returns the last change of name, so repeated changes behave like repeated assignments.
+func lookup(changes []Setting, name string) (Setting, bool) {
+	for i := len(changes) - 1; i >= 0; i-- {
+		if changes[i].Name == name {
+			return changes[i], true
+		}
+	}
+	return Setting{}, false
+}
+
+// format returns the file line of a setting.
+func format(s Setting) string {
+	return s.Name + " = " + s.Value + "\n"
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestLoadUpdate_KeepsCommentsAndOrder expects settings to load in file order as flag arguments, and updates to
+// replace, remove, and append settings while keeping comments and other lines. The test fails if a comment is lost,
+// a repeated name survives an update, or a malformed line is accepted.
+func TestLoadUpdate_KeepsCommentsAndOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "target.conf")
+	if err := os.WriteFile(path, []byte("# acme target\nlayout = centered\n\nca-cert = a.pem\nca-cert = b.pem\nsubtitle = Build {{.BuildID}} = nightly\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	want := []string{"--layout=centered", "--ca-cert=a.pem", "--ca-cert=b.pem", "--subtitle=Build {{.BuildID}} = nightly"}
+	if got := Args(settings); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Args: got %q, want %q", got, want)
+	}
+
+	if err := Update(path, []Setting{{Name: "ca-cert", Value: "c.pem"}, {Name: "subtitle"}, {Name: "query", Value: "forest"}, {Name: "query", Value: "lake"}}); err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# acme target\nlayout = centered\n\nca-cert = c.pem\nquery = lake\n"; string(data) != want {
+		t.Fatalf("updated file:\n%s\nwant:\n%s", data, want)
+	}
+
+	created := filepath.Join(t.TempDir(), "new.conf")
+	if err := Update(created, []Setting{{Name: "theme", Value: "brand.json"}}); err != nil {
+		t.Fatalf("Update of a new file: %v", err)
+	}
+	if data, _ := os.ReadFile(created); string(data) != "theme = brand.json\n" {
+		t.Fatalf("new file: %q", data)
+	}
+
+	for _, bad := range []string{"layout centered\n", "--layout = centered\n", "Layout = x\n"} {
+		if err := os.WriteFile(path, []byte(bad), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Fatalf("%q: got %v, want a line error", bad, err)
+		}
+	}
+	if err := Update(created, []Setting{{Name: "subtitle", Value: "a\nb"}}); err == nil {
+		t.Fatalf("expected an error for a multi-line value")
+	}
+}
+
+// TestSchema_ValidateJSONTemplate expects Validate to report every problem of a file at its line and column, with a
+// suggestion for misspelled names, and the JSON Schema and template to describe the same settings. The test fails if a
+// problem is missed, reported at the wrong position, or the template does not load as an empty configuration.
+func TestSchema_ValidateJSONTemplate(t *testing.T) {
+	schema := Schema{
+		{Name: "layout", Description: "text layout", Type: TypeString, Default: "centered", Enum: []string{"centered", "minimal"}},
+		{Name: "grain", Description: "film grain", Type: TypeNumber, Default: "0"},
+		{Name: "png", Description: "also write a PNG", Type: TypeBoolean, Default: "false"},
+		{Name: "tag", Description: "badge text", Type: TypeString, Repeatable: true, Check: func(v string) error {
+			if v == "" {
+				return errors.New("empty tag")
+			}
+			return nil
+		}},
+	}
+	path := filepath.Join(t.TempDir(), "target.conf")
+	if err := os.WriteFile(path, []byte("# acme\nlayout = minimal\ntag = a\ntag = b\npng = yes\n  grian = 3\nlayout =  sideways\ngrain = 1.5\ntag =\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	err = schema.Validate(path, settings)
+	want := []string{
+		path + `: line 5, column 7: png: "yes" is not a valid boolean`,
+		path + `: line 6, column 3: unknown setting "grian"; did you mean "grain"?`,
+		path + `: line 7, column 1: layout is already set in line 2 and takes one value`,
+		path + `: line 9, column 6: tag: empty tag`,
+	}
+	var got []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var pe *PositionError
+		if !errors.As(e, &pe) {
+			t.Fatalf("%v is not a *PositionError", e)
+		}
+		got = append(got, strings.TrimPrefix(e.Error(), "config: "))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Validate:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if err := schema.Validate(path, []Setting{{Name: "layout", Value: "sideways", Line: 1, ValueColumn: 10}}); err == nil || !strings.Contains(err.Error(), `line 1, column 10: layout: "sideways" is not one of centered, minimal`) {
+		t.Fatalf("expected the enum error, got %v", err)
+	}
+
+	data, err := schema.JSON("test")
+	if err != nil {
+		t.Fatalf("JSON error: %v", err)
+	}
+	var doc struct {
+		AdditionalProperties bool `json:"additionalProperties"`
+		Properties           map[string]struct {
+			Type    string `json:"type"`
+			Default any    `json:"default"`
+			Items   *struct {
+				Type string `json:"type"`
+			} `json:"items"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("JSON output does not parse: %v\n%s", err, data)
+	}
+	if doc.AdditionalProperties || len(doc.Properties) != 4 || doc.Properties["grain"].Default != 0.0 || doc.Properties["png"].Default != false ||
+		doc.Properties["tag"].Type != "array" || doc.Properties["tag"].Items == nil || doc.Properties["tag"].Items.Type != TypeString {
+		t.Fatalf("JSON Schema:\n%s", data)
+	}
+
+	if err := os.WriteFile(path, []byte(schema.Template("acme\ndefaults")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if settings, err := Load(path); err != nil || len(settings) != 0 {
+		t.Fatalf("template loads as %v, %v; want no settings", settings, err)
+	}
+	if data, _ := os.ReadFile(path); !strings.HasPrefix(string(data), "# acme\n# defaults\n\n# text layout\n#layout = centered\n") || !strings.Contains(string(data), "\n#tag =\n") {
+		t.Fatalf("template:\n%s", data)
+	}
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Types of a Property, named as in JSON Schema.
+const (
+	TypeString  = "string"
+	TypeBoolean = "boolean"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+)
+
+// Property describes the setting of one flag.
+type Property struct {
+	Name        string
+	Description string
+	// Type is TypeString, TypeBoolean, TypeInteger, or TypeNumber.
+	Type string
+	// Default is the value of the flag when it is not set, as it would be written in the file.
+	Default string
+	// Enum lists the allowed values besides Default; empty allows any value of Type.
+	Enum []string
+	// Repeatable marks flags that collect every value rather than keeping the last.
+	Repeatable bool
+	// Check validates a value beyond its type and Enum, e.g. by parsing it like the flag does; nil accepts it.
+	Check func(value string) error
+}
+
+// Schema describes all settings of a configuration file, in a stable order.
+type Schema []Property
+
+// lookup returns the property of name.
+func (s Schema) lookup(name string) (Property, bool) {
+	for _, p := range s {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// Validate checks settings, loaded from the file at path, against the schema. It returns every problem as a
+// *PositionError, joined with errors.Join: unknown names with the closest known one, values of the wrong type or
+// outside Enum, values that Check rejects, and names that are not Repeatable but set again.
+func (s Schema) Validate(path string, settings []Setting) error {
+	var errs []error
+	seen := map[string]int{}
+	for _, setting := range settings {
+		at := func(column int, format string, args ...any) {
+			errs = append(errs, &PositionError{Path: path, Line: setting.Line, Column: column, Err: fmt.Errorf(format, args...)})
+		}
+		p, ok := s.lookup(setting.Name)
+		if !ok {
+			if suggestion := s.closest(setting.Name); suggestion != "" {
+				at(setting.Column, "unknown setting %q; did you mean %q?", setting.Name, suggestion)
+			} else {
+				at(setting.Column, "unknown setting %q", setting.Name)
+			}
+			continue
+		}
+		if line, ok := seen[p.Name]; ok && !p.Repeatable {
+			at(setting.Column, "%s is already set in line %d and takes one value", p.Name, line)
+			continue
+		}
+		seen[p.Name] = setting.Line
+		if err := p.Validate(setting.Value); err != nil {
+			at(setting.ValueColumn, "%s: %w", p.Name, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks a value against the type, Enum, and Check of p.
+func (p Property) Validate(value string) error {
+	var err error
+	switch p.Type {
+	case TypeBoolean:
+		_, err = strconv.ParseBool(value)
+	case TypeInteger:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case TypeNumber:
+		_, err = strconv.ParseFloat(value, 64)
+	}
+	if err != nil {
+		return fmt.Errorf("%q is not a valid %s", value, p.Type)
+	}
+	if len(p.Enum) > 0 && value != p.Default && !slices.Contains(p.Enum, value) {
+		return fmt.Errorf("%q is not one of %s", value, strings.Join(p.Enum, ", "))
+	}
+	if p.Check != nil {
+		return p.Check(value)
+	}
+	return nil
+}
+
+// closest returns the known name within two edits of name, or "" if there is none.
+func (s Schema) closest(name string) string {
+	best, bestDistance := "", 3
+	for _, p := range s {
+		if d := editDistance(name, p.Name); d < bestDistance {
+			best, bestDistance = p.Name, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance of a and b in bytes; names are ASCII.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// jsonSchema is the JSON Schema of a property; repeatable properties wrap it in an array.
+type jsonSchema struct {
+	Type        string      `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Default     any         `json:"default,omitempty"`
+	Enum        []string    `json:"enum,omitempty"`
+	Items       *jsonSchema `json:"items,omitempty"`
+}
+
+// JSON returns the schema as a JSON Schema (draft 2020-12) of an object with one property per setting, so the
+// settings can be checked and completed by editors and other tools. Repeatable settings are arrays.
+func (s Schema) JSON(title string) ([]byte, error) {
+	properties := map[string]jsonSchema{}
+	for _, p := range s {
+		prop := jsonSchema{Type: p.Type, Description: p.Description, Enum: p.Enum}
+		switch {
+		case p.Default == "":
+		case p.Type == TypeBoolean:
+			prop.Default, _ = strconv.ParseBool(p.Default)
+		case p.Type == TypeInteger || p.Type == TypeNumber:
+			prop.Default = json.Number(p.Default)
+		default:
+			prop.Default = p.Default
+		}
+		if p.Repeatable {
+			item := prop
+			item.Description, item.Default = "", nil
+			prop = jsonSchema{Type: "array", Description: p.Description, Items: &item}
+		}
+		properties[p.Name] = prop
+	}
+	data, err := json.MarshalIndent(map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                title,
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           properties,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// Template returns a configuration file that lists every setting as a comment with its description and default, so
+// it loads as an empty configuration and settings are enabled by removing the '#'. header starts the file as comment
+// lines.
+func (s Schema) Template(header string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(header, "\n") {
+		b.WriteString(strings.TrimRight("# "+line, " ") + "\n")
+	}
+	for _, p := range s {
+		b.WriteString("\n# " + p.Description + "\n")
+		b.WriteString(strings.TrimRight("#"+p.Name+" = "+p.Default, " ") + "\n")
+	}
+	return b.String()
+}
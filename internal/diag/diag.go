@@ -0,0 +1,303 @@
+// Package diag records what a diagnostic bundle reports about a run, the last HTTP requests and the timings of its
+// stages, and writes bundles for bug reports without secrets: URLs lose their passwords and credential query
+// parameters, and secret environment variables are only reported as set.
+package diag
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRequests and maxTimings are the numbers of HTTP requests and stages a Recorder keeps, dropping the oldest, so
+// long-running servers do not grow them without bound.
+const (
+	maxRequests = 32
+	maxTimings  = 64
+)
+
+// Request is a finished HTTP request.
+type Request struct {
+	Time     time.Time `json:"time"`
+	Method   string    `json:"method"`
+	URL      string    `json:"url"`
+	Status   int       `json:"status,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Duration Duration  `json:"duration"`
+}
+
+// Timing is a stage of a run, e.g. fetch; Running marks stages that had not ended when the bundle was written.
+type Timing struct {
+	Name     string    `json:"name"`
+	Start    time.Time `json:"start"`
+	Duration Duration  `json:"duration"`
+	Running  bool      `json:"running,omitempty"`
+}
+
+// Duration is a time.Duration that encodes as text, e.g. "1.5s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Recorder keeps the last HTTP requests and the stage timings of a process. It is safe for concurrent use.
+type Recorder struct {
+	mu       sync.Mutex
+	requests []Request
+	timings  []*Timing
+}
+
+// Default is the recorder of this process. Like trace.Default it is shared by all runs of the process.
+var Default = &Recorder{}
+
+// Request records a finished HTTP request, with its URL redacted.
+func (r *Recorder) Request(req Request) {
+	req.URL = RedactURL(req.URL)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.requests) == maxRequests {
+		r.requests = append(r.requests[:0], r.requests[1:]...)
+	}
+	r.requests = append(r.requests, req)
+}
+
+// Stage records the start of the stage name and returns the function that records its end; only its first call has
+// an effect.
+func (r *Recorder) Stage(name string) (end func()) {
+	t := &Timing{Name: name, Start: time.Now(), Running: true}
+	r.mu.Lock()
+	if len(r.timings) == maxTimings {
+		r.timings = append(r.timings[:0], r.timings[1:]...)
+	}
+	r.timings = append(r.timings, t)
+	r.mu.Unlock()
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if t.Running {
+			t.Duration, t.Running = Duration(time.Since(t.Start)), false
+		}
+	}
+}
+
+// snapshot returns copies of the requests and the timings, with the time of running stages up to now.
+func (r *Recorder) snapshot() ([]Request, []Timing) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var timings []Timing
+	for _, t := range r.timings {
+		timing := *t
+		if timing.Running {
+			timing.Duration = Duration(time.Since(timing.Start))
+		}
+		timings = append(timings, timing)
+	}
+	return append([]Request(nil), r.requests...), timings
+}
+
+// Transport records the requests made through Base in Recorder.
+type Transport struct {
+	Base     http.RoundTripper
+	Recorder *Recorder
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.Base.RoundTrip(req)
+	rec := Request{Time: start, Method: req.Method, URL: req.URL.String(), Duration: Duration(time.Since(start))}
+	if err != nil {
+		rec.Error = err.Error()
+	} else {
+		rec.Status = resp.StatusCode
+	}
+	t.Recorder.Request(rec)
+	return resp, err
+}
+
+// PanicError is a panic that Recover turned into an error, e.g. of an image decoder on malformed data, so one bad
+// input fails its build instead of the whole process.
+type PanicError struct {
+	// Op names what panicked, e.g. "render".
+	Op    string
+	Value any
+	// Stack is the stack of the panicking goroutine, for diagnostic bundles.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s: internal error: %v", e.Op, e.Value)
+}
+
+// Recover turns a panic of the calling function into a *PanicError in *err. It must be deferred directly, e.g.
+// defer diag.Recover("render", &err), on a function with a named error result.
+func Recover(op string, err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Op: op, Value: r, Stack: debug.Stack()}
+	}
+}
+
+// secretParams are query parameter names, compared in lower case, whose values RedactURL replaces.
+var secretParams = []string{"key", "token", "secret", "signature", "password", "credential", "auth"}
+
+// RedactURL returns s with the password of its user info and the values of query parameters that look like
+// credentials, e.g. apikey or X-Amz-Signature, replaced by "xxxxx". Other text is returned unchanged.
+func RedactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return s
+	}
+	query := u.Query()
+	redacted := false
+	for name := range query {
+		lower := strings.ToLower(name)
+		for _, secret := range secretParams {
+			if strings.Contains(lower, secret) {
+				query.Set(name, "xxxxx")
+				redacted = true
+				break
+			}
+		}
+	}
+	if redacted {
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
+
+// EnvVar is an environment variable a bundle reports; the value of a Secret one is only reported as set.
+type EnvVar struct {
+	Name   string
+	Secret bool
+}
+
+// Report is the content of a diagnostic bundle besides what the Recorder keeps.
+type Report struct {
+	Version string
+	// Command is the command line after the executable name; Write redacts URLs in it.
+	Command []string
+	// Error is the error or panic that prompted the bundle, empty for a bundle on demand.
+	Error string
+	// Stack holds the goroutine stacks at the time of a panic.
+	Stack []byte
+	// ConfigPath and Config are the config file of the command and its "name = value" lines; Write redacts URLs in the
+	// values.
+	ConfigPath string
+	Config     []string
+	Env        []EnvVar
+}
+
+// summary is report.json of a bundle.
+type summary struct {
+	Version     string            `json:"version"`
+	GoVersion   string            `json:"go_version"`
+	OS          string            `json:"os"`
+	Arch        string            `json:"arch"`
+	CPUs        int               `json:"cpus"`
+	Time        time.Time         `json:"time"`
+	Command     []string          `json:"command"`
+	Error       string            `json:"error,omitempty"`
+	ConfigPath  string            `json:"config_path,omitempty"`
+	Environment map[string]string `json:"environment"`
+	Requests    []Request         `json:"requests"`
+	Timings     []Timing          `json:"timings"`
+}
+
+// bundleFile is a file of a bundle.
+type bundleFile struct {
+	name string
+	data []byte
+}
+
+// Write writes a bundle of report and what r recorded to path as a ZIP file with report.json, config.txt with the
+// redacted config file if there is one, and stack.txt if there is a stack.
+func (r *Recorder) Write(path string, report Report) error {
+	requests, timings := r.snapshot()
+	s := summary{
+		Version:     report.Version,
+		GoVersion:   runtime.Version(),
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		CPUs:        runtime.NumCPU(),
+		Time:        time.Now().UTC(),
+		Command:     []string{},
+		Error:       report.Error,
+		ConfigPath:  report.ConfigPath,
+		Environment: map[string]string{},
+		Requests:    append([]Request{}, requests...),
+		Timings:     append([]Timing{}, timings...),
+	}
+	for _, arg := range report.Command {
+		s.Command = append(s.Command, redactArg(arg))
+	}
+	for _, v := range report.Env {
+		value, ok := os.LookupEnv(v.Name)
+		switch {
+		case !ok:
+		case v.Secret:
+			s.Environment[v.Name] = "(set)"
+		default:
+			s.Environment[v.Name] = RedactURL(value)
+		}
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("diag: %w", err)
+	}
+	files := []bundleFile{{"report.json", append(data, '\n')}}
+	if report.ConfigPath != "" {
+		var b strings.Builder
+		for _, line := range report.Config {
+			name, value, ok := strings.Cut(line, "=")
+			if ok {
+				line = name + "= " + RedactURL(strings.TrimSpace(value))
+			}
+			b.WriteString(line + "\n")
+		}
+		files = append(files, bundleFile{"config.txt", []byte(b.String())})
+	}
+	if len(report.Stack) > 0 {
+		files = append(files, bundleFile{"stack.txt", report.Stack})
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("diag: %w", err)
+	}
+	zw := zip.NewWriter(f)
+	for _, file := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: s.Time})
+		if err == nil {
+			_, err = w.Write(file.data)
+		}
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("diag: write %s: %w", file.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return fmt.Errorf("diag: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("diag: %w", err)
+	}
+	return nil
+}
+
+// redactArg redacts a URL argument or the URL value of a --flag=value argument.
+func redactArg(arg string) string {
+	if name, value, ok := strings.Cut(arg, "="); ok && strings.HasPrefix(name, "-") {
+		return name + "=" + RedactURL(value)
+	}
+	return RedactURL(arg)
+}
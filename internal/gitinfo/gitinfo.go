@@ -0,0 +1,78 @@
+package gitinfo
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Info describes the state of a git working tree at generation time.
+type Info struct {
+	Commit      string
+	ShortCommit string
+	Tag         string
+	Branch      string
+	Dirty       bool
+}
+
+// Read collects commit, nearest tag, branch, and dirty state from the working tree in dir.
+// It returns an error if git is unavailable or dir is not a repository; a missing tag or detached HEAD are not errors.
+func Read(dir string) (Info, error) {
+	commit, err := run(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return Info{}, err
+	}
+	short, err := run(dir, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return Info{}, err
+	}
+	status, err := run(dir, "status", "--porcelain")
+	if err != nil {
+		return Info{}, err
+	}
+
+	info := Info{
+		Commit:      commit,
+		ShortCommit: short,
+		Dirty:       status != "",
+	}
+
+	// Repositories without tags are common; treat a failing describe as "no tag".
+	if tag, err := run(dir, "describe", "--tags", "--abbrev=0"); err == nil {
+		info.Tag = tag
+	}
+	if branch, err := run(dir, "rev-parse", "--abbrev-ref", "HEAD"); err == nil && branch != "HEAD" {
+		info.Branch = branch
+	}
+	return info, nil
+}
+
+// Describe runs git describe in dir and returns its trimmed output.
+// It returns an error if git fails or prints nothing.
+func Describe(dir string) (string, error) {
+	out, err := run(dir, "describe", "--tags", "--always", "--dirty")
+	if err != nil {
+		return "", err
+	}
+	if out == "" {
+		return "", fmt.Errorf("gitinfo: git describe returned no output")
+	}
+	return out, nil
+}
+
+// run executes git with the given arguments in dir and returns trimmed stdout.
+// An empty dir means the current working directory; failures include git's stderr for context.
+func run(dir string, args ...string) (string, error) {
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	cmd := exec.Command("git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("gitinfo: git %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,73 @@
+package gitinfo
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// mustRepo creates a temporary git repository with a single commit and returns its path.
+// The test is skipped if git is not installed and fails if any git command fails.
+func mustRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	mustGit(t, dir, "init", "-q", "-b", "main")
+	mustGit(t, dir, "add", ".")
+	mustGit(t, dir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "init")
+	return dir
+}
+
+// mustGit runs a git command in dir and fails the test with git's output on error.
+func mustGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+}
+
+// TestRead_CleanTaggedRepo verifies commit, tag, branch, and dirty state for a tagged repository.
+// The test fails if any field is missing or the dirty flag does not follow working tree changes.
+func TestRead_CleanTaggedRepo(t *testing.T) {
+	dir := mustRepo(t)
+	mustGit(t, dir, "tag", "v2.3.1")
+
+	info, err := Read(dir)
+	if err != nil {
+		t.Fatalf("Read error: %v", err)
+	}
+	if len(info.Commit) != 40 || info.ShortCommit == "" {
+		t.Fatalf("unexpected commit fields: %+v", info)
+	}
+	if info.Tag != "v2.3.1" || info.Branch != "main" || info.Dirty {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("b\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	info, err = Read(dir)
+	if err != nil {
+		t.Fatalf("Read error: %v", err)
+	}
+	if !info.Dirty {
+		t.Fatalf("expected dirty working tree")
+	}
+}
+
+// TestRead_NotARepository_Error expects an error for a directory outside any git repository.
+func TestRead_NotARepository_Error(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	if _, err := Read(t.TempDir()); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,128 @@
+// Package hook writes the glue that lets image build tools such as mkosi and debos pick up a ts-release build from
+// their staging layout, so image definitions need no copy steps of their own.
+package hook
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Tool identifies an image build tool whose staging layout hook writes.
+type Tool string
+
+const (
+	ToolMkosi Tool = "mkosi"
+	ToolDebos Tool = "debos"
+)
+
+// marker starts every generated file, so a later run may replace it but never a file of the image definition.
+const marker = "Generated by ts-release hook"
+
+const filePerm = 0o644
+
+// Tools returns all supported tools in a stable order for help output and validation.
+func Tools() []string {
+	return []string{string(ToolMkosi), string(ToolDebos)}
+}
+
+// ParseTool validates a tool name. Unknown names return an error.
+func ParseTool(name string) (Tool, error) {
+	for _, t := range Tools() {
+		if name == t {
+			return Tool(t), nil
+		}
+	}
+	return "", fmt.Errorf("hook: unknown tool %q (available: %s)", name, strings.Join(Tools(), ", "))
+}
+
+// RootFS returns the directory below the image definition dir that the tool copies into the image: the extra tree
+// of mkosi or an overlay of debos.
+func (t Tool) RootFS(dir string) string {
+	if t == ToolDebos {
+		return filepath.Join(dir, "overlays", "tssh")
+	}
+	return filepath.Join(dir, "mkosi.extra")
+}
+
+// Options selects what the generated glue does in the image.
+type Options struct {
+	// Manifest is the rootfs-relative path of the checksum manifest to verify; empty selects etc/tssh.manifest.
+	Manifest string
+	// PlymouthTheme is activated with plymouth-set-default-theme; empty leaves the default theme.
+	PlymouthTheme string
+}
+
+// Write writes the glue of the tool into dir and returns its path. For mkosi it is mkosi.postinst.chroot, which mkosi
+// runs inside the image after copying mkosi.extra; for debos it is tssh-actions.yaml with the actions to add to the
+// recipe. It returns an error rather than replace a file that ts-release did not generate.
+func Write(dir string, t Tool, opts Options) (string, error) {
+	var path, content string
+	switch t {
+	case ToolMkosi:
+		path, content = filepath.Join(dir, "mkosi.postinst.chroot"), mkosiScript(opts)
+	case ToolDebos:
+		path, content = filepath.Join(dir, "tssh-actions.yaml"), debosActions(opts)
+	default:
+		return "", fmt.Errorf("hook: unknown tool %q", t)
+	}
+	if old, err := os.ReadFile(path); err == nil && !bytes.Contains(old, []byte(marker)) {
+		return "", fmt.Errorf("hook: %s exists and was not generated by ts-release; add its commands to it instead: %s", path, strings.Join(commands(opts), " && "))
+	} else if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("hook: %w", err)
+	}
+	perm := os.FileMode(filePerm)
+	if t == ToolMkosi {
+		// mkosi only runs executable scripts.
+		perm = 0o755
+	}
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		return "", fmt.Errorf("hook: %w", err)
+	}
+	// WriteFile keeps the mode of an existing file.
+	if err := os.Chmod(path, perm); err != nil {
+		return "", fmt.Errorf("hook: %w", err)
+	}
+	return path, nil
+}
+
+// commands returns the shell commands to run inside the image: verifying the installed files against the manifest
+// and activating the boot splash theme.
+func commands(opts Options) []string {
+	manifest := opts.Manifest
+	if manifest == "" {
+		manifest = "etc/tssh.manifest"
+	}
+	cmds := []string{"cd /", "sha256sum --check --quiet " + manifest}
+	if opts.PlymouthTheme != "" {
+		cmds = append(cmds, "plymouth-set-default-theme "+opts.PlymouthTheme)
+	}
+	return cmds
+}
+
+// mkosiScript returns the postinst script for mkosi.
+func mkosiScript(opts Options) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#!/bin/sh\n# %s mkosi. mkosi runs it in the image after copying mkosi.extra.\nset -e\n", marker)
+	for _, cmd := range commands(opts) {
+		b.WriteString(cmd + "\n")
+	}
+	return b.String()
+}
+
+// debosActions returns the overlay and run actions for a debos recipe. The overlay source is relative to the recipe.
+func debosActions(opts Options) string {
+	return fmt.Sprintf(`# %s debos. Add these actions to the recipe after the packages are installed.
+- action: overlay
+  description: Install ts-release branding
+  source: overlays/tssh
+  destination: /
+
+- action: run
+  description: Verify ts-release branding
+  chroot: true
+  command: %s
+`, marker, strings.Join(commands(opts), " && "))
+}
@@ -0,0 +1,63 @@
+package hook
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestWrite_StagesGlueForMkosiAndDebos expects an executable mkosi.postinst.chroot and a debos action list that verify
+// the manifest and activate the Plymouth theme, later runs to replace them, and files of the image definition to be
+// kept. The test fails if a path, mode, command, or the refusal differs.
+func TestWrite_StagesGlueForMkosiAndDebos(t *testing.T) {
+	dir := t.TempDir()
+	if got := ToolMkosi.RootFS(dir); got != filepath.Join(dir, "mkosi.extra") {
+		t.Fatalf("mkosi rootfs = %s", got)
+	}
+	script, err := Write(dir, ToolMkosi, Options{PlymouthTheme: "tssh"})
+	if err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	info, err := os.Stat(script)
+	if err != nil || filepath.Base(script) != "mkosi.postinst.chroot" || info.Mode().Perm() != 0o755 {
+		t.Fatalf("mkosi script %s: %v, %v", script, info, err)
+	}
+	data, _ := os.ReadFile(script)
+	for _, want := range []string{"#!/bin/sh\n", "set -e\n", "sha256sum --check --quiet etc/tssh.manifest\n", "plymouth-set-default-theme tssh\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("expected %q in mkosi script:\n%s", want, data)
+		}
+	}
+	if _, err := Write(dir, ToolMkosi, Options{}); err != nil {
+		t.Fatalf("rewriting the generated script: %v", err)
+	}
+	if data, _ := os.ReadFile(script); strings.Contains(string(data), "plymouth") {
+		t.Fatalf("rewritten script still activates the theme:\n%s", data)
+	}
+
+	if got := ToolDebos.RootFS(dir); got != filepath.Join(dir, "overlays", "tssh") {
+		t.Fatalf("debos rootfs = %s", got)
+	}
+	actions, err := Write(dir, ToolDebos, Options{})
+	if err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	data, _ = os.ReadFile(actions)
+	for _, want := range []string{"- action: overlay\n  description: Install ts-release branding\n  source: overlays/tssh\n", "- action: run\n", "  chroot: true\n", "  command: cd / && sha256sum --check --quiet etc/tssh.manifest\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("expected %q in debos actions:\n%s", want, data)
+		}
+	}
+
+	own := t.TempDir()
+	if err := os.WriteFile(filepath.Join(own, "mkosi.postinst.chroot"), []byte("#!/bin/sh\nsystemctl enable kiosk\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Write(own, ToolMkosi, Options{}); err == nil || !strings.Contains(err.Error(), "sha256sum") {
+		t.Fatalf("expected a refusal naming the commands, got %v", err)
+	}
+	if _, err := ParseTool("kiwi"); err == nil {
+		t.Fatal("expected an unknown tool to be rejected")
+	}
+}
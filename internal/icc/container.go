@@ -0,0 +1,176 @@
+package icc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+const (
+	jpegICCHeader = "ICC_PROFILE\x00"
+	markerAPP2    = 0xE2
+	pngSignature  = "\x89PNG\r\n\x1a\n"
+	// maxJPEGChunk is the profile payload per APP2 segment: 65535 minus length, header, and sequence bytes.
+	maxJPEGChunk = 0xFFFF - 2 - len(jpegICCHeader) - 2
+)
+
+// Extract returns the ICC profile embedded in JPEG APP2 segments or a PNG iCCP chunk.
+// It returns nil without error when the file has no profile or is in another format.
+func Extract(data []byte) ([]byte, error) {
+	switch {
+	case len(data) >= 2 && data[0] == 0xFF && data[1] == 0xD8:
+		return extractJPEG(data)
+	case bytes.HasPrefix(data, []byte(pngSignature)):
+		return extractPNG(data)
+	}
+	return nil, nil
+}
+
+// extractJPEG reassembles the profile from APP2 segments in sequence order.
+func extractJPEG(data []byte) ([]byte, error) {
+	chunks := map[int][]byte{}
+	total := 0
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			return nil, fmt.Errorf("icc: invalid jpeg marker at offset %d", pos)
+		}
+		marker := data[pos+1]
+		if marker == 0xD9 || marker == 0xDA {
+			// End of image or start of scan: no more metadata segments.
+			break
+		}
+		size := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if size < 2 || pos+2+size > len(data) {
+			return nil, fmt.Errorf("icc: truncated jpeg segment at offset %d", pos)
+		}
+		payload := data[pos+4 : pos+2+size]
+		if marker == markerAPP2 && bytes.HasPrefix(payload, []byte(jpegICCHeader)) && len(payload) >= len(jpegICCHeader)+2 {
+			seq := int(payload[len(jpegICCHeader)])
+			total = int(payload[len(jpegICCHeader)+1])
+			chunks[seq] = payload[len(jpegICCHeader)+2:]
+		}
+		pos += 2 + size
+	}
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+	if len(chunks) != total {
+		return nil, fmt.Errorf("icc: jpeg profile has %d of %d segments", len(chunks), total)
+	}
+	seqs := make([]int, 0, len(chunks))
+	for seq := range chunks {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	var profile []byte
+	for _, seq := range seqs {
+		profile = append(profile, chunks[seq]...)
+	}
+	return profile, nil
+}
+
+// extractPNG decompresses the profile of the iCCP chunk.
+func extractPNG(data []byte) ([]byte, error) {
+	for pos := len(pngSignature); pos+8 <= len(data); {
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		chunkType := string(data[pos+4 : pos+8])
+		if size < 0 || pos+12+size > len(data) {
+			return nil, fmt.Errorf("icc: truncated png chunk %q", chunkType)
+		}
+		if chunkType == "IDAT" {
+			// iCCP must precede the image data.
+			return nil, nil
+		}
+		if chunkType == "iCCP" {
+			body := data[pos+8 : pos+8+size]
+			nul := bytes.IndexByte(body, 0)
+			if nul < 0 || nul+2 > len(body) || body[nul+1] != 0 {
+				return nil, fmt.Errorf("icc: invalid png iCCP chunk")
+			}
+			r, err := zlib.NewReader(bytes.NewReader(body[nul+2:]))
+			if err != nil {
+				return nil, fmt.Errorf("icc: decompress png profile: %w", err)
+			}
+			defer r.Close()
+			profile, err := io.ReadAll(r)
+			if err != nil {
+				return nil, fmt.Errorf("icc: decompress png profile: %w", err)
+			}
+			return profile, nil
+		}
+		pos += 12 + size
+	}
+	return nil, nil
+}
+
+// EmbedJPEG inserts the profile as APP2 segments directly after the SOI marker of an encoded JPEG.
+// It returns an error if the data does not start with SOI or the profile needs more than 255 segments.
+func EmbedJPEG(jpegData, profile []byte) ([]byte, error) {
+	if len(jpegData) < 2 || jpegData[0] != 0xFF || jpegData[1] != 0xD8 {
+		return nil, fmt.Errorf("icc: jpeg data does not start with SOI marker")
+	}
+	count := (len(profile) + maxJPEGChunk - 1) / maxJPEGChunk
+	if count == 0 || count > 255 {
+		return nil, fmt.Errorf("icc: cannot embed a %d byte profile in jpeg", len(profile))
+	}
+
+	var segments bytes.Buffer
+	for i := 0; i < count; i++ {
+		chunk := profile[i*maxJPEGChunk:]
+		if len(chunk) > maxJPEGChunk {
+			chunk = chunk[:maxJPEGChunk]
+		}
+		segments.Write([]byte{0xFF, markerAPP2})
+		_ = binary.Write(&segments, binary.BigEndian, uint16(2+len(jpegICCHeader)+2+len(chunk)))
+		segments.WriteString(jpegICCHeader)
+		segments.Write([]byte{byte(i + 1), byte(count)})
+		segments.Write(chunk)
+	}
+
+	out := make([]byte, 0, len(jpegData)+segments.Len())
+	out = append(out, jpegData[:2]...)
+	out = append(out, segments.Bytes()...)
+	out = append(out, jpegData[2:]...)
+	return out, nil
+}
+
+// EmbedPNG inserts the profile as a compressed iCCP chunk directly after the IHDR chunk of an encoded PNG.
+// It returns an error if the data does not start with the signature and IHDR or the name is invalid.
+func EmbedPNG(pngData []byte, name string, profile []byte) ([]byte, error) {
+	headerEnd := len(pngSignature) + 4 + 4 + 13 + 4
+	if len(pngData) < headerEnd || !bytes.HasPrefix(pngData, []byte(pngSignature)) ||
+		string(pngData[len(pngSignature)+4:len(pngSignature)+8]) != "IHDR" {
+		return nil, fmt.Errorf("icc: png data does not start with signature and IHDR")
+	}
+	if len(name) == 0 || len(name) > 79 || bytes.IndexByte([]byte(name), 0) >= 0 {
+		return nil, fmt.Errorf("icc: invalid png profile name %q", name)
+	}
+
+	var body bytes.Buffer
+	body.WriteString(name)
+	body.Write([]byte{0, 0}) // NUL separator, compression method 0 (zlib)
+	zw := zlib.NewWriter(&body)
+	_, _ = zw.Write(profile)
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("icc: compress png profile: %w", err)
+	}
+
+	var chunk bytes.Buffer
+	_ = binary.Write(&chunk, binary.BigEndian, uint32(body.Len()))
+	chunk.WriteString("iCCP")
+	chunk.Write(body.Bytes())
+	crc := crc32.NewIEEE()
+	crc.Write([]byte("iCCP"))
+	crc.Write(body.Bytes())
+	_ = binary.Write(&chunk, binary.BigEndian, crc.Sum32())
+
+	out := make([]byte, 0, len(pngData)+chunk.Len())
+	out = append(out, pngData[:headerEnd]...)
+	out = append(out, chunk.Bytes()...)
+	out = append(out, pngData[headerEnd:]...)
+	return out, nil
+}
@@ -0,0 +1,312 @@
+// Package icc reads the embedded ICC color profiles of JPEG and PNG files, converts images to sRGB,
+// and embeds an sRGB profile into encoded outputs.
+// Only matrix/TRC RGB profiles are converted; they cover camera and display profiles such as Adobe RGB and
+// Display P3. LUT-based and non-RGB profiles are reported with ErrUnsupported.
+package icc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+)
+
+// Profile is a parsed matrix/TRC RGB profile.
+type Profile struct {
+	// Matrix maps linear RGB to PCS XYZ (D50); the columns are the red, green, and blue colorants.
+	Matrix [3][3]float64
+	// Curves map encoded channel values in [0, 1] to linear light.
+	Curves [3]Curve
+}
+
+// ErrUnsupported is wrapped by errors for valid profiles this package cannot convert, e.g. LUT-based or CMYK profiles.
+var ErrUnsupported = errors.New("unsupported profile")
+
+// Curve is a tone reproduction curve from an encoded value in [0, 1] to linear light.
+type Curve func(float64) float64
+
+// srgbMatrix holds the D50-adapted sRGB colorants as stored in the common sRGB profiles.
+var srgbMatrix = [3][3]float64{
+	{0.4360747, 0.3850649, 0.1430804},
+	{0.2225045, 0.7168786, 0.0606169},
+	{0.0139322, 0.0971045, 0.7141733},
+}
+
+// xyzToSRGB maps PCS XYZ (D50) to linear sRGB.
+var xyzToSRGB = invert(srgbMatrix)
+
+// srgbDecode converts an sRGB-encoded value to linear light.
+func srgbDecode(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// srgbEncode converts linear light to an sRGB-encoded value.
+func srgbEncode(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// Parse decodes an ICC profile.
+// It returns an error for truncated data, a missing signature, and profiles that are not matrix/TRC RGB.
+func Parse(data []byte) (*Profile, error) {
+	if len(data) < 132 || string(data[36:40]) != "acsp" {
+		return nil, fmt.Errorf("icc: not an ICC profile")
+	}
+	if cs := string(data[16:20]); cs != "RGB " {
+		return nil, fmt.Errorf("icc: color space %q: %w", cs, ErrUnsupported)
+	}
+	if pcs := string(data[20:24]); pcs != "XYZ " {
+		return nil, fmt.Errorf("icc: connection space %q: %w", pcs, ErrUnsupported)
+	}
+
+	tags := map[string][]byte{}
+	count := int(binary.BigEndian.Uint32(data[128:132]))
+	if count > (len(data)-132)/12 {
+		return nil, fmt.Errorf("icc: tag table truncated")
+	}
+	for i := 0; i < count; i++ {
+		entry := data[132+12*i:]
+		offset, size := int(binary.BigEndian.Uint32(entry[4:8])), int(binary.BigEndian.Uint32(entry[8:12]))
+		if offset < 0 || size < 0 || offset+size > len(data) || offset+size < offset {
+			return nil, fmt.Errorf("icc: tag %q out of bounds", entry[:4])
+		}
+		tags[string(entry[:4])] = data[offset : offset+size]
+	}
+
+	p := &Profile{}
+	for c, sig := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		xyz, err := parseXYZ(tags[sig])
+		if err != nil {
+			return nil, fmt.Errorf("icc: %s: %w", sig, err)
+		}
+		for r := range xyz {
+			p.Matrix[r][c] = xyz[r]
+		}
+	}
+	for c, sig := range []string{"rTRC", "gTRC", "bTRC"} {
+		curve, err := parseCurve(tags[sig])
+		if err != nil {
+			return nil, fmt.Errorf("icc: %s: %w", sig, err)
+		}
+		p.Curves[c] = curve
+	}
+	return p, nil
+}
+
+// s15Fixed16 decodes a signed 15.16 fixed-point number.
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+// parseXYZ decodes an XYZType tag with a single value.
+func parseXYZ(tag []byte) ([3]float64, error) {
+	if tag == nil {
+		// LUT-based profiles have no colorants.
+		return [3]float64{}, ErrUnsupported
+	}
+	if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+		return [3]float64{}, fmt.Errorf("missing or invalid XYZ tag")
+	}
+	return [3]float64{s15Fixed16(tag[8:]), s15Fixed16(tag[12:]), s15Fixed16(tag[16:])}, nil
+}
+
+// parseCurve decodes a curveType or parametricCurveType tag.
+func parseCurve(tag []byte) (Curve, error) {
+	if tag == nil {
+		return nil, ErrUnsupported
+	}
+	if len(tag) < 12 {
+		return nil, fmt.Errorf("missing or invalid curve tag")
+	}
+	switch string(tag[:4]) {
+	case "curv":
+		n := int(binary.BigEndian.Uint32(tag[8:12]))
+		if len(tag) < 12+2*n {
+			return nil, fmt.Errorf("curve table truncated")
+		}
+		switch n {
+		case 0:
+			return func(v float64) float64 { return v }, nil
+		case 1:
+			gamma := float64(binary.BigEndian.Uint16(tag[12:])) / 256
+			return func(v float64) float64 { return math.Pow(v, gamma) }, nil
+		}
+		table := make([]float64, n)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(tag[12+2*i:])) / 65535
+		}
+		return func(v float64) float64 {
+			pos := clamp(v) * float64(n-1)
+			i := int(pos)
+			if i >= n-1 {
+				return table[n-1]
+			}
+			return table[i] + (table[i+1]-table[i])*(pos-float64(i))
+		}, nil
+	case "para":
+		kind := binary.BigEndian.Uint16(tag[8:10])
+		params := []int{1, 3, 4, 5, 7}
+		if int(kind) >= len(params) || len(tag) < 12+4*params[kind] {
+			return nil, fmt.Errorf("parametric curve type %d: %w", kind, ErrUnsupported)
+		}
+		var g [7]float64
+		for i := 0; i < params[kind]; i++ {
+			g[i] = s15Fixed16(tag[12+4*i:])
+		}
+		gamma, a, b, c, d, e, f := g[0], g[1], g[2], g[3], g[4], g[5], g[6]
+		pow := func(v float64) float64 { return math.Pow(math.Max(0, v), gamma) }
+		switch kind {
+		case 0:
+			return func(v float64) float64 { return pow(v) }, nil
+		case 1:
+			return func(v float64) float64 {
+				if v >= -b/a {
+					return pow(a*v + b)
+				}
+				return 0
+			}, nil
+		case 2:
+			return func(v float64) float64 {
+				if v >= -b/a {
+					return pow(a*v+b) + c
+				}
+				return c
+			}, nil
+		case 3:
+			return func(v float64) float64 {
+				if v >= d {
+					return pow(a*v + b)
+				}
+				return c * v
+			}, nil
+		default:
+			return func(v float64) float64 {
+				if v >= d {
+					return pow(a*v+b) + e
+				}
+				return c*v + f
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("curve type %q: %w", tag[:4], ErrUnsupported)
+}
+
+// ConvertToSRGB converts img, decoded from data, to sRGB according to the profile embedded in data.
+// Images without a profile or with an sRGB profile are returned unchanged; errors wrap ErrUnsupported for
+// profiles that cannot be converted.
+func ConvertToSRGB(data []byte, img image.Image) (image.Image, error) {
+	raw, err := Extract(data)
+	if err != nil || raw == nil {
+		return img, err
+	}
+	profile, err := Parse(raw)
+	if err != nil {
+		return img, err
+	}
+	if profile.IsSRGB() {
+		return img, nil
+	}
+	return profile.ToSRGB(img), nil
+}
+
+// IsSRGB reports whether the profile matches sRGB closely enough that conversion can be skipped.
+func (p *Profile) IsSRGB() bool {
+	for r := range p.Matrix {
+		for c := range p.Matrix[r] {
+			if math.Abs(p.Matrix[r][c]-srgbMatrix[r][c]) > 0.002 {
+				return false
+			}
+		}
+	}
+	for _, curve := range p.Curves {
+		for i := 0; i <= 16; i++ {
+			v := float64(i) / 16
+			if math.Abs(srgbEncode(curve(v))-v) > 0.5/255 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ToSRGB converts img from the profile's color space to sRGB.
+// Colors outside the sRGB gamut are clipped per channel.
+func (p *Profile) ToSRGB(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+
+	var linear [3][256]float64
+	for c, curve := range p.Curves {
+		for i := range linear[c] {
+			linear[c][i] = curve(float64(i) / 255)
+		}
+	}
+	m := multiply(xyzToSRGB, p.Matrix)
+	const encodeSteps = 4096
+	var encode [encodeSteps + 1]uint8
+	for i := range encode {
+		encode[i] = uint8(srgbEncode(float64(i)/encodeSteps)*255 + 0.5)
+	}
+
+	for i := 0; i+3 < len(out.Pix); i += 4 {
+		px := out.Pix[i : i+4 : i+4]
+		alpha := px[3]
+		if alpha == 0 {
+			continue
+		}
+		var rgb [3]float64
+		for c := range rgb {
+			// Channels are premultiplied; undo that before applying the curves.
+			rgb[c] = linear[c][int(math.Min(255, float64(px[c])*255/float64(alpha)+0.5))]
+		}
+		for r := 0; r < 3; r++ {
+			v := m[r][0]*rgb[0] + m[r][1]*rgb[1] + m[r][2]*rgb[2]
+			enc := float64(encode[int(clamp(v)*encodeSteps+0.5)])
+			px[r] = uint8(enc*float64(alpha)/255 + 0.5)
+		}
+	}
+	return out
+}
+
+// clamp limits v to [0, 1].
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// multiply returns the matrix product a*b.
+func multiply(a, b [3][3]float64) [3][3]float64 {
+	var m [3][3]float64
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			for k := 0; k < 3; k++ {
+				m[r][c] += a[r][k] * b[k][c]
+			}
+		}
+	}
+	return m
+}
+
+// invert returns the inverse of a non-singular 3x3 matrix.
+func invert(m [3][3]float64) [3][3]float64 {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	var inv [3][3]float64
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			// Cofactor of the transposed position, using cyclic indices for the sign.
+			r1, r2 := (c+1)%3, (c+2)%3
+			c1, c2 := (r+1)%3, (r+2)%3
+			inv[r][c] = (m[r1][c1]*m[r2][c2] - m[r1][c2]*m[r2][c1]) / det
+		}
+	}
+	return inv
+}
@@ -0,0 +1,134 @@
+package icc
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"testing"
+)
+
+// adobeRGBMatrix holds the D50-adapted Adobe RGB (1998) colorants.
+var adobeRGBMatrix = [3][3]float64{
+	{0.6097559, 0.2052401, 0.1492240},
+	{0.3111242, 0.6256560, 0.0632197},
+	{0.0194811, 0.0608902, 0.7448387},
+}
+
+// adobeProfile builds an Adobe RGB profile with its 2.2 gamma curve.
+func adobeProfile() []byte {
+	return build("Adobe RGB (1998)", adobeRGBMatrix, func(v float64) float64 { return math.Pow(v, 563.0/256) })
+}
+
+// TestParse_SRGBAndAdobeRGB expects the embedded sRGB profile to parse as sRGB and convert as identity, and an
+// Adobe RGB profile to keep grays gray while making saturated colors more saturated in sRGB.
+func TestParse_SRGBAndAdobeRGB(t *testing.T) {
+	srgb, err := Parse(SRGB())
+	if err != nil || !srgb.IsSRGB() {
+		t.Fatalf("Parse(SRGB()) = %v, %v; want an sRGB profile", srgb, err)
+	}
+	adobe, err := Parse(adobeProfile())
+	if err != nil || adobe.IsSRGB() {
+		t.Fatalf("Parse(adobe) = %v, %v; want a non-sRGB profile", adobe, err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 3, 1))
+	gray, green, red := color.RGBA{128, 128, 128, 255}, color.RGBA{60, 150, 60, 255}, color.RGBA{200, 40, 40, 255}
+	img.SetRGBA(0, 0, gray)
+	img.SetRGBA(1, 0, green)
+	img.SetRGBA(2, 0, red)
+
+	same := srgb.ToSRGB(img)
+	for x := 0; x < 3; x++ {
+		if a, b := img.RGBAAt(x, 0), same.RGBAAt(x, 0); absDiff(a.R, b.R) > 1 || absDiff(a.G, b.G) > 1 || absDiff(a.B, b.B) > 1 {
+			t.Fatalf("sRGB conversion changed %v to %v", a, b)
+		}
+	}
+
+	converted := adobe.ToSRGB(img)
+	if g := converted.RGBAAt(0, 0); absDiff(g.R, g.G) > 1 || absDiff(g.G, g.B) > 1 {
+		t.Fatalf("gray converted to %v", g)
+	}
+	spread := func(c color.RGBA) int { return int(max(c.R, c.G, c.B)) - int(min(c.R, c.G, c.B)) }
+	for x, orig := range []color.RGBA{green, red} {
+		if got := converted.RGBAAt(x+1, 0); spread(got) <= spread(orig) {
+			t.Fatalf("Adobe RGB %v converted to %v, want more saturated", orig, got)
+		}
+	}
+
+	cmyk := append([]byte(nil), SRGB()...)
+	copy(cmyk[16:], "CMYK")
+	if _, err := Parse(cmyk); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("Parse(CMYK) = %v, want ErrUnsupported", err)
+	}
+	if _, err := Parse([]byte("not a profile")); err == nil || errors.Is(err, ErrUnsupported) {
+		t.Fatalf("Parse(garbage) = %v, want a format error", err)
+	}
+}
+
+// TestEmbedAndExtract_RoundTrip expects profiles embedded into encoded JPEG and PNG data to be extracted unchanged,
+// including profiles split across several JPEG segments, and ConvertToSRGB to apply them.
+func TestEmbedAndExtract_RoundTrip(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	var jpegBuf, pngBuf bytes.Buffer
+	if err := jpeg.Encode(&jpegBuf, img, nil); err != nil {
+		t.Fatalf("jpeg.Encode error: %v", err)
+	}
+	if err := png.Encode(&pngBuf, img); err != nil {
+		t.Fatalf("png.Encode error: %v", err)
+	}
+
+	if got, err := Extract(jpegBuf.Bytes()); got != nil || err != nil {
+		t.Fatalf("Extract(plain jpeg) = %d bytes, %v; want none", len(got), err)
+	}
+
+	// A profile larger than one APP2 segment is split and must be reassembled in order.
+	large := append(adobeProfile(), bytes.Repeat([]byte{0xAB}, 2*maxJPEGChunk)...)
+	for name, profile := range map[string][]byte{"srgb": SRGB(), "large": large} {
+		data, err := EmbedJPEG(jpegBuf.Bytes(), profile)
+		if err != nil {
+			t.Fatalf("%s: EmbedJPEG error: %v", name, err)
+		}
+		if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+			t.Fatalf("%s: embedded jpeg does not decode: %v", name, err)
+		}
+		if got, err := Extract(data); err != nil || !bytes.Equal(got, profile) {
+			t.Fatalf("%s: jpeg round trip: %d bytes, %v; want %d bytes", name, len(got), err, len(profile))
+		}
+
+		data, err = EmbedPNG(pngBuf.Bytes(), "test", profile)
+		if err != nil {
+			t.Fatalf("%s: EmbedPNG error: %v", name, err)
+		}
+		if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+			t.Fatalf("%s: embedded png does not decode: %v", name, err)
+		}
+		if got, err := Extract(data); err != nil || !bytes.Equal(got, profile) {
+			t.Fatalf("%s: png round trip: %d bytes, %v; want %d bytes", name, len(got), err, len(profile))
+		}
+	}
+
+	data, err := EmbedJPEG(jpegBuf.Bytes(), adobeProfile())
+	if err != nil {
+		t.Fatalf("EmbedJPEG error: %v", err)
+	}
+	if out, err := ConvertToSRGB(data, img); err != nil || out == image.Image(img) {
+		t.Fatalf("ConvertToSRGB(adobe) = %T, %v; want a converted copy", out, err)
+	}
+	if out, err := ConvertToSRGB(jpegBuf.Bytes(), img); err != nil || out != image.Image(img) {
+		t.Fatalf("ConvertToSRGB(no profile) = %T, %v; want the input image", out, err)
+	}
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
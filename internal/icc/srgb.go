@@ -0,0 +1,107 @@
+package icc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// d50 is the PCS illuminant written to the header and media white point.
+var d50 = [3]float64{0.9642, 1.0, 0.8249}
+
+var (
+	srgbOnce    sync.Once
+	srgbProfile []byte
+)
+
+// SRGB returns a compact ICC v2 sRGB profile (matrix/TRC with a 1024-entry curve) for embedding into outputs.
+// Callers must not modify the returned slice.
+func SRGB() []byte {
+	srgbOnce.Do(func() {
+		srgbProfile = build("sRGB IEC61966-2.1", srgbMatrix, srgbDecode)
+	})
+	return srgbProfile
+}
+
+// build encodes a display-class ICC v2 profile with the given colorants and one curve shared by all channels.
+func build(description string, matrix [3][3]float64, curve func(float64) float64) []byte {
+	type tag struct {
+		sig  string
+		data []byte
+	}
+
+	var desc bytes.Buffer
+	desc.WriteString("desc\x00\x00\x00\x00")
+	_ = binary.Write(&desc, binary.BigEndian, uint32(len(description)+1))
+	desc.WriteString(description + "\x00")
+	// Empty Unicode and ScriptCode descriptions: language, count, code, count, and the fixed 67-byte field.
+	desc.Write(make([]byte, 4+4+2+1+67))
+
+	xyz := func(v [3]float64) []byte {
+		var b bytes.Buffer
+		b.WriteString("XYZ \x00\x00\x00\x00")
+		for _, c := range v {
+			_ = binary.Write(&b, binary.BigEndian, int32(math.Round(c*65536)))
+		}
+		return b.Bytes()
+	}
+	column := func(c int) [3]float64 { return [3]float64{matrix[0][c], matrix[1][c], matrix[2][c]} }
+
+	const curveSize = 1024
+	var trc bytes.Buffer
+	trc.WriteString("curv\x00\x00\x00\x00")
+	_ = binary.Write(&trc, binary.BigEndian, uint32(curveSize))
+	for i := 0; i < curveSize; i++ {
+		_ = binary.Write(&trc, binary.BigEndian, uint16(math.Round(clamp(curve(float64(i)/(curveSize-1)))*65535)))
+	}
+
+	tags := []tag{
+		{"desc", desc.Bytes()},
+		{"cprt", []byte("text\x00\x00\x00\x00No copyright, use freely\x00")},
+		{"wtpt", xyz(d50)},
+		{"rXYZ", xyz(column(0))},
+		{"gXYZ", xyz(column(1))},
+		{"bXYZ", xyz(column(2))},
+		{"rTRC", trc.Bytes()},
+	}
+
+	var header [128]byte
+	binary.BigEndian.PutUint32(header[8:], 0x02100000)
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	copy(header[36:], "acsp")
+	for i, c := range d50 {
+		binary.BigEndian.PutUint32(header[68+4*i:], uint32(int32(math.Round(c*65536))))
+	}
+
+	// The green and blue curves share the red curve's data, which the format allows.
+	const tagCount = 9
+	offset := len(header) + 4 + 12*tagCount
+	var table, data bytes.Buffer
+	_ = binary.Write(&table, binary.BigEndian, uint32(tagCount))
+	entry := func(sig string, off, size int) {
+		table.WriteString(sig)
+		_ = binary.Write(&table, binary.BigEndian, uint32(off))
+		_ = binary.Write(&table, binary.BigEndian, uint32(size))
+	}
+	for _, t := range tags {
+		off := offset + data.Len()
+		entry(t.sig, off, len(t.data))
+		if t.sig == "rTRC" {
+			entry("gTRC", off, len(t.data))
+			entry("bTRC", off, len(t.data))
+		}
+		data.Write(t.data)
+		// Tag data must start on a 4-byte boundary.
+		for data.Len()%4 != 0 {
+			data.WriteByte(0)
+		}
+	}
+
+	out := append(header[:], table.Bytes()...)
+	out = append(out, data.Bytes()...)
+	binary.BigEndian.PutUint32(out[0:], uint32(len(out)))
+	return out
+}
@@ -0,0 +1,261 @@
+package imagediff
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"os"
+
+	_ "golang.org/x/image/bmp"
+)
+
+// DefaultThreshold is the perceptual delta below which two pixels count as equal.
+// It tolerates anti-aliasing and rounding noise while still flagging visible changes in text and edges.
+const DefaultThreshold = 0.1
+
+// maxYIQDelta is the largest possible weighted squared YIQ distance between two 8-bit colors.
+const maxYIQDelta = 35215.0
+
+// Options controls the comparison.
+// The zero value uses DefaultThreshold.
+type Options struct {
+	// Threshold is the per-pixel perceptual delta in [0, 1] above which a pixel counts as different; nil selects
+	// DefaultThreshold, and 0 counts every changed pixel.
+	Threshold *float64
+}
+
+// Result summarizes the differences between two images of equal size.
+type Result struct {
+	Width, Height int
+	// Threshold is the per-pixel delta used to count DiffPixels.
+	Threshold float64
+	// DiffPixels is the number of pixels whose delta exceeds the threshold.
+	DiffPixels int
+	// MaxDelta and MeanDelta are perceptual deltas in [0, 1] over all pixels.
+	MaxDelta  float64
+	MeanDelta float64
+	// MaxDeltaE and MeanDeltaE are CIE76 color differences in CIELAB; about 2.3 is a just-noticeable difference.
+	MaxDeltaE  float64
+	MeanDeltaE float64
+	// SSIM is the mean structural similarity of the luma channels in [-1, 1]; 1 means identical structure.
+	SSIM float64
+}
+
+// Ratio returns the fraction of differing pixels, or 0 for an empty image.
+func (r Result) Ratio() float64 {
+	total := r.Width * r.Height
+	if total == 0 {
+		return 0
+	}
+	return float64(r.DiffPixels) / float64(total)
+}
+
+// Compare measures per-pixel perceptual differences between a and b.
+// Pixels are blended onto white and compared by weighted YIQ distance, so changes in luminance weigh more than
+// changes in chroma; it returns an error when the image sizes differ or the threshold is out of range.
+func Compare(a, b image.Image, opts Options) (Result, error) {
+	threshold := DefaultThreshold
+	if opts.Threshold != nil {
+		threshold = *opts.Threshold
+	}
+	if threshold < 0 || threshold > 1 {
+		return Result{}, fmt.Errorf("imagediff: threshold %v out of range [0, 1]", threshold)
+	}
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Dx() != bb.Dx() || ab.Dy() != bb.Dy() {
+		return Result{}, fmt.Errorf("imagediff: size mismatch: %dx%d vs %dx%d", ab.Dx(), ab.Dy(), bb.Dx(), bb.Dy())
+	}
+
+	res := Result{Width: ab.Dx(), Height: ab.Dy(), Threshold: threshold}
+	lumaA := make([]float64, res.Width*res.Height)
+	lumaB := make([]float64, res.Width*res.Height)
+	var sum, sumE float64
+	for y := 0; y < res.Height; y++ {
+		for x := 0; x < res.Width; x++ {
+			r0, g0, b0 := blendOnWhite(a, ab.Min.X+x, ab.Min.Y+y)
+			r1, g1, b1 := blendOnWhite(b, bb.Min.X+x, bb.Min.Y+y)
+			lumaA[y*res.Width+x] = yiqY(r0, g0, b0)
+			lumaB[y*res.Width+x] = yiqY(r1, g1, b1)
+
+			d := colorDelta(r0, g0, b0, r1, g1, b1)
+			sum += d
+			res.MaxDelta = math.Max(res.MaxDelta, d)
+			if d > threshold {
+				res.DiffPixels++
+			}
+
+			e := deltaE76(r0, g0, b0, r1, g1, b1)
+			sumE += e
+			res.MaxDeltaE = math.Max(res.MaxDeltaE, e)
+		}
+	}
+	if n := res.Width * res.Height; n > 0 {
+		res.MeanDelta = sum / float64(n)
+		res.MeanDeltaE = sumE / float64(n)
+	}
+	res.SSIM = ssim(lumaA, lumaB, res.Width, res.Height)
+	return res, nil
+}
+
+// Write prints the result as "key: value" lines in the style of the inspect report.
+func (r Result) Write(w io.Writer) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "resolution: %dx%d\n", r.Width, r.Height)
+	fmt.Fprintf(&b, "ssim: %.5f\n", r.SSIM)
+	fmt.Fprintf(&b, "delta-e (CIE76): mean %.3f, max %.3f\n", r.MeanDeltaE, r.MaxDeltaE)
+	fmt.Fprintf(&b, "perceptual delta: mean %.4f, max %.4f\n", r.MeanDelta, r.MaxDelta)
+	fmt.Fprintf(&b, "differing pixels: %d (%.3f%%) above threshold %g\n", r.DiffPixels, 100*r.Ratio(), r.Threshold)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// LoadFile decodes a PNG, JPEG, GIF, or BMP image from path.
+func LoadFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("imagediff: open %s: %w", path, err)
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("imagediff: decode %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// Heatmap visualizes where a and b differ: a faded grayscale copy of a, with pixels above the threshold colored
+// from yellow (just above) to red (maximal difference).
+// It returns the same errors as Compare.
+func Heatmap(a, b image.Image, opts Options) (*image.RGBA, error) {
+	res, err := Compare(a, b, opts)
+	if err != nil {
+		return nil, err
+	}
+	ab, bb := a.Bounds(), b.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, res.Width, res.Height))
+	for y := 0; y < res.Height; y++ {
+		for x := 0; x < res.Width; x++ {
+			r0, g0, b0 := blendOnWhite(a, ab.Min.X+x, ab.Min.Y+y)
+			r1, g1, b1 := blendOnWhite(b, bb.Min.X+x, bb.Min.Y+y)
+			d := colorDelta(r0, g0, b0, r1, g1, b1)
+			if d <= res.Threshold {
+				gray := uint8(255 - 0.25*(255-yiqY(r0, g0, b0)))
+				out.SetRGBA(x, y, color.RGBA{R: gray, G: gray, B: gray, A: 255})
+				continue
+			}
+			k := (d - res.Threshold) / (1 - res.Threshold)
+			out.SetRGBA(x, y, color.RGBA{R: 255, G: uint8(255 * (1 - k)), A: 255})
+		}
+	}
+	return out, nil
+}
+
+// PixelDelta returns the perceptual delta in [0, 1] between pixel (ax, ay) of a and pixel (bx, by) of b.
+func PixelDelta(a, b image.Image, ax, ay, bx, by int) float64 {
+	r0, g0, b0 := blendOnWhite(a, ax, ay)
+	r1, g1, b1 := blendOnWhite(b, bx, by)
+	return colorDelta(r0, g0, b0, r1, g1, b1)
+}
+
+// colorDelta returns the normalized YIQ distance between two 8-bit-scale colors.
+func colorDelta(r0, g0, b0, r1, g1, b1 float64) float64 {
+	if r0 == r1 && g0 == g1 && b0 == b1 {
+		return 0
+	}
+	dy := yiqY(r0, g0, b0) - yiqY(r1, g1, b1)
+	di := yiqI(r0, g0, b0) - yiqI(r1, g1, b1)
+	dq := yiqQ(r0, g0, b0) - yiqQ(r1, g1, b1)
+	delta := 0.5053*dy*dy + 0.299*di*di + 0.1957*dq*dq
+	return math.Min(1, math.Sqrt(delta/maxYIQDelta))
+}
+
+// blendOnWhite returns the pixel's 8-bit-scale color composited over an opaque white background.
+func blendOnWhite(img image.Image, x, y int) (float64, float64, float64) {
+	r, g, b, a := img.At(x, y).RGBA()
+	// RGBA returns alpha-premultiplied 16-bit values; add the white showing through.
+	white := float64(0xffff - a)
+	return (float64(r) + white) / 257, (float64(g) + white) / 257, (float64(b) + white) / 257
+}
+
+func yiqY(r, g, b float64) float64 { return 0.29889531*r + 0.58662247*g + 0.11448223*b }
+func yiqI(r, g, b float64) float64 { return 0.59597799*r - 0.27417610*g - 0.32180189*b }
+func yiqQ(r, g, b float64) float64 { return 0.21147017*r - 0.52261711*g + 0.31114694*b }
+
+// deltaE76 returns the Euclidean distance of two 8-bit-scale sRGB colors in CIELAB (D65).
+func deltaE76(r0, g0, b0, r1, g1, b1 float64) float64 {
+	if r0 == r1 && g0 == g1 && b0 == b1 {
+		return 0
+	}
+	l0, a0, bb0 := srgbToLab(r0, g0, b0)
+	l1, a1, bb1 := srgbToLab(r1, g1, b1)
+	return math.Sqrt((l0-l1)*(l0-l1) + (a0-a1)*(a0-a1) + (bb0-bb1)*(bb0-bb1))
+}
+
+// srgbToLab converts an 8-bit-scale sRGB color to CIELAB with a D65 white point.
+func srgbToLab(r, g, b float64) (float64, float64, float64) {
+	lin := func(c float64) float64 {
+		c /= 255
+		if c <= 0.04045 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	rl, gl, bl := lin(r), lin(g), lin(b)
+	x := (0.4124564*rl + 0.3575761*gl + 0.1804375*bl) / 0.95047
+	y := 0.2126729*rl + 0.7151522*gl + 0.0721750*bl
+	z := (0.0193339*rl + 0.1191920*gl + 0.9503041*bl) / 1.08883
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// ssimWindow and ssimStride define the square windows averaged for SSIM.
+const (
+	ssimWindow = 8
+	ssimStride = 4
+)
+
+// ssim returns the mean structural similarity of two luma planes over overlapping square windows.
+// Images smaller than a window are treated as a single window.
+func ssim(a, b []float64, w, h int) float64 {
+	const c1, c2 = (0.01 * 255) * (0.01 * 255), (0.03 * 255) * (0.03 * 255)
+	if w == 0 || h == 0 {
+		return 1
+	}
+	win := min(ssimWindow, w, h)
+	var total float64
+	var count int
+	for y0 := 0; y0+win <= h; y0 += ssimStride {
+		for x0 := 0; x0+win <= w; x0 += ssimStride {
+			var sa, sb, saa, sbb, sab float64
+			for y := y0; y < y0+win; y++ {
+				for x := x0; x < x0+win; x++ {
+					va, vb := a[y*w+x], b[y*w+x]
+					sa += va
+					sb += vb
+					saa += va * va
+					sbb += vb * vb
+					sab += va * vb
+				}
+			}
+			n := float64(win * win)
+			ma, mb := sa/n, sb/n
+			va, vb := saa/n-ma*ma, sbb/n-mb*mb
+			cov := sab/n - ma*mb
+			total += ((2*ma*mb + c1) * (2*cov + c2)) / ((ma*ma + mb*mb + c1) * (va + vb + c2))
+			count++
+		}
+	}
+	return total / float64(count)
+}
@@ -0,0 +1,113 @@
+package imagediff
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+)
+
+// fill returns a w x h image filled with c.
+func fill(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// TestCompare_IdenticalAndDifferent expects zero deltas for identical images and full deltas for black vs. white.
+// The test fails if a visible change goes unnoticed, noise below the threshold is counted, or a threshold of 0 is
+// replaced by the default.
+func TestCompare_IdenticalAndDifferent(t *testing.T) {
+	gray := fill(10, 10, color.RGBA{128, 128, 128, 255})
+	res, err := Compare(gray, fill(10, 10, color.RGBA{128, 128, 128, 255}), Options{})
+	if err != nil {
+		t.Fatalf("Compare error: %v", err)
+	}
+	if res.DiffPixels != 0 || res.MaxDelta != 0 {
+		t.Fatalf("expected identical images, got %+v", res)
+	}
+
+	noisy := fill(10, 10, color.RGBA{130, 128, 127, 255})
+	if res, _ := Compare(gray, noisy, Options{}); res.DiffPixels != 0 || res.MaxDelta == 0 {
+		t.Fatalf("expected small non-zero delta below threshold, got %+v", res)
+	}
+	// A threshold of 0 counts every changed pixel rather than falling back to DefaultThreshold.
+	zero := 0.0
+	if res, _ := Compare(gray, noisy, Options{Threshold: &zero}); res.DiffPixels != 100 || res.Threshold != 0 {
+		t.Fatalf("expected every noisy pixel to count at threshold 0, got %+v", res)
+	}
+
+	black, white := fill(10, 10, color.Black), fill(10, 10, color.White)
+	black.Set(0, 0, color.White)
+	res, err = Compare(black, white, Options{})
+	if err != nil {
+		t.Fatalf("Compare error: %v", err)
+	}
+	if res.DiffPixels != 99 || res.MaxDelta < 0.95 || res.Ratio() != 0.99 {
+		t.Fatalf("unexpected result for black vs. white: %+v", res)
+	}
+}
+
+// TestCompare_SizeMismatchAndBadThreshold_Error expects errors instead of partial comparisons.
+func TestCompare_SizeMismatchAndBadThreshold_Error(t *testing.T) {
+	if _, err := Compare(fill(2, 2, color.Black), fill(3, 2, color.Black), Options{}); err == nil || !strings.Contains(err.Error(), "size mismatch") {
+		t.Fatalf("expected size mismatch error, got %v", err)
+	}
+	two := 2.0
+	if _, err := Compare(fill(2, 2, color.Black), fill(2, 2, color.Black), Options{Threshold: &two}); err == nil {
+		t.Fatalf("expected threshold error")
+	}
+}
+
+// TestCompare_SSIMAndDeltaE expects SSIM 1 and zero delta-E for identical images, and a lower SSIM and a
+// delta-E near the known CIELAB distance when structure and color change.
+func TestCompare_SSIMAndDeltaE(t *testing.T) {
+	stripes := fill(16, 16, color.White)
+	for y := 0; y < 16; y += 2 {
+		for x := 0; x < 16; x++ {
+			stripes.Set(x, y, color.Black)
+		}
+	}
+	res, err := Compare(stripes, stripes, Options{})
+	if err != nil {
+		t.Fatalf("Compare error: %v", err)
+	}
+	if res.SSIM != 1 || res.MaxDeltaE != 0 {
+		t.Fatalf("expected SSIM 1 and no delta-E for identical images, got %+v", res)
+	}
+
+	if res, _ := Compare(stripes, fill(16, 16, color.Gray{128}), Options{}); res.SSIM > 0.1 {
+		t.Fatalf("expected low SSIM for stripes vs. flat gray, got %v", res.SSIM)
+	}
+
+	// Black to white spans the full lightness axis: L* 0 to 100.
+	res, _ = Compare(fill(4, 4, color.Black), fill(4, 4, color.White), Options{})
+	if res.MaxDeltaE < 99.5 || res.MaxDeltaE > 100.5 || math.Abs(res.MeanDeltaE-res.MaxDeltaE) > 1e-9 {
+		t.Fatalf("expected delta-E of about 100 for black vs. white, got %+v", res)
+	}
+}
+
+// TestHeatmap_MarksDifferingPixels expects red-to-yellow pixels only where the images differ above the threshold.
+func TestHeatmap_MarksDifferingPixels(t *testing.T) {
+	a := fill(4, 4, color.White)
+	b := fill(4, 4, color.White)
+	b.Set(2, 1, color.Black)
+	heat, err := Heatmap(a, b, Options{})
+	if err != nil {
+		t.Fatalf("Heatmap error: %v", err)
+	}
+	if got := heat.RGBAAt(2, 1); got.R != 255 || got.B != 0 {
+		t.Fatalf("expected red/yellow at differing pixel, got %v", got)
+	}
+	if got := heat.RGBAAt(0, 0); got.R != got.G || got.G != got.B {
+		t.Fatalf("expected gray at equal pixel, got %v", got)
+	}
+	if _, err := Heatmap(a, fill(3, 4, color.White), Options{}); err == nil {
+		t.Fatalf("expected size mismatch error")
+	}
+}
@@ -0,0 +1,86 @@
+package inspect
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+
+	_ "github.com/nickhildebrandt/ts-release/internal/qoi"
+	_ "golang.org/x/image/bmp"
+)
+
+// Entry is a single metadata key/value pair read from an artifact.
+type Entry struct {
+	Key   string
+	Value string
+}
+
+// Report summarizes a generated artifact for diagnostics.
+type Report struct {
+	Path     string
+	Format   string
+	Width    int
+	Height   int
+	Metadata []Entry
+	// Manifest is the checksum manifest found near the file, empty if none.
+	Manifest string
+	// Checksum is one of the Checksum states.
+	Checksum string
+}
+
+// File decodes the artifact at path and collects its format, resolution, embedded metadata, and manifest checksum state.
+// It returns an error if the file cannot be read, the image header cannot be decoded, or the metadata or manifest is malformed.
+func File(path string) (Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Report{}, fmt.Errorf("inspect: read %q: %w", path, err)
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Report{}, fmt.Errorf("inspect: decode %q: %w", path, err)
+	}
+
+	report := Report{Path: path, Format: format, Width: cfg.Width, Height: cfg.Height}
+	switch format {
+	case "png":
+		report.Metadata, err = pngText(data)
+	case "jpeg":
+		report.Metadata, err = jpegMetadata(data)
+	}
+	if err != nil {
+		return Report{}, fmt.Errorf("inspect: %q: %w", path, err)
+	}
+
+	if report.Manifest, report.Checksum, err = verifyChecksum(path, data); err != nil {
+		return Report{}, err
+	}
+	return report, nil
+}
+
+// Write prints the report in a human-readable, line-oriented form.
+func (r Report) Write(w io.Writer) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "file: %s\n", r.Path)
+	fmt.Fprintf(&b, "format: %s\n", r.Format)
+	fmt.Fprintf(&b, "resolution: %dx%d\n", r.Width, r.Height)
+	if len(r.Metadata) == 0 {
+		b.WriteString("metadata: none\n")
+	} else {
+		b.WriteString("metadata:\n")
+		for _, e := range r.Metadata {
+			fmt.Fprintf(&b, "  %s: %s\n", e.Key, e.Value)
+		}
+	}
+	if r.Manifest != "" {
+		fmt.Fprintf(&b, "manifest: %s\n", r.Manifest)
+	}
+	fmt.Fprintf(&b, "checksum: %s\n", r.Checksum)
+	_, err := w.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,194 @@
+package inspect
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nickhildebrandt/ts-release/internal/install"
+)
+
+// sampleImage builds a small deterministic test image.
+func sampleImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	return img
+}
+
+// TestFile_PNG_ReadsBackInstalledText verifies that metadata written by Install round-trips through inspect,
+// including a non-ASCII target name stored as iTXt.
+func TestFile_PNG_ReadsBackInstalledText(t *testing.T) {
+	root := t.TempDir()
+	info := install.ImageInfo{TargetName: "Zürich", BuildID: "b-1", GeneratorVersion: "1.2.3", SourceURL: "https://example.com/a.jpg"}
+	if err := install.Install(root, sampleImage(), "b-1", install.Options{PNG: true, Image: info}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+
+	report, err := File(filepath.Join(root, "usr", "share", "backgrounds", "tssh", "background.png"))
+	if err != nil {
+		t.Fatalf("File error: %v", err)
+	}
+	if report.Format != "png" || report.Width != 4 || report.Height != 3 {
+		t.Fatalf("unexpected report header: %+v", report)
+	}
+
+	got := map[string]string{}
+	for _, e := range report.Metadata {
+		got[e.Key] = e.Value
+	}
+	want := map[string]string{
+		"tssh:Target":  "Zürich",
+		"tssh:BuildID": "b-1",
+		"Software":     "ts-release 1.2.3",
+		"Source":       "https://example.com/a.jpg",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("metadata %q: got %q want %q", k, got[k], v)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := report.Write(&out); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	if !strings.Contains(out.String(), "resolution: 4x3") || !strings.Contains(out.String(), "tssh:Target: Zürich") {
+		t.Fatalf("unexpected report output:\n%s", out.String())
+	}
+}
+
+// TestFile_NotAnImage_Error expects an error for files that are not decodable images.
+func TestFile_NotAnImage_Error(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.bin")
+	if err := os.WriteFile(path, []byte("garbage"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := File(path); err == nil {
+		t.Fatalf("expected error")
+	} else if !strings.Contains(err.Error(), "decode") {
+		t.Fatalf("unexpected error: %q", err.Error())
+	}
+}
+
+// TestFile_JPEG_ReportsMetadataAndChecksum expects EXIF/XMP entries from an installed background.jpg and a matching checksum,
+// then a mismatch once the file is modified after installation.
+func TestFile_JPEG_ReportsMetadataAndChecksum(t *testing.T) {
+	root := t.TempDir()
+	info := install.ImageInfo{TargetName: "t", BuildID: "b-7", GeneratorVersion: "1.0", SourceURL: "https://example.com/a.jpg"}
+	if err := install.Install(root, sampleImage(), "b-7", install.Options{Image: info}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	jpgPath := filepath.Join(root, "usr", "share", "backgrounds", "tssh", "background.jpg")
+
+	report, err := File(jpgPath)
+	if err != nil {
+		t.Fatalf("File error: %v", err)
+	}
+	got := map[string]string{}
+	for _, e := range report.Metadata {
+		got[e.Key] = e.Value
+	}
+	want := map[string]string{
+		"EXIF ImageDescription": "TSSH t build b-7",
+		"EXIF Software":         "ts-release 1.0",
+		"EXIF DocumentName":     "https://example.com/a.jpg",
+		"XMP tssh:BuildID":      "b-7",
+		"XMP dc:source":         "https://example.com/a.jpg",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("metadata %q: got %q want %q (all: %v)", k, got[k], v, got)
+		}
+	}
+	if report.Checksum != ChecksumOK {
+		t.Fatalf("checksum: got %q want %q", report.Checksum, ChecksumOK)
+	}
+
+	data, err := os.ReadFile(jpgPath)
+	if err != nil {
+		t.Fatalf("read jpg: %v", err)
+	}
+	if err := os.WriteFile(jpgPath, append(data, 0), 0o644); err != nil {
+		t.Fatalf("write jpg: %v", err)
+	}
+	report, err = File(jpgPath)
+	if err != nil {
+		t.Fatalf("File error: %v", err)
+	}
+	if report.Checksum != ChecksumMismatch {
+		t.Fatalf("checksum after tampering: got %q want %q", report.Checksum, ChecksumMismatch)
+	}
+}
+
+// TestFile_BMP_NoManifest expects format/resolution for a BMP outside any rootfs and the no-manifest state.
+func TestFile_BMP_NoManifest(t *testing.T) {
+	root := t.TempDir()
+	if err := install.Install(root, sampleImage(), "b", install.Options{}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "boot", "splash.bmp"))
+	if err != nil {
+		t.Fatalf("read bmp: %v", err)
+	}
+	loose := filepath.Join(t.TempDir(), "splash.bmp")
+	if err := os.WriteFile(loose, data, 0o644); err != nil {
+		t.Fatalf("write bmp: %v", err)
+	}
+
+	report, err := File(loose)
+	if err != nil {
+		t.Fatalf("File error: %v", err)
+	}
+	if report.Format != "bmp" || report.Width != 4 || report.Height != 3 {
+		t.Fatalf("unexpected report header: %+v", report)
+	}
+	if report.Manifest != "" || report.Checksum != ChecksumNoManifest {
+		t.Fatalf("expected no manifest, got %q / %q", report.Manifest, report.Checksum)
+	}
+}
+
+// TestVerifyRootFS_ReportsChangedAndMissingFiles expects every manifest entry of an installed rootfs to be checked in
+// manifest order, with changed and deleted files reported as such. The test fails if a state is wrong or a missing
+// manifest is not an error.
+func TestVerifyRootFS_ReportsChangedAndMissingFiles(t *testing.T) {
+	root := t.TempDir()
+	if err := install.Install(root, sampleImage(), "b-1", install.Options{}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	checks, err := VerifyRootFS(root)
+	if err != nil {
+		t.Fatalf("VerifyRootFS error: %v", err)
+	}
+	if len(checks) != 3 || checks[0].Path != "boot/splash.bmp" {
+		t.Fatalf("checks: %+v", checks)
+	}
+	for _, c := range checks {
+		if c.State != ChecksumOK {
+			t.Fatalf("fresh install: %+v", c)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "etc", "tssh.build"), []byte("b-2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "boot", "splash.bmp")); err != nil {
+		t.Fatal(err)
+	}
+	if checks, err = VerifyRootFS(root); err != nil {
+		t.Fatalf("VerifyRootFS error: %v", err)
+	}
+	states := map[string]string{}
+	for _, c := range checks {
+		states[c.Path] = c.State
+	}
+	if states["boot/splash.bmp"] != ChecksumMissing || states["etc/tssh.build"] != ChecksumMismatch || states["usr/share/backgrounds/tssh/background.jpg"] != ChecksumOK {
+		t.Fatalf("after tampering: %v", states)
+	}
+	if _, err := VerifyRootFS(t.TempDir()); err == nil {
+		t.Fatal("expected an error without a manifest")
+	}
+}
@@ -0,0 +1,153 @@
+package inspect
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+const (
+	exifHeader = "Exif\x00\x00"
+	xmpHeader  = "http://ns.adobe.com/xap/1.0/\x00"
+)
+
+// exifTagNames maps the IFD0 ASCII tags of interest to display names.
+var exifTagNames = map[uint16]string{
+	0x010D: "DocumentName",
+	0x010E: "ImageDescription",
+	0x010F: "Make",
+	0x0110: "Model",
+	0x0131: "Software",
+	0x0132: "DateTime",
+	0x013B: "Artist",
+	0x8298: "Copyright",
+}
+
+// jpegMetadata walks the JPEG marker segments up to the start of scan and collects EXIF IFD0 strings and XMP attributes.
+// It returns an error for truncated segments or malformed EXIF structures.
+func jpegMetadata(data []byte) ([]Entry, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("jpeg: missing SOI marker")
+	}
+
+	var entries []Entry
+	rest := data[2:]
+	for len(rest) >= 4 {
+		if rest[0] != 0xFF {
+			return nil, fmt.Errorf("jpeg: expected marker, got 0x%02x", rest[0])
+		}
+		marker := rest[1]
+		if marker == 0xDA || marker == 0xD9 {
+			// Start of scan or end of image: no more metadata segments.
+			break
+		}
+		length := int(binary.BigEndian.Uint16(rest[2:4]))
+		if length < 2 || len(rest) < 2+length {
+			return nil, fmt.Errorf("jpeg: truncated segment 0x%02x", marker)
+		}
+		body := rest[4 : 2+length]
+		rest = rest[2+length:]
+
+		if marker != 0xE1 {
+			continue
+		}
+		switch {
+		case bytes.HasPrefix(body, []byte(exifHeader)):
+			exif, err := parseEXIF(body[len(exifHeader):])
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, exif...)
+		case bytes.HasPrefix(body, []byte(xmpHeader)):
+			entries = append(entries, parseXMP(body[len(xmpHeader):])...)
+		}
+	}
+	return entries, nil
+}
+
+// parseEXIF reads the ASCII tags of IFD0 from a TIFF structure in either byte order.
+func parseEXIF(tiff []byte) ([]Entry, error) {
+	if len(tiff) < 8 {
+		return nil, fmt.Errorf("jpeg: truncated EXIF header")
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "MM":
+		order = binary.BigEndian
+	case "II":
+		order = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("jpeg: invalid EXIF byte order")
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return nil, fmt.Errorf("jpeg: EXIF IFD offset out of range")
+	}
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+	if ifd+2+count*12 > len(tiff) {
+		return nil, fmt.Errorf("jpeg: truncated EXIF IFD")
+	}
+
+	var entries []Entry
+	for i := 0; i < count; i++ {
+		e := tiff[ifd+2+i*12 : ifd+2+(i+1)*12]
+		tag := order.Uint16(e[0:2])
+		typ := order.Uint16(e[2:4])
+		n := int(order.Uint32(e[4:8]))
+		name, known := exifTagNames[tag]
+		if !known || typ != 2 {
+			continue
+		}
+		value := e[8:12]
+		if n > 4 {
+			off := int(order.Uint32(e[8:12]))
+			if off < 0 || off+n > len(tiff) {
+				return nil, fmt.Errorf("jpeg: EXIF tag %s value out of range", name)
+			}
+			value = tiff[off : off+n]
+		} else {
+			value = value[:n]
+		}
+		entries = append(entries, Entry{Key: "EXIF " + name, Value: strings.TrimRight(string(value), "\x00")})
+	}
+	return entries, nil
+}
+
+// parseXMP collects the attributes of all rdf:Description elements as "XMP prefix:name" entries.
+// Malformed XMP yields whatever was parsed before the error, since it is informational only.
+func parseXMP(packet []byte) []Entry {
+	var entries []Entry
+	prefixes := map[string]string{}
+	dec := xml.NewDecoder(bytes.NewReader(packet))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return entries
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, a := range start.Attr {
+			if a.Name.Space == "xmlns" {
+				prefixes[a.Value] = a.Name.Local
+			}
+		}
+		if start.Name.Local != "Description" {
+			continue
+		}
+		for _, a := range start.Attr {
+			if a.Name.Space == "xmlns" || a.Name.Space == "" || prefixes[a.Name.Space] == "rdf" {
+				continue
+			}
+			prefix := prefixes[a.Name.Space]
+			if prefix == "" {
+				prefix = a.Name.Space
+			}
+			entries = append(entries, Entry{Key: "XMP " + prefix + ":" + a.Name.Local, Value: a.Value})
+		}
+	}
+}
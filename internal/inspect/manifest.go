@@ -0,0 +1,139 @@
+package inspect
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickhildebrandt/ts-release/internal/install"
+)
+
+// Checksum states reported for an artifact.
+const (
+	ChecksumNoManifest = "no manifest found"
+	ChecksumNotListed  = "not listed in manifest"
+	ChecksumOK         = "ok"
+	ChecksumMismatch   = "MISMATCH"
+	ChecksumMissing    = "missing"
+)
+
+// FileCheck is the checksum state of one file listed in a rootfs manifest.
+type FileCheck struct {
+	// Path is relative to the rootfs, as in the manifest.
+	Path  string
+	State string
+}
+
+// VerifyRootFS compares every file listed in the manifest of rootFS with its checksum, in manifest order. It returns an
+// error if the manifest cannot be read or is malformed; missing and changed files are reported in their state.
+func VerifyRootFS(rootFS string) ([]FileCheck, error) {
+	// Without a manifest of any profile, the Linux one is read so that the error names the usual location.
+	rel := install.ManifestPath
+	if _, layout, err := install.DetectLayout(rootFS); err == nil {
+		rel = layout.Manifest()
+	}
+	manifest := filepath.Join(rootFS, filepath.FromSlash(rel))
+	names, sums, err := readManifestEntries(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("inspect: %w", err)
+	}
+	checks := make([]FileCheck, 0, len(names))
+	for _, name := range names {
+		check := FileCheck{Path: name, State: ChecksumOK}
+		data, err := os.ReadFile(filepath.Join(rootFS, filepath.FromSlash(name)))
+		switch {
+		case os.IsNotExist(err):
+			check.State = ChecksumMissing
+		case err != nil:
+			return nil, fmt.Errorf("inspect: read %q: %w", name, err)
+		default:
+			if got := sha256.Sum256(data); hex.EncodeToString(got[:]) != sums[name] {
+				check.State = ChecksumMismatch
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// verifyChecksum looks for the rootfs manifest in the file's ancestor directories and compares the file's SHA-256 against it.
+// It returns the manifest path (empty if none was found) and one of the Checksum states.
+func verifyChecksum(path string, data []byte) (string, string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", fmt.Errorf("inspect: resolve %q: %w", path, err)
+	}
+
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		_, layout, err := install.DetectLayout(dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", "", err
+		}
+		if err == nil {
+			manifest := filepath.Join(dir, filepath.FromSlash(layout.Manifest()))
+			sums, err := readManifest(manifest)
+			if err != nil {
+				return "", "", err
+			}
+			rel, relErr := filepath.Rel(dir, abs)
+			if relErr != nil {
+				return manifest, ChecksumNotListed, nil
+			}
+			want, listed := sums[filepath.ToSlash(rel)]
+			if !listed {
+				return manifest, ChecksumNotListed, nil
+			}
+			got := sha256.Sum256(data)
+			if hex.EncodeToString(got[:]) != want {
+				return manifest, ChecksumMismatch, nil
+			}
+			return manifest, ChecksumOK, nil
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return "", ChecksumNoManifest, nil
+		}
+	}
+}
+
+// readManifest parses a sha256sum-style manifest into a map of relative path to hex digest.
+func readManifest(path string) (map[string]string, error) {
+	_, sums, err := readManifestEntries(path)
+	return sums, err
+}
+
+// readManifestEntries parses a sha256sum-style manifest into its paths in file order and a map of path to hex digest.
+func readManifestEntries(path string) ([]string, map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	var names []string
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			return nil, nil, fmt.Errorf("inspect: malformed manifest line in %q: %q", path, line)
+		}
+		if _, dup := sums[name]; !dup {
+			names = append(names, name)
+		}
+		sums[name] = strings.ToLower(sum)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("inspect: read manifest %q: %w", path, err)
+	}
+	return names, sums, nil
+}
@@ -0,0 +1,118 @@
+package inspect
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// pngText walks the PNG chunk list and returns all tEXt, zTXt, and iTXt entries in file order.
+// It returns an error for truncated chunks or undecodable compressed text.
+func pngText(data []byte) ([]Entry, error) {
+	if !bytes.HasPrefix(data, []byte(pngSignature)) {
+		return nil, fmt.Errorf("png: missing signature")
+	}
+
+	var entries []Entry
+	rest := data[len(pngSignature):]
+	for len(rest) >= 12 {
+		length := binary.BigEndian.Uint32(rest[:4])
+		chunkType := string(rest[4:8])
+		if uint64(len(rest)) < 12+uint64(length) {
+			return nil, fmt.Errorf("png: truncated %s chunk", chunkType)
+		}
+		body := rest[8 : 8+length]
+		rest = rest[12+length:]
+
+		var (
+			entry Entry
+			err   error
+		)
+		switch chunkType {
+		case "tEXt":
+			entry, err = parseTEXt(body)
+		case "zTXt":
+			entry, err = parseZTXt(body)
+		case "iTXt":
+			entry, err = parseITXt(body)
+		case "IEND":
+			return entries, nil
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// parseTEXt splits a tEXt body into keyword and Latin-1 text.
+func parseTEXt(body []byte) (Entry, error) {
+	key, text, ok := bytes.Cut(body, []byte{0})
+	if !ok {
+		return Entry{}, fmt.Errorf("png: malformed tEXt chunk")
+	}
+	return Entry{Key: string(key), Value: latin1(text)}, nil
+}
+
+// parseZTXt splits a zTXt body into keyword and zlib-compressed Latin-1 text.
+func parseZTXt(body []byte) (Entry, error) {
+	key, rest, ok := bytes.Cut(body, []byte{0})
+	if !ok || len(rest) < 1 {
+		return Entry{}, fmt.Errorf("png: malformed zTXt chunk")
+	}
+	text, err := inflate(rest[1:])
+	if err != nil {
+		return Entry{}, fmt.Errorf("png: zTXt %q: %w", key, err)
+	}
+	return Entry{Key: string(key), Value: latin1(text)}, nil
+}
+
+// parseITXt splits an iTXt body into keyword and UTF-8 text, inflating it if the compression flag is set.
+func parseITXt(body []byte) (Entry, error) {
+	key, rest, ok := bytes.Cut(body, []byte{0})
+	if !ok || len(rest) < 2 {
+		return Entry{}, fmt.Errorf("png: malformed iTXt chunk")
+	}
+	compressed := rest[0] == 1
+	rest = rest[2:]
+	// Skip language tag and translated keyword.
+	for i := 0; i < 2; i++ {
+		if _, rest, ok = bytes.Cut(rest, []byte{0}); !ok {
+			return Entry{}, fmt.Errorf("png: malformed iTXt chunk")
+		}
+	}
+	text := rest
+	if compressed {
+		var err error
+		if text, err = inflate(rest); err != nil {
+			return Entry{}, fmt.Errorf("png: iTXt %q: %w", key, err)
+		}
+	}
+	return Entry{Key: string(key), Value: string(text)}, nil
+}
+
+// inflate decompresses zlib data.
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// latin1 converts ISO 8859-1 bytes to a UTF-8 string.
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
@@ -0,0 +1,92 @@
+package install
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// File is a generated file to be written into a rootfs as it is, e.g. from a packed build.
+type File struct {
+	// Path is the clean, slash-separated location in the rootfs.
+	Path string
+	Data []byte
+}
+
+// ApplyOptions controls how Apply writes; its fields mean the same as those of Options.
+type ApplyOptions struct {
+	Resolver       Resolver
+	Sync           bool
+	SkipSpaceCheck bool
+	Ownership      *Ownership
+	Backup         *Backup
+}
+
+// Apply writes files into the given rootfs, creating missing directories and overwriting existing files, with the same
+// symlink, durability, and ownership handling as Install. It returns an error for an invalid rootfs, paths that are
+// not clean rootfs-relative paths or that symlinks lead out of the rootfs, and any write failure.
+func Apply(rootFS string, files []File, opts ApplyOptions) (err error) {
+	if err := checkRootFS(rootFS); err != nil {
+		return err
+	}
+	var size uint64
+	for _, f := range files {
+		if f.Path == "" || path.IsAbs(f.Path) || path.Clean(f.Path) != f.Path || f.Path == ".." || strings.HasPrefix(f.Path, "../") {
+			return fmt.Errorf("install: %q is not a clean rootfs-relative path", f.Path)
+		}
+		size += uint64(len(f.Data))
+	}
+	if opts.Ownership != nil {
+		if err := opts.Ownership.validate(); err != nil {
+			return err
+		}
+	}
+	if opts.Backup != nil {
+		if err := opts.Backup.validate(); err != nil {
+			return err
+		}
+	}
+	resolver, err := ParseResolver(string(opts.Resolver))
+	if err != nil {
+		return err
+	}
+
+	r, err := openRoot(rootFS, resolver)
+	if err != nil {
+		return err
+	}
+	r.sync = opts.Sync
+	if opts.Backup != nil {
+		r.backup = newBackup(*opts.Backup)
+	}
+	defer func() {
+		if closeErr := r.close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("install: close rootfs: %w", closeErr)
+		}
+	}()
+	if !opts.SkipSpaceCheck {
+		if err := checkSpace(rootFS, size); err != nil {
+			return err
+		}
+	}
+
+	written := make([]string, 0, len(files))
+	for _, f := range files {
+		host := filepath.Join(rootFS, filepath.FromSlash(f.Path))
+		if err := r.mkdirAll(filepath.Dir(host)); err != nil {
+			return err
+		}
+		if err := r.writeFile(host, f.Data); err != nil {
+			return fmt.Errorf("install: write %q: %w", host, err)
+		}
+		written = append(written, host)
+	}
+	if r.backup != nil {
+		written = append(written, r.backup.written...)
+	}
+	if opts.Ownership != nil {
+		return writeOwnership(rootFS, *opts.Ownership, written)
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BackupSuffix is appended to the name of a file to save it next to itself when Backup.Dir is empty.
+const BackupSuffix = ".bak"
+
+// BackupTimeLayout is the time layout of the names of the per-run subdirectories of Backup.Dir, which sort by time.
+const BackupTimeLayout = "20060102T150405Z"
+
+// Backup saves the files Install and Apply are about to overwrite, e.g. hand-tuned splashes, so Restore can put them
+// back.
+type Backup struct {
+	// Dir is a rootfs-relative directory, such as var/backups/tssh, that receives one subdirectory per run named by
+	// Time, holding the saved files at their rootfs-relative paths. Empty saves each file next to itself with
+	// BackupSuffix instead; an existing backup there is kept, so it holds the file from before the first run.
+	Dir string
+	// Time names the run's subdirectory of Dir; zero uses the current time. Restore reads the latest run if zero.
+	Time time.Time
+}
+
+// validate checks that Dir is a clean rootfs-relative path.
+func (b Backup) validate() error {
+	if b.Dir == "" {
+		return nil
+	}
+	if path.IsAbs(b.Dir) || path.Clean(b.Dir) != b.Dir || b.Dir == "." || b.Dir == ".." || strings.HasPrefix(b.Dir, "../") {
+		return fmt.Errorf("install: backup directory %q is not a clean rootfs-relative path", b.Dir)
+	}
+	return nil
+}
+
+// backup is the state of a Backup while a root writes, set as root.backup.
+type backup struct {
+	// run is the rootfs-relative directory of this run below Dir; empty saves next to the file.
+	run string
+	// seen holds the rootfs-relative paths already saved or found missing, so a file written twice is saved once.
+	seen map[string]bool
+	// written holds the host paths of the saved files, for ownership and times.
+	written []string
+	// mu guards seen and written against images written concurrently.
+	mu sync.Mutex
+}
+
+// newBackup prepares saving files as b describes.
+func newBackup(b Backup) *backup {
+	bk := &backup{seen: map[string]bool{}}
+	if b.Dir != "" {
+		t := b.Time
+		if t.IsZero() {
+			t = time.Now()
+		}
+		bk.run = path.Join(b.Dir, t.UTC().Format(BackupTimeLayout))
+	}
+	return bk
+}
+
+// save copies the regular file at rel, if there is one, to its backup location before it is overwritten. Copying
+// rather than renaming keeps a durable write atomic and follows symlinks inside the rootfs as the write does.
+func (b *backup) save(r *root, rel string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.seen[rel] {
+		return nil
+	}
+	b.seen[rel] = true
+	data, ok, err := r.readFile(rel)
+	if err != nil || !ok {
+		return err
+	}
+	dst := rel + BackupSuffix
+	if b.run != "" {
+		dst = path.Join(b.run, rel)
+	} else if _, exists, err := r.readFile(dst); err != nil || exists {
+		return err
+	}
+	host := filepath.Join(r.dir, filepath.FromSlash(dst))
+	if err := r.mkdirAll(filepath.Dir(host)); err != nil {
+		return err
+	}
+	if err := r.write(dst, data); err != nil {
+		return fmt.Errorf("install: back up %q: %w", rel, err)
+	}
+	b.written = append(b.written, host)
+	return nil
+}
+
+// readFile reads the regular file at the rootfs-relative path rel. ok is false if there is none, or something else.
+func (r *root) readFile(rel string) (data []byte, ok bool, err error) {
+	f, err := r.beneath(rel, os.O_RDONLY, 0)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("install: open %q: %w", rel, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, false, fmt.Errorf("install: stat %q: %w", rel, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, false, nil
+	}
+	data, err = io.ReadAll(f)
+	if err != nil {
+		return nil, false, fmt.Errorf("install: read %q: %w", rel, err)
+	}
+	return data, true, nil
+}
+
+// RestoreOptions controls what Restore puts back and how it writes.
+type RestoreOptions struct {
+	// Profile and Product select the layout whose manifest lists the files saved with BackupSuffix; empty selects
+	// ProfileLinux and DefaultProduct.
+	Profile Profile
+	Product string
+	// Backup is where the files were saved; its Time selects a run of Backup.Dir.
+	Backup   Backup
+	Resolver Resolver
+	Sync     bool
+}
+
+// Restore copies saved files in rootFS back into place and returns their rootfs-relative paths in lexical order.
+// Without Backup.Dir it restores the files listed in the layout's manifest, and the manifest itself, that have a copy
+// with BackupSuffix; with it, every file of the selected run. Backups are kept, and files without one are left as
+// they are. It returns an error if there is nothing to restore.
+func Restore(rootFS string, opts RestoreOptions) (restored []string, err error) {
+	if err := checkRootFS(rootFS); err != nil {
+		return nil, err
+	}
+	profile, err := ParseProfile(string(opts.Profile))
+	if err != nil {
+		return nil, err
+	}
+	product, err := ParseProduct(opts.Product)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.Backup.validate(); err != nil {
+		return nil, err
+	}
+	resolver, err := ParseResolver(string(opts.Resolver))
+	if err != nil {
+		return nil, err
+	}
+	r, err := openRoot(rootFS, resolver)
+	if err != nil {
+		return nil, err
+	}
+	r.sync = opts.Sync
+	defer func() {
+		if closeErr := r.close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("install: close rootfs: %w", closeErr)
+		}
+	}()
+
+	// saved maps each path to restore to the rootfs-relative path of its copy.
+	saved := map[string]string{}
+	if opts.Backup.Dir == "" {
+		manifest := profile.ProductLayout(product).Manifest()
+		data, ok, err := r.readFile(manifest)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("install: no manifest %s in %q lists files to restore", manifest, rootFS)
+		}
+		candidates := []string{manifest}
+		for line := range strings.Lines(string(data)) {
+			if _, p, ok := strings.Cut(strings.TrimSuffix(line, "\n"), "  "); ok {
+				candidates = append(candidates, p)
+			}
+		}
+		for _, p := range candidates {
+			if _, ok, err := r.readFile(p + BackupSuffix); err != nil {
+				return nil, err
+			} else if ok {
+				saved[p] = p + BackupSuffix
+			}
+		}
+	} else {
+		run, err := r.backupRun(opts.Backup)
+		if err != nil {
+			return nil, err
+		}
+		files, err := r.regularFiles(run)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range files {
+			saved[p] = path.Join(run, p)
+		}
+	}
+	if len(saved) == 0 {
+		return nil, fmt.Errorf("install: no backups to restore in %q", rootFS)
+	}
+
+	for _, p := range slices.Sorted(maps.Keys(saved)) {
+		data, _, err := r.readFile(saved[p])
+		if err != nil {
+			return nil, err
+		}
+		if err := r.mkdirAll(filepath.Join(rootFS, filepath.FromSlash(path.Dir(p)))); err != nil {
+			return nil, err
+		}
+		if err := r.write(p, data); err != nil {
+			return nil, fmt.Errorf("install: restore %q: %w", p, err)
+		}
+		restored = append(restored, p)
+	}
+	return restored, nil
+}
+
+// backupRun returns the rootfs-relative directory of the run of b.Dir that b.Time names, or of the latest run if it
+// is zero.
+func (r *root) backupRun(b Backup) (string, error) {
+	if !b.Time.IsZero() {
+		run := path.Join(b.Dir, b.Time.UTC().Format(BackupTimeLayout))
+		if _, err := r.readDir(run); err != nil {
+			return "", err
+		}
+		return run, nil
+	}
+	entries, err := r.readDir(b.Dir)
+	if err != nil {
+		return "", err
+	}
+	latest := ""
+	for _, e := range entries {
+		if _, err := time.Parse(BackupTimeLayout, e.Name()); err == nil && e.IsDir() && e.Name() > latest {
+			latest = e.Name()
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("install: backup directory %s holds no runs", b.Dir)
+	}
+	return path.Join(b.Dir, latest), nil
+}
+
+// readDir lists the directory at the rootfs-relative path rel.
+func (r *root) readDir(rel string) ([]fs.DirEntry, error) {
+	dir, err := r.beneath(rel, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("install: open %q: %w", rel, err)
+	}
+	defer dir.Close()
+	entries, err := dir.ReadDir(-1)
+	if err != nil {
+		return nil, fmt.Errorf("install: read %q: %w", rel, err)
+	}
+	return entries, nil
+}
+
+// regularFiles returns the paths of the regular files below the rootfs-relative directory rel, relative to it.
+func (r *root) regularFiles(rel string) ([]string, error) {
+	entries, err := r.readDir(rel)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		switch {
+		case e.IsDir():
+			sub, err := r.regularFiles(path.Join(rel, e.Name()))
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range sub {
+				files = append(files, path.Join(e.Name(), s))
+			}
+		case e.Type().IsRegular():
+			files = append(files, e.Name())
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,170 @@
+package install
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// BoardDir is the rootfs-relative directory of the board splash outputs, the boot partition of embedded targets.
+const BoardDir = "boot"
+
+// maxBoardSide bounds the panel resolution of a board, matching the largest spanning canvas.
+const maxBoardSide = 16384
+
+// Board describes the boot splash outputs of an embedded board: the native resolution of its panel and the names of
+// the files its boot flow reads from BoardDir.
+type Board struct {
+	Name   string `json:"name"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	// Framebuffer is the name of the raw RGB565 framebuffer dump; empty skips it.
+	Framebuffer string `json:"framebuffer,omitempty"`
+	// BMP is the name of the U-Boot splash BMP; empty skips it.
+	BMP string `json:"bmp,omitempty"`
+	// BMPDepth is the bit depth of the BMP: 8 for U-Boot builds without CONFIG_BMP_24BPP, or 24; 0 selects 8.
+	BMPDepth int `json:"bmp_depth,omitempty"`
+}
+
+// boards are the built-in board profiles, keyed by name.
+var boards = map[string]Board{
+	"rpi-touch":   {Name: "rpi-touch", Width: 800, Height: 480, Framebuffer: "splash-rpi.rgb565", BMP: "splash-rpi.bmp"},
+	"rpi-touch2":  {Name: "rpi-touch2", Width: 720, Height: 1280, Framebuffer: "splash-rpi.rgb565", BMP: "splash-rpi.bmp"},
+	"uboot-1080p": {Name: "uboot-1080p", Width: 1920, Height: 1080, BMP: "splash-uboot.bmp", BMPDepth: 24},
+}
+
+// Boards returns the names of the built-in board profiles in a stable order for help output and validation.
+func Boards() []string {
+	return []string{"rpi-touch", "rpi-touch2", "uboot-1080p"}
+}
+
+// ParseBoard returns the built-in board profile of the given name. Unknown names return an error.
+func ParseBoard(name string) (Board, error) {
+	board, ok := boards[name]
+	if !ok {
+		return Board{}, fmt.Errorf("install: unknown board %q (available: %s)", name, strings.Join(Boards(), ", "))
+	}
+	return board, nil
+}
+
+// LoadBoard reads a board profile from a JSON file, for panels without a built-in profile.
+// Unknown fields are rejected so typos do not silently fall back to defaults.
+func LoadBoard(path string) (Board, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Board{}, fmt.Errorf("install: read board %s: %w", path, err)
+	}
+	var board Board
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&board); err != nil {
+		return Board{}, fmt.Errorf("install: decode board %s: %w", path, err)
+	}
+	if err := board.validate(); err != nil {
+		return Board{}, err
+	}
+	return board, nil
+}
+
+// validate checks the resolution, the bit depth, and that the file names stay inside BoardDir without replacing
+// the static boot splash.
+func (b Board) validate() error {
+	if b.Width <= 0 || b.Height <= 0 || b.Width > maxBoardSide || b.Height > maxBoardSide {
+		return fmt.Errorf("install: board %q: resolution %dx%d out of range [1, %d]", b.Name, b.Width, b.Height, maxBoardSide)
+	}
+	if b.BMPDepth != 0 && b.BMPDepth != 8 && b.BMPDepth != 24 {
+		return fmt.Errorf("install: board %q: bmp depth %d must be 8 or 24", b.Name, b.BMPDepth)
+	}
+	if b.Framebuffer == "" && b.BMP == "" {
+		return fmt.Errorf("install: board %q writes neither a framebuffer dump nor a bmp", b.Name)
+	}
+	if b.Framebuffer == b.BMP {
+		return fmt.Errorf("install: board %q uses %q for both outputs", b.Name, b.BMP)
+	}
+	for _, name := range []string{b.Framebuffer, b.BMP} {
+		if name == "" {
+			continue
+		}
+		if name == "." || name == ".." || strings.ContainsAny(name, `/\`) || slices.Contains(bootSplashNames(), name) {
+			return fmt.Errorf("install: board %q: invalid file name %q", b.Name, name)
+		}
+	}
+	return nil
+}
+
+// BoardSplash is the splash of a board rendered at its panel resolution.
+type BoardSplash struct {
+	Board Board
+	Image image.Image
+}
+
+// validate checks the board and that the image has its panel resolution.
+func (s BoardSplash) validate() error {
+	if err := s.Board.validate(); err != nil {
+		return err
+	}
+	if s.Image == nil {
+		return fmt.Errorf("install: board %q has no splash image", s.Board.Name)
+	}
+	if size := s.Image.Bounds().Size(); size.X != s.Board.Width || size.Y != s.Board.Height {
+		return fmt.Errorf("install: board %q needs a %dx%d splash, got %dx%d", s.Board.Name, s.Board.Width, s.Board.Height, size.X, size.Y)
+	}
+	return nil
+}
+
+// writeBoardSplash writes the outputs of the board into BoardDir.
+// It returns the written paths in order, or an error if encoding or writing fails.
+func writeBoardSplash(r *root, s BoardSplash) ([]string, error) {
+	dir := filepath.Join(r.dir, filepath.FromSlash(BoardDir))
+	if err := r.mkdirAll(dir); err != nil {
+		return nil, err
+	}
+
+	var written []string
+	if s.Board.Framebuffer != "" {
+		path := filepath.Join(dir, s.Board.Framebuffer)
+		if err := r.writeFile(path, rgb565(s.Image)); err != nil {
+			return nil, fmt.Errorf("install: write framebuffer dump %q: %w", path, err)
+		}
+		written = append(written, path)
+	}
+	if s.Board.BMP != "" {
+		img := s.Image
+		if s.Board.BMPDepth != 24 {
+			// 8-bit BMPs are indexed; dithering against a fixed palette keeps gradients in the photo from banding.
+			paletted := image.NewPaletted(img.Bounds(), palette.Plan9)
+			draw.FloydSteinberg.Draw(paletted, paletted.Rect, img, img.Bounds().Min)
+			img = paletted
+		}
+		path := filepath.Join(dir, s.Board.BMP)
+		if err := writeBMP(r, path, img); err != nil {
+			return nil, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// rgb565 returns the pixels of img as a headerless little-endian RGB565 framebuffer, row by row, as Linux fbdev
+// drivers of 16-bit panels expect it. The dump can be written to the device directly, e.g. `cat > /dev/fb0`.
+func rgb565(img image.Image) []byte {
+	b := img.Bounds()
+	out := make([]byte, 0, 2*b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			// Rounding to the nearest 5- and 6-bit levels instead of truncating keeps the image from darkening.
+			v := uint16((r*31+0x7fff)/0xffff)<<11 | uint16((g*63+0x7fff)/0xffff)<<5 | uint16((bl*31+0x7fff)/0xffff)
+			out = binary.LittleEndian.AppendUint16(out, v)
+		}
+	}
+	return out
+}
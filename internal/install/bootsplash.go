@@ -0,0 +1,111 @@
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/qoi"
+	"golang.org/x/image/bmp"
+)
+
+// BootSplashFormats returns the formats of the static boot splash in a stable order for help output and validation:
+// BMP for bootloaders that only read uncompressed bitmaps, lossless PNG for those with zlib, and QOI, which decodes
+// several times faster than PNG at a similar size.
+func BootSplashFormats() []string {
+	return []string{"bmp", "png", "qoi"}
+}
+
+// validateBootSplashFormat checks that format is one of BootSplashFormats; empty selects bmp.
+func validateBootSplashFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	for _, f := range BootSplashFormats() {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("install: unknown boot splash format %q", format)
+}
+
+// BootSplashAs returns the location of the static boot splash in format, one of BootSplashFormats with empty
+// selecting bmp, or empty if the profile has none.
+func (l Layout) BootSplashAs(format string) string {
+	if l.BootSplash == "" || format == "" {
+		return l.BootSplash
+	}
+	return strings.TrimSuffix(l.BootSplash, path.Ext(l.BootSplash)) + "." + format
+}
+
+// bootSplashNames returns the file names of the static boot splash in each of BootSplashFormats, which board splashes
+// in the same directory must not take.
+func bootSplashNames() []string {
+	var names []string
+	for _, f := range BootSplashFormats() {
+		names = append(names, "splash."+f)
+	}
+	return names
+}
+
+// encodeBootSplash writes img to w in format, one of BootSplashFormats.
+func encodeBootSplash(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case "png":
+		return png.Encode(w, img)
+	case "qoi":
+		return qoi.Encode(w, img)
+	default:
+		return bmp.Encode(w, img)
+	}
+}
+
+// writeBootSplash streams the image in format to the target path and overwrites any existing file.
+func writeBootSplash(r *root, path string, img image.Image, format string) error {
+	if err := r.writeStream(path, func(w io.Writer) error { return encodeBootSplash(w, img, format) }); err != nil {
+		return fmt.Errorf("install: write boot splash %q: %w", path, err)
+	}
+	return nil
+}
+
+// BootSplashCandidate is the result of encoding a boot splash in one of BootSplashFormats.
+type BootSplashCandidate struct {
+	Format string
+	// Size is the encoded size in bytes.
+	Size int
+	// Decode is the fastest of a few decodes on the build host; only the ratios carry over to the target's CPU.
+	Decode time.Duration
+}
+
+// decodeRuns is how often CompareBootSplash decodes each candidate, to skip over a cold cache or a busy build host.
+const decodeRuns = 3
+
+// CompareBootSplash encodes img in each of BootSplashFormats, in that order, and decodes it decodeRuns times. It
+// returns an error if a format fails to encode or decode.
+func CompareBootSplash(img image.Image) ([]BootSplashCandidate, error) {
+	decoders := map[string]func(io.Reader) (image.Image, error){"bmp": bmp.Decode, "png": png.Decode, "qoi": qoi.Decode}
+	var candidates []BootSplashCandidate
+	for _, format := range BootSplashFormats() {
+		var buf bytes.Buffer
+		if err := encodeBootSplash(&buf, img, format); err != nil {
+			return nil, fmt.Errorf("install: encode boot splash as %s: %w", format, err)
+		}
+		c := BootSplashCandidate{Format: format, Size: buf.Len()}
+		for i := 0; i < decodeRuns; i++ {
+			start := time.Now()
+			if _, err := decoders[format](bytes.NewReader(buf.Bytes())); err != nil {
+				return nil, fmt.Errorf("install: decode boot splash as %s: %w", format, err)
+			}
+			if d := time.Since(start); i == 0 || d < c.Decode {
+				c.Decode = d
+			}
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
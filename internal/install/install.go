@@ -1,12 +1,25 @@
 package install
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/nickhildebrandt/ts-release/internal/diag"
+	"github.com/nickhildebrandt/ts-release/internal/icc"
 	"golang.org/x/image/bmp"
 )
 
@@ -15,94 +28,607 @@ const (
 	filePerm = 0o644
 )
 
-// Install writes the generated artifacts into the given rootfs and creates missing target directories.
-// It returns an error for invalid rootfs paths, a nil image, or any write/encode failure.
-func Install(rootFS string, img image.Image, buildID string) error {
-	if rootFS == "" {
-		return fmt.Errorf("install: rootfs path is empty")
+// JPEGQuality is the quality of the JPEG artifacts unless Options.JPEGTargetSize tunes that of the background.
+const JPEGQuality = 92
+
+// Field is a single KEY="value" entry of the release metadata file.
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Options carries optional metadata written alongside the artifacts. Paths below are those of ProfileLinux; other
+// profiles use the locations of their Layout.
+type Options struct {
+	// Profile selects the layout of the target operating system; empty selects ProfileLinux.
+	Profile Profile
+	// Product names the backgrounds subdirectory and the metadata files, e.g. acme for usr/share/backgrounds/acme and
+	// etc/acme.build; empty selects DefaultProduct.
+	Product string
+	// BuildCounter, when set, is written to Layout.BuildCounter once everything else is installed, so a failed install
+	// does not use up the number of a counter build ID.
+	BuildCounter string
+	// PoolPosition, when positive, is written to Layout.PoolPosition once everything else is installed, so a failed
+	// build picks the same round-robin pool entry again.
+	PoolPosition uint64
+	// Metadata fields are written to etc/tssh.release in os-release syntax; none means no file.
+	Metadata []Field
+	// Image is embedded as EXIF/XMP into the JPEG background (and as text chunks into the PNG) when set.
+	Image ImageInfo
+	// PNG additionally writes a lossless usr/share/backgrounds/tssh/background.png.
+	PNG bool
+	// SRGBProfile embeds an sRGB ICC profile into the JPEG and PNG backgrounds so color-managed viewers do not guess.
+	SRGBProfile bool
+	// JPEGTargetSize, when positive, replaces JPEGQuality for the JPEG background with the highest quality at which
+	// it is at most this many bytes, metadata included.
+	JPEGTargetSize int64
+	// BootSplashFormat is the format of the static boot splash, one of BootSplashFormats; empty selects bmp, and
+	// other formats replace the extension of boot/splash.bmp.
+	BootSplashFormat string
+	// Splash additionally writes an animated boot splash theme into Layout.SplashDir when set.
+	Splash *Splash
+	// Slideshow additionally writes time-of-day variants and a GNOME slideshow XML at Layout.Slideshow when set.
+	Slideshow *Slideshow
+	// Span additionally writes a multi-monitor spanning canvas and its per-monitor crops when set.
+	Span *Span
+	// Board additionally writes the raw framebuffer dump and U-Boot BMP of an embedded board into BoardDir when set.
+	Board *BoardSplash
+	// Psplash additionally writes psplash image and color headers into Layout.PsplashDir when set.
+	Psplash *Psplash
+	// Links are rootfs-relative paths, such as usr/share/backgrounds/default.jpg, made symlinks to background.jpg.
+	// Symlinks already in the rootfs resolve inside it; a regular file at a link path is an error.
+	Links []string
+	// Alternatives are Debian alternatives groups the background is registered in and selected for.
+	Alternatives []Alternative
+	// Resolver selects how paths are resolved inside the rootfs; empty selects ResolverAuto.
+	Resolver Resolver
+	// Sync writes each file under a temporary name, flushes it, renames it into place, and flushes its directory, so
+	// a snapshot or power loss right after Install never sees a truncated file.
+	Sync bool
+	// SkipSpaceCheck writes without first checking that the rootfs file system has space for the estimated size of
+	// the artifacts.
+	SkipSpaceCheck bool
+	// Ownership additionally records root ownership of everything written in a fakeroot or mksquashfs database when
+	// set, for builds that do not run as root.
+	Ownership *Ownership
+	// Parallel is the number of images encoded at the same time: the boot splash, the backgrounds, and the spanning and
+	// slideshow images. Zero selects runtime.GOMAXPROCS and 1 encodes one after the other.
+	Parallel int
+	// Backup, when set, saves every file before it is overwritten.
+	Backup *Backup
+	// Report, when set, is called with the files listed in the manifest and their sizes, in manifest order, once the
+	// manifest is written, e.g. to check them against the budget of an OTA payload.
+	Report func(artifacts []Artifact)
+	// ModTime, when set, becomes the modification and access time of everything written and of its parent
+	// directories, e.g. the time of SOURCE_DATE_EPOCH for reproducible builds.
+	ModTime time.Time
+}
+
+// Install writes the generated artifacts into the given rootfs and creates missing target directories. It returns an
+// error for invalid rootfs paths, a nil image, paths that symlinks lead out of the rootfs, or any write/encode failure;
+// a panic while writing, e.g. of an encoder, is returned as a *diag.PanicError.
+func Install(rootFS string, img image.Image, buildID string, opts Options) (err error) {
+	defer diag.Recover("install", &err)
+	if err := checkRootFS(rootFS); err != nil {
+		return err
+	}
+	if img == nil {
+		return fmt.Errorf("install: image is nil")
+	}
+	profile, err := ParseProfile(string(opts.Profile))
+	if err != nil {
+		return err
+	}
+	product, err := ParseProduct(opts.Product)
+	if err != nil {
+		return err
+	}
+	// The boot splash theme, the slideshow XML, and board and psplash splashes are read by Plymouth, GNOME, U-Boot,
+	// and Yocto, which only Linux targets have.
+	if profile != ProfileLinux && (opts.Splash != nil || opts.Slideshow != nil || opts.Board != nil || opts.Psplash != nil) {
+		return fmt.Errorf("install: boot splash animations, slideshows, and board and psplash splashes need the %s profile", ProfileLinux)
+	}
+	if profile != ProfileLinux && opts.BootSplashFormat != "" && opts.BootSplashFormat != "bmp" {
+		return fmt.Errorf("install: boot splash formats need the %s profile", ProfileLinux)
+	}
+	if profile != ProfileLinux && (len(opts.Links) > 0 || len(opts.Alternatives) > 0) {
+		return fmt.Errorf("install: links and alternatives need the %s profile", ProfileLinux)
+	}
+	for _, link := range opts.Links {
+		if err := validateLink(link); err != nil {
+			return err
+		}
+	}
+	for _, alt := range opts.Alternatives {
+		if err := alt.validate(); err != nil {
+			return err
+		}
+	}
+	if err := validateBootSplashFormat(opts.BootSplashFormat); err != nil {
+		return err
+	}
+	if opts.Splash != nil {
+		if err := opts.Splash.validate(); err != nil {
+			return err
+		}
+	}
+	if opts.Slideshow != nil {
+		if err := opts.Slideshow.validate(); err != nil {
+			return err
+		}
+	}
+	if opts.Span != nil {
+		if err := opts.Span.validate(); err != nil {
+			return err
+		}
+	}
+	if opts.Board != nil {
+		if err := opts.Board.validate(); err != nil {
+			return err
+		}
+	}
+	if opts.Psplash != nil {
+		if err := opts.Psplash.validate(); err != nil {
+			return err
+		}
+	}
+	if opts.Ownership != nil {
+		if err := opts.Ownership.validate(); err != nil {
+			return err
+		}
+	}
+	if opts.Backup != nil {
+		if err := opts.Backup.validate(); err != nil {
+			return err
+		}
 	}
 
-	info, err := os.Stat(rootFS)
+	resolver, err := ParseResolver(string(opts.Resolver))
 	if err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("install: rootfs %q does not exist", rootFS)
+		return err
+	}
+
+	r, err := openRoot(rootFS, resolver)
+	if err != nil {
+		return err
+	}
+	r.sync = opts.Sync
+	if opts.Backup != nil {
+		r.backup = newBackup(*opts.Backup)
+	}
+	defer func() {
+		if closeErr := r.close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("install: close rootfs: %w", closeErr)
+		}
+	}()
+	layout := profile.ProductLayout(product)
+	if !opts.SkipSpaceCheck {
+		// Failing here beats a JPEG truncated by a full build tmpfs.
+		if err := checkSpace(rootFS, estimateSize(img, opts, layout)); err != nil {
+			return err
 		}
-		return fmt.Errorf("install: stat rootfs: %w", err)
 	}
-	if !info.IsDir() {
-		return fmt.Errorf("install: rootfs %q is not a directory", rootFS)
+	// local turns a location of the layout into a path on the build host.
+	local := func(rel string) string {
+		return filepath.Join(rootFS, filepath.FromSlash(rel))
 	}
-	if img == nil {
-		return fmt.Errorf("install: image is nil")
+	backgroundDir := local(layout.BackgroundDir)
+	metadataDir := local(layout.MetadataDir)
+	dirs := []string{backgroundDir, metadataDir}
+	if layout.BootSplash != "" {
+		dirs = append(dirs, filepath.Dir(local(layout.BootSplash)))
+	}
+	for _, dir := range dirs {
+		if err := r.mkdirAll(dir); err != nil {
+			return err
+		}
 	}
 
-	bootDir := filepath.Join(rootFS, "boot")
-	backgroundDir := filepath.Join(rootFS, "usr", "share", "backgrounds", "tssh")
-	etcDir := filepath.Join(rootFS, "etc")
+	// Encoding the images takes most of the time of an install, so they are encoded concurrently.
+	var jobs []writeJob
+	if layout.BootSplash != "" {
+		splashPath := local(layout.BootSplashAs(opts.BootSplashFormat))
+		jobs = append(jobs, func() ([]string, error) {
+			return []string{splashPath}, writeBootSplash(r, splashPath, img, opts.BootSplashFormat)
+		})
+	}
 
-	for _, dir := range []string{bootDir, backgroundDir, etcDir} {
-		if err := os.MkdirAll(dir, dirPerm); err != nil {
-			return fmt.Errorf("install: create dir %q: %w", dir, err)
+	jpegPath := local(layout.Background())
+	jobs = append(jobs, func() ([]string, error) {
+		quality := JPEGQuality
+		if opts.JPEGTargetSize > 0 {
+			var err error
+			if quality, err = tuneJPEGQuality(jpegPath, img, opts.Image, opts.SRGBProfile, opts.JPEGTargetSize); err != nil {
+				return nil, err
+			}
 		}
+		return []string{jpegPath}, writeJPEG(r, jpegPath, img, opts.Image, opts.SRGBProfile, quality)
+	})
+
+	if opts.PNG {
+		pngPath := filepath.Join(backgroundDir, "background.png")
+		jobs = append(jobs, func() ([]string, error) {
+			return []string{pngPath}, writePNG(r, pngPath, img, opts.Image, opts.SRGBProfile)
+		})
 	}
 
-	if err := writeBMP(filepath.Join(bootDir, "splash.bmp"), img); err != nil {
+	if opts.Span != nil {
+		jobs = append(jobs, func() ([]string, error) {
+			return writeSpan(r, backgroundDir, *opts.Span, opts.Image, opts.SRGBProfile)
+		})
+	}
+
+	if opts.Slideshow != nil {
+		jobs = append(jobs, func() ([]string, error) {
+			return writeSlideshow(r, layout.Slideshow(), *opts.Slideshow, opts.Image, opts.SRGBProfile)
+		})
+	}
+
+	written, err := runJobs(jobs, opts.Parallel)
+	if err != nil {
 		return err
 	}
 
-	if err := writeJPEG(filepath.Join(backgroundDir, "background.jpg"), img); err != nil {
+	buildPath := local(layout.Build())
+	if err := writeText(r, buildPath, buildID+"\n"); err != nil {
 		return err
 	}
+	written = append(written, buildPath)
+
+	if len(opts.Metadata) > 0 {
+		releasePath := local(layout.Release())
+		if err := writeText(r, releasePath, formatMetadata(opts.Metadata)); err != nil {
+			return err
+		}
+		written = append(written, releasePath)
+	}
+
+	if opts.Splash != nil {
+		brand := opts.Image.Brand
+		if brand == "" {
+			brand = DefaultBrand
+		}
+		paths, err := writeSplash(r, layout, brand, *opts.Splash)
+		if err != nil {
+			return err
+		}
+		written = append(written, paths...)
+	}
 
-	if err := writeText(filepath.Join(etcDir, "tssh.build"), buildID+"\n"); err != nil {
+	if opts.Board != nil {
+		paths, err := writeBoardSplash(r, *opts.Board)
+		if err != nil {
+			return err
+		}
+		written = append(written, paths...)
+	}
+
+	if opts.Psplash != nil {
+		paths, err := writePsplash(r, layout, *opts.Psplash)
+		if err != nil {
+			return err
+		}
+		written = append(written, paths...)
+	}
+
+	settingsPath, err := writeSettings(r, profile, layout, buildID)
+	if err != nil {
+		return err
+	}
+	if settingsPath != "" {
+		written = append(written, settingsPath)
+	}
+
+	artifacts, err := writeManifest(r, layout.Manifest(), written)
+	if err != nil {
 		return err
 	}
+	if opts.Report != nil {
+		opts.Report(artifacts)
+	}
+	owned := append(written, local(layout.Manifest()))
+
+	// Links and alternatives are not listed in the manifest: checking it follows symlinks, and update-alternatives
+	// rewrites its files whenever a package joins the group.
+	for _, link := range opts.Links {
+		linkPath, err := writeLink(r, link, jpegPath)
+		if err != nil {
+			return err
+		}
+		owned = append(owned, linkPath)
+	}
+	for _, alt := range opts.Alternatives {
+		paths, err := writeAlternative(r, alt, path.Join("/", layout.Background()))
+		if err != nil {
+			return err
+		}
+		owned = append(owned, paths...)
+	}
+	// State is written last and is not listed in the manifest: the next build changes it.
+	if opts.BuildCounter != "" {
+		counterPath := local(layout.BuildCounter())
+		if err := r.mkdirAll(filepath.Dir(counterPath)); err != nil {
+			return err
+		}
+		if err := writeState(r, counterPath, opts.BuildCounter+"\n"); err != nil {
+			return err
+		}
+		owned = append(owned, counterPath)
+	}
+	if opts.PoolPosition > 0 {
+		positionPath := local(layout.PoolPosition())
+		if err := r.mkdirAll(filepath.Dir(positionPath)); err != nil {
+			return err
+		}
+		if err := writeState(r, positionPath, strconv.FormatUint(opts.PoolPosition, 10)+"\n"); err != nil {
+			return err
+		}
+		owned = append(owned, positionPath)
+	}
+	if r.backup != nil {
+		owned = append(owned, r.backup.written...)
+	}
 
+	if opts.Ownership != nil {
+		if err := writeOwnership(rootFS, *opts.Ownership, owned); err != nil {
+			return err
+		}
+	}
+	if !opts.ModTime.IsZero() {
+		return writeTimes(r, rootFS, owned, opts.ModTime)
+	}
 	return nil
 }
 
-// writeBMP writes the image as a BMP to the target path and overwrites any existing file.
-// It returns an error if the file cannot be opened/created or the BMP encoding fails.
-func writeBMP(path string, img image.Image) error {
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, filePerm)
+// checkRootFS returns an error if rootFS is empty, missing, or not a directory.
+func checkRootFS(rootFS string) error {
+	if rootFS == "" {
+		return fmt.Errorf("install: rootfs path is empty")
+	}
+	info, err := os.Stat(rootFS)
 	if err != nil {
-		return fmt.Errorf("install: open bmp %q: %w", path, err)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("install: rootfs %q does not exist", rootFS)
+		}
+		return fmt.Errorf("install: stat rootfs: %w", err)
 	}
-	defer file.Close()
+	if !info.IsDir() {
+		return fmt.Errorf("install: rootfs %q is not a directory", rootFS)
+	}
+	return nil
+}
+
+// formatMetadata renders the fields as os-release style KEY="value" lines.
+// Characters with special meaning inside double quotes are backslash-escaped.
+func formatMetadata(fields []Field) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`", "\n", `\n`)
+	var b strings.Builder
+	for _, f := range fields {
+		fmt.Fprintf(&b, "%s=\"%s\"\n", f.Key, escaper.Replace(f.Value))
+	}
+	return b.String()
+}
 
-	if err := bmp.Encode(file, img); err != nil {
-		return fmt.Errorf("install: encode bmp %q: %w", path, err)
+// writeBMP streams the image as a BMP to the target path and overwrites any existing file.
+// It returns an error if the file cannot be opened/created or the BMP encoding or writing fails.
+func writeBMP(r *root, path string, img image.Image) error {
+	if err := r.writeStream(path, func(w io.Writer) error { return bmp.Encode(w, img) }); err != nil {
+		return fmt.Errorf("install: write bmp %q: %w", path, err)
 	}
 	return nil
 }
 
-// writeJPEG writes the image as a JPEG to the target path and overwrites any existing file.
-// It returns an error if opening/writing fails or if the JPEG encoding fails.
-func writeJPEG(path string, img image.Image) error {
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, filePerm)
+// writeJPEG streams the image as a JPEG of the given quality to the target path and overwrites any existing file.
+// Non-empty image info is embedded as EXIF/XMP and srgb adds an sRGB ICC profile; errors are returned if encoding,
+// embedding, or writing fails.
+func writeJPEG(r *root, path string, img image.Image, info ImageInfo, srgb bool, quality int) error {
+	header, err := jpegHeader(path, info, srgb)
 	if err != nil {
-		return fmt.Errorf("install: open jpeg %q: %w", path, err)
+		return err
 	}
-	defer file.Close()
+	err = r.writeStream(path, func(w io.Writer) error { return encodeJPEG(w, header, img, quality) })
+	if err != nil {
+		return fmt.Errorf("install: write jpeg %q: %w", path, err)
+	}
+	return nil
+}
+
+// jpegHeader returns the SOI marker followed by the metadata segments of info and srgb for the JPEG at path.
+func jpegHeader(path string, info ImageInfo, srgb bool) ([]byte, error) {
+	// The metadata segments follow the SOI marker, so they are embedded into a bare one that replaces the encoder's.
+	header := []byte{0xFF, markerSOI}
+	if !info.isZero() {
+		var err error
+		if header, err = embedJPEGMetadata(header, info); err != nil {
+			return nil, err
+		}
+	}
+	if srgb {
+		var err error
+		if header, err = icc.EmbedJPEG(header, icc.SRGB()); err != nil {
+			return nil, fmt.Errorf("install: embed profile in jpeg %q: %w", path, err)
+		}
+	}
+	return header, nil
+}
+
+// encodeJPEG writes header and then the image encoded at quality to w.
+func encodeJPEG(w io.Writer, header []byte, img image.Image, quality int) error {
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	// The encoder starts with its own SOI marker.
+	return jpeg.Encode(&skipWriter{w: w, n: 2}, img, &jpeg.Options{Quality: quality})
+}
 
-	options := &jpeg.Options{Quality: 92}
-	if err := jpeg.Encode(file, img, options); err != nil {
-		return fmt.Errorf("install: encode jpeg %q: %w", path, err)
+// tuneJPEGQuality returns the highest quality at which img, with the metadata of info and srgb, encodes to at most
+// target bytes, found by a binary search over qualities 1 to 100. It returns an error if even quality 1 is larger.
+func tuneJPEGQuality(path string, img image.Image, info ImageInfo, srgb bool, target int64) (int, error) {
+	header, err := jpegHeader(path, info, srgb)
+	if err != nil {
+		return 0, err
+	}
+	// Without a fitting quality, the search ends with quality 1, whose size is the last over the target.
+	best, over, lo, hi := 0, int64(0), 1, 100
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		var w countingWriter
+		if err := encodeJPEG(&w, header, img, mid); err != nil {
+			return 0, fmt.Errorf("install: encode jpeg %q: %w", path, err)
+		}
+		if w.n <= target {
+			best, lo = mid, mid+1
+		} else {
+			over, hi = w.n, mid-1
+		}
+	}
+	if best == 0 {
+		return 0, fmt.Errorf("install: jpeg %q is %d bytes even at quality 1, over the target size of %d", path, over, target)
+	}
+	return best, nil
+}
+
+// countingWriter discards what is written to it and counts the bytes.
+type countingWriter struct {
+	n int64
+}
+
+// Write implements io.Writer.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// skipWriter drops the first n bytes written to it and passes the rest on to w.
+type skipWriter struct {
+	w io.Writer
+	n int
+}
+
+// Write implements io.Writer.
+func (s *skipWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	skip := min(s.n, len(p))
+	s.n -= skip
+	if _, err := s.w.Write(p[skip:]); err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
+// writePNG writes the image as a PNG to the target path and overwrites any existing file.
+// Non-empty image info is embedded as tEXt/iTXt chunks and srgb adds an sRGB ICC profile; errors are returned if
+// encoding, embedding, or writing fails.
+func writePNG(r *root, path string, img image.Image, info ImageInfo, srgb bool) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("install: encode png %q: %w", path, err)
+	}
+
+	data := buf.Bytes()
+	if !info.isZero() {
+		var err error
+		if data, err = embedPNGText(data, pngTextEntries(info)); err != nil {
+			return err
+		}
+	}
+	if srgb {
+		var err error
+		if data, err = icc.EmbedPNG(data, "sRGB", icc.SRGB()); err != nil {
+			return fmt.Errorf("install: embed profile in png %q: %w", path, err)
+		}
+	}
+
+	if err := r.writeFile(path, data); err != nil {
+		return fmt.Errorf("install: write png %q: %w", path, err)
 	}
 	return nil
 }
 
 // writeText writes plain text to a file and overwrites any existing file.
 // It returns an error if the file cannot be created or the write fails.
-func writeText(path string, content string) error {
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, filePerm)
+func writeText(r *root, path string, content string) error {
+	if err := r.writeFile(path, []byte(content)); err != nil {
+		return fmt.Errorf("install: write metadata %q: %w", path, err)
+	}
+	return nil
+}
+
+// writeState writes content to a state file of the layout and overwrites any existing file.
+func writeState(r *root, path string, content string) error {
+	if err := r.writeFile(path, []byte(content)); err != nil {
+		return fmt.Errorf("install: write state %q: %w", path, err)
+	}
+	return nil
+}
+
+// InstalledBuild returns the build ID that the build file of layout in rootFS holds, read through the rootfs with
+// resolver. ok is false if there is no build file, e.g. for a rootfs that was never built into.
+func InstalledBuild(rootFS string, layout Layout, resolver Resolver) (id string, ok bool, err error) {
+	resolver, err = ParseResolver(string(resolver))
 	if err != nil {
-		return fmt.Errorf("install: open metadata %q: %w", path, err)
+		return "", false, err
+	}
+	r, err := openRoot(rootFS, resolver)
+	if err != nil {
+		return "", false, err
+	}
+	defer r.close()
+
+	path := filepath.Join(rootFS, filepath.FromSlash(layout.Build()))
+	file, err := r.open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("install: open build file %q: %w", path, err)
 	}
 	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, 4096))
+	if err != nil {
+		return "", false, fmt.Errorf("install: read build file %q: %w", path, err)
+	}
+	return strings.TrimSuffix(string(data), "\n"), true, nil
+}
 
-	if _, err := file.WriteString(content); err != nil {
-		return fmt.Errorf("install: write metadata %q: %w", path, err)
+// ReadFile reads the regular file at the rootfs-relative, slash-separated path rel in rootFS, resolving symlinks with
+// resolver as Install does, so a link out of the rootfs is an error rather than a read of the build host's file. ok is
+// false if there is no such file.
+func ReadFile(rootFS, rel string, resolver Resolver) (data []byte, ok bool, err error) {
+	resolver, err = ParseResolver(string(resolver))
+	if err != nil {
+		return nil, false, err
 	}
-	return nil
+	r, err := openRoot(rootFS, resolver)
+	if err != nil {
+		return nil, false, err
+	}
+	defer r.close()
+	return r.readFile(rel)
+}
+
+// ReadDir returns the names of the entries of the directory at the rootfs-relative path rel in rootFS in lexical
+// order, resolving symlinks with resolver as ReadFile does. ok is false if there is no such directory.
+func ReadDir(rootFS, rel string, resolver Resolver) (names []string, ok bool, err error) {
+	resolver, err = ParseResolver(string(resolver))
+	if err != nil {
+		return nil, false, err
+	}
+	r, err := openRoot(rootFS, resolver)
+	if err != nil {
+		return nil, false, err
+	}
+	defer r.close()
+	entries, err := r.readDir(rel)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	slices.Sort(names)
+	return names, true, nil
 }
@@ -1,14 +1,29 @@
 package install
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
 	"image"
 	"image/color"
 	"image/jpeg"
+	"image/png"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unicode/utf16"
 
+	"github.com/nickhildebrandt/ts-release/internal/diag"
+	"github.com/nickhildebrandt/ts-release/internal/icc"
+	"github.com/nickhildebrandt/ts-release/internal/qoi"
 	"golang.org/x/image/bmp"
 )
 
@@ -32,7 +47,7 @@ func TestInstall_SucceedsAndWritesExpectedPaths(t *testing.T) {
 	img := sampleImage()
 	buildID := "build-123"
 
-	if err := Install(root, img, buildID); err != nil {
+	if err := Install(root, img, buildID, Options{}); err != nil {
 		t.Fatalf("Install error: %v", err)
 	}
 
@@ -84,7 +99,7 @@ func TestInstall_SucceedsAndWritesExpectedPaths(t *testing.T) {
 // It also checks that the error message reflects the missing-path case.
 func TestInstall_MissingRootFS_Error(t *testing.T) {
 	missing := filepath.Join(t.TempDir(), "does-not-exist")
-	if err := Install(missing, sampleImage(), "b"); err == nil {
+	if err := Install(missing, sampleImage(), "b", Options{}); err == nil {
 		t.Fatalf("expected error")
 	} else if !strings.Contains(err.Error(), "does not exist") {
 		t.Fatalf("unexpected error: %q", err.Error())
@@ -99,7 +114,7 @@ func TestInstall_RootFSIsFile_Error(t *testing.T) {
 	if err := os.WriteFile(p, []byte("x"), 0o644); err != nil {
 		t.Fatalf("write file: %v", err)
 	}
-	if err := Install(p, sampleImage(), "b"); err == nil {
+	if err := Install(p, sampleImage(), "b", Options{}); err == nil {
 		t.Fatalf("expected error")
 	} else if !strings.Contains(err.Error(), "not a directory") {
 		t.Fatalf("unexpected error: %q", err.Error())
@@ -110,7 +125,7 @@ func TestInstall_RootFSIsFile_Error(t *testing.T) {
 // This prevents later panics in the encoder paths.
 func TestInstall_ImageNil_Error(t *testing.T) {
 	root := t.TempDir()
-	if err := Install(root, nil, "b"); err == nil {
+	if err := Install(root, nil, "b", Options{}); err == nil {
 		t.Fatalf("expected error")
 	} else if !strings.Contains(err.Error(), "image is nil") {
 		t.Fatalf("unexpected error: %q", err.Error())
@@ -121,7 +136,7 @@ func TestInstall_ImageNil_Error(t *testing.T) {
 // It expects that exactly a newline is written to the metadata file.
 func TestInstall_EmptyBuildID_CurrentBehavior(t *testing.T) {
 	root := t.TempDir()
-	if err := Install(root, sampleImage(), ""); err != nil {
+	if err := Install(root, sampleImage(), "", Options{}); err != nil {
 		t.Fatalf("expected success with empty buildID, got error: %v", err)
 	}
 	buildPath := filepath.Join(root, "etc", "tssh.build")
@@ -163,7 +178,7 @@ func TestInstall_OverwritesExistingFiles(t *testing.T) {
 		t.Fatalf("write build old: %v", err)
 	}
 
-	if err := Install(root, img, "new-build"); err != nil {
+	if err := Install(root, img, "new-build", Options{}); err != nil {
 		t.Fatalf("Install error: %v", err)
 	}
 
@@ -206,8 +221,1361 @@ func TestInstall_ReadOnlyRootFS_Error(t *testing.T) {
 	}
 	t.Cleanup(func() { _ = os.Chmod(root, 0o755) })
 
-	err := Install(root, sampleImage(), "b")
+	err := Install(root, sampleImage(), "b", Options{})
 	if err == nil {
 		t.Fatalf("expected error on read-only rootfs")
 	}
 }
+
+// TestInstall_WritesReleaseMetadata expects metadata fields in etc/tssh.release with os-release quoting.
+// It also checks that no release file is written when no fields are given.
+func TestInstall_WritesReleaseMetadata(t *testing.T) {
+	root := t.TempDir()
+	releasePath := filepath.Join(root, "etc", "tssh.release")
+
+	if err := Install(root, sampleImage(), "b", Options{}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	if _, err := os.Stat(releasePath); !os.IsNotExist(err) {
+		t.Fatalf("expected no release file without metadata, got err=%v", err)
+	}
+
+	fields := []Field{
+		{Key: "TSSH_TARGET", Value: "my target"},
+		{Key: "TSSH_NOTE", Value: `say "$hi"`},
+	}
+	if err := Install(root, sampleImage(), "b", Options{Metadata: fields}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	data, err := os.ReadFile(releasePath)
+	if err != nil {
+		t.Fatalf("read release file: %v", err)
+	}
+	want := "TSSH_TARGET=\"my target\"\nTSSH_NOTE=\"say \\\"\\$hi\\\"\"\n"
+	if string(data) != want {
+		t.Fatalf("unexpected release file content %q want %q", string(data), want)
+	}
+}
+
+// TestInstall_EmbedsJPEGMetadata expects EXIF and XMP segments with the build fields directly after SOI in background.jpg.
+// The test fails if the segments are missing, misplaced, or the JPEG no longer decodes.
+func TestInstall_EmbedsJPEGMetadata(t *testing.T) {
+	root := t.TempDir()
+	info := ImageInfo{
+		TargetName:       "my-target",
+		BuildID:          "2026-01-04",
+		GeneratorVersion: "1.2.3",
+		SourceURL:        "https://w.wallhaven.cc/full/ab/wallhaven-abc.jpg",
+	}
+	if err := Install(root, sampleImage(), "b", Options{Image: info}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+
+	jpgPath := filepath.Join(root, "usr", "share", "backgrounds", "tssh", "background.jpg")
+	data, err := os.ReadFile(jpgPath)
+	if err != nil {
+		t.Fatalf("read jpg: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF, 0xE1}) || !bytes.Equal(data[6:12], []byte("Exif\x00\x00")) {
+		t.Fatalf("expected EXIF APP1 segment directly after SOI, got % x", data[:16])
+	}
+	for _, want := range []string{
+		"TSSH my-target build 2026-01-04",
+		"ts-release 1.2.3",
+		info.SourceURL,
+		"http://ns.adobe.com/xap/1.0/",
+		`tssh:BuildID="2026-01-04"`,
+	} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Fatalf("expected %q in jpeg metadata", want)
+		}
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("decode jpg: %v", err)
+	}
+}
+
+// TestInstall_PNG_WritesDecodableFileWithText expects background.png only when enabled, with tEXt chunks after IHDR.
+// The test fails if the PNG is missing, undecodable, or lacks the build metadata.
+func TestInstall_PNG_WritesDecodableFileWithText(t *testing.T) {
+	root := t.TempDir()
+	pngPath := filepath.Join(root, "usr", "share", "backgrounds", "tssh", "background.png")
+
+	if err := Install(root, sampleImage(), "b", Options{}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	if _, err := os.Stat(pngPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no png without option, got err=%v", err)
+	}
+
+	info := ImageInfo{TargetName: "my-target", BuildID: "b-1", GeneratorVersion: "1.2.3"}
+	if err := Install(root, sampleImage(), "b", Options{PNG: true, Image: info}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	data, err := os.ReadFile(pngPath)
+	if err != nil {
+		t.Fatalf("read png: %v", err)
+	}
+	if got := string(data[33+4 : 33+8]); got != "tEXt" {
+		t.Fatalf("expected tEXt chunk after IHDR, got %q", got)
+	}
+	if !bytes.Contains(data, []byte("tssh:BuildID\x00b-1")) {
+		t.Fatalf("expected build ID text chunk")
+	}
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+}
+
+// TestInstall_SRGBProfile_EmbedsICC expects the sRGB profile in both backgrounds only when enabled.
+// The test fails if the profile is missing, altered, or breaks decoding.
+func TestInstall_SRGBProfile_EmbedsICC(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "usr", "share", "backgrounds", "tssh")
+	if err := Install(root, sampleImage(), "b", Options{PNG: true}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	for _, name := range []string{"background.jpg", "background.png"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if profile, err := icc.Extract(data); profile != nil || err != nil {
+			t.Fatalf("%s: expected no profile without option, got %d bytes, %v", name, len(profile), err)
+		}
+	}
+
+	if err := Install(root, sampleImage(), "b", Options{PNG: true, SRGBProfile: true, Image: ImageInfo{BuildID: "b-1"}}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	for _, name := range []string{"background.jpg", "background.png"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if profile, err := icc.Extract(data); err != nil || !bytes.Equal(profile, icc.SRGB()) {
+			t.Fatalf("%s: expected embedded sRGB profile, got %d bytes, %v", name, len(profile), err)
+		}
+		if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+	}
+}
+
+// TestInstall_Splash_WritesFramesAndTheme expects numbered frames, a Plymouth theme, and manifest entries for a splash.
+// The test fails if a file is missing, the script does not reference the frames, or an unknown format is accepted.
+func TestInstall_Splash_WritesFramesAndTheme(t *testing.T) {
+	root := t.TempDir()
+	splash := &Splash{
+		Format:    "png",
+		FPS:       25,
+		LoopStart: 1,
+		Render: func(emit func(image.Image) error) error {
+			for i := 0; i < 3; i++ {
+				if err := emit(sampleImage()); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	if err := Install(root, sampleImage(), "b", Options{Splash: splash}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+
+	dir := filepath.Join(root, filepath.FromSlash(SplashDir))
+	for _, name := range []string{"frame-0000.png", "frame-0001.png", "frame-0002.png"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+	}
+	theme, err := os.ReadFile(filepath.Join(dir, "tssh.plymouth"))
+	if err != nil || !strings.Contains(string(theme), "ModuleName=script") {
+		t.Fatalf("expected script theme, got %q, %v", theme, err)
+	}
+	script, err := os.ReadFile(filepath.Join(dir, "tssh.script"))
+	if err != nil {
+		t.Fatalf("read script: %v", err)
+	}
+	for _, want := range []string{`Image("frame-0002.png")`, "frame_count = 3;", "loop_start = 1;", "fps = 25;"} {
+		if !strings.Contains(string(script), want) {
+			t.Fatalf("script missing %q:\n%s", want, script)
+		}
+	}
+	manifest, err := os.ReadFile(filepath.Join(root, ManifestPath))
+	if err != nil || !strings.Contains(string(manifest), SplashDir+"/tssh.script") {
+		t.Fatalf("expected splash files in manifest, got %q, %v", manifest, err)
+	}
+
+	splash.Format = "gif"
+	if err := Install(t.TempDir(), sampleImage(), "b", Options{Splash: splash}); err == nil || !strings.Contains(err.Error(), "splash format") {
+		t.Fatalf("expected splash format error, got %v", err)
+	}
+}
+
+// TestInstall_Splash_QOIFrames expects the qoi splash format to write frames that decode to the rendered pixels and a
+// script that names them. The test fails if a frame is missing, decodes differently, or the script names PNG frames.
+func TestInstall_Splash_QOIFrames(t *testing.T) {
+	root := t.TempDir()
+	splash := &Splash{
+		Format: "qoi",
+		FPS:    25,
+		Render: func(emit func(image.Image) error) error {
+			for i := 0; i < 2; i++ {
+				if err := emit(sampleImage()); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	if err := Install(root, sampleImage(), "b", Options{Splash: splash}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	dir := filepath.Join(root, filepath.FromSlash(SplashDir))
+	for _, name := range []string{"frame-0000.qoi", "frame-0001.qoi"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		frame, err := qoi.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+		want := sampleImage()
+		if w, g := color.NRGBAModel.Convert(want.At(1, 0)), frame.At(1, 0); w != g {
+			t.Fatalf("%s: pixel = %v, want %v", name, g, w)
+		}
+	}
+	script, err := os.ReadFile(filepath.Join(dir, "tssh.script"))
+	if err != nil || !strings.Contains(string(script), `Image("frame-0001.qoi")`) {
+		t.Fatalf("expected the script to name the qoi frames, got %v:\n%s", err, script)
+	}
+}
+
+// TestInstall_Splash_ProgressBar expects a splash with a progress bar region to write the bar images and a script that
+// scales the region with the frames. The test fails if an image is missing or misplaced, the script does not draw the
+// boot progress, or a region outside the frames is accepted.
+func TestInstall_Splash_ProgressBar(t *testing.T) {
+	root := t.TempDir()
+	bar := color.NRGBA{R: 46, G: 160, B: 67, A: 255}
+	splash := &Splash{
+		Format: "png",
+		FPS:    25,
+		Render: func(emit func(image.Image) error) error {
+			return emit(sampleImage())
+		},
+		Progress: &SplashProgress{Rect: image.Rect(1, 1, 3, 2), Bar: bar, Background: color.NRGBA{A: 255}},
+	}
+	if err := Install(root, sampleImage(), "b", Options{Splash: splash}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+
+	dir := filepath.Join(root, filepath.FromSlash(SplashDir))
+	data, err := os.ReadFile(filepath.Join(dir, "progress-bar.png"))
+	if err != nil {
+		t.Fatalf("read progress-bar.png: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("decode progress-bar.png: %v", err)
+	}
+	if img.Bounds().Size() != image.Pt(2, 1) || color.NRGBAModel.Convert(img.At(0, 0)) != bar {
+		t.Fatalf("progress-bar.png is %v in %v, want 2x1 in %v", img.Bounds(), img.At(0, 0), bar)
+	}
+	script, err := os.ReadFile(filepath.Join(dir, "tssh.script"))
+	if err != nil {
+		t.Fatalf("read script: %v", err)
+	}
+	size := sampleImage().Bounds().Size()
+	for _, want := range []string{
+		fmt.Sprintf("scale_x = Window.GetWidth() / %d;", size.X),
+		"progress_x = 1 * scale_x;", "progress_y = 1 * scale_y;", "progress_width = 2 * scale_x;",
+		`Image("progress-track.png")`, "Plymouth.SetBootProgressFunction(progress_callback);",
+	} {
+		if !strings.Contains(string(script), want) {
+			t.Fatalf("script missing %q:\n%s", want, script)
+		}
+	}
+	manifest, err := os.ReadFile(filepath.Join(root, ManifestPath))
+	if err != nil || !strings.Contains(string(manifest), SplashDir+"/progress-track.png") {
+		t.Fatalf("expected progress bar images in manifest, got %q, %v", manifest, err)
+	}
+
+	splash.Progress.Rect = image.Rect(1, 1, 3, size.Y+1)
+	if err := Install(t.TempDir(), sampleImage(), "b", Options{Splash: splash}); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Fatalf("expected a progress bar outside the frames to be rejected, got %v", err)
+	}
+}
+
+// TestInstall_Slideshow_WritesVariantsAndXML expects one JPEG per slide and a GNOME slideshow XML whose durations
+// cover 24 hours and whose file references point to the installed variants.
+// The test fails if a variant is missing, the XML is malformed or mistimed, or invalid slideshows are accepted.
+func TestInstall_Slideshow_WritesVariantsAndXML(t *testing.T) {
+	root := t.TempDir()
+	slideshow := &Slideshow{
+		Slides: []Slide{
+			{Name: "day", Hour: 8, Image: sampleImage()},
+			{Name: "night", Hour: 20, Image: sampleImage()},
+		},
+		Transition: time.Hour,
+	}
+	if err := Install(root, sampleImage(), "b", Options{Slideshow: slideshow}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+
+	dir := filepath.Join(root, "usr", "share", "backgrounds", "tssh")
+	for _, name := range []string{"background-day.jpg", "background-night.jpg"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(SlideshowPath)))
+	if err != nil {
+		t.Fatalf("read slideshow: %v", err)
+	}
+	var doc struct {
+		Hour   int `xml:"starttime>hour"`
+		Static []struct {
+			Duration float64 `xml:"duration"`
+			File     string  `xml:"file"`
+		} `xml:"static"`
+		Transition []struct {
+			Duration float64 `xml:"duration"`
+			From     string  `xml:"from"`
+			To       string  `xml:"to"`
+		} `xml:"transition"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("parse slideshow: %v\n%s", err, data)
+	}
+	if doc.Hour != 8 || len(doc.Static) != 2 || len(doc.Transition) != 2 {
+		t.Fatalf("unexpected slideshow structure:\n%s", data)
+	}
+	total := 0.0
+	for i := range doc.Static {
+		total += doc.Static[i].Duration + doc.Transition[i].Duration
+	}
+	if total != 24*3600 || doc.Static[0].Duration != 11*3600 {
+		t.Fatalf("durations do not cover the day (total %v, first static %v)", total, doc.Static[0].Duration)
+	}
+	want := "/usr/share/backgrounds/tssh/background-night.jpg"
+	if doc.Static[1].File != want || doc.Transition[0].To != want || doc.Transition[1].To != doc.Static[0].File {
+		t.Fatalf("unexpected file references:\n%s", data)
+	}
+	manifest, err := os.ReadFile(filepath.Join(root, ManifestPath))
+	if err != nil || !strings.Contains(string(manifest), SlideshowPath) {
+		t.Fatalf("expected slideshow in manifest, got %q, %v", manifest, err)
+	}
+
+	for _, bad := range []Slideshow{
+		{Slides: slideshow.Slides[:1]},
+		{Slides: []Slide{slideshow.Slides[1], slideshow.Slides[0]}},
+		{Slides: []Slide{slideshow.Slides[0], {Name: "Day 2", Hour: 9, Image: sampleImage()}}},
+		{Slides: slideshow.Slides, Transition: 12 * time.Hour},
+	} {
+		if err := Install(t.TempDir(), sampleImage(), "b", Options{Slideshow: &bad}); err == nil {
+			t.Fatalf("expected error for slideshow %+v", bad)
+		}
+	}
+}
+
+// TestInstall_Span_WritesCanvasAndMonitorCrops expects the spanning canvas and one JPEG per monitor with matching
+// sizes, listed in the manifest.
+// The test fails if an output is missing or mis-sized, or a span without monitors is accepted.
+func TestInstall_Span_WritesCanvasAndMonitorCrops(t *testing.T) {
+	root := t.TempDir()
+	canvas := image.NewRGBA(image.Rect(0, 0, 6, 2))
+	span := &Span{Image: canvas, Monitors: []image.Image{canvas.SubImage(image.Rect(0, 0, 2, 2)), canvas.SubImage(image.Rect(2, 0, 6, 2))}}
+	if err := Install(root, sampleImage(), "b", Options{Span: span}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+
+	dir := filepath.Join(root, "usr", "share", "backgrounds", "tssh")
+	for name, want := range map[string]image.Point{
+		"background-span.jpg":      {6, 2},
+		"background-monitor-1.jpg": {2, 2},
+		"background-monitor-2.jpg": {4, 2},
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+		if img.Bounds().Size() != want {
+			t.Fatalf("%s: got size %v want %v", name, img.Bounds().Size(), want)
+		}
+	}
+	manifest, err := os.ReadFile(filepath.Join(root, ManifestPath))
+	if err != nil || !strings.Contains(string(manifest), "background-monitor-2.jpg") {
+		t.Fatalf("expected monitor crops in manifest, got %q, %v", manifest, err)
+	}
+
+	if err := Install(t.TempDir(), sampleImage(), "b", Options{Span: &Span{Image: canvas}}); err == nil {
+		t.Fatalf("expected error for span without monitors")
+	}
+}
+
+// TestInstall_WritesChecksumManifest expects a sha256sum-style manifest listing every written artifact.
+// The test fails if an artifact is missing from the manifest or its digest does not match.
+func TestInstall_WritesChecksumManifest(t *testing.T) {
+	root := t.TempDir()
+	if err := Install(root, sampleImage(), "b", Options{PNG: true, Metadata: []Field{{Key: "K", Value: "v"}}}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, ManifestPath))
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	wantFiles := []string{
+		"boot/splash.bmp",
+		"usr/share/backgrounds/tssh/background.jpg",
+		"usr/share/backgrounds/tssh/background.png",
+		"etc/tssh.build",
+		"etc/tssh.release",
+	}
+	if len(lines) != len(wantFiles) {
+		t.Fatalf("expected %d manifest lines, got %d:\n%s", len(wantFiles), len(lines), data)
+	}
+	for i, want := range wantFiles {
+		sum, name, ok := strings.Cut(lines[i], "  ")
+		if !ok || name != want {
+			t.Fatalf("line %d: got %q want file %q", i, lines[i], want)
+		}
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		digest := sha256.Sum256(content)
+		if sum != hex.EncodeToString(digest[:]) {
+			t.Fatalf("%s: digest mismatch", name)
+		}
+	}
+}
+
+// TestInstall_BootSplashFormat_WritesAndCompares expects each of BootSplashFormats to be written as boot/splash.<format>
+// with the image's pixels, listed in the manifest, CompareBootSplash to cover every format, and unknown formats, other
+// profiles, and board files named like the boot splash to be rejected. The test fails if a file, pixel, or candidate
+// differs, or an invalid setup is accepted.
+func TestInstall_BootSplashFormat_WritesAndCompares(t *testing.T) {
+	for _, format := range BootSplashFormats() {
+		root := t.TempDir()
+		if err := Install(root, sampleImage(), "b", Options{BootSplashFormat: format}); err != nil {
+			t.Fatalf("%s: Install error: %v", format, err)
+		}
+		splash, err := os.Open(filepath.Join(root, "boot", "splash."+format))
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		got, name, err := image.Decode(splash)
+		splash.Close()
+		if err != nil || name != format {
+			t.Fatalf("%s: decoded as %q: %v", format, name, err)
+		}
+		want := sampleImage()
+		if w, g := color.NRGBAModel.Convert(want.At(2, 1)), color.NRGBAModel.Convert(got.At(2, 1)); w != g {
+			t.Fatalf("%s: pixel = %v, want %v", format, g, w)
+		}
+		manifest, err := os.ReadFile(filepath.Join(root, ManifestPath))
+		if err != nil || !strings.Contains(string(manifest), "  boot/splash."+format+"\n") {
+			t.Fatalf("%s: manifest lacks the boot splash: %v\n%s", format, err, manifest)
+		}
+		if format != "bmp" {
+			if _, err := os.Stat(filepath.Join(root, "boot", "splash.bmp")); !os.IsNotExist(err) {
+				t.Fatalf("%s: also wrote splash.bmp: %v", format, err)
+			}
+		}
+	}
+
+	candidates, err := CompareBootSplash(sampleImage())
+	if err != nil {
+		t.Fatalf("CompareBootSplash error: %v", err)
+	}
+	if len(candidates) != len(BootSplashFormats()) {
+		t.Fatalf("got %d candidates", len(candidates))
+	}
+	for i, c := range candidates {
+		if c.Format != BootSplashFormats()[i] || c.Size <= 0 {
+			t.Fatalf("candidate %d = %+v", i, c)
+		}
+	}
+
+	for want, opts := range map[string]Options{
+		"unknown boot splash format": {BootSplashFormat: "webp"},
+		"need the linux profile":     {BootSplashFormat: "qoi", Profile: ProfileWindows},
+		"invalid file name":          {Board: &BoardSplash{Board: Board{Name: "b", Width: 3, Height: 2, BMP: "splash.qoi"}, Image: sampleImage()}},
+	} {
+		if err := Install(t.TempDir(), sampleImage(), "b", opts); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected an error with %q, got %v", want, err)
+		}
+	}
+}
+
+// TestInstall_Parallel_MatchesSequential expects installs that encode the images concurrently to write the same
+// files and manifest as one that encodes them one after the other, and runJobs to keep job order, run at most the
+// given number of jobs at a time, return the first error in job order, start no job after a failure, and fail a job
+// that panics. The test fails if a file, the order, the bound, or the error differs, a job starts after a failure, or a
+// panic of a job is not returned as an error.
+func TestInstall_Parallel_MatchesSequential(t *testing.T) {
+	install := func(parallel int) string {
+		root := t.TempDir()
+		opts := Options{PNG: true, Parallel: parallel, Metadata: []Field{{Key: "K", Value: "v"}}, Slideshow: &Slideshow{Slides: []Slide{
+			{Name: "day", Hour: 6, Image: sampleImage()},
+			{Name: "night", Hour: 18, Image: sampleImage()},
+		}}}
+		if err := Install(root, sampleImage(), "b", opts); err != nil {
+			t.Fatalf("Install with %d parallel encodes: %v", parallel, err)
+		}
+		manifest, err := os.ReadFile(filepath.Join(root, ManifestPath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(manifest)
+	}
+	if sequential, parallel := install(1), install(8); sequential != parallel {
+		t.Fatalf("manifests differ:\n%s\n%s", sequential, parallel)
+	}
+
+	var running, peak atomic.Int32
+	jobs := make([]writeJob, 12)
+	for i := range jobs {
+		jobs[i] = func() ([]string, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(time.Millisecond)
+			return []string{fmt.Sprint(i)}, nil
+		}
+	}
+	paths, err := runJobs(jobs, 3)
+	if err != nil || strings.Join(paths, ",") != "0,1,2,3,4,5,6,7,8,9,10,11" || peak.Load() > 3 {
+		t.Fatalf("runJobs = %v, %v with %d at a time", paths, err, peak.Load())
+	}
+	errFirst, errSecond := errors.New("first"), errors.New("second")
+	jobs = []writeJob{
+		func() ([]string, error) { time.Sleep(5 * time.Millisecond); return nil, errFirst },
+		func() ([]string, error) { return nil, errSecond },
+	}
+	if _, err := runJobs(jobs, 2); err != errFirst {
+		t.Fatalf("expected the error of the first job, got %v", err)
+	}
+	var started atomic.Int32
+	jobs = []writeJob{func() ([]string, error) { return nil, errFirst }, func() ([]string, error) { time.Sleep(10 * time.Millisecond); return nil, nil }}
+	for range 8 {
+		jobs = append(jobs, func() ([]string, error) { started.Add(1); return nil, nil })
+	}
+	if _, err := runJobs(jobs, 2); err != errFirst || started.Load() != 0 {
+		t.Fatalf("expected no job to start after a failure, got %v with %d started", err, started.Load())
+	}
+	var panicErr *diag.PanicError
+	_, err = runJobs([]writeJob{func() ([]string, error) { panic("encoder broke") }}, 2)
+	if !errors.As(err, &panicErr) || err.Error() != "install: encode: internal error: encoder broke" || len(panicErr.Stack) == 0 {
+		t.Fatalf("expected the panic of a job as an error with its stack, got %v", err)
+	}
+}
+
+// TestInstall_Report_ListsArtifactSizes expects Report to receive every manifest entry in order with the size of the
+// file on disk, and the streamed JPEG and BMP to decode with metadata intact. The test fails if an entry, a size, or
+// a decoded image differs.
+func TestInstall_Report_ListsArtifactSizes(t *testing.T) {
+	root := t.TempDir()
+	var artifacts []Artifact
+	opts := Options{SRGBProfile: true, Metadata: []Field{{Key: "K", Value: "v"}}, Report: func(a []Artifact) { artifacts = a }}
+	if err := Install(root, sampleImage(), "b", opts); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	manifest, err := os.ReadFile(filepath.Join(root, ManifestPath))
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(manifest)), "\n")
+	if len(artifacts) != len(lines) {
+		t.Fatalf("got %d artifacts for %d manifest lines", len(artifacts), len(lines))
+	}
+	for i, a := range artifacts {
+		if _, name, _ := strings.Cut(lines[i], "  "); name != a.Path {
+			t.Fatalf("artifact %d: got %q want %q", i, a.Path, name)
+		}
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(a.Path)))
+		if err != nil || info.Size() != a.Size {
+			t.Fatalf("%s: reported %d bytes, file has %v (%v)", a.Path, a.Size, info.Size(), err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "usr", "share", "backgrounds", "tssh", "background.jpg"))
+	if err != nil {
+		t.Fatalf("read jpeg: %v", err)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("decode jpeg: %v", err)
+	}
+	if !bytes.Equal(data[:2], []byte{0xff, 0xd8}) || bytes.Count(data, []byte{0xff, 0xd8}) != 1 || !bytes.Contains(data, []byte("ICC_PROFILE")) {
+		t.Fatalf("expected a single SOI marker and an ICC profile in the jpeg")
+	}
+	splash, err := os.Open(filepath.Join(root, "boot", "splash.bmp"))
+	if err != nil {
+		t.Fatalf("open bmp: %v", err)
+	}
+	defer splash.Close()
+	if _, err := bmp.Decode(splash); err != nil {
+		t.Fatalf("decode bmp: %v", err)
+	}
+}
+
+// TestInstall_JPEGTargetSize_TunesQuality expects JPEGTargetSize to write the background at the highest quality that
+// keeps it within the target, metadata included, and a target that even quality 1 exceeds to be rejected. The test
+// fails if the file is larger than the target, a higher quality would also fit, or the small target is accepted.
+func TestInstall_JPEGTargetSize_TunesQuality(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7919 % 251)
+	}
+	info := ImageInfo{TargetName: "t", BuildID: "b"}
+	header, err := jpegHeader("background.jpg", info, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := func(quality int) int64 {
+		var w countingWriter
+		if err := encodeJPEG(&w, header, img, quality); err != nil {
+			t.Fatal(err)
+		}
+		return w.n
+	}
+	target := (size(40) + size(41)) / 2
+
+	root := t.TempDir()
+	if err := Install(root, img, "b", Options{Image: info, SRGBProfile: true, JPEGTargetSize: target}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "usr", "share", "backgrounds", "tssh", "background.jpg"))
+	if err != nil {
+		t.Fatalf("read jpeg: %v", err)
+	}
+	if int64(len(data)) > target || int64(len(data)) != size(40) {
+		t.Fatalf("got %d bytes for a target of %d, want the %d of quality 40", len(data), target, size(40))
+	}
+
+	err = Install(t.TempDir(), img, "b", Options{Image: info, JPEGTargetSize: 100})
+	if err == nil || !strings.Contains(err.Error(), "even at quality 1") {
+		t.Fatalf("expected a target below the size at quality 1 to be rejected, got %v", err)
+	}
+}
+
+// TestInstall_Profiles_WriteWindowsAndMacOSLayouts expects the windows and macos profiles to place the artifacts in
+// their layout, write a UTF-16LE registry fragment and a configuration profile that point at the installed
+// background, list them in the profile's manifest, and reject the Linux-only boot splash. The test fails if a path,
+// the encoding, or a setting differs.
+func TestInstall_Profiles_WriteWindowsAndMacOSLayouts(t *testing.T) {
+	root := t.TempDir()
+	if err := Install(root, sampleImage(), "b", Options{Profile: ProfileWindows, Metadata: []Field{{Key: "K", Value: "v"}}}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "boot")); !os.IsNotExist(err) {
+		t.Fatalf("windows profile wrote a boot splash: %v", err)
+	}
+	reg, err := os.ReadFile(filepath.Join(root, "ProgramData", "TSSH", "wallpaper.reg"))
+	if err != nil {
+		t.Fatalf("read registry fragment: %v", err)
+	}
+	if !bytes.HasPrefix(reg, []byte{0xff, 0xfe}) || len(reg)%2 != 0 {
+		t.Fatalf("registry fragment is not UTF-16LE with a byte order mark: % x", reg[:4])
+	}
+	units := make([]uint16, 0, len(reg)/2-1)
+	for i := 2; i < len(reg); i += 2 {
+		units = append(units, uint16(reg[i])|uint16(reg[i+1])<<8)
+	}
+	text := string(utf16.Decode(units))
+	want := `"DesktopImagePath"="C:\\Windows\\Web\\Wallpaper\\TSSH\\background.jpg"` + "\r\n"
+	if !strings.HasPrefix(text, "Windows Registry Editor Version 5.00\r\n") || !strings.Contains(text, want) {
+		t.Fatalf("registry fragment = %q", text)
+	}
+	manifest, err := os.ReadFile(filepath.Join(root, "ProgramData", "TSSH", "tssh.manifest"))
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	for _, name := range []string{"Windows/Web/Wallpaper/TSSH/background.jpg", "ProgramData/TSSH/tssh.release", "ProgramData/TSSH/wallpaper.reg"} {
+		if !strings.Contains(string(manifest), "  "+name+"\n") {
+			t.Fatalf("manifest lacks %s:\n%s", name, manifest)
+		}
+	}
+	if p, err := DetectProfile(root); err != nil || p != ProfileWindows {
+		t.Fatalf("DetectProfile = %q, %v", p, err)
+	}
+
+	root = t.TempDir()
+	if err := Install(root, sampleImage(), "b&1", Options{Profile: ProfileMacOS}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "Library", "Desktop Pictures", "TSSH", "background.jpg")); err != nil {
+		t.Fatalf("background: %v", err)
+	}
+	config, err := os.ReadFile(filepath.Join(root, "Library", "Application Support", "TSSH", "desktop.mobileconfig"))
+	if err != nil {
+		t.Fatalf("read configuration profile: %v", err)
+	}
+	if err := xml.Unmarshal(config, new(struct{})); err != nil {
+		t.Fatalf("configuration profile is not XML: %v", err)
+	}
+	for _, want := range []string{"<string>/Library/Desktop Pictures/TSSH/background.jpg</string>", "<string>Build b&amp;1</string>"} {
+		if !strings.Contains(string(config), want) {
+			t.Fatalf("expected %q in configuration profile:\n%s", want, config)
+		}
+	}
+
+	err = Install(t.TempDir(), sampleImage(), "b", Options{Profile: ProfileMacOS, Slideshow: &Slideshow{}})
+	if err == nil {
+		t.Fatal("expected the macos profile to reject a slideshow")
+	}
+	if _, err := ParseProfile("bsd"); err == nil {
+		t.Fatal("expected an unknown profile to be rejected")
+	}
+}
+
+// TestInstall_Board_WritesFramebufferDumpAndBMP expects a board splash to be written as a little-endian RGB565 dump
+// and an 8-bit BMP at the panel resolution, listed in the manifest, and boards with another image size or file names
+// outside boot/ to be rejected. The test fails if a size, pixel, or path differs.
+func TestInstall_Board_WritesFramebufferDumpAndBMP(t *testing.T) {
+	root := t.TempDir()
+	board := Board{Name: "panel", Width: 3, Height: 2, Framebuffer: "splash.rgb565", BMP: "splash-uboot.bmp"}
+	if err := Install(root, sampleImage(), "b", Options{Board: &BoardSplash{Board: board, Image: sampleImage()}}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	raw, err := os.ReadFile(filepath.Join(root, "boot", "splash.rgb565"))
+	if err != nil {
+		t.Fatalf("read framebuffer dump: %v", err)
+	}
+	// Red, green, and blue at full intensity become the top 5, middle 6, and low 5 bits.
+	want := []byte{0x00, 0xf8, 0xe0, 0x07, 0x1f, 0x00}
+	if len(raw) != 2*3*2 || !bytes.Equal(raw[:6], want) {
+		t.Fatalf("framebuffer dump = % x, want first row % x", raw, want)
+	}
+	f, err := os.Open(filepath.Join(root, "boot", "splash-uboot.bmp"))
+	if err != nil {
+		t.Fatalf("open bmp: %v", err)
+	}
+	defer f.Close()
+	cfg, err := bmp.DecodeConfig(f)
+	if err != nil || cfg.Width != 3 || cfg.Height != 2 {
+		t.Fatalf("bmp config = %+v, %v", cfg, err)
+	}
+	if _, paletted := cfg.ColorModel.(color.Palette); !paletted {
+		t.Fatalf("expected an 8-bit indexed bmp, got %T", cfg.ColorModel)
+	}
+	manifest, err := os.ReadFile(filepath.Join(root, ManifestPath))
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	for _, name := range []string{"boot/splash.rgb565", "boot/splash-uboot.bmp"} {
+		if !strings.Contains(string(manifest), "  "+name+"\n") {
+			t.Fatalf("manifest lacks %s:\n%s", name, manifest)
+		}
+	}
+
+	large := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	if err := Install(t.TempDir(), sampleImage(), "b", Options{Board: &BoardSplash{Board: board, Image: large}}); err == nil {
+		t.Fatal("expected a splash of the wrong size to be rejected")
+	}
+	board.BMP = "../etc/splash.bmp"
+	if err := Install(t.TempDir(), sampleImage(), "b", Options{Board: &BoardSplash{Board: board, Image: sampleImage()}}); err == nil {
+		t.Fatal("expected a file name outside boot/ to be rejected")
+	}
+	if _, err := ParseBoard("rpi-touch"); err != nil {
+		t.Fatalf("ParseBoard: %v", err)
+	}
+}
+
+// TestInstall_Psplash_WritesImageAndColorHeaders expects psplash-poky-img.h to hold the image run-length encoded under
+// the Poky macro names, psplash-colors.h the given colors, and both to be listed in the manifest. The test fails if the
+// encoding does not decode to the image or a macro differs.
+func TestInstall_Psplash_WritesImageAndColorHeaders(t *testing.T) {
+	// Two rows of four pixels: a run of three, then literals, so both kinds of runs are encoded.
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for i, c := range []color.NRGBA{{1, 2, 3, 255}, {1, 2, 3, 255}, {1, 2, 3, 255}, {'"', '?', '\\', 255}, {'7', 0, 9, 255}, {4, 5, 6, 255}, {7, 8, 9, 255}, {7, 8, 9, 128}} {
+		img.SetNRGBA(i%4, i/4, c)
+	}
+	data := rleRGBA(img)
+	var decoded []byte
+	for p := 0; p < len(data); {
+		n := int(data[p])
+		p++
+		if n >= 128 {
+			for range n - 128 {
+				decoded = append(decoded, data[p:p+4]...)
+			}
+			p += 4
+			continue
+		}
+		decoded = append(decoded, data[p:p+4*n]...)
+		p += 4 * n
+	}
+	if !bytes.Equal(decoded, img.Pix) || data[0] != 128+3 {
+		t.Fatalf("run-length data % x decodes to % x, want % x", data, decoded, img.Pix)
+	}
+	if got := cString([]byte{1, '7', '"', 'a'}); got != `"\1\67\42a"` {
+		t.Fatalf("cString = %s", got)
+	}
+
+	root := t.TempDir()
+	splash := &Psplash{
+		Image:         img,
+		Background:    color.RGBA{R: 0x10, G: 0x20, B: 0x30, A: 255},
+		Text:          color.White,
+		Bar:           color.RGBA{R: 0x1f, G: 0x6f, B: 0xeb, A: 255},
+		BarBackground: color.Black,
+	}
+	if err := Install(root, sampleImage(), "b", Options{Psplash: splash}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	header, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(PsplashDir), "psplash-poky-img.h"))
+	if err != nil {
+		t.Fatalf("read image header: %v", err)
+	}
+	for _, want := range []string{"#define POKY_IMG_WIDTH (4)\n", "#define POKY_IMG_HEIGHT (2)\n", "#define POKY_IMG_ROWSTRIDE (16)\n", "#define POKY_IMG_RLE_PIXEL_DATA ((uint8*) \\\n\""} {
+		if !strings.Contains(string(header), want) {
+			t.Fatalf("expected %q in image header:\n%s", want, header)
+		}
+	}
+	colors, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(PsplashDir), "psplash-colors.h"))
+	if err != nil {
+		t.Fatalf("read colors header: %v", err)
+	}
+	for _, want := range []string{"#define PSPLASH_BACKGROUND_COLOR 0x10,0x20,0x30\n", "#define PSPLASH_BAR_COLOR 0x1f,0x6f,0xeb\n", "#define PSPLASH_BAR_BACKGROUND_COLOR 0x00,0x00,0x00\n"} {
+		if !strings.Contains(string(colors), want) {
+			t.Fatalf("expected %q in colors header:\n%s", want, colors)
+		}
+	}
+	manifest, err := os.ReadFile(filepath.Join(root, ManifestPath))
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	for _, name := range []string{"psplash-poky-img.h", "psplash-poky-img.png", "psplash-colors.h"} {
+		if !strings.Contains(string(manifest), "  "+PsplashDir+"/"+name+"\n") {
+			t.Fatalf("manifest lacks %s:\n%s", name, manifest)
+		}
+	}
+}
+
+func TestInstall_LinksAndAlternatives_StayInsideRootFS(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	// An absolute symlink resolves against the host when followed naively; inside the rootfs it must not escape.
+	if err := os.MkdirAll(filepath.Join(root, "usr", "share"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "usr", "share", "wallpapers")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	admin := filepath.Join(root, "var", "lib", "dpkg", "alternatives")
+	if err := os.MkdirAll(admin, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	existing := "auto\n/usr/share/images/desktop-base/desktop-background\n\n/usr/share/desktop-base/active-theme/wallpaper/contents/images/1920x1080.svg\n50\n\n"
+	if err := os.WriteFile(filepath.Join(admin, "desktop-background"), []byte(existing), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	alt, err := ParseAlternative("desktop-background=/usr/share/images/desktop-base/desktop-background")
+	if err != nil {
+		t.Fatalf("ParseAlternative error: %v", err)
+	}
+	if _, err := ParseAlternative("desktop-background=relative"); err == nil {
+		t.Fatalf("expected error for a relative master link")
+	}
+
+	opts := Options{Links: []string{"usr/share/backgrounds/default.jpg", "usr/share/wallpapers/default.jpg"}, Alternatives: []Alternative{alt}}
+	// Running twice replaces the links made by the first run.
+	for range 2 {
+		if err := Install(root, sampleImage(), "b", opts); err != nil {
+			t.Fatalf("Install error: %v", err)
+		}
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Fatalf("link escaped the rootfs into %s: %v", outside, entries)
+	}
+	// The absolute target of usr/share/wallpapers is re-rooted, and the relative link made there reaches the JPEG.
+	if info, err := os.Stat(filepath.Join(root, outside, "default.jpg")); err != nil || !info.Mode().IsRegular() {
+		t.Fatalf("link through absolute symlink does not resolve to the background inside the rootfs: %v", err)
+	}
+	for link, want := range map[string]string{
+		"usr/share/backgrounds/default.jpg":                "tssh/background.jpg",
+		"etc/alternatives/desktop-background":              "/usr/share/backgrounds/tssh/background.jpg",
+		"usr/share/images/desktop-base/desktop-background": "/etc/alternatives/desktop-background",
+	} {
+		if got, err := os.Readlink(filepath.Join(root, filepath.FromSlash(link))); err != nil || got != want {
+			t.Fatalf("%s -> %q (%v), want %q", link, got, err, want)
+		}
+	}
+	got, err := os.ReadFile(filepath.Join(admin, "desktop-background"))
+	if err != nil {
+		t.Fatalf("read alternatives: %v", err)
+	}
+	want := "manual\n/usr/share/images/desktop-base/desktop-background\n\n/usr/share/desktop-base/active-theme/wallpaper/contents/images/1920x1080.svg\n50\n/usr/share/backgrounds/tssh/background.jpg\n100\n\n"
+	if string(got) != want {
+		t.Fatalf("alternatives file:\n%s\nwant:\n%s", got, want)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "etc", "default.jpg"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := Install(root, sampleImage(), "b", Options{Links: []string{"etc/default.jpg"}}); err == nil {
+		t.Fatalf("expected error when a link would replace a regular file")
+	}
+}
+
+func TestInstall_Resolvers_RejectSymlinkEscapes(t *testing.T) {
+	if _, err := ParseResolver("chroot"); err == nil {
+		t.Fatalf("expected error for an unknown resolver")
+	}
+	for _, name := range Resolvers() {
+		resolver := Resolver(name)
+		t.Run(name, func(t *testing.T) {
+			if resolver == ResolverOpenat2 {
+				r, err := openRoot(t.TempDir(), resolver)
+				if errors.Is(err, errors.ErrUnsupported) {
+					t.Skipf("openat2 unavailable: %v", err)
+				}
+				if err != nil {
+					t.Fatalf("openRoot error: %v", err)
+				}
+				r.close()
+			}
+			malicious := map[string]func(root, outside string) error{
+				"absolute directory link": func(root, outside string) error {
+					return os.Symlink(outside, filepath.Join(root, "etc"))
+				},
+				"dot-dot directory link": func(root, outside string) error {
+					if err := os.MkdirAll(filepath.Join(root, "usr", "share"), 0o755); err != nil {
+						return err
+					}
+					rel, err := filepath.Rel(filepath.Join(root, "usr", "share"), outside)
+					if err != nil {
+						return err
+					}
+					return os.Symlink(rel, filepath.Join(root, "usr", "share", "backgrounds"))
+				},
+				// The state files of the build counter and the round-robin pool are written through the rootfs too.
+				"state directory link": func(root, outside string) error {
+					return os.Symlink(outside, filepath.Join(root, "var"))
+				},
+				"file link": func(root, outside string) error {
+					if err := os.MkdirAll(filepath.Join(root, "etc"), 0o755); err != nil {
+						return err
+					}
+					return os.Symlink(filepath.Join(outside, "tssh.build"), filepath.Join(root, "etc", "tssh.build"))
+				},
+			}
+			for layout, setup := range malicious {
+				root, outside := t.TempDir(), t.TempDir()
+				if err := setup(root, outside); err != nil {
+					t.Fatalf("%s: setup: %v", layout, err)
+				}
+				if err := Install(root, sampleImage(), "b", Options{Resolver: resolver, BuildCounter: "b", PoolPosition: 1}); err == nil {
+					t.Fatalf("%s: expected Install to fail", layout)
+				}
+				if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+					t.Fatalf("%s: Install wrote outside the rootfs: %v", layout, entries)
+				}
+			}
+			// Reading the state back does not follow the link to the build host either.
+			linked, host := t.TempDir(), t.TempDir()
+			if err := os.MkdirAll(filepath.Join(host, "lib", "tssh"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(host, "lib", "tssh", "build.counter"), []byte("41\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(host, filepath.Join(linked, "var")); err != nil {
+				t.Fatal(err)
+			}
+			if data, ok, err := ReadFile(linked, ProfileLinux.Layout().BuildCounter(), resolver); ok || err == nil && len(data) > 0 {
+				t.Fatalf("read the host's counter through the link: %q, %v, %v", data, ok, err)
+			}
+
+			// Symlinks that stay inside the rootfs, as in merged-/usr layouts, are followed.
+			root := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(root, "srv", "backgrounds"), 0o755); err != nil {
+				t.Fatalf("mkdir: %v", err)
+			}
+			if err := os.MkdirAll(filepath.Join(root, "usr", "share"), 0o755); err != nil {
+				t.Fatalf("mkdir: %v", err)
+			}
+			if err := os.Symlink("../../srv/backgrounds", filepath.Join(root, "usr", "share", "backgrounds")); err != nil {
+				t.Fatalf("symlink: %v", err)
+			}
+			if err := Install(root, sampleImage(), "b", Options{Resolver: resolver}); err != nil {
+				t.Fatalf("Install error: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(root, "srv", "backgrounds", "tssh", "background.jpg")); err != nil {
+				t.Fatalf("expected the background behind the in-rootfs symlink: %v", err)
+			}
+		})
+	}
+}
+
+func TestInstall_Ownership_RecordsRootOwnershipForFakerootAndSquashfs(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	etcInfo, err := os.Stat(filepath.Join(root, "etc"))
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	etcID, err := fileID(etcInfo)
+	if err != nil {
+		t.Fatalf("fileID: %v", err)
+	}
+	// An earlier fakeroot session recorded etc and an unrelated file; both entries have to survive.
+	etcLine := fmt.Sprintf("dev=%x,ino=%d,mode=40700,uid=0,gid=0,nlink=2,rdev=0", etcID.dev, etcID.ino)
+	db := filepath.Join(t.TempDir(), "fakeroot.db")
+	if err := os.WriteFile(db, []byte(etcLine+"\ndev=1,ino=1,mode=100600,uid=0,gid=42,nlink=1,rdev=0\n"), 0o644); err != nil {
+		t.Fatalf("write db: %v", err)
+	}
+
+	opts := Options{Links: []string{"usr/share/backgrounds/default.jpg"}, Ownership: &Ownership{Path: db}}
+	if err := Install(root, sampleImage(), "b", opts); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	got, err := os.ReadFile(db)
+	if err != nil {
+		t.Fatalf("read db: %v", err)
+	}
+	want := []string{etcLine, "dev=1,ino=1,mode=100600,uid=0,gid=42,nlink=1,rdev=0"}
+	for rel, mode := range map[string]string{
+		"etc/tssh.build":                    "100644",
+		"etc/tssh.manifest":                 "100644",
+		"usr":                               "40755",
+		"usr/share/backgrounds/tssh":        "40755",
+		"usr/share/backgrounds/default.jpg": "120777",
+	} {
+		info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatalf("lstat: %v", err)
+		}
+		id, _ := fileID(info)
+		want = append(want, fmt.Sprintf("dev=%x,ino=%d,mode=%s,uid=0,gid=0,", id.dev, id.ino, mode))
+	}
+	for _, w := range want {
+		if strings.Count(string(got), w) != 1 {
+			t.Fatalf("expected one %q in fakeroot database:\n%s", w, got)
+		}
+	}
+
+	pseudo := filepath.Join(t.TempDir(), "pseudo")
+	if err := os.WriteFile(pseudo, []byte("dev/console c 600 0 0 5 1\netc/tssh.build m 600 1000 1000\n"), 0o644); err != nil {
+		t.Fatalf("write pseudo: %v", err)
+	}
+	if err := Install(root, sampleImage(), "b", Options{Ownership: &Ownership{Format: OwnershipSquashfs, Path: pseudo}}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	got, err = os.ReadFile(pseudo)
+	if err != nil {
+		t.Fatalf("read pseudo: %v", err)
+	}
+	for _, w := range []string{"dev/console c 600 0 0 5 1\netc/tssh.build m 644 0 0\n", "\netc m 755 0 0\n", "\nusr/share/backgrounds/tssh/background.jpg m 644 0 0\n"} {
+		if !strings.Contains(string(got), w) {
+			t.Fatalf("expected %q in pseudo file:\n%s", w, got)
+		}
+	}
+
+	if err := Install(root, sampleImage(), "b", Options{Ownership: &Ownership{Format: "pseudo", Path: pseudo}}); err == nil {
+		t.Fatalf("expected error for an unknown ownership format")
+	}
+}
+
+func TestInstall_Sync_ReplacesFilesByRename(t *testing.T) {
+	for _, name := range []string{string(ResolverGo), string(ResolverAuto)} {
+		root := t.TempDir()
+		buildPath := filepath.Join(root, "etc", "tssh.build")
+		if err := os.MkdirAll(filepath.Dir(buildPath), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(buildPath, []byte("old\n"), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		// A hard link keeps the old inode: truncating in place would change it, renaming a new file over it does not.
+		snapshot := filepath.Join(root, "tssh.build.snapshot")
+		if err := os.Link(buildPath, snapshot); err != nil {
+			t.Fatalf("link: %v", err)
+		}
+
+		opts := Options{Sync: true, Resolver: Resolver(name), Links: []string{"usr/share/backgrounds/default.jpg"}}
+		if err := Install(root, sampleImage(), "new", opts); err != nil {
+			t.Fatalf("%s: Install error: %v", name, err)
+		}
+		if got, _ := os.ReadFile(buildPath); string(got) != "new\n" {
+			t.Fatalf("%s: tssh.build = %q, want the new build ID", name, got)
+		}
+		if got, _ := os.ReadFile(snapshot); string(got) != "old\n" {
+			t.Fatalf("%s: the old tssh.build was modified in place: %q", name, got)
+		}
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && strings.HasSuffix(path, ".tssh-tmp") {
+				err = fmt.Errorf("temporary file %s left behind", path)
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+}
+
+// boundsOnly is an image with bounds but no pixels, for sizes that are never encoded.
+type boundsOnly image.Rectangle
+
+func (b boundsOnly) ColorModel() color.Model { return color.RGBAModel }
+func (b boundsOnly) Bounds() image.Rectangle { return image.Rectangle(b) }
+func (b boundsOnly) At(x, y int) color.Color { return color.Black }
+
+func TestInstall_SpaceCheck_FailsBeforeWriting(t *testing.T) {
+	img := boundsOnly(image.Rect(0, 0, 100, 10))
+	want := uint64(metadataSlack + 1000*jpegBytesPerPixel + 1000*bmpBytesPerPixel + 1000*pngBytesPerPixel + 3*1000*bmpBytesPerPixel)
+	opts := Options{PNG: true, Splash: &Splash{Format: "bmp", Frames: 3}}
+	if got := estimateSize(img, opts, ProfileLinux.Layout()); got != want {
+		t.Fatalf("estimateSize = %d, want %d", got, want)
+	}
+	if got := formatBytes(3 << 29); got != "1.5 GiB" {
+		t.Fatalf("formatBytes = %q", got)
+	}
+
+	// Petabytes of psplash source do not fit into any test machine's temporary directory.
+	root := t.TempDir()
+	psplash := &Psplash{Image: boundsOnly(image.Rect(0, 0, 1<<25, 1<<25)), Background: color.Black, Text: color.White, Bar: color.White, BarBackground: color.Black}
+	err := Install(root, sampleImage(), "b", Options{Psplash: psplash})
+	if err == nil || !strings.Contains(err.Error(), "not enough space") {
+		t.Fatalf("expected the space check to fail, got %v", err)
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 0 {
+		t.Fatalf("Install wrote before failing the space check: %v", entries)
+	}
+}
+
+func TestInstall_ModTime_SetsTimesOfFilesDirectoriesAndLinks(t *testing.T) {
+	root := t.TempDir()
+	mtime := time.Unix(1700000000, 0)
+	opts := Options{ModTime: mtime, Links: []string{"usr/share/backgrounds/default.jpg"}}
+	if err := Install(root, sampleImage(), "b", opts); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+
+	for _, rel := range []string{
+		"boot/splash.bmp",
+		"usr/share/backgrounds/tssh/background.jpg",
+		"usr/share/backgrounds/default.jpg",
+		"usr/share/backgrounds/tssh",
+		"usr",
+		"etc/tssh.build",
+		"etc",
+	} {
+		info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatalf("lstat %s: %v", rel, err)
+		}
+		if !info.ModTime().Equal(mtime) {
+			t.Fatalf("%s: mtime %v, want %v", rel, info.ModTime(), mtime)
+		}
+	}
+	// The rootfs itself is not written by Install.
+	if info, err := os.Stat(root); err != nil || info.ModTime().Equal(mtime) {
+		t.Fatalf("expected the rootfs directory to keep its time (%v)", err)
+	}
+}
+
+func TestInstall_Apply_WritesFilesInsideRootFS(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "etc", "tssh.build"), []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	files := []File{
+		{Path: "etc/tssh.build", Data: []byte("new\n")},
+		{Path: "usr/share/backgrounds/tssh/background.jpg", Data: []byte("jpeg")},
+	}
+	db := filepath.Join(t.TempDir(), "pseudo")
+	opts := ApplyOptions{Sync: true, Ownership: &Ownership{Format: OwnershipSquashfs, Path: db}}
+	if err := Apply(root, files, opts); err != nil {
+		t.Fatalf("Apply error: %v", err)
+	}
+	for _, f := range files {
+		if got, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(f.Path))); err != nil || !bytes.Equal(got, f.Data) {
+			t.Fatalf("%s: got %q, %v", f.Path, got, err)
+		}
+	}
+	if data, err := os.ReadFile(db); err != nil || !strings.Contains(string(data), "usr/share/backgrounds m 755 0 0\n") {
+		t.Fatalf("unexpected pseudo file (%v):\n%s", err, data)
+	}
+
+	for _, p := range []string{"../escape", "/etc/passwd", "etc/./x"} {
+		if err := Apply(root, []File{{Path: p}}, ApplyOptions{}); err == nil {
+			t.Fatalf("Apply accepted %q", p)
+		}
+	}
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "boot")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(root, []File{{Path: "boot/splash.bmp", Data: []byte("bmp")}}, ApplyOptions{}); err == nil {
+		t.Fatalf("expected a symlink out of the rootfs to be rejected")
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Fatalf("Apply wrote outside the rootfs: %v", entries)
+	}
+}
+
+// TestInstall_Backup_SavesAndRestoresOverwrittenFiles verifies both backup locations and Restore.
+// The test fails if a hand-tuned file is lost on overwrite, a .bak is replaced by a later run, a run directory is not
+// named by the time, or Restore does not put the saved files back.
+func TestInstall_Backup_SavesAndRestoresOverwrittenFiles(t *testing.T) {
+	root := t.TempDir()
+	splash := filepath.Join(root, "boot", "splash.bmp")
+	if err := os.MkdirAll(filepath.Dir(splash), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(splash, []byte("hand-tuned"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		if err := Install(root, sampleImage(), id, Options{Backup: &Backup{}, Sync: true}); err != nil {
+			t.Fatalf("Install error: %v", err)
+		}
+	}
+	if got, err := os.ReadFile(splash + BackupSuffix); err != nil || string(got) != "hand-tuned" {
+		t.Fatalf("splash.bmp.bak = %q, %v; want the file from before the first run", got, err)
+	}
+	if got, err := os.ReadFile(filepath.Join(root, "etc", "tssh.build.bak")); err != nil || string(got) != "1\n" {
+		t.Fatalf("tssh.build.bak = %q, %v; want the first build", got, err)
+	}
+	restored, err := Restore(root, RestoreOptions{})
+	if err != nil {
+		t.Fatalf("Restore error: %v", err)
+	}
+	if !slices.Contains(restored, "boot/splash.bmp") || !slices.Contains(restored, ManifestPath) {
+		t.Fatalf("restored %v, want the splash and the manifest", restored)
+	}
+	if got, _ := os.ReadFile(splash); string(got) != "hand-tuned" {
+		t.Fatalf("splash.bmp = %q after Restore", got)
+	}
+
+	when := time.Date(2026, 1, 4, 13, 35, 13, 0, time.UTC)
+	backup := &Backup{Dir: "var/backups/tssh", Time: when}
+	if err := Install(root, sampleImage(), "3", Options{Backup: backup}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	run := filepath.Join(root, "var", "backups", "tssh", "20260104T133513Z")
+	if got, err := os.ReadFile(filepath.Join(run, "boot", "splash.bmp")); err != nil || string(got) != "hand-tuned" {
+		t.Fatalf("backup in %s = %q, %v", run, got, err)
+	}
+	if err := Install(root, sampleImage(), "4", Options{Backup: &Backup{Dir: "var/backups/tssh", Time: when.Add(time.Hour)}}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	if _, err := Restore(root, RestoreOptions{Backup: *backup}); err != nil {
+		t.Fatalf("Restore error: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(root, "etc", "tssh.build")); string(got) != "1\n" {
+		t.Fatalf("tssh.build = %q after restoring the first run", got)
+	}
+	if _, err := Restore(root, RestoreOptions{Backup: Backup{Dir: "var/backups/tssh"}}); err != nil {
+		t.Fatalf("Restore error: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(root, "etc", "tssh.build")); string(got) != "3\n" {
+		t.Fatalf("tssh.build = %q after restoring the latest run", got)
+	}
+
+	if err := Install(root, sampleImage(), "5", Options{Backup: &Backup{Dir: "../outside"}}); err == nil {
+		t.Fatalf("expected a backup directory outside the rootfs to be rejected")
+	}
+	if _, err := Restore(t.TempDir(), RestoreOptions{}); err == nil {
+		t.Fatalf("expected Restore without backups to fail")
+	}
+}
+
+// TestInstall_Product_NamesDirectoriesAndMetadataFiles expects a product to name the backgrounds directory, the
+// metadata files, the state directory, and the Windows vendor directories, the brand to lead the image description, DetectLayout to find
+// the product's manifest, and invalid product names to be rejected. The test fails if a file is written under tssh or
+// a layout is not detected.
+func TestInstall_Product_NamesDirectoriesAndMetadataFiles(t *testing.T) {
+	root := t.TempDir()
+	info := ImageInfo{Brand: "ACME", TargetName: "my-target", BuildID: "b"}
+	slideshow := &Slideshow{Slides: []Slide{{Name: "day", Hour: 8, Image: sampleImage()}, {Name: "night", Hour: 20, Image: sampleImage()}}}
+	splash := &Splash{Format: "png", FPS: 25, Frames: 1, Render: func(emit func(image.Image) error) error { return emit(sampleImage()) }}
+	psplash := &Psplash{Image: sampleImage(), Background: color.Black, Text: color.White, Bar: color.White, BarBackground: color.Black}
+	opts := Options{Product: "acme", BuildCounter: "b", Metadata: []Field{{Key: "K", Value: "v"}}, Slideshow: slideshow, Splash: splash, Psplash: psplash, Image: info}
+	if err := Install(root, sampleImage(), "b", opts); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	for _, name := range []string{
+		"usr/share/backgrounds/acme/background.jpg",
+		"usr/share/backgrounds/acme/background-timed.xml",
+		"etc/acme.build",
+		"etc/acme.release",
+		"etc/acme.manifest",
+		"var/lib/acme/build.counter",
+		"usr/share/plymouth/themes/acme/acme.script",
+		"usr/share/acme/psplash/psplash-colors.h",
+	} {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(name))); err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"usr/share/backgrounds/tssh", "etc/tssh.build", "var/lib/tssh", SplashDir, "usr/share/tssh"} {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(name))); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("expected no %s, got %v", name, err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(root, "usr", "share", "backgrounds", "acme", "background.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("ACME my-target build b")) {
+		t.Fatal("expected the brand in the jpeg description")
+	}
+	theme, err := os.ReadFile(filepath.Join(root, "usr", "share", "plymouth", "themes", "acme", "acme.plymouth"))
+	if err != nil || !strings.Contains(string(theme), "Name=ACME\n") || !strings.Contains(string(theme), "ScriptFile=/usr/share/plymouth/themes/acme/acme.script\n") {
+		t.Fatalf("Plymouth theme does not carry the product and brand: %v\n%s", err, theme)
+	}
+	profile, layout, err := DetectLayout(root)
+	if err != nil || profile != ProfileLinux || layout.Product != "acme" {
+		t.Fatalf("DetectLayout = %q, %+v, %v", profile, layout, err)
+	}
+	if build, ok, err := InstalledBuild(root, layout, ResolverAuto); err != nil || !ok || build != "b" {
+		t.Fatalf("InstalledBuild = %q, %v, %v", build, ok, err)
+	}
+
+	root = t.TempDir()
+	if err := Install(root, sampleImage(), "b", Options{Profile: ProfileWindows, Product: "acme"}); err != nil {
+		t.Fatalf("Install error: %v", err)
+	}
+	for _, name := range []string{"Windows/Web/Wallpaper/ACME/background.jpg", "ProgramData/ACME/acme.manifest", "ProgramData/ACME/wallpaper.reg"} {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(name))); err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+	}
+	if profile, layout, err := DetectLayout(root); err != nil || profile != ProfileWindows || layout.Product != "acme" {
+		t.Fatalf("DetectLayout = %q, %+v, %v", profile, layout, err)
+	}
+
+	for _, name := range []string{"ACME", "../acme", "-acme", "ac me"} {
+		if _, err := ParseProduct(name); err == nil {
+			t.Fatalf("expected product %q to be rejected", name)
+		}
+	}
+}
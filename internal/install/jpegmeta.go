@@ -0,0 +1,175 @@
+package install
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// ImageInfo identifies the build an image belongs to.
+// It is embedded as EXIF and XMP into the JPEG background so a copied file can still be traced to its build.
+type ImageInfo struct {
+	// Brand leads the description, as it leads the rendered title; empty selects DefaultBrand.
+	Brand            string
+	TargetName       string
+	BuildID          string
+	GeneratorVersion string
+	SourceURL        string
+	// WallpaperID is the Wallhaven ID of the background; it is only embedded when set.
+	WallpaperID string
+	// Attribution is the background's credit line, e.g. "Photo by Jane Doe on Unsplash"; it is only embedded when set.
+	Attribution string
+	// BackgroundSource names where the background actually came from, e.g. "wallhaven" or "cache"; it is only
+	// embedded when set.
+	BackgroundSource string
+}
+
+// description returns the image description, e.g. "TSSH my-target build 2026-01-04".
+func (info ImageInfo) description() string {
+	brand := info.Brand
+	if brand == "" {
+		brand = DefaultBrand
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s %s build %s", brand, info.TargetName, info.BuildID))
+}
+
+const (
+	markerSOI  = 0xD8
+	markerAPP1 = 0xE1
+
+	exifHeader = "Exif\x00\x00"
+	xmpHeader  = "http://ns.adobe.com/xap/1.0/\x00"
+	xmpNS      = "https://github.com/nickhildebrandt/ts-release/ns/1.0/"
+
+	tagDocumentName     = 0x010D
+	tagImageDescription = 0x010E
+	tagSoftware         = 0x0131
+	typeASCII           = 2
+
+	maxSegmentPayload = 0xFFFF - 2
+)
+
+// isZero reports whether no field is set, in which case no metadata segments are written.
+func (i ImageInfo) isZero() bool {
+	return i == ImageInfo{}
+}
+
+// embedJPEGMetadata inserts EXIF and XMP APP1 segments directly after the SOI marker of an encoded JPEG.
+// It returns an error if the data does not start with SOI or a segment would exceed the JPEG size limit.
+func embedJPEGMetadata(jpegData []byte, info ImageInfo) ([]byte, error) {
+	if len(jpegData) < 2 || jpegData[0] != 0xFF || jpegData[1] != markerSOI {
+		return nil, fmt.Errorf("install: jpeg data does not start with SOI marker")
+	}
+
+	exif, err := app1Segment(exifHeader, exifPayload(info))
+	if err != nil {
+		return nil, err
+	}
+	xmp, err := app1Segment(xmpHeader, []byte(xmpPacket(info)))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(jpegData)+len(exif)+len(xmp))
+	out = append(out, jpegData[:2]...)
+	out = append(out, exif...)
+	out = append(out, xmp...)
+	out = append(out, jpegData[2:]...)
+	return out, nil
+}
+
+// app1Segment wraps a header and payload into a length-prefixed APP1 marker segment.
+func app1Segment(header string, payload []byte) ([]byte, error) {
+	size := len(header) + len(payload)
+	if size > maxSegmentPayload {
+		return nil, fmt.Errorf("install: jpeg metadata segment too large (%d bytes)", size)
+	}
+	var b bytes.Buffer
+	b.Write([]byte{0xFF, markerAPP1})
+	_ = binary.Write(&b, binary.BigEndian, uint16(size+2))
+	b.WriteString(header)
+	b.Write(payload)
+	return b.Bytes(), nil
+}
+
+// exifPayload builds a big-endian TIFF structure with a single IFD holding ASCII tags.
+// DocumentName carries the source URL, ImageDescription the target/build, and Software the generator version.
+func exifPayload(info ImageInfo) []byte {
+	type entry struct {
+		tag   uint16
+		value string
+	}
+	description := info.description()
+	software := strings.TrimSpace("ts-release " + info.GeneratorVersion)
+	// Tags must be sorted in ascending order.
+	entries := []entry{
+		{tag: tagDocumentName, value: info.SourceURL},
+		{tag: tagImageDescription, value: description},
+		{tag: tagSoftware, value: software},
+	}
+
+	const tiffHeaderSize = 8
+	ifdSize := 2 + len(entries)*12 + 4
+	dataOffset := tiffHeaderSize + ifdSize
+
+	var ifd, data bytes.Buffer
+	_ = binary.Write(&ifd, binary.BigEndian, uint16(len(entries)))
+	for _, e := range entries {
+		value := append([]byte(e.value), 0)
+		_ = binary.Write(&ifd, binary.BigEndian, e.tag)
+		_ = binary.Write(&ifd, binary.BigEndian, uint16(typeASCII))
+		_ = binary.Write(&ifd, binary.BigEndian, uint32(len(value)))
+		if len(value) <= 4 {
+			inline := make([]byte, 4)
+			copy(inline, value)
+			ifd.Write(inline)
+			continue
+		}
+		_ = binary.Write(&ifd, binary.BigEndian, uint32(dataOffset+data.Len()))
+		data.Write(value)
+		if data.Len()%2 == 1 {
+			// Offsets should be word-aligned.
+			data.WriteByte(0)
+		}
+	}
+	_ = binary.Write(&ifd, binary.BigEndian, uint32(0)) // no next IFD
+
+	var b bytes.Buffer
+	b.WriteString("MM")
+	_ = binary.Write(&b, binary.BigEndian, uint16(42))
+	_ = binary.Write(&b, binary.BigEndian, uint32(tiffHeaderSize))
+	b.Write(ifd.Bytes())
+	b.Write(data.Bytes())
+	return b.Bytes()
+}
+
+// xmpPacket builds a minimal XMP packet with the build fields in a ts-release namespace and the source URL as dc:source.
+func xmpPacket(info ImageInfo) string {
+	esc := html.EscapeString
+	optional := ""
+	if info.WallpaperID != "" {
+		optional += ` tssh:WallpaperID="` + esc(info.WallpaperID) + `"`
+	}
+	if info.Attribution != "" {
+		optional += ` tssh:Attribution="` + esc(info.Attribution) + `"`
+	}
+	if info.BackgroundSource != "" {
+		optional += ` tssh:BackgroundSource="` + esc(info.BackgroundSource) + `"`
+	}
+	return `<?xpacket begin="` + "\uFEFF" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>` +
+		`<x:xmpmeta xmlns:x="adobe:ns:meta/">` +
+		`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">` +
+		`<rdf:Description rdf:about=""` +
+		` xmlns:dc="http://purl.org/dc/elements/1.1/"` +
+		` xmlns:tssh="` + xmpNS + `"` +
+		` tssh:Target="` + esc(info.TargetName) + `"` +
+		` tssh:BuildID="` + esc(info.BuildID) + `"` +
+		` tssh:GeneratorVersion="` + esc(info.GeneratorVersion) + `"` +
+		optional +
+		` dc:source="` + esc(info.SourceURL) + `"/>` +
+		`</rdf:RDF>` +
+		`</x:xmpmeta>` +
+		`<?xpacket end="w"?>`
+}
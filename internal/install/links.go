@@ -0,0 +1,319 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// AlternativePriority is the priority the background is registered with in an alternatives group. It only matters
+// once the group is switched back to automatic mode, as Install selects the background manually.
+const AlternativePriority = 100
+
+// maxLinkHops bounds the symlinks followed while resolving a path in the rootfs, like the kernel's ELOOP limit.
+const maxLinkHops = 40
+
+// Alternative is a Debian alternatives group, such as desktop-background of desktop-base, that the background is
+// registered in and selected for.
+type Alternative struct {
+	// Name is the name of the group, e.g. desktop-background.
+	Name string
+	// Link is the absolute path of the group's master link on the running system, e.g.
+	// /usr/share/images/desktop-base/desktop-background.
+	Link string
+}
+
+// ParseAlternative parses a "name=link" group specification. It returns an error for names that are not a single
+// path element and links that are not absolute.
+func ParseAlternative(spec string) (Alternative, error) {
+	name, link, _ := strings.Cut(spec, "=")
+	alt := Alternative{Name: name, Link: link}
+	if err := alt.validate(); err != nil {
+		return Alternative{}, err
+	}
+	return alt, nil
+}
+
+// validate checks the name and link of the group.
+func (a Alternative) validate() error {
+	if a.Name == "" || a.Name == "." || a.Name == ".." || strings.ContainsAny(a.Name, "/\n") {
+		return fmt.Errorf("install: invalid alternatives group %q, want name=/absolute/link", a.Name)
+	}
+	if !path.IsAbs(a.Link) || path.Clean(a.Link) == "/" || strings.Contains(a.Link, "\n") {
+		return fmt.Errorf("install: alternatives group %s: master link %q must be an absolute path", a.Name, a.Link)
+	}
+	return nil
+}
+
+// validateLink checks a rootfs-relative symlink location.
+func validateLink(name string) error {
+	if name == "" || path.Clean("/"+name) == "/" {
+		return fmt.Errorf("install: invalid link %q", name)
+	}
+	return nil
+}
+
+// resolveInRoot returns the path on the build host of the rootfs location name, resolving symlinks in its directory
+// as they resolve inside the rootfs: absolute targets start at rootFS, and ".." stops there. The last element is not
+// followed, so a symlink there can be replaced. Nothing outside rootFS is ever read or returned.
+func resolveInRoot(rootFS, name string) (string, error) {
+	clean := path.Clean("/" + filepath.ToSlash(name))
+	if clean == "/" {
+		return "", fmt.Errorf("install: %q names the rootfs itself", name)
+	}
+	pending := strings.Split(clean[1:], "/")
+	var resolved []string
+	hops := 0
+	for len(pending) > 1 {
+		part := pending[0]
+		pending = pending[1:]
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(resolved) > 0 {
+				resolved = resolved[:len(resolved)-1]
+			}
+			continue
+		}
+		host := filepath.Join(rootFS, filepath.Join(append(resolved, part)...))
+		info, err := os.Lstat(host)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("install: resolve %s: %w", name, err)
+		}
+		if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+			resolved = append(resolved, part)
+			continue
+		}
+		if hops++; hops > maxLinkHops {
+			return "", fmt.Errorf("install: resolve %s: too many levels of symbolic links", name)
+		}
+		target, err := os.Readlink(host)
+		if err != nil {
+			return "", fmt.Errorf("install: resolve %s: %w", name, err)
+		}
+		if path.IsAbs(target) {
+			resolved = nil
+		}
+		pending = append(strings.Split(target, "/"), pending...)
+	}
+	return filepath.Join(rootFS, filepath.Join(resolved...), pending[0]), nil
+}
+
+// replaceSymlink points the symlink at host to target, creating missing parent directories. It returns an error
+// rather than replace anything but a symlink, so files shipped by packages are never lost.
+func replaceSymlink(r *root, host, target string) error {
+	if info, err := os.Lstat(host); err == nil && info.Mode()&fs.ModeSymlink == 0 {
+		return fmt.Errorf("install: %s exists and is not a symlink", host)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("install: %w", err)
+	}
+	if err := r.mkdirAll(filepath.Dir(host)); err != nil {
+		return err
+	}
+	// Renaming a new link over the old one never leaves the location missing.
+	tmp := host + ".tssh-new"
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return fmt.Errorf("install: %w", err)
+	}
+	if err := os.Rename(tmp, host); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("install: %w", err)
+	}
+	if r.sync {
+		dir, err := r.rel(filepath.Dir(host))
+		if err != nil {
+			return err
+		}
+		if err := r.syncDir(dir); err != nil {
+			return fmt.Errorf("install: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeLink points the rootfs location name at the background with a relative symlink, which resolves the same from
+// the build host and inside the rootfs. It returns the path of the symlink on the build host.
+func writeLink(r *root, name, background string) (string, error) {
+	host, err := resolveInRoot(r.dir, name)
+	if err != nil {
+		return "", err
+	}
+	target, err := filepath.Rel(filepath.Dir(host), background)
+	if err != nil {
+		return "", fmt.Errorf("install: link %s: %w", name, err)
+	}
+	if err := replaceSymlink(r, host, filepath.ToSlash(target)); err != nil {
+		return "", err
+	}
+	return host, nil
+}
+
+// writeAlternative registers choice, the absolute path of the background on the running system, in the group and
+// selects it as update-alternatives --set does: the administrative file in var/lib/dpkg/alternatives lists it in
+// manual mode, etc/alternatives/<name> points to it, and the master link points to etc/alternatives/<name>. It returns
+// the paths of the administrative file and the two symlinks on the build host.
+func writeAlternative(r *root, alt Alternative, choice string) ([]string, error) {
+	admin, err := resolveInRoot(r.dir, path.Join("var/lib/dpkg/alternatives", alt.Name))
+	if err != nil {
+		return nil, err
+	}
+	db := alternativesDB{link: alt.Link}
+	if file, err := r.open(admin); err == nil {
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("install: %w", err)
+		}
+		if db, err = parseAlternatives(string(data)); err != nil {
+			return nil, fmt.Errorf("install: %s: %w", admin, err)
+		}
+		if db.link != alt.Link {
+			return nil, fmt.Errorf("install: alternatives group %s has master link %s, not %s", alt.Name, db.link, alt.Link)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("install: %w", err)
+	}
+	db.status = "manual"
+	db.set(choice, AlternativePriority)
+	if err := r.mkdirAll(filepath.Dir(admin)); err != nil {
+		return nil, err
+	}
+	if err := writeText(r, admin, db.String()); err != nil {
+		return nil, err
+	}
+
+	written := []string{admin}
+	selection := path.Join("/etc/alternatives", alt.Name)
+	for _, l := range []struct{ name, target string }{{selection, choice}, {alt.Link, selection}} {
+		host, err := resolveInRoot(r.dir, l.name)
+		if err != nil {
+			return nil, err
+		}
+		if err := replaceSymlink(r, host, l.target); err != nil {
+			return nil, err
+		}
+		written = append(written, host)
+	}
+	return written, nil
+}
+
+// alternativesDB is an administrative file of update-alternatives: the mode, the master link, the slave links, and
+// each alternative with its priority and one path per slave.
+type alternativesDB struct {
+	status  string
+	link    string
+	slaves  []alternativeSlave
+	choices []alternativeChoice
+}
+
+type alternativeSlave struct {
+	name, link string
+}
+
+type alternativeChoice struct {
+	path     string
+	priority int
+	// slaves holds the path for each slave link of the group, empty where the alternative provides none.
+	slaves []string
+}
+
+// parseAlternatives parses the administrative file format documented in update-alternatives(1).
+func parseAlternatives(data string) (alternativesDB, error) {
+	lines := strings.Split(data, "\n")
+	next := func() (string, error) {
+		if len(lines) == 0 {
+			return "", fmt.Errorf("truncated alternatives file")
+		}
+		line := lines[0]
+		lines = lines[1:]
+		return line, nil
+	}
+	var db alternativesDB
+	var err error
+	if db.status, err = next(); err != nil {
+		return db, err
+	}
+	if db.status != "auto" && db.status != "manual" {
+		return db, fmt.Errorf("unknown alternatives mode %q", db.status)
+	}
+	if db.link, err = next(); err != nil {
+		return db, err
+	}
+	for {
+		name, err := next()
+		if err != nil {
+			return db, err
+		}
+		if name == "" {
+			break
+		}
+		link, err := next()
+		if err != nil {
+			return db, err
+		}
+		db.slaves = append(db.slaves, alternativeSlave{name: name, link: link})
+	}
+	for {
+		p, err := next()
+		if err != nil {
+			return db, err
+		}
+		if p == "" {
+			break
+		}
+		line, err := next()
+		if err != nil {
+			return db, err
+		}
+		choice := alternativeChoice{path: p}
+		if choice.priority, err = strconv.Atoi(line); err != nil {
+			return db, fmt.Errorf("alternative %s: invalid priority %q", p, line)
+		}
+		for range db.slaves {
+			slave, err := next()
+			if err != nil {
+				return db, err
+			}
+			choice.slaves = append(choice.slaves, slave)
+		}
+		db.choices = append(db.choices, choice)
+	}
+	return db, nil
+}
+
+// set adds the alternative p with the given priority and no slave paths, or updates its priority if it is listed.
+func (db *alternativesDB) set(p string, priority int) {
+	for i := range db.choices {
+		if db.choices[i].path == p {
+			db.choices[i].priority = priority
+			return
+		}
+	}
+	db.choices = append(db.choices, alternativeChoice{path: p, priority: priority, slaves: make([]string, len(db.slaves))})
+}
+
+// String formats db as an administrative file.
+func (db alternativesDB) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%s\n", db.status, db.link)
+	for _, s := range db.slaves {
+		fmt.Fprintf(&b, "%s\n%s\n", s.name, s.link)
+	}
+	b.WriteString("\n")
+	for _, c := range db.choices {
+		fmt.Fprintf(&b, "%s\n%d\n", c.path, c.priority)
+		for _, s := range c.slaves {
+			b.WriteString(s + "\n")
+		}
+	}
+	b.WriteString("\n")
+	return b.String()
+}
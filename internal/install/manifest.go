@@ -0,0 +1,59 @@
+package install
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// ManifestPath is the rootfs-relative location of the checksum manifest of ProfileLinux; Layout.Manifest gives it for
+// every profile. It uses sha256sum syntax with rootfs-relative paths, so `sha256sum -c` works from the rootfs directory.
+const ManifestPath = "etc/tssh.manifest"
+
+// Artifact is a file Install wrote and listed in the manifest.
+type Artifact struct {
+	// Path is rootfs-relative and slash-separated, as in the manifest.
+	Path string
+	// Size is the size of the file in bytes, as it was written.
+	Size int64
+}
+
+// writeManifest hashes the given artifact paths and writes them as a sha256sum-style manifest to the rootfs-relative
+// location manifest. It returns the artifacts in manifest order, or an error if an artifact cannot be hashed or the
+// manifest cannot be written.
+func writeManifest(r *root, manifest string, paths []string) ([]Artifact, error) {
+	var b strings.Builder
+	var artifacts []Artifact
+	for _, path := range paths {
+		sum, size, err := fileSHA256(r, path)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(r.dir, path)
+		if err != nil {
+			return nil, fmt.Errorf("install: manifest path %q: %w", path, err)
+		}
+		fmt.Fprintf(&b, "%s  %s\n", sum, filepath.ToSlash(rel))
+		artifacts = append(artifacts, Artifact{Path: filepath.ToSlash(rel), Size: size})
+	}
+	return artifacts, writeText(r, filepath.Join(r.dir, filepath.FromSlash(manifest)), b.String())
+}
+
+// fileSHA256 returns the lowercase hex SHA-256 and the size of the file contents, read back through the rootfs.
+func fileSHA256(r *root, path string) (string, int64, error) {
+	file, err := r.open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("install: open %q for hashing: %w", path, err)
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return "", 0, fmt.Errorf("install: hash %q: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
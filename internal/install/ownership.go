@@ -0,0 +1,215 @@
+package install
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// OwnershipFormat identifies the database format in which Install records the intended ownership of what it wrote.
+type OwnershipFormat string
+
+const (
+	// OwnershipFakeroot is the save file of fakeroot -s, keyed by device and inode, which fakeroot -i loads before the
+	// pack step.
+	OwnershipFakeroot OwnershipFormat = "fakeroot"
+	// OwnershipSquashfs is a pseudo file for mksquashfs -pf, keyed by rootfs-relative path.
+	OwnershipSquashfs OwnershipFormat = "squashfs"
+)
+
+// symlinkPerm is the mode of symlinks, which Linux ignores but the databases record.
+const symlinkPerm = 0o777
+
+// OwnershipFormats returns all supported formats in a stable order for help output and validation.
+func OwnershipFormats() []string {
+	return []string{string(OwnershipFakeroot), string(OwnershipSquashfs)}
+}
+
+// ParseOwnershipFormat validates a format name. Empty selects OwnershipFakeroot; unknown names return an error.
+func ParseOwnershipFormat(name string) (OwnershipFormat, error) {
+	if name == "" {
+		return OwnershipFakeroot, nil
+	}
+	for _, f := range OwnershipFormats() {
+		if name == f {
+			return OwnershipFormat(f), nil
+		}
+	}
+	return "", fmt.Errorf("install: unknown ownership format %q (available: %s)", name, strings.Join(OwnershipFormats(), ", "))
+}
+
+// Ownership records that everything Install writes belongs to root, so an unprivileged build packs into an image
+// with correct ownership. Files are recorded with mode 0644, directories with 0755.
+type Ownership struct {
+	// Format selects the database format; empty selects OwnershipFakeroot.
+	Format OwnershipFormat
+	// Path is the database file on the build host. An existing database is updated: entries of other files are kept,
+	// as are those of directories Install did not create.
+	Path string
+}
+
+// validate checks the format and that a database path is set.
+func (o Ownership) validate() error {
+	if _, err := ParseOwnershipFormat(string(o.Format)); err != nil {
+		return err
+	}
+	if o.Path == "" {
+		return fmt.Errorf("install: ownership database path is empty")
+	}
+	return nil
+}
+
+// ownedEntry is the intended ownership of one written path.
+type ownedEntry struct {
+	path string
+	// mode holds the file type bits and permissions as stat reports them, e.g. 0o100644.
+	mode uint32
+	info fs.FileInfo
+	dir  bool
+}
+
+// writeOwnership records paths, which lie in rootFS, and their parent directories below rootFS as owned by root in
+// the database of o.
+func writeOwnership(rootFS string, o Ownership, paths []string) error {
+	format, err := ParseOwnershipFormat(string(o.Format))
+	if err != nil {
+		return err
+	}
+	// The paths were joined onto rootFS, which cleaned them.
+	rootFS = filepath.Clean(rootFS)
+	all, err := withParents(rootFS, paths)
+	if err != nil {
+		return fmt.Errorf("install: ownership: %w", err)
+	}
+
+	entries := make([]ownedEntry, 0, len(all))
+	for _, p := range all {
+		info, err := os.Lstat(p)
+		if err != nil {
+			return fmt.Errorf("install: ownership: %w", err)
+		}
+		e := ownedEntry{path: p, info: info}
+		switch {
+		case info.IsDir():
+			e.mode, e.dir = 0o040000|dirPerm, true
+		case info.Mode()&fs.ModeSymlink != 0:
+			e.mode = 0o120000 | symlinkPerm
+		default:
+			e.mode = 0o100000 | filePerm
+		}
+		entries = append(entries, e)
+	}
+
+	var old []byte
+	if old, err = os.ReadFile(o.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("install: read ownership database: %w", err)
+	}
+	var db string
+	switch format {
+	case OwnershipFakeroot:
+		db, err = fakerootDB(string(old), entries)
+	case OwnershipSquashfs:
+		db, err = squashfsPseudo(string(old), rootFS, entries)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(o.Path, []byte(db), filePerm); err != nil {
+		return fmt.Errorf("install: write ownership database: %w", err)
+	}
+	return nil
+}
+
+// withParents returns paths, which lie in rootFS, and their parent directories below rootFS, sorted and without
+// duplicates.
+func withParents(rootFS string, paths []string) ([]string, error) {
+	seen := map[string]bool{}
+	var all []string
+	for _, p := range paths {
+		for ; p != rootFS && !seen[p]; p = filepath.Dir(p) {
+			if rel, err := filepath.Rel(rootFS, p); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return nil, fmt.Errorf("%q is outside the rootfs", p)
+			}
+			seen[p] = true
+			all = append(all, p)
+		}
+	}
+	sort.Strings(all)
+	return all, nil
+}
+
+// fakerootDB returns the fakeroot save file old with the entries added. fakeroot identifies files by device and inode
+// in hex and decimal, and writes modes in octal.
+func fakerootDB(old string, entries []ownedEntry) (string, error) {
+	var lines []string
+	index := map[string]int{}
+	scanner := bufio.NewScanner(strings.NewReader(old))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		dev, rest, _ := strings.Cut(line, ",")
+		ino, _, _ := strings.Cut(rest, ",")
+		index[dev+","+ino] = len(lines)
+		lines = append(lines, line)
+	}
+	for _, e := range entries {
+		id, err := fileID(e.info)
+		if err != nil {
+			return "", err
+		}
+		key := fmt.Sprintf("dev=%x,ino=%d", id.dev, id.ino)
+		line := fmt.Sprintf("%s,mode=%o,uid=0,gid=0,nlink=%d,rdev=0", key, e.mode, id.nlink)
+		if i, ok := index[key]; ok {
+			if !e.dir {
+				lines[i] = line
+			}
+			continue
+		}
+		index[key] = len(lines)
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// squashfsPseudo returns the mksquashfs pseudo file old with "path m mode uid gid" definitions of the entries added.
+func squashfsPseudo(old, rootFS string, entries []ownedEntry) (string, error) {
+	var lines []string
+	index := map[string]int{}
+	for _, line := range strings.Split(old, "\n") {
+		if line == "" {
+			continue
+		}
+		if name, _, ok := strings.Cut(line, " "); ok && !strings.HasPrefix(line, "#") {
+			index[name] = len(lines)
+		}
+		lines = append(lines, line)
+	}
+	for _, e := range entries {
+		rel, err := filepath.Rel(rootFS, e.path)
+		if err != nil {
+			return "", fmt.Errorf("install: ownership: %w", err)
+		}
+		name := filepath.ToSlash(rel)
+		// Pseudo definitions are split at white space, and quoting them is not portable across mksquashfs versions.
+		if strings.ContainsAny(name, " \t\n\"\\") {
+			return "", fmt.Errorf("install: ownership: %q cannot be written to a squashfs pseudo file", name)
+		}
+		line := fmt.Sprintf("%s m %o 0 0", name, e.mode&0o7777)
+		if i, ok := index[name]; ok {
+			if !e.dir {
+				lines[i] = line
+			}
+			continue
+		}
+		index[name] = len(lines)
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package install
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// inode identifies a file the way fakeroot does.
+type inode struct {
+	dev, ino, nlink uint64
+}
+
+// fileID reports that fakeroot databases need the inodes of a Unix build host; OwnershipSquashfs works everywhere.
+func fileID(info fs.FileInfo) (inode, error) {
+	return inode{}, fmt.Errorf("install: ownership format %s needs a Unix build host", OwnershipFakeroot)
+}
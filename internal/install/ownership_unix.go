@@ -0,0 +1,23 @@
+//go:build unix
+
+package install
+
+import (
+	"fmt"
+	"io/fs"
+	"syscall"
+)
+
+// inode identifies a file the way fakeroot does.
+type inode struct {
+	dev, ino, nlink uint64
+}
+
+// fileID returns the device, inode, and link count of info.
+func fileID(info fs.FileInfo) (inode, error) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return inode{}, fmt.Errorf("install: ownership: no inode for %s", info.Name())
+	}
+	return inode{dev: uint64(st.Dev), ino: uint64(st.Ino), nlink: uint64(st.Nlink)}, nil
+}
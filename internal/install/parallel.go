@@ -0,0 +1,62 @@
+package install
+
+import (
+	"runtime"
+	"sync/atomic"
+
+	"github.com/nickhildebrandt/ts-release/internal/diag"
+	"golang.org/x/sync/errgroup"
+)
+
+// writeJob writes one or more artifacts and returns their paths in manifest order.
+type writeJob func() ([]string, error)
+
+// runJobs runs jobs with at most parallel of them at a time, where parallel below 1 selects runtime.GOMAXPROCS, and
+// returns the written paths in job order, so the manifest does not depend on which job finishes first. Once a job
+// fails, no later job starts and running jobs finish, since encoders cannot be interrupted; the error of the first
+// failed job in job order is returned. A panic in a job fails it with a *diag.PanicError.
+func runJobs(jobs []writeJob, parallel int) ([]string, error) {
+	if parallel < 1 {
+		parallel = runtime.GOMAXPROCS(0)
+	}
+	results := make([][]string, len(jobs))
+	errs := make([]error, len(jobs))
+	// failed is the lowest index of a failed job. Only jobs after it are skipped: a job before it still runs, so its
+	// error takes precedence in job order.
+	var failed atomic.Int64
+	failed.Store(int64(len(jobs)))
+	var g errgroup.Group
+	g.SetLimit(parallel)
+	for i, job := range jobs {
+		// Go waits for a free slot, during which a running job may fail.
+		g.Go(func() error {
+			if failed.Load() < int64(i) {
+				return nil
+			}
+			results[i], errs[i] = runJob(job)
+			for f := failed.Load(); errs[i] != nil && int64(i) < f && !failed.CompareAndSwap(f, int64(i)); f = failed.Load() {
+			}
+			return nil
+		})
+		if failed.Load() < int64(len(jobs)) {
+			break
+		}
+	}
+	_ = g.Wait()
+
+	var paths []string
+	for i := range jobs {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		paths = append(paths, results[i]...)
+	}
+	return paths, nil
+}
+
+// runJob runs job, turning a panic into an error: a panic in a goroutine would end the process, e.g. a serve or
+// watch process with builds of other targets.
+func runJob(job writeJob) (paths []string, err error) {
+	defer diag.Recover("install: encode", &err)
+	return job()
+}
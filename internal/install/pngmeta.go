@@ -0,0 +1,88 @@
+package install
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	pngSignature = "\x89PNG\r\n\x1a\n"
+	// ihdrChunkSize is length + type + 13 data bytes + CRC.
+	ihdrChunkSize = 4 + 4 + 13 + 4
+)
+
+// pngTextEntries returns the PNG text keywords and values for the image info.
+// Standard keywords are used where they exist; the TSSH-specific keys are machine-readable.
+func pngTextEntries(info ImageInfo) []Field {
+	return []Field{
+		{Key: "Description", Value: info.description()},
+		{Key: "Software", Value: strings.TrimSpace("ts-release " + info.GeneratorVersion)},
+		{Key: "Source", Value: info.SourceURL},
+		{Key: "tssh:Target", Value: info.TargetName},
+		{Key: "tssh:BuildID", Value: info.BuildID},
+		{Key: "tssh:GeneratorVersion", Value: info.GeneratorVersion},
+		{Key: "tssh:WallpaperID", Value: info.WallpaperID},
+		{Key: "tssh:Attribution", Value: info.Attribution},
+		{Key: "tssh:BackgroundSource", Value: info.BackgroundSource},
+	}
+}
+
+// embedPNGText inserts text chunks directly after the IHDR chunk of an encoded PNG.
+// ASCII values use tEXt; anything else uses uncompressed iTXt so UTF-8 survives.
+func embedPNGText(pngData []byte, entries []Field) ([]byte, error) {
+	headerEnd := len(pngSignature) + ihdrChunkSize
+	if len(pngData) < headerEnd || string(pngData[:len(pngSignature)]) != pngSignature ||
+		string(pngData[len(pngSignature)+4:len(pngSignature)+8]) != "IHDR" {
+		return nil, fmt.Errorf("install: png data does not start with signature and IHDR")
+	}
+
+	var chunks bytes.Buffer
+	for _, e := range entries {
+		if e.Value == "" {
+			continue
+		}
+		if len(e.Key) == 0 || len(e.Key) > 79 || !isASCII(e.Key) {
+			return nil, fmt.Errorf("install: invalid png text keyword %q", e.Key)
+		}
+		if isASCII(e.Value) {
+			writePNGChunk(&chunks, "tEXt", []byte(e.Key+"\x00"+e.Value))
+			continue
+		}
+		if !utf8.ValidString(e.Value) {
+			return nil, fmt.Errorf("install: png text value for %q is not valid UTF-8", e.Key)
+		}
+		// keyword, NUL, compression flag, compression method, empty language tag, NUL, empty translated keyword, NUL, text
+		writePNGChunk(&chunks, "iTXt", []byte(e.Key+"\x00\x00\x00\x00\x00"+e.Value))
+	}
+
+	out := make([]byte, 0, len(pngData)+chunks.Len())
+	out = append(out, pngData[:headerEnd]...)
+	out = append(out, chunks.Bytes()...)
+	out = append(out, pngData[headerEnd:]...)
+	return out, nil
+}
+
+// writePNGChunk appends a length-prefixed, CRC-terminated chunk to the buffer.
+func writePNGChunk(b *bytes.Buffer, chunkType string, data []byte) {
+	_ = binary.Write(b, binary.BigEndian, uint32(len(data)))
+	b.WriteString(chunkType)
+	b.Write(data)
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(chunkType))
+	crc.Write(data)
+	_ = binary.Write(b, binary.BigEndian, crc.Sum32())
+}
+
+// isASCII reports whether s consists of printable ASCII characters only.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7E {
+			return false
+		}
+	}
+	return true
+}
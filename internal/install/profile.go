@@ -0,0 +1,318 @@
+package install
+
+import (
+	"crypto/sha1"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+// Profile selects the directory layout of the operating system the rootfs belongs to.
+type Profile string
+
+// Supported install profiles.
+const (
+	ProfileLinux   Profile = "linux"
+	ProfileWindows Profile = "windows"
+	ProfileMacOS   Profile = "macos"
+)
+
+// Profiles returns the names of all install profiles in a stable order for help output and validation.
+func Profiles() []string {
+	return []string{string(ProfileLinux), string(ProfileWindows), string(ProfileMacOS)}
+}
+
+// ParseProfile validates a profile name. An empty name selects ProfileLinux; unknown names return an error.
+func ParseProfile(name string) (Profile, error) {
+	if name == "" {
+		return ProfileLinux, nil
+	}
+	for _, p := range Profiles() {
+		if name == p {
+			return Profile(p), nil
+		}
+	}
+	return "", fmt.Errorf("install: unknown profile %q (available: %s)", name, strings.Join(Profiles(), ", "))
+}
+
+// DefaultProduct names the backgrounds subdirectory and the metadata files unless another product is selected.
+const DefaultProduct = "tssh"
+
+// DefaultBrand leads the description embedded into the backgrounds unless ImageInfo.Brand is set.
+const DefaultBrand = "TSSH"
+
+// productPattern matches product names, which become file and directory names.
+var productPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ParseProduct validates a product name: lowercase letters, digits, "-", and "_", starting with a letter or digit.
+// An empty name selects DefaultProduct.
+func ParseProduct(name string) (string, error) {
+	if name == "" {
+		return DefaultProduct, nil
+	}
+	if !productPattern.MatchString(name) {
+		return "", fmt.Errorf("install: product %q must consist of lowercase letters, digits, \"-\", and \"_\"", name)
+	}
+	return name, nil
+}
+
+// Layout holds the rootfs-relative locations of a profile's files. They are slash-separated, like the paths in the
+// manifest; filepath.FromSlash turns them into paths of the build host.
+type Layout struct {
+	// BackgroundDir holds background.jpg and the optional PNG and spanning images.
+	BackgroundDir string
+	// MetadataDir holds the build, release, and manifest files named after Product, e.g. tssh.build.
+	MetadataDir string
+	// BootSplash is the static boot splash BMP; empty if the profile has none. BootSplashAs names it in other formats.
+	BootSplash string
+	// SplashDir holds the Plymouth theme named after Product, and PsplashDir the psplash sources; empty if the profile
+	// has no such boot splash.
+	SplashDir, PsplashDir string
+	// StateDir holds the state that consecutive builds into the rootfs carry over, such as the build counter.
+	StateDir string
+	// Settings makes the system show the background, e.g. a registry fragment; empty if the profile needs none.
+	Settings string
+	// SystemRoot is the absolute path of the rootfs on the running system, used inside Settings.
+	SystemRoot string
+	// Product names the metadata files and the product's directories.
+	Product string
+}
+
+// Layout returns the layout of the profile for DefaultProduct.
+func (p Profile) Layout() Layout {
+	return p.ProductLayout(DefaultProduct)
+}
+
+// ProductLayout returns the layout of the profile for the product, which must be valid for ParseProduct. Windows
+// keeps the background next to the stock wallpapers under Windows\Web\Wallpaper and macOS under /Library/Desktop
+// Pictures, in directories named after the product in upper case as vendor directories there are.
+func (p Profile) ProductLayout(product string) Layout {
+	vendor := strings.ToUpper(product)
+	switch p {
+	case ProfileWindows:
+		return Layout{
+			BackgroundDir: "Windows/Web/Wallpaper/" + vendor,
+			MetadataDir:   "ProgramData/" + vendor,
+			StateDir:      "ProgramData/" + vendor,
+			Settings:      "ProgramData/" + vendor + "/wallpaper.reg",
+			SystemRoot:    `C:\`,
+			Product:       product,
+		}
+	case ProfileMacOS:
+		return Layout{
+			BackgroundDir: "Library/Desktop Pictures/" + vendor,
+			MetadataDir:   "Library/Application Support/" + vendor,
+			StateDir:      "Library/Application Support/" + vendor,
+			Settings:      "Library/Application Support/" + vendor + "/desktop.mobileconfig",
+			SystemRoot:    "/",
+			Product:       product,
+		}
+	default:
+		return Layout{
+			BackgroundDir: "usr/share/backgrounds/" + product,
+			MetadataDir:   "etc",
+			StateDir:      "var/lib/" + product,
+			BootSplash:    "boot/splash.bmp",
+			SplashDir:     "usr/share/plymouth/themes/" + product,
+			PsplashDir:    "usr/share/" + product + "/psplash",
+			SystemRoot:    "/",
+			Product:       product,
+		}
+	}
+}
+
+// Background returns the location of background.jpg.
+func (l Layout) Background() string {
+	return path.Join(l.BackgroundDir, "background.jpg")
+}
+
+// BuildCounter returns the location of the persisted counter of the counter build ID format, e.g.
+// var/lib/tssh/build.counter.
+func (l Layout) BuildCounter() string {
+	return path.Join(l.StateDir, "build.counter")
+}
+
+// PoolPosition returns the location of the persisted round-robin position of a wallpaper pool, e.g.
+// var/lib/tssh/pool.position.
+func (l Layout) PoolPosition() string {
+	return path.Join(l.StateDir, "pool.position")
+}
+
+// Slideshow returns the location of the GNOME background slideshow XML; for DefaultProduct it is SlideshowPath.
+func (l Layout) Slideshow() string {
+	return path.Join(l.BackgroundDir, "background-timed.xml")
+}
+
+// Build returns the location of the build file, e.g. tssh.build.
+func (l Layout) Build() string {
+	return path.Join(l.MetadataDir, l.Product+".build")
+}
+
+// Release returns the location of the release file, e.g. tssh.release.
+func (l Layout) Release() string {
+	return path.Join(l.MetadataDir, l.Product+".release")
+}
+
+// Manifest returns the location of the manifest, e.g. tssh.manifest; for ProfileLinux and DefaultProduct it is
+// ManifestPath.
+func (l Layout) Manifest() string {
+	return path.Join(l.MetadataDir, l.Product+".manifest")
+}
+
+// systemPath returns the absolute path of a rootfs-relative location on the running system, with the separator of
+// the profile's operating system rather than the build host's.
+func (l Layout) systemPath(rel string) string {
+	if strings.HasSuffix(l.SystemRoot, `\`) {
+		return l.SystemRoot + strings.ReplaceAll(rel, "/", `\`)
+	}
+	return l.SystemRoot + rel
+}
+
+// DetectProfile returns the profile whose manifest exists in rootFS, as DetectLayout finds it.
+func DetectProfile(rootFS string) (Profile, error) {
+	p, _, err := DetectLayout(rootFS)
+	return p, err
+}
+
+// DetectLayout returns the profile and layout whose manifest exists in rootFS, checking ProfileLinux first and, per
+// profile, DefaultProduct before other products in lexical order. It returns an error wrapping fs.ErrNotExist if the
+// rootfs has no manifest.
+func DetectLayout(rootFS string) (Profile, Layout, error) {
+	for _, name := range Profiles() {
+		p := Profile(name)
+		if _, err := os.Stat(filepath.Join(rootFS, filepath.FromSlash(p.Layout().Manifest()))); err == nil {
+			return p, p.Layout(), nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", Layout{}, fmt.Errorf("install: %w", err)
+		}
+		// The layout of the product "*" is the pattern of every product's manifest.
+		matches, err := filepath.Glob(filepath.Join(rootFS, filepath.FromSlash(p.ProductLayout("*").Manifest())))
+		if err != nil {
+			return "", Layout{}, fmt.Errorf("install: %w", err)
+		}
+		for _, match := range matches {
+			product := strings.TrimSuffix(filepath.Base(match), ".manifest")
+			if _, err := ParseProduct(product); err != nil {
+				continue
+			}
+			if layout := p.ProductLayout(product); filepath.Join(rootFS, filepath.FromSlash(layout.Manifest())) == match {
+				return p, layout, nil
+			}
+		}
+	}
+	return "", Layout{}, fmt.Errorf("install: no manifest in %s: %w", rootFS, fs.ErrNotExist)
+}
+
+// writeSettings writes the Settings file of the profile, which points the system at the installed background.
+func writeSettings(r *root, p Profile, layout Layout, buildID string) (string, error) {
+	if layout.Settings == "" {
+		return "", nil
+	}
+	target := filepath.Join(r.dir, filepath.FromSlash(layout.Settings))
+	if err := r.mkdirAll(filepath.Dir(target)); err != nil {
+		return "", err
+	}
+	background := layout.systemPath(layout.Background())
+	var content string
+	switch p {
+	case ProfileWindows:
+		content = registryFragment(background)
+	case ProfileMacOS:
+		content = configurationProfile(background, buildID, layout.Product)
+	}
+	if err := writeText(r, target, content); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// registryFragment returns a .reg file that sets the desktop and lock screen image for all users through the
+// PersonalizationCSP keys. It is UTF-16LE with a byte order mark and CRLF line ends, as regedit writes it.
+func registryFragment(background string) string {
+	value := `"` + strings.ReplaceAll(background, `\`, `\\`) + `"`
+	lines := []string{
+		"Windows Registry Editor Version 5.00",
+		"",
+		`[HKEY_LOCAL_MACHINE\SOFTWARE\Microsoft\Windows\CurrentVersion\PersonalizationCSP]`,
+		`"DesktopImagePath"=` + value,
+		`"DesktopImageUrl"=` + value,
+		`"DesktopImageStatus"=dword:00000001`,
+		`"LockScreenImagePath"=` + value,
+		`"LockScreenImageUrl"=` + value,
+		`"LockScreenImageStatus"=dword:00000001`,
+		"",
+	}
+	units := utf16.Encode([]rune("\ufeff" + strings.Join(lines, "\r\n")))
+	b := make([]byte, 0, 2*len(units))
+	for _, u := range units {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	return string(b)
+}
+
+// configurationProfile returns a macOS configuration profile of product whose com.apple.desktop payload locks the
+// desktop picture to background. The payload UUIDs are derived from the identifiers, so a new build replaces the
+// installed profile instead of adding another one.
+func configurationProfile(background, buildID, product string) string {
+	identifier := "com." + product + ".desktop"
+	var b strings.Builder
+	esc := func(s string) string {
+		var e strings.Builder
+		xml.EscapeText(&e, []byte(s))
+		return e.String()
+	}
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadContent</key>
+	<array>
+		<dict>
+			<key>PayloadType</key>
+			<string>com.apple.desktop</string>
+			<key>PayloadIdentifier</key>
+			<string>%[1]s.picture</string>
+			<key>PayloadUUID</key>
+			<string>%[2]s</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+			<key>override-picture-path</key>
+			<string>%[3]s</string>
+		</dict>
+	</array>
+	<key>PayloadDisplayName</key>
+	<string>%[6]s desktop picture</string>
+	<key>PayloadDescription</key>
+	<string>Build %[4]s</string>
+	<key>PayloadIdentifier</key>
+	<string>%[1]s</string>
+	<key>PayloadScope</key>
+	<string>System</string>
+	<key>PayloadType</key>
+	<string>Configuration</string>
+	<key>PayloadUUID</key>
+	<string>%[5]s</string>
+	<key>PayloadVersion</key>
+	<integer>1</integer>
+</dict>
+</plist>
+`, identifier, nameUUID(identifier+".picture"), esc(background), esc(buildID), nameUUID(identifier), esc(strings.ToUpper(product)))
+	return b.String()
+}
+
+// nameUUID returns the version 5 UUID of name in the URL namespace of RFC 9562.
+func nameUUID(name string) string {
+	namespace := []byte{0x6b, 0xa7, 0xb8, 0x11, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+	sum := sha1.Sum(append(namespace, name...))
+	u := sum[:16]
+	u[6] = u[6]&0x0f | 0x50
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%X-%X-%X-%X-%X", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
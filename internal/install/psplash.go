@@ -0,0 +1,182 @@
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"path/filepath"
+	"strings"
+)
+
+// PsplashDir is the rootfs-relative directory of the generated psplash sources of DefaultProduct, for a Yocto psplash
+// recipe to copy into its source tree; Layout.PsplashDir gives it for every product.
+const PsplashDir = "usr/share/tssh/psplash"
+
+// psplashImagePrefix is the macro prefix psplash reads its image under; writing the header under the name of the
+// stock Poky image lets recipes replace it without patching psplash.c.
+const psplashImagePrefix = "POKY_IMG"
+
+// Psplash describes the image and colors of a psplash boot splash.
+type Psplash struct {
+	// Image is shown centered on the screen, usually at the panel resolution.
+	Image image.Image
+	// Background fills the screen around the image, Text colors boot messages, and Bar and BarBackground color the
+	// filled and unfilled parts of the progress bar.
+	Background, Text, Bar, BarBackground color.Color
+}
+
+// validate checks that there is an image to encode and that all colors are set.
+func (p Psplash) validate() error {
+	if p.Image == nil || p.Image.Bounds().Empty() {
+		return fmt.Errorf("install: psplash has no image")
+	}
+	if p.Background == nil || p.Text == nil || p.Bar == nil || p.BarBackground == nil {
+		return fmt.Errorf("install: psplash colors are incomplete")
+	}
+	return nil
+}
+
+// writePsplash writes psplash-poky-img.h, the PNG it was generated from, and psplash-colors.h into the PsplashDir of
+// layout. It returns the written paths in order, or an error if the layout has no psplash directory or encoding or
+// writing fails.
+func writePsplash(r *root, layout Layout, p Psplash) ([]string, error) {
+	if layout.PsplashDir == "" {
+		return nil, fmt.Errorf("install: the layout has no psplash directory")
+	}
+	dir := filepath.Join(r.dir, filepath.FromSlash(layout.PsplashDir))
+	if err := r.mkdirAll(dir); err != nil {
+		return nil, err
+	}
+
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, p.Image); err != nil {
+		return nil, fmt.Errorf("install: encode psplash png: %w", err)
+	}
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"psplash-poky-img.h", []byte(psplashImageHeader(psplashImagePrefix, p.Image))},
+		{"psplash-poky-img.png", pngData.Bytes()},
+		{"psplash-colors.h", []byte(psplashColorsHeader(p))},
+	}
+	var written []string
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := r.writeFile(path, f.data); err != nil {
+			return nil, fmt.Errorf("install: write psplash file %q: %w", path, err)
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// psplashImageHeader returns img as the run-length encoded RGBA C source that gdk-pixbuf-csource --macros --rle
+// writes and psplash compiles in.
+func psplashImageHeader(prefix string, img image.Image) string {
+	b := img.Bounds()
+	var s strings.Builder
+	s.WriteString("/* GdkPixbuf RGBA C-Source image dump 1-byte-run-length-encoded, generated by ts-release */\n\n")
+	fmt.Fprintf(&s, "#define %s_ROWSTRIDE (%d)\n", prefix, 4*b.Dx())
+	fmt.Fprintf(&s, "#define %s_WIDTH (%d)\n", prefix, b.Dx())
+	fmt.Fprintf(&s, "#define %s_HEIGHT (%d)\n", prefix, b.Dy())
+	fmt.Fprintf(&s, "#define %s_BYTES_PER_PIXEL (4) /* 3:RGB, 4:RGBA */\n", prefix)
+	fmt.Fprintf(&s, "#define %s_RLE_PIXEL_DATA ((uint8*) \\\n", prefix)
+	s.WriteString(cString(rleRGBA(img)))
+	s.WriteString(")\n")
+	return s.String()
+}
+
+// rleRGBA returns the RGBA pixels of img in the 1-byte run-length encoding of gdk-pixbuf-csource. Each run starts
+// with a byte n: n-128 copies of the following pixel if n is at least 128, or else n literal pixels.
+func rleRGBA(img image.Image) []byte {
+	b := img.Bounds()
+	pixels := make([][4]byte, 0, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			pixels = append(pixels, [4]byte{c.R, c.G, c.B, c.A})
+		}
+	}
+
+	var data []byte
+	for i := 0; i < len(pixels); {
+		run := 1
+		for i+run < len(pixels) && run < 127 && pixels[i+run] == pixels[i] {
+			run++
+		}
+		if run > 1 {
+			data = append(data, byte(128+run))
+			data = append(data, pixels[i][:]...)
+			i += run
+			continue
+		}
+		// A literal stretch ends where the next two pixels repeat, which encodes shorter as a run.
+		n := 1
+		for i+n < len(pixels) && n < 127 && !(i+n+1 < len(pixels) && pixels[i+n] == pixels[i+n+1]) {
+			n++
+		}
+		data = append(data, byte(n))
+		for _, px := range pixels[i : i+n] {
+			data = append(data, px[:]...)
+		}
+		i += n
+	}
+	return data
+}
+
+// cString returns data as a C string literal for a macro body, split into lines of at most about 70 characters that
+// end in a line continuation. Bytes other than
+// printable ASCII, quotes, backslashes, question marks (trigraphs), and digits after an octal escape are written as
+// octal escapes.
+func cString(data []byte) string {
+	var s strings.Builder
+	line := 0
+	octal := false
+	s.WriteByte('"')
+	for _, c := range data {
+		if line >= 70 {
+			s.WriteString("\" \\\n\"")
+			line, octal = 0, false
+		}
+		if c < ' ' || c > '~' || c == '"' || c == '\\' || c == '?' || (octal && c >= '0' && c <= '9') {
+			n, _ := fmt.Fprintf(&s, "\\%o", c)
+			line += n
+			octal = true
+			continue
+		}
+		s.WriteByte(c)
+		line++
+		octal = false
+	}
+	s.WriteString("\"")
+	return s.String()
+}
+
+// psplashColorsHeader returns psplash-colors.h with the colors of p, in the syntax of the header psplash ships.
+func psplashColorsHeader(p Psplash) string {
+	rgb := func(c color.Color) string {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		return fmt.Sprintf("0x%02x,0x%02x,0x%02x", n.R, n.G, n.B)
+	}
+	return fmt.Sprintf(`/* Generated by ts-release from the wallpaper theme. */
+#ifndef _HAVE_PSPLASH_COLORS_H
+#define _HAVE_PSPLASH_COLORS_H
+
+/* This is the overall background color */
+#define PSPLASH_BACKGROUND_COLOR %s
+
+/* This is the color of any text output */
+#define PSPLASH_TEXT_COLOR %s
+
+/* This is the color of the progress bar indicator */
+#define PSPLASH_BAR_COLOR %s
+
+/* This is the color of the progress bar background */
+#define PSPLASH_BAR_BACKGROUND_COLOR %s
+
+#endif
+`, rgb(p.Background), rgb(p.Text), rgb(p.Bar), rgb(p.BarBackground))
+}
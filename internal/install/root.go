@@ -0,0 +1,234 @@
+package install
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Resolver selects how Install resolves paths inside the rootfs. Every resolver follows symlinks that stay inside the
+// rootfs and fails on absolute symlinks and ".." that would leave it, so a rootfs with etc -> /etc cannot make Install
+// write to the build host.
+type Resolver string
+
+const (
+	// ResolverAuto uses ResolverOpenat2 where the kernel supports it and ResolverGo elsewhere.
+	ResolverAuto Resolver = "auto"
+	// ResolverGo resolves one path element at a time through os.Root, on every platform.
+	ResolverGo Resolver = "go"
+	// ResolverOpenat2 leaves resolution to the kernel with openat2 and RESOLVE_BENEATH, which Linux has since 5.6.
+	ResolverOpenat2 Resolver = "openat2"
+)
+
+// Resolvers returns all supported resolvers in a stable order for help output and validation.
+func Resolvers() []string {
+	return []string{string(ResolverAuto), string(ResolverGo), string(ResolverOpenat2)}
+}
+
+// ParseResolver validates a resolver name. Empty selects ResolverAuto; unknown names return an error.
+func ParseResolver(name string) (Resolver, error) {
+	if name == "" {
+		return ResolverAuto, nil
+	}
+	for _, r := range Resolvers() {
+		if name == r {
+			return Resolver(r), nil
+		}
+	}
+	return "", fmt.Errorf("install: unknown resolver %q (available: %s)", name, strings.Join(Resolvers(), ", "))
+}
+
+// root confines the files Install creates to the rootfs. Its methods take paths on the build host below dir, as the
+// writers compute them, and resolve them relative to the rootfs with the selected resolver.
+type root struct {
+	dir string
+	// beneath opens a rootfs-relative, slash-separated path with the flags and permissions of os.OpenFile.
+	beneath func(rel string, flag int, perm fs.FileMode) (*os.File, error)
+	// mkdir creates the directory rel, whose parent exists.
+	mkdir func(rel string, perm fs.FileMode) error
+	close func() error
+	// sync writes files under a temporary name and renames them into place, flushing files and the directories that
+	// hold them to stable storage.
+	sync bool
+	// backup, if set, saves files before writeFile overwrites them.
+	backup *backup
+}
+
+// openRoot opens the rootfs dir for writing with the resolver r. It returns an error if ResolverOpenat2 is selected
+// on a system without openat2.
+func openRoot(dir string, r Resolver) (*root, error) {
+	if r == ResolverOpenat2 || r == ResolverAuto {
+		rt, err := openat2Root(dir)
+		if err == nil || r == ResolverOpenat2 {
+			return rt, err
+		}
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return nil, err
+		}
+	}
+	osRoot, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, fmt.Errorf("install: open rootfs: %w", err)
+	}
+	return &root{
+		dir:     dir,
+		beneath: osRoot.OpenFile,
+		mkdir:   osRoot.Mkdir,
+		close:   osRoot.Close,
+	}, nil
+}
+
+// rel returns the host path relative to the rootfs, slash-separated.
+func (r *root) rel(host string) (string, error) {
+	rel, err := filepath.Rel(r.dir, host)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", fmt.Errorf("install: %q is outside the rootfs %q", host, r.dir)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// mkdirAll creates the directory at the host path and its missing parents inside the rootfs.
+func (r *root) mkdirAll(host string) error {
+	rel, err := r.rel(host)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+	elems := strings.Split(rel, "/")
+	for i := range elems {
+		dir := strings.Join(elems[:i+1], "/")
+		err := r.mkdir(dir, dirPerm)
+		if err == nil {
+			if r.sync {
+				if err := r.syncDir(path.Dir(dir)); err != nil {
+					return fmt.Errorf("install: create dir %q: %w", host, err)
+				}
+			}
+			continue
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("install: create dir %q: %w", host, err)
+		}
+		// An existing element may be a symlink, which has to lead to a directory inside the rootfs.
+		f, err := r.beneath(dir, os.O_RDONLY, 0)
+		if err != nil {
+			return fmt.Errorf("install: create dir %q: %w", host, err)
+		}
+		info, err := f.Stat()
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("install: create dir %q: %w", host, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("install: create dir %q: %s is not a directory", host, dir)
+		}
+	}
+	return nil
+}
+
+// open opens the file at the host path inside the rootfs for reading.
+func (r *root) open(host string) (*os.File, error) {
+	rel, err := r.rel(host)
+	if err != nil {
+		return nil, err
+	}
+	return r.beneath(rel, os.O_RDONLY, 0)
+}
+
+// writeFile writes data to the file at the host path inside the rootfs and overwrites any existing file. With sync, a
+// crash leaves either the old or the new file, never a truncated one.
+func (r *root) writeFile(host string, data []byte) error {
+	return r.writeStream(host, writeBytes(data))
+}
+
+// writeStream is writeFile for content that encode writes as it produces it, so an encoded image is not held in
+// memory next to the pixels it was encoded from.
+func (r *root) writeStream(host string, encode func(w io.Writer) error) error {
+	rel, err := r.rel(host)
+	if err != nil {
+		return err
+	}
+	if r.backup != nil {
+		if err := r.backup.save(r, rel); err != nil {
+			return err
+		}
+	}
+	return r.writeTo(rel, encode)
+}
+
+// write writes data to the file at the rootfs-relative path rel like writeFile, without saving a backup first.
+func (r *root) write(rel string, data []byte) error {
+	return r.writeTo(rel, writeBytes(data))
+}
+
+// writeBytes returns an encode function for writeStream and writeTo that writes data.
+func writeBytes(data []byte) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}
+}
+
+// writeTo writes what encode writes to the file at the rootfs-relative path rel like writeStream, without saving a
+// backup first. A failing encode leaves the file truncated, or with sync the temporary file.
+func (r *root) writeTo(rel string, encode func(w io.Writer) error) error {
+	name := rel
+	if r.sync {
+		name = rel + ".tssh-tmp"
+	}
+	file, err := r.beneath(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, filePerm)
+	if err != nil {
+		return err
+	}
+	buf := bufio.NewWriter(file)
+	err = encode(buf)
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err == nil && r.sync {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || !r.sync {
+		return err
+	}
+
+	dir, err := r.beneath(path.Dir(rel), os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	if err := renameAt(dir, path.Base(name), path.Base(rel)); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir flushes the directory rel inside the rootfs, so entries created or renamed in it survive a crash.
+func (r *root) syncDir(rel string) error {
+	dir, err := r.beneath(rel, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return syncDir(dir)
+}
+
+// syncDir flushes the open directory dir. Windows cannot flush directories and keeps renames durable on its own.
+func syncDir(dir *os.File) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	return dir.Sync()
+}
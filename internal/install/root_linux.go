@@ -0,0 +1,134 @@
+package install
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// sysOpenat2 is the openat2 system call number, which is the same on every architecture as it was added after the
+// tables were unified in Linux 5.1.
+const sysOpenat2 = 437
+
+// Flags of struct open_how.resolve from linux/openat2.h.
+const (
+	resolveNoMagiclinks = 0x02
+	resolveBeneath      = 0x08
+)
+
+// errEscapesRoot replaces the EXDEV with which RESOLVE_BENEATH reports an escape, whose message speaks of devices.
+var errEscapesRoot = errors.New("path escapes from the rootfs")
+
+// openHow is struct open_how from linux/openat2.h.
+type openHow struct {
+	flags   uint64
+	mode    uint64
+	resolve uint64
+}
+
+// openat2 opens rel below the directory dirfd with RESOLVE_BENEATH, so the kernel fails with EXDEV instead of
+// following a symlink or ".." out of it.
+func openat2(dirfd int, rel string, flag int, perm fs.FileMode) (int, error) {
+	p, err := syscall.BytePtrFromString(rel)
+	if err != nil {
+		return -1, err
+	}
+	how := openHow{
+		flags:   uint64(flag | syscall.O_CLOEXEC),
+		mode:    uint64(syscallMode(perm)),
+		resolve: resolveBeneath | resolveNoMagiclinks,
+	}
+	for {
+		fd, _, errno := syscall.Syscall6(sysOpenat2, uintptr(dirfd), uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&how)), unsafe.Sizeof(how), 0, 0)
+		// EAGAIN reports a concurrent rename that the kernel could not rule out as an escape.
+		if errno == syscall.EINTR || errno == syscall.EAGAIN {
+			continue
+		}
+		if errno == syscall.EXDEV {
+			return -1, &os.PathError{Op: "openat2", Path: rel, Err: errEscapesRoot}
+		}
+		if errno != 0 {
+			return -1, &os.PathError{Op: "openat2", Path: rel, Err: errno}
+		}
+		return int(fd), nil
+	}
+}
+
+// syscallMode returns the permission bits of perm for a system call.
+func syscallMode(perm fs.FileMode) uint32 {
+	return uint32(perm.Perm())
+}
+
+// openat2Root opens dir as a root that resolves paths with openat2. It returns an error wrapping
+// errors.ErrUnsupported if the kernel or a seccomp filter does not allow openat2.
+func openat2Root(dir string) (*root, error) {
+	dirFile, err := os.Open(dir)
+	if err != nil {
+		return nil, fmt.Errorf("install: open rootfs: %w", err)
+	}
+	dirfd := int(dirFile.Fd())
+	probe, err := openat2(dirfd, ".", syscall.O_RDONLY|syscall.O_DIRECTORY, 0)
+	if err != nil {
+		dirFile.Close()
+		if errors.Is(err, syscall.ENOSYS) || errors.Is(err, syscall.EPERM) {
+			return nil, fmt.Errorf("install: openat2 is not available: %w", errors.ErrUnsupported)
+		}
+		return nil, fmt.Errorf("install: open rootfs: %w", err)
+	}
+	syscall.Close(probe)
+
+	return &root{
+		dir: dir,
+		beneath: func(rel string, flag int, perm fs.FileMode) (*os.File, error) {
+			fd, err := openat2(dirfd, rel, flag, perm)
+			if err != nil {
+				return nil, err
+			}
+			return os.NewFile(uintptr(fd), path.Join(dir, rel)), nil
+		},
+		mkdir: func(rel string, perm fs.FileMode) error {
+			parent, err := openat2(dirfd, path.Dir(rel), syscall.O_RDONLY|syscall.O_DIRECTORY, 0)
+			if err != nil {
+				return err
+			}
+			defer syscall.Close(parent)
+			if err := syscall.Mkdirat(parent, path.Base(rel), syscallMode(perm)); err != nil {
+				return &os.PathError{Op: "mkdirat", Path: rel, Err: err}
+			}
+			return nil
+		},
+		close: dirFile.Close,
+	}, nil
+}
+
+// renameAt renames from to to inside the open directory dir, without resolving either name again.
+func renameAt(dir *os.File, from, to string) error {
+	fd := int(dir.Fd())
+	if err := syscall.Renameat(fd, from, fd, to); err != nil {
+		return &os.LinkError{Op: "renameat", Old: from, New: to, Err: err}
+	}
+	return nil
+}
+
+// atSymlinkNofollow is AT_SYMLINK_NOFOLLOW from linux/fcntl.h.
+const atSymlinkNofollow = 0x100
+
+// chtimesAt sets the access and modification times of name inside the open directory dir to t with utimensat, which
+// changes a symlink itself rather than its target.
+func chtimesAt(dir *os.File, name string, t time.Time) error {
+	p, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return err
+	}
+	ts := [2]syscall.Timespec{syscall.NsecToTimespec(t.UnixNano()), syscall.NsecToTimespec(t.UnixNano())}
+	_, _, errno := syscall.Syscall6(syscall.SYS_UTIMENSAT, dir.Fd(), uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&ts)), atSymlinkNofollow, 0, 0)
+	if errno != 0 {
+		return &os.PathError{Op: "utimensat", Path: name, Err: errno}
+	}
+	return nil
+}
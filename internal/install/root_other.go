@@ -0,0 +1,37 @@
+//go:build !linux
+
+package install
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// openat2Root reports that openat2 is a Linux system call, so ResolverAuto falls back to ResolverGo.
+func openat2Root(dir string) (*root, error) {
+	return nil, fmt.Errorf("install: openat2 needs Linux: %w", errors.ErrUnsupported)
+}
+
+// renameAt renames from to to inside dir, which was opened through the root. Without renameat in package syscall the
+// names are joined onto the path dir was opened with.
+func renameAt(dir *os.File, from, to string) error {
+	return os.Rename(filepath.Join(dir.Name(), from), filepath.Join(dir.Name(), to))
+}
+
+// chtimesAt sets the access and modification times of name inside dir, which was opened through the root, to t.
+// Package syscall cannot change the times of a symlink itself here, so symlinks keep theirs.
+func chtimesAt(dir *os.File, name string, t time.Time) error {
+	p := filepath.Join(dir.Name(), name)
+	info, err := os.Lstat(p)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return nil
+	}
+	return os.Chtimes(p, t, t)
+}
@@ -0,0 +1,121 @@
+package install
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SlideshowPath is the rootfs-relative location of the GNOME background slideshow XML of DefaultProduct; Layout.Slideshow
+// gives it for every product.
+const SlideshowPath = "usr/share/backgrounds/tssh/background-timed.xml"
+
+// Slideshow describes wallpaper variants that GNOME cycles through over the day.
+type Slideshow struct {
+	// Slides are shown in order starting at their Hour; the last one lasts until the first one's hour the next day.
+	Slides []Slide
+	// Transition is the cross-fade duration into the next slide; it is taken from the end of each slide.
+	Transition time.Duration
+}
+
+// Slide is one wallpaper variant of a Slideshow, written as background-<Name>.jpg next to background.jpg.
+type Slide struct {
+	// Name is the file name suffix: lowercase letters, digits, and dashes.
+	Name string
+	// Hour is the local hour in [0, 24) at which the slide starts; hours must increase.
+	Hour int
+	// Image is the rendered variant.
+	Image image.Image
+}
+
+var slideNamePattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// validate checks the slide names, images, and hours, and that the transition fits into the shortest slide.
+func (s Slideshow) validate() error {
+	if len(s.Slides) < 2 {
+		return fmt.Errorf("install: slideshow needs at least 2 slides, got %d", len(s.Slides))
+	}
+	seen := map[string]bool{}
+	for i, slide := range s.Slides {
+		if !slideNamePattern.MatchString(slide.Name) || seen[slide.Name] {
+			return fmt.Errorf("install: slide %d: invalid or duplicate name %q", i, slide.Name)
+		}
+		seen[slide.Name] = true
+		if slide.Image == nil {
+			return fmt.Errorf("install: slide %q: image is nil", slide.Name)
+		}
+		if slide.Hour < 0 || slide.Hour >= 24 || (i > 0 && slide.Hour <= s.Slides[i-1].Hour) {
+			return fmt.Errorf("install: slide %q: hour %d out of order or range [0, 24)", slide.Name, slide.Hour)
+		}
+	}
+	for i := range s.Slides {
+		if s.Transition < 0 || s.Transition >= s.span(i) {
+			return fmt.Errorf("install: slideshow transition %v must be shorter than slide %q (%v)", s.Transition, s.Slides[i].Name, s.span(i))
+		}
+	}
+	return nil
+}
+
+// span returns how long slide i is current, including its outgoing transition.
+func (s Slideshow) span(i int) time.Duration {
+	next := s.Slides[0].Hour + 24
+	if i+1 < len(s.Slides) {
+		next = s.Slides[i+1].Hour
+	}
+	return time.Duration(next-s.Slides[i].Hour) * time.Hour
+}
+
+// writeSlideshow writes the slide images and the slideshow XML to the rootfs-relative location xmlPath and next to it.
+// It returns the written paths in order, or an error if encoding or writing fails.
+func writeSlideshow(r *root, xmlPath string, s Slideshow, info ImageInfo, srgb bool) ([]string, error) {
+	slideshowPath := filepath.Join(r.dir, filepath.FromSlash(xmlPath))
+
+	var written, files []string
+	for _, slide := range s.Slides {
+		name := "background-" + slide.Name + ".jpg"
+		slidePath := filepath.Join(filepath.Dir(slideshowPath), name)
+		if err := writeJPEG(r, slidePath, slide.Image, info, srgb, JPEGQuality); err != nil {
+			return nil, err
+		}
+		written = append(written, slidePath)
+		// GNOME resolves the file references on the running system, where the rootfs is mounted at /.
+		files = append(files, "/"+path.Join(path.Dir(xmlPath), name))
+	}
+
+	if err := writeText(r, slideshowPath, slideshowXML(s, files)); err != nil {
+		return nil, err
+	}
+	return append(written, slideshowPath), nil
+}
+
+// slideshowXML returns the GNOME background XML that shows each file statically and then cross-fades to the next.
+// The start date lies in the past, so GNOME only uses its time of day and repeats the 24-hour cycle.
+func slideshowXML(s Slideshow, files []string) string {
+	var b strings.Builder
+	b.WriteString("<background>\n")
+	fmt.Fprintf(&b, "  <starttime>\n    <year>2024</year>\n    <month>1</month>\n    <day>1</day>\n"+
+		"    <hour>%d</hour>\n    <minute>0</minute>\n    <second>0</second>\n  </starttime>\n", s.Slides[0].Hour)
+	for i := range s.Slides {
+		from, to := escapeXML(files[i]), escapeXML(files[(i+1)%len(files)])
+		static := s.span(i) - s.Transition
+		fmt.Fprintf(&b, "  <static>\n    <duration>%.1f</duration>\n    <file>%s</file>\n  </static>\n", static.Seconds(), from)
+		if s.Transition > 0 {
+			fmt.Fprintf(&b, "  <transition type=\"overlay\">\n    <duration>%.1f</duration>\n    <from>%s</from>\n    <to>%s</to>\n  </transition>\n",
+				s.Transition.Seconds(), from, to)
+		}
+	}
+	b.WriteString("</background>\n")
+	return b.String()
+}
+
+// escapeXML escapes s for use as XML character data.
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package install
+
+import (
+	"fmt"
+	"image"
+)
+
+// Encoded sizes per pixel used to estimate the artifacts before writing them. They are at the upper end of what
+// photographic wallpapers encode to, so the check fails when a build would likely fill the file system, not when it
+// merely might.
+const (
+	jpegBytesPerPixel = 1
+	pngBytesPerPixel  = 3
+	bmpBytesPerPixel  = 4
+	// psplashBytesPerPixel covers the run-length encoded C source and the PNG beside it.
+	psplashBytesPerPixel = 8
+	// metadataSlack covers EXIF, XMP, and ICC profiles, text files, and the manifest.
+	metadataSlack = 256 << 10
+)
+
+// pixels returns the number of pixels of img, or zero for nil.
+func pixels(img image.Image) uint64 {
+	if img == nil {
+		return 0
+	}
+	size := img.Bounds().Size()
+	return uint64(size.X) * uint64(size.Y)
+}
+
+// estimateSize returns the approximate number of bytes Install writes for img and opts with layout.
+func estimateSize(img image.Image, opts Options, layout Layout) uint64 {
+	n := uint64(metadataSlack) + jpegBytesPerPixel*pixels(img)
+	if layout.BootSplash != "" {
+		n += bmpBytesPerPixel * pixels(img)
+	}
+	if opts.PNG {
+		n += pngBytesPerPixel * pixels(img)
+	}
+	if opts.Span != nil {
+		n += jpegBytesPerPixel * pixels(opts.Span.Image)
+		for _, m := range opts.Span.Monitors {
+			n += jpegBytesPerPixel * pixels(m)
+		}
+	}
+	if opts.Slideshow != nil {
+		for _, s := range opts.Slideshow.Slides {
+			n += jpegBytesPerPixel * pixels(s.Image)
+		}
+	}
+	if opts.Splash != nil && opts.Splash.Frames > 0 {
+		perPixel := uint64(pngBytesPerPixel)
+		if opts.Splash.Format == "bmp" || opts.Splash.Format == "qoi" {
+			perPixel = bmpBytesPerPixel
+		}
+		n += uint64(opts.Splash.Frames) * perPixel * pixels(img)
+	}
+	if opts.Board != nil {
+		// The RGB565 dump has two bytes per pixel.
+		n += (2 + bmpBytesPerPixel) * pixels(opts.Board.Image)
+	}
+	if opts.Psplash != nil {
+		n += psplashBytesPerPixel * pixels(opts.Psplash.Image)
+	}
+	return n
+}
+
+// checkSpace returns an error if the file system of rootFS has less space available than Install is estimated to
+// write. It does nothing where the available space cannot be determined.
+func checkSpace(rootFS string, need uint64) error {
+	avail, ok, err := availableSpace(rootFS)
+	if err != nil {
+		return fmt.Errorf("install: check free space: %w", err)
+	}
+	if ok && avail < need {
+		return fmt.Errorf("install: not enough space in %s: the artifacts need about %s, but only %s is available", rootFS, formatBytes(need), formatBytes(avail))
+	}
+	return nil
+}
+
+// formatBytes formats n in binary units with one decimal, e.g. "1.5 MiB".
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
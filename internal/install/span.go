@@ -0,0 +1,49 @@
+package install
+
+import (
+	"fmt"
+	"image"
+	"path/filepath"
+)
+
+// Span holds the outputs of a multi-monitor spanning wallpaper.
+type Span struct {
+	// Image is the canvas covering all monitors, written as background-span.jpg for GNOME's "spanned" option.
+	Image image.Image
+	// Monitors are the per-monitor crops, written as background-monitor-1.jpg and onwards.
+	Monitors []image.Image
+}
+
+// validate checks that the canvas and every monitor crop are present.
+func (s Span) validate() error {
+	if s.Image == nil {
+		return fmt.Errorf("install: span image is nil")
+	}
+	if len(s.Monitors) < 2 {
+		return fmt.Errorf("install: span needs at least 2 monitors, got %d", len(s.Monitors))
+	}
+	for i, m := range s.Monitors {
+		if m == nil {
+			return fmt.Errorf("install: span monitor %d image is nil", i+1)
+		}
+	}
+	return nil
+}
+
+// writeSpan writes the spanning canvas and the per-monitor crops into backgroundDir.
+// It returns the written paths in order, or an error if encoding or writing fails.
+func writeSpan(r *root, backgroundDir string, s Span, info ImageInfo, srgb bool) ([]string, error) {
+	spanPath := filepath.Join(backgroundDir, "background-span.jpg")
+	if err := writeJPEG(r, spanPath, s.Image, info, srgb, JPEGQuality); err != nil {
+		return nil, err
+	}
+	written := []string{spanPath}
+	for i, m := range s.Monitors {
+		path := filepath.Join(backgroundDir, fmt.Sprintf("background-monitor-%d.jpg", i+1))
+		if err := writeJPEG(r, path, m, info, srgb, JPEGQuality); err != nil {
+			return nil, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
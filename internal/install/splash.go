@@ -0,0 +1,213 @@
+package install
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickhildebrandt/ts-release/internal/qoi"
+	"golang.org/x/image/bmp"
+)
+
+// SplashDir is the rootfs-relative directory of the generated Plymouth theme of DefaultProduct; Layout.SplashDir gives
+// it for every product.
+const SplashDir = "usr/share/plymouth/themes/tssh"
+
+// Splash describes an animated boot splash written as numbered frames plus a Plymouth script theme.
+type Splash struct {
+	// Format is the frame file format: "png" (required by Plymouth), "bmp" (e.g. for fbsplash), or "qoi" (for splash
+	// daemons that decode it faster than they read a BMP from slow storage).
+	Format string
+	// FPS is the playback rate written to the theme script.
+	FPS float64
+	// LoopStart is the index of the first looping frame; earlier frames play once.
+	LoopStart int
+	// Frames is the number of frames Render produces, which the free space check counts before they are rendered.
+	Frames int
+	// Render produces the frames in order by calling emit once per frame.
+	Render func(emit func(frame image.Image) error) error
+	// Progress adds a progress bar to the script that fills with the boot progress; nil leaves it out. It is read
+	// after Render returns, so Render may still fill it in.
+	Progress *SplashProgress
+}
+
+// SplashProgress is the live boot progress bar of a Splash, drawn by the script in the region the frames leave empty.
+type SplashProgress struct {
+	// Rect is the region in frame pixels; the script scales it with the frames to the screen.
+	Rect image.Rectangle
+	// Bar colors the filled part of the bar and Background the unfilled part.
+	Bar, Background color.NRGBA
+}
+
+// SplashFormats returns the supported frame formats in a stable order for help output and validation.
+func SplashFormats() []string {
+	return []string{"png", "bmp", "qoi"}
+}
+
+// validate checks the format and playback rate.
+func (s Splash) validate() error {
+	known := false
+	for _, f := range SplashFormats() {
+		known = known || f == s.Format
+	}
+	if !known {
+		return fmt.Errorf("install: unknown splash format %q", s.Format)
+	}
+	if s.FPS <= 0 || s.FPS > 60 {
+		return fmt.Errorf("install: splash fps %v out of range (0, 60]", s.FPS)
+	}
+	if s.Render == nil {
+		return fmt.Errorf("install: splash has no frames")
+	}
+	return nil
+}
+
+// writeSplash renders the frames into the SplashDir of layout and writes the theme descriptor and script, named after
+// its product and shown as brand. It returns the written paths in order, or an error if the layout has no Plymouth
+// theme or rendering, encoding, or writing fails.
+func writeSplash(r *root, layout Layout, brand string, s Splash) ([]string, error) {
+	if layout.SplashDir == "" {
+		return nil, fmt.Errorf("install: the layout has no Plymouth theme directory")
+	}
+	dir := filepath.Join(r.dir, filepath.FromSlash(layout.SplashDir))
+	if err := r.mkdirAll(dir); err != nil {
+		return nil, err
+	}
+
+	var written, names []string
+	var size image.Point
+	err := s.Render(func(frame image.Image) error {
+		if len(names) == 0 {
+			size = frame.Bounds().Size()
+		}
+		name := fmt.Sprintf("frame-%04d.%s", len(names), s.Format)
+		path := filepath.Join(dir, name)
+		var buf bytes.Buffer
+		var err error
+		switch s.Format {
+		case "bmp":
+			err = bmp.Encode(&buf, frame)
+		case "qoi":
+			err = qoi.Encode(&buf, frame)
+		default:
+			err = png.Encode(&buf, frame)
+		}
+		if err != nil {
+			return fmt.Errorf("install: encode splash frame %q: %w", path, err)
+		}
+		if err := r.writeFile(path, buf.Bytes()); err != nil {
+			return fmt.Errorf("install: write splash frame %q: %w", path, err)
+		}
+		written = append(written, path)
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("install: splash rendered no frames")
+	}
+
+	if p := s.Progress; p != nil {
+		if p.Rect.Empty() || !p.Rect.In(image.Rectangle{Max: size}) {
+			return nil, fmt.Errorf("install: splash progress bar %v outside the %dx%d frames", p.Rect, size.X, size.Y)
+		}
+		for _, part := range []struct {
+			name string
+			col  color.NRGBA
+		}{{"progress-bar.png", p.Bar}, {"progress-track.png", p.Background}} {
+			path := filepath.Join(dir, part.name)
+			img := image.NewNRGBA(image.Rectangle{Max: p.Rect.Size()})
+			draw.Draw(img, img.Bounds(), image.NewUniform(part.col), image.Point{}, draw.Src)
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, img); err != nil {
+				return nil, fmt.Errorf("install: encode splash progress bar %q: %w", path, err)
+			}
+			if err := r.writeFile(path, buf.Bytes()); err != nil {
+				return nil, fmt.Errorf("install: write splash progress bar %q: %w", path, err)
+			}
+			written = append(written, path)
+		}
+	}
+
+	themePath := filepath.Join(dir, layout.Product+".plymouth")
+	if err := writeText(r, themePath, plymouthTheme(layout, brand)); err != nil {
+		return nil, err
+	}
+	scriptPath := filepath.Join(dir, layout.Product+".script")
+	if err := writeText(r, scriptPath, plymouthScript(names, s.FPS, s.LoopStart, size, s.Progress)); err != nil {
+		return nil, err
+	}
+	return append(written, themePath, scriptPath), nil
+}
+
+// plymouthTheme returns the theme descriptor of layout that selects the script module.
+func plymouthTheme(layout Layout, brand string) string {
+	return "[Plymouth Theme]\n" +
+		"Name=" + brand + "\n" +
+		"Description=" + brand + " boot splash generated by ts-release\n" +
+		"ModuleName=script\n" +
+		"\n" +
+		"[script]\n" +
+		"ImageDir=/" + layout.SplashDir + "\n" +
+		"ScriptFile=/" + path.Join(layout.SplashDir, layout.Product+".script") + "\n"
+}
+
+// plymouthScript returns a Plymouth script that plays the frames once up to loopStart and then loops the rest.
+// Plymouth calls the refresh function 50 times per second, so the frame index is derived from a tick counter.
+// With progress, it also stretches the progress bar images over the region of the frames of the given size.
+func plymouthScript(names []string, fps float64, loopStart int, size image.Point, progress *SplashProgress) string {
+	loopStart = max(0, min(loopStart, len(names)-1))
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by ts-release: %d frames at %g fps, looping from frame %d.\n", len(names), fps, loopStart)
+	fmt.Fprintf(&b, "frame_count = %d;\nloop_start = %d;\nfps = %g;\n\n", len(names), loopStart, fps)
+	for i, name := range names {
+		fmt.Fprintf(&b, "frames[%d] = Image(%q).Scale(Window.GetWidth(), Window.GetHeight());\n", i, name)
+	}
+	b.WriteString(`
+sprite = Sprite(frames[0]);
+sprite.SetZ(-100);
+ticks = 0;
+
+fun refresh_callback () {
+  index = Math.Int(ticks * fps / 50);
+  if (index >= frame_count)
+    index = loop_start + (index - loop_start) % (frame_count - loop_start);
+  sprite.SetImage(frames[index]);
+  ticks++;
+}
+
+Plymouth.SetRefreshFunction(refresh_callback);
+`)
+	if progress == nil {
+		return b.String()
+	}
+	r := progress.Rect
+	fmt.Fprintf(&b, "\n# Progress bar in the region the frames leave empty at %v.\n", r)
+	fmt.Fprintf(&b, "scale_x = Window.GetWidth() / %d;\nscale_y = Window.GetHeight() / %d;\n", size.X, size.Y)
+	fmt.Fprintf(&b, "progress_x = %d * scale_x;\nprogress_y = %d * scale_y;\n", r.Min.X, r.Min.Y)
+	fmt.Fprintf(&b, "progress_width = %d * scale_x;\nprogress_height = %d * scale_y;\n", r.Dx(), r.Dy())
+	b.WriteString(`
+track = Sprite(Image("progress-track.png").Scale(progress_width, progress_height));
+track.SetPosition(progress_x, progress_y, 10);
+bar_image = Image("progress-bar.png");
+bar = Sprite();
+bar.SetPosition(progress_x, progress_y, 11);
+
+fun progress_callback (duration, progress) {
+  width = Math.Int(progress_width * progress);
+  if (width > 0)
+    bar.SetImage(bar_image.Scale(width, progress_height));
+}
+
+Plymouth.SetBootProgressFunction(progress_callback);
+`)
+	return b.String()
+}
@@ -0,0 +1,40 @@
+package install
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// writeTimes sets the modification and access times of paths, which lie in rootFS, and of their parent directories
+// below rootFS to t, so rebuilds of the same tree are bit-identical when packed. Symlinks themselves are changed, not
+// their targets.
+func writeTimes(r *root, rootFS string, paths []string, t time.Time) error {
+	all, err := withParents(filepath.Clean(rootFS), paths)
+	if err != nil {
+		return fmt.Errorf("install: set times: %w", err)
+	}
+	for _, p := range all {
+		if err := r.chtimes(p, t); err != nil {
+			return fmt.Errorf("install: set times of %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// chtimes sets the times of the entry at the host path inside the rootfs to t. Its parent directory is resolved
+// inside the rootfs, and the entry itself is not followed if it is a symlink.
+func (r *root) chtimes(host string, t time.Time) error {
+	rel, err := r.rel(host)
+	if err != nil {
+		return err
+	}
+	dir, err := r.beneath(path.Dir(rel), os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return chtimesAt(dir, path.Base(rel), t)
+}
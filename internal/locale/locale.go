@@ -0,0 +1,95 @@
+package locale
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed catalogs/*.json
+var catalogFS embed.FS
+
+// Default is the language used when no locale is requested.
+const Default = "en"
+
+// Catalog holds the translated fixed strings and date layout for one language.
+// The zero value behaves like the English catalog.
+type Catalog struct {
+	lang       string
+	dateLayout string
+	messages   map[string]string
+}
+
+type catalogFile struct {
+	DateLayout string            `json:"date_layout"`
+	Messages   map[string]string `json:"messages"`
+}
+
+// Languages returns the embedded catalog languages in sorted order.
+func Languages() []string {
+	entries, err := catalogFS.ReadDir("catalogs")
+	if err != nil {
+		return nil
+	}
+	langs := make([]string, 0, len(entries))
+	for _, e := range entries {
+		langs = append(langs, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Load returns the catalog for a POSIX or BCP 47 style locale such as "de_DE", "de-DE.UTF-8", or "de".
+// Only the language part is used; an empty name selects Default and unknown languages are an error.
+func Load(name string) (Catalog, error) {
+	lang := languageOf(name)
+	if lang == "" {
+		lang = Default
+	}
+	data, err := catalogFS.ReadFile(path.Join("catalogs", lang+".json"))
+	if err != nil {
+		return Catalog{}, fmt.Errorf("locale: unsupported locale %q (available: %s)", name, strings.Join(Languages(), ", "))
+	}
+	var file catalogFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Catalog{}, fmt.Errorf("locale: parse catalog %q: %w", lang, err)
+	}
+	return Catalog{lang: lang, dateLayout: file.DateLayout, messages: file.Messages}, nil
+}
+
+// languageOf extracts the lowercase language subtag from a locale name.
+func languageOf(name string) string {
+	name, _, _ = strings.Cut(name, ".")
+	name, _, _ = strings.Cut(name, "@")
+	lang, _, _ := strings.Cut(strings.ReplaceAll(name, "-", "_"), "_")
+	return strings.ToLower(lang)
+}
+
+// Language returns the catalog's language code.
+func (c Catalog) Language() string {
+	if c.lang == "" {
+		return Default
+	}
+	return c.lang
+}
+
+// T translates a fixed English string, falling back to the input when the catalog has no entry.
+func (c Catalog) T(msg string) string {
+	if translated, ok := c.messages[msg]; ok && translated != "" {
+		return translated
+	}
+	return msg
+}
+
+// FormatDate formats t in UTC using the catalog's numeric date layout.
+func (c Catalog) FormatDate(t time.Time) string {
+	layout := c.dateLayout
+	if layout == "" {
+		layout = time.DateOnly
+	}
+	return t.UTC().Format(layout)
+}
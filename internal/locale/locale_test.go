@@ -0,0 +1,72 @@
+package locale
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestLoad_AllCatalogsParseAndTranslate verifies every embedded catalog parses and defines the fixed strings.
+// The test fails if a catalog is malformed or leaves a required string untranslated by key.
+func TestLoad_AllCatalogsParseAndTranslate(t *testing.T) {
+	langs := Languages()
+	if len(langs) < 6 {
+		t.Fatalf("expected at least 6 languages, got %v", langs)
+	}
+	for _, lang := range langs {
+		c, err := Load(lang)
+		if err != nil {
+			t.Fatalf("%s: Load error: %v", lang, err)
+		}
+		for _, key := range []string{"build", "build unknown"} {
+			if _, ok := c.messages[key]; !ok {
+				t.Fatalf("%s: missing message %q", lang, key)
+			}
+		}
+		if c.dateLayout == "" {
+			t.Fatalf("%s: missing date layout", lang)
+		}
+	}
+}
+
+// TestLoad_LocaleNameVariants expects POSIX and BCP 47 spellings to resolve to the same language.
+func TestLoad_LocaleNameVariants(t *testing.T) {
+	for _, name := range []string{"de", "de_DE", "de-AT", "de_DE.UTF-8", "DE_de@euro"} {
+		c, err := Load(name)
+		if err != nil {
+			t.Fatalf("%s: Load error: %v", name, err)
+		}
+		if c.Language() != "de" {
+			t.Fatalf("%s: got language %q", name, c.Language())
+		}
+	}
+	if _, err := Load("xx_XX"); err == nil {
+		t.Fatalf("expected error for unsupported locale")
+	} else if !strings.Contains(err.Error(), "unsupported locale") {
+		t.Fatalf("unexpected error: %q", err.Error())
+	}
+}
+
+// TestCatalog_TranslateAndFormatDate checks translation, fallback, and locale date layouts, including the zero value.
+func TestCatalog_TranslateAndFormatDate(t *testing.T) {
+	day := time.Date(2026, 1, 4, 13, 0, 0, 0, time.UTC)
+
+	de, err := Load("de_DE")
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if got := de.T("build unknown"); got != "Build unbekannt" {
+		t.Fatalf("T: got %q", got)
+	}
+	if got := de.T("no such message"); got != "no such message" {
+		t.Fatalf("T fallback: got %q", got)
+	}
+	if got := de.FormatDate(day); got != "04.01.2026" {
+		t.Fatalf("FormatDate: got %q", got)
+	}
+
+	var zero Catalog
+	if zero.Language() != Default || zero.T("build") != "build" || zero.FormatDate(day) != "2026-01-04" {
+		t.Fatalf("unexpected zero-value behavior")
+	}
+}
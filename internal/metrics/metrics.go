@@ -0,0 +1,246 @@
+// Package metrics keeps counters and histograms and writes them in the Prometheus text exposition format, for a
+// /metrics endpoint or a node_exporter textfile. It covers what ts-release records without the Prometheus client
+// library: labelled counters and histograms with fixed buckets.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Registry holds metrics in registration order. It is safe for concurrent use and serves them as an http.Handler.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// metric is a counter or histogram that writes its samples under the registry's lock.
+type metric interface {
+	write(b *bytes.Buffer)
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// family holds what counters and histograms share: the name, help, label names, and the series by label values.
+type family[T any] struct {
+	reg    *Registry
+	name   string
+	help   string
+	labels []string
+	series map[string]*T
+	values map[string][]string
+}
+
+// get returns the series of the label values, creating it with create. It panics if the number of values does not
+// match the label names, which is a programming error.
+func (f *family[T]) get(values []string, create func() *T) *T {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s: got %d label values for %d labels", f.name, len(values), len(f.labels)))
+	}
+	key := strings.Join(values, "\x00")
+	s, ok := f.series[key]
+	if !ok {
+		s = create()
+		f.series[key] = s
+		f.values[key] = slices.Clone(values)
+	}
+	return s
+}
+
+// header writes the HELP and TYPE lines.
+func (f *family[T]) header(b *bytes.Buffer, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", f.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.help), f.name, kind)
+}
+
+// keys returns the series keys sorted by label values, so the output is stable.
+func (f *family[T]) keys() []string {
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// labelPairs formats the label names with values, plus an extra pair if name is not empty.
+func (f *family[T]) labelPairs(values []string, name, value string) string {
+	var pairs []string
+	for i, l := range f.labels {
+		pairs = append(pairs, l+`="`+escapeLabel(values[i])+`"`)
+	}
+	if name != "" {
+		pairs = append(pairs, name+`="`+escapeLabel(value)+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a monotonically increasing value per combination of label values.
+type Counter struct {
+	family[float64]
+}
+
+// Counter registers a counter. Its name should end in _total.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{family[float64]{reg: r, name: name, help: help, labels: labels, series: map[string]*float64{}, values: map[string][]string{}}}
+	r.add(c)
+	return c
+}
+
+// Add increases the counter of the label values by v, which must not be negative.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: %s: negative increment %v", c.name, v))
+	}
+	c.reg.mu.Lock()
+	defer c.reg.mu.Unlock()
+	*c.get(values, func() *float64 { return new(float64) }) += v
+}
+
+// Inc increases the counter of the label values by one.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *Counter) write(b *bytes.Buffer) {
+	c.header(b, "counter")
+	for _, k := range c.keys() {
+		fmt.Fprintf(b, "%s%s %s\n", c.name, c.labelPairs(c.values[k], "", ""), formatFloat(*c.series[k]))
+	}
+}
+
+// Histogram counts observations in cumulative buckets per combination of label values.
+type Histogram struct {
+	family[histogramSeries]
+	buckets []float64
+}
+
+// histogramSeries holds the bucket counts, sum, and count of one series.
+type histogramSeries struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Histogram registers a histogram with the upper bounds of its buckets in increasing order; the +Inf bucket is added.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !slices.IsSorted(buckets) {
+		panic(fmt.Sprintf("metrics: %s: buckets are not sorted", name))
+	}
+	h := &Histogram{
+		family:  family[histogramSeries]{reg: r, name: name, help: help, labels: labels, series: map[string]*histogramSeries{}, values: map[string][]string{}},
+		buckets: buckets,
+	}
+	r.add(h)
+	return h
+}
+
+// Observe records v in the series of the label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.reg.mu.Lock()
+	defer h.reg.mu.Unlock()
+	s := h.get(values, func() *histogramSeries { return &histogramSeries{counts: make([]uint64, len(h.buckets))} })
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *Histogram) write(b *bytes.Buffer) {
+	h.header(b, "histogram")
+	for _, k := range h.keys() {
+		s, values := h.series[k], h.values[k]
+		for i, upper := range h.buckets {
+			fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, h.labelPairs(values, "le", formatFloat(upper)), s.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, h.labelPairs(values, "le", "+Inf"), s.count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", h.name, h.labelPairs(values, "", ""), formatFloat(s.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", h.name, h.labelPairs(values, "", ""), s.count)
+	}
+}
+
+// add appends a metric to the registry.
+func (r *Registry) add(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteTo writes all metrics in the text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+	r.mu.Lock()
+	for _, m := range r.metrics {
+		m.write(&b)
+	}
+	r.mu.Unlock()
+	return b.WriteTo(w)
+}
+
+// ServeHTTP answers a scrape with all metrics.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteTo(w)
+}
+
+// WriteFile writes all metrics to path, replacing it atomically so the node_exporter textfile collector never reads a
+// partial file.
+func (r *Registry) WriteFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".metrics-*")
+	if err != nil {
+		return fmt.Errorf("metrics: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := r.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("metrics: %w", err)
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("metrics: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("metrics: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("metrics: %w", err)
+	}
+	return nil
+}
+
+// escapeLabel escapes a label value for the text format.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// formatFloat formats a sample value, with +Inf and -Inf spelled as the text format expects.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
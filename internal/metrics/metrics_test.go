@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRegistry_WritesTextFormat expects counters and histograms to be written in the Prometheus text format with
+// escaped labels, cumulative buckets, and series sorted by label values, over HTTP and to a file. The test fails if
+// a line differs from the format or the file is not written.
+func TestRegistry_WritesTextFormat(t *testing.T) {
+	r := NewRegistry()
+	failures := r.Counter("fetch_failures_total", "Failed fetches by category.", "kind")
+	bytes := r.Counter("downloaded_bytes_total", "Bytes downloaded.")
+	duration := r.Histogram("render_seconds", "Render duration.", []float64{0.5, 2}, "operation")
+	failures.Inc("network")
+	failures.Add(2, "dns")
+	failures.Inc(`say "hi"`)
+	bytes.Add(1536)
+	duration.Observe(0.25, "render")
+	duration.Observe(1, "render")
+	duration.Observe(3, "render")
+
+	want := `# HELP fetch_failures_total Failed fetches by category.
+# TYPE fetch_failures_total counter
+fetch_failures_total{kind="dns"} 2
+fetch_failures_total{kind="network"} 1
+fetch_failures_total{kind="say \"hi\""} 1
+# HELP downloaded_bytes_total Bytes downloaded.
+# TYPE downloaded_bytes_total counter
+downloaded_bytes_total 1536
+# HELP render_seconds Render duration.
+# TYPE render_seconds histogram
+render_seconds_bucket{operation="render",le="0.5"} 1
+render_seconds_bucket{operation="render",le="2"} 2
+render_seconds_bucket{operation="render",le="+Inf"} 3
+render_seconds_sum{operation="render"} 4.25
+render_seconds_count{operation="render"} 3
+`
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Body.String() != want || rec.Header().Get("Content-Type") != ContentType {
+		t.Fatalf("scrape:\n%s\nwant:\n%s", rec.Body.String(), want)
+	}
+
+	path := filepath.Join(t.TempDir(), "ts-release.prom")
+	if err := r.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != want {
+		t.Fatalf("file: %v\n%s", err, got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for a wrong number of label values")
+		}
+	}()
+	failures.Inc()
+}
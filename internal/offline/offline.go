@@ -0,0 +1,289 @@
+// Package offline packs approved backgrounds, fonts, and themes into a signed archive on an online machine and
+// unpacks it in an air-gapped build environment after verifying the signature and every file's checksum.
+//
+// An archive is a tar file whose first entry is manifest.json, followed by manifest.json.sig (the raw Ed25519
+// signature of the manifest) and the files the manifest lists with their SHA-256.
+package offline
+
+import (
+	"archive/tar"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// ManifestName and SignatureName are the first two entries of an archive; ManifestName is also kept in the
+// unpacked directory.
+const (
+	ManifestName  = "manifest.json"
+	SignatureName = "manifest.json.sig"
+)
+
+// maxFileSize bounds each archived file, so a damaged archive cannot exhaust the disk while unpacking.
+const maxFileSize = 256 << 20
+
+// Kinds of archived files; each kind is unpacked into the directory of the same name.
+const (
+	KindBackground = "backgrounds"
+	KindFont       = "fonts"
+	KindTheme      = "themes"
+)
+
+// ErrSignature is wrapped when an archive's signature does not verify with the given public key.
+var ErrSignature = errors.New("invalid signature")
+
+// File is one archived file with the metadata recorded for it.
+type File struct {
+	// Kind is KindBackground, KindFont, or KindTheme.
+	Kind string `json:"kind"`
+	// Name is a flat file name, unique within its kind.
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+	// SourceURL, WallpaperID, and Attribution describe where a background was downloaded from.
+	SourceURL   string `json:"source_url,omitempty"`
+	WallpaperID string `json:"wallpaper_id,omitempty"`
+	Attribution string `json:"attribution,omitempty"`
+
+	// Data is the content; it is not part of the manifest.
+	Data []byte `json:"-"`
+}
+
+// Path returns the file's path inside the archive and the unpacked directory, e.g. "backgrounds/a.jpg".
+func (f File) Path() string {
+	return f.Kind + "/" + f.Name
+}
+
+// Manifest lists the files of an archive.
+type Manifest struct {
+	Created time.Time `json:"created"`
+	Files   []File    `json:"files"`
+}
+
+// Backgrounds returns the manifest's background files in archive order.
+func (m Manifest) Backgrounds() []File {
+	var files []File
+	for _, f := range m.Files {
+		if f.Kind == KindBackground {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// Create writes an archive of files signed with key. It returns an error for unknown kinds, names that are not flat
+// file names, or duplicates.
+func Create(w io.Writer, key ed25519.PrivateKey, created time.Time, files []File) error {
+	manifest := Manifest{Created: created.UTC()}
+	seen := map[string]bool{}
+	for _, f := range files {
+		if err := f.validate(); err != nil {
+			return err
+		}
+		if seen[f.Path()] {
+			return fmt.Errorf("offline: duplicate file %q", f.Path())
+		}
+		seen[f.Path()] = true
+		sum := sha256.Sum256(f.Data)
+		f.SHA256 = hex.EncodeToString(sum[:])
+		manifest.Files = append(manifest.Files, f)
+	}
+	raw, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("offline: %w", err)
+	}
+
+	tw := tar.NewWriter(w)
+	entries := []struct {
+		name string
+		data []byte
+	}{{ManifestName, raw}, {SignatureName, ed25519.Sign(key, raw)}}
+	for _, f := range files {
+		entries = append(entries, struct {
+			name string
+			data []byte
+		}{f.Path(), f.Data})
+	}
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.data)), ModTime: manifest.Created, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("offline: write %s: %w", e.name, err)
+		}
+		if _, err := tw.Write(e.data); err != nil {
+			return fmt.Errorf("offline: write %s: %w", e.name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("offline: %w", err)
+	}
+	return nil
+}
+
+// Extract verifies an archive with pub and unpacks its files and manifest into dir. Nothing is written unless the
+// signature and every file's checksum verified. It returns an error wrapping
+// ErrSignature for archives that were not signed with the matching key or were altered.
+func Extract(r io.Reader, pub ed25519.PublicKey, dir string) (Manifest, error) {
+	tr := tar.NewReader(r)
+	raw, err := readEntry(tr, ManifestName)
+	if err != nil {
+		return Manifest{}, err
+	}
+	sig, err := readEntry(tr, SignatureName)
+	if err != nil {
+		return Manifest{}, err
+	}
+	if !ed25519.Verify(pub, raw, sig) {
+		return Manifest{}, fmt.Errorf("offline: %w", ErrSignature)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("offline: decode manifest: %w", err)
+	}
+	expected := map[string]File{}
+	for _, f := range manifest.Files {
+		if err := f.validate(); err != nil {
+			return Manifest{}, err
+		}
+		expected[f.Path()] = f
+	}
+
+	// Files are only written once the whole archive verified, so a rejected archive leaves dir untouched.
+	var verified []File
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Manifest{}, fmt.Errorf("offline: read archive: %w", err)
+		}
+		f, ok := expected[header.Name]
+		if !ok {
+			return Manifest{}, fmt.Errorf("offline: %s is not listed in the manifest", header.Name)
+		}
+		delete(expected, header.Name)
+		data, err := io.ReadAll(io.LimitReader(tr, maxFileSize+1))
+		if err != nil {
+			return Manifest{}, fmt.Errorf("offline: read %s: %w", header.Name, err)
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != f.SHA256 {
+			return Manifest{}, fmt.Errorf("offline: %s: checksum mismatch", header.Name)
+		}
+		f.Data = data
+		verified = append(verified, f)
+	}
+	for name := range expected {
+		return Manifest{}, fmt.Errorf("offline: %s is missing from the archive", name)
+	}
+	for _, f := range verified {
+		target := filepath.Join(dir, filepath.FromSlash(f.Path()))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return Manifest{}, fmt.Errorf("offline: %w", err)
+		}
+		if err := os.WriteFile(target, f.Data, 0o644); err != nil {
+			return Manifest{}, fmt.Errorf("offline: %w", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, ManifestName), raw, 0o644); err != nil {
+		return Manifest{}, fmt.Errorf("offline: %w", err)
+	}
+	return manifest, nil
+}
+
+// Load reads the manifest of a directory unpacked by Extract.
+func Load(dir string) (Manifest, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		return Manifest{}, fmt.Errorf("offline: %w", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("offline: %s: %w", dir, err)
+	}
+	return manifest, nil
+}
+
+// LoadPrivateKey reads a PEM-encoded PKCS #8 Ed25519 private key, e.g. from `openssl genpkey -algorithm ed25519`.
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("offline: %s: %w", path, err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("offline: %s: not an Ed25519 key", path)
+	}
+	return edKey, nil
+}
+
+// LoadPublicKey reads a PEM-encoded PKIX Ed25519 public key, e.g. from `openssl pkey -pubout`.
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("offline: %s: %w", path, err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("offline: %s: not an Ed25519 key", path)
+	}
+	return edKey, nil
+}
+
+// readPEM returns the first PEM block of path, which must have the given type.
+func readPEM(path, blockType string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("offline: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("offline: %s: no %s PEM block", path, blockType)
+	}
+	return block, nil
+}
+
+// readEntry reads the next archive entry, which must be called name.
+func readEntry(tr *tar.Reader, name string) ([]byte, error) {
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("offline: read %s: %w", name, err)
+	}
+	if header.Name != name {
+		return nil, fmt.Errorf("offline: archive starts with %q, want %s", header.Name, name)
+	}
+	data, err := io.ReadAll(io.LimitReader(tr, maxFileSize))
+	if err != nil {
+		return nil, fmt.Errorf("offline: read %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// validate checks the kind and that the name cannot escape the kind's directory.
+func (f File) validate() error {
+	switch f.Kind {
+	case KindBackground, KindFont, KindTheme:
+	default:
+		return fmt.Errorf("offline: unknown kind %q", f.Kind)
+	}
+	if f.Name == "" || f.Name == "." || f.Name == ".." || path.Base(f.Name) != f.Name || filepath.Base(f.Name) != f.Name {
+		return fmt.Errorf("offline: %q is not a flat file name", f.Name)
+	}
+	return nil
+}
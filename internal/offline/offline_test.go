@@ -0,0 +1,95 @@
+package offline
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCreateExtract_VerifiesSignatureAndChecksums expects an archive to unpack with its manifest when the public
+// key matches, and to be rejected for another key, altered content, or names escaping the directory.
+// The test fails if an unverified or altered archive writes any file.
+func TestCreateExtract_VerifiesSignatureAndChecksums(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []File{
+		{Kind: KindBackground, Name: "wallhaven-k7q1vd.jpg", Data: []byte("jpeg"), SourceURL: "https://w.wallhaven.cc/full/k7/wallhaven-k7q1vd.jpg", WallpaperID: "k7q1vd"},
+		{Kind: KindFont, Name: "Brand.ttf", Data: []byte("TTF-DATA")},
+		{Kind: KindTheme, Name: "brand.json", Data: []byte("{}")},
+	}
+	var archive bytes.Buffer
+	if err := Create(&archive, key, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), files); err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+
+	dir := t.TempDir()
+	manifest, err := Extract(bytes.NewReader(archive.Bytes()), pub, dir)
+	if err != nil {
+		t.Fatalf("Extract error: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "fonts", "Brand.ttf")); err != nil || string(data) != "TTF-DATA" {
+		t.Fatalf("unpacked font: got %q, %v", data, err)
+	}
+	loaded, err := Load(dir)
+	if err != nil || len(loaded.Backgrounds()) != 1 || loaded.Backgrounds()[0].WallpaperID != "k7q1vd" || len(manifest.Files) != 3 {
+		t.Fatalf("Load: got %+v, %v", loaded, err)
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	empty := t.TempDir()
+	if _, err := Extract(bytes.NewReader(archive.Bytes()), otherPub, empty); !errors.Is(err, ErrSignature) {
+		t.Fatalf("other key: got %v, want ErrSignature", err)
+	}
+	altered := bytes.Replace(archive.Bytes(), []byte("TTF-DATA"), []byte("TTF-D4TA"), 1)
+	if _, err := Extract(bytes.NewReader(altered), pub, empty); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("altered file: got %v", err)
+	}
+	if entries, _ := os.ReadDir(empty); len(entries) != 0 {
+		t.Fatalf("rejected archives wrote %d entries", len(entries))
+	}
+
+	for _, f := range []File{{Kind: KindFont, Name: "../evil.ttf"}, {Kind: KindFont, Name: ".."}, {Kind: "scripts", Name: "x.sh"}} {
+		if err := Create(&bytes.Buffer{}, key, time.Now(), []File{f}); err == nil {
+			t.Fatalf("Create accepted %+v", f)
+		}
+	}
+}
+
+// TestLoadKeys_OpenSSLFormat expects Ed25519 keys in the PEM formats openssl writes to load, and other PEM blocks to
+// be rejected. The test fails if a PKCS #8 private or PKIX public key is not understood.
+func TestLoadKeys_OpenSSLFormat(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	pubDER, _ := x509.MarshalPKIXPublicKey(pub)
+	keyPath, pubPath := filepath.Join(dir, "key.pem"), filepath.Join(dir, "pub.pem")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded, err := LoadPrivateKey(keyPath); err != nil || !loaded.Equal(key) {
+		t.Fatalf("LoadPrivateKey: %v", err)
+	}
+	if loaded, err := LoadPublicKey(pubPath); err != nil || !loaded.Equal(pub) {
+		t.Fatalf("LoadPublicKey: %v", err)
+	}
+	if _, err := LoadPublicKey(keyPath); err == nil {
+		t.Fatalf("expected an error for a private key given as public key")
+	}
+}
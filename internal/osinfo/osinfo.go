@@ -0,0 +1,221 @@
+// Package osinfo reads the identity of the system in a rootfs from its os-release, hostname, and machine-info files,
+// and the versions of its kernels, so the wallpaper and its metadata name the image they are installed into.
+package osinfo
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nickhildebrandt/ts-release/internal/install"
+)
+
+// Rootfs-relative locations of the identity files. os-release is read from OSReleasePath, falling back to
+// OSReleaseFallbackPath as os-release(5) prescribes.
+const (
+	OSReleasePath         = "etc/os-release"
+	OSReleaseFallbackPath = "usr/lib/os-release"
+	HostnamePath          = "etc/hostname"
+	MachineInfoPath       = "etc/machine-info"
+)
+
+// Info describes the system in a rootfs. Fields whose file or key is missing are empty.
+type Info struct {
+	// PrettyName, Name, ID, Version, VersionID, Variant, VariantID, ImageID, and ImageVersion are the os-release
+	// values of the same names. PrettyName defaults to "Linux" if os-release exists but does not set it.
+	PrettyName   string
+	Name         string
+	ID           string
+	Version      string
+	VersionID    string
+	Variant      string
+	VariantID    string
+	ImageID      string
+	ImageVersion string
+	// Hostname is the static hostname in etc/hostname.
+	Hostname string
+	// PrettyHostname, Deployment, and Location are the machine-info values PRETTY_HOSTNAME, DEPLOYMENT, and LOCATION.
+	PrettyHostname string
+	Deployment     string
+	Location       string
+}
+
+// Line returns the non-empty ones of PrettyName, VariantID, and PrettyHostname or else Hostname, joined by " · ",
+// e.g. "Debian GNU/Linux 12 (bookworm) · kiosk · lobby-07".
+func (i Info) Line() string {
+	host := i.PrettyHostname
+	if host == "" {
+		host = i.Hostname
+	}
+	var parts []string
+	for _, s := range []string{i.PrettyName, i.VariantID, host} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// Read reads the identity files of rootFS, resolving symlinks inside it with resolver. Missing files are skipped; it
+// returns an error if none of them names the system, or a file cannot be read or parsed.
+func Read(rootFS string, resolver install.Resolver) (Info, error) {
+	var info Info
+	data, ok, err := install.ReadFile(rootFS, OSReleasePath, resolver)
+	if err == nil && !ok {
+		data, ok, err = install.ReadFile(rootFS, OSReleaseFallbackPath, resolver)
+	}
+	if err != nil {
+		return Info{}, fmt.Errorf("osinfo: %w", err)
+	}
+	if ok {
+		values, err := Parse(string(data))
+		if err != nil {
+			return Info{}, fmt.Errorf("osinfo: os-release: %w", err)
+		}
+		info.PrettyName = values["PRETTY_NAME"]
+		if info.PrettyName == "" {
+			info.PrettyName = "Linux"
+		}
+		info.Name = values["NAME"]
+		info.ID = values["ID"]
+		info.Version = values["VERSION"]
+		info.VersionID = values["VERSION_ID"]
+		info.Variant = values["VARIANT"]
+		info.VariantID = values["VARIANT_ID"]
+		info.ImageID = values["IMAGE_ID"]
+		info.ImageVersion = values["IMAGE_VERSION"]
+	}
+
+	data, ok, err = install.ReadFile(rootFS, HostnamePath, resolver)
+	if err != nil {
+		return Info{}, fmt.Errorf("osinfo: %w", err)
+	}
+	if ok {
+		info.Hostname = hostname(string(data))
+	}
+
+	data, ok, err = install.ReadFile(rootFS, MachineInfoPath, resolver)
+	if err != nil {
+		return Info{}, fmt.Errorf("osinfo: %w", err)
+	}
+	if ok {
+		values, err := Parse(string(data))
+		if err != nil {
+			return Info{}, fmt.Errorf("osinfo: machine-info: %w", err)
+		}
+		info.PrettyHostname = values["PRETTY_HOSTNAME"]
+		info.Deployment = values["DEPLOYMENT"]
+		info.Location = values["LOCATION"]
+	}
+
+	if info.Line() == "" {
+		return Info{}, fmt.Errorf("osinfo: %s has no %s, %s, or %s naming the system", rootFS, OSReleasePath, HostnamePath, MachineInfoPath)
+	}
+	return info, nil
+}
+
+// hostname returns the first line of an etc/hostname file that is neither empty nor a comment.
+func hostname(data string) string {
+	for line := range strings.Lines(data) {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+	return ""
+}
+
+// Parse parses the KEY=value lines of an os-release or machine-info file. Values may be quoted with double or single
+// quotes; inside double quotes, a backslash escapes $, ", \, and `. Empty lines and comments are skipped. It returns
+// an error for a line without "=" or with an unterminated quote.
+func Parse(data string) (map[string]string, error) {
+	values := map[string]string{}
+	n := 0
+	for line := range strings.Lines(data) {
+		n++
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: want KEY=value, got %q", n, line)
+		}
+		value, err := unquote(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", n, key, err)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// unquote removes the shell quoting of a value.
+func unquote(raw string) (string, error) {
+	if raw == "" || (raw[0] != '"' && raw[0] != '\'') {
+		return raw, nil
+	}
+	quote := raw[0]
+	if len(raw) < 2 || raw[len(raw)-1] != quote {
+		return "", fmt.Errorf("unterminated quote in %s", raw)
+	}
+	inner := raw[1 : len(raw)-1]
+	if quote == '\'' {
+		return inner, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(inner); i++ {
+		if inner[i] == '\\' && i+1 < len(inner) && strings.IndexByte("$\"\\`", inner[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(inner[i])
+	}
+	return b.String(), nil
+}
+
+// Rootfs-relative directories scanned for kernels: the images in BootDir named vmlinuz-<version> and the module
+// directories named <version> in ModulesDir, or LegacyModulesDir on systems without a merged /usr.
+const (
+	BootDir          = "boot"
+	ModulesDir       = "usr/lib/modules"
+	LegacyModulesDir = "lib/modules"
+)
+
+// Kernels returns the versions of the kernels installed in rootFS in lexical order, each once, resolving symlinks
+// inside it with resolver. It returns an error if there is none.
+func Kernels(rootFS string, resolver install.Resolver) ([]string, error) {
+	seen := map[string]bool{}
+	var versions []string
+	add := func(v string) {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			versions = append(versions, v)
+		}
+	}
+	names, _, err := install.ReadDir(rootFS, BootDir, resolver)
+	if err != nil {
+		return nil, fmt.Errorf("osinfo: %w", err)
+	}
+	for _, name := range names {
+		if v, ok := strings.CutPrefix(name, "vmlinuz-"); ok {
+			add(v)
+		}
+	}
+	for _, dir := range []string{ModulesDir, LegacyModulesDir} {
+		names, ok, err := install.ReadDir(rootFS, dir, resolver)
+		if err != nil {
+			return nil, fmt.Errorf("osinfo: %w", err)
+		}
+		if ok {
+			for _, name := range names {
+				add(name)
+			}
+			// With a merged /usr, lib is a link to usr/lib and lists the same directories.
+			break
+		}
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("osinfo: %s has no %s/vmlinuz-<version> or %s/<version>", rootFS, BootDir, ModulesDir)
+	}
+	slices.Sort(versions)
+	return versions, nil
+}
@@ -0,0 +1,126 @@
+package osinfo
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nickhildebrandt/ts-release/internal/install"
+)
+
+// writeFile writes data to the slash-separated path rel below root, creating its directory.
+func writeFile(t *testing.T, root, rel, data string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestParse_UnquotesShellValues verifies the quoting rules of os-release(5).
+// The test fails if a value keeps its quotes or escapes, or a malformed line is accepted.
+func TestParse_UnquotesShellValues(t *testing.T) {
+	values, err := Parse("# comment\n\nNAME=\"Debian GNU/Linux\"\nID=debian\nVARIANT='Kiosk \"Lobby\"'\nPRETTY_NAME=\"A \\\"quoted\\\" \\$name\"\n")
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	want := map[string]string{
+		"NAME":        "Debian GNU/Linux",
+		"ID":          "debian",
+		"VARIANT":     `Kiosk "Lobby"`,
+		"PRETTY_NAME": `A "quoted" $name`,
+	}
+	for key, value := range want {
+		if values[key] != value {
+			t.Errorf("%s = %q, want %q", key, values[key], value)
+		}
+	}
+	for _, data := range []string{"NAME\n", "=x\n", "NAME=\"open\n"} {
+		if _, err := Parse(data); err == nil {
+			t.Errorf("expected %q to be rejected", data)
+		}
+	}
+}
+
+// TestRead_ReadsIdentityInsideRootFS verifies Read on a rootfs whose etc/os-release links to usr/lib/os-release, as in
+// most distributions, and the fallback and error cases. The test fails if a value is missing from Line, the build
+// host's os-release is read through an absolute link, or a rootfs without identity files is accepted.
+func TestRead_ReadsIdentityInsideRootFS(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "usr/lib/os-release", "NAME=\"Kiosk OS\"\nPRETTY_NAME=\"Kiosk OS 3 (lobby)\"\nVARIANT_ID=signage\n")
+	writeFile(t, root, "etc/hostname", "# static\nlobby-07\n")
+	if err := os.Symlink("../usr/lib/os-release", filepath.Join(root, "etc", "os-release")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, root, "etc/machine-info", "PRETTY_HOSTNAME=\"Lobby display 7\"\nDEPLOYMENT=production\n")
+
+	for _, resolver := range install.Resolvers() {
+		info, err := Read(root, install.Resolver(resolver))
+		if err != nil {
+			t.Fatalf("%s: Read error: %v", resolver, err)
+		}
+		if info.Name != "Kiosk OS" || info.Hostname != "lobby-07" || info.Deployment != "production" {
+			t.Fatalf("%s: Read = %+v", resolver, info)
+		}
+		if got, want := info.Line(), "Kiosk OS 3 (lobby) · signage · Lobby display 7"; got != want {
+			t.Fatalf("%s: Line = %q, want %q", resolver, got, want)
+		}
+	}
+
+	root = t.TempDir()
+	writeFile(t, root, "etc/hostname", "lobby-08\n")
+	info, err := Read(root, install.ResolverAuto)
+	if err != nil || info.Line() != "lobby-08" || info.PrettyName != "" {
+		t.Fatalf("Read without os-release = %+v, %v", info, err)
+	}
+
+	root = t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/etc/os-release", filepath.Join(root, "etc", "os-release")); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := Read(root, install.ResolverAuto); err == nil {
+		t.Fatalf("expected an absolute link out of the rootfs to fail, got %+v", info)
+	}
+
+	if _, err := Read(t.TempDir(), install.ResolverAuto); err == nil || !strings.Contains(err.Error(), "naming the system") {
+		t.Fatalf("expected an error for a rootfs without identity files, got %v", err)
+	}
+}
+
+// TestKernels_ScansBootAndModules verifies that kernel versions are collected from boot and the module directories.
+// The test fails if a version is missing or listed twice, other boot files count as kernels, or a rootfs without
+// kernels is accepted.
+func TestKernels_ScansBootAndModules(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "boot/vmlinuz-6.1.0-18-amd64", "")
+	writeFile(t, root, "boot/initrd.img-6.1.0-18-amd64", "")
+	writeFile(t, root, "boot/config-6.1.0-18-amd64", "")
+	writeFile(t, root, "usr/lib/modules/6.1.0-18-amd64/modules.dep", "")
+	writeFile(t, root, "usr/lib/modules/6.6.15-rt/modules.dep", "")
+
+	got, err := Kernels(root, install.ResolverAuto)
+	if err != nil {
+		t.Fatalf("Kernels error: %v", err)
+	}
+	if want := []string{"6.1.0-18-amd64", "6.6.15-rt"}; !slices.Equal(got, want) {
+		t.Fatalf("Kernels = %q, want %q", got, want)
+	}
+
+	root = t.TempDir()
+	writeFile(t, root, "lib/modules/5.10.0/modules.dep", "")
+	if got, err := Kernels(root, install.ResolverAuto); err != nil || !slices.Equal(got, []string{"5.10.0"}) {
+		t.Fatalf("Kernels without a merged /usr = %q, %v", got, err)
+	}
+
+	if _, err := Kernels(t.TempDir(), install.ResolverAuto); err == nil {
+		t.Fatal("expected an error for a rootfs without kernels")
+	}
+}
@@ -0,0 +1,220 @@
+// Package pexels is a minimal client for the Pexels API (https://www.pexels.com/api/documentation/).
+// It searches photos with orientation and size filters; requests are authenticated with an API key.
+package pexels
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultBaseURL is the root of the public API.
+const DefaultBaseURL = "https://api.pexels.com/v1"
+
+// Errors wrapped by requests that the API rejects with the corresponding HTTP status.
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrServer       = errors.New("server error")
+)
+
+// ErrTooLarge is wrapped when an image download exceeds its size limit.
+var ErrTooLarge = errors.New("too large")
+
+// DefaultMaxDownloadSize bounds image downloads unless Client.MaxDownloadSize is set. 256 MiB is far above the files of
+// the largest backgrounds, yet keeps a server that sends an endless body from exhausting memory.
+const DefaultMaxDownloadSize = 256 << 20
+
+// Orientations accepted by SearchParams.Orientation.
+const (
+	Landscape = "landscape"
+	Portrait  = "portrait"
+	Square    = "square"
+)
+
+// Minimum sizes accepted by SearchParams.Size.
+const (
+	// Large is at least 24 megapixels.
+	Large = "large"
+	// Medium is at least 12 megapixels.
+	Medium = "medium"
+	// Small is at least 4 megapixels.
+	Small = "small"
+)
+
+// Client sends requests to the Pexels API. It is safe for concurrent use.
+type Client struct {
+	// HTTPClient sends all requests, including image downloads; nil selects http.DefaultClient.
+	HTTPClient *http.Client
+	// BaseURL is the API root without a trailing slash; empty selects DefaultBaseURL.
+	BaseURL string
+	// APIKey is sent as the Authorization header.
+	APIKey string
+	// MaxDownloadSize bounds the bytes of an image download; zero selects DefaultMaxDownloadSize.
+	MaxDownloadSize int64
+}
+
+// SearchParams are the filters of the search endpoint; zero fields are omitted so the API defaults apply.
+type SearchParams struct {
+	Query string
+	// Orientation is Landscape, Portrait, or Square.
+	Orientation string
+	// Size is the minimum photo size: Large, Medium, or Small.
+	Size string
+	// Page selects the result page, starting at 1.
+	Page int
+	// PerPage is the number of results per page, at most 80.
+	PerPage int
+}
+
+// Photo is a photo record of the API.
+type Photo struct {
+	ID     int `json:"id"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// URL is the photo's page on pexels.com.
+	URL             string `json:"url"`
+	Photographer    string `json:"photographer"`
+	PhotographerURL string `json:"photographer_url"`
+	Src             struct {
+		// Original is the uploaded image; it accepts resizing parameters such as w, h, and fit.
+		Original string `json:"original"`
+		Large2x  string `json:"large2x"`
+	} `json:"src"`
+}
+
+// Attribution returns the credit line the Pexels guidelines ask for, e.g. "Photo by Jane Doe on Pexels".
+func (p Photo) Attribution() string {
+	return fmt.Sprintf("Photo by %s on Pexels", p.Photographer)
+}
+
+// SizedURL returns the original image URL cropped to width x height by the image service.
+func (p Photo) SizedURL(width, height int) string {
+	sep := "?"
+	if strings.Contains(p.Src.Original, "?") {
+		sep = "&"
+	}
+	v := url.Values{}
+	v.Set("auto", "compress")
+	v.Set("cs", "tinysrgb")
+	v.Set("fit", "crop")
+	v.Set("w", strconv.Itoa(width))
+	v.Set("h", strconv.Itoa(height))
+	return p.Src.Original + sep + v.Encode()
+}
+
+// Result is one page of search results.
+type Result struct {
+	Photos       []Photo `json:"photos"`
+	Page         int     `json:"page"`
+	PerPage      int     `json:"per_page"`
+	TotalResults int     `json:"total_results"`
+}
+
+// SizeFor returns the smallest size class that still covers width x height, so the search does not exclude photos
+// that are large enough.
+func SizeFor(width, height int) string {
+	switch pixels := width * height; {
+	case pixels > 12_000_000:
+		return Large
+	case pixels > 4_000_000:
+		return Medium
+	default:
+		return Small
+	}
+}
+
+// Search returns one page of search results.
+func (c *Client) Search(params SearchParams) (*Result, error) {
+	v := url.Values{}
+	for key, value := range map[string]string{"query": params.Query, "orientation": params.Orientation, "size": params.Size} {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	if params.Page > 0 {
+		v.Set("page", strconv.Itoa(params.Page))
+	}
+	if params.PerPage > 0 {
+		v.Set("per_page", strconv.Itoa(params.PerPage))
+	}
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	resp, err := c.do("search", strings.TrimSuffix(base, "/")+"/search?"+v.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result Result
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("pexels: search: decode response: %w", err)
+	}
+	return &result, nil
+}
+
+// Download fetches a photo image, e.g. from SizedURL, and returns its bytes.
+func (c *Client) Download(imageURL string) ([]byte, error) {
+	resp, err := c.do("download", imageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	limit := c.MaxDownloadSize
+	if limit <= 0 {
+		limit = DefaultMaxDownloadSize
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("pexels: download: read body: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("pexels: download: %w: larger than %d bytes", ErrTooLarge, limit)
+	}
+	return data, nil
+}
+
+// do sends an authenticated GET request and returns the response of a 2xx status; other statuses are mapped to
+// errors. op names the call in errors.
+func (c *Client) do(op, endpoint string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("pexels: %s: %w", op, err)
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", c.APIKey)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("pexels: %s: request failed: %w", op, err)
+	}
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return resp, nil
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		err = ErrUnauthorized
+	case http.StatusNotFound:
+		err = ErrNotFound
+	case http.StatusTooManyRequests:
+		err = ErrRateLimited
+	default:
+		if resp.StatusCode < http.StatusInternalServerError {
+			return nil, fmt.Errorf("pexels: %s: http %d", op, resp.StatusCode)
+		}
+		err = ErrServer
+	}
+	return nil, fmt.Errorf("pexels: %s: http %d: %w", op, resp.StatusCode, err)
+}
@@ -0,0 +1,68 @@
+package pexels
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestClient_SearchFiltersAndSizeClass expects search filters and the API key to be sent, results to decode, size
+// classes to cover the requested resolution, and rejected requests to wrap the sentinel errors.
+// The test fails if a filter or the authorization is missing, a status is mapped wrong, or a download beyond the limit
+// is accepted.
+func TestClient_SearchFiltersAndSizeClass(t *testing.T) {
+	var last *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = r
+		switch {
+		case r.Header.Get("Authorization") != "key":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/image":
+			_, _ = w.Write([]byte("image"))
+		case r.URL.Path == "/v1/search":
+			_, _ = w.Write([]byte(`{"page":1,"per_page":80,"total_results":1,"photos":[{"id":42,"width":6000,"height":4000,
+				"url":"https://www.pexels.com/photo/42/","photographer":"Jane Doe","src":{"original":"https://images.pexels.com/photos/42/a.jpeg"}}]}`))
+		default:
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+	client := &Client{HTTPClient: server.Client(), BaseURL: server.URL + "/v1", APIKey: "key"}
+
+	result, err := client.Search(SearchParams{Query: "nature", Orientation: Landscape, Size: SizeFor(3840, 2160), PerPage: 80})
+	if err != nil {
+		t.Fatalf("Search error: %v", err)
+	}
+	if got := last.URL.RawQuery; got != "orientation=landscape&per_page=80&query=nature&size=medium" {
+		t.Fatalf("query: %s", got)
+	}
+	photo := result.Photos[0]
+	if len(result.Photos) != 1 || photo.ID != 42 || photo.Attribution() != "Photo by Jane Doe on Pexels" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if got := photo.SizedURL(2560, 1440); got != "https://images.pexels.com/photos/42/a.jpeg?auto=compress&cs=tinysrgb&fit=crop&h=1440&w=2560" {
+		t.Fatalf("sized URL %q", got)
+	}
+	for size, pixels := range map[string][2]int{Small: {1920, 1080}, Medium: {3840, 2160}, Large: {7680, 4320}} {
+		if got := SizeFor(pixels[0], pixels[1]); got != size {
+			t.Fatalf("SizeFor(%v) = %s want %s", pixels, got, size)
+		}
+	}
+
+	if data, err := client.Download(server.URL + "/image"); err != nil || string(data) != "image" {
+		t.Fatalf("Download: got %q, %v", data, err)
+	}
+	client.MaxDownloadSize = 4
+	if _, err := client.Download(server.URL + "/image"); !errors.Is(err, ErrTooLarge) || !strings.Contains(err.Error(), "larger than 4 bytes") {
+		t.Fatalf("download beyond the limit: got %v, want ErrTooLarge", err)
+	}
+	if _, err := client.Download(server.URL + "/limited"); !errors.Is(err, ErrRateLimited) || !strings.Contains(err.Error(), "http 429") {
+		t.Fatalf("limited: got %v, want ErrRateLimited", err)
+	}
+	client.APIKey = ""
+	if _, err := client.Search(SearchParams{}); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("missing key: got %v, want ErrUnauthorized", err)
+	}
+}
@@ -0,0 +1,223 @@
+// Package preview draws a downscaled image inline in a terminal, with the kitty graphics protocol, as sixels, or as
+// colored half blocks that every terminal with 24-bit color shows.
+package preview
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Protocol selects how an image is sent to the terminal.
+type Protocol string
+
+const (
+	// ProtocolAuto selects a protocol from the environment with Detect.
+	ProtocolAuto Protocol = "auto"
+	// ProtocolKitty sends a PNG with the kitty graphics protocol, also understood by WezTerm and Ghostty.
+	ProtocolKitty Protocol = "kitty"
+	// ProtocolSixel sends sixels with a 216-color palette, e.g. for xterm -ti vt340, mlterm, or foot.
+	ProtocolSixel Protocol = "sixel"
+	// ProtocolANSI prints "▀" half blocks with 24-bit foreground and background colors, two pixels per cell.
+	ProtocolANSI Protocol = "ansi"
+)
+
+// Protocols returns all supported protocols in a stable order for help output and validation.
+func Protocols() []Protocol {
+	return []Protocol{ProtocolAuto, ProtocolKitty, ProtocolSixel, ProtocolANSI}
+}
+
+// ParseProtocol converts a user-supplied protocol name into a Protocol; empty selects ProtocolAuto.
+// It returns an error for unknown names.
+func ParseProtocol(name string) (Protocol, error) {
+	if name == "" {
+		return ProtocolAuto, nil
+	}
+	for _, p := range Protocols() {
+		if string(p) == name {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown preview protocol %q (want one of %v)", name, Protocols())
+}
+
+// Detect returns the protocol of the terminal described by the environment, read with getenv: kitty for kitty,
+// WezTerm, and Ghostty, sixel for terminals whose TERM names sixel support, and ANSI otherwise.
+func Detect(getenv func(string) string) Protocol {
+	term, program := getenv("TERM"), getenv("TERM_PROGRAM")
+	switch {
+	case getenv("KITTY_WINDOW_ID") != "", term == "xterm-kitty", term == "xterm-ghostty", program == "WezTerm", program == "ghostty":
+		return ProtocolKitty
+	case strings.Contains(term, "sixel"), term == "mlterm", strings.HasPrefix(term, "foot"), term == "yaft-256color":
+		return ProtocolSixel
+	default:
+		return ProtocolANSI
+	}
+}
+
+// Options configure Write.
+type Options struct {
+	// Width is the preview width in pixels for kitty and sixel; zero selects 640.
+	Width int
+	// Columns is the preview width in terminal cells for ANSI; zero selects 80.
+	Columns int
+}
+
+// Write scales img to the preview size, keeping its aspect ratio, and writes it to w with the protocol, followed by
+// a newline. ProtocolAuto is not resolved here; pass the result of Detect instead.
+func Write(w io.Writer, img image.Image, protocol Protocol, opts Options) error {
+	if opts.Width <= 0 {
+		opts.Width = 640
+	}
+	if opts.Columns <= 0 {
+		opts.Columns = 80
+	}
+	var out bytes.Buffer
+	var err error
+	switch protocol {
+	case ProtocolKitty:
+		err = writeKitty(&out, scale(img, opts.Width))
+	case ProtocolSixel:
+		writeSixel(&out, scale(img, opts.Width))
+	case ProtocolANSI:
+		writeANSI(&out, scale(img, opts.Columns))
+	default:
+		return fmt.Errorf("preview: cannot write protocol %q", protocol)
+	}
+	if err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	if _, err := w.Write(out.Bytes()); err != nil {
+		return fmt.Errorf("preview: %w", err)
+	}
+	return nil
+}
+
+// scale returns img scaled to width pixels, with the height following the aspect ratio.
+func scale(img image.Image, width int) *image.RGBA {
+	b := img.Bounds()
+	height := max(1, b.Dy()*width/max(1, b.Dx()))
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+// kittyChunk is the maximum payload per escape sequence of the kitty graphics protocol.
+const kittyChunk = 4096
+
+// writeKitty transmits img as PNG and displays it at the cursor (a=T), split into chunks as the protocol requires.
+func writeKitty(w *bytes.Buffer, img image.Image) error {
+	var raw bytes.Buffer
+	if err := png.Encode(&raw, img); err != nil {
+		return fmt.Errorf("preview: %w", err)
+	}
+	data := base64.StdEncoding.EncodeToString(raw.Bytes())
+	for first := true; first || data != ""; first = false {
+		chunk := data[:min(kittyChunk, len(data))]
+		data = data[len(chunk):]
+		more := 0
+		if data != "" {
+			more = 1
+		}
+		if first {
+			fmt.Fprintf(w, "\x1b_Gf=100,a=T,q=2,m=%d;%s\x1b\\", more, chunk)
+		} else {
+			fmt.Fprintf(w, "\x1b_Gm=%d;%s\x1b\\", more, chunk)
+		}
+	}
+	return nil
+}
+
+// writeSixel writes img as a sixel image whose colors are rounded to a 6x6x6 color cube, which needs no palette
+// search and is supported by terminals with 256 color registers.
+func writeSixel(w *bytes.Buffer, img *image.RGBA) {
+	b := img.Bounds()
+	fmt.Fprintf(w, "\x1bP0;1q\"1;1;%d;%d", b.Dx(), b.Dy())
+	for i := 0; i < 216; i++ {
+		// Sixel colors are given in percent.
+		fmt.Fprintf(w, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+	}
+	indexes := make([]uint8, b.Dx()*b.Dy())
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			c := img.RGBAAt(b.Min.X+x, b.Min.Y+y)
+			indexes[y*b.Dx()+x] = uint8(cube(c.R)*36 + cube(c.G)*6 + cube(c.B))
+		}
+	}
+	row := make([]byte, b.Dx())
+	for band := 0; band < b.Dy(); band += 6 {
+		var used [216]bool
+		for y := band; y < min(band+6, b.Dy()); y++ {
+			for x := 0; x < b.Dx(); x++ {
+				used[indexes[y*b.Dx()+x]] = true
+			}
+		}
+		first := true
+		for color := range used {
+			if !used[color] {
+				continue
+			}
+			for x := range row {
+				var bits byte
+				for dy := 0; dy < 6 && band+dy < b.Dy(); dy++ {
+					if int(indexes[(band+dy)*b.Dx()+x]) == color {
+						bits |= 1 << dy
+					}
+				}
+				row[x] = '?' + bits
+			}
+			if !first {
+				w.WriteByte('$')
+			}
+			first = false
+			fmt.Fprintf(w, "#%d", color)
+			writeRuns(w, row)
+		}
+		w.WriteByte('-')
+	}
+	w.WriteString("\x1b\\")
+}
+
+// writeRuns writes sixel characters with repeats of more than three written as !<count><char>.
+func writeRuns(w *bytes.Buffer, row []byte) {
+	for i := 0; i < len(row); {
+		j := i
+		for j < len(row) && row[j] == row[i] {
+			j++
+		}
+		if n := j - i; n > 3 {
+			fmt.Fprintf(w, "!%d%c", n, row[i])
+		} else {
+			w.Write(row[i:j])
+		}
+		i = j
+	}
+}
+
+// cube returns the nearest of the six levels of the color cube for an 8-bit channel.
+func cube(v uint8) int {
+	return (int(v)*5 + 127) / 255
+}
+
+// writeANSI writes two pixel rows per line as upper half blocks, the upper pixel in the foreground color and the
+// lower one in the background color.
+func writeANSI(w *bytes.Buffer, img *image.RGBA) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y += 2 {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			top, bottom := img.RGBAAt(x, y), img.RGBAAt(x, min(y+1, b.Max.Y-1))
+			fmt.Fprintf(w, "\x1b[38;2;%d;%d;%dm\x1b[48;2;%d;%d;%dm▀", top.R, top.G, top.B, bottom.R, bottom.G, bottom.B)
+		}
+		w.WriteString("\x1b[0m")
+		if y+2 < b.Max.Y {
+			w.WriteByte('\n')
+		}
+	}
+}
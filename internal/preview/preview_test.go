@@ -0,0 +1,96 @@
+package preview
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestWrite_Protocols expects each protocol to frame the scaled image correctly: kitty as chunked base64 PNG of the
+// preview width, sixel with its raster size and terminator, and ANSI as half blocks of the source colors.
+// The test fails if a chunk exceeds the protocol limit, the image is not scaled, or colors are lost.
+func TestWrite_Protocols(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 1600, 900))
+	for y := 0; y < 900; y++ {
+		for x := 0; x < 1600; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if y >= 450 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	var kitty bytes.Buffer
+	if err := Write(&kitty, img, ProtocolKitty, Options{Width: 320}); err != nil {
+		t.Fatalf("kitty: %v", err)
+	}
+	chunks := regexp.MustCompile("\x1b_G([^;]*);([^\x1b]*)\x1b\\\\").FindAllStringSubmatch(kitty.String(), -1)
+	if len(chunks) == 0 || !strings.Contains(chunks[0][1], "f=100,a=T") || !strings.HasSuffix(chunks[len(chunks)-1][1], "m=0") {
+		t.Fatalf("kitty: unexpected framing %q", kitty.String()[:min(80, kitty.Len())])
+	}
+	var payload strings.Builder
+	for _, c := range chunks {
+		if len(c[2]) > kittyChunk {
+			t.Fatalf("kitty: chunk of %d bytes", len(c[2]))
+		}
+		payload.WriteString(c[2])
+	}
+	raw, err := base64.StdEncoding.DecodeString(payload.String())
+	if err != nil {
+		t.Fatalf("kitty payload: %v", err)
+	}
+	if decoded, err := png.Decode(bytes.NewReader(raw)); err != nil || decoded.Bounds().Dx() != 320 || decoded.Bounds().Dy() != 180 {
+		t.Fatalf("kitty PNG: %v, %v", decoded, err)
+	}
+
+	var sixel bytes.Buffer
+	if err := Write(&sixel, img, ProtocolSixel, Options{Width: 64}); err != nil {
+		t.Fatalf("sixel: %v", err)
+	}
+	if s := sixel.String(); !strings.HasPrefix(s, "\x1bP0;1q\"1;1;64;36") || !strings.HasSuffix(s, "\x1b\\\n") || !strings.Contains(s, "#180!64~") {
+		t.Fatalf("sixel: unexpected output %q", s[:min(80, len(s))])
+	}
+
+	var ansi bytes.Buffer
+	if err := Write(&ansi, img, ProtocolANSI, Options{Columns: 16}); err != nil {
+		t.Fatalf("ansi: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(ansi.String(), "\n"), "\n")
+	if len(lines) != 5 || strings.Count(lines[0], "▀") != 16 || !strings.Contains(lines[0], "\x1b[38;2;255;0;0m") || !strings.Contains(lines[4], "\x1b[48;2;0;0;255m") {
+		t.Fatalf("ansi: got %d lines: %q", len(lines), lines[0])
+	}
+
+	if err := Write(&ansi, img, ProtocolAuto, Options{}); err == nil {
+		t.Fatalf("expected an error for an unresolved protocol")
+	}
+}
+
+// TestDetect expects the terminal's protocol to be detected from TERM, TERM_PROGRAM, and KITTY_WINDOW_ID, with
+// ANSI for unknown terminals. The test fails if a known terminal gets the wrong protocol.
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct {
+		env  map[string]string
+		want Protocol
+	}{
+		{map[string]string{"TERM": "xterm-kitty"}, ProtocolKitty},
+		{map[string]string{"TERM": "xterm-256color", "TERM_PROGRAM": "WezTerm"}, ProtocolKitty},
+		{map[string]string{"TERM": "foot-extra"}, ProtocolSixel},
+		{map[string]string{"TERM": "xterm-256color"}, ProtocolANSI},
+	} {
+		if got := Detect(func(k string) string { return tc.env[k] }); got != tc.want {
+			t.Fatalf("%v: got %s, want %s", tc.env, got, tc.want)
+		}
+	}
+	if p, err := ParseProtocol(""); err != nil || p != ProtocolAuto {
+		t.Fatalf("ParseProtocol(\"\"): got %s, %v", p, err)
+	}
+	if _, err := ParseProtocol("iterm"); err == nil {
+		t.Fatalf("expected an error for an unknown protocol")
+	}
+}
@@ -0,0 +1,69 @@
+package qoi
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+)
+
+// TestEncode_KnownBytes encodes three pixels that take a full RGB op, a small difference, and a run. The test fails if
+// the header, the ops, or the end padding differ from the format specification.
+func TestEncode_KnownBytes(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{10, 20, 30, 255})
+	img.SetNRGBA(1, 0, color.NRGBA{11, 20, 29, 255})
+	img.SetNRGBA(2, 0, color.NRGBA{11, 20, 29, 255})
+	var buf bytes.Buffer
+	if err := Encode(&buf, img); err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	want := []byte{'q', 'o', 'i', 'f', 0, 0, 0, 3, 0, 0, 0, 1, 3, 0, opRGB, 10, 20, 30, 0x79, opRun, 0, 0, 0, 0, 0, 0, 0, 1}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("got % x\nwant % x", buf.Bytes(), want)
+	}
+}
+
+// TestRoundTrip_OpaqueAndTranslucent encodes and decodes images with runs, repeats, small and large steps, and
+// alpha, and reads their size through the image package. The test fails if a pixel, the channel count, or the size
+// differs, or a truncated stream decodes.
+func TestRoundTrip_OpaqueAndTranslucent(t *testing.T) {
+	for _, alpha := range []bool{false, true} {
+		img := image.NewNRGBA(image.Rect(0, 0, 97, 41))
+		for i := 0; i < len(img.Pix); i += 4 {
+			p := i / 4
+			v := uint8(p * 7919 % 251)
+			if p%13 < 5 {
+				v = uint8(p / 13)
+			}
+			a := uint8(255)
+			if alpha {
+				a = uint8(p % 3 * 100)
+			}
+			copy(img.Pix[i:], []byte{v, v + uint8(p%4), v ^ 0x55, a})
+		}
+		var buf bytes.Buffer
+		if err := Encode(&buf, img); err != nil {
+			t.Fatalf("Encode error: %v", err)
+		}
+		if channels := buf.Bytes()[12]; (channels == 4) != alpha {
+			t.Fatalf("alpha %v: got %d channels", alpha, channels)
+		}
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(buf.Bytes()))
+		if err != nil || format != "qoi" || cfg.Width != 97 || cfg.Height != 41 {
+			t.Fatalf("DecodeConfig = %+v, %q, %v", cfg, format, err)
+		}
+		got, err := Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("Decode error: %v", err)
+		}
+		if !bytes.Equal(got.(*image.NRGBA).Pix, img.Pix) {
+			t.Fatalf("alpha %v: decoded pixels differ", alpha)
+		}
+		if _, err := Decode(bytes.NewReader(buf.Bytes()[:buf.Len()/2])); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected a truncated stream to fail, got %v", err)
+		}
+	}
+}
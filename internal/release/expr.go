@@ -0,0 +1,645 @@
+package release
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ExprPrefix marks a text as an expression rather than a template, e.g.
+// "expr: 'build ' + buildID[:10] + ' / ' + channel".
+const ExprPrefix = "expr:"
+
+// maxExprLen and maxExprDepth bound the length of an expression in bytes and the nesting of its parentheses,
+// conditionals, indexes, calls, and ! and - prefixes, so that texts from serve requests cannot exhaust the stack of the
+// recursive parser or of the evaluation.
+const (
+	maxExprLen   = 4096
+	maxExprDepth = 64
+)
+
+// eval evaluates an expression against the release info and returns its value as text. Expressions combine string
+// and integer literals, the variables of vars, and the functions of exprFuncs with + (concatenation or addition), -,
+// the comparisons == != < <= > >=, the logical operators && || !, the conditional c ? a : b, parentheses, indexing
+// s[i], and slicing s[a:b]. Indexes count runes, negative ones count from the end, and slice bounds are clamped, so
+// buildID[:10] also works for shorter IDs. It returns an error for syntax errors, unknown variables and functions,
+// operands of the wrong type, and expressions longer than maxExprLen or nested deeper than maxExprDepth.
+func (i Info) eval(expr string) (string, error) {
+	if len(expr) > maxExprLen {
+		return "", fmt.Errorf("release: expression of %d bytes, want at most %d", len(expr), maxExprLen)
+	}
+	p := &exprParser{src: expr}
+	p.next()
+	n, err := p.parseExpr()
+	if err == nil && p.err != nil {
+		err = p.err
+	} else if err == nil && p.tok.kind != tokEOF {
+		err = p.errorf("unexpected %s", p.tok)
+	}
+	if err != nil {
+		return "", fmt.Errorf("release: expression %q: %w", expr, err)
+	}
+	v, err := n(exprEnv{vars: i.vars(), info: i})
+	if err != nil {
+		return "", fmt.Errorf("release: expression %q: %w", expr, err)
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case int:
+		return strconv.Itoa(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("release: expression %q: result is a %s, want a string", expr, typeName(v))
+	}
+}
+
+// vars returns the variables of expressions: strings, a bool for git.dirty, and a list of strings for kernels. The
+// git and os variables are only set when the info has them.
+func (i Info) vars() map[string]any {
+	vars := map[string]any{
+		"targetName":     i.TargetName,
+		"buildID":        i.BuildID,
+		"channel":        i.Channel,
+		"kernels":        i.Kernels,
+		"packagesSHA256": i.PackagesSHA256,
+	}
+	if g := i.Git; g != nil {
+		vars["git.commit"], vars["git.shortCommit"], vars["git.tag"], vars["git.branch"] = g.Commit, g.ShortCommit, g.Tag, g.Branch
+		vars["git.dirty"] = g.Dirty
+	}
+	if o := i.OS; o != nil {
+		for name, value := range map[string]string{
+			"prettyName": o.PrettyName, "name": o.Name, "id": o.ID, "version": o.Version, "versionID": o.VersionID,
+			"variant": o.Variant, "variantID": o.VariantID, "imageID": o.ImageID, "imageVersion": o.ImageVersion,
+			"hostname": o.Hostname, "prettyHostname": o.PrettyHostname, "deployment": o.Deployment,
+			"location": o.Location, "line": o.Line(),
+		} {
+			vars["os."+name] = value
+		}
+	}
+	return vars
+}
+
+// exprEnv is what an expression is evaluated against.
+type exprEnv struct {
+	vars map[string]any
+	info Info
+}
+
+// exprNode evaluates a parsed part of an expression to a string, int, bool, or []string.
+type exprNode func(env exprEnv) (any, error)
+
+// exprFuncs are the functions expressions can call, by name.
+var exprFuncs = map[string]func(env exprEnv, args []any) (any, error){
+	"T": func(env exprEnv, args []any) (any, error) {
+		s, err := stringArgs("T", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return env.info.Locale.T(s[0]), nil
+	},
+	"date": func(env exprEnv, args []any) (any, error) {
+		if _, err := stringArgs("date", args, 0); err != nil {
+			return nil, err
+		}
+		return env.info.Locale.FormatDate(env.info.Time), nil
+	},
+	"upper": stringFunc("upper", strings.ToUpper),
+	"lower": stringFunc("lower", strings.ToLower),
+	"trim":  stringFunc("trim", strings.TrimSpace),
+	"len": func(env exprEnv, args []any) (any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("len takes 1 argument, got %d", len(args))
+		}
+		switch v := args[0].(type) {
+		case string:
+			return utf8.RuneCountInString(v), nil
+		case []string:
+			return len(v), nil
+		default:
+			return nil, fmt.Errorf("len of a %s", typeName(v))
+		}
+	},
+	"join": func(env exprEnv, args []any) (any, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("join takes 2 arguments, got %d", len(args))
+		}
+		list, ok := args[0].([]string)
+		sep, sepOK := args[1].(string)
+		if !ok || !sepOK {
+			return nil, fmt.Errorf("join takes a list and a string, got a %s and a %s", typeName(args[0]), typeName(args[1]))
+		}
+		return strings.Join(list, sep), nil
+	},
+}
+
+// stringFunc returns an expression function of one string argument.
+func stringFunc(name string, f func(string) string) func(exprEnv, []any) (any, error) {
+	return func(env exprEnv, args []any) (any, error) {
+		s, err := stringArgs(name, args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return f(s[0]), nil
+	}
+}
+
+// stringArgs checks that the function name got n string arguments and returns them.
+func stringArgs(name string, args []any, n int) ([]string, error) {
+	if len(args) != n {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", name, n, len(args))
+	}
+	s := make([]string, n)
+	for i, a := range args {
+		var ok bool
+		if s[i], ok = a.(string); !ok {
+			return nil, fmt.Errorf("%s takes strings, got a %s", name, typeName(a))
+		}
+	}
+	return s, nil
+}
+
+// typeName names the type of an expression value in errors.
+func typeName(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case int:
+		return "number"
+	case bool:
+		return "bool"
+	case []string:
+		return "list"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// Token kinds of expressions.
+const (
+	tokEOF = iota
+	tokIdent
+	tokString
+	tokInt
+	tokOp
+)
+
+// exprToken is a token of an expression with the byte offset it starts at.
+type exprToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+// String describes the token in syntax errors.
+func (t exprToken) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+// exprOps are the operators, longest first so that e.g. "<=" is not read as "<".
+var exprOps = []string{"==", "!=", "<=", ">=", "&&", "||", "+", "-", "<", ">", "!", "?", ":", "(", ")", "[", "]", ","}
+
+// exprParser is a recursive-descent parser that turns an expression into exprNodes.
+type exprParser struct {
+	src string
+	off int
+	tok exprToken
+	// err is the first error of the tokenizer, reported by the parser when it reaches the token.
+	err error
+	// depth is the number of parseExpr calls and ! and - prefixes in progress.
+	depth int
+}
+
+// enter counts a nested parseExpr call or prefix and returns an error beyond maxExprDepth; callers defer leave.
+func (p *exprParser) enter() error {
+	p.depth++
+	if p.depth > maxExprDepth {
+		return p.errorf("nested deeper than %d levels", maxExprDepth)
+	}
+	return nil
+}
+
+// leave ends a call counted by enter.
+func (p *exprParser) leave() {
+	p.depth--
+}
+
+// errorf returns a syntax error at the current token.
+func (p *exprParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("column %d: %s", p.tok.pos+1, fmt.Sprintf(format, args...))
+}
+
+// next reads the next token into p.tok.
+func (p *exprParser) next() {
+	for p.off < len(p.src) && (p.src[p.off] == ' ' || p.src[p.off] == '\t') {
+		p.off++
+	}
+	start := p.off
+	if p.off == len(p.src) {
+		p.tok = exprToken{kind: tokEOF, pos: start}
+		return
+	}
+	c := p.src[p.off]
+	switch {
+	case c == '\'' || c == '"':
+		var b strings.Builder
+		for p.off++; p.off < len(p.src) && p.src[p.off] != c; p.off++ {
+			if p.src[p.off] == '\\' && p.off+1 < len(p.src) {
+				p.off++
+			}
+			b.WriteByte(p.src[p.off])
+		}
+		if p.off == len(p.src) {
+			p.tok = exprToken{kind: tokEOF, pos: start}
+			p.err = fmt.Errorf("column %d: unterminated string", start+1)
+			return
+		}
+		p.off++
+		p.tok = exprToken{kind: tokString, text: b.String(), pos: start}
+	case c >= '0' && c <= '9':
+		for p.off < len(p.src) && p.src[p.off] >= '0' && p.src[p.off] <= '9' {
+			p.off++
+		}
+		p.tok = exprToken{kind: tokInt, text: p.src[start:p.off], pos: start}
+	case isIdentRune(rune(c), true):
+		for p.off < len(p.src) && isIdentRune(rune(p.src[p.off]), false) {
+			p.off++
+		}
+		p.tok = exprToken{kind: tokIdent, text: p.src[start:p.off], pos: start}
+	default:
+		for _, op := range exprOps {
+			if strings.HasPrefix(p.src[p.off:], op) {
+				p.off += len(op)
+				p.tok = exprToken{kind: tokOp, text: op, pos: start}
+				return
+			}
+		}
+		r, _ := utf8.DecodeRuneInString(p.src[p.off:])
+		p.tok = exprToken{kind: tokEOF, pos: start}
+		p.err = fmt.Errorf("column %d: unexpected character %q", start+1, r)
+	}
+}
+
+// isIdentRune reports whether r can be part of a variable or function name; dots join the parts of names like
+// git.shortCommit.
+func isIdentRune(r rune, first bool) bool {
+	return r == '_' || r < utf8.RuneSelf && unicode.IsLetter(r) || !first && (r == '.' || unicode.IsDigit(r))
+}
+
+// accept consumes the operator op if it is the current token.
+func (p *exprParser) accept(op string) bool {
+	if p.tok.kind == tokOp && p.tok.text == op {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// expect consumes the operator op or returns a syntax error.
+func (p *exprParser) expect(op string) error {
+	if p.err != nil {
+		return p.err
+	}
+	if !p.accept(op) {
+		return p.errorf("expected %q, got %s", op, p.tok)
+	}
+	return nil
+}
+
+// parseExpr parses a conditional: or [? expr : expr].
+func (p *exprParser) parseExpr() (exprNode, error) {
+	defer p.leave()
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	cond, err := p.parseBinary(0)
+	if err != nil || !p.accept("?") {
+		return cond, err
+	}
+	then, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return func(env exprEnv) (any, error) {
+		c, err := evalBool(env, cond, "?")
+		if err != nil {
+			return nil, err
+		}
+		if c {
+			return then(env)
+		}
+		return otherwise(env)
+	}, nil
+}
+
+// exprLevels are the binary operators by precedence, loosest first.
+var exprLevels = [][]string{{"||"}, {"&&"}, {"==", "!=", "<", "<=", ">", ">="}, {"+", "-"}}
+
+// parseBinary parses the left-associative binary operators of exprLevels[level] and tighter.
+func (p *exprParser) parseBinary(level int) (exprNode, error) {
+	if level == len(exprLevels) {
+		return p.parseUnary()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOp {
+		op := p.tok.text
+		found := false
+		for _, o := range exprLevels[level] {
+			found = found || o == op
+		}
+		if !found {
+			break
+		}
+		p.next()
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode(op, left, right)
+	}
+	return left, nil
+}
+
+// binaryNode returns the node applying op to the values of left and right. && and || only evaluate right if needed.
+func binaryNode(op string, left, right exprNode) exprNode {
+	return func(env exprEnv) (any, error) {
+		if op == "&&" || op == "||" {
+			l, err := evalBool(env, left, op)
+			if err != nil || l == (op == "||") {
+				return l, err
+			}
+			return evalBool(env, right, op)
+		}
+		l, err := left(env)
+		if err != nil {
+			return nil, err
+		}
+		r, err := right(env)
+		if err != nil {
+			return nil, err
+		}
+		if op == "==" || op == "!=" {
+			if typeName(l) != typeName(r) || typeName(l) == "list" {
+				return nil, fmt.Errorf("cannot compare a %s and a %s", typeName(l), typeName(r))
+			}
+			return (l == r) == (op == "=="), nil
+		}
+		if ls, ok := l.(string); ok && op == "+" {
+			if rs, ok := r.(string); ok {
+				return ls + rs, nil
+			}
+		}
+		li, lok := l.(int)
+		ri, rok := r.(int)
+		if !lok || !rok {
+			return nil, fmt.Errorf("cannot apply %s to a %s and a %s", op, typeName(l), typeName(r))
+		}
+		switch op {
+		case "+":
+			return li + ri, nil
+		case "-":
+			return li - ri, nil
+		case "<":
+			return li < ri, nil
+		case "<=":
+			return li <= ri, nil
+		case ">":
+			return li > ri, nil
+		default:
+			return li >= ri, nil
+		}
+	}
+}
+
+// evalBool evaluates n and returns an error naming op if the value is not a bool.
+func evalBool(env exprEnv, n exprNode, op string) (bool, error) {
+	v, err := n(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%s needs a bool, got a %s", op, typeName(v))
+	}
+	return b, nil
+}
+
+// parseUnary parses ! and - prefixes.
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.tok.kind == tokOp && (p.tok.text == "!" || p.tok.text == "-") {
+		defer p.leave()
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env exprEnv) (any, error) {
+			b, err := evalBool(env, operand, "!")
+			return !b, err
+		}, nil
+	}
+	if p.accept("-") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env exprEnv) (any, error) {
+			v, err := operand(env)
+			if err != nil {
+				return nil, err
+			}
+			n, ok := v.(int)
+			if !ok {
+				return nil, fmt.Errorf("cannot negate a %s", typeName(v))
+			}
+			return -n, nil
+		}, nil
+	}
+	return p.parsePostfix()
+}
+
+// parsePostfix parses a primary followed by any number of [i] and [a:b].
+func (p *exprParser) parsePostfix() (exprNode, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("[") {
+		var lo, hi exprNode
+		if p.tok.kind != tokOp || p.tok.text != ":" {
+			if lo, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+		}
+		slice := p.accept(":")
+		if slice && (p.tok.kind != tokOp || p.tok.text != "]") {
+			if hi, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		if !slice && lo == nil {
+			return nil, p.errorf("empty index")
+		}
+		n = indexNode(n, lo, hi, slice)
+	}
+	return n, nil
+}
+
+// indexNode returns the node that indexes or slices the value of n by the values of lo and hi, either of which may
+// be nil in a slice.
+func indexNode(n, lo, hi exprNode, slice bool) exprNode {
+	return func(env exprEnv) (any, error) {
+		v, err := n(env)
+		if err != nil {
+			return nil, err
+		}
+		var runes []rune
+		var list []string
+		length := 0
+		switch v := v.(type) {
+		case string:
+			runes = []rune(v)
+			length = len(runes)
+		case []string:
+			list = v
+			length = len(list)
+		default:
+			return nil, fmt.Errorf("cannot index a %s", typeName(v))
+		}
+		bound := func(b exprNode, def int) (int, error) {
+			if b == nil {
+				return def, nil
+			}
+			bv, err := b(env)
+			if err != nil {
+				return 0, err
+			}
+			i, ok := bv.(int)
+			if !ok {
+				return 0, fmt.Errorf("index is a %s, want a number", typeName(bv))
+			}
+			if i < 0 {
+				i += length
+			}
+			return i, nil
+		}
+		start, err := bound(lo, 0)
+		if err != nil {
+			return nil, err
+		}
+		if !slice {
+			if start < 0 || start >= length {
+				return nil, fmt.Errorf("index %d out of range for length %d", start, length)
+			}
+			if list != nil {
+				return list[start], nil
+			}
+			return string(runes[start]), nil
+		}
+		end, err := bound(hi, length)
+		if err != nil {
+			return nil, err
+		}
+		start, end = max(0, min(start, length)), max(0, min(end, length))
+		end = max(start, end)
+		if list != nil {
+			return list[start:end], nil
+		}
+		return string(runes[start:end]), nil
+	}
+}
+
+// parsePrimary parses a literal, variable, function call, or parenthesized expression.
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	tok := p.tok
+	switch tok.kind {
+	case tokString:
+		p.next()
+		return func(exprEnv) (any, error) { return tok.text, nil }, nil
+	case tokInt:
+		p.next()
+		n, err := strconv.Atoi(tok.text)
+		if err != nil {
+			return nil, fmt.Errorf("column %d: number %s out of range", tok.pos+1, tok.text)
+		}
+		return func(exprEnv) (any, error) { return n, nil }, nil
+	case tokIdent:
+		p.next()
+		if tok.text == "true" || tok.text == "false" {
+			b := tok.text == "true"
+			return func(exprEnv) (any, error) { return b, nil }, nil
+		}
+		if !p.accept("(") {
+			return func(env exprEnv) (any, error) {
+				v, ok := env.vars[tok.text]
+				if !ok {
+					return nil, fmt.Errorf("unknown variable %s", tok.text)
+				}
+				return v, nil
+			}, nil
+		}
+		f, ok := exprFuncs[tok.text]
+		if !ok {
+			return nil, fmt.Errorf("column %d: unknown function %s", tok.pos+1, tok.text)
+		}
+		var args []exprNode
+		for !p.accept(")") {
+			if len(args) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		return func(env exprEnv) (any, error) {
+			values := make([]any, len(args))
+			for i, arg := range args {
+				v, err := arg(env)
+				if err != nil {
+					return nil, err
+				}
+				values[i] = v
+			}
+			return f(env, values)
+		}, nil
+	}
+	if p.accept("(") {
+		n, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(")")
+	}
+	return nil, p.errorf("unexpected %s", tok)
+}
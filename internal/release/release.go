@@ -0,0 +1,124 @@
+package release
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/gitinfo"
+	"github.com/nickhildebrandt/ts-release/internal/install"
+	"github.com/nickhildebrandt/ts-release/internal/locale"
+	"github.com/nickhildebrandt/ts-release/internal/osinfo"
+)
+
+// DefaultSubtitleTemplate renders the build ID, matching the behavior before templates existed.
+const DefaultSubtitleTemplate = "{{.BuildID}}"
+
+// GitSubtitleTemplate is used when git metadata is available and no subtitle template was given,
+// producing e.g. "v2.3.1 (a1b2c3d)" or "(a1b2c3d-dirty)" for untagged trees.
+const GitSubtitleTemplate = "{{with .Git.Tag}}{{.}} {{end}}({{.Git.ShortCommit}}{{if .Git.Dirty}}-dirty{{end}})"
+
+// OSSubtitleTemplate is appended to the default subtitle when the identity of the rootfs was read, producing e.g.
+// "v2.3.1 (a1b2c3d) · Debian GNU/Linux 12 (bookworm) · kiosk · lobby-07".
+const OSSubtitleTemplate = " · {{.OS.Line}}"
+
+// Info is the data available to text templates and written to the release metadata file.
+type Info struct {
+	TargetName string
+	BuildID    string
+	// Channel is the release channel name, empty if none was selected.
+	Channel string
+	// WallpaperID is the Wallhaven ID of the background, empty for other sources; it is only known after templates
+	// are expanded, so it is written to the metadata file but not available to templates.
+	WallpaperID string
+	// BackgroundSource, BackgroundPage, and Attribution describe where the background came from and whom to
+	// credit; like WallpaperID they are only known after templates are expanded.
+	BackgroundSource string
+	BackgroundPage   string
+	Attribution      string
+	// Git is nil when no git directory was requested.
+	Git *gitinfo.Info
+	// OS is the identity of the system in the rootfs, nil unless it was requested.
+	OS *osinfo.Info
+	// Kernels holds the versions of the kernels in the rootfs, empty unless they were requested.
+	Kernels []string
+	// PackagesSHA256 is the hex SHA-256 of the package list of the image, empty if none was given.
+	PackagesSHA256 string
+	// Time is the generation time, formatted per locale by the date template function.
+	Time time.Time
+	// Locale translates fixed strings via the T template function.
+	Locale locale.Catalog
+}
+
+// Expand executes a text/template against the release info, or evaluates text as an expression if it starts with
+// ExprPrefix. Besides the Info fields, templates can call T to translate fixed strings and date to format Time per
+// locale. It returns an error for invalid template or expression syntax or references to unknown fields.
+func (i Info) Expand(text string) (string, error) {
+	if expr, ok := strings.CutPrefix(text, ExprPrefix); ok {
+		return i.eval(strings.TrimSpace(expr))
+	}
+	funcs := template.FuncMap{
+		"T":    i.Locale.T,
+		"date": func() string { return i.Locale.FormatDate(i.Time) },
+	}
+	tmpl, err := template.New("text").Option("missingkey=error").Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("release: parse template %q: %w", text, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, i); err != nil {
+		return "", fmt.Errorf("release: execute template %q: %w", text, err)
+	}
+	return b.String(), nil
+}
+
+// Fields returns the metadata entries describing this release in a stable order.
+// Channel, background, git, rootfs identity, kernel, and package entries are only included when set.
+func (i Info) Fields() []install.Field {
+	fields := []install.Field{
+		{Key: "TSSH_TARGET", Value: i.TargetName},
+		{Key: "TSSH_BUILD_ID", Value: i.BuildID},
+		{Key: "TSSH_LOCALE", Value: i.Locale.Language()},
+	}
+	if i.Channel != "" {
+		fields = append(fields, install.Field{Key: "TSSH_CHANNEL", Value: i.Channel})
+	}
+	for _, f := range []install.Field{
+		{Key: "TSSH_BACKGROUND_SOURCE", Value: i.BackgroundSource},
+		{Key: "TSSH_BACKGROUND_PAGE", Value: i.BackgroundPage},
+		{Key: "TSSH_BACKGROUND_ATTRIBUTION", Value: i.Attribution},
+		{Key: "TSSH_WALLPAPER_ID", Value: i.WallpaperID},
+	} {
+		if f.Value != "" {
+			fields = append(fields, f)
+		}
+	}
+	if i.Git != nil {
+		fields = append(fields,
+			install.Field{Key: "TSSH_GIT_COMMIT", Value: i.Git.Commit},
+			install.Field{Key: "TSSH_GIT_TAG", Value: i.Git.Tag},
+			install.Field{Key: "TSSH_GIT_BRANCH", Value: i.Git.Branch},
+			install.Field{Key: "TSSH_GIT_DIRTY", Value: strconv.FormatBool(i.Git.Dirty)},
+		)
+	}
+	if i.OS != nil {
+		for _, f := range []install.Field{
+			{Key: "TSSH_OS_PRETTY_NAME", Value: i.OS.PrettyName},
+			{Key: "TSSH_OS_VARIANT_ID", Value: i.OS.VariantID},
+			{Key: "TSSH_HOSTNAME", Value: i.OS.Hostname},
+		} {
+			if f.Value != "" {
+				fields = append(fields, f)
+			}
+		}
+	}
+	if len(i.Kernels) > 0 {
+		fields = append(fields, install.Field{Key: "TSSH_KERNEL", Value: strings.Join(i.Kernels, " ")})
+	}
+	if i.PackagesSHA256 != "" {
+		fields = append(fields, install.Field{Key: "TSSH_PACKAGES_SHA256", Value: i.PackagesSHA256})
+	}
+	return fields
+}
@@ -0,0 +1,186 @@
+package release
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/gitinfo"
+	"github.com/nickhildebrandt/ts-release/internal/locale"
+)
+
+// TestExpand_GitSubtitleTemplate verifies the automatic git subtitle for tagged, untagged, and dirty trees.
+// The test fails if the rendered text deviates from the documented "v2.3.1 (a1b2c3d)" shape.
+func TestExpand_GitSubtitleTemplate(t *testing.T) {
+	cases := []struct {
+		name string
+		git  gitinfo.Info
+		want string
+	}{
+		{name: "tagged", git: gitinfo.Info{Tag: "v2.3.1", ShortCommit: "a1b2c3d"}, want: "v2.3.1 (a1b2c3d)"},
+		{name: "untagged", git: gitinfo.Info{ShortCommit: "a1b2c3d"}, want: "(a1b2c3d)"},
+		{name: "dirty", git: gitinfo.Info{Tag: "v1", ShortCommit: "a1b2c3d", Dirty: true}, want: "v1 (a1b2c3d-dirty)"},
+	}
+	for _, c := range cases {
+		git := c.git
+		got, err := Info{Git: &git}.Expand(GitSubtitleTemplate)
+		if err != nil {
+			t.Fatalf("%s: Expand error: %v", c.name, err)
+		}
+		if got != c.want {
+			t.Fatalf("%s: got %q want %q", c.name, got, c.want)
+		}
+	}
+}
+
+// TestExpand_InvalidTemplate_Error expects errors for bad syntax and unknown fields.
+// This keeps typos in --subtitle from silently rendering empty text.
+func TestExpand_InvalidTemplate_Error(t *testing.T) {
+	info := Info{BuildID: "b"}
+	if _, err := info.Expand("{{.BuildID"); err == nil {
+		t.Fatalf("expected parse error")
+	}
+	if _, err := info.Expand("{{.Nope}}"); err == nil {
+		t.Fatalf("expected execute error")
+	} else if !strings.Contains(err.Error(), "execute template") {
+		t.Fatalf("unexpected error: %q", err.Error())
+	}
+}
+
+// TestFields_IncludesGitOnlyWhenPresent verifies the metadata keys with and without git information.
+func TestFields_IncludesGitOnlyWhenPresent(t *testing.T) {
+	info := Info{TargetName: "t", BuildID: "b"}
+	if got := len(info.Fields()); got != 3 {
+		t.Fatalf("expected 3 fields without git, got %d", got)
+	}
+	info.Git = &gitinfo.Info{Commit: "abc", Dirty: true}
+	fields := info.Fields()
+	last := fields[len(fields)-1]
+	if last.Key != "TSSH_GIT_DIRTY" || last.Value != "true" {
+		t.Fatalf("unexpected last field: %+v", last)
+	}
+}
+
+// TestExpand_LocaleFunctions expects the T and date template functions to follow the selected locale.
+func TestExpand_LocaleFunctions(t *testing.T) {
+	de, err := locale.Load("de_DE")
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	info := Info{BuildID: "42", Time: time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC), Locale: de}
+	got, err := info.Expand(`{{T "build"}} {{.BuildID}} ({{date}})`)
+	if err != nil {
+		t.Fatalf("Expand error: %v", err)
+	}
+	if got != "Build 42 (04.01.2026)" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+// TestExpand_Expressions evaluates expr: texts against release info with git metadata. The test fails if an operator,
+// slice, function, or variable gives a different value, or a syntax or type error is accepted.
+func TestExpand_Expressions(t *testing.T) {
+	de, err := locale.Load("de_DE")
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	info := Info{
+		TargetName: "kiosk",
+		BuildID:    "2026.01.04-1530",
+		Channel:    "beta",
+		Git:        &gitinfo.Info{ShortCommit: "a1b2c3d", Tag: "v2.3.1", Dirty: true},
+		Kernels:    []string{"6.1.0-18-amd64", "6.1.0-17-amd64"},
+		Time:       time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC),
+		Locale:     de,
+	}
+	cases := []struct{ expr, want string }{
+		{`expr: 'build ' + buildID[:10] + ' / ' + channel`, "build 2026.01.04 / beta"},
+		{`expr:buildID[-4:]`, "1530"},
+		{`expr: buildID[:99]`, "2026.01.04-1530"},
+		{`expr: git.tag != '' ? git.tag : git.shortCommit`, "v2.3.1"},
+		{`expr: git.shortCommit + (git.dirty ? "-dirty" : "")`, "a1b2c3d-dirty"},
+		{`expr: channel == 'stable' || channel == '' ? upper(targetName) : upper(targetName) + ' ' + upper(channel)`, "KIOSK BETA"},
+		{`expr: kernels[0] + ' (' + (len(kernels) == 2 ? 'latest of 2' : 'only') + ')'`, "6.1.0-18-amd64 (latest of 2)"},
+		{`expr: join(kernels, ', ')`, "6.1.0-18-amd64, 6.1.0-17-amd64"},
+		{`expr: T('build') + ' ' + date()`, "Build 04.01.2026"},
+		{`expr: len(buildID) > 10 && !git.dirty`, "false"},
+		{`expr: 'It\'s ' + trim('  ok ')`, "It's ok"},
+		{`{{.Channel}}`, "beta"},
+	}
+	for _, c := range cases {
+		got, err := info.Expand(c.expr)
+		if err != nil || got != c.want {
+			t.Fatalf("%s: got %q, %v; want %q", c.expr, got, err, c.want)
+		}
+	}
+	for _, expr := range []string{"expr: 'open", "expr: buildID +", "expr: os.line", "expr: nope(1)", "expr: buildID[1", "expr: kernels", "expr: 1 $ 2", "expr: kernels[5]", "expr: 'build ' + 1"} {
+		if got, err := info.Expand(expr); err == nil || !strings.HasPrefix(err.Error(), "release: expression") {
+			t.Fatalf("%s: expected an expression error, got %q, %v", expr, got, err)
+		}
+	}
+}
+
+// TestExpand_ExpressionLimits expects deeply nested parentheses, ! and - prefixes, and overlong expressions to fail
+// with an error instead of overflowing the stack, while nesting within the limit still evaluates.
+// The test fails if a nested or overlong expression is accepted or crashes the test binary.
+func TestExpand_ExpressionLimits(t *testing.T) {
+	nested := strings.Repeat("(", maxExprDepth-1) + "1" + strings.Repeat(")", maxExprDepth-1)
+	if got, err := (Info{}).Expand("expr: " + nested); err != nil || got != "1" {
+		t.Fatalf("nesting within the limit: got %q, %v", got, err)
+	}
+	for name, expr := range map[string]string{
+		"parentheses": strings.Repeat("(", 1000) + "1" + strings.Repeat(")", 1000),
+		"not":         strings.Repeat("!", 1000) + "true",
+		"negation":    strings.Repeat("-", 1000) + "1",
+		"mixed":       strings.Repeat("!(", 500) + "true" + strings.Repeat(")", 500),
+	} {
+		if got, err := (Info{}).Expand("expr: " + expr); err == nil || !strings.Contains(err.Error(), "nested deeper than") {
+			t.Fatalf("%s: expected a nesting error, got %q, %v", name, got, err)
+		}
+	}
+	for name, expr := range map[string]string{
+		"nesting":       strings.Repeat("!", 900000) + "true",
+		"long addition": strings.Repeat("1 + ", 100000) + "1",
+	} {
+		if got, err := (Info{}).Expand("expr: " + expr); err == nil || !strings.Contains(err.Error(), "want at most") {
+			t.Fatalf("%s: expected a length error, got %q, %v", name, got, err)
+		}
+	}
+}
+
+// TestCheckText_RejectsOrSanitizesNonPrintableRunes verifies both text policies on line breaks, controls, and invalid
+// UTF-8. The test fails if a newline survives either policy, shaping marks of emoji and RTL text are removed, or
+// unknown policies are accepted.
+func TestCheckText_RejectsOrSanitizesNonPrintableRunes(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"2026-01-04", "2026-01-04"},
+		{"1.2.3\n", "1.2.3"},
+		{"build\r\n42", "build 42"},
+		{"a \tb", "a b"},
+		{"\x00bell\x07", "bell"},
+		{"line\u2028break", "line break"},
+		{"bad\xffbyte", "badbyte"},
+		{"T\u00fcr \U0001F469\u200d\U0001F4BB \u200f\u05e9\u05dc\u05d5\u05dd", "T\u00fcr \U0001F469\u200d\U0001F4BB \u200f\u05e9\u05dc\u05d5\u05dd"},
+	}
+	for _, c := range cases {
+		got, err := CheckText(TextSanitize, "build ID", c.in)
+		if err != nil || got != c.want {
+			t.Fatalf("sanitize %q: got %q, %v; want %q", c.in, got, err, c.want)
+		}
+		got, err = CheckText(TextReject, "build ID", c.in)
+		if c.in == c.want && (err != nil || got != c.in) {
+			t.Fatalf("reject %q: got %q, %v", c.in, got, err)
+		}
+		if c.in != c.want && err == nil {
+			t.Fatalf("reject %q: expected error", c.in)
+		}
+	}
+	if _, err := CheckText(TextSanitize, "target name", "\n\t"); err == nil {
+		t.Fatalf("expected an error for text without printable runes")
+	}
+	if _, err := ParseTextPolicy("strip"); err == nil {
+		t.Fatalf("expected error for an unknown text policy")
+	}
+}
@@ -0,0 +1,84 @@
+package release
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TextPolicy selects how CheckText treats runes that cannot be shown on one line in the target name and build ID:
+// control characters such as line breaks and tabs, line and paragraph separators, other runes that are not graphic,
+// and invalid UTF-8. The joiners and direction marks that shape emoji and right-to-left text are allowed.
+type TextPolicy string
+
+const (
+	// TextReject fails on such runes, so a stray newline cannot corrupt the one-line build file.
+	TextReject TextPolicy = "reject"
+	// TextSanitize replaces line breaks, tabs, and other whitespace among them with a single space, drops the rest,
+	// and trims what this leaves at either end.
+	TextSanitize TextPolicy = "sanitize"
+)
+
+// TextPolicies returns all supported text policies in a stable order for help output and validation.
+func TextPolicies() []string {
+	return []string{string(TextReject), string(TextSanitize)}
+}
+
+// ParseTextPolicy validates a text policy name. Empty selects TextReject; unknown names return an error.
+func ParseTextPolicy(name string) (TextPolicy, error) {
+	if name == "" {
+		return TextReject, nil
+	}
+	for _, p := range TextPolicies() {
+		if name == p {
+			return TextPolicy(p), nil
+		}
+	}
+	return "", fmt.Errorf("release: unknown text policy %q (available: %s)", name, strings.Join(TextPolicies(), ", "))
+}
+
+// CheckText applies p to s, the value of what (e.g. "build ID"), and returns the text to render and write. It returns
+// an error if p rejects s, or if sanitizing leaves nothing of a non-empty s.
+func CheckText(p TextPolicy, what, s string) (string, error) {
+	var b strings.Builder
+	// space is set when sanitizing dropped whitespace that separates the next printable rune from the last one.
+	space, last := false, ' '
+	for i, r := range s {
+		if r == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(s[i:]); size == 1 {
+				if p == TextSanitize {
+					continue
+				}
+				return "", fmt.Errorf("release: %s %q contains invalid UTF-8 at byte %d", what, s, i)
+			}
+		}
+		if printable(r) {
+			if space && last != ' ' {
+				b.WriteByte(' ')
+			}
+			space, last = false, r
+			b.WriteRune(r)
+			continue
+		}
+		if p == TextSanitize {
+			space = space || unicode.IsSpace(r) || unicode.In(r, unicode.Zl, unicode.Zp)
+			continue
+		}
+		return "", fmt.Errorf("release: %s %q contains %U at byte %d", what, s, r, i)
+	}
+	if s != "" && b.Len() == 0 {
+		return "", fmt.Errorf("release: %s %q has no printable text", what, s)
+	}
+	return b.String(), nil
+}
+
+// printable reports whether r may appear in a one-line text.
+func printable(r rune) bool {
+	switch r {
+	case '\u200c', '\u200d', '\u200e', '\u200f':
+		// Zero-width non-joiner and joiner, left-to-right and right-to-left mark.
+		return true
+	}
+	return unicode.IsGraphic(r) && !unicode.In(r, unicode.Zl, unicode.Zp)
+}
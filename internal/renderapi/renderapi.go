@@ -0,0 +1,380 @@
+// Package renderapi holds the messages of api/tsrelease/v1/render.proto with their protobuf encoding. They are written
+// by hand on top of internal/rpc, so the field numbers here must follow the .proto file.
+package renderapi
+
+import (
+	"github.com/nickhildebrandt/ts-release/internal/rpc"
+)
+
+// Service is the path prefix of the RenderService methods, and the Method constants are their full paths.
+const (
+	Service       = "/tsrelease.v1.RenderService/"
+	MethodRender  = "/tsrelease.v1.RenderService/Render"
+	MethodInstall = "/tsrelease.v1.RenderService/Install"
+	MethodVerify  = "/tsrelease.v1.RenderService/Verify"
+)
+
+// ImageFormat selects the encoding of a rendered image.
+type ImageFormat int32
+
+const (
+	FormatUnspecified ImageFormat = 0
+	FormatPNG         ImageFormat = 1
+	FormatJPEG        ImageFormat = 2
+)
+
+// Stage is a step of a render or install reported in Progress.
+type Stage int32
+
+const (
+	StageUnspecified Stage = 0
+	StageFetch       Stage = 1
+	StageRender      Stage = 2
+	StageEncode      Stage = 3
+	StageInstall     Stage = 4
+)
+
+// FileState is the result of checking one installed file.
+type FileState int32
+
+const (
+	FileStateUnspecified FileState = 0
+	FileStateOK          FileState = 1
+	FileStateMismatch    FileState = 2
+	FileStateMissing     FileState = 3
+)
+
+// RenderRequest overrides the settings of the server's config file; empty fields keep them.
+type RenderRequest struct {
+	TargetName  string
+	BuildID     string
+	Subtitle    string
+	Channel     string
+	Layout      string
+	ThemeJSON   string
+	Width       int32
+	Height      int32
+	Format      ImageFormat
+	WallpaperID string
+}
+
+// Marshal encodes the message.
+func (m *RenderRequest) Marshal() []byte {
+	var e rpc.Encoder
+	e.String(1, m.TargetName)
+	e.String(2, m.BuildID)
+	e.String(3, m.Subtitle)
+	e.String(4, m.Channel)
+	e.String(5, m.Layout)
+	e.String(6, m.ThemeJSON)
+	e.Int(7, int64(m.Width))
+	e.Int(8, int64(m.Height))
+	e.Int(9, int64(m.Format))
+	e.String(10, m.WallpaperID)
+	return e.Encoded()
+}
+
+// Unmarshal decodes data into the message, which should be zero.
+func (m *RenderRequest) Unmarshal(data []byte) error {
+	return rpc.Decode(data, func(f rpc.Field) error {
+		switch f.Num {
+		case 1:
+			m.TargetName = f.String()
+		case 2:
+			m.BuildID = f.String()
+		case 3:
+			m.Subtitle = f.String()
+		case 4:
+			m.Channel = f.String()
+		case 5:
+			m.Layout = f.String()
+		case 6:
+			m.ThemeJSON = f.String()
+		case 7:
+			m.Width = int32(f.Int())
+		case 8:
+			m.Height = int32(f.Int())
+		case 9:
+			m.Format = ImageFormat(f.Int())
+		case 10:
+			m.WallpaperID = f.String()
+		}
+		return nil
+	})
+}
+
+// Progress announces the stage a call has reached.
+type Progress struct {
+	Stage   Stage
+	Message string
+}
+
+// Marshal encodes the message.
+func (m *Progress) Marshal() []byte {
+	var e rpc.Encoder
+	e.Int(1, int64(m.Stage))
+	e.String(2, m.Message)
+	return e.Encoded()
+}
+
+// Unmarshal decodes data into the message, which should be zero.
+func (m *Progress) Unmarshal(data []byte) error {
+	return rpc.Decode(data, func(f rpc.Field) error {
+		switch f.Num {
+		case 1:
+			m.Stage = Stage(f.Int())
+		case 2:
+			m.Message = f.String()
+		}
+		return nil
+	})
+}
+
+// RenderResult is the encoded wallpaper of a render.
+type RenderResult struct {
+	Image            []byte
+	ContentType      string
+	BuildID          string
+	WallpaperID      string
+	BackgroundSource string
+}
+
+// Marshal encodes the message.
+func (m *RenderResult) Marshal() []byte {
+	var e rpc.Encoder
+	e.Bytes(1, m.Image)
+	e.String(2, m.ContentType)
+	e.String(3, m.BuildID)
+	e.String(4, m.WallpaperID)
+	e.String(5, m.BackgroundSource)
+	return e.Encoded()
+}
+
+// Unmarshal decodes data into the message, which should be zero.
+func (m *RenderResult) Unmarshal(data []byte) error {
+	return rpc.Decode(data, func(f rpc.Field) error {
+		switch f.Num {
+		case 1:
+			m.Image = append([]byte(nil), f.Bytes...)
+		case 2:
+			m.ContentType = f.String()
+		case 3:
+			m.BuildID = f.String()
+		case 4:
+			m.WallpaperID = f.String()
+		case 5:
+			m.BackgroundSource = f.String()
+		}
+		return nil
+	})
+}
+
+// RenderEvent is one message of the Render stream; exactly one of its fields is set.
+type RenderEvent struct {
+	Progress *Progress
+	Result   *RenderResult
+}
+
+// Marshal encodes the message.
+func (m *RenderEvent) Marshal() []byte {
+	var e rpc.Encoder
+	if m.Progress != nil {
+		e.Message(1, m.Progress.Marshal())
+	}
+	if m.Result != nil {
+		e.Message(2, m.Result.Marshal())
+	}
+	return e.Encoded()
+}
+
+// Unmarshal decodes data into the message, which should be zero.
+func (m *RenderEvent) Unmarshal(data []byte) error {
+	return rpc.Decode(data, func(f rpc.Field) error {
+		switch f.Num {
+		case 1:
+			m.Progress, m.Result = &Progress{}, nil
+			return m.Progress.Unmarshal(f.Bytes)
+		case 2:
+			m.Progress, m.Result = nil, &RenderResult{}
+			return m.Result.Unmarshal(f.Bytes)
+		}
+		return nil
+	})
+}
+
+// InstallRequest generates all artifacts into RootFS, relative to the server's install root.
+type InstallRequest struct {
+	Render RenderRequest
+	RootFS string
+}
+
+// Marshal encodes the message.
+func (m *InstallRequest) Marshal() []byte {
+	var e rpc.Encoder
+	e.Message(1, m.Render.Marshal())
+	e.String(2, m.RootFS)
+	return e.Encoded()
+}
+
+// Unmarshal decodes data into the message, which should be zero.
+func (m *InstallRequest) Unmarshal(data []byte) error {
+	return rpc.Decode(data, func(f rpc.Field) error {
+		switch f.Num {
+		case 1:
+			return m.Render.Unmarshal(f.Bytes)
+		case 2:
+			m.RootFS = f.String()
+		}
+		return nil
+	})
+}
+
+// InstallResult describes a finished install; Files are relative to the rootfs.
+type InstallResult struct {
+	BuildID     string
+	WallpaperID string
+	Files       []string
+}
+
+// Marshal encodes the message.
+func (m *InstallResult) Marshal() []byte {
+	var e rpc.Encoder
+	e.String(1, m.BuildID)
+	e.String(2, m.WallpaperID)
+	e.Strings(3, m.Files)
+	return e.Encoded()
+}
+
+// Unmarshal decodes data into the message, which should be zero.
+func (m *InstallResult) Unmarshal(data []byte) error {
+	return rpc.Decode(data, func(f rpc.Field) error {
+		switch f.Num {
+		case 1:
+			m.BuildID = f.String()
+		case 2:
+			m.WallpaperID = f.String()
+		case 3:
+			m.Files = append(m.Files, f.String())
+		}
+		return nil
+	})
+}
+
+// InstallEvent is one message of the Install stream; exactly one of its fields is set.
+type InstallEvent struct {
+	Progress *Progress
+	Result   *InstallResult
+}
+
+// Marshal encodes the message.
+func (m *InstallEvent) Marshal() []byte {
+	var e rpc.Encoder
+	if m.Progress != nil {
+		e.Message(1, m.Progress.Marshal())
+	}
+	if m.Result != nil {
+		e.Message(2, m.Result.Marshal())
+	}
+	return e.Encoded()
+}
+
+// Unmarshal decodes data into the message, which should be zero.
+func (m *InstallEvent) Unmarshal(data []byte) error {
+	return rpc.Decode(data, func(f rpc.Field) error {
+		switch f.Num {
+		case 1:
+			m.Progress, m.Result = &Progress{}, nil
+			return m.Progress.Unmarshal(f.Bytes)
+		case 2:
+			m.Progress, m.Result = nil, &InstallResult{}
+			return m.Result.Unmarshal(f.Bytes)
+		}
+		return nil
+	})
+}
+
+// VerifyRequest names the rootfs to check, relative to the server's install root.
+type VerifyRequest struct {
+	RootFS string
+}
+
+// Marshal encodes the message.
+func (m *VerifyRequest) Marshal() []byte {
+	var e rpc.Encoder
+	e.String(1, m.RootFS)
+	return e.Encoded()
+}
+
+// Unmarshal decodes data into the message, which should be zero.
+func (m *VerifyRequest) Unmarshal(data []byte) error {
+	return rpc.Decode(data, func(f rpc.Field) error {
+		if f.Num == 1 {
+			m.RootFS = f.String()
+		}
+		return nil
+	})
+}
+
+// FileCheck is the state of one file listed in the manifest.
+type FileCheck struct {
+	Path  string
+	State FileState
+}
+
+// Marshal encodes the message.
+func (m *FileCheck) Marshal() []byte {
+	var e rpc.Encoder
+	e.String(1, m.Path)
+	e.Int(2, int64(m.State))
+	return e.Encoded()
+}
+
+// Unmarshal decodes data into the message, which should be zero.
+func (m *FileCheck) Unmarshal(data []byte) error {
+	return rpc.Decode(data, func(f rpc.Field) error {
+		switch f.Num {
+		case 1:
+			m.Path = f.String()
+		case 2:
+			m.State = FileState(f.Int())
+		}
+		return nil
+	})
+}
+
+// VerifyResponse reports the checked files; OK is true if all of them are present and unchanged.
+type VerifyResponse struct {
+	OK      bool
+	BuildID string
+	Files   []FileCheck
+}
+
+// Marshal encodes the message.
+func (m *VerifyResponse) Marshal() []byte {
+	var e rpc.Encoder
+	e.Bool(1, m.OK)
+	e.String(2, m.BuildID)
+	for i := range m.Files {
+		e.Message(3, m.Files[i].Marshal())
+	}
+	return e.Encoded()
+}
+
+// Unmarshal decodes data into the message, which should be zero.
+func (m *VerifyResponse) Unmarshal(data []byte) error {
+	return rpc.Decode(data, func(f rpc.Field) error {
+		switch f.Num {
+		case 1:
+			m.OK = f.Bool()
+		case 2:
+			m.BuildID = f.String()
+		case 3:
+			var check FileCheck
+			if err := check.Unmarshal(f.Bytes); err != nil {
+				return err
+			}
+			m.Files = append(m.Files, check)
+		}
+		return nil
+	})
+}
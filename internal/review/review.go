@@ -0,0 +1,179 @@
+// Package review keeps the wallpaper candidates downloaded for a human review in a directory with an HTML contact
+// sheet, and records the approved choice in a curated list that builds read with --curated.
+package review
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/wallhaven"
+	"github.com/nickhildebrandt/ts-release/internal/wallpaper"
+)
+
+// IndexName and CandidatesName are the contact sheet and the machine-readable candidate list in a review directory.
+const (
+	IndexName      = "index.html"
+	CandidatesName = "candidates.json"
+)
+
+// Candidate is a downloaded wallpaper offered for approval.
+type Candidate struct {
+	ID string `json:"id"`
+	// File is the image's name inside the review directory.
+	File       string `json:"file"`
+	PageURL    string `json:"page_url"`
+	ImageURL   string `json:"image_url"`
+	Resolution string `json:"resolution"`
+	FileSize   int64  `json:"file_size"`
+	Views      int    `json:"views"`
+	Favorites  int    `json:"favorites"`
+}
+
+// Sheet is a review round: the search it came from and its candidates in search order.
+type Sheet struct {
+	Query      string      `json:"query"`
+	Created    time.Time   `json:"created"`
+	Candidates []Candidate `json:"candidates"`
+}
+
+// Candidate returns the candidate with the given wallpaper ID or URL. It returns an error if ref is no wallpaper
+// reference or the sheet does not offer it.
+func (s Sheet) Candidate(ref string) (Candidate, error) {
+	id, err := wallhaven.ParseWallpaperID(ref)
+	if err != nil {
+		return Candidate{}, fmt.Errorf("review: %w", err)
+	}
+	for _, c := range s.Candidates {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return Candidate{}, fmt.Errorf("review: %s is not among the candidates", id)
+}
+
+// Write stores sheet in dir as CandidatesName and renders the contact sheet IndexName next to the images.
+// The approval command shown on the sheet names dir as given.
+func Write(dir string, sheet Sheet) error {
+	raw, err := json.MarshalIndent(sheet, "", "  ")
+	if err != nil {
+		return fmt.Errorf("review: %w", err)
+	}
+	var index bytes.Buffer
+	if err := indexTemplate.Execute(&index, struct {
+		Sheet
+		Dir string
+	}{sheet, dir}); err != nil {
+		return fmt.Errorf("review: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, CandidatesName), append(raw, '\n'), 0o644); err != nil {
+		return fmt.Errorf("review: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, IndexName), index.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("review: %w", err)
+	}
+	return nil
+}
+
+// Load reads the sheet a previous Write stored in dir.
+func Load(dir string) (Sheet, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, CandidatesName))
+	if err != nil {
+		return Sheet{}, fmt.Errorf("review: %w", err)
+	}
+	var sheet Sheet
+	if err := json.Unmarshal(raw, &sheet); err != nil {
+		return Sheet{}, fmt.Errorf("review: %s: %w", dir, err)
+	}
+	return sheet, nil
+}
+
+// Approve appends c to the curated list at path, creating the file if needed, with a comment naming the search and
+// the approval date. It reports false without changing the file if the list already has the wallpaper, and returns
+// an error if the existing list does not parse, so approvals never break the builds reading it.
+func Approve(path string, c Candidate, query string, at time.Time) (bool, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("review: %w", err)
+	}
+	if hasEntries(existing) {
+		pool, err := wallpaper.ParsePool(bytes.NewReader(existing))
+		if err != nil {
+			return false, fmt.Errorf("review: %s: %w", path, err)
+		}
+		for _, entry := range pool {
+			if entry.ID == c.ID {
+				return false, nil
+			}
+		}
+	}
+
+	var entry bytes.Buffer
+	if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+		entry.WriteByte('\n')
+	}
+	fmt.Fprintf(&entry, "# approved %s from %q\n%s\n", at.UTC().Format(time.DateOnly), query, c.ID)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, fmt.Errorf("review: %w", err)
+	}
+	if _, err := f.Write(entry.Bytes()); err != nil {
+		f.Close()
+		return false, fmt.Errorf("review: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return false, fmt.Errorf("review: %w", err)
+	}
+	return true, nil
+}
+
+// hasEntries reports whether a curated list has a line that is neither blank nor a comment; ParsePool rejects
+// lists without one, but a new list legitimately starts that way.
+func hasEntries(list []byte) bool {
+	for _, line := range strings.Split(string(list), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && !strings.HasPrefix(fields[0], "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// indexTemplate renders the contact sheet; html/template escapes the API-provided values.
+var indexTemplate = template.Must(template.New(IndexName).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Wallpaper candidates: {{.Query}}</title>
+<style>
+body { font-family: sans-serif; margin: 2rem; background: #1e1e1e; color: #eee; }
+main { display: grid; grid-template-columns: repeat(auto-fill, minmax(24rem, 1fr)); gap: 1.5rem; }
+figure { margin: 0; }
+img { width: 100%; aspect-ratio: 16 / 9; object-fit: cover; border-radius: 4px; }
+a { color: #8ab4f8; }
+code { user-select: all; }
+</style>
+</head>
+<body>
+<h1>Wallpaper candidates: {{.Query}}</h1>
+<p>{{len .Candidates}} candidates, downloaded {{.Created.Format "2006-01-02 15:04 MST"}}. Approve one with the command below it.</p>
+<main>
+{{- range .Candidates}}
+<figure id="{{.ID}}">
+<a href="{{.File}}"><img src="{{.File}}" alt="wallpaper {{.ID}}"></a>
+<figcaption>
+<a href="{{.PageURL}}">{{.ID}}</a> · {{.Resolution}} · {{.Views}} views · {{.Favorites}} favorites<br>
+<code>ts-release pick --out {{$.Dir}} --approve {{.ID}}</code>
+</figcaption>
+</figure>
+{{- end}}
+</main>
+</body>
+</html>
+`))
@@ -0,0 +1,78 @@
+package review
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/wallpaper"
+)
+
+// TestWriteLoadApprove expects a sheet to load back from its directory with an escaped contact sheet, and approvals
+// to append each wallpaper once to a curated list that ParsePool accepts. The test fails if a candidate is approved
+// twice, an unknown ID is accepted, or a broken list is appended to.
+func TestWriteLoadApprove(t *testing.T) {
+	dir := t.TempDir()
+	sheet := Sheet{
+		Query:   `nature <b>`,
+		Created: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+		Candidates: []Candidate{
+			{ID: "k7q1vd", File: "wallhaven-k7q1vd.jpg", PageURL: "https://wallhaven.cc/w/k7q1vd", Resolution: "3840x2160"},
+			{ID: "94x38z", File: "wallhaven-94x38z.png", PageURL: "https://wallhaven.cc/w/94x38z", Resolution: "2560x1440"},
+		},
+	}
+	if err := Write(dir, sheet); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	index, err := os.ReadFile(filepath.Join(dir, IndexName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(index), `src="wallhaven-94x38z.png"`) || !strings.Contains(string(index), "--approve k7q1vd") ||
+		strings.Contains(string(index), "<b>") {
+		t.Fatalf("contact sheet lacks a candidate or the approve command, or is not escaped:\n%s", index)
+	}
+	loaded, err := Load(dir)
+	if err != nil || len(loaded.Candidates) != 2 || loaded.Query != sheet.Query {
+		t.Fatalf("Load: got %+v, %v", loaded, err)
+	}
+
+	c, err := loaded.Candidate("https://wallhaven.cc/w/94x38z")
+	if err != nil || c.ID != "94x38z" {
+		t.Fatalf("Candidate by URL: got %+v, %v", c, err)
+	}
+	if _, err := loaded.Candidate("aaaaaa"); err == nil || !strings.Contains(err.Error(), "not among the candidates") {
+		t.Fatalf("unknown candidate: got %v", err)
+	}
+
+	list := filepath.Join(dir, "approved.txt")
+	if err := os.WriteFile(list, []byte("# marketing approved"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []bool{true, false} {
+		if added, err := Approve(list, c, sheet.Query, sheet.Created); err != nil || added != want {
+			t.Fatalf("approval %d: got %v, %v, want %v", i+1, added, err, want)
+		}
+	}
+	data, err := os.ReadFile(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := wallpaper.ParsePool(strings.NewReader(string(data)))
+	if err != nil || len(pool) != 1 || pool[0].ID != "94x38z" || !strings.Contains(string(data), "# approved 2026-10-01 from") {
+		t.Fatalf("curated list:\n%s\n%+v, %v", data, pool, err)
+	}
+
+	broken := filepath.Join(dir, "broken.txt")
+	if err := os.WriteFile(broken, []byte("not a wallpaper\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Approve(broken, c, sheet.Query, sheet.Created); err == nil {
+		t.Fatalf("expected an error for a broken list")
+	}
+	if data, _ := os.ReadFile(broken); string(data) != "not a wallpaper\n" {
+		t.Fatalf("broken list was changed: %q", data)
+	}
+}
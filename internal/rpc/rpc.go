@@ -0,0 +1,279 @@
+// Package rpc serves and calls gRPC methods over HTTP/2 with the standard library, including unencrypted HTTP/2 by
+// prior knowledge, and encodes protobuf messages without the protobuf runtime. It supports unary and server-streaming
+// calls without message compression, which is what the render service needs.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Code is a gRPC status code.
+type Code int
+
+// Status codes used by the render service, numbered as in the gRPC specification.
+const (
+	OK                 Code = 0
+	Canceled           Code = 1
+	Unknown            Code = 2
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	PermissionDenied   Code = 7
+	ResourceExhausted  Code = 8
+	FailedPrecondition Code = 9
+	Unimplemented      Code = 12
+	Internal           Code = 13
+	Unavailable        Code = 14
+)
+
+var codeNames = map[Code]string{
+	OK: "OK", Canceled: "Canceled", Unknown: "Unknown", InvalidArgument: "InvalidArgument",
+	DeadlineExceeded: "DeadlineExceeded", NotFound: "NotFound", PermissionDenied: "PermissionDenied",
+	ResourceExhausted: "ResourceExhausted", FailedPrecondition: "FailedPrecondition",
+	Unimplemented: "Unimplemented", Internal: "Internal", Unavailable: "Unavailable",
+}
+
+// String returns the name of the code as in the gRPC specification.
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return "Code(" + strconv.Itoa(int(c)) + ")"
+}
+
+// Error is a call that ended with a status other than OK.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+// Errorf returns an Error with the code and a formatted message.
+func Errorf(code Code, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc: %s: %s", e.Code, e.Message)
+}
+
+// CodeOf returns the code of err: OK for nil, the code of an Error, and Unknown otherwise.
+func CodeOf(err error) Code {
+	var rpcErr *Error
+	switch {
+	case err == nil:
+		return OK
+	case errors.As(err, &rpcErr):
+		return rpcErr.Code
+	default:
+		return Unknown
+	}
+}
+
+// Handler answers a call to one method with the encoded request, passing every response message to send. Unary
+// methods send exactly one. A returned error that is no Error is reported with code Unknown.
+type Handler func(ctx context.Context, req []byte, send func([]byte) error) error
+
+// Server dispatches gRPC calls by their "/package.Service/Method" path. It is an http.Handler.
+type Server struct {
+	handlers map[string]Handler
+	// maxMessage limits the size of a request message.
+	maxMessage int
+}
+
+// NewServer returns a server without methods that rejects request messages larger than maxMessage bytes.
+func NewServer(maxMessage int) *Server {
+	return &Server{handlers: map[string]Handler{}, maxMessage: maxMessage}
+}
+
+// Handle registers h for the method, given as its full path such as "/tsrelease.v1.RenderService/Render".
+func (s *Server) Handle(method string, h Handler) {
+	s.handlers[method] = h
+}
+
+// ServeHTTP answers one gRPC call. Requests that are not gRPC over HTTP/2 get a plain HTTP error.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 || r.Method != http.MethodPost {
+		http.Error(w, "gRPC needs POST over HTTP/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "application/grpc" && !strings.HasPrefix(ct, "application/grpc+proto") {
+		http.Error(w, "unsupported content type "+ct, http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	err := s.call(w, r)
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(CodeOf(err))))
+	if err != nil {
+		message := err.Error()
+		var rpcErr *Error
+		if errors.As(err, &rpcErr) {
+			message = rpcErr.Message
+		}
+		w.Header().Set("Grpc-Message", encodeMessage(message))
+	}
+}
+
+// call reads the request of a call, runs its handler, and writes the responses.
+func (s *Server) call(w http.ResponseWriter, r *http.Request) error {
+	h := s.handlers[r.URL.Path]
+	if h == nil {
+		return Errorf(Unimplemented, "unknown method %s", r.URL.Path)
+	}
+	ctx := r.Context()
+	if timeout := r.Header.Get("Grpc-Timeout"); timeout != "" {
+		d, err := parseTimeout(timeout)
+		if err != nil {
+			return Errorf(InvalidArgument, "%v", err)
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	req, err := readMessage(r.Body, s.maxMessage)
+	if err != nil {
+		return err
+	}
+	if _, err := readMessage(r.Body, s.maxMessage); !errors.Is(err, io.EOF) {
+		return Errorf(InvalidArgument, "expected a single request message")
+	}
+	flusher := http.NewResponseController(w)
+	err = h(ctx, req, func(msg []byte) error {
+		if _, err := w.Write(frame(msg)); err != nil {
+			return err
+		}
+		return flusher.Flush()
+	})
+	if err != nil && CodeOf(err) == Unknown && ctx.Err() != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return Errorf(DeadlineExceeded, "%v", err)
+		}
+		return Errorf(Canceled, "%v", err)
+	}
+	return err
+}
+
+// frame prefixes a message with the uncompressed flag and its length.
+func frame(msg []byte) []byte {
+	b := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(b[1:], uint32(len(msg)))
+	return append(b, msg...)
+}
+
+// readMessage reads one length-prefixed message. It returns io.EOF if the stream ends before a message.
+func readMessage(r io.Reader, max int) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, Errorf(Internal, "read message header: %v", err)
+	}
+	if header[0] != 0 {
+		return nil, Errorf(Unimplemented, "compressed messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if uint64(size) > uint64(max) {
+		return nil, Errorf(ResourceExhausted, "message of %d bytes exceeds the limit of %d", size, max)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, Errorf(Internal, "read message: %v", err)
+	}
+	return msg, nil
+}
+
+// parseTimeout parses a grpc-timeout header value such as "100m".
+func parseTimeout(value string) (time.Duration, error) {
+	units := map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second, 'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond}
+	if len(value) < 2 || len(value) > 9 || units[value[len(value)-1]] == 0 {
+		return 0, fmt.Errorf("malformed grpc-timeout %q", value)
+	}
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("malformed grpc-timeout %q", value)
+	}
+	return time.Duration(n) * units[value[len(value)-1]], nil
+}
+
+// encodeMessage percent-encodes a grpc-message value as the gRPC specification requires.
+func encodeMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		if c := message[i]; c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// NewClient returns an HTTP client that speaks HTTP/2 to http:// targets by prior knowledge, as gRPC servers expect.
+func NewClient() *http.Client {
+	var protocols http.Protocols
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	return &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+}
+
+// Call invokes method at target, a base URL such as http://localhost:8080, with the encoded request and passes every
+// response message to recv. It returns an Error for a status other than OK and other errors for transport failures.
+func Call(ctx context.Context, client *http.Client, target, method string, req []byte, recv func([]byte) error) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(target, "/")+method, bytes.NewReader(frame(req)))
+	if err != nil {
+		return fmt.Errorf("rpc: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/grpc")
+	httpReq.Header.Set("TE", "trailers")
+	if deadline, ok := ctx.Deadline(); ok {
+		httpReq.Header.Set("Grpc-Timeout", strconv.FormatInt(max(time.Until(deadline).Milliseconds(), 0), 10)+"m")
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("rpc: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("rpc: %s: HTTP %s", method, resp.Status)
+	}
+	for {
+		msg, err := readMessage(resp.Body, 1<<30)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := recv(msg); err != nil {
+			return err
+		}
+	}
+	// A trailers-only response carries the status in the headers.
+	status := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return fmt.Errorf("rpc: %s: missing grpc-status", method)
+	}
+	if Code(code) != OK {
+		if unescaped, err := url.PathUnescape(message); err == nil {
+			message = unescaped
+		}
+		return &Error{Code: Code(code), Message: message}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestEncoderDecode_RoundTripsProtobufFields expects the encoder to produce the protobuf wire format byte for byte
+// and Decode to read it back, including negative integers and repeated strings. The test fails if the bytes differ
+// from protobuf's or a truncated message is accepted.
+func TestEncoderDecode_RoundTripsProtobufFields(t *testing.T) {
+	var e Encoder
+	e.String(1, "a")
+	e.Int(7, 1280)
+	e.Int(2, 0)
+	e.Bool(3, true)
+	e.Strings(4, []string{"x", ""})
+	// Bytes as protoc encodes: string 1 "a", int32 7 1280, bool 3 true, repeated string 4 "x" and "".
+	want := []byte{0x0a, 0x01, 'a', 0x38, 0x80, 0x0a, 0x18, 0x01, 0x22, 0x01, 'x', 0x22, 0x00}
+	if !bytes.Equal(e.Encoded(), want) {
+		t.Fatalf("encoded % x, want % x", e.Encoded(), want)
+	}
+
+	var neg Encoder
+	neg.Int(5, -1)
+	var got []Field
+	msg := append(append(append([]byte{}, e.Encoded()...), neg.Encoded()...), 0x4d, 1, 0, 0, 0)
+	if err := Decode(msg, func(f Field) error {
+		got = append(got, f)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 7 || got[0].String() != "a" || got[1].Int() != 1280 || !got[2].Bool() || got[4].String() != "" ||
+		got[5].Int() != -1 || got[6].Num != 9 || got[6].Varint != 1 {
+		t.Fatalf("decoded %+v", got)
+	}
+	if err := Decode(want[:len(want)-3], func(Field) error { return nil }); err == nil {
+		t.Fatal("expected an error for a truncated message")
+	}
+}
+
+// TestServerCall_StreamsMessagesAndStatus expects a call over unencrypted HTTP/2 to receive every streamed message
+// and the handler's status, with unknown methods, oversized requests, and deadlines mapped to their codes. The test
+// fails if a message or status code is lost.
+func TestServerCall_StreamsMessagesAndStatus(t *testing.T) {
+	server := NewServer(64)
+	server.Handle("/test.v1.Echo/Repeat", func(ctx context.Context, req []byte, send func([]byte) error) error {
+		for i := 0; i < 3; i++ {
+			if err := send(req); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	server.Handle("/test.v1.Echo/Fail", func(ctx context.Context, req []byte, send func([]byte) error) error {
+		if err := send([]byte("partial")); err != nil {
+			return err
+		}
+		return Errorf(InvalidArgument, "bad 100%% input")
+	})
+	server.Handle("/test.v1.Echo/Wait", func(ctx context.Context, req []byte, send func([]byte) error) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	ts := httptest.NewUnstartedServer(server)
+	ts.Config.Protocols = new(http.Protocols)
+	ts.Config.Protocols.SetHTTP1(true)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	defer ts.Close()
+	client := NewClient()
+
+	var got []string
+	collect := func(msg []byte) error {
+		got = append(got, string(msg))
+		return nil
+	}
+	if err := Call(context.Background(), client, ts.URL, "/test.v1.Echo/Repeat", []byte("hi"), collect); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "hi,hi,hi" {
+		t.Fatalf("streamed %q", got)
+	}
+
+	got = nil
+	err := Call(context.Background(), client, ts.URL, "/test.v1.Echo/Fail", nil, collect)
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != InvalidArgument || rpcErr.Message != "bad 100% input" || len(got) != 1 {
+		t.Fatalf("Fail: got %v after %q", err, got)
+	}
+	if err := Call(context.Background(), client, ts.URL, "/test.v1.Echo/Missing", nil, collect); CodeOf(err) != Unimplemented {
+		t.Fatalf("unknown method: got %v", err)
+	}
+	if err := Call(context.Background(), client, ts.URL, "/test.v1.Echo/Repeat", make([]byte, 65), collect); CodeOf(err) != ResourceExhausted {
+		t.Fatalf("oversized request: got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := Call(ctx, client, ts.URL, "/test.v1.Echo/Wait", nil, collect); err == nil {
+		t.Fatal("expected the deadline to end the call")
+	}
+
+	resp, err := http.Post(ts.URL+"/test.v1.Echo/Repeat", "application/grpc", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusHTTPVersionNotSupported {
+		t.Fatalf("HTTP/1.1 request: got %s", resp.Status)
+	}
+}
@@ -0,0 +1,168 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Wire types of the protobuf encoding.
+const (
+	WireVarint  = 0
+	WireFixed64 = 1
+	WireBytes   = 2
+	WireFixed32 = 5
+)
+
+// Encoder builds a protobuf message field by field. Scalar fields with their zero value are omitted, as proto3 does.
+type Encoder struct {
+	b []byte
+}
+
+// tag appends the key of a field.
+func (e *Encoder) tag(field, wireType int) {
+	e.b = binary.AppendUvarint(e.b, uint64(field)<<3|uint64(wireType))
+}
+
+// Uint appends an unsigned varint field such as an enum.
+func (e *Encoder) Uint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, WireVarint)
+	e.b = binary.AppendUvarint(e.b, v)
+}
+
+// Varint appends a varint field even if it is zero, e.g. for a set oneof member.
+func (e *Encoder) Varint(field int, v uint64) {
+	e.tag(field, WireVarint)
+	e.b = binary.AppendUvarint(e.b, v)
+}
+
+// Fixed64 appends a fixed64 field such as a timestamp in nanoseconds.
+func (e *Encoder) Fixed64(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, WireFixed64)
+	e.b = binary.LittleEndian.AppendUint64(e.b, v)
+}
+
+// Int appends an int32 or int64 field; negative values take ten bytes, like in protobuf.
+func (e *Encoder) Int(field int, v int64) {
+	e.Uint(field, uint64(v))
+}
+
+// Bool appends a bool field.
+func (e *Encoder) Bool(field int, v bool) {
+	if v {
+		e.Uint(field, 1)
+	}
+}
+
+// String appends a string field.
+func (e *Encoder) String(field int, s string) {
+	if s != "" {
+		e.Bytes(field, []byte(s))
+	}
+}
+
+// Strings appends a repeated string field, including empty elements.
+func (e *Encoder) Strings(field int, values []string) {
+	for _, s := range values {
+		e.Message(field, []byte(s))
+	}
+}
+
+// Bytes appends a bytes field.
+func (e *Encoder) Bytes(field int, b []byte) {
+	if len(b) > 0 {
+		e.Message(field, b)
+	}
+}
+
+// Message appends an embedded message even if it is empty, so a set oneof member stays recognizable.
+func (e *Encoder) Message(field int, encoded []byte) {
+	e.tag(field, WireBytes)
+	e.b = binary.AppendUvarint(e.b, uint64(len(encoded)))
+	e.b = append(e.b, encoded...)
+}
+
+// Encoded returns the message built so far.
+func (e *Encoder) Encoded() []byte {
+	return e.b
+}
+
+// Field is one field of a decoded protobuf message. Varint holds varint and fixed-size values, Bytes the contents of
+// length-delimited ones.
+type Field struct {
+	Num    int
+	Type   int
+	Varint uint64
+	Bytes  []byte
+}
+
+// String returns the value of a string field.
+func (f Field) String() string {
+	return string(f.Bytes)
+}
+
+// Int returns the value of an int32 or int64 field.
+func (f Field) Int() int64 {
+	return int64(f.Varint)
+}
+
+// Bool returns the value of a bool field.
+func (f Field) Bool() bool {
+	return f.Varint != 0
+}
+
+// Decode calls fn for every field of the protobuf message in data, in encoding order. It returns an error for a
+// truncated message, an unsupported wire type, or the first error of fn.
+func Decode(data []byte, fn func(Field) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("rpc: decode: truncated field key")
+		}
+		data = data[n:]
+		f := Field{Num: int(key >> 3), Type: int(key & 7)}
+		if f.Num == 0 {
+			return errors.New("rpc: decode: field number 0")
+		}
+		switch f.Type {
+		case WireVarint:
+			if f.Varint, n = binary.Uvarint(data); n <= 0 {
+				return fmt.Errorf("rpc: decode: field %d: truncated varint", f.Num)
+			}
+			data = data[n:]
+		case WireFixed64, WireFixed32:
+			size := 8
+			if f.Type == WireFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return fmt.Errorf("rpc: decode: field %d: truncated fixed value", f.Num)
+			}
+			if size == 8 {
+				f.Varint = binary.LittleEndian.Uint64(data)
+			} else {
+				f.Varint = uint64(binary.LittleEndian.Uint32(data))
+			}
+			data = data[size:]
+		case WireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return fmt.Errorf("rpc: decode: field %d: truncated length-delimited value", f.Num)
+			}
+			f.Bytes = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			return fmt.Errorf("rpc: decode: field %d: unsupported wire type %d", f.Num, f.Type)
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
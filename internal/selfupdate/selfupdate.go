@@ -0,0 +1,260 @@
+// Package selfupdate publishes signed releases of the ts-release executable and replaces a running executable with
+// the release for its platform, for hosts without a package manager.
+//
+// A release endpoint is a directory served over HTTP(S) that holds release.json, release.json.sig (the raw Ed25519
+// signature of release.json), and the executables release.json lists with their platform and SHA-256. The signature
+// covers the version and the checksums, so an executable is only installed if the key holder published it under
+// that version.
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ManifestName and SignatureName are the files of a release endpoint besides the executables.
+const (
+	ManifestName  = "release.json"
+	SignatureName = "release.json.sig"
+)
+
+// maxManifestSize and maxBinarySize bound downloads, so a broken endpoint cannot exhaust memory.
+const (
+	maxManifestSize = 1 << 20
+	maxBinarySize   = 512 << 20
+)
+
+// ErrSignature is wrapped when release.json does not verify with the given public key.
+var ErrSignature = errors.New("invalid signature")
+
+// Binary is the executable of one platform.
+type Binary struct {
+	// Platform is GOOS/GOARCH, e.g. "linux/amd64".
+	Platform string `json:"platform"`
+	// URL locates the executable, relative to the endpoint or absolute.
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// Release describes a published version and its executables.
+type Release struct {
+	Version  string    `json:"version"`
+	Created  time.Time `json:"created"`
+	Binaries []Binary  `json:"binaries"`
+}
+
+// Platform returns the platform of the running executable, e.g. "linux/amd64".
+func Platform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// Binary returns the executable of platform.
+func (r Release) Binary(platform string) (Binary, bool) {
+	i := slices.IndexFunc(r.Binaries, func(b Binary) bool { return b.Platform == platform })
+	if i < 0 {
+		return Binary{}, false
+	}
+	return r.Binaries[i], true
+}
+
+// FileName returns the name Publish gives the executable of platform, e.g. "ts-release-linux-amd64" or
+// "ts-release-windows-amd64.exe".
+func FileName(platform string) string {
+	name := "ts-release-" + strings.ReplaceAll(platform, "/", "-")
+	if strings.HasPrefix(platform, "windows/") {
+		name += ".exe"
+	}
+	return name
+}
+
+// Publish writes a release endpoint into dir: the executables, named by FileName, and the manifest of version with
+// its signature by key. executables maps platforms to their files. Existing files of the same names are replaced.
+func Publish(dir string, key ed25519.PrivateKey, version string, created time.Time, executables map[string]string) error {
+	if version == "" {
+		return fmt.Errorf("selfupdate: empty version")
+	}
+	if len(executables) == 0 {
+		return fmt.Errorf("selfupdate: no executables")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("selfupdate: %w", err)
+	}
+	release := Release{Version: version, Created: created.UTC()}
+	for platform, file := range executables {
+		goos, goarch, ok := strings.Cut(platform, "/")
+		if !ok || goos == "" || goarch == "" || strings.Contains(goarch, "/") {
+			return fmt.Errorf("selfupdate: platform %q: want GOOS/GOARCH, e.g. linux/amd64", platform)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("selfupdate: %w", err)
+		}
+		name := FileName(platform)
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			return fmt.Errorf("selfupdate: %w", err)
+		}
+		sum := sha256.Sum256(data)
+		release.Binaries = append(release.Binaries, Binary{Platform: platform, URL: name, SHA256: hex.EncodeToString(sum[:]), Size: int64(len(data))})
+	}
+	slices.SortFunc(release.Binaries, func(a, b Binary) int { return strings.Compare(a.Platform, b.Platform) })
+	raw, err := json.MarshalIndent(release, "", "  ")
+	if err != nil {
+		return fmt.Errorf("selfupdate: %w", err)
+	}
+	raw = append(raw, '\n')
+	if err := os.WriteFile(filepath.Join(dir, ManifestName), raw, 0o644); err != nil {
+		return fmt.Errorf("selfupdate: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, SignatureName), ed25519.Sign(key, raw), 0o644); err != nil {
+		return fmt.Errorf("selfupdate: %w", err)
+	}
+	return nil
+}
+
+// Check fetches the release of the endpoint at base and verifies it with pub. It returns an error wrapping
+// ErrSignature if release.json was not signed with the matching key or was altered.
+func Check(ctx context.Context, client *http.Client, base string, pub ed25519.PublicKey) (Release, error) {
+	raw, err := fetch(ctx, client, base, ManifestName, maxManifestSize)
+	if err != nil {
+		return Release{}, err
+	}
+	sig, err := fetch(ctx, client, base, SignatureName, ed25519.SignatureSize)
+	if err != nil {
+		return Release{}, err
+	}
+	if !ed25519.Verify(pub, raw, sig) {
+		return Release{}, fmt.Errorf("selfupdate: %s: %w", ManifestName, ErrSignature)
+	}
+	var release Release
+	if err := json.Unmarshal(raw, &release); err != nil {
+		return Release{}, fmt.Errorf("selfupdate: decode %s: %w", ManifestName, err)
+	}
+	if release.Version == "" {
+		return Release{}, fmt.Errorf("selfupdate: %s has no version", ManifestName)
+	}
+	return release, nil
+}
+
+// Download fetches the executable b of the endpoint at base and returns it if it matches its SHA-256.
+func Download(ctx context.Context, client *http.Client, base string, b Binary) ([]byte, error) {
+	data, err := fetch(ctx, client, base, b.URL, maxBinarySize)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != b.SHA256 {
+		return nil, fmt.Errorf("selfupdate: %s: SHA-256 %s does not match the signed %s", b.URL, got, b.SHA256)
+	}
+	return data, nil
+}
+
+// fetch reads ref, resolved against the endpoint base, and fails for bodies larger than limit.
+func fetch(ctx context.Context, client *http.Client, base, ref string, limit int64) ([]byte, error) {
+	u, err := resolve(base, ref)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("selfupdate: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("selfupdate: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("selfupdate: GET %s: %s", u, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("selfupdate: GET %s: %w", u, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("selfupdate: GET %s: larger than %d bytes", u, limit)
+	}
+	return data, nil
+}
+
+// resolve returns ref relative to the directory of the endpoint base.
+func resolve(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil || (b.Scheme != "https" && b.Scheme != "http") || b.Host == "" {
+		return "", fmt.Errorf("selfupdate: release URL %q: want an http or https URL", base)
+	}
+	if !strings.HasSuffix(b.Path, "/") {
+		b.Path = path.Join(b.Path) + "/"
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("selfupdate: %q: %w", ref, err)
+	}
+	return b.ResolveReference(r).String(), nil
+}
+
+// Replace atomically replaces the executable at path with data, keeping its permissions: data is written and
+// flushed to a temporary file next to it, which is then renamed over it, so a crash leaves either the old or the
+// new executable. Windows cannot replace a running executable, so the old one is first moved aside to path.old.
+func Replace(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("selfupdate: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("selfupdate: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, bytes.NewReader(data))
+	if err == nil {
+		err = tmp.Chmod(info.Mode().Perm())
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("selfupdate: %w", err)
+	}
+	if runtime.GOOS == "windows" {
+		old := path + ".old"
+		os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return fmt.Errorf("selfupdate: %w", err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("selfupdate: %w", err)
+	}
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("selfupdate: %w", err)
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("selfupdate: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPublishCheckReplace publishes a release, checks and downloads it from a server, and replaces an executable
+// with it. The test fails if a release signed with another key or an altered executable is accepted, or the replaced
+// file loses its mode.
+func TestPublishCheckReplace(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := t.TempDir()
+	for name, data := range map[string]string{"amd64": "new amd64 executable", "arm64": "new arm64 executable"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(data), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir := filepath.Join(t.TempDir(), "releases")
+	executables := map[string]string{"linux/arm64": filepath.Join(src, "arm64"), "linux/amd64": filepath.Join(src, "amd64")}
+	if err := Publish(dir, key, "1.5.0", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), executables); err != nil {
+		t.Fatalf("Publish error: %v", err)
+	}
+	if err := Publish(dir, key, "1.5.0", time.Now(), map[string]string{"linux": filepath.Join(src, "amd64")}); err == nil {
+		t.Fatalf("expected a platform without GOARCH to be rejected")
+	}
+	server := httptest.NewServer(http.StripPrefix("/releases/", http.FileServer(http.Dir(dir))))
+	defer server.Close()
+	ctx := context.Background()
+
+	release, err := Check(ctx, server.Client(), server.URL+"/releases", pub)
+	if err != nil {
+		t.Fatalf("Check error: %v", err)
+	}
+	b, ok := release.Binary("linux/arm64")
+	if release.Version != "1.5.0" || len(release.Binaries) != 2 || !ok || b.URL != "ts-release-linux-arm64" {
+		t.Fatalf("release %+v", release)
+	}
+	data, err := Download(ctx, server.Client(), server.URL+"/releases/", b)
+	if err != nil || string(data) != "new arm64 executable" {
+		t.Fatalf("Download: %q, %v", data, err)
+	}
+
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Check(ctx, server.Client(), server.URL+"/releases", other); !errors.Is(err, ErrSignature) {
+		t.Fatalf("expected ErrSignature for another key, got %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, b.URL), []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Download(ctx, server.Client(), server.URL+"/releases", b); err == nil || !strings.Contains(err.Error(), "does not match the signed") {
+		t.Fatalf("expected the altered executable to be rejected, got %v", err)
+	}
+
+	exe := filepath.Join(t.TempDir(), "ts-release")
+	if err := os.WriteFile(exe, []byte("old executable"), 0o750); err != nil {
+		t.Fatal(err)
+	}
+	if err := Replace(exe, data); err != nil {
+		t.Fatalf("Replace error: %v", err)
+	}
+	info, err := os.Stat(exe)
+	if got, _ := os.ReadFile(exe); err != nil || string(got) != "new arm64 executable" || info.Mode().Perm() != 0o750 {
+		t.Fatalf("replaced executable %q, %v", got, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(exe)); len(entries) != 1 {
+		t.Fatalf("Replace left %d files behind", len(entries)-1)
+	}
+}
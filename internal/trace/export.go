@@ -0,0 +1,373 @@
+package trace
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/rpc"
+)
+
+// scopeName is the instrumentation scope of all spans.
+const scopeName = "github.com/nickhildebrandt/ts-release"
+
+// Protocols of OTEL_EXPORTER_OTLP_PROTOCOL.
+const (
+	protocolProtobuf = "http/protobuf"
+	protocolJSON     = "http/json"
+)
+
+// exporter sends spans to an OTLP/HTTP traces endpoint.
+type exporter struct {
+	endpoint string
+	protocol string
+	gzip     bool
+	headers  map[string]string
+	timeout  time.Duration
+	resource []Attr
+	version  string
+	client   *http.Client
+}
+
+// Configure sets up export from the standard OpenTelemetry environment variables read with getenv; version is
+// reported as service.version. Export is on once an OTLP endpoint is set or OTEL_TRACES_EXPORTER is otlp, and off
+// for OTEL_TRACES_EXPORTER=none or OTEL_SDK_DISABLED=true. Unsupported settings leave export off and return an error.
+func (t *Tracer) Configure(getenv func(string) string, version string) error {
+	exp, sampler, err := newExporter(getenv, version)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.exporter, t.sampler = exp, sampler
+	return err
+}
+
+// newExporter returns the exporter and sampler of the environment, or a nil exporter if export is off.
+func newExporter(getenv func(string) string, version string) (*exporter, string, error) {
+	// signal returns the traces-specific variable of an OTLP setting, falling back to the general one.
+	signal := func(name string) string {
+		if v := getenv("OTEL_EXPORTER_OTLP_TRACES_" + name); v != "" {
+			return v
+		}
+		return getenv("OTEL_EXPORTER_OTLP_" + name)
+	}
+	if strings.EqualFold(getenv("OTEL_SDK_DISABLED"), "true") {
+		return nil, "", nil
+	}
+	kind := getenv("OTEL_TRACES_EXPORTER")
+	switch kind {
+	case "", "otlp":
+	case "none":
+		return nil, "", nil
+	default:
+		return nil, "", fmt.Errorf("trace: unsupported OTEL_TRACES_EXPORTER %q (available: otlp, none)", kind)
+	}
+	sampler := getenv("OTEL_TRACES_SAMPLER")
+	switch sampler {
+	case "", samplerParentAlwaysOn, samplerParentAlwaysOff, samplerAlwaysOn, samplerAlwaysOff:
+	default:
+		return nil, "", fmt.Errorf("trace: unsupported OTEL_TRACES_SAMPLER %q (available: %s, %s, %s, %s)",
+			sampler, samplerParentAlwaysOn, samplerParentAlwaysOff, samplerAlwaysOn, samplerAlwaysOff)
+	}
+
+	endpoint := getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		base := getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			if kind == "" {
+				// Without any setting ts-release does not expect a collector, unlike an SDK that was added on purpose.
+				return nil, "", nil
+			}
+			base = "http://localhost:4318"
+		}
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, "", fmt.Errorf("trace: invalid OTLP endpoint %q: want an http or https URL", endpoint)
+	}
+	exp := &exporter{endpoint: endpoint, protocol: signal("PROTOCOL"), version: version, client: &http.Client{}}
+	switch exp.protocol {
+	case "":
+		exp.protocol = protocolProtobuf
+	case protocolProtobuf, protocolJSON:
+	default:
+		return nil, "", fmt.Errorf("trace: unsupported OTLP protocol %q (available: %s, %s)", exp.protocol, protocolProtobuf, protocolJSON)
+	}
+	switch compression := signal("COMPRESSION"); compression {
+	case "", "none":
+	case "gzip":
+		exp.gzip = true
+	default:
+		return nil, "", fmt.Errorf("trace: unsupported OTLP compression %q (available: gzip, none)", compression)
+	}
+	var err error
+	if exp.headers, err = parsePairs(signal("HEADERS")); err != nil {
+		return nil, "", fmt.Errorf("trace: OTLP headers: %w", err)
+	}
+	exp.timeout = 10 * time.Second
+	if v := signal("TIMEOUT"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms <= 0 {
+			return nil, "", fmt.Errorf("trace: invalid OTLP timeout %q: want milliseconds", v)
+		}
+		exp.timeout = time.Duration(ms) * time.Millisecond
+	}
+
+	resource, err := parsePairs(getenv("OTEL_RESOURCE_ATTRIBUTES"))
+	if err != nil {
+		return nil, "", fmt.Errorf("trace: OTEL_RESOURCE_ATTRIBUTES: %w", err)
+	}
+	if name := getenv("OTEL_SERVICE_NAME"); name != "" {
+		resource["service.name"] = name
+	} else if resource["service.name"] == "" {
+		resource["service.name"] = "ts-release"
+	}
+	resource["service.version"] = version
+	for _, key := range sortedKeys(resource) {
+		exp.resource = append(exp.resource, String(key, resource[key]))
+	}
+	return exp, sampler, nil
+}
+
+// parsePairs parses the comma-separated key=value list of OTEL_EXPORTER_OTLP_HEADERS and OTEL_RESOURCE_ATTRIBUTES,
+// whose values are percent-encoded.
+func parsePairs(s string) (map[string]string, error) {
+	pairs := map[string]string{}
+	for _, item := range strings.Split(s, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid pair %q: want key=value", item)
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pair %q: %w", item, err)
+		}
+		pairs[key] = value
+	}
+	return pairs, nil
+}
+
+// sortedKeys returns the keys of m in order, so resources encode the same way every time.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// export sends spans in one ExportTraceServiceRequest.
+func (e *exporter) export(ctx context.Context, spans []spanData) error {
+	var body []byte
+	contentType := "application/x-protobuf"
+	if e.protocol == protocolJSON {
+		contentType = "application/json"
+		var err error
+		if body, err = json.Marshal(e.jsonRequest(spans)); err != nil {
+			return err
+		}
+	} else {
+		body = e.protobufRequest(spans)
+	}
+	if e.gzip {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		zw.Write(body)
+		zw.Close()
+		body = b.Bytes()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if e.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s: %s", e.endpoint, resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// protobufRequest encodes an ExportTraceServiceRequest of opentelemetry/proto/collector/trace/v1.
+func (e *exporter) protobufRequest(spans []spanData) []byte {
+	var resource rpc.Encoder
+	for _, a := range e.resource {
+		resource.Message(1, protobufAttr(a))
+	}
+	var scope rpc.Encoder
+	scope.String(1, scopeName)
+	scope.String(2, e.version)
+	var scopeSpans rpc.Encoder
+	scopeSpans.Message(1, scope.Encoded())
+	for _, s := range spans {
+		var span rpc.Encoder
+		span.Bytes(1, s.sc.TraceID[:])
+		span.Bytes(2, s.sc.SpanID[:])
+		span.String(3, s.sc.State)
+		if s.parent != [8]byte{} {
+			span.Bytes(4, s.parent[:])
+		}
+		span.String(5, s.name)
+		span.Uint(6, uint64(s.kind))
+		span.Fixed64(7, uint64(s.start.UnixNano()))
+		span.Fixed64(8, uint64(s.end.UnixNano()))
+		for _, a := range s.attrs {
+			span.Message(9, protobufAttr(a))
+		}
+		if s.statusCode != statusUnset {
+			var status rpc.Encoder
+			status.String(2, s.statusMessage)
+			status.Uint(3, uint64(s.statusCode))
+			span.Message(15, status.Encoded())
+		}
+		scopeSpans.Message(2, span.Encoded())
+	}
+	var resourceSpans rpc.Encoder
+	resourceSpans.Message(1, resource.Encoded())
+	resourceSpans.Message(2, scopeSpans.Encoded())
+	var req rpc.Encoder
+	req.Message(1, resourceSpans.Encoded())
+	return req.Encoded()
+}
+
+// protobufAttr encodes a KeyValue with its AnyValue.
+func protobufAttr(a Attr) []byte {
+	var value rpc.Encoder
+	switch v := a.Value.(type) {
+	case string:
+		value.Message(1, []byte(v))
+	case bool:
+		var n uint64
+		if v {
+			n = 1
+		}
+		value.Varint(2, n)
+	case int64:
+		value.Varint(3, uint64(v))
+	}
+	var kv rpc.Encoder
+	kv.String(1, a.Key)
+	kv.Message(2, value.Encoded())
+	return kv.Encoded()
+}
+
+// The OTLP/JSON encoding: lowerCamelCase field names, hex IDs, and 64-bit integers as strings.
+type (
+	jsonRequest struct {
+		ResourceSpans []jsonResourceSpans `json:"resourceSpans"`
+	}
+	jsonResourceSpans struct {
+		Resource   jsonResource     `json:"resource"`
+		ScopeSpans []jsonScopeSpans `json:"scopeSpans"`
+	}
+	jsonResource struct {
+		Attributes []jsonAttr `json:"attributes"`
+	}
+	jsonScopeSpans struct {
+		Scope jsonScope  `json:"scope"`
+		Spans []jsonSpan `json:"spans"`
+	}
+	jsonScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+	jsonSpan struct {
+		TraceID           string      `json:"traceId"`
+		SpanID            string      `json:"spanId"`
+		TraceState        string      `json:"traceState,omitempty"`
+		ParentSpanID      string      `json:"parentSpanId,omitempty"`
+		Name              string      `json:"name"`
+		Kind              Kind        `json:"kind"`
+		StartTimeUnixNano string      `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+		Attributes        []jsonAttr  `json:"attributes,omitempty"`
+		Status            *jsonStatus `json:"status,omitempty"`
+	}
+	jsonStatus struct {
+		Message string `json:"message,omitempty"`
+		Code    int    `json:"code"`
+	}
+	jsonAttr struct {
+		Key   string    `json:"key"`
+		Value jsonValue `json:"value"`
+	}
+	jsonValue struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		BoolValue   *bool   `json:"boolValue,omitempty"`
+		IntValue    string  `json:"intValue,omitempty"`
+	}
+)
+
+// jsonRequest builds the OTLP/JSON form of an ExportTraceServiceRequest.
+func (e *exporter) jsonRequest(spans []spanData) jsonRequest {
+	scopeSpans := jsonScopeSpans{Scope: jsonScope{Name: scopeName, Version: e.version}, Spans: []jsonSpan{}}
+	for _, s := range spans {
+		span := jsonSpan{
+			TraceID:           hex.EncodeToString(s.sc.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.sc.SpanID[:]),
+			TraceState:        s.sc.State,
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        jsonAttrs(s.attrs),
+		}
+		if s.parent != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		if s.statusCode != statusUnset {
+			span.Status = &jsonStatus{Message: s.statusMessage, Code: s.statusCode}
+		}
+		scopeSpans.Spans = append(scopeSpans.Spans, span)
+	}
+	return jsonRequest{ResourceSpans: []jsonResourceSpans{{
+		Resource:   jsonResource{Attributes: jsonAttrs(e.resource)},
+		ScopeSpans: []jsonScopeSpans{scopeSpans},
+	}}}
+}
+
+// jsonAttrs converts attributes to their OTLP/JSON form.
+func jsonAttrs(attrs []Attr) []jsonAttr {
+	var out []jsonAttr
+	for _, a := range attrs {
+		var value jsonValue
+		switch v := a.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case bool:
+			value.BoolValue = &v
+		case int64:
+			value.IntValue = strconv.FormatInt(v, 10)
+		}
+		out = append(out, jsonAttr{Key: a.Key, Value: value})
+	}
+	return out
+}
@@ -0,0 +1,125 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Inject sets the traceparent and tracestate headers of the span in ctx, if there is one.
+func Inject(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil || !span.sc.IsValid() {
+		return
+	}
+	header.Set("Traceparent", span.sc.Traceparent())
+	if span.sc.State != "" {
+		header.Set("Tracestate", span.sc.State)
+	}
+}
+
+// Extract returns a copy of ctx that continues the trace of the traceparent header, if it is valid.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, err := ParseTraceparent(header.Get("Traceparent"))
+	if err != nil {
+		return ctx
+	}
+	sc.State = header.Get("Tracestate")
+	return ContextWithRemoteParent(ctx, sc)
+}
+
+// Transport records a client span for each request and passes its context on in the request headers. The span
+// lasts until the response body is closed; its parent is the span in the request's context, or else the span in
+// Context, for clients whose requests are made without one.
+type Transport struct {
+	Base    http.RoundTripper
+	Context context.Context
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if SpanFromContext(ctx) == nil && t.Context != nil {
+		ctx = t.Context
+	}
+	// The query and user info are left out of url.full, since they may hold API keys or passwords.
+	u := *req.URL
+	u.RawQuery, u.User = "", nil
+	ctx, span := tracerOf(ctx).start(ctx, req.Method, KindClient, []Attr{
+		String("http.request.method", req.Method),
+		String("server.address", req.URL.Hostname()),
+		String("url.full", u.String()),
+	})
+	req = req.Clone(req.Context())
+	Inject(ctx, req.Header)
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		span.SetError(err)
+		span.End()
+		return nil, err
+	}
+	span.SetAttributes(Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetError(fmt.Errorf("%s", resp.Status))
+	}
+	resp.Body = &spanBody{ReadCloser: resp.Body, span: span}
+	return resp, nil
+}
+
+// spanBody ends the span of a request once its response body is closed.
+type spanBody struct {
+	io.ReadCloser
+	span *Span
+	once sync.Once
+}
+
+func (b *spanBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.span.End)
+	return err
+}
+
+// Handler records a server span for each request, continuing the trace of its traceparent header, and passes the
+// span to next in the request's context. Responses with a 5xx status or a gRPC status other than OK mark it failed.
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := Extract(r.Context(), r.Header)
+		ctx, span := tracerOf(ctx).start(ctx, r.Method+" "+r.URL.Path, KindServer, []Attr{
+			String("http.request.method", r.Method),
+			String("url.path", r.URL.Path),
+		})
+		defer span.End()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		span.SetAttributes(Int("http.response.status_code", rec.status))
+		if rec.status >= 500 {
+			span.SetError(fmt.Errorf("%d %s", rec.status, http.StatusText(rec.status)))
+		}
+		if status := w.Header().Get("Grpc-Status"); status != "" {
+			code, _ := strconv.Atoi(status)
+			span.SetAttributes(Int("rpc.grpc.status_code", code))
+			if code != 0 {
+				span.SetError(fmt.Errorf("grpc status %d: %s", code, w.Header().Get("Grpc-Message")))
+			}
+		}
+	})
+}
+
+// statusRecorder remembers the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush streamed gRPC messages.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
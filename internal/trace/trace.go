@@ -0,0 +1,323 @@
+// Package trace records OpenTelemetry spans, propagates their context in W3C Trace Context headers and the TRACEPARENT
+// environment variable, and exports them with OTLP over HTTP. It covers what ts-release needs without the OpenTelemetry
+// SDK: the always and parent-based samplers, string, int, and bool attributes, and export in batches on Flush.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpanContext identifies a span across process boundaries.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+	// State is the vendor data of the tracestate header, passed on unchanged.
+	State string
+}
+
+// IsValid reports whether the trace and span IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent formats the span context as a version 00 traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := 0
+	if sc.Sampled {
+		flags = 1
+	}
+	return fmt.Sprintf("00-%x-%x-%02x", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses a traceparent header value. Versions after 00 are accepted as long as they start like it,
+// as the W3C Trace Context specification asks.
+func ParseTraceparent(s string) (SpanContext, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, fmt.Errorf("trace: invalid traceparent %q", s)
+	}
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) || strings.ToLower(s) != s {
+		return SpanContext{}, fmt.Errorf("trace: invalid traceparent %q", s)
+	}
+	var sc SpanContext
+	var version, flags [1]byte
+	_, err1 := hex.Decode(version[:], []byte(parts[0]))
+	_, err2 := hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	_, err3 := hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	_, err4 := hex.Decode(flags[:], []byte(parts[3]))
+	if err := errors.Join(err1, err2, err3, err4); err != nil || !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("trace: invalid traceparent %q", s)
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+// Kind is the OTLP span kind.
+type Kind int
+
+// Span kinds, numbered as in OTLP.
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// Attr is a span or resource attribute. Value is a string, int64, or bool.
+type Attr struct {
+	Key   string
+	Value any
+}
+
+// String returns a string attribute.
+func String(key, value string) Attr {
+	return Attr{key, value}
+}
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attr {
+	return Attr{key, int64(value)}
+}
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attr {
+	return Attr{key, value}
+}
+
+// Status codes of a span, numbered as in OTLP.
+const (
+	statusUnset = 0
+	statusError = 2
+)
+
+// spanData is what a recorded span exports.
+type spanData struct {
+	sc            SpanContext
+	parent        [8]byte
+	name          string
+	kind          Kind
+	start, end    time.Time
+	attrs         []Attr
+	statusCode    int
+	statusMessage string
+}
+
+// Span is an operation in a trace. Spans that are not sampled, or started while export is off, only carry their
+// context to child spans and outgoing requests; their methods do nothing.
+type Span struct {
+	sc     SpanContext
+	tracer *Tracer
+
+	mu    sync.Mutex
+	data  spanData
+	ended bool
+}
+
+// SpanContext returns the context of the span to propagate.
+func (s *Span) SpanContext() SpanContext {
+	return s.sc
+}
+
+// SetAttributes adds attributes to a recording span.
+func (s *Span) SetAttributes(attrs ...Attr) {
+	if s.tracer == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.attrs = append(s.data.attrs, attrs...)
+}
+
+// SetError marks a recording span as failed with the message of err. A nil err leaves the span unchanged.
+func (s *Span) SetError(err error) {
+	if s.tracer == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.statusCode, s.data.statusMessage = statusError, err.Error()
+}
+
+// End ends a recording span and queues it for the next Flush of its tracer. Only the first call has an effect.
+func (s *Span) End() {
+	if s.tracer == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.end = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	s.tracer.enqueue(data)
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx that carries span as the parent of spans started from it.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span carried by ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteParent returns a copy of ctx whose spans continue the trace of a span in another process.
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return ContextWithSpan(ctx, &Span{sc: sc})
+}
+
+// FromEnvironment continues the trace given by the TRACEPARENT and TRACESTATE environment variables, which a build
+// orchestrator sets for the processes it starts. A missing or invalid TRACEPARENT leaves ctx unchanged.
+func FromEnvironment(ctx context.Context, getenv func(string) string) context.Context {
+	sc, err := ParseTraceparent(getenv("TRACEPARENT"))
+	if err != nil {
+		return ctx
+	}
+	sc.State = getenv("TRACESTATE")
+	return ContextWithRemoteParent(ctx, sc)
+}
+
+// Start starts an internal span as a child of the span in ctx, on the tracer of that span or on Default.
+func Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return tracerOf(ctx).start(ctx, name, KindInternal, attrs)
+}
+
+// tracerOf returns the tracer that recorded the span in ctx, or Default.
+func tracerOf(ctx context.Context) *Tracer {
+	if parent := SpanFromContext(ctx); parent != nil && parent.tracer != nil {
+		return parent.tracer
+	}
+	return Default
+}
+
+// Samplers selected by OTEL_TRACES_SAMPLER.
+const (
+	samplerParentAlwaysOn  = "parentbased_always_on"
+	samplerParentAlwaysOff = "parentbased_always_off"
+	samplerAlwaysOn        = "always_on"
+	samplerAlwaysOff       = "always_off"
+)
+
+// maxQueue is the number of ended spans a tracer keeps until Flush, as OTEL_BSP_MAX_QUEUE_SIZE defaults to.
+const maxQueue = 2048
+
+// Tracer starts spans and exports the recorded ones. The zero value records nothing until Configure enables export.
+// It is safe for concurrent use.
+type Tracer struct {
+	mu       sync.Mutex
+	exporter *exporter
+	sampler  string
+	pending  []spanData
+	dropped  int
+}
+
+// Default is the tracer of this process, configured once by main. Like wallhaven.DefaultLimiter it is shared by all
+// builds of the process.
+var Default = &Tracer{}
+
+// Start starts an internal span as a child of the span in ctx.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...Attr) (context.Context, *Span) {
+	return t.start(ctx, name, KindInternal, attrs)
+}
+
+// start starts a span of the kind. Without export the span keeps the parent's context, so requests made under it
+// carry the caller's span on as their parent.
+func (t *Tracer) start(ctx context.Context, name string, kind Kind, attrs []Attr) (context.Context, *Span) {
+	var parent SpanContext
+	if p := SpanFromContext(ctx); p != nil {
+		parent = p.sc
+	}
+	t.mu.Lock()
+	enabled, sampler := t.exporter != nil, t.sampler
+	t.mu.Unlock()
+	if !enabled {
+		span := &Span{sc: parent}
+		return ContextWithSpan(ctx, span), span
+	}
+
+	sc := SpanContext{SpanID: [8]byte(randomBytes(8))}
+	if parent.IsValid() {
+		sc.TraceID, sc.State = parent.TraceID, parent.State
+	} else {
+		sc.TraceID = [16]byte(randomBytes(16))
+	}
+	switch sampler {
+	case samplerAlwaysOn:
+		sc.Sampled = true
+	case samplerAlwaysOff:
+	case samplerParentAlwaysOff:
+		sc.Sampled = parent.IsValid() && parent.Sampled
+	default:
+		sc.Sampled = !parent.IsValid() || parent.Sampled
+	}
+	span := &Span{sc: sc}
+	if sc.Sampled {
+		span.tracer = t
+		span.data = spanData{sc: sc, name: name, kind: kind, start: time.Now(), attrs: attrs}
+		if parent.IsValid() {
+			span.data.parent = parent.SpanID
+		}
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// enqueue keeps an ended span for the next Flush, dropping it if the queue is full.
+func (t *Tracer) enqueue(data spanData) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.pending) >= maxQueue {
+		t.dropped++
+		return
+	}
+	t.pending = append(t.pending, data)
+}
+
+// Enabled reports whether Configure turned on export.
+func (t *Tracer) Enabled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.exporter != nil
+}
+
+// Flush exports the spans ended since the last Flush. It returns an error if the export fails or spans were dropped
+// because the queue was full; the spans of a failed export are not retried.
+func (t *Tracer) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	exp, spans, dropped := t.exporter, t.pending, t.dropped
+	t.pending, t.dropped = nil, 0
+	t.mu.Unlock()
+	if exp == nil || len(spans) == 0 {
+		return nil
+	}
+	if err := exp.export(ctx, spans); err != nil {
+		return fmt.Errorf("trace: export %d spans: %w", len(spans), err)
+	}
+	if dropped > 0 {
+		return fmt.Errorf("trace: dropped %d spans over the queue limit of %d", dropped, maxQueue)
+	}
+	return nil
+}
+
+// randomBytes returns n random bytes for a trace or span ID.
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
@@ -0,0 +1,251 @@
+package trace
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/nickhildebrandt/ts-release/internal/rpc"
+)
+
+// TestParseTraceparent_AcceptsValidAndRejectsMalformed expects W3C traceparent values to round-trip and malformed
+// ones, all-zero IDs, and version ff to be rejected. The test fails if a value is parsed differently.
+func TestParseTraceparent_AcceptsValidAndRejectsMalformed(t *testing.T) {
+	const valid = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(valid)
+	if err != nil || !sc.Sampled || sc.Traceparent() != valid {
+		t.Fatalf("ParseTraceparent(%q) = %+v, %v", valid, sc, err)
+	}
+	if sc, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future"); err != nil || sc.Sampled {
+		t.Fatalf("future version: %+v, %v", sc, err)
+	}
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceparent(s); err == nil {
+			t.Errorf("ParseTraceparent(%q) succeeded", s)
+		}
+	}
+}
+
+// exported is a span as a fake collector decoded it.
+type exported struct {
+	traceID, spanID, parentID string
+	name                      string
+	kind                      int
+	attrs                     map[string]string
+	statusCode                int
+}
+
+// decodeProtobuf decodes the spans and resource attributes of an OTLP/protobuf ExportTraceServiceRequest.
+func decodeProtobuf(t *testing.T, data []byte) ([]exported, map[string]string) {
+	t.Helper()
+	resource := map[string]string{}
+	var spans []exported
+	// attr decodes a KeyValue whose value is a string or int into m.
+	attr := func(data []byte, m map[string]string) error {
+		var key, value string
+		err := rpc.Decode(data, func(f rpc.Field) error {
+			if f.Num == 1 {
+				key = f.String()
+				return nil
+			}
+			return rpc.Decode(f.Bytes, func(v rpc.Field) error {
+				if v.Num == 3 {
+					value = strconv.FormatInt(v.Int(), 10)
+				} else {
+					value = v.String()
+				}
+				return nil
+			})
+		})
+		m[key] = value
+		return err
+	}
+	span := func(data []byte) error {
+		s := exported{attrs: map[string]string{}}
+		err := rpc.Decode(data, func(f rpc.Field) error {
+			switch f.Num {
+			case 1:
+				s.traceID = hex.EncodeToString(f.Bytes)
+			case 2:
+				s.spanID = hex.EncodeToString(f.Bytes)
+			case 4:
+				s.parentID = hex.EncodeToString(f.Bytes)
+			case 5:
+				s.name = f.String()
+			case 6:
+				s.kind = int(f.Varint)
+			case 9:
+				return attr(f.Bytes, s.attrs)
+			case 15:
+				return rpc.Decode(f.Bytes, func(st rpc.Field) error {
+					if st.Num == 3 {
+						s.statusCode = int(st.Varint)
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+		spans = append(spans, s)
+		return err
+	}
+	err := rpc.Decode(data, func(rs rpc.Field) error {
+		return rpc.Decode(rs.Bytes, func(f rpc.Field) error {
+			if f.Num == 1 {
+				return rpc.Decode(f.Bytes, func(a rpc.Field) error { return attr(a.Bytes, resource) })
+			}
+			return rpc.Decode(f.Bytes, func(ss rpc.Field) error {
+				if ss.Num == 2 {
+					return span(ss.Bytes)
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		t.Fatalf("decode export: %v", err)
+	}
+	return spans, resource
+}
+
+// TestTracer_ExportsSpansWithOTLP expects spans configured from OTEL_* variables to continue a remote parent, to
+// pass their context on in outgoing requests and continue incoming ones, and to be exported with the configured
+// headers as gzipped OTLP/protobuf and as OTLP/JSON. The test fails if a span, link, attribute, status, or header differs.
+func TestTracer_ExportsSpansWithOTLP(t *testing.T) {
+	var bodies [][]byte
+	var contentTypes []string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		body := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		data, _ := io.ReadAll(body)
+		bodies = append(bodies, data)
+		contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+	}))
+	defer collector.Close()
+	var gotParent string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotParent = r.Header.Get("Traceparent")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer backend.Close()
+
+	env := map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT":    collector.URL + "/",
+		"OTEL_EXPORTER_OTLP_HEADERS":     "Authorization=Bearer%20s3cret",
+		"OTEL_EXPORTER_OTLP_COMPRESSION": "gzip",
+		"OTEL_RESOURCE_ATTRIBUTES":       "deployment.environment=ci,service.name=ignored",
+		"OTEL_SERVICE_NAME":              "image-builder",
+		"TRACEPARENT":                    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}
+	tracer := &Tracer{}
+	if err := tracer.Configure(func(k string) string { return env[k] }, "1.2.3"); err != nil {
+		t.Fatal(err)
+	}
+	root := FromEnvironment(context.Background(), func(k string) string { return env[k] })
+	ctx, build := tracer.Start(root, "build", String("ts_release.target", "kiosk"))
+	fetchCtx, fetch := Start(ctx, "fetch", Int("ts_release.width", 3840))
+	client := &http.Client{Transport: Transport{Base: http.DefaultTransport, Context: fetchCtx}}
+	resp, err := client.Get(backend.URL + "/img?apikey=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	fetch.End()
+	build.SetError(errors.New("no background"))
+	build.End()
+	build.End()
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bodies) != 1 || contentTypes[0] != "application/x-protobuf" {
+		t.Fatalf("exports: %d, content types %q", len(bodies), contentTypes)
+	}
+	spans, resource := decodeProtobuf(t, bodies[0])
+	if resource["service.name"] != "image-builder" || resource["deployment.environment"] != "ci" || resource["service.version"] != "1.2.3" {
+		t.Fatalf("resource = %v", resource)
+	}
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want GET, fetch, build: %+v", len(spans), spans)
+	}
+	get, fetchSpan, buildSpan := spans[0], spans[1], spans[2]
+	for _, s := range spans {
+		if s.traceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Fatalf("span %s left the trace: %s", s.name, s.traceID)
+		}
+	}
+	if buildSpan.name != "build" || buildSpan.parentID != "00f067aa0ba902b7" || buildSpan.statusCode != statusError || buildSpan.attrs["ts_release.target"] != "kiosk" {
+		t.Fatalf("build span = %+v", buildSpan)
+	}
+	if fetchSpan.parentID != buildSpan.spanID || get.parentID != fetchSpan.spanID || get.kind != int(KindClient) {
+		t.Fatalf("links: build %+v, fetch %+v, GET %+v", buildSpan, fetchSpan, get)
+	}
+	if get.attrs["url.full"] != backend.URL+"/img" || get.statusCode != statusError {
+		t.Fatalf("GET span = %+v", get)
+	}
+	if gotParent != "00-4bf92f3577b34da6a3ce929d0e0e4736-"+get.spanID+"-01" {
+		t.Fatalf("backend got traceparent %q, GET span %s", gotParent, get.spanID)
+	}
+
+	env["OTEL_EXPORTER_OTLP_PROTOCOL"] = protocolJSON
+	delete(env, "OTEL_EXPORTER_OTLP_COMPRESSION")
+	if err := tracer.Configure(func(k string) string { return env[k] }, "1.2.3"); err != nil {
+		t.Fatal(err)
+	}
+	// Handler and spans without a recording parent use Default.
+	saved := Default
+	Default = tracer
+	defer func() { Default = saved }()
+	handler := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "render")
+		span.End()
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/render", nil)
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var export jsonRequest
+	if len(bodies) != 2 || contentTypes[1] != "application/json" || json.Unmarshal(bodies[1], &export) != nil {
+		t.Fatalf("JSON export: %q %s", contentTypes, bodies[len(bodies)-1])
+	}
+	got := export.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(got) != 2 || got[0].Name != "render" || got[1].Name != "POST /render" || got[1].Kind != KindServer ||
+		got[0].ParentSpanID != got[1].SpanID || got[1].ParentSpanID != "00f067aa0ba902b7" || got[1].Status == nil {
+		t.Fatalf("JSON spans = %+v", got)
+	}
+
+	env["OTEL_EXPORTER_OTLP_PROTOCOL"] = "grpc"
+	if err := tracer.Configure(func(k string) string { return env[k] }, "1.2.3"); err == nil || tracer.Enabled() {
+		t.Fatalf("expected grpc to be rejected and export to be off, got %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+// Package unsplash is a minimal client for the Unsplash API (https://unsplash.com/documentation).
+// It fetches random photos with orientation and content filters and reports the download as the API guidelines
+// require; requests are authenticated with an application's access key.
+package unsplash
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultBaseURL is the root of the public API.
+const DefaultBaseURL = "https://api.unsplash.com"
+
+// Errors wrapped by requests that the API rejects with the corresponding HTTP status.
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrServer       = errors.New("server error")
+)
+
+// ErrTooLarge is wrapped when an image download exceeds its size limit.
+var ErrTooLarge = errors.New("too large")
+
+// DefaultMaxDownloadSize bounds image downloads unless Client.MaxDownloadSize is set. 256 MiB is far above the files of
+// the largest backgrounds, yet keeps a server that sends an endless body from exhausting memory.
+const DefaultMaxDownloadSize = 256 << 20
+
+// Orientations accepted by RandomParams.Orientation.
+const (
+	Landscape = "landscape"
+	Portrait  = "portrait"
+	Squarish  = "squarish"
+)
+
+// Client sends requests to the Unsplash API. It is safe for concurrent use.
+type Client struct {
+	// HTTPClient sends all requests, including image downloads; nil selects http.DefaultClient.
+	HTTPClient *http.Client
+	// BaseURL is the API root without a trailing slash; empty selects DefaultBaseURL.
+	BaseURL string
+	// AccessKey is the application's access key, sent as "Authorization: Client-ID <key>".
+	AccessKey string
+	// MaxDownloadSize bounds the bytes of an image download; zero selects DefaultMaxDownloadSize.
+	MaxDownloadSize int64
+}
+
+// RandomParams are the filters of the random photo endpoint; zero fields are omitted.
+type RandomParams struct {
+	Query string
+	// Orientation is Landscape, Portrait, or Squarish.
+	Orientation string
+	// ContentFilter is low or high; high excludes anything not safe for work.
+	ContentFilter string
+}
+
+// Photo is a photo record of the API.
+type Photo struct {
+	ID     string `json:"id"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	URLs   struct {
+		// Raw is the original image; it accepts imgix parameters such as w, h, and fit.
+		Raw string `json:"raw"`
+		// Full is the full-size JPEG.
+		Full string `json:"full"`
+	} `json:"urls"`
+	Links struct {
+		// HTML is the photo's page on unsplash.com.
+		HTML string `json:"html"`
+		// DownloadLocation must be requested whenever the photo is downloaded.
+		DownloadLocation string `json:"download_location"`
+	} `json:"links"`
+	User struct {
+		Name  string `json:"name"`
+		Links struct {
+			HTML string `json:"html"`
+		} `json:"links"`
+	} `json:"user"`
+}
+
+// Attribution returns the credit line the Unsplash guidelines ask for, e.g. "Photo by Jane Doe on Unsplash".
+func (p Photo) Attribution() string {
+	return fmt.Sprintf("Photo by %s on Unsplash", p.User.Name)
+}
+
+// SizedURL returns the raw image URL cropped to width x height by the image service.
+func (p Photo) SizedURL(width, height int) string {
+	sep := "?"
+	if strings.Contains(p.URLs.Raw, "?") {
+		sep = "&"
+	}
+	v := url.Values{}
+	v.Set("w", strconv.Itoa(width))
+	v.Set("h", strconv.Itoa(height))
+	v.Set("fit", "crop")
+	v.Set("fm", "jpg")
+	v.Set("q", "90")
+	return p.URLs.Raw + sep + v.Encode()
+}
+
+// Random returns one random photo matching params.
+func (c *Client) Random(params RandomParams) (*Photo, error) {
+	v := url.Values{}
+	for key, value := range map[string]string{"query": params.Query, "orientation": params.Orientation, "content_filter": params.ContentFilter} {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	endpoint := c.url("/photos/random")
+	if len(v) > 0 {
+		endpoint += "?" + v.Encode()
+	}
+	resp, err := c.do("random", endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var photo Photo
+	if err := json.NewDecoder(resp.Body).Decode(&photo); err != nil {
+		return nil, fmt.Errorf("unsplash: random: decode response: %w", err)
+	}
+	return &photo, nil
+}
+
+// Download fetches imageURL, e.g. from SizedURL, after reporting the download of photo to its download location.
+func (c *Client) Download(photo *Photo, imageURL string) ([]byte, error) {
+	if photo.Links.DownloadLocation != "" {
+		resp, err := c.do("track download", photo.Links.DownloadLocation)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+	}
+	resp, err := c.do("download", imageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	limit := c.MaxDownloadSize
+	if limit <= 0 {
+		limit = DefaultMaxDownloadSize
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("unsplash: download: read body: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("unsplash: download: %w: larger than %d bytes", ErrTooLarge, limit)
+	}
+	return data, nil
+}
+
+// url returns path below the base URL.
+func (c *Client) url(path string) string {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return strings.TrimSuffix(base, "/") + path
+}
+
+// do sends an authenticated GET request and returns the response of a 2xx status; other statuses are mapped to
+// errors. op names the call in errors.
+func (c *Client) do(op, endpoint string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unsplash: %s: %w", op, err)
+	}
+	req.Header.Set("Accept-Version", "v1")
+	if c.AccessKey != "" {
+		req.Header.Set("Authorization", "Client-ID "+c.AccessKey)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unsplash: %s: request failed: %w", op, err)
+	}
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return resp, nil
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		err = ErrUnauthorized
+	case http.StatusNotFound:
+		err = ErrNotFound
+	case http.StatusTooManyRequests, http.StatusForbidden:
+		// Unsplash answers 403 once the hourly limit is used up.
+		err = ErrRateLimited
+	default:
+		if resp.StatusCode < http.StatusInternalServerError {
+			return nil, fmt.Errorf("unsplash: %s: http %d", op, resp.StatusCode)
+		}
+		err = ErrServer
+	}
+	return nil, fmt.Errorf("unsplash: %s: http %d: %w", op, resp.StatusCode, err)
+}
@@ -0,0 +1,67 @@
+package unsplash
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestClient_RandomAndTrackedDownload expects the random endpoint to receive the filters and the access key, and a
+// download to report the photo's download location before fetching the image.
+// The test fails if a filter or the authorization is missing, the download is not tracked, a download beyond the
+// limit is read whole, or errors are not mapped.
+func TestClient_RandomAndTrackedDownload(t *testing.T) {
+	var requests []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Client-ID key" || r.Header.Get("Accept-Version") != "v1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		requests = append(requests, r.URL.Path+"?"+r.URL.RawQuery)
+		switch r.URL.Path {
+		case "/photos/random":
+			_, _ = w.Write([]byte(`{"id":"p1","width":6000,"height":4000,"urls":{"raw":"` + server.URL + `/raw?ixid=1"},
+				"links":{"html":"https://unsplash.com/photos/p1","download_location":"` + server.URL + `/photos/p1/download"},
+				"user":{"name":"Jane Doe","links":{"html":"https://unsplash.com/@jane"}}}`))
+		case "/photos/p1/download", "/raw":
+			_, _ = w.Write([]byte("image"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client := &Client{HTTPClient: server.Client(), BaseURL: server.URL, AccessKey: "key"}
+
+	photo, err := client.Random(RandomParams{Query: "nature", Orientation: Landscape, ContentFilter: "high"})
+	if err != nil {
+		t.Fatalf("Random error: %v", err)
+	}
+	if photo.Attribution() != "Photo by Jane Doe on Unsplash" || photo.Links.HTML != "https://unsplash.com/photos/p1" {
+		t.Fatalf("unexpected photo %+v", photo)
+	}
+	imageURL := photo.SizedURL(2560, 1440)
+	if !strings.HasPrefix(imageURL, server.URL+"/raw?ixid=1&") || !strings.Contains(imageURL, "w=2560") || !strings.Contains(imageURL, "fit=crop") {
+		t.Fatalf("sized URL %q", imageURL)
+	}
+	data, err := client.Download(photo, imageURL)
+	if err != nil || string(data) != "image" {
+		t.Fatalf("Download: got %q, %v", data, err)
+	}
+	limited := *client
+	limited.MaxDownloadSize = 4
+	if _, err := limited.Download(&Photo{}, imageURL); !errors.Is(err, ErrTooLarge) || !strings.Contains(err.Error(), "larger than 4 bytes") {
+		t.Fatalf("download beyond the limit: got %v, want ErrTooLarge", err)
+	}
+	want := []string{"/photos/random?content_filter=high&orientation=landscape&query=nature", "/photos/p1/download?", "/raw?"}
+	if len(requests) != 4 || requests[0] != want[0] || requests[1] != want[1] || !strings.HasPrefix(requests[2], want[2]) {
+		t.Fatalf("requests: got %q want %q", requests, want)
+	}
+
+	client.AccessKey = "wrong"
+	if _, err := client.Random(RandomParams{}); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("wrong key: got %v, want ErrUnauthorized", err)
+	}
+}
@@ -0,0 +1,151 @@
+package wallhaven
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Cache keeps downloaded images on disk together with their ETag and Last-Modified validators.
+// A cached image is revalidated with a conditional request, so an unchanged image costs a 304 instead of a transfer.
+type Cache struct {
+	// Dir holds one <key>.img and one <key>.json file per URL; it is created on the first store.
+	Dir string
+	// Observe is told after each download through the cache whether the cached image was used; nil ignores it.
+	Observe func(hit bool)
+}
+
+// observe reports a download to Observe if it is set.
+func (c *Cache) observe(hit bool) {
+	if c.Observe != nil {
+		c.Observe(hit)
+	}
+}
+
+// cacheEntry is the metadata stored next to a cached image.
+type cacheEntry struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// paths returns the image and metadata file of imageURL.
+func (c *Cache) paths(imageURL string) (data, meta string) {
+	sum := sha256.Sum256([]byte(imageURL))
+	key := hex.EncodeToString(sum[:])
+	return filepath.Join(c.Dir, key+".img"), filepath.Join(c.Dir, key+".json")
+}
+
+// load returns the cached entry and image of imageURL; missing or unreadable files count as a miss.
+func (c *Cache) load(imageURL string) (cacheEntry, []byte, bool) {
+	dataPath, metaPath := c.paths(imageURL)
+	raw, err := os.ReadFile(metaPath)
+	if err != nil {
+		return cacheEntry{}, nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(raw, &entry); err != nil || entry.URL != imageURL || (entry.ETag == "" && entry.LastModified == "") {
+		return cacheEntry{}, nil, false
+	}
+	data, err := os.ReadFile(dataPath)
+	if err != nil {
+		return cacheEntry{}, nil, false
+	}
+	return entry, data, true
+}
+
+// store writes the image and the validators of resp; responses without validators drop a previous entry.
+// The image is written first and both files are replaced atomically, so metadata never points at a partial image.
+func (c *Cache) store(imageURL string, resp *http.Response, data []byte) error {
+	entry := cacheEntry{URL: imageURL, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	if entry.ETag == "" && entry.LastModified == "" {
+		_, metaPath := c.paths(imageURL)
+		if err := os.Remove(metaPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("cache: %w", err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	meta, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	dataPath, metaPath := c.paths(imageURL)
+	if err := writeAtomic(dataPath, data); err != nil {
+		return err
+	}
+	return writeAtomic(metaPath, meta)
+}
+
+// Latest returns the most recently stored image and its URL, e.g. to fall back on while the service is down.
+// It returns an error wrapping fs.ErrNotExist if the cache holds no image.
+func (c *Cache) Latest() (imageURL string, data []byte, err error) {
+	metas, err := filepath.Glob(filepath.Join(c.Dir, "*.json"))
+	if err != nil {
+		return "", nil, fmt.Errorf("cache: %w", err)
+	}
+	var newest time.Time
+	for _, metaPath := range metas {
+		raw, err := os.ReadFile(metaPath)
+		if err != nil {
+			continue
+		}
+		var entry cacheEntry
+		if json.Unmarshal(raw, &entry) != nil || entry.URL == "" {
+			continue
+		}
+		dataPath, _ := c.paths(entry.URL)
+		info, err := os.Stat(dataPath)
+		if err != nil || !info.ModTime().After(newest) {
+			continue
+		}
+		if cached, err := os.ReadFile(dataPath); err == nil {
+			imageURL, data, newest = entry.URL, cached, info.ModTime()
+		}
+	}
+	if data == nil {
+		return "", nil, fmt.Errorf("cache: no image in %s: %w", c.Dir, fs.ErrNotExist)
+	}
+	return imageURL, data, nil
+}
+
+// conditional returns the request header that revalidates the entry.
+func (e cacheEntry) conditional() http.Header {
+	header := http.Header{}
+	if e.ETag != "" {
+		header.Set("If-None-Match", e.ETag)
+	}
+	if e.LastModified != "" {
+		header.Set("If-Modified-Since", e.LastModified)
+	}
+	return header
+}
+
+// writeAtomic writes data to a temporary file next to path and renames it into place.
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cache: write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cache: write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("cache: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package wallhaven
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestClient_DownloadRevalidatesCache expects the first download to store the image with its validators and later
+// downloads to send them, returning the cached bytes on 304 and replacing them on 200, and each download to be
+// observed as hit or miss. The test fails if a conditional header is missing, a 304 returns no data, a changed image
+// is not stored, or a download is observed wrong.
+func TestClient_DownloadRevalidatesCache(t *testing.T) {
+	body, etag := "image-v1", `"v1"`
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", "Mon, 01 Jan 2024 00:00:00 GMT")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	dir := filepath.Join(t.TempDir(), "cache")
+	var hits []bool
+	client := &Client{HTTPClient: server.Client(), Cache: &Cache{Dir: dir, Observe: func(hit bool) { hits = append(hits, hit) }}}
+	imageURL := server.URL + "/full/ab/wallhaven-abc.jpg"
+
+	download := func(want string) {
+		t.Helper()
+		data, err := client.Download(imageURL)
+		if err != nil {
+			t.Fatalf("Download error: %v", err)
+		}
+		if string(data) != want {
+			t.Fatalf("Download: got %q want %q", data, want)
+		}
+	}
+
+	download("image-v1")
+	if got := requests[0].Header.Get("If-None-Match"); got != "" {
+		t.Fatalf("first request is conditional: If-None-Match %q", got)
+	}
+	download("image-v1")
+	if got := requests[1].Header.Get("If-Modified-Since"); got != "Mon, 01 Jan 2024 00:00:00 GMT" {
+		t.Fatalf("revalidation If-Modified-Since: got %q", got)
+	}
+
+	body, etag = "image-v2", `"v2"`
+	download("image-v2")
+	if got := requests[2].Header.Get("If-None-Match"); got != `"v1"` {
+		t.Fatalf("revalidation If-None-Match: got %q want %q", got, `"v1"`)
+	}
+	download("image-v2")
+	if len(requests) != 4 {
+		t.Fatalf("requests: got %d want 4", len(requests))
+	}
+	if want := []bool{false, true, false, true}; !slices.Equal(hits, want) {
+		t.Fatalf("observed hits %v, want %v", hits, want)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read cache dir: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("cache files: got %d want image and metadata", len(entries))
+	}
+}
+
+// TestCache_IgnoresDamagedEntries expects unreadable metadata or a missing image to count as a miss, so the
+// download is unconditional and repairs the entry.
+// The test fails if a damaged entry sends validators or makes the download fail.
+func TestCache_IgnoresDamagedEntries(t *testing.T) {
+	var conditional []bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conditional = append(conditional, r.Header.Get("If-None-Match") != "")
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte("image"))
+	}))
+	t.Cleanup(server.Close)
+	cache := &Cache{Dir: t.TempDir()}
+	client := &Client{HTTPClient: server.Client(), Cache: cache}
+	imageURL := server.URL + "/image.jpg"
+	dataPath, metaPath := cache.paths(imageURL)
+
+	damage := []func(){
+		func() {},
+		func() { _ = os.WriteFile(metaPath, []byte("{"), 0o644) },
+		func() { _ = os.Remove(dataPath) },
+	}
+	for i, prepare := range damage {
+		if i > 0 {
+			if _, err := client.Download(imageURL); err != nil {
+				t.Fatalf("repair download %d: %v", i, err)
+			}
+		}
+		prepare()
+		data, err := client.Download(imageURL)
+		if err != nil || string(data) != "image" {
+			t.Fatalf("download %d: got %q, %v", i, data, err)
+		}
+	}
+	want := []bool{false, true, false, true, false}
+	if len(conditional) != len(want) {
+		t.Fatalf("requests: got %v want %v", conditional, want)
+	}
+	for i := range want {
+		if conditional[i] != want[i] {
+			t.Fatalf("conditional requests: got %v want %v", conditional, want)
+		}
+	}
+}
+
+// TestCache_LatestReturnsNewestImage expects Latest to return the most recently stored image with its URL and an
+// fs.ErrNotExist error for an empty cache. The test fails if an older or damaged entry wins.
+func TestCache_LatestReturnsNewestImage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"`+r.URL.Path+`"`)
+		_, _ = w.Write([]byte("image " + r.URL.Path))
+	}))
+	t.Cleanup(server.Close)
+	cache := &Cache{Dir: filepath.Join(t.TempDir(), "cache")}
+	client := &Client{HTTPClient: server.Client(), Cache: cache}
+
+	if _, _, err := cache.Latest(); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("empty cache: got %v, want fs.ErrNotExist", err)
+	}
+	for i, name := range []string{"/old.jpg", "/new.jpg"} {
+		if _, err := client.Download(server.URL + name); err != nil {
+			t.Fatalf("Download error: %v", err)
+		}
+		// File systems with coarse timestamps would otherwise tie.
+		dataPath, _ := cache.paths(server.URL + name)
+		stamp := time.Now().Add(time.Duration(i-2) * time.Hour)
+		if err := os.Chtimes(dataPath, stamp, stamp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(cache.Dir, "damaged.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	imageURL, data, err := cache.Latest()
+	if err != nil || imageURL != server.URL+"/new.jpg" || string(data) != "image /new.jpg" {
+		t.Fatalf("Latest: got %q, %q, %v", imageURL, data, err)
+	}
+}
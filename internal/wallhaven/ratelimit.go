@@ -0,0 +1,112 @@
+package wallhaven
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultRequestsPerMinute is Wallhaven's documented API limit.
+const DefaultRequestsPerMinute = 45
+
+// defaultRetryAfter is the pause after a 429 response without a usable Retry-After header; the API counts
+// requests per minute.
+const defaultRetryAfter = time.Minute
+
+// DefaultLimiter is shared by all clients without their own Limiter, so concurrent fetches in one process draw from
+// the same budget.
+var DefaultLimiter = NewLimiter(DefaultRequestsPerMinute)
+
+// Limiter is a token bucket that spaces API requests and follows the rate limit headers of the responses.
+// It is safe for concurrent use.
+type Limiter struct {
+	mu sync.Mutex
+	// perMinute is the refill rate and the bucket size; zero or less disables the bucket.
+	perMinute float64
+	tokens    float64
+	last      time.Time
+	// until blocks all requests after a 429 response, even with the bucket disabled.
+	until time.Time
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// NewLimiter returns a full bucket for perMinute requests per minute; zero or less only honors 429 responses.
+func NewLimiter(perMinute int) *Limiter {
+	return &Limiter{perMinute: float64(perMinute), tokens: float64(perMinute), now: time.Now, sleep: time.Sleep}
+}
+
+// SetRate changes the requests per minute, keeping at most that many tokens.
+func (l *Limiter) SetRate(perMinute int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.perMinute = float64(perMinute)
+	l.tokens = min(l.tokens, max(0, l.perMinute))
+}
+
+// Wait blocks until a request may be sent and takes one token.
+func (l *Limiter) Wait() {
+	for {
+		l.mu.Lock()
+		delay := l.reserve()
+		l.mu.Unlock()
+		if delay <= 0 {
+			return
+		}
+		l.sleep(delay)
+	}
+}
+
+// reserve takes a token and returns zero, or returns how long to wait before trying again; l.mu must be held.
+func (l *Limiter) reserve() time.Duration {
+	now := l.refill()
+	if now.Before(l.until) {
+		return l.until.Sub(now)
+	}
+	if l.perMinute <= 0 {
+		return 0
+	}
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.perMinute * float64(time.Minute))
+}
+
+// refill adds the tokens accrued since the last call and returns the current time; l.mu must be held.
+func (l *Limiter) refill() time.Time {
+	now := l.now()
+	if !l.last.IsZero() && l.perMinute > 0 {
+		l.tokens = min(l.perMinute, l.tokens+now.Sub(l.last).Minutes()*l.perMinute)
+	}
+	l.last = now
+	return now
+}
+
+// Observe adjusts the bucket to a response: a lower X-RateLimit-Limit lowers the rate, X-RateLimit-Remaining caps
+// the tokens, and a 429 blocks requests for its Retry-After seconds or a minute.
+func (l *Limiter) Observe(resp *http.Response) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.refill()
+	if limit, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit")); err == nil && limit > 0 && float64(limit) < l.perMinute {
+		l.perMinute = float64(limit)
+		l.tokens = min(l.tokens, l.perMinute)
+	}
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil && remaining >= 0 {
+		l.tokens = min(l.tokens, float64(remaining))
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		wait := defaultRetryAfter
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			wait = time.Duration(seconds) * time.Second
+		}
+		if until := now.Add(wait); until.After(l.until) {
+			l.until = until
+		}
+		l.tokens = 0
+	}
+}
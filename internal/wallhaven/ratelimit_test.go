@@ -0,0 +1,97 @@
+package wallhaven
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeLimiter returns a limiter on a simulated clock that advances by every sleep, and the total time slept.
+func fakeLimiter(perMinute int) (*Limiter, *time.Duration) {
+	l := NewLimiter(perMinute)
+	clock := time.Unix(0, 0)
+	slept := new(time.Duration)
+	l.now = func() time.Time { return clock }
+	l.sleep = func(d time.Duration) {
+		*slept += d
+		clock = clock.Add(d)
+	}
+	return l, slept
+}
+
+// TestLimiter_TokenBucketAndHeaders expects a full bucket to allow a burst, further requests to be spaced by the
+// rate, and the rate limit headers and 429 responses to slow the bucket down.
+// The test fails if requests are sent too early or the limiter sleeps without need.
+func TestLimiter_TokenBucketAndHeaders(t *testing.T) {
+	l, slept := fakeLimiter(60)
+	for i := 0; i < 60; i++ {
+		l.Wait()
+	}
+	if *slept != 0 {
+		t.Fatalf("burst slept %v", *slept)
+	}
+	l.Wait()
+	if *slept != time.Second {
+		t.Fatalf("request after the burst slept %v, want 1s", *slept)
+	}
+
+	// A lower server limit replaces the configured rate.
+	l.Observe(&http.Response{StatusCode: http.StatusOK, Header: http.Header{"X-Ratelimit-Limit": {"30"}, "X-Ratelimit-Remaining": {"0"}}})
+	*slept = 0
+	l.Wait()
+	if *slept != 2*time.Second {
+		t.Fatalf("slept %v after exhausting the server budget, want 2s", *slept)
+	}
+
+	l.Observe(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"7"}}})
+	*slept = 0
+	l.Wait()
+	if *slept != 7*time.Second {
+		t.Fatalf("slept %v after 429, want Retry-After of 7s", *slept)
+	}
+
+	unlimited, slept := fakeLimiter(0)
+	for i := 0; i < 1000; i++ {
+		unlimited.Wait()
+	}
+	unlimited.Observe(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}})
+	unlimited.Wait()
+	if *slept != time.Minute {
+		t.Fatalf("disabled limiter slept %v, want only the default 429 pause", *slept)
+	}
+}
+
+// TestClient_RetriesAfterRateLimit expects a 429 response to be retried after its Retry-After pause and repeated
+// 429s to give up with ErrRateLimited.
+// The test fails if the client neither waits nor retries, or retries without bound.
+func TestClient_RetriesAfterRateLimit(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 || r.URL.Path == "/tag/2" {
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"id":1}}`))
+	}))
+	defer server.Close()
+	limiter, slept := fakeLimiter(0)
+	client := &Client{HTTPClient: server.Client(), BaseURL: server.URL, Limiter: limiter}
+
+	if tag, err := client.Tag(1); err != nil || tag.ID != 1 {
+		t.Fatalf("Tag: got %+v, %v", tag, err)
+	}
+	if calls.Load() != 2 || *slept != 3*time.Second {
+		t.Fatalf("got %d calls after sleeping %v, want 2 after 3s", calls.Load(), *slept)
+	}
+
+	calls.Store(10)
+	if _, err := client.Tag(2); err == nil {
+		t.Fatalf("expected error after repeated 429")
+	}
+	if calls.Load() != 10+maxAttempts {
+		t.Fatalf("got %d attempts, want %d", calls.Load()-10, maxAttempts)
+	}
+}
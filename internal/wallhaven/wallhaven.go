@@ -0,0 +1,459 @@
+// Package wallhaven is a client for the Wallhaven API v1 (https://wallhaven.cc/help/api).
+// It covers search with pagination, wallpaper details, tags, and collections; the zero Client talks to the public
+// API over http.DefaultClient.
+package wallhaven
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultBaseURL is the root of the public API.
+const DefaultBaseURL = "https://wallhaven.cc/api/v1"
+
+// Errors wrapped by requests that the API rejects with the corresponding HTTP status.
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrNotFound     = errors.New("not found")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrServer       = errors.New("server error")
+)
+
+// ErrTooLarge is wrapped when an image download exceeds its size limit.
+var ErrTooLarge = errors.New("too large")
+
+// DefaultMaxDownloadSize bounds image downloads unless Client.MaxDownloadSize is set. 256 MiB is far above the files of
+// the largest backgrounds, yet keeps a server that sends an endless body from exhausting memory.
+const DefaultMaxDownloadSize = 256 << 20
+
+// Client sends requests to the Wallhaven API. The zero value is ready to use, and a client is safe for concurrent use.
+type Client struct {
+	// HTTPClient sends all requests, including image downloads; nil selects http.DefaultClient.
+	HTTPClient *http.Client
+	// BaseURL is the API root without a trailing slash; empty selects DefaultBaseURL.
+	BaseURL string
+	// APIKey is sent as X-API-Key; it is required for NSFW results and the user's own collections.
+	APIKey string
+	// Limiter spaces the API requests; nil selects DefaultLimiter. Image downloads are not limited.
+	Limiter *Limiter
+	// Cache revalidates image downloads instead of transferring them again; nil disables caching.
+	Cache *Cache
+	// MaxDownloadSize bounds the bytes of an image download; zero selects DefaultMaxDownloadSize.
+	MaxDownloadSize int64
+}
+
+// maxAttempts bounds how often an API request is sent when the API answers 429.
+const maxAttempts = 3
+
+// SearchParams are the filters of the search endpoint; zero fields are omitted so the API defaults apply.
+type SearchParams struct {
+	// Query is the search text, including the API's operators such as "+tag", "-tag", "@user", or "id:123".
+	Query string
+	// Categories enables general, anime, and people as three 0/1 digits, e.g. "100".
+	Categories string
+	// Purity enables sfw, sketchy, and nsfw as three 0/1 digits, e.g. "100"; nsfw needs an API key.
+	Purity string
+	// Sorting is one of date_added, relevance, random, views, favorites, toplist.
+	Sorting string
+	// Order is desc or asc.
+	Order string
+	// TopRange is the toplist period, e.g. 1M; it only applies with Sorting toplist.
+	TopRange string
+	// AtLeast is the minimum resolution, e.g. 1920x1080.
+	AtLeast string
+	// Resolutions lists exact resolutions, e.g. 2560x1440.
+	Resolutions []string
+	// Ratios lists aspect ratios, e.g. 16x9.
+	Ratios []string
+	// Colors lists hex colors without '#', e.g. 660000.
+	Colors []string
+	// Page selects the result page, starting at 1.
+	Page int
+	// Seed keeps random sorting stable across pages; take it from the first page's Meta.
+	Seed string
+}
+
+// values encodes the non-zero parameters as query values.
+func (p SearchParams) values() url.Values {
+	v := url.Values{}
+	for key, value := range map[string]string{
+		"q":           p.Query,
+		"categories":  p.Categories,
+		"purity":      p.Purity,
+		"sorting":     p.Sorting,
+		"order":       p.Order,
+		"topRange":    p.TopRange,
+		"atleast":     p.AtLeast,
+		"resolutions": strings.Join(p.Resolutions, ","),
+		"ratios":      strings.Join(p.Ratios, ","),
+		"colors":      strings.Join(p.Colors, ","),
+		"seed":        p.Seed,
+	} {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	if p.Page > 0 {
+		v.Set("page", strconv.Itoa(p.Page))
+	}
+	return v
+}
+
+// Wallpaper is a search result or, from Wallpaper, a full detail record with uploader and tags.
+type Wallpaper struct {
+	ID         string   `json:"id"`
+	URL        string   `json:"url"`
+	ShortURL   string   `json:"short_url"`
+	Views      int      `json:"views"`
+	Favorites  int      `json:"favorites"`
+	Source     string   `json:"source"`
+	Purity     string   `json:"purity"`
+	Category   string   `json:"category"`
+	DimensionX int      `json:"dimension_x"`
+	DimensionY int      `json:"dimension_y"`
+	Resolution string   `json:"resolution"`
+	Ratio      string   `json:"ratio"`
+	FileSize   int64    `json:"file_size"`
+	FileType   string   `json:"file_type"`
+	CreatedAt  string   `json:"created_at"`
+	Colors     []string `json:"colors"`
+	// Path is the full-size image URL.
+	Path   string `json:"path"`
+	Thumbs Thumbs `json:"thumbs"`
+	// Uploader and Tags are only set by the detail endpoint.
+	Uploader *Uploader `json:"uploader,omitempty"`
+	Tags     []Tag     `json:"tags,omitempty"`
+}
+
+// Thumbs holds the thumbnail URLs of a wallpaper.
+type Thumbs struct {
+	Large    string `json:"large"`
+	Original string `json:"original"`
+	Small    string `json:"small"`
+}
+
+// Uploader is the user who uploaded a wallpaper.
+type Uploader struct {
+	Username string            `json:"username"`
+	Group    string            `json:"group"`
+	Avatar   map[string]string `json:"avatar"`
+}
+
+// Tag is a wallpaper tag.
+type Tag struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Alias      string `json:"alias"`
+	CategoryID int    `json:"category_id"`
+	Category   string `json:"category"`
+	Purity     string `json:"purity"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// Collection is a user's wallpaper collection.
+type Collection struct {
+	ID     int    `json:"id"`
+	Label  string `json:"label"`
+	Views  int    `json:"views"`
+	Public int    `json:"public"`
+	Count  int    `json:"count"`
+}
+
+// Meta describes the pagination of a result list.
+type Meta struct {
+	CurrentPage int `json:"current_page"`
+	LastPage    int `json:"last_page"`
+	PerPage     int `json:"per_page"`
+	Total       int `json:"total"`
+	// Query echoes the search text; searches by tag ID report the tag name.
+	Query Query `json:"query"`
+	// Seed is set for random sorting; pass it to later pages.
+	Seed string `json:"seed"`
+}
+
+// Query is the search text a result list was produced for.
+type Query struct {
+	Text  string
+	TagID int
+}
+
+// UnmarshalJSON accepts the plain string and the {"id": 1, "tag": "name"} object the API returns for tag searches.
+func (q *Query) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*q = Query{}
+		return nil
+	}
+	if err := json.Unmarshal(data, &q.Text); err == nil {
+		return nil
+	}
+	var tag struct {
+		ID  int    `json:"id"`
+		Tag string `json:"tag"`
+	}
+	if err := json.Unmarshal(data, &tag); err != nil {
+		return err
+	}
+	*q = Query{Text: tag.Tag, TagID: tag.ID}
+	return nil
+}
+
+// UnmarshalJSON accepts per_page as a number or a numeric string; the API sends either.
+func (m *Meta) UnmarshalJSON(data []byte) error {
+	type plain Meta
+	aux := struct {
+		*plain
+		PerPage json.RawMessage `json:"per_page"`
+	}{plain: (*plain)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if len(aux.PerPage) == 0 || string(aux.PerPage) == "null" {
+		m.PerPage = 0
+		return nil
+	}
+	n, err := strconv.Atoi(strings.Trim(string(aux.PerPage), `"`))
+	if err != nil {
+		return fmt.Errorf("invalid per_page %s", aux.PerPage)
+	}
+	m.PerPage = n
+	return nil
+}
+
+// Page is one page of wallpapers from search or a collection.
+type Page struct {
+	Data []Wallpaper `json:"data"`
+	Meta Meta        `json:"meta"`
+}
+
+// Search returns one page of search results.
+func (c *Client) Search(params SearchParams) (*Page, error) {
+	var page Page
+	if err := c.get("search", "/search", params.values(), &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Wallpaper returns the details of the wallpaper with the given ID, including uploader and tags.
+func (c *Client) Wallpaper(id string) (*Wallpaper, error) {
+	var resp struct {
+		Data Wallpaper `json:"data"`
+	}
+	if err := c.get("wallpaper", "/w/"+url.PathEscape(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// idPattern matches wallpaper IDs, e.g. "94x38z".
+var idPattern = regexp.MustCompile(`^[0-9a-z]+$`)
+
+// ParseWallpaperID returns the wallpaper ID in ref, which is a bare ID, a page URL such as https://wallhaven.cc/w/94x38z
+// or https://whvn.cc/94x38z, or an image URL such as https://w.wallhaven.cc/full/94/wallhaven-94x38z.jpg.
+// It returns an error if no ID can be found.
+func ParseWallpaperID(ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if idPattern.MatchString(ref) {
+		return ref, nil
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("wallhaven: %q is neither a wallpaper ID nor a URL", ref)
+	}
+	dir, name := path.Split(u.Path)
+	switch {
+	case strings.HasPrefix(name, "wallhaven-"):
+		name = strings.TrimSuffix(strings.TrimPrefix(name, "wallhaven-"), path.Ext(name))
+	case dir == "/w/", dir == "/" && u.Hostname() == "whvn.cc":
+	default:
+		name = ""
+	}
+	if !idPattern.MatchString(name) {
+		return "", fmt.Errorf("wallhaven: no wallpaper ID in URL %q", ref)
+	}
+	return name, nil
+}
+
+// ParseCollection returns the owner and ID of a collection given as "username/id" or as its page URL, e.g.
+// https://wallhaven.cc/user/alice/favorites/123. It returns an error for other forms.
+func ParseCollection(ref string) (username string, id int, err error) {
+	ref = strings.TrimSpace(ref)
+	if u, perr := url.Parse(ref); perr == nil && u.Host != "" {
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) != 4 || parts[0] != "user" || parts[2] != "favorites" {
+			return "", 0, fmt.Errorf("wallhaven: no collection in URL %q", ref)
+		}
+		ref = parts[1] + "/" + parts[3]
+	}
+	username, rawID, ok := strings.Cut(ref, "/")
+	id, err = strconv.Atoi(rawID)
+	if !ok || username == "" || err != nil || id < 1 {
+		return "", 0, fmt.Errorf("wallhaven: invalid collection %q: want username/id", ref)
+	}
+	return username, id, nil
+}
+
+// Tag returns the tag with the given ID.
+func (c *Client) Tag(id int) (*Tag, error) {
+	var resp struct {
+		Data Tag `json:"data"`
+	}
+	if err := c.get("tag", "/tag/"+strconv.Itoa(id), nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// Collections lists the public collections of username, or the API key owner's collections for an empty username.
+func (c *Client) Collections(username string) ([]Collection, error) {
+	path := "/collections"
+	if username != "" {
+		path += "/" + url.PathEscape(username)
+	}
+	var resp struct {
+		Data []Collection `json:"data"`
+	}
+	if err := c.get("collections", path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// CollectionWallpapers returns one page of the wallpapers in a collection; purity filters like in SearchParams.
+func (c *Client) CollectionWallpapers(username string, id int, purity string, page int) (*Page, error) {
+	values := SearchParams{Purity: purity, Page: page}.values()
+	var resp Page
+	if err := c.get("collection", "/collections/"+url.PathEscape(username)+"/"+strconv.Itoa(id), values, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Download fetches a wallpaper image, e.g. Wallpaper.Path, and returns its bytes.
+// With a Cache, a cached image is returned when the server answers the conditional request with 304 Not Modified.
+func (c *Client) Download(imageURL string) ([]byte, error) {
+	var header http.Header
+	var cached []byte
+	if c.Cache != nil {
+		if entry, data, ok := c.Cache.load(imageURL); ok {
+			header, cached = entry.conditional(), data
+		}
+	}
+	resp, err := c.do("download", imageURL, nil, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		c.Cache.observe(true)
+		return cached, nil
+	}
+	limit := c.MaxDownloadSize
+	if limit <= 0 {
+		limit = DefaultMaxDownloadSize
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("wallhaven: download: read body: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("wallhaven: download: %w: larger than %d bytes", ErrTooLarge, limit)
+	}
+	if c.Cache != nil {
+		if err := c.Cache.store(imageURL, resp, data); err != nil {
+			return nil, fmt.Errorf("wallhaven: download: %w", err)
+		}
+		c.Cache.observe(false)
+	}
+	return data, nil
+}
+
+// get requests path below the base URL and decodes the JSON response into out; op names the call in errors.
+func (c *Client) get(op, path string, query url.Values, out any) error {
+	base := c.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	endpoint := strings.TrimSuffix(base, "/") + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	limiter := c.Limiter
+	if limiter == nil {
+		limiter = DefaultLimiter
+	}
+	resp, err := c.do(op, endpoint, limiter, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("wallhaven: %s: decode response: %w", op, err)
+	}
+	return nil
+}
+
+// do sends a GET request with the extra header and returns the response of a 2xx status, or of a 304 status for
+// conditional requests; other statuses are mapped to errors.
+// A non-nil limiter paces the request, observes the response, and retries it after 429 responses.
+func (c *Client) do(op, endpoint string, limiter *Limiter, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("wallhaven: %s: %w", op, err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	var resp *http.Response
+	for attempt := 1; ; attempt++ {
+		if limiter != nil {
+			limiter.Wait()
+		}
+		resp, err = httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("wallhaven: %s: request failed: %w", op, err)
+		}
+		if limiter == nil {
+			break
+		}
+		limiter.Observe(resp)
+		if resp.StatusCode != http.StatusTooManyRequests || attempt == maxAttempts {
+			break
+		}
+		resp.Body.Close()
+	}
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices ||
+		resp.StatusCode == http.StatusNotModified && len(header) > 0 {
+		return resp, nil
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		err = ErrUnauthorized
+	case http.StatusNotFound:
+		err = ErrNotFound
+	case http.StatusTooManyRequests:
+		err = ErrRateLimited
+	default:
+		if resp.StatusCode < http.StatusInternalServerError {
+			return nil, fmt.Errorf("wallhaven: %s: http %d", op, resp.StatusCode)
+		}
+		err = ErrServer
+	}
+	return nil, fmt.Errorf("wallhaven: %s: http %d: %w", op, resp.StatusCode, err)
+}
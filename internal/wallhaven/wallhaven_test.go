@@ -0,0 +1,189 @@
+package wallhaven
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// testServer serves canned API responses by path and records the last request.
+// Unknown paths answer 404, and the path /limited answers 429.
+func testServer(t *testing.T, responses map[string]string, last **http.Request) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*last = r
+		if r.URL.Path == "/api/v1/limited" {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	limiter, _ := fakeLimiter(0)
+	return &Client{HTTPClient: server.Client(), BaseURL: server.URL + "/api/v1/", APIKey: "secret", Limiter: limiter}
+}
+
+// TestClient_SearchPagination expects search parameters to be encoded and pagination metadata to decode, including
+// the quoted per_page and the object query the API returns for tag searches.
+// The test fails if a parameter is dropped or a meta field decodes wrong.
+func TestClient_SearchPagination(t *testing.T) {
+	var last *http.Request
+	client := testServer(t, map[string]string{
+		"/api/v1/search": `{"data":[{"id":"abc","path":"https://w.wallhaven.cc/full/ab/wallhaven-abc.jpg","dimension_x":2560,"dimension_y":1440,"colors":["#663300"],"thumbs":{"small":"s"}}],
+			"meta":{"current_page":2,"last_page":9,"per_page":"24","total":200,"query":{"id":37,"tag":"nature"},"seed":"Xy12"}}`,
+	}, &last)
+
+	page, err := client.Search(SearchParams{Query: "id:37", Purity: "110", Sorting: "random", Resolutions: []string{"2560x1440", "3840x2160"}, Page: 2, Seed: "Xy12"})
+	if err != nil {
+		t.Fatalf("Search error: %v", err)
+	}
+	query := last.URL.Query()
+	for key, want := range map[string]string{"q": "id:37", "purity": "110", "sorting": "random", "resolutions": "2560x1440,3840x2160", "page": "2", "seed": "Xy12"} {
+		if got := query.Get(key); got != want {
+			t.Fatalf("query %s: got %q want %q", key, got, want)
+		}
+	}
+	if query.Has("categories") || last.Header.Get("X-API-Key") != "secret" {
+		t.Fatalf("unexpected request %s with key %q", last.URL, last.Header.Get("X-API-Key"))
+	}
+	wantMeta := Meta{CurrentPage: 2, LastPage: 9, PerPage: 24, Total: 200, Query: Query{Text: "nature", TagID: 37}, Seed: "Xy12"}
+	if page.Meta != wantMeta {
+		t.Fatalf("meta: got %+v want %+v", page.Meta, wantMeta)
+	}
+	if len(page.Data) != 1 || page.Data[0].ID != "abc" || page.Data[0].DimensionX != 2560 || page.Data[0].Thumbs.Small != "s" {
+		t.Fatalf("unexpected data: %+v", page.Data)
+	}
+
+	var meta Meta
+	if err := meta.UnmarshalJSON([]byte(`{"per_page":24,"query":"nature","seed":null}`)); err != nil || meta.PerPage != 24 || meta.Query.Text != "nature" {
+		t.Fatalf("plain meta: got %+v, %v", meta, err)
+	}
+}
+
+// TestClient_DetailTagsAndCollections expects the detail, tag, and collection endpoints to decode into their types.
+// The test fails if a path is built wrong or a field is lost.
+func TestClient_DetailTagsAndCollections(t *testing.T) {
+	var last *http.Request
+	client := testServer(t, map[string]string{
+		"/api/v1/w/abc":             `{"data":{"id":"abc","uploader":{"username":"ann","avatar":{"32px":"a.png"}},"tags":[{"id":37,"name":"nature"}]}}`,
+		"/api/v1/tag/37":            `{"data":{"id":37,"name":"nature","alias":"outdoors","category_id":5,"category":"Nature","purity":"sfw"}}`,
+		"/api/v1/collections":       `{"data":[{"id":1,"label":"Mine","public":0,"count":3}]}`,
+		"/api/v1/collections/ann":   `{"data":[{"id":7,"label":"Forests","views":10,"public":1,"count":42}]}`,
+		"/api/v1/collections/ann/7": `{"data":[{"id":"def"}],"meta":{"current_page":3,"last_page":3,"per_page":24,"total":42}}`,
+	}, &last)
+
+	w, err := client.Wallpaper("abc")
+	if err != nil || w.Uploader == nil || w.Uploader.Username != "ann" || w.Uploader.Avatar["32px"] != "a.png" || len(w.Tags) != 1 || w.Tags[0].Name != "nature" {
+		t.Fatalf("Wallpaper: got %+v, %v", w, err)
+	}
+	tag, err := client.Tag(37)
+	want := Tag{ID: 37, Name: "nature", Alias: "outdoors", CategoryID: 5, Category: "Nature", Purity: "sfw"}
+	if err != nil || *tag != want {
+		t.Fatalf("Tag: got %+v, %v", tag, err)
+	}
+	own, err := client.Collections("")
+	if err != nil || !reflect.DeepEqual(own, []Collection{{ID: 1, Label: "Mine", Count: 3}}) {
+		t.Fatalf("own Collections: got %+v, %v", own, err)
+	}
+	theirs, err := client.Collections("ann")
+	if err != nil || !reflect.DeepEqual(theirs, []Collection{{ID: 7, Label: "Forests", Views: 10, Public: 1, Count: 42}}) {
+		t.Fatalf("Collections: got %+v, %v", theirs, err)
+	}
+	page, err := client.CollectionWallpapers("ann", 7, "100", 3)
+	if err != nil || len(page.Data) != 1 || page.Data[0].ID != "def" || page.Meta.CurrentPage != 3 {
+		t.Fatalf("CollectionWallpapers: got %+v, %v", page, err)
+	}
+	if q := last.URL.Query(); q.Get("purity") != "100" || q.Get("page") != "3" {
+		t.Fatalf("CollectionWallpapers query: %s", last.URL.RawQuery)
+	}
+}
+
+// TestClient_ErrorStatuses expects rejected requests to wrap the sentinel for their status, malformed bodies to fail,
+// and downloads beyond the limit to wrap ErrTooLarge. The test fails if a status maps to the wrong error, a bad
+// response decodes silently, or an oversized download is read whole.
+func TestClient_ErrorStatuses(t *testing.T) {
+	var last *http.Request
+	client := testServer(t, map[string]string{"/api/v1/tag/1": `{not json`, "/api/v1/image": "image"}, &last)
+
+	if _, err := client.Wallpaper("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := client.get("limited", "/limited", nil, &struct{}{}); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if _, err := client.Tag(1); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected decode error, got %v", err)
+	}
+	if _, err := client.Download(client.BaseURL + "nothing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for download, got %v", err)
+	}
+	client.MaxDownloadSize = 4
+	if _, err := client.Download(client.BaseURL + "image"); !errors.Is(err, ErrTooLarge) || err.Error() != "wallhaven: download: too large: larger than 4 bytes" {
+		t.Fatalf("expected ErrTooLarge for a download beyond the limit, got %v", err)
+	}
+}
+
+// TestParseWallpaperID expects bare IDs, page URLs, short URLs, and image URLs to yield the wallpaper ID, and other
+// URLs to be rejected. The test fails if an accepted form yields a wrong ID or an unrelated URL is accepted.
+func TestParseWallpaperID(t *testing.T) {
+	for ref, want := range map[string]string{
+		"94x38z":                        "94x38z",
+		" 94x38z ":                      "94x38z",
+		"https://wallhaven.cc/w/94x38z": "94x38z",
+		"https://whvn.cc/94x38z":        "94x38z",
+		"https://w.wallhaven.cc/full/94/wallhaven-94x38z.jpg": "94x38z",
+		"https://th.wallhaven.cc/small/94/94x38z.jpg":         "",
+		"https://wallhaven.cc/search?q=nature":                "",
+		"https://wallhaven.cc/":                               "",
+		"94X38Z":                                              "",
+		"":                                                    "",
+	} {
+		got, err := ParseWallpaperID(ref)
+		if want == "" {
+			if err == nil {
+				t.Fatalf("ParseWallpaperID(%q) = %q, want error", ref, got)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Fatalf("ParseWallpaperID(%q) = %q, %v; want %q", ref, got, err, want)
+		}
+	}
+}
+
+// TestParseCollection expects "username/id" and collection page URLs to yield owner and ID, and other forms to be
+// rejected. The test fails if an accepted form is parsed wrong or a malformed reference is accepted.
+func TestParseCollection(t *testing.T) {
+	type collection struct {
+		user string
+		id   int
+	}
+	for ref, want := range map[string]collection{
+		"alice/123": {"alice", 123},
+		"https://wallhaven.cc/user/alice/favorites/123": {"alice", 123},
+		"alice":      {},
+		"alice/0":    {},
+		"alice/12/3": {},
+		"/123":       {},
+		"https://wallhaven.cc/user/alice/uploads": {},
+	} {
+		user, id, err := ParseCollection(ref)
+		if want.user == "" {
+			if err == nil {
+				t.Fatalf("ParseCollection(%q) = %q, %d; want error", ref, user, id)
+			}
+			continue
+		}
+		if err != nil || user != want.user || id != want.id {
+			t.Fatalf("ParseCollection(%q) = %q, %d, %v; want %+v", ref, user, id, err, want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package wallpaper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	stddraw "image/draw"
+	"image/gif"
+)
+
+// AnimatedPolicy selects which frame of an animated GIF or APNG background is used, or rejects animated backgrounds.
+type AnimatedPolicy string
+
+const (
+	// AnimatedFirst uses the first frame, or the default image of an APNG; this matches the historical output.
+	AnimatedFirst AnimatedPolicy = "first"
+	// AnimatedMiddle uses the middle frame of an animated GIF, which often shows more than a fade-in does. APNGs use
+	// their default image, the only one the PNG decoder decodes.
+	AnimatedMiddle AnimatedPolicy = "middle"
+	// AnimatedReject fails with an *AnimatedError, so a fallback source is used instead.
+	AnimatedReject AnimatedPolicy = "reject"
+)
+
+// AnimatedPolicies returns all animation policies in a stable order for help output and validation.
+func AnimatedPolicies() []AnimatedPolicy {
+	return []AnimatedPolicy{AnimatedFirst, AnimatedMiddle, AnimatedReject}
+}
+
+// ParseAnimatedPolicy converts a user-supplied policy name into an AnimatedPolicy; empty selects AnimatedFirst.
+func ParseAnimatedPolicy(name string) (AnimatedPolicy, error) {
+	if name == "" {
+		return AnimatedFirst, nil
+	}
+	for _, a := range AnimatedPolicies() {
+		if string(a) == name {
+			return a, nil
+		}
+	}
+	return AnimatedFirst, fmt.Errorf("decode: unknown animation policy %q", name)
+}
+
+// AnimatedError is returned for an animated background under AnimatedReject. It wraps ErrDecode, so fallbacks and
+// ClassifyFetchError treat it like undecodable data.
+type AnimatedError struct {
+	// Format is "GIF" or "APNG".
+	Format string
+	Frames int
+}
+
+func (e *AnimatedError) Error() string {
+	return fmt.Sprintf("%v: background is an animated %s with %d frames", ErrDecode, e.Format, e.Frames)
+}
+
+// Unwrap returns ErrDecode.
+func (e *AnimatedError) Unwrap() error {
+	return ErrDecode
+}
+
+// decodeAnimated applies l.Animated to data, an image of the size in config, and reports the frame it uses to l.Warn.
+// It returns a nil image and no error if data is not animated or its first image is used, which image.Decode decodes.
+func (l Limits) decodeAnimated(data []byte, config image.Config) (image.Image, error) {
+	format, frames := "GIF", 0
+	ends := gifFrameEnds(data)
+	if frames = len(ends); frames < 2 {
+		format, frames = "APNG", apngFrames(data)
+	}
+	if frames < 2 {
+		return nil, nil
+	}
+	switch {
+	case l.Animated == AnimatedReject:
+		return nil, &AnimatedError{Format: format, Frames: frames}
+	case l.Animated == AnimatedMiddle && format == "GIF":
+		// Decoding stops after the middle frame, but the frames up to it are held at once; they count against the
+		// pixel limit like a single larger image would.
+		middle := frames / 2
+		if maxPixels := l.resolved().MaxPixels; int64(middle+1)*int64(config.Width)*int64(config.Height) > maxPixels {
+			l.warn("background is an animated GIF with %d frames, too large to compose its middle frame; using the first frame",
+				frames)
+			return nil, nil
+		}
+		g, err := gif.DecodeAll(bytes.NewReader(append(data[:ends[middle]:ends[middle]], gifTrailer)))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrDecode, err)
+		}
+		l.warn("background is an animated GIF with %d frames; using frame %d", frames, middle+1)
+		return composeGIF(g), nil
+	case format == "APNG":
+		l.warn("background is an animated APNG with %d frames; using its default image", frames)
+	default:
+		l.warn("background is an animated GIF with %d frames; using the first frame", frames)
+	}
+	return nil, nil
+}
+
+// warn passes a message to l.Warn if it is set.
+func (l Limits) warn(format string, args ...any) {
+	if l.Warn != nil {
+		l.Warn(fmt.Sprintf(format, args...))
+	}
+}
+
+// composeGIF draws the frames of g onto its logical screen, disposing of each but the last as it asks, and returns
+// the screen after the last frame.
+func composeGIF(g *gif.GIF) image.Image {
+	screen := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(screen.Rect)
+			copy(previous.Pix, screen.Pix)
+		}
+		stddraw.Draw(screen, frame.Bounds(), frame, frame.Bounds().Min, stddraw.Over)
+		if i == len(g.Image)-1 {
+			break
+		}
+		switch disposal {
+		case gif.DisposalBackground:
+			stddraw.Draw(screen, frame.Bounds(), image.Transparent, image.Point{}, stddraw.Src)
+		case gif.DisposalPrevious:
+			screen = previous
+		}
+	}
+	return screen
+}
+
+// gifTrailer ends the blocks of a GIF.
+const gifTrailer = 0x3b
+
+// gifFrameEnds returns the offset after each complete frame of GIF data, without decoding any pixels, so the frames can
+// be counted and data cut after one of them. It returns nil for data that is not a GIF.
+func gifFrameEnds(data []byte) []int {
+	if len(data) < 13 || !bytes.HasPrefix(data, []byte("GIF8")) {
+		return nil
+	}
+	pos := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << (flags&7 + 1)
+	}
+	var ends []int
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // extension: label and sub-blocks
+			pos = skipGIFSubBlocks(data, pos+2)
+		case 0x2c: // image descriptor, local color table, LZW minimum code size, and sub-blocks
+			if pos+10 > len(data) {
+				return ends
+			}
+			if flags := data[pos+9]; flags&0x80 != 0 {
+				pos += 3 << (flags&7 + 1)
+			}
+			if pos = skipGIFSubBlocks(data, pos+11); pos > len(data) {
+				return ends
+			}
+			ends = append(ends, pos)
+		default: // trailer or garbage
+			return ends
+		}
+	}
+	return ends
+}
+
+// skipGIFSubBlocks returns the offset after the sub-blocks starting at pos, or len(data)+1 if they are truncated.
+func skipGIFSubBlocks(data []byte, pos int) int {
+	for pos < len(data) {
+		n := int(data[pos])
+		pos++
+		if n == 0 {
+			return pos
+		}
+		pos += n
+	}
+	return len(data) + 1
+}
+
+// apngFrames returns the number of frames an APNG's acTL chunk declares, or 0 for data that is not an APNG. The chunk
+// must precede the image data, so only the chunks before it are read.
+func apngFrames(data []byte) int {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return 0
+	}
+	for pos := len(signature); pos+8 <= len(data); {
+		switch string(data[pos+4 : pos+8]) {
+		case "acTL":
+			if pos+12 > len(data) {
+				return 0
+			}
+			return int(binary.BigEndian.Uint32(data[pos+8:]))
+		case "IDAT":
+			return 0
+		}
+		n := binary.BigEndian.Uint32(data[pos:])
+		if n > uint32(len(data)) {
+			return 0
+		}
+		pos += 12 + int(n)
+	}
+	return 0
+}
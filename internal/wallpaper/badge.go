@@ -0,0 +1,261 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"golang.org/x/image/font"
+)
+
+// Channel identifies the release channel shown as a badge in the top-right corner.
+type Channel string
+
+const (
+	ChannelNone    Channel = ""
+	ChannelStable  Channel = "stable"
+	ChannelBeta    Channel = "beta"
+	ChannelNightly Channel = "nightly"
+	ChannelDev     Channel = "dev"
+)
+
+// BadgePalette names a built-in set of channel badge colors.
+type BadgePalette string
+
+const (
+	// BadgePaletteDefault is green, blue, orange, and purple on a slightly translucent badge. Stable and nightly are
+	// hard to tell apart with protanopia.
+	BadgePaletteDefault BadgePalette = "default"
+	// BadgePaletteOkabeIto takes bluish green, blue, orange, and reddish purple from the palette of Okabe and Ito,
+	// designed to stay distinct for the common color vision deficiencies.
+	BadgePaletteOkabeIto BadgePalette = "okabe-ito"
+	// BadgePaletteTolBright takes green, cyan, yellow, and purple from Paul Tol's bright qualitative scheme, which
+	// keeps the channels further apart than okabe-ito at the cost of lighter colors.
+	BadgePaletteTolBright BadgePalette = "tol-bright"
+)
+
+// badgePalettes maps each palette to the badge background color of each channel.
+var badgePalettes = map[BadgePalette]map[Channel]color.NRGBA{
+	BadgePaletteDefault: {
+		ChannelStable:  {R: 46, G: 160, B: 67, A: 235},
+		ChannelBeta:    {R: 31, G: 111, B: 235, A: 235},
+		ChannelNightly: {R: 230, G: 126, B: 34, A: 235},
+		ChannelDev:     {R: 142, G: 68, B: 173, A: 235},
+	},
+	BadgePaletteOkabeIto: {
+		ChannelStable:  {R: 0, G: 158, B: 115, A: 255},
+		ChannelBeta:    {R: 0, G: 114, B: 178, A: 255},
+		ChannelNightly: {R: 230, G: 159, B: 0, A: 255},
+		ChannelDev:     {R: 204, G: 121, B: 167, A: 255},
+	},
+	BadgePaletteTolBright: {
+		ChannelStable:  {R: 34, G: 136, B: 51, A: 255},
+		ChannelBeta:    {R: 102, G: 204, B: 238, A: 255},
+		ChannelNightly: {R: 204, G: 187, B: 68, A: 255},
+		ChannelDev:     {R: 170, G: 51, B: 119, A: 255},
+	},
+}
+
+// BadgePalettes returns all selectable badge palettes in a stable order for help output and validation.
+func BadgePalettes() []BadgePalette {
+	return []BadgePalette{BadgePaletteDefault, BadgePaletteOkabeIto, BadgePaletteTolBright}
+}
+
+// BadgeStyle selects the colors of the channel badge.
+type BadgeStyle struct {
+	// Palette names a built-in palette; empty selects BadgePaletteDefault.
+	Palette BadgePalette `json:"palette,omitempty"`
+	// Colors overrides the palette's color of a channel with an opaque "#rrggbb".
+	Colors map[Channel]string `json:"colors,omitempty"`
+}
+
+// custom reports whether s differs from the default palette. Custom badges are checked with CheckBadgeColors and
+// get a black or white label, whichever contrasts more; the default keeps its white label.
+func (s BadgeStyle) custom() bool {
+	return (s.Palette != "" && s.Palette != BadgePaletteDefault) || len(s.Colors) > 0
+}
+
+// colors returns the badge color of every channel.
+func (s BadgeStyle) colors() (map[Channel]color.NRGBA, error) {
+	palette := s.Palette
+	if palette == "" {
+		palette = BadgePaletteDefault
+	}
+	base, ok := badgePalettes[palette]
+	if !ok {
+		return nil, fmt.Errorf("unknown palette %q", s.Palette)
+	}
+	colors := make(map[Channel]color.NRGBA, len(base))
+	for c, v := range base {
+		colors[c] = v
+	}
+	for c, hex := range s.Colors {
+		if _, ok := base[c]; !ok {
+			return nil, fmt.Errorf("colors: unknown channel %q", c)
+		}
+		v, err := parseHexColor(hex, color.NRGBA{})
+		if err != nil {
+			return nil, fmt.Errorf("colors: %s: %w", c, err)
+		}
+		if v.A != 255 {
+			return nil, fmt.Errorf("colors: %s: %s must be opaque", c, hex)
+		}
+		colors[c] = v
+	}
+	return colors, nil
+}
+
+// validate checks the palette and colors, and that custom colors pass CheckBadgeColors.
+func (s BadgeStyle) validate() error {
+	colors, err := s.colors()
+	if err != nil {
+		return fmt.Errorf("theme: badge: %w", err)
+	}
+	if s.custom() {
+		if err := CheckBadgeColors(colors); err != nil {
+			return fmt.Errorf("theme: badge: %w", err)
+		}
+	}
+	return nil
+}
+
+// MinBadgeDistance is the smallest CIE76 color difference that CheckBadgeColors accepts between the badges of two
+// channels; about 2.3 is just noticeable, and the default palette's stable and nightly are 9.3 apart with protanopia.
+const MinBadgeDistance = 15
+
+// colorVisions are the kinds of color vision CheckBadgeColors simulates, as matrices on linear sRGB after Viénot,
+// Brettel, and Mollon (1999). Tritanopia is rare enough that palettes are not checked for it.
+var colorVisions = []struct {
+	name   string
+	matrix [3][3]float64
+}{
+	{"normal vision", [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}},
+	{"protanopia", [3][3]float64{{0.11238, 0.88762, 0}, {0.11238, 0.88762, 0}, {0.00401, -0.00401, 1}}},
+	{"deuteranopia", [3][3]float64{{0.29275, 0.70725, 0}, {0.29275, 0.70725, 0}, {-0.02234, 0.02234, 1}}},
+}
+
+// CheckBadgeColors returns an error naming the first two channels whose badge colors differ by less than
+// MinBadgeDistance in CIELAB with normal vision, protanopia, or deuteranopia.
+func CheckBadgeColors(colors map[Channel]color.NRGBA) error {
+	channels := Channels()
+	for _, vision := range colorVisions {
+		for i, a := range channels {
+			for _, b := range channels[i+1:] {
+				if d := badgeDistance(vision.matrix, colors[a], colors[b]); d < MinBadgeDistance {
+					return fmt.Errorf("%s and %s are %.1f apart with %s, want at least %d", a, b, d, vision.name, MinBadgeDistance)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// badgeDistance returns the CIE76 difference of a and b as seen through the color vision matrix m.
+func badgeDistance(m [3][3]float64, a, b color.NRGBA) float64 {
+	la, aa, ba := simulatedLab(m, a)
+	lb, ab, bb := simulatedLab(m, b)
+	return math.Sqrt((la-lb)*(la-lb) + (aa-ab)*(aa-ab) + (ba-bb)*(ba-bb))
+}
+
+// simulatedLab returns c as seen through the color vision matrix m in CIELAB with a D65 white point.
+func simulatedLab(m [3][3]float64, c color.NRGBA) (l, a, b float64) {
+	in := [3]float64{srgbToLinear(float64(c.R) / 255), srgbToLinear(float64(c.G) / 255), srgbToLinear(float64(c.B) / 255)}
+	var rgb [3]float64
+	for i, row := range m {
+		rgb[i] = math.Min(1, math.Max(0, row[0]*in[0]+row[1]*in[1]+row[2]*in[2]))
+	}
+	x := (0.4124564*rgb[0] + 0.3575761*rgb[1] + 0.1804375*rgb[2]) / 0.95047
+	y := 0.2126729*rgb[0] + 0.7151522*rgb[1] + 0.0721750*rgb[2]
+	z := (0.0193339*rgb[0] + 0.1191920*rgb[1] + 0.9503041*rgb[2]) / 1.08883
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+const badgeFontScale = 0.03 // relative to image height
+
+// Channels returns all selectable channels in a stable order for help output and validation.
+func Channels() []Channel {
+	return []Channel{ChannelStable, ChannelBeta, ChannelNightly, ChannelDev}
+}
+
+// ParseChannel converts a user-supplied channel name into a Channel.
+// An empty name selects ChannelNone; unknown names return an error.
+func ParseChannel(name string) (Channel, error) {
+	if name == "" {
+		return ChannelNone, nil
+	}
+	for _, c := range Channels() {
+		if string(c) == name {
+			return c, nil
+		}
+	}
+	return ChannelNone, fmt.Errorf("render: unknown channel %q", name)
+}
+
+// badgeLabel returns the badge font face for layout and the label of channel with its text height.
+func badgeLabel(layout Layout, channel Channel, hinting font.Hinting) (font.Face, string, int, error) {
+	face, err := loadFace(boldFontData, float64(layout.Height)*badgeFontScale, hinting)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("render: load badge font: %w", err)
+	}
+	metrics := face.Metrics()
+	return face, strings.ToUpper(string(channel)), (metrics.Ascent + metrics.Descent).Ceil(), nil
+}
+
+// placeBadge returns the rectangle of the badge of channel in the top-right corner of layout (top-left for
+// right-to-left layouts), one padding in from the edges.
+func placeBadge(layout Layout, channel Channel, hinting font.Hinting) (image.Rectangle, error) {
+	face, label, textHeight, err := badgeLabel(layout, channel, hinting)
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	margin := layout.Padding
+	badgeW := font.MeasureString(face, label).Ceil() + 2*(textHeight/2)
+	badgeH := textHeight + 2*(textHeight/4)
+
+	if layout.Direction == DirectionRTL {
+		return image.Rect(margin, margin, margin+badgeW, margin+badgeH), nil
+	}
+	return image.Rect(layout.Width-margin-badgeW, margin, layout.Width-margin, margin+badgeH), nil
+}
+
+// drawBadge renders the channel name as an uppercase label on a rounded, colored badge at the badge rectangle of
+// layout (see placeBadge).
+// It is a no-op for ChannelNone and returns an error for unknown channels.
+func drawBadge(dst *image.RGBA, layout Layout, channel Channel, style BadgeStyle, hinting font.Hinting) error {
+	if channel == ChannelNone {
+		return nil
+	}
+	colors, err := style.colors()
+	if err != nil {
+		return fmt.Errorf("render: badge: %w", err)
+	}
+	bgColor, ok := colors[channel]
+	if !ok {
+		return fmt.Errorf("render: unknown channel %q", channel)
+	}
+
+	face, label, textHeight, err := badgeLabel(layout, channel, hinting)
+	if err != nil {
+		return err
+	}
+	metrics := face.Metrics()
+	padX := textHeight / 2
+	padY := textHeight / 4
+	rect := layout.badgeRect()
+	drawRoundedRect(dst, rect, rect.Dy()/3, bgColor)
+
+	textColor := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	if black := (color.NRGBA{A: 255}); style.custom() && ContrastRatio(black, bgColor) > ContrastRatio(textColor, bgColor) {
+		textColor = black
+	}
+	return drawText(dst, face, label, rect.Min.X+padX, rect.Min.Y+padY+metrics.Ascent.Ceil(), textColor)
+}
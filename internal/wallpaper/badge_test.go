@@ -0,0 +1,151 @@
+package wallpaper
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// TestRender_ChannelBadge_DrawnTopRight expects the nightly badge color in the top-right corner and nothing there without a channel.
+// The test fails if the badge is missing, misplaced, or drawn for ChannelNone.
+func TestRender_ChannelBadge_DrawnTopRight(t *testing.T) {
+	bg := solidBG(32, 32, color.RGBA{0, 0, 0, 255})
+
+	margin := maxInt(14, minInt(TargetWidth, TargetHeight)*paddingPercent/100)
+	// A point just inside the badge's right edge, vertically within its first text line.
+	x := TargetWidth - margin - 5
+	fontSize := float64(TargetHeight) * badgeFontScale
+	y := margin + int(fontSize/2)
+
+	plain, err := Render(bg, "t", "b", Options{})
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	if r, g, b, _ := plain.At(x, y).RGBA(); r>>8 != 0 || g>>8 != 0 || b>>8 != 0 {
+		t.Fatalf("expected untouched background without channel, got %v", plain.At(x, y))
+	}
+
+	img, err := Render(bg, "t", "b", Options{Channel: ChannelNightly})
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	r, g, b, _ := img.At(x, y).RGBA()
+	if r>>8 < 150 || g>>8 < 70 || b>>8 > 60 {
+		t.Fatalf("expected orange badge pixel at (%d,%d), got %v", x, y, img.At(x, y))
+	}
+}
+
+// TestParseChannel_KnownAndUnknown verifies channel name parsing, including the empty default.
+func TestParseChannel_KnownAndUnknown(t *testing.T) {
+	if c, err := ParseChannel(""); err != nil || c != ChannelNone {
+		t.Fatalf("ParseChannel(\"\") = %q, %v", c, err)
+	}
+	for _, want := range Channels() {
+		got, err := ParseChannel(string(want))
+		if err != nil || got != want {
+			t.Fatalf("ParseChannel(%q) = %q, %v", want, got, err)
+		}
+	}
+	if _, err := ParseChannel("canary"); err == nil {
+		t.Fatalf("expected error")
+	} else if !strings.Contains(err.Error(), "unknown channel") {
+		t.Fatalf("unexpected error: %q", err.Error())
+	}
+}
+
+// TestRender_ChannelBadge_MirroredForRTLTitle expects the badge in the top-left corner when the target name is right-to-left.
+func TestRender_ChannelBadge_MirroredForRTLTitle(t *testing.T) {
+	bg := solidBG(32, 32, color.RGBA{0, 0, 0, 255})
+
+	margin := maxInt(14, minInt(TargetWidth, TargetHeight)*paddingPercent/100)
+	fontSize := float64(TargetHeight) * badgeFontScale
+	y := margin + int(fontSize/2)
+
+	img, err := Render(bg, "שלום", "2026-01-04", Options{Channel: ChannelNightly})
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	if r, g, b, _ := img.At(margin+5, y).RGBA(); r>>8 < 150 || g>>8 < 70 || b>>8 > 60 {
+		t.Fatalf("expected orange badge pixel top-left, got %v", img.At(margin+5, y))
+	}
+	if r, g, b, _ := img.At(TargetWidth-margin-5, y).RGBA(); r>>8 != 0 || g>>8 != 0 || b>>8 != 0 {
+		t.Fatalf("expected no badge top-right, got %v", img.At(TargetWidth-margin-5, y))
+	}
+}
+
+// TestCheckBadgeColors_PalettesForColorVisionDeficiencies verifies that the okabe-ito and tol-bright palettes keep
+// every pair of channels apart with protanopia and deuteranopia, and that the default palette does not. The test fails
+// if a safe palette is rejected or the default's stable and nightly pass the check.
+func TestCheckBadgeColors_PalettesForColorVisionDeficiencies(t *testing.T) {
+	for _, palette := range []BadgePalette{BadgePaletteOkabeIto, BadgePaletteTolBright} {
+		colors, err := BadgeStyle{Palette: palette}.colors()
+		if err != nil {
+			t.Fatalf("%s: %v", palette, err)
+		}
+		if err := CheckBadgeColors(colors); err != nil {
+			t.Errorf("%s: %v", palette, err)
+		}
+	}
+	err := CheckBadgeColors(badgePalettes[BadgePaletteDefault])
+	if err == nil || !strings.Contains(err.Error(), "stable and nightly") || !strings.Contains(err.Error(), "protanopia") {
+		t.Fatalf("expected the default palette to fail for stable and nightly with protanopia, got %v", err)
+	}
+}
+
+// TestParseTheme_BadgePalette verifies palette selection and custom colors in themes. The test fails if an unknown
+// palette or channel, a translucent color, or custom colors that make beta and stable hard to tell apart are
+// accepted, or valid ones are rejected.
+func TestParseTheme_BadgePalette(t *testing.T) {
+	for _, data := range []string{
+		`{"badge": {"palette": "okabe-ito"}}`,
+		`{"badge": {"palette": "tol-bright", "colors": {"dev": "#000000"}}}`,
+		`{"badge": {"colors": {"nightly": "#ffe000"}}}`,
+	} {
+		if _, err := ParseTheme([]byte(data)); err != nil {
+			t.Errorf("%s: %v", data, err)
+		}
+	}
+	for data, want := range map[string]string{
+		`{"badge": {"palette": "rainbow"}}`:                                    "unknown palette",
+		`{"badge": {"palette": "okabe-ito", "colors": {"canary": "#ffffff"}}}`: "unknown channel",
+		`{"badge": {"palette": "okabe-ito", "colors": {"beta": "#0072b280"}}}`: "opaque",
+		`{"badge": {"palette": "okabe-ito", "colors": {"beta": "#009e80"}}}`:   "stable and beta",
+		`{"badge": {"colors": {"beta": "#1f6feb"}}}`:                           "stable and nightly",
+	} {
+		if _, err := ParseTheme([]byte(data)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", data, want, err)
+		}
+	}
+}
+
+// TestRender_ChannelBadge_ThemePalette expects the badge in the theme's palette color with a black label where that
+// contrasts more. The test fails if the default color is drawn or the label stays white on the light badge.
+func TestRender_ChannelBadge_ThemePalette(t *testing.T) {
+	// On white, the only dark pixels in the top-right corner are the label's.
+	bg := solidBG(32, 32, color.RGBA{255, 255, 255, 255})
+	margin := maxInt(14, minInt(TargetWidth, TargetHeight)*paddingPercent/100)
+	fontSize := float64(TargetHeight) * badgeFontScale
+
+	opts := Options{Channel: ChannelNightly}
+	opts.Theme.Badge.Palette = BadgePaletteTolBright
+	img, err := Render(bg, "t", "b", opts)
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	want := badgePalettes[BadgePaletteTolBright][ChannelNightly]
+	if got := color.NRGBAModel.Convert(img.At(TargetWidth-margin-5, margin+int(fontSize/2))).(color.NRGBA); got != want {
+		t.Fatalf("badge pixel = %v, want %v", got, want)
+	}
+	dark := false
+	for y := margin; y < margin+int(2*fontSize) && !dark; y++ {
+		for x := TargetWidth / 2; x < TargetWidth-margin; x++ {
+			if r, g, b, _ := img.At(x, y).RGBA(); r>>8 < 40 && g>>8 < 40 && b>>8 < 40 {
+				dark = true
+				break
+			}
+		}
+	}
+	if !dark {
+		t.Fatal("expected a black label on the light tol-bright nightly badge")
+	}
+}
@@ -0,0 +1,336 @@
+package wallpaper
+
+import (
+	"sort"
+	"unicode"
+
+	"golang.org/x/text/unicode/bidi"
+)
+
+// bidiClass is the simplified set of bidirectional character types used for reordering.
+type bidiClass uint8
+
+const (
+	classL   bidiClass = iota // strong left-to-right
+	classR                    // strong right-to-left (including Arabic letters)
+	classEN                   // European number
+	classAN                   // Arabic number
+	classON                   // neutral: whitespace, punctuation, separators
+	classNSM                  // non-spacing mark, takes the class of the preceding character
+)
+
+// classify maps each rune to a simplified bidi class using the Unicode bidi properties.
+// Number separators are resolved to numbers when they sit between digits of the same kind.
+func classify(runes []rune) []bidiClass {
+	raw := make([]bidi.Class, len(runes))
+	for i, r := range runes {
+		props, _ := bidi.LookupRune(r)
+		raw[i] = props.Class()
+	}
+
+	classes := make([]bidiClass, len(runes))
+	for i, c := range raw {
+		switch c {
+		case bidi.L:
+			classes[i] = classL
+		case bidi.R, bidi.AL:
+			classes[i] = classR
+		case bidi.EN:
+			classes[i] = classEN
+		case bidi.AN:
+			classes[i] = classAN
+		case bidi.NSM:
+			classes[i] = classNSM
+		default:
+			classes[i] = classON
+		}
+	}
+
+	for i, c := range raw {
+		if i == 0 || i == len(raw)-1 {
+			continue
+		}
+		prev, next := classes[i-1], classes[i+1]
+		switch {
+		case c == bidi.ES && prev == classEN && next == classEN:
+			classes[i] = classEN
+		case c == bidi.CS && prev == next && (prev == classEN || prev == classAN):
+			classes[i] = prev
+		}
+	}
+
+	// Runs of European terminators (%, $, ...) adjacent to European numbers become numbers.
+	for i := 0; i < len(raw); {
+		if raw[i] != bidi.ET {
+			i++
+			continue
+		}
+		j := i
+		for j < len(raw) && raw[j] == bidi.ET {
+			j++
+		}
+		if (i > 0 && classes[i-1] == classEN) || (j < len(raw) && classes[j] == classEN) {
+			for k := i; k < j; k++ {
+				classes[k] = classEN
+			}
+		}
+		i = j
+	}
+
+	for i, c := range classes {
+		if c == classNSM {
+			if i == 0 {
+				classes[i] = classON
+			} else {
+				classes[i] = classes[i-1]
+			}
+		}
+	}
+	return classes
+}
+
+// baseLevel returns 1 if the first strong character is right-to-left, otherwise 0.
+func baseLevel(classes []bidiClass) int {
+	for _, c := range classes {
+		switch c {
+		case classL:
+			return 0
+		case classR:
+			return 1
+		}
+	}
+	return 0
+}
+
+// visualOrder reorders a single line from logical to visual order following the core of the Unicode bidi algorithm
+// (weak/neutral resolution, implicit levels, and rule L2 reversal) without explicit embedding controls.
+func visualOrder(runes []rune) []rune {
+	return visualOrderBase(runes, baseLevel(classify(runes)))
+}
+
+// visualOrderBase is visualOrder with an explicit paragraph level (0 for left-to-right, 1 for right-to-left).
+func visualOrderBase(runes []rune, base int) []rune {
+	if len(runes) == 0 {
+		return runes
+	}
+	classes := classify(runes)
+	if base == 0 && !hasRTL(classes) {
+		return runes
+	}
+	levels := resolveLevels(runes, classes, base)
+	return reorderByLevels(runes, levels)
+}
+
+// hasRTL reports whether any character needs right-to-left handling.
+func hasRTL(classes []bidiClass) bool {
+	for _, c := range classes {
+		if c == classR || c == classAN {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveLevels assigns an embedding level to each character.
+// Bracket pairs resolve together (rule N0); other neutrals take the direction of surrounding strong types
+// when both sides agree, otherwise the base direction.
+func resolveLevels(runes []rune, classes []bidiClass, base int) []int {
+	baseDir := classL
+	if base == 1 {
+		baseDir = classR
+	}
+	// Numbers count as right-to-left when resolving neutrals.
+	strong := func(c bidiClass) bidiClass {
+		if c == classEN || c == classAN {
+			return classR
+		}
+		return c
+	}
+
+	resolved := make([]bidiClass, len(classes))
+	copy(resolved, classes)
+
+	// European numbers following left-to-right text (or at the start of a left-to-right line) are left-to-right.
+	lastStrong := baseDir
+	for i, c := range resolved {
+		switch c {
+		case classL, classR:
+			lastStrong = c
+		case classEN:
+			if lastStrong == classL {
+				resolved[i] = classL
+			}
+		}
+	}
+
+	resolveBracketPairs(runes, resolved, baseDir, strong)
+
+	for i := 0; i < len(resolved); {
+		if resolved[i] != classON {
+			i++
+			continue
+		}
+		j := i
+		for j < len(resolved) && resolved[j] == classON {
+			j++
+		}
+		before, after := baseDir, baseDir
+		if i > 0 {
+			before = strong(resolved[i-1])
+		}
+		if j < len(resolved) {
+			after = strong(resolved[j])
+		}
+		dir := baseDir
+		if before == after {
+			dir = before
+		}
+		for k := i; k < j; k++ {
+			resolved[k] = dir
+		}
+		i = j
+	}
+
+	levels := make([]int, len(classes))
+	for i, c := range resolved {
+		switch {
+		case base == 0 && c == classR:
+			levels[i] = 1
+		case base == 0 && (c == classEN || c == classAN):
+			levels[i] = 2
+		case base == 1 && (c == classL || c == classEN || c == classAN):
+			levels[i] = 2
+		default:
+			levels[i] = base
+		}
+	}
+
+	// Trailing whitespace returns to the base level.
+	for i := len(runes) - 1; i >= 0 && unicode.IsSpace(runes[i]); i-- {
+		levels[i] = base
+	}
+	return levels
+}
+
+// bracketPairs maps opening brackets to their closing counterparts for rule N0.
+var bracketPairs = map[rune]rune{'(': ')', '[': ']', '{': '}'}
+
+// resolveBracketPairs applies rule N0: a bracket pair takes the embedding direction if it encloses a strong type of
+// that direction, otherwise the opposite direction if it encloses one and the preceding context agrees.
+// Pairs are matched with a stack as in rule BD16; unmatched brackets stay neutral.
+func resolveBracketPairs(runes []rune, resolved []bidiClass, baseDir bidiClass, strong func(bidiClass) bidiClass) {
+	type pair struct{ open, close int }
+	var pairs []pair
+	var stack []int
+	for i, r := range runes {
+		if resolved[i] != classON {
+			continue
+		}
+		if _, ok := bracketPairs[r]; ok {
+			stack = append(stack, i)
+			continue
+		}
+		for k := len(stack) - 1; k >= 0; k-- {
+			if bracketPairs[runes[stack[k]]] == r {
+				pairs = append(pairs, pair{stack[k], i})
+				stack = stack[:k]
+				break
+			}
+		}
+	}
+	sort.Slice(pairs, func(a, b int) bool { return pairs[a].open < pairs[b].open })
+
+	opposite := classL
+	if baseDir == classL {
+		opposite = classR
+	}
+	for _, p := range pairs {
+		var foundEmbedding, foundOpposite bool
+		for k := p.open + 1; k < p.close; k++ {
+			switch c := strong(resolved[k]); c {
+			case baseDir:
+				foundEmbedding = true
+			case opposite:
+				foundOpposite = true
+			}
+		}
+		dir := classON
+		switch {
+		case foundEmbedding:
+			dir = baseDir
+		case foundOpposite:
+			before := baseDir
+			for k := p.open - 1; k >= 0; k-- {
+				if c := strong(resolved[k]); c == classL || c == classR {
+					before = c
+					break
+				}
+			}
+			dir = baseDir
+			if before == opposite {
+				dir = opposite
+			}
+		}
+		if dir != classON {
+			resolved[p.open], resolved[p.close] = dir, dir
+		}
+	}
+}
+
+// mirroredRunes maps paired punctuation to its mirror image for right-to-left runs (rule L4).
+var mirroredRunes = map[rune]rune{
+	'(': ')', ')': '(',
+	'[': ']', ']': '[',
+	'{': '}', '}': '{',
+	'<': '>', '>': '<',
+	'«': '»', '»': '«',
+	'‹': '›', '›': '‹',
+}
+
+// reorderByLevels mirrors paired punctuation at odd levels and then applies rule L2:
+// from the highest level down to the lowest odd level, reverse every maximal run at that level or higher.
+func reorderByLevels(runes []rune, levels []int) []rune {
+	out := make([]rune, len(runes))
+	copy(out, runes)
+	for i, r := range out {
+		if m, ok := mirroredRunes[r]; ok && levels[i]%2 == 1 {
+			out[i] = m
+		}
+	}
+	lv := make([]int, len(levels))
+	copy(lv, levels)
+
+	highest, lowestOdd := 0, 1<<30
+	for _, l := range lv {
+		if l > highest {
+			highest = l
+		}
+		if l%2 == 1 && l < lowestOdd {
+			lowestOdd = l
+		}
+	}
+	for level := highest; level >= lowestOdd && level > 0; level-- {
+		for i := 0; i < len(lv); {
+			if lv[i] < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(lv) && lv[j] >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				out[a], out[b] = out[b], out[a]
+				lv[a], lv[b] = lv[b], lv[a]
+			}
+			i = j
+		}
+	}
+	return out
+}
+
+// prepareText shapes Arabic script and reorders the line into visual order for left-to-right glyph drawing.
+// Emoji presentation selectors are dropped because fonts choose text or color glyphs by fallback order instead.
+func prepareText(s string) string {
+	return string(visualOrder(shapeArabic(dropPresentationSelectors([]rune(s)))))
+}
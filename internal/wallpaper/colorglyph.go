@@ -0,0 +1,278 @@
+package wallpaper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// colorBitmapFont holds the CBLC/CBDT color bitmap tables of a font such as Noto Color Emoji.
+// The golang.org/x/image sfnt package parses these fonts but exposes no glyph images for them.
+type colorBitmapFont struct {
+	strikes []bitmapStrike
+	cbdt    []byte
+}
+
+// bitmapStrike is one CBLC size record: the glyph images drawn for a single ppem.
+type bitmapStrike struct {
+	ppem       int
+	subtables  []indexSubtable
+	firstGlyph sfnt.GlyphIndex
+	lastGlyph  sfnt.GlyphIndex
+}
+
+// indexSubtable maps a contiguous glyph range to glyph data in the CBDT table.
+type indexSubtable struct {
+	first, last sfnt.GlyphIndex
+	indexFormat uint16
+	imageFormat uint16
+	imageOffset uint32
+	// data is the CBLC bytes following the index subtable header.
+	data []byte
+}
+
+// bitmapGlyph is a decoded color glyph with its metrics in strike pixels.
+type bitmapGlyph struct {
+	img      image.Image
+	bearingX int
+	bearingY int
+	advance  int
+}
+
+var errNoBitmapGlyph = errors.New("no color bitmap for glyph")
+
+// parseColorBitmaps extracts the CBLC/CBDT tables from raw font data.
+// It returns nil without error when the font has no color bitmaps and an error for malformed tables.
+func parseColorBitmaps(data []byte) (*colorBitmapFont, error) {
+	tables, err := sfntTables(data)
+	if err != nil {
+		return nil, err
+	}
+	cblc, okLoc := tables["CBLC"]
+	cbdt, okDat := tables["CBDT"]
+	if !okLoc || !okDat {
+		return nil, nil
+	}
+
+	if len(cblc) < 8 {
+		return nil, fmt.Errorf("render: CBLC table too short")
+	}
+	numSizes := int(binary.BigEndian.Uint32(cblc[4:8]))
+	const sizeRecordLen = 48
+	if numSizes <= 0 || len(cblc) < 8+numSizes*sizeRecordLen {
+		return nil, fmt.Errorf("render: CBLC table has invalid size records")
+	}
+
+	f := &colorBitmapFont{cbdt: cbdt}
+	for i := 0; i < numSizes; i++ {
+		rec := cblc[8+i*sizeRecordLen:]
+		arrayOffset := int(binary.BigEndian.Uint32(rec[0:4]))
+		numSubtables := int(binary.BigEndian.Uint32(rec[8:12]))
+		strike := bitmapStrike{
+			firstGlyph: sfnt.GlyphIndex(binary.BigEndian.Uint16(rec[40:42])),
+			lastGlyph:  sfnt.GlyphIndex(binary.BigEndian.Uint16(rec[42:44])),
+			ppem:       int(rec[45]),
+		}
+		if strike.ppem == 0 || arrayOffset+numSubtables*8 > len(cblc) {
+			return nil, fmt.Errorf("render: CBLC size record %d is invalid", i)
+		}
+		for j := 0; j < numSubtables; j++ {
+			entry := cblc[arrayOffset+j*8:]
+			sub := indexSubtable{
+				first: sfnt.GlyphIndex(binary.BigEndian.Uint16(entry[0:2])),
+				last:  sfnt.GlyphIndex(binary.BigEndian.Uint16(entry[2:4])),
+			}
+			headerOffset := arrayOffset + int(binary.BigEndian.Uint32(entry[4:8]))
+			if sub.last < sub.first || headerOffset+8 > len(cblc) {
+				return nil, fmt.Errorf("render: CBLC index subtable %d/%d is invalid", i, j)
+			}
+			header := cblc[headerOffset:]
+			sub.indexFormat = binary.BigEndian.Uint16(header[0:2])
+			sub.imageFormat = binary.BigEndian.Uint16(header[2:4])
+			sub.imageOffset = binary.BigEndian.Uint32(header[4:8])
+			sub.data = header[8:]
+			strike.subtables = append(strike.subtables, sub)
+		}
+		f.strikes = append(f.strikes, strike)
+	}
+	return f, nil
+}
+
+// sfntTables returns the table data of a single-font TrueType/OpenType file keyed by tag.
+func sfntTables(data []byte) (map[string][]byte, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("render: font data too short")
+	}
+	numTables := int(binary.BigEndian.Uint16(data[4:6]))
+	if len(data) < 12+numTables*16 {
+		return nil, fmt.Errorf("render: font table directory truncated")
+	}
+	tables := make(map[string][]byte, numTables)
+	for i := 0; i < numTables; i++ {
+		rec := data[12+i*16:]
+		offset := uint64(binary.BigEndian.Uint32(rec[8:12]))
+		length := uint64(binary.BigEndian.Uint32(rec[12:16]))
+		if offset+length > uint64(len(data)) {
+			return nil, fmt.Errorf("render: font table %q out of bounds", rec[0:4])
+		}
+		tables[string(rec[0:4])] = data[offset : offset+length]
+	}
+	return tables, nil
+}
+
+// strikeFor returns the strike best suited to render at size pixels: the smallest one at least that large,
+// or the largest available strike when all are smaller.
+func (f *colorBitmapFont) strikeFor(size float64) *bitmapStrike {
+	var best *bitmapStrike
+	for i := range f.strikes {
+		s := &f.strikes[i]
+		switch {
+		case best == nil:
+			best = s
+		case float64(best.ppem) < size && s.ppem > best.ppem:
+			best = s
+		case float64(s.ppem) >= size && s.ppem < best.ppem:
+			best = s
+		}
+	}
+	return best
+}
+
+// glyph decodes the color bitmap for gid from the given strike.
+// It returns errNoBitmapGlyph when the strike has no image for gid and an error for unsupported or corrupt data.
+func (f *colorBitmapFont) glyph(strike *bitmapStrike, gid sfnt.GlyphIndex) (bitmapGlyph, error) {
+	if gid < strike.firstGlyph || gid > strike.lastGlyph {
+		return bitmapGlyph{}, errNoBitmapGlyph
+	}
+	for _, sub := range strike.subtables {
+		if gid < sub.first || gid > sub.last {
+			continue
+		}
+		start, end, metrics, err := sub.locate(gid)
+		if err != nil {
+			return bitmapGlyph{}, err
+		}
+		if start == end {
+			return bitmapGlyph{}, errNoBitmapGlyph
+		}
+		if uint64(end) > uint64(len(f.cbdt)) || start > end {
+			return bitmapGlyph{}, fmt.Errorf("render: CBDT glyph %d out of bounds", gid)
+		}
+		return decodeBitmapGlyph(f.cbdt[start:end], sub.imageFormat, metrics)
+	}
+	return bitmapGlyph{}, errNoBitmapGlyph
+}
+
+// locate returns the CBDT byte range of gid and, for index formats 2 and 5, the shared big glyph metrics.
+func (sub indexSubtable) locate(gid sfnt.GlyphIndex) (start, end uint32, metrics []byte, err error) {
+	i := int(gid - sub.first)
+	d := sub.data
+	switch sub.indexFormat {
+	case 1:
+		if len(d) < (i+2)*4 {
+			break
+		}
+		return sub.imageOffset + binary.BigEndian.Uint32(d[i*4:]), sub.imageOffset + binary.BigEndian.Uint32(d[(i+1)*4:]), nil, nil
+	case 3:
+		if len(d) < (i+2)*2 {
+			break
+		}
+		return sub.imageOffset + uint32(binary.BigEndian.Uint16(d[i*2:])), sub.imageOffset + uint32(binary.BigEndian.Uint16(d[(i+1)*2:])), nil, nil
+	case 2:
+		if len(d) < 12 {
+			break
+		}
+		size := binary.BigEndian.Uint32(d[0:4])
+		start = sub.imageOffset + uint32(i)*size
+		return start, start + size, d[4:12], nil
+	case 4:
+		if len(d) < 4 {
+			break
+		}
+		n := int(binary.BigEndian.Uint32(d[0:4]))
+		if len(d) < 4+(n+1)*4 {
+			break
+		}
+		for k := 0; k < n; k++ {
+			pair := d[4+k*4:]
+			if sfnt.GlyphIndex(binary.BigEndian.Uint16(pair[0:2])) == gid {
+				next := d[4+(k+1)*4:]
+				return sub.imageOffset + uint32(binary.BigEndian.Uint16(pair[2:4])), sub.imageOffset + uint32(binary.BigEndian.Uint16(next[2:4])), nil, nil
+			}
+		}
+		return 0, 0, nil, nil
+	case 5:
+		if len(d) < 16 {
+			break
+		}
+		size := binary.BigEndian.Uint32(d[0:4])
+		n := int(binary.BigEndian.Uint32(d[12:16]))
+		if len(d) < 16+n*2 {
+			break
+		}
+		for k := 0; k < n; k++ {
+			if sfnt.GlyphIndex(binary.BigEndian.Uint16(d[16+k*2:])) == gid {
+				start = sub.imageOffset + uint32(k)*size
+				return start, start + size, d[4:12], nil
+			}
+		}
+		return 0, 0, nil, nil
+	default:
+		return 0, 0, nil, fmt.Errorf("render: unsupported CBLC index format %d", sub.indexFormat)
+	}
+	return 0, 0, nil, fmt.Errorf("render: CBLC index subtable (format %d) truncated", sub.indexFormat)
+}
+
+// decodeBitmapGlyph decodes a CBDT PNG glyph record (image formats 17, 18, and 19).
+func decodeBitmapGlyph(rec []byte, imageFormat uint16, bigMetrics []byte) (bitmapGlyph, error) {
+	var g bitmapGlyph
+	switch imageFormat {
+	case 17:
+		if len(rec) < 9 {
+			return g, fmt.Errorf("render: CBDT format 17 record truncated")
+		}
+		g.bearingX, g.bearingY, g.advance = int(int8(rec[2])), int(int8(rec[3])), int(rec[4])
+		rec = rec[5:]
+	case 18:
+		if len(rec) < 12 {
+			return g, fmt.Errorf("render: CBDT format 18 record truncated")
+		}
+		g.bearingX, g.bearingY, g.advance = int(int8(rec[2])), int(int8(rec[3])), int(rec[4])
+		rec = rec[8:]
+	case 19:
+		if len(rec) < 4 || len(bigMetrics) < 5 {
+			return g, fmt.Errorf("render: CBDT format 19 record truncated")
+		}
+		g.bearingX, g.bearingY, g.advance = int(int8(bigMetrics[2])), int(int8(bigMetrics[3])), int(bigMetrics[4])
+	default:
+		return g, fmt.Errorf("render: unsupported CBDT image format %d", imageFormat)
+	}
+
+	n := binary.BigEndian.Uint32(rec[0:4])
+	if uint64(n) > uint64(len(rec)-4) {
+		return g, fmt.Errorf("render: CBDT PNG data truncated")
+	}
+	img, err := png.Decode(bytes.NewReader(rec[4 : 4+n]))
+	if err != nil {
+		return g, fmt.Errorf("render: decode color glyph PNG: %w", err)
+	}
+	g.img = img
+	return g, nil
+}
+
+// dropPresentationSelectors removes the text/emoji variation selectors U+FE0E and U+FE0F,
+// which would otherwise render as missing-glyph boxes after an emoji.
+func dropPresentationSelectors(runes []rune) []rune {
+	out := runes[:0:0]
+	for _, r := range runes {
+		if r != 0xFE0E && r != 0xFE0F {
+			out = append(out, r)
+		}
+	}
+	return out
+}
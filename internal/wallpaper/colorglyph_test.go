@@ -0,0 +1,177 @@
+package wallpaper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"sort"
+	"testing"
+
+	"golang.org/x/image/font"
+)
+
+// rocket is the rune mapped to the single color glyph in the synthetic test font.
+const rocket = '\U0001F680'
+
+// buildColorFont assembles a minimal CBDT/CBLC font with one solid-red 16x16 PNG glyph for rocket at 20 ppem.
+func buildColorFont(t *testing.T) []byte {
+	t.Helper()
+	be := binary.BigEndian
+
+	glyph := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for i := 0; i < len(glyph.Pix); i += 4 {
+		copy(glyph.Pix[i:], []byte{255, 0, 0, 255})
+	}
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, glyph); err != nil {
+		t.Fatalf("encode glyph: %v", err)
+	}
+
+	head := make([]byte, 54)
+	be.PutUint32(head[0:], 0x00010000)
+	be.PutUint32(head[12:], 0x5F0F3CF5)
+	be.PutUint16(head[18:], 1000)
+
+	maxp := make([]byte, 32)
+	be.PutUint32(maxp[0:], 0x00010000)
+	be.PutUint16(maxp[4:], 2)
+
+	hhea := make([]byte, 36)
+	be.PutUint32(hhea[0:], 0x00010000)
+	be.PutUint16(hhea[4:], 800)
+	be.PutUint16(hhea[6:], uint16(0x10000-200))
+	be.PutUint16(hhea[18:], 1)
+	be.PutUint16(hhea[34:], 2)
+
+	hmtx := []byte{0x03, 0xE8, 0, 0, 0x03, 0xE8, 0, 0}
+
+	post := make([]byte, 32)
+	be.PutUint32(post[0:], 0x00030000)
+
+	cmap := make([]byte, 12+28)
+	be.PutUint16(cmap[2:], 1)
+	be.PutUint16(cmap[4:], 3)
+	be.PutUint16(cmap[6:], 10)
+	be.PutUint32(cmap[8:], 12)
+	sub := cmap[12:]
+	be.PutUint16(sub[0:], 12)
+	be.PutUint32(sub[4:], 28)
+	be.PutUint32(sub[12:], 1)
+	be.PutUint32(sub[16:], rocket)
+	be.PutUint32(sub[20:], rocket)
+	be.PutUint32(sub[24:], 1)
+
+	record := []byte{16, 16, 0, 16, 20}
+	record = be.AppendUint32(record, uint32(pngData.Len()))
+	record = append(record, pngData.Bytes()...)
+	cbdt := append([]byte{0, 3, 0, 0}, record...)
+
+	cblc := make([]byte, 8+48+8+8+8)
+	be.PutUint16(cblc[0:], 3)
+	be.PutUint32(cblc[4:], 1)
+	size := cblc[8:]
+	be.PutUint32(size[0:], 56)
+	be.PutUint32(size[8:], 1)
+	be.PutUint16(size[40:], 1)
+	be.PutUint16(size[42:], 1)
+	size[44], size[45], size[46] = 20, 20, 32
+	array := cblc[56:]
+	be.PutUint16(array[0:], 1)
+	be.PutUint16(array[2:], 1)
+	be.PutUint32(array[4:], 8)
+	index := cblc[64:]
+	be.PutUint16(index[0:], 1)
+	be.PutUint16(index[2:], 17)
+	be.PutUint32(index[4:], 4)
+	be.PutUint32(index[12:], uint32(len(record)))
+
+	tables := map[string][]byte{
+		"CBDT": cbdt, "CBLC": cblc, "cmap": cmap, "head": head,
+		"hhea": hhea, "hmtx": hmtx, "maxp": maxp, "post": post,
+	}
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	out := make([]byte, 12+16*len(tags))
+	be.PutUint32(out[0:], 0x00010000)
+	be.PutUint16(out[4:], uint16(len(tags)))
+	for i, tag := range tags {
+		data := tables[tag]
+		rec := out[12+16*i:]
+		copy(rec[0:4], tag)
+		be.PutUint32(rec[8:], uint32(len(out)))
+		be.PutUint32(rec[12:], uint32(len(data)))
+		out = append(out, data...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	return out
+}
+
+// TestParseColorBitmaps_DecodesPNGGlyph expects the CBLC/CBDT tables to yield the embedded PNG and its metrics.
+// The test fails if a plain outline font reports color bitmaps or the glyph cannot be located and decoded.
+func TestParseColorBitmaps_DecodesPNGGlyph(t *testing.T) {
+	if f, err := parseColorBitmaps(regularFontData); err != nil || f != nil {
+		t.Fatalf("expected no color bitmaps for DejaVu, got %v, %v", f, err)
+	}
+
+	f, err := parseColorBitmaps(buildColorFont(t))
+	if err != nil || f == nil {
+		t.Fatalf("parseColorBitmaps: %v, %v", f, err)
+	}
+	strike := f.strikeFor(48)
+	if strike.ppem != 20 {
+		t.Fatalf("expected the only strike (20 ppem), got %d", strike.ppem)
+	}
+	g, err := f.glyph(strike, 1)
+	if err != nil {
+		t.Fatalf("glyph error: %v", err)
+	}
+	if g.img.Bounds().Dx() != 16 || g.bearingY != 16 || g.advance != 20 {
+		t.Fatalf("unexpected glyph: bounds %v bearingY %d advance %d", g.img.Bounds(), g.bearingY, g.advance)
+	}
+	if _, err := f.glyph(strike, 0); err != errNoBitmapGlyph {
+		t.Fatalf("expected errNoBitmapGlyph for .notdef, got %v", err)
+	}
+}
+
+// TestDrawText_ColorEmojiKeepsGlyphColors expects an emoji from a color font to be composited in its own colors.
+// The test fails if the rocket is drawn in the text color, as a missing-glyph box, or not at all.
+func TestDrawText_ColorEmojiKeepsGlyphColors(t *testing.T) {
+	face, err := loadFaceChain(40, font.HintingNone, regularFontData, buildColorFont(t))
+	if err != nil {
+		t.Fatalf("loadFaceChain error: %v", err)
+	}
+	defer face.Close()
+
+	dst := image.NewRGBA(image.Rect(0, 0, 200, 80))
+	text := prepareText("A " + string(rocket) + "\uFE0F")
+	if err := drawText(dst, face, text, 10, 60, color.NRGBA{R: 255, G: 255, B: 255, A: 255}); err != nil {
+		t.Fatalf("drawText error: %v", err)
+	}
+
+	var red, white int
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 200; x++ {
+			switch c := dst.RGBAAt(x, y); {
+			case c.R > 200 && c.G < 50 && c.B < 50:
+				red++
+			case c.R > 200 && c.G > 200 && c.B > 200:
+				white++
+			}
+		}
+	}
+	// 16px at 20 ppem scaled to 40px gives a 32x32 bitmap.
+	if red < 30*30 {
+		t.Fatalf("expected a red emoji of about 32x32 pixels, got %d red pixels", red)
+	}
+	if white == 0 {
+		t.Fatalf("expected the outline glyph 'A' in the text color")
+	}
+}
@@ -0,0 +1,167 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	stddraw "image/draw"
+	"math"
+)
+
+// CornerShape selects the curve of rounded corners.
+type CornerShape string
+
+const (
+	// CornerCircular draws quarter circles, whose curvature jumps where they meet the straight edges.
+	CornerCircular CornerShape = "circular"
+	// CornerSquircle draws superellipse quarters, whose curvature grows continuously from the straight edges, as the
+	// continuous corners of current design systems do.
+	CornerSquircle CornerShape = "squircle"
+)
+
+// CornerShapes returns all selectable corner shapes in a stable order for help output and validation.
+func CornerShapes() []CornerShape {
+	return []CornerShape{CornerCircular, CornerSquircle}
+}
+
+// Bounds and default of Corners.Exponent.
+const (
+	MinCornerExponent     = 2
+	MaxCornerExponent     = 10
+	DefaultCornerExponent = 4
+)
+
+// Corners shapes the corners of a rectangle. The zero value keeps circular corners of the rectangle's radius.
+type Corners struct {
+	// Shape is CornerCircular or CornerSquircle; empty selects CornerCircular.
+	Shape CornerShape `json:"shape,omitempty"`
+	// Exponent is the superellipse exponent of squircle corners, in [MinCornerExponent, MaxCornerExponent]; zero
+	// selects DefaultCornerExponent. Larger exponents are squarer, and 2 is circular.
+	Exponent float64 `json:"exponent,omitempty"`
+	// Radii sets one radius for all corners, or four for the top-left, top-right, bottom-right, and bottom-left corners;
+	// empty keeps the rectangle's radius. A zero radius leaves its corner square.
+	Radii []float64 `json:"radii,omitempty"`
+}
+
+// validate checks the shape, exponent, and radii.
+func (c *Corners) validate() error {
+	if c == nil {
+		return nil
+	}
+	switch c.Shape {
+	case "", CornerCircular:
+		if c.Exponent != 0 {
+			return fmt.Errorf("corners: exponent needs shape %q", CornerSquircle)
+		}
+	case CornerSquircle:
+		if c.Exponent != 0 && (c.Exponent < MinCornerExponent || c.Exponent > MaxCornerExponent) {
+			return fmt.Errorf("corners: exponent %v out of range [%d, %d]", c.Exponent, MinCornerExponent, MaxCornerExponent)
+		}
+	default:
+		return fmt.Errorf("corners: unknown shape %q", c.Shape)
+	}
+	if n := len(c.Radii); n != 0 && n != 1 && n != 4 {
+		return fmt.Errorf("corners: want 1 or 4 radii, got %d", n)
+	}
+	for _, r := range c.Radii {
+		if r < 0 {
+			return fmt.Errorf("corners: radius %v must not be negative", r)
+		}
+	}
+	return nil
+}
+
+// isZero reports whether c keeps the default corners.
+func (c *Corners) isZero() bool {
+	return c == nil || (c.Shape == "" && c.Exponent == 0 && len(c.Radii) == 0)
+}
+
+// scaled returns a copy of c with its radii multiplied by scale, or nil if c keeps the default corners.
+func (c *Corners) scaled(scale float64) *Corners {
+	if c.isZero() {
+		return nil
+	}
+	s := *c
+	s.Radii = make([]float64, len(c.Radii))
+	for i, r := range c.Radii {
+		s.Radii[i] = r * scale
+	}
+	return &s
+}
+
+// radii returns the radii of the top-left, top-right, bottom-right, and bottom-left corners, with def for all of them
+// unless c sets its own.
+func (c *Corners) radii(def float64) [4]float64 {
+	switch {
+	case c == nil || len(c.Radii) == 0:
+		return [4]float64{def, def, def, def}
+	case len(c.Radii) == 1:
+		return [4]float64{c.Radii[0], c.Radii[0], c.Radii[0], c.Radii[0]}
+	default:
+		return [4]float64(c.Radii)
+	}
+}
+
+// exponent returns the superellipse exponent of the corners, 2 for circular ones.
+func (c *Corners) exponent() float64 {
+	switch {
+	case c == nil || c.Shape != CornerSquircle:
+		return 2
+	case c.Exponent == 0:
+		return DefaultCornerExponent
+	default:
+		return c.Exponent
+	}
+}
+
+// drawShapedRect draws a rectangle with corners of the given radii, in the order of Corners.Radii, and superellipse
+// exponent. Radii are clamped to half the smaller side, and a rectangle without radii is drawn plain.
+func drawShapedRect(dst *image.RGBA, rect image.Rectangle, radii [4]int, exponent float64, col color.NRGBA) {
+	limit := minInt(rect.Dx()/2, rect.Dy()/2)
+	for i := range radii {
+		radii[i] = maxInt(0, minInt(radii[i], limit))
+	}
+	if radii == ([4]int{}) {
+		stddraw.Draw(dst, rect, image.NewUniform(col), image.Point{}, stddraw.Over)
+		return
+	}
+	mask := image.NewAlpha(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	if exponent == 2 && radii[0] == radii[1] && radii[1] == radii[2] && radii[2] == radii[3] {
+		fillRoundedMask(mask, radii[0])
+	} else {
+		fillCornerMask(mask, radii, exponent)
+	}
+	stddraw.DrawMask(dst, rect, image.NewUniform(col), image.Point{}, mask, image.Point{}, stddraw.Over)
+}
+
+// fillCornerMask fills mask, then clears the pixels outside each corner's superellipse quarter. A pixel at offsets dx
+// and dy from the corner's center, counted from 0 as fillRoundedMask does, is inside if (dx/r)^n + (dy/r)^n < 1, so
+// exponent 2 gives the same circles.
+func fillCornerMask(mask *image.Alpha, radii [4]int, exponent float64) {
+	for i := range mask.Pix {
+		mask.Pix[i] = 255
+	}
+	b := mask.Bounds()
+	w, h := b.Dx(), b.Dy()
+	for corner, r := range radii {
+		for dy := 0; dy < r; dy++ {
+			for dx := 0; dx < r; dx++ {
+				inside := dx*dx+dy*dy < r*r
+				if exponent != 2 {
+					inside = math.Pow(float64(dx)/float64(r), exponent)+math.Pow(float64(dy)/float64(r), exponent) < 1
+				}
+				if inside {
+					continue
+				}
+				x, y := r-1-dx, r-1-dy
+				if corner == 1 || corner == 2 {
+					x = w - r + dx
+				}
+				if corner == 2 || corner == 3 {
+					y = h - r + dy
+				}
+				mask.SetAlpha(b.Min.X+x, b.Min.Y+y, color.Alpha{})
+			}
+		}
+	}
+}
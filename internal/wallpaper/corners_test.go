@@ -0,0 +1,104 @@
+package wallpaper
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// coverage returns the number of pixels mask covers.
+func coverage(mask *image.Alpha) int {
+	n := 0
+	for _, a := range mask.Pix {
+		if a != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// TestFillCornerMask_SquircleAndPerCornerRadii verifies the corner masks. The test fails if exponent 2 differs from
+// the circular mask, a squircle does not lie between the circle and the square, or a corner with a zero radius is
+// rounded.
+func TestFillCornerMask_SquircleAndPerCornerRadii(t *testing.T) {
+	rect := image.Rect(0, 0, 120, 80)
+	circle, general := image.NewAlpha(rect), image.NewAlpha(rect)
+	fillRoundedMask(circle, 30)
+	fillCornerMask(general, [4]int{30, 30, 30, 30}, 2)
+	if !bytes.Equal(circle.Pix, general.Pix) {
+		t.Fatal("expected exponent 2 to match the circular mask")
+	}
+
+	squircle := image.NewAlpha(rect)
+	fillCornerMask(squircle, [4]int{30, 30, 30, 30}, DefaultCornerExponent)
+	if c, s := coverage(circle), coverage(squircle); s <= c || s >= rect.Dx()*rect.Dy() {
+		t.Fatalf("squircle covers %d pixels, want between the circle's %d and the square's %d", s, c, rect.Dx()*rect.Dy())
+	}
+	// On the diagonal, the squircle reaches further into the corner than the circle, but not into its tip.
+	if circle.AlphaAt(5, 5).A != 0 || squircle.AlphaAt(5, 5).A == 0 || squircle.AlphaAt(0, 0).A != 0 {
+		t.Fatalf("at (5,5): circle %v, squircle %v; squircle tip %v", circle.AlphaAt(5, 5), squircle.AlphaAt(5, 5), squircle.AlphaAt(0, 0))
+	}
+
+	tab := image.NewAlpha(rect)
+	fillCornerMask(tab, [4]int{20, 20, 0, 0}, 2)
+	for _, p := range []image.Point{{0, 79}, {119, 79}} {
+		if tab.AlphaAt(p.X, p.Y).A == 0 {
+			t.Errorf("expected the square bottom corner %v to be covered", p)
+		}
+	}
+	for _, p := range []image.Point{{0, 0}, {119, 0}} {
+		if tab.AlphaAt(p.X, p.Y).A != 0 {
+			t.Errorf("expected the rounded top corner %v to be cleared", p)
+		}
+	}
+}
+
+// TestParseTheme_PanelCorners verifies the corner options of the theme panel and scene rect layers. The test fails if
+// valid corners are rejected, invalid ones are accepted, or a squircle panel is drawn like the default box.
+func TestParseTheme_PanelCorners(t *testing.T) {
+	for _, data := range []string{
+		`{"panel": {"corners": {"shape": "squircle"}}}`,
+		`{"panel": {"color": "#000000", "corners": {"shape": "squircle", "exponent": 5, "radii": [48]}}}`,
+		`{"panel": {"corners": {"radii": [48, 48, 0, 0]}}}`,
+	} {
+		if _, err := ParseTheme([]byte(data)); err != nil {
+			t.Errorf("%s: %v", data, err)
+		}
+	}
+	for data, want := range map[string]string{
+		`{"panel": {"corners": {"shape": "blob"}}}`:                      "unknown shape",
+		`{"panel": {"corners": {"exponent": 4}}}`:                        "exponent needs shape",
+		`{"panel": {"corners": {"shape": "squircle", "exponent": 1.5}}}`: "out of range",
+		`{"panel": {"corners": {"radii": [1, 2]}}}`:                      "want 1 or 4 radii",
+		`{"panel": {"corners": {"radii": [-1]}}}`:                        "must not be negative",
+	} {
+		if _, err := ParseTheme([]byte(data)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", data, want, err)
+		}
+	}
+	if _, err := ParseScene([]byte(`{"width": 100, "height": 100, "layers": [{"type": "rect", "w": 10, "h": 10, "corners": {"shape": "oval"}}]}`), ""); err == nil {
+		t.Error("expected a rect layer with an unknown corner shape to be rejected")
+	}
+
+	bg := solidBG(32, 32, color.RGBA{0, 0, 0, 255})
+	plain, layout, err := RenderWithLayout(bg, "t", "b", Options{})
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	opts := Options{}
+	opts.Theme.Panel.Corners = &Corners{Shape: CornerSquircle}
+	shaped, err := Render(bg, "t", "b", opts)
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	if bytes.Equal(plain.Pix, shaped.Pix) {
+		t.Fatal("expected squircle corners to change the box")
+	}
+	// Away from the corners, the box is unchanged.
+	mid := image.Pt((layout.BoxX0+layout.BoxX1)/2, layout.BoxY0+1)
+	if plain.At(mid.X, mid.Y) != shaped.At(mid.X, mid.Y) {
+		t.Fatalf("box edge at %v = %v, want %v", mid, shaped.At(mid.X, mid.Y), plain.At(mid.X, mid.Y))
+	}
+}
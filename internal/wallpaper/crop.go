@@ -0,0 +1,199 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Crop selects which part of a background scaled to cover the canvas is kept.
+type Crop string
+
+const (
+	// CropCenter trims equally on opposite sides; this matches the historical output.
+	CropCenter Crop = "center"
+	// CropSmart keeps the window with the most detail, measured as edge energy, and the least detail behind the panel
+	// and other rect layers, so the text sits on a calm part of the photo.
+	CropSmart Crop = "smart"
+	// CropThirds is CropSmart that also favors windows whose detail centers on a rule-of-thirds point.
+	CropThirds Crop = "thirds"
+)
+
+// Crops returns all crop modes in a stable order for help output and validation.
+func Crops() []Crop {
+	return []Crop{CropCenter, CropSmart, CropThirds}
+}
+
+// ParseCrop converts a user-supplied crop name into a Crop; empty selects CropCenter.
+func ParseCrop(name string) (Crop, error) {
+	if name == "" {
+		return CropCenter, nil
+	}
+	for _, c := range Crops() {
+		if string(c) == name {
+			return c, nil
+		}
+	}
+	return CropCenter, fmt.Errorf("render: unknown crop %q", name)
+}
+
+const (
+	// saliencyCells is the longer side of the grid the saliency of a background is measured on; finer grids cost
+	// time without moving the crop by more than a few pixels.
+	saliencyCells = 192
+	// quietWeight is how much detail behind the panel counts against a window, on top of not counting for it.
+	quietWeight = 2.0
+	// thirdsWeight scales the share of a window's detail that CropThirds adds for centering it on a thirds point.
+	thirdsWeight = 0.5
+)
+
+// FocalPoint is a point of a background in fractions of its width and height, from 0,0 at the top left to 1,1 at the
+// bottom right.
+type FocalPoint struct {
+	X, Y float64
+}
+
+// ParseFocalPoint parses "x,y", e.g. "0.3,0.6", with each coordinate in [0, 1].
+func ParseFocalPoint(s string) (*FocalPoint, error) {
+	xs, ys, ok := strings.Cut(s, ",")
+	x, errX := strconv.ParseFloat(strings.TrimSpace(xs), 64)
+	y, errY := strconv.ParseFloat(strings.TrimSpace(ys), 64)
+	if !ok || errX != nil || errY != nil || x < 0 || x > 1 || y < 0 || y > 1 {
+		return nil, fmt.Errorf("render: invalid focal point %q, want x,y with each in [0, 1]", s)
+	}
+	return &FocalPoint{X: x, Y: y}, nil
+}
+
+// String returns p as ParseFocalPoint reads it.
+func (p FocalPoint) String() string {
+	return strconv.FormatFloat(p.X, 'g', -1, 64) + "," + strconv.FormatFloat(p.Y, 'g', -1, 64)
+}
+
+// cropRule selects the window resizeAndCrop keeps, or how it extends a background instead; the zero value keeps the
+// center.
+type cropRule struct {
+	fit   Fit
+	mode  Crop
+	focal *FocalPoint
+	// quiet lists canvas rectangles, e.g. the panel, that smart crops keep free of detail.
+	quiet []image.Rectangle
+	// sharpen is the unsharp mask amount applied after downscaling.
+	sharpen float64
+}
+
+// saliencyMap is the edge energy of an image on a coarse grid, with summed-area tables of it and of its products with
+// the cell coordinates, so the detail of any window and its centroid cost constant time.
+type saliencyMap struct {
+	w, h            int
+	sum, sumX, sumY []float64
+}
+
+// newSaliencyMap measures the saliency of img as the luminance gradient of each cell of a downscaled copy.
+func newSaliencyMap(img *image.RGBA) *saliencyMap {
+	b := img.Bounds()
+	scale := math.Min(1, saliencyCells/float64(max(b.Dx(), b.Dy())))
+	w, h := max(1, int(math.Round(float64(b.Dx())*scale))), max(1, int(math.Round(float64(b.Dy())*scale)))
+	small := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.BiLinear.Scale(small, small.Rect, img, b, draw.Src, nil)
+	luma := make([]float64, w*h)
+	for i := range luma {
+		p := small.Pix[4*i:]
+		luma[i] = 0.2126*float64(p[0]) + 0.7152*float64(p[1]) + 0.0722*float64(p[2])
+	}
+	at := func(x, y int) float64 {
+		return luma[min(h-1, max(0, y))*w+min(w-1, max(0, x))]
+	}
+	m := &saliencyMap{w: w, h: h}
+	stride := w + 1
+	m.sum, m.sumX, m.sumY = make([]float64, stride*(h+1)), make([]float64, stride*(h+1)), make([]float64, stride*(h+1))
+	for y := range h {
+		for x := range w {
+			s := math.Abs(at(x+1, y)-at(x-1, y)) + math.Abs(at(x, y+1)-at(x, y-1))
+			i := (y+1)*stride + x + 1
+			for _, t := range []struct {
+				table []float64
+				v     float64
+			}{{m.sum, s}, {m.sumX, s * (float64(x) + 0.5)}, {m.sumY, s * (float64(y) + 0.5)}} {
+				t.table[i] = t.v + t.table[i-1] + t.table[i-stride] - t.table[i-stride-1]
+			}
+		}
+	}
+	return m
+}
+
+// total returns the sum of table over the cells of r within the grid.
+func (m *saliencyMap) total(table []float64, r image.Rectangle) float64 {
+	r = r.Intersect(image.Rect(0, 0, m.w, m.h))
+	if r.Empty() {
+		return 0
+	}
+	stride := m.w + 1
+	return table[r.Max.Y*stride+r.Max.X] - table[r.Min.Y*stride+r.Max.X] - table[r.Max.Y*stride+r.Min.X] + table[r.Min.Y*stride+r.Min.X]
+}
+
+// cropOffset returns the top-left corner of the width × height window of scaled that rule keeps: the one centering its
+// focal point as far as the slack allows, or for smart crops the best scoring one. Windows that score the same, e.g. on
+// a plain background, resolve to the one closest to the center.
+func cropOffset(scaled *image.RGBA, width, height int, rule cropRule) image.Point {
+	b := scaled.Bounds()
+	slack := image.Pt(b.Dx()-width, b.Dy()-height)
+	center := slack.Div(2)
+	if f := rule.focal; f != nil {
+		x := int(math.Round(f.X*float64(b.Dx()) - float64(width)/2))
+		y := int(math.Round(f.Y*float64(b.Dy()) - float64(height)/2))
+		return image.Pt(min(max(x, 0), slack.X), min(max(y, 0), slack.Y))
+	}
+	crop := rule.mode
+	if crop != CropSmart && crop != CropThirds || slack == (image.Point{}) {
+		return center
+	}
+	m := newSaliencyMap(scaled)
+	cells := float64(m.w) / float64(b.Dx())
+	grid := func(r image.Rectangle) image.Rectangle {
+		round := func(v int) int { return int(math.Round(float64(v) * cells)) }
+		return image.Rect(round(r.Min.X), round(r.Min.Y), round(r.Max.X), round(r.Max.Y))
+	}
+	// Only one axis has more than rounding slack, so the candidates step along it one grid cell at a time.
+	steps := max(1, grid(image.Rectangle{Max: slack}).Size().X, grid(image.Rectangle{Max: slack}).Size().Y)
+	best, bestScore := center, math.Inf(-1)
+	for i := range steps + 1 {
+		offset := image.Pt(slack.X*i/steps, slack.Y*i/steps)
+		window := grid(image.Rect(0, 0, width, height).Add(offset))
+		detail := m.total(m.sum, window)
+		score := detail
+		for _, q := range rule.quiet {
+			score -= (1 + quietWeight) * m.total(m.sum, grid(q.Add(offset)))
+		}
+		if crop == CropThirds && detail > 0 {
+			cx := (m.total(m.sumX, window)/detail - float64(window.Min.X)) / float64(window.Dx())
+			cy := (m.total(m.sumY, window)/detail - float64(window.Min.Y)) / float64(window.Dy())
+			score += thirdsWeight * detail * thirdsCloseness(cx, cy)
+		}
+		if score > bestScore || score == bestScore && distance(offset, center) < distance(best, center) {
+			best, bestScore = offset, score
+		}
+	}
+	return best
+}
+
+// thirdsCloseness returns 1 for a point, in fractions of the window, on a rule-of-thirds intersection, falling to 0 at
+// a third of the window away from the nearest one.
+func thirdsCloseness(x, y float64) float64 {
+	d := math.Inf(1)
+	for _, px := range []float64{1.0 / 3, 2.0 / 3} {
+		for _, py := range []float64{1.0 / 3, 2.0 / 3} {
+			d = math.Min(d, math.Hypot(x-px, y-py))
+		}
+	}
+	return math.Max(0, 1-3*d)
+}
+
+// distance returns the Manhattan distance between a and b.
+func distance(a, b image.Point) int {
+	d := a.Sub(b)
+	return max(d.X, -d.X) + max(d.Y, -d.Y)
+}
@@ -0,0 +1,135 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// stripBackground returns a plain 400x100 gray background with a black-and-white checkered strip over the columns
+// [x0, x1), the only detail in it.
+func stripBackground(x0, x1 int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 100))
+	for y := range 100 {
+		for x := range 400 {
+			c := color.RGBA{128, 128, 128, 255}
+			if x >= x0 && x < x1 {
+				c = color.RGBA{0, 0, 0, 255}
+				if (x/4+y/4)%2 == 0 {
+					c = color.RGBA{255, 255, 255, 255}
+				}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// detailCenter returns the mean x of the columns of img that show the strip of stripBackground, or -1 if none does.
+func detailCenter(img *image.RGBA) float64 {
+	sum, n := 0, 0
+	for x := range img.Bounds().Dx() {
+		if r := img.RGBAAt(x, img.Bounds().Dy()/2).R; r < 64 || r > 192 {
+			sum += x
+			n++
+		}
+	}
+	if n == 0 {
+		return -1
+	}
+	return float64(sum) / float64(n)
+}
+
+// TestResizeAndCrop_SmartCrop expects smart crops to keep the detailed part of a wide background, to move it out from
+// behind a quiet rectangle, and with the rule of thirds to center it on a third of the canvas; center crops and plain
+// backgrounds keep the center. A 400x100 background covers a 160x90 canvas at 360x90, leaving 200 pixels of slack.
+// The test fails if a smart crop cuts away the detail or leaves it behind the panel.
+func TestResizeAndCrop_SmartCrop(t *testing.T) {
+	const w, h = 160, 90
+	right, middle := stripBackground(340, 380), stripBackground(180, 220)
+	panel := []image.Rectangle{image.Rect(w/2, 0, w, h)}
+	for _, c := range []struct {
+		name     string
+		bg       *image.RGBA
+		crop     Crop
+		quiet    []image.Rectangle
+		min, max float64
+	}{
+		// The strip scales to columns 306..342; the center window [100, 260) does not show it.
+		{"center", right, CropCenter, nil, -1, -1},
+		{"smart keeps detail", right, CropSmart, nil, 0, w},
+		// The strip scales to columns 162..198; the center window shows it at 80, behind the panel.
+		{"center behind panel", middle, CropCenter, panel, 79, 81},
+		{"smart avoids panel", middle, CropSmart, panel, 0, w/2 - 18},
+		// Every window showing the strip scores the same without thirds, so the center wins.
+		{"smart tie", middle, CropSmart, nil, 79, 81},
+	} {
+		out, err := resizeAndCrop(c.bg, w, h, cropRule{mode: c.crop, quiet: c.quiet})
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got := detailCenter(out); got < c.min || got > c.max {
+			t.Fatalf("%s: detail centered at %.1f, want within [%.0f, %.0f]", c.name, got, c.min, c.max)
+		}
+	}
+	out, err := resizeAndCrop(middle, w, h, cropRule{mode: CropThirds})
+	if err != nil {
+		t.Fatalf("thirds: %v", err)
+	}
+	if got := detailCenter(out); math.Abs(got-w/3.0) > 4 && math.Abs(got-2*w/3.0) > 4 {
+		t.Fatalf("thirds: detail centered at %.1f, want near %.1f or %.1f", got, w/3.0, 2*w/3.0)
+	}
+
+	plain := image.NewRGBA(image.Rect(0, 0, 400, 100))
+	if got := cropOffset(plain, 400-100, 100, cropRule{mode: CropThirds, quiet: panel}); got != image.Pt(50, 0) {
+		t.Fatalf("plain background cropped at %v, want the center (50,0)", got)
+	}
+	if _, err := ParseCrop("faces"); err == nil {
+		t.Fatalf("expected error for unknown crop")
+	}
+}
+
+// TestCropOffset_FocalPoint expects a focal point to be centered in the window of every aspect ratio as far as the
+// slack allows, to stay in frame when it is near an edge, and to override smart crops.
+// The test fails if the focal point leaves the frame or the crop ignores it.
+func TestCropOffset_FocalPoint(t *testing.T) {
+	focal, err := ParseFocalPoint("0.3,0.6")
+	if err != nil {
+		t.Fatalf("ParseFocalPoint: %v", err)
+	}
+	if focal.String() != "0.3,0.6" {
+		t.Fatalf("String() = %q", focal.String())
+	}
+	// The background is scaled to cover each canvas first, so the window is width x height of a 1200x900 image.
+	scaled := image.NewRGBA(image.Rect(0, 0, 1200, 900))
+	for _, c := range []struct {
+		width, height int
+		want          image.Point
+	}{
+		// 16:9 and 21:9 windows slide vertically: the focal row 540 is centered.
+		{1200, 675, image.Pt(0, 203)},
+		{1200, 514, image.Pt(0, 283)},
+		// A portrait window slides horizontally: the focal column 360 is centered.
+		{506, 900, image.Pt(107, 0)},
+		// A narrow window would center the focal column at 300, within reach.
+		{120, 900, image.Pt(300, 0)},
+	} {
+		got := cropOffset(scaled, c.width, c.height, cropRule{mode: CropSmart, focal: focal})
+		if got != c.want {
+			t.Fatalf("%dx%d: offset %v, want %v", c.width, c.height, got, c.want)
+		}
+	}
+	// Near an edge the window stops at it and the point stays in frame.
+	if got := cropOffset(scaled, 600, 900, cropRule{focal: &FocalPoint{X: 0.05, Y: 0.5}}); got != image.Pt(0, 0) {
+		t.Fatalf("edge focal point: offset %v, want (0,0)", got)
+	}
+	if got := cropOffset(scaled, 600, 900, cropRule{focal: &FocalPoint{X: 1, Y: 0.5}}); got != image.Pt(600, 0) {
+		t.Fatalf("right edge focal point: offset %v, want (600,0)", got)
+	}
+	for _, s := range []string{"0.3", "0.3,x", "-0.1,0.5", "0.5,1.01"} {
+		if _, err := ParseFocalPoint(s); err == nil {
+			t.Fatalf("ParseFocalPoint(%q): expected error", s)
+		}
+	}
+}
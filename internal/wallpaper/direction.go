@@ -0,0 +1,70 @@
+package wallpaper
+
+import "fmt"
+
+// Direction is the base (paragraph) direction of a text line.
+type Direction string
+
+const (
+	// DirectionAuto takes the direction from the first strong character of the text.
+	DirectionAuto Direction = "auto"
+	DirectionLTR  Direction = "ltr"
+	DirectionRTL  Direction = "rtl"
+)
+
+// Directions returns all selectable directions in a stable order for help output and validation.
+func Directions() []Direction {
+	return []Direction{DirectionAuto, DirectionLTR, DirectionRTL}
+}
+
+// ParseDirection converts a user-supplied direction name into a Direction.
+// An empty name selects DirectionAuto; unknown names return an error.
+func ParseDirection(name string) (Direction, error) {
+	if name == "" {
+		return DirectionAuto, nil
+	}
+	for _, d := range Directions() {
+		if string(d) == name {
+			return d, nil
+		}
+	}
+	return DirectionAuto, fmt.Errorf("render: unknown direction %q", name)
+}
+
+// detectDirection returns the direction of the first strong character in s.
+// It returns DirectionAuto when s has no strong character (e.g. only digits and punctuation).
+func detectDirection(s string) Direction {
+	for _, c := range classify([]rune(s)) {
+		switch c {
+		case classL:
+			return DirectionLTR
+		case classR:
+			return DirectionRTL
+		}
+	}
+	return DirectionAuto
+}
+
+// resolveDirection picks the base direction for s: an explicit dir wins, then the text itself, then fallback.
+// The result is never DirectionAuto.
+func resolveDirection(s string, dir, fallback Direction) Direction {
+	if dir == DirectionLTR || dir == DirectionRTL {
+		return dir
+	}
+	if d := detectDirection(s); d != DirectionAuto {
+		return d
+	}
+	if fallback == DirectionRTL {
+		return DirectionRTL
+	}
+	return DirectionLTR
+}
+
+// prepareLine shapes s and reorders it into visual order using the given resolved base direction.
+func prepareLine(s string, dir Direction) string {
+	base := 0
+	if dir == DirectionRTL {
+		base = 1
+	}
+	return string(visualOrderBase(shapeArabic(dropPresentationSelectors([]rune(s))), base))
+}
@@ -0,0 +1,104 @@
+package wallpaper
+
+import "fmt"
+
+// Dither selects how the 16-bit compositing buffer is quantized to the 8-bit outputs.
+type Dither string
+
+const (
+	// DitherNone rounds to the nearest 8-bit value; this matches the historical output.
+	DitherNone Dither = "none"
+	// DitherOrdered adds a fixed 8x8 Bayer threshold pattern, which is stable between builds and compresses well.
+	DitherOrdered Dither = "ordered"
+	// DitherFloydSteinberg diffuses the rounding error to neighboring pixels for the least visible pattern.
+	DitherFloydSteinberg Dither = "floyd-steinberg"
+)
+
+// Dithers returns all selectable dithering modes in a stable order for help output and validation.
+func Dithers() []Dither {
+	return []Dither{DitherNone, DitherOrdered, DitherFloydSteinberg}
+}
+
+// ParseDither converts a user-supplied dithering name into a Dither.
+// An empty name selects DitherNone; unknown names return an error.
+func ParseDither(name string) (Dither, error) {
+	if name == "" {
+		return DitherNone, nil
+	}
+	for _, d := range Dithers() {
+		if string(d) == name {
+			return d, nil
+		}
+	}
+	return DitherNone, fmt.Errorf("render: unknown dither %q", name)
+}
+
+// enabled reports whether d dithers; the zero value does not.
+func (d Dither) enabled() bool {
+	return d != "" && d != DitherNone
+}
+
+// bayer8 is the 8x8 Bayer threshold matrix with values 0..63.
+var bayer8 = [8][8]uint8{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// quantizer converts 8.8 fixed-point channel values to 8 bits, one row at a time from top to bottom.
+type quantizer struct {
+	dither Dither
+	// errCur and errNext hold the diffused Floyd–Steinberg error per channel for the current and next row,
+	// in 8.8 units, offset by one pixel so the left and right neighbors need no bounds checks.
+	errCur, errNext []int32
+}
+
+// newQuantizer returns a quantizer for rows of width pixels.
+func newQuantizer(d Dither, width int) *quantizer {
+	q := &quantizer{dither: d}
+	if d == DitherFloydSteinberg {
+		q.errCur = make([]int32, 3*(width+2))
+		q.errNext = make([]int32, 3*(width+2))
+	}
+	return q
+}
+
+// nextRow advances the error buffers; call it after each row.
+func (q *quantizer) nextRow() {
+	if q.dither == DitherFloydSteinberg {
+		q.errCur, q.errNext = q.errNext, q.errCur
+		clear(q.errNext)
+	}
+}
+
+// quantize returns the 8-bit value of channel c at (x, y) for v in 8.8 fixed point; x is relative to the row start.
+func (q *quantizer) quantize(v uint16, x, y, c int) uint8 {
+	switch q.dither {
+	case DitherOrdered:
+		// Thresholds spread evenly over one 8-bit step with a mean of half a step, so the average stays unbiased.
+		t := int32(bayer8[y&7][x&7])*4 + 2
+		return clampByte((int32(v) + t) >> 8)
+	case DitherFloydSteinberg:
+		i := 3*(x+1) + c
+		want := int32(v) + q.errCur[i]
+		out := clampByte((want + 128) >> 8)
+		e := want - int32(out)<<8
+		q.errCur[i+3] += e * 7 / 16
+		q.errNext[i-3] += e * 3 / 16
+		q.errNext[i] += e * 5 / 16
+		q.errNext[i+3] += e / 16
+		return out
+	default:
+		return clampByte((int32(v) + 128) >> 8)
+	}
+}
+
+// clampByte limits v to [0, 255].
+func clampByte(v int32) uint8 {
+	return uint8(max(0, min(255, v)))
+}
@@ -0,0 +1,64 @@
+package wallpaper
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+// TestQuantizer_DitherPreservesAverage expects dithered quantization of a fractional level to mix the two nearest
+// 8-bit values so the average matches, and plain rounding to collapse it onto one value.
+// The test fails if a dithering mode is biased or produces values outside the two neighbors.
+func TestQuantizer_DitherPreservesAverage(t *testing.T) {
+	const w, h = 64, 16
+	const level = 100.25
+	for _, d := range Dithers() {
+		q := newQuantizer(d, w)
+		sum := 0.0
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				v := q.quantize(uint16(level*256), x, y, 0)
+				if v != 100 && v != 101 {
+					t.Fatalf("%s: value %d at (%d,%d), want 100 or 101", d, v, x, y)
+				}
+				sum += float64(v)
+			}
+			q.nextRow()
+		}
+		mean := sum / (w * h)
+		want := level
+		if d == DitherNone {
+			want = 100
+		}
+		if math.Abs(mean-want) > 0.02 {
+			t.Fatalf("%s: mean %.3f, want %.3f", d, mean, want)
+		}
+	}
+
+	if _, err := ParseDither("random"); err == nil {
+		t.Fatalf("expected error for unknown dither")
+	}
+}
+
+// TestCompositor_DitherKeepsExact8BitPixels expects dithering to leave pixels untouched when nothing is blended,
+// so only values with a fractional part after compositing change.
+func TestCompositor_DitherKeepsExact8BitPixels(t *testing.T) {
+	bg := goldenBackground(64, 32, goldenSeed)
+	src := image.NewRGBA(bg.Bounds())
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 64; x++ {
+			src.Set(x, y, bg.At(x, y))
+		}
+	}
+	for _, d := range []Dither{DitherOrdered, DitherFloydSteinberg} {
+		canvas := image.NewRGBA(src.Bounds())
+		comp := newCompositor(canvas, false, d)
+		comp.replace(src)
+		comp.finish()
+		for i := range canvas.Pix {
+			if canvas.Pix[i] != src.Pix[i] {
+				t.Fatalf("%s: byte %d changed from %d to %d", d, i, src.Pix[i], canvas.Pix[i])
+			}
+		}
+	}
+}
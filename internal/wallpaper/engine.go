@@ -0,0 +1,374 @@
+package wallpaper
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/image/font"
+)
+
+// Names of the built-in layout engines.
+const (
+	LayoutCentered   = "centered"
+	LayoutBottomBar  = "bottom-bar"
+	LayoutCornerCard = "corner-card"
+	LayoutMinimal    = "minimal"
+	LayoutFullBleed  = "full-bleed"
+)
+
+// LayoutEngine positions the title and subtitle and the overlay drawn behind them.
+// Implementations are registered with RegisterLayout or passed directly via Options.Layout.
+type LayoutEngine interface {
+	// Name identifies the engine for --layout and must be unique among registered engines.
+	Name() string
+	// FontSizes returns the title and subtitle font sizes in pixels for the output resolution.
+	FontSizes(width, height int) (title, subtitle float64)
+	// Compute returns the geometry for the measured lines. A zero BoxOpacity draws no box and a zero
+	// SeparatorThickness draws no separator.
+	Compute(in LayoutInput) (Layout, error)
+}
+
+// LayoutInput is the measured text handed to a LayoutEngine.
+// Title and Subtitle are in visual order with their resolved base directions, as drawn.
+type LayoutInput struct {
+	Width, Height int
+
+	TitleFace, SubtitleFace font.Face
+	Title, Subtitle         string
+	TitleDir, SubtitleDir   Direction
+
+	// RowWidth and RowHeight are the size of the tag row to place below the subtitle; zero without tags. Engines
+	// that leave Layout.RowHeight zero for a row make rendering fail, as the row would have no place.
+	RowWidth, RowHeight int
+}
+
+// rowBlock returns the height the tag row adds below the subtitle: a gap of a third of padding and the row, or zero
+// without tags.
+func (in LayoutInput) rowBlock(padding int) int {
+	if in.RowHeight <= 0 {
+		return 0
+	}
+	return padding/3 + in.RowHeight
+}
+
+// placeRow records the tag row of in at x in l, a third of padding below subtitleBottom, the lowest pixel row of the
+// subtitle line; l is returned unchanged without tags.
+func (in LayoutInput) placeRow(l Layout, x, subtitleBottom, padding int) Layout {
+	if in.RowHeight <= 0 {
+		return l
+	}
+	l.RowX, l.RowY = x, subtitleBottom+padding/3
+	l.RowWidth, l.RowHeight = in.RowWidth, in.RowHeight
+	return l
+}
+
+// lineMetrics holds the pixel extents of the title and subtitle lines.
+type lineMetrics struct {
+	titleAdvance, subAdvance int
+	titleAscent, subAscent   int
+	titleHeight, subHeight   int
+}
+
+// measure validates the input, falls back to the target resolution for non-positive sizes, and measures both lines.
+func (in LayoutInput) measure() (LayoutInput, lineMetrics, error) {
+	if in.Width <= 0 || in.Height <= 0 {
+		in.Width, in.Height = TargetWidth, TargetHeight
+	}
+	if in.TitleFace == nil || in.SubtitleFace == nil {
+		return in, lineMetrics{}, fmt.Errorf("layout: font face is nil")
+	}
+	tm, sm := in.TitleFace.Metrics(), in.SubtitleFace.Metrics()
+	return in, lineMetrics{
+		titleAdvance: font.MeasureString(in.TitleFace, in.Title).Ceil(),
+		subAdvance:   font.MeasureString(in.SubtitleFace, in.Subtitle).Ceil(),
+		titleAscent:  tm.Ascent.Ceil(),
+		subAscent:    sm.Ascent.Ceil(),
+		titleHeight:  (tm.Ascent + tm.Descent).Ceil(),
+		subHeight:    (sm.Ascent + sm.Descent).Ceil(),
+	}, nil
+}
+
+// fontSizes returns the font sizes recorded in Layout, matching ComputeLayoutForText.
+func (in LayoutInput) fontSizes() (float64, float64) {
+	tm, sm := in.TitleFace.Metrics(), in.SubtitleFace.Metrics()
+	return float64(tm.Ascent.Ceil() + tm.Descent.Ceil()), float64(sm.Ascent.Ceil() + sm.Descent.Ceil())
+}
+
+// layoutPadding is the base spacing unit shared by all built-in engines.
+func layoutPadding(width, height int) int {
+	return maxInt(14, minInt(width, height)*paddingPercent/100)
+}
+
+var (
+	layoutMu      sync.RWMutex
+	layoutEngines = []LayoutEngine{centeredLayout{}, bottomBarLayout{}, cornerCardLayout{}, minimalLayout{}, fullBleedLayout{}}
+)
+
+// Layouts returns the names of all registered layout engines, built-ins first, for help output and validation.
+func Layouts() []string {
+	layoutMu.RLock()
+	defer layoutMu.RUnlock()
+	names := make([]string, 0, len(layoutEngines))
+	for _, e := range layoutEngines {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+// ParseLayout looks up a registered layout engine by name.
+// An empty name selects the centered default; unknown names return an error.
+func ParseLayout(name string) (LayoutEngine, error) {
+	if name == "" {
+		return centeredLayout{}, nil
+	}
+	layoutMu.RLock()
+	defer layoutMu.RUnlock()
+	for _, e := range layoutEngines {
+		if e.Name() == name {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("render: unknown layout %q", name)
+}
+
+// RegisterLayout makes a third-party layout engine selectable by name, e.g. from an init function.
+// It returns an error for a nil engine, an empty name, or a name that is already registered.
+func RegisterLayout(engine LayoutEngine) error {
+	if engine == nil || engine.Name() == "" {
+		return fmt.Errorf("render: layout engine needs a name")
+	}
+	layoutMu.Lock()
+	defer layoutMu.Unlock()
+	for _, e := range layoutEngines {
+		if e.Name() == engine.Name() {
+			return fmt.Errorf("render: layout %q already registered", engine.Name())
+		}
+	}
+	layoutEngines = append(layoutEngines, engine)
+	return nil
+}
+
+// centeredLayout is the default: a rounded box in the image center with the title above a separator and the subtitle.
+type centeredLayout struct{}
+
+func (centeredLayout) Name() string { return LayoutCentered }
+
+func (centeredLayout) FontSizes(width, height int) (float64, float64) {
+	return float64(height) * 0.06, float64(height) * 0.036
+}
+
+func (centeredLayout) Compute(in LayoutInput) (Layout, error) {
+	return computeCenteredLayout(in)
+}
+
+// bottomBarLayout draws a full-width bar along the bottom edge with the title at the start and the subtitle at the end
+// of a shared baseline; right-to-left titles swap the sides.
+type bottomBarLayout struct{}
+
+func (bottomBarLayout) Name() string { return LayoutBottomBar }
+
+func (bottomBarLayout) FontSizes(width, height int) (float64, float64) {
+	return float64(height) * 0.04, float64(height) * 0.028
+}
+
+func (bottomBarLayout) Compute(in LayoutInput) (Layout, error) {
+	in, m, err := in.measure()
+	if err != nil {
+		return Layout{}, err
+	}
+	padding := layoutPadding(in.Width, in.Height)
+	if m.titleAdvance+padding+m.subAdvance > in.Width-2*padding || in.RowWidth > in.Width-2*padding {
+		return Layout{}, fmt.Errorf("layout: title and subtitle do not fit the bottom bar: %w, please reduce the text", ErrTextTooLong)
+	}
+
+	ascent := maxInt(m.titleAscent, m.subAscent)
+	descent := maxInt(m.titleHeight-m.titleAscent, m.subHeight-m.subAscent)
+	barHeight := padding + ascent + descent + in.rowBlock(padding)
+	boxY0 := in.Height - barHeight
+	baseline := boxY0 + padding/2 + ascent
+
+	// The tag row sits below the subtitle, at the end of the bar.
+	titleX, subtitleX, rowX := padding, in.Width-padding-m.subAdvance, in.Width-padding-in.RowWidth
+	if in.TitleDir == DirectionRTL {
+		titleX, subtitleX, rowX = in.Width-padding-m.titleAdvance, padding, padding
+	}
+	titleSize, subtitleSize := in.fontSizes()
+	return in.placeRow(Layout{
+		Width:            in.Width,
+		Height:           in.Height,
+		BoxX0:            0,
+		BoxY0:            boxY0,
+		BoxX1:            in.Width,
+		BoxY1:            in.Height,
+		BoxWidth:         in.Width,
+		BoxHeight:        barHeight,
+		BoxOpacity:       boxOpacityDefault,
+		Padding:          padding,
+		TitleX:           titleX,
+		TitleY:           baseline,
+		SubtitleX:        subtitleX,
+		SubtitleY:        baseline,
+		TitleFontSize:    titleSize,
+		SubtitleFontSize: subtitleSize,
+		Direction:        resolveDirection("", in.TitleDir, DirectionLTR),
+	}, rowX, baseline+descent, padding), nil
+}
+
+// cornerCardLayout draws a compact rounded card in the bottom-left corner (bottom-right for right-to-left titles),
+// sized to the text rather than to the image.
+type cornerCardLayout struct{}
+
+func (cornerCardLayout) Name() string { return LayoutCornerCard }
+
+func (cornerCardLayout) FontSizes(width, height int) (float64, float64) {
+	return float64(height) * 0.045, float64(height) * 0.027
+}
+
+func (cornerCardLayout) Compute(in LayoutInput) (Layout, error) {
+	in, m, err := in.measure()
+	if err != nil {
+		return Layout{}, err
+	}
+	padding := layoutPadding(in.Width, in.Height)
+	lineThickness := maxInt(2, in.Height/lineThicknessDiv)
+	gapAfterTitle := maxInt(padding/3, lineThickness)
+	gapAfterSeparator := padding / 2
+
+	boxWidth := maxInt(maxInt(m.titleAdvance, m.subAdvance), in.RowWidth) + 2*padding
+	boxHeight := padding + m.titleHeight + gapAfterTitle + lineThickness + gapAfterSeparator + m.subHeight + in.rowBlock(padding) + padding
+	boxX0 := padding
+	if in.TitleDir == DirectionRTL {
+		boxX0 = in.Width - padding - boxWidth
+	}
+	boxY0 := in.Height - padding - boxHeight
+	boxX1, boxY1 := boxX0+boxWidth, boxY0+boxHeight
+
+	separatorY := boxY0 + padding + m.titleHeight + gapAfterTitle + lineThickness/2
+	subtitleY := separatorY + lineThickness/2 + gapAfterSeparator + m.subAscent
+	titleSize, subtitleSize := in.fontSizes()
+	return in.placeRow(Layout{
+		Width:              in.Width,
+		Height:             in.Height,
+		BoxX0:              boxX0,
+		BoxY0:              boxY0,
+		BoxX1:              boxX1,
+		BoxY1:              boxY1,
+		BoxWidth:           boxWidth,
+		BoxHeight:          boxHeight,
+		BoxRadius:          maxInt(10, minInt(boxWidth, boxHeight)/radiusDivisor),
+		BoxOpacity:         boxOpacityDefault,
+		Padding:            padding,
+		SeparatorY:         separatorY,
+		SeparatorThickness: lineThickness,
+		TitleX:             lineX(boxX0, boxX1, m.titleAdvance, in.TitleDir),
+		TitleY:             boxY0 + padding + m.titleAscent,
+		SubtitleX:          lineX(boxX0, boxX1, m.subAdvance, in.SubtitleDir),
+		SubtitleY:          subtitleY,
+		TitleFontSize:      titleSize,
+		SubtitleFontSize:   subtitleSize,
+		Direction:          resolveDirection("", in.TitleDir, DirectionLTR),
+	}, lineX(boxX0, boxX1, in.RowWidth, in.TitleDir), subtitleY-m.subAscent+m.subHeight, padding), nil
+}
+
+// minimalLayout draws only the two lines, stacked in the bottom-left corner (bottom-right for right-to-left titles),
+// without box or separator.
+type minimalLayout struct{}
+
+func (minimalLayout) Name() string { return LayoutMinimal }
+
+func (minimalLayout) FontSizes(width, height int) (float64, float64) {
+	return float64(height) * 0.05, float64(height) * 0.03
+}
+
+func (minimalLayout) Compute(in LayoutInput) (Layout, error) {
+	in, m, err := in.measure()
+	if err != nil {
+		return Layout{}, err
+	}
+	padding := layoutPadding(in.Width, in.Height)
+	gap := padding / 3
+	contentWidth := maxInt(maxInt(m.titleAdvance, m.subAdvance), in.RowWidth)
+	contentHeight := m.titleHeight + gap + m.subHeight + in.rowBlock(padding)
+
+	// The box records the text bounds for callers; it is not drawn.
+	boxX0 := 2 * padding
+	if in.TitleDir == DirectionRTL {
+		boxX0 = in.Width - 2*padding - contentWidth
+	}
+	boxY0 := in.Height - 2*padding - contentHeight
+	boxX1 := boxX0 + contentWidth
+
+	titleX, subtitleX, rowX := boxX0, boxX0, boxX0
+	if in.TitleDir == DirectionRTL {
+		titleX, subtitleX, rowX = boxX1-m.titleAdvance, boxX1-m.subAdvance, boxX1-in.RowWidth
+	}
+	titleSize, subtitleSize := in.fontSizes()
+	return in.placeRow(Layout{
+		Width:            in.Width,
+		Height:           in.Height,
+		BoxX0:            boxX0,
+		BoxY0:            boxY0,
+		BoxX1:            boxX1,
+		BoxY1:            boxY0 + contentHeight,
+		BoxWidth:         contentWidth,
+		BoxHeight:        contentHeight,
+		Padding:          padding,
+		TitleX:           titleX,
+		TitleY:           boxY0 + m.titleAscent,
+		SubtitleX:        subtitleX,
+		SubtitleY:        boxY0 + m.titleHeight + gap + m.subAscent,
+		TitleFontSize:    titleSize,
+		SubtitleFontSize: subtitleSize,
+		Direction:        resolveDirection("", in.TitleDir, DirectionLTR),
+	}, rowX, boxY0+m.titleHeight+gap+m.subHeight, padding), nil
+}
+
+// fullBleedLayout dims the whole image and sets a large title across its center, with the subtitle below a separator.
+type fullBleedLayout struct{}
+
+func (fullBleedLayout) Name() string { return LayoutFullBleed }
+
+func (fullBleedLayout) FontSizes(width, height int) (float64, float64) {
+	return float64(height) * 0.1, float64(height) * 0.04
+}
+
+// fullBleedOpacity keeps the background visible while giving the large title enough contrast.
+const fullBleedOpacity = 110
+
+func (fullBleedLayout) Compute(in LayoutInput) (Layout, error) {
+	in, m, err := in.measure()
+	if err != nil {
+		return Layout{}, err
+	}
+	padding := layoutPadding(in.Width, in.Height)
+	lineThickness := maxInt(2, in.Height/lineThicknessDiv)
+	gapAfterTitle := maxInt(padding/2, lineThickness)
+	gapAfterSeparator := padding / 2
+
+	contentHeight := m.titleHeight + gapAfterTitle + lineThickness + gapAfterSeparator + m.subHeight + in.rowBlock(padding)
+	top := (in.Height - contentHeight) / 2
+	separatorY := top + m.titleHeight + gapAfterTitle + lineThickness/2
+	subtitleY := separatorY + lineThickness/2 + gapAfterSeparator + m.subAscent
+	titleSize, subtitleSize := in.fontSizes()
+	return in.placeRow(Layout{
+		Width:              in.Width,
+		Height:             in.Height,
+		BoxX0:              0,
+		BoxY0:              0,
+		BoxX1:              in.Width,
+		BoxY1:              in.Height,
+		BoxWidth:           in.Width,
+		BoxHeight:          in.Height,
+		BoxOpacity:         fullBleedOpacity,
+		Padding:            padding,
+		SeparatorY:         separatorY,
+		SeparatorThickness: lineThickness,
+		TitleX:             lineX(0, in.Width, m.titleAdvance, in.TitleDir),
+		TitleY:             top + m.titleAscent,
+		SubtitleX:          lineX(0, in.Width, m.subAdvance, in.SubtitleDir),
+		SubtitleY:          subtitleY,
+		TitleFontSize:      titleSize,
+		SubtitleFontSize:   subtitleSize,
+		Direction:          resolveDirection("", in.TitleDir, DirectionLTR),
+	}, lineX(0, in.Width, in.RowWidth, in.TitleDir), subtitleY-m.subAscent+m.subHeight, padding), nil
+}
@@ -0,0 +1,118 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font"
+)
+
+// TestLayoutEngines_GeometryInsideImage computes every built-in layout for left-to-right and right-to-left titles.
+// The test fails if a box or a text line leaves the image, or if start-aligned layouts do not mirror for RTL.
+func TestLayoutEngines_GeometryInsideImage(t *testing.T) {
+	const w, h = 1280, 720
+	bounds := image.Rect(0, 0, w, h)
+	for _, name := range []string{LayoutCentered, LayoutBottomBar, LayoutCornerCard, LayoutMinimal, LayoutFullBleed} {
+		engine, err := ParseLayout(name)
+		if err != nil {
+			t.Fatalf("ParseLayout(%q) error: %v", name, err)
+		}
+		titleSize, subtitleSize := engine.FontSizes(w, h)
+		titleFace, err := loadFace(boldFontData, titleSize, font.HintingNone)
+		if err != nil {
+			t.Fatalf("loadFace error: %v", err)
+		}
+		subtitleFace, err := loadFace(regularFontData, subtitleSize, font.HintingNone)
+		if err != nil {
+			t.Fatalf("loadFace error: %v", err)
+		}
+
+		var titleX [2]int
+		for i, dir := range []Direction{DirectionLTR, DirectionRTL} {
+			in := LayoutInput{
+				Width: w, Height: h,
+				TitleFace: titleFace, SubtitleFace: subtitleFace,
+				Title: "TSSH lab", Subtitle: "2026-01-04",
+				TitleDir: dir, SubtitleDir: dir,
+			}
+			l, err := engine.Compute(in)
+			if err != nil {
+				t.Fatalf("%s/%s: Compute error: %v", name, dir, err)
+			}
+			if l.Width != w || l.Height != h || l.Direction != dir {
+				t.Fatalf("%s/%s: unexpected size or direction: %+v", name, dir, l)
+			}
+			if box := image.Rect(l.BoxX0, l.BoxY0, l.BoxX1, l.BoxY1); !box.In(bounds) {
+				t.Fatalf("%s/%s: box %v outside image", name, dir, box)
+			}
+			titleW := font.MeasureString(titleFace, in.Title).Ceil()
+			subW := font.MeasureString(subtitleFace, in.Subtitle).Ceil()
+			if l.TitleX < 0 || l.TitleX+titleW > w || l.SubtitleX < 0 || l.SubtitleX+subW > w || l.TitleY <= 0 || l.SubtitleY > h {
+				t.Fatalf("%s/%s: text outside image: %+v", name, dir, l)
+			}
+			titleX[i] = l.TitleX
+		}
+
+		mirrored := titleX[0] < w/2 && titleX[1] > w/2
+		if wantMirror := name == LayoutBottomBar || name == LayoutCornerCard || name == LayoutMinimal; mirrored != wantMirror {
+			t.Fatalf("%s: title x %d (LTR) vs. %d (RTL), want mirrored=%v", name, titleX[0], titleX[1], wantMirror)
+		}
+	}
+}
+
+// fixedLayout is a third-party engine that places both lines at fixed positions without any overlay.
+type fixedLayout struct{}
+
+func (fixedLayout) Name() string { return "test-fixed" }
+
+func (fixedLayout) FontSizes(width, height int) (float64, float64) { return 20, 12 }
+
+func (fixedLayout) Compute(in LayoutInput) (Layout, error) {
+	return Layout{Width: in.Width, Height: in.Height, TitleX: 10, TitleY: 30, SubtitleX: 10, SubtitleY: 50}, nil
+}
+
+// TestRegisterLayout_ThirdPartyEngineDrivesRender registers a custom engine and renders with it.
+// The test fails if registration, lookup, or duplicate detection misbehaves, or if Render ignores the engine.
+func TestRegisterLayout_ThirdPartyEngineDrivesRender(t *testing.T) {
+	if err := RegisterLayout(fixedLayout{}); err != nil {
+		t.Fatalf("RegisterLayout error: %v", err)
+	}
+	if err := RegisterLayout(fixedLayout{}); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Fatalf("expected duplicate registration error, got %v", err)
+	}
+	if names := Layouts(); names[0] != LayoutCentered || names[len(names)-1] != "test-fixed" {
+		t.Fatalf("unexpected layout names %v", names)
+	}
+	if _, err := ParseLayout("diagonal"); err == nil || !strings.Contains(err.Error(), "unknown layout") {
+		t.Fatalf("expected unknown layout error, got %v", err)
+	}
+	if e, err := ParseLayout(""); err != nil || e.Name() != LayoutCentered {
+		t.Fatalf("ParseLayout(\"\") = %v, %v", e, err)
+	}
+
+	engine, err := ParseLayout("test-fixed")
+	if err != nil {
+		t.Fatalf("ParseLayout error: %v", err)
+	}
+	bgColor := color.RGBA{R: 40, G: 90, B: 150, A: 255}
+	img, err := Render(solidBG(320, 180, bgColor), "lab", "b1", Options{Width: 320, Height: 180, Layout: engine})
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	// Without an overlay, only the text near the fixed origin changes the background.
+	changed := func(r image.Rectangle) bool {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if img.RGBAAt(x, y) != bgColor {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if !changed(image.Rect(10, 10, 120, 55)) || changed(image.Rect(160, 60, 320, 180)) {
+		t.Fatalf("expected text only near the fixed layout position")
+	}
+}
@@ -0,0 +1,173 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// fallbackFace is a font.Face that draws each rune with the first font in the chain that has a glyph for it.
+// Metrics come from the primary font, with ascent/descent widened to fit every font in the chain.
+// Fonts with CBDT color bitmaps (e.g. Noto Color Emoji) are drawn through ColorGlyph instead of outline masks.
+type fallbackFace struct {
+	size   float64
+	fonts  []*sfnt.Font
+	faces  []font.Face
+	colors []*colorBitmapFont
+	buf    sfnt.Buffer
+	cache  map[rune]scaledColorGlyph
+}
+
+// scaledColorGlyph is a color bitmap resized to the face size, with the offset of its top-left corner from the dot.
+type scaledColorGlyph struct {
+	img    *image.RGBA
+	offset image.Point
+}
+
+// colorGlyphFace is implemented by faces that can draw some runes as color bitmaps.
+// ColorGlyph returns the destination rectangle and an image whose bounds start at the origin, or false to fall back to Glyph.
+type colorGlyphFace interface {
+	font.Face
+	ColorGlyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, bool)
+}
+
+// loadFaceChain builds a face from the primary font data followed by optional fallback fonts.
+// Without fallbacks it returns a plain face so rendering is unchanged; invalid font data is an error.
+func loadFaceChain(size float64, hinting font.Hinting, primary []byte, fallbacks ...[]byte) (font.Face, error) {
+	if len(fallbacks) == 0 {
+		return loadFace(primary, size, hinting)
+	}
+
+	chain := &fallbackFace{size: size, cache: map[rune]scaledColorGlyph{}}
+	for i, data := range append([][]byte{primary}, fallbacks...) {
+		parsed, err := opentype.Parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("render: parse font %d in fallback chain: %w", i, err)
+		}
+		face, err := newFace(parsed, size, hinting)
+		if err != nil {
+			return nil, fmt.Errorf("render: construct font face %d in fallback chain: %w", i, err)
+		}
+		colors, err := parseColorBitmaps(data)
+		if err != nil {
+			return nil, fmt.Errorf("render: parse color bitmaps of font %d in fallback chain: %w", i, err)
+		}
+		chain.fonts = append(chain.fonts, parsed)
+		chain.faces = append(chain.faces, face)
+		chain.colors = append(chain.colors, colors)
+	}
+	return chain, nil
+}
+
+// pick returns the index of the first font that maps r to a glyph and that glyph, or the primary font if none does.
+func (f *fallbackFace) pick(r rune) (int, sfnt.GlyphIndex) {
+	for i, parsed := range f.fonts {
+		if idx, err := parsed.GlyphIndex(&f.buf, r); err == nil && idx != 0 {
+			return i, idx
+		}
+	}
+	return 0, 0
+}
+
+// faceFor returns the face that draws r.
+func (f *fallbackFace) faceFor(r rune) font.Face {
+	i, _ := f.pick(r)
+	return f.faces[i]
+}
+
+// ColorGlyph implements colorGlyphFace for runes drawn from a font with color bitmaps.
+// Scaled bitmaps are cached per rune; missing or undecodable bitmaps report false.
+func (f *fallbackFace) ColorGlyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, bool) {
+	g, ok := f.cache[r]
+	if !ok {
+		g, ok = f.scaleColorGlyph(r)
+		if !ok {
+			return image.Rectangle{}, nil, false
+		}
+		f.cache[r] = g
+	}
+	origin := image.Point{X: dot.X.Round(), Y: dot.Y.Round()}.Add(g.offset)
+	return g.img.Bounds().Add(origin), g.img, true
+}
+
+// scaleColorGlyph decodes the color bitmap for r from the best strike and resizes it to the face size.
+func (f *fallbackFace) scaleColorGlyph(r rune) (scaledColorGlyph, bool) {
+	i, gid := f.pick(r)
+	colors := f.colors[i]
+	if colors == nil || gid == 0 {
+		return scaledColorGlyph{}, false
+	}
+	strike := colors.strikeFor(f.size)
+	g, err := colors.glyph(strike, gid)
+	if err != nil {
+		return scaledColorGlyph{}, false
+	}
+
+	scale := f.size / float64(strike.ppem)
+	src := g.img.Bounds()
+	w := int(math.Max(1, math.Round(float64(src.Dx())*scale)))
+	h := int(math.Max(1, math.Round(float64(src.Dy())*scale)))
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), g.img, src, draw.Src, nil)
+	return scaledColorGlyph{
+		img:    dst,
+		offset: image.Point{X: int(math.Round(float64(g.bearingX) * scale)), Y: -int(math.Round(float64(g.bearingY) * scale))},
+	}, true
+}
+
+// Close closes all faces in the chain.
+func (f *fallbackFace) Close() error {
+	var first error
+	for _, face := range f.faces {
+		if err := face.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Glyph implements font.Face.
+func (f *fallbackFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	return f.faceFor(r).Glyph(dot, r)
+}
+
+// GlyphBounds implements font.Face.
+func (f *fallbackFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	return f.faceFor(r).GlyphBounds(r)
+}
+
+// GlyphAdvance implements font.Face.
+func (f *fallbackFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	return f.faceFor(r).GlyphAdvance(r)
+}
+
+// Kern implements font.Face; kerning only applies when both runes come from the same font.
+func (f *fallbackFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	face := f.faceFor(r0)
+	if face != f.faceFor(r1) {
+		return 0
+	}
+	return face.Kern(r0, r1)
+}
+
+// Metrics implements font.Face.
+func (f *fallbackFace) Metrics() font.Metrics {
+	m := f.faces[0].Metrics()
+	for _, face := range f.faces[1:] {
+		other := face.Metrics()
+		if other.Ascent > m.Ascent {
+			m.Ascent = other.Ascent
+		}
+		if other.Descent > m.Descent {
+			m.Descent = other.Descent
+		}
+	}
+	m.Height = m.Ascent + m.Descent
+	return m
+}
@@ -1,120 +1,242 @@
 package wallpaper
 
 import (
-	"encoding/json"
+	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/nickhildebrandt/ts-release/internal/diag"
+	"github.com/nickhildebrandt/ts-release/internal/icc"
+	"github.com/nickhildebrandt/ts-release/internal/wallhaven"
 )
 
-// SearchParams captures the query configuration for Wallhaven search.
-// Adjust these values to tweak query, category, or sorting behavior.
-type SearchParams struct {
-	Query      string
-	Categories string
-	Purity     string
-	Sorting    string
+// DefaultSearchParams returns the search FetchBackground and WallhavenSource use unless told otherwise; the resolution
+// is set per request. Each call returns a new value, so callers can change it without affecting concurrent fetches.
+func DefaultSearchParams() wallhaven.SearchParams {
+	return wallhaven.SearchParams{
+		Query:      "nature",
+		Categories: "100",
+		Purity:     "100",
+		Sorting:    "random",
+	}
 }
 
-var DefaultSearchParams = SearchParams{
-	// Use OR separators so any of these themes can match instead of requiring all.
-	Query:      "nature",
-	Categories: "100",
-	Purity:     "100",
-	Sorting:    "random",
+// FetchBackground fetches and decodes a single background image for the requested resolution.
+// It returns an error for invalid dimensions, HTTP failures/non-2xx responses, invalid JSON, or image decode errors.
+func FetchBackground(width, height int) (image.Image, error) {
+	img, _, err := FetchBackgroundWithURL(width, height)
+	return img, err
 }
 
-const wallhavenSearchEndpoint = "https://wallhaven.cc/api/v1/search"
+// FetchBackgroundWithURL behaves like FetchBackground and additionally returns the URL the image was downloaded from.
+// The URL is empty whenever an error is returned.
+func FetchBackgroundWithURL(width, height int) (image.Image, string, error) {
+	return FetchBackgroundWithClient(&wallhaven.Client{}, width, height)
+}
 
-type searchResponse struct {
-	Data []struct {
-		Path string `json:"path"`
-	} `json:"data"`
+// FetchBackgroundWithClient is FetchBackgroundWithURL with an explicit Wallhaven client, e.g. one with an API key,
+// a custom http.Client, or a test server as base URL.
+func FetchBackgroundWithClient(client *wallhaven.Client, width, height int) (image.Image, string, error) {
+	bg, err := SearchBackground(client, width, height)
+	if err != nil {
+		return nil, "", err
+	}
+	return bg.Image, bg.URL, nil
 }
 
-// FetchBackground fetches and decodes a single background image for the requested resolution.
-// It returns an error for invalid dimensions, HTTP failures/non-2xx responses, invalid JSON, or image decode errors.
-func FetchBackground(width, height int) (image.Image, error) {
+// Background is a fetched and decoded background image together with its origin.
+type Background struct {
+	Image image.Image
+	// URL is the address the image was downloaded from.
+	URL string
+	// WallpaperID is the Wallhaven ID; FetchWallpaper fetches the same image again.
+	WallpaperID string
+	// Source names the service the image came from, e.g. SourceWallhaven.
+	Source string
+	// PageURL is the image's page on the service, if known.
+	PageURL string
+	// Attribution is the credit line the service's license asks for, e.g. "Photo by Jane Doe on Unsplash".
+	Attribution string
+	// Focal is the point of the image its crop keeps in frame, e.g. from a curated list entry; nil leaves the choice to
+	// Options.
+	Focal *FocalPoint
+	// NextPoolPosition is the round-robin position the next build continues at if this image is a round-robin pick of
+	// a pool; zero otherwise.
+	NextPoolPosition uint64
+}
+
+// SearchBackground searches DefaultSearchParams for the requested resolution and fetches the first result.
+// It returns an error for invalid dimensions, API failures, an empty result, or image decode errors.
+func SearchBackground(client *wallhaven.Client, width, height int) (Background, error) {
+	return searchBackground(client, DefaultSearchParams(), width, height, Limits{})
+}
+
+// searchBackground is SearchBackground with explicit search parameters; the resolution is set from the size.
+func searchBackground(client *wallhaven.Client, params wallhaven.SearchParams, width, height int, limits Limits) (Background, error) {
 	if width <= 0 || height <= 0 {
-		return nil, fmt.Errorf("fetch background: invalid target size %dx%d", width, height)
+		return Background{}, fmt.Errorf("fetch background: invalid target size %dx%d", width, height)
 	}
 
-	imageURL, err := fetchImageURL(width, height, DefaultSearchParams)
+	params.Resolutions = []string{fmt.Sprintf("%dx%d", width, height)}
+	page, err := client.Search(params)
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: %w", err)
+	}
+	if len(page.Data) == 0 || page.Data[0].Path == "" {
+		return Background{}, fmt.Errorf("fetch background: %w: no usable image for %dx%d", ErrNoResults, width, height)
+	}
+	bg, err := download(client, page.Data[0], limits)
 	if err != nil {
-		return nil, err
+		return Background{}, fmt.Errorf("fetch background: %w", err)
 	}
+	return bg, nil
+}
 
-	return downloadAndDecode(imageURL)
+// FetchWallpaper fetches the wallpaper with the given Wallhaven ID through the detail API, whatever its resolution,
+// so a release can keep an approved background. It returns an error for unknown IDs, API failures, or decode errors.
+func FetchWallpaper(client *wallhaven.Client, id string) (Background, error) {
+	return fetchWallpaper(client, id, Limits{})
 }
 
-// fetchImageURL calls the search API and extracts the image URL from the response.
-// It returns an error if the URL cannot be built, the request fails, the status is non-2xx, or no usable data is returned.
-func fetchImageURL(width, height int, params SearchParams) (string, error) {
-	searchURL, err := buildSearchURL(width, height, params)
+// fetchWallpaper is FetchWallpaper with the limits of the decoded image.
+func fetchWallpaper(client *wallhaven.Client, id string, limits Limits) (Background, error) {
+	w, err := client.Wallpaper(id)
 	if err != nil {
-		return "", err
+		return Background{}, fmt.Errorf("fetch wallpaper %s: %w", id, err)
 	}
-
-	resp, err := http.Get(searchURL)
+	if w.Path == "" {
+		return Background{}, fmt.Errorf("fetch wallpaper %s: no image path", id)
+	}
+	if w.ID == "" {
+		w.ID = id
+	}
+	bg, err := download(client, *w, limits)
 	if err != nil {
-		return "", fmt.Errorf("fetch background: search request failed: %w", err)
+		return Background{}, fmt.Errorf("fetch wallpaper %s: %w", id, err)
 	}
-	defer resp.Body.Close()
+	return bg, nil
+}
 
-	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return "", fmt.Errorf("fetch background: search request returned http %d", resp.StatusCode)
+// download fetches and decodes the full-size image of w within limits.
+func download(client *wallhaven.Client, w wallhaven.Wallpaper, limits Limits) (Background, error) {
+	data, err := client.Download(w.Path)
+	if err != nil {
+		return Background{}, err
 	}
-
-	var payload searchResponse
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return "", fmt.Errorf("fetch background: decode search failed: %w", err)
+	img, err := decodeSRGB(data, limits)
+	if err != nil {
+		return Background{}, err
 	}
+	return Background{Image: img, URL: w.Path, WallpaperID: w.ID, Source: SourceWallhaven, PageURL: w.URL}, nil
+}
 
-	if len(payload.Data) == 0 || payload.Data[0].Path == "" {
-		return "", fmt.Errorf("fetch background: no usable image for %dx%d", width, height)
-	}
+// SourceFile and SourceStdin name backgrounds read by FileSource.
+const (
+	SourceFile  = "file"
+	SourceStdin = "stdin"
+)
 
-	return payload.Data[0].Path, nil
+// FileSource reads a local background image, or standard input for the path "-", so pipelines need no temporary
+// files. The format is sniffed from the data, whatever the file name says.
+type FileSource struct {
+	Path string
+	// Stdin is read for the path "-"; nil selects os.Stdin.
+	Stdin io.Reader
+	// MaxStdinSize bounds the bytes read from Stdin; zero selects DefaultMaxStdinSize.
+	MaxStdinSize int64
+	Limits       Limits
 }
 
-// buildSearchURL builds the full Wallhaven search URL including query parameters for resolution and filters.
-// It returns an error if the fixed endpoint cannot be parsed as a URL.
-func buildSearchURL(width, height int, params SearchParams) (string, error) {
-	values := url.Values{}
-	values.Set("q", params.Query)
-	values.Set("categories", params.Categories)
-	values.Set("purity", params.Purity)
-	values.Set("resolutions", fmt.Sprintf("%dx%d", width, height))
-	values.Set("sorting", params.Sorting)
-
-	endpoint, err := url.Parse(wallhavenSearchEndpoint)
+// DefaultMaxStdinSize bounds a background read from standard input unless FileSource.MaxStdinSize is set, like the
+// downloads of the services are bounded, so a pipe that never ends cannot exhaust memory.
+const DefaultMaxStdinSize = 256 << 20
+
+// Fetch implements BackgroundSource; the size is ignored because the image is scaled like any other background.
+func (s FileSource) Fetch(width, height int) (Background, error) {
+	var data []byte
+	var err error
+	bg := Background{Source: SourceStdin}
+	if s.Path == "-" {
+		stdin := s.Stdin
+		if stdin == nil {
+			stdin = os.Stdin
+		}
+		limit := s.MaxStdinSize
+		if limit <= 0 {
+			limit = DefaultMaxStdinSize
+		}
+		data, err = io.ReadAll(io.LimitReader(stdin, limit+1))
+		if err == nil && int64(len(data)) > limit {
+			return Background{}, fmt.Errorf("read background: %w: standard input is larger than %d bytes", ErrTooLarge, limit)
+		}
+	} else {
+		abs, absErr := filepath.Abs(s.Path)
+		if absErr != nil {
+			abs = s.Path
+		}
+		bg = Background{Source: SourceFile, URL: (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()}
+		data, err = os.ReadFile(s.Path)
+	}
 	if err != nil {
-		return "", fmt.Errorf("fetch background: invalid search endpoint: %w", err)
+		return Background{}, fmt.Errorf("read background: %w", err)
+	}
+	if len(data) == 0 {
+		return Background{}, fmt.Errorf("read background: %s is empty", s.Path)
+	}
+	if bg.Image, err = decodeSRGB(data, s.Limits); err != nil {
+		return Background{}, fmt.Errorf("read background: %w", err)
 	}
-	endpoint.RawQuery = values.Encode()
-	return endpoint.String(), nil
+	return bg, nil
 }
 
-// downloadAndDecode fetches the resource over HTTP and decodes it via image.Decode.
-// It returns an error if the request fails, the status is non-2xx, or the image bytes cannot be decoded.
-func downloadAndDecode(resource string) (image.Image, error) {
-	resp, err := http.Get(resource)
-	if err != nil {
-		return nil, fmt.Errorf("fetch background: image request failed: %w", err)
+// decodeSRGB decodes image data, converts it to sRGB using its embedded ICC profile, and turns JPEGs upright as their
+// EXIF orientation asks. Profiles that cannot be converted (e.g. LUT-based ones) are ignored and the pixels are
+// treated as sRGB.
+// Malformed data that makes a decoder panic fails with ErrDecode like other undecodable data, so it falls back, and
+// images beyond limits fail with a *TooLargeError before their pixels are decoded. Data whose header does not decode
+// fails with ErrDecode without decoding pixels either. Animated GIFs and APNGs are decoded as limits.Animated selects.
+func decodeSRGB(data []byte, limits Limits) (img image.Image, err error) {
+	defer func() {
+		var panicErr *diag.PanicError
+		if errors.As(err, &panicErr) {
+			err = fmt.Errorf("%w: %w", ErrDecode, err)
+		}
+	}()
+	defer diag.Recover("decode", &err)
+	// Data whose header does not decode is rejected before any pixels are, since its size cannot be checked.
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err == nil {
+		if err := limits.check(config.Width, config.Height); err != nil {
+			return nil, err
+		}
+		if img, err = limits.decodeAnimated(data, config); err != nil {
+			return nil, err
+		}
+		if img == nil {
+			img, _, err = image.Decode(bytes.NewReader(data))
+		}
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return nil, fmt.Errorf("fetch background: image request returned http %d", resp.StatusCode)
+	if errors.Is(err, image.ErrFormat) {
+		// Name what arrived instead, e.g. an HTML error page piped in by curl.
+		return nil, fmt.Errorf("%w: %w (data looks like %s)", ErrDecode, err, http.DetectContentType(data))
 	}
-
-	img, _, err := image.Decode(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("fetch background: decode failed: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	converted, err := icc.ConvertToSRGB(data, img)
+	if errors.Is(err, icc.ErrUnsupported) {
+		converted = img
+	} else if err != nil {
+		return nil, fmt.Errorf("color profile: %w", err)
 	}
-	return img, nil
+	return orient(converted, exifOrientation(data)), nil
 }
@@ -2,33 +2,25 @@ package wallpaper
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
 	"image"
 	"image/color"
+	"image/gif"
 	"image/png"
+	"io"
 	"net/http"
 	"net/http/httptest"
-	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
-)
-
-type rewriteTransport struct {
-	base       http.RoundTripper
-	rewriteURL *url.URL
-}
 
-// RoundTrip rewrites requests to wallhaven.cc to the local test server without changing path/query.
-// This enables real http.Get calls in the code while avoiding external network access.
-func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	if req.URL.Host == "wallhaven.cc" {
-		clone := req.Clone(req.Context())
-		clone.URL.Scheme = t.rewriteURL.Scheme
-		clone.URL.Host = t.rewriteURL.Host
-		// Keep path/query as-is.
-		return t.base.RoundTrip(clone)
-	}
-	return t.base.RoundTrip(req)
-}
+	"github.com/nickhildebrandt/ts-release/internal/diag"
+	"github.com/nickhildebrandt/ts-release/internal/icc"
+	"github.com/nickhildebrandt/ts-release/internal/wallhaven"
+)
 
 // mustPNGBytes produces a small valid PNG byte slice for mocked HTTP responses.
 // The test fails fast if PNG encoding unexpectedly fails.
@@ -43,28 +35,14 @@ func mustPNGBytes(t *testing.T) []byte {
 	return buf.Bytes()
 }
 
-// withHTTPRedirectToServer temporarily replaces http.DefaultTransport to redirect wallhaven.cc to an httptest.Server.
-// The original transport is restored via t.Cleanup to avoid cross-test interference.
-func withHTTPRedirectToServer(t *testing.T, serverURL string) {
-	t.Helper()
-	u, err := url.Parse(serverURL)
-	if err != nil {
-		t.Fatalf("parse server url: %v", err)
-	}
-
-	old := http.DefaultTransport
-	if old == nil {
-		old = http.DefaultTransport
-	}
-	http.DefaultTransport = &rewriteTransport{base: old, rewriteURL: u}
-
-	t.Cleanup(func() {
-		http.DefaultTransport = old
-	})
+// testClient returns a Wallhaven client that sends all requests to the test server.
+// Search requests go to the server's /api/v1 tree; image URLs returned by the server point at it directly.
+func testClient(server *httptest.Server) *wallhaven.Client {
+	return &wallhaven.Client{HTTPClient: server.Client(), BaseURL: server.URL + "/api/v1", Limiter: wallhaven.NewLimiter(0)}
 }
 
 // TestFetchBackground_Success_MockedHTTP verifies the happy path: search returns an image URL and the image is decoded.
-// The test fails if rewriting, JSON handling, or image decoding does not behave as expected.
+// The test fails if client injection, JSON handling, or image decoding does not behave as expected.
 func TestFetchBackground_Success_MockedHTTP(t *testing.T) {
 	pngBytes := mustPNGBytes(t)
 
@@ -85,9 +63,7 @@ func TestFetchBackground_Success_MockedHTTP(t *testing.T) {
 	}))
 	defer server.Close()
 
-	withHTTPRedirectToServer(t, server.URL)
-
-	img, err := FetchBackground(1920, 1080)
+	img, _, err := FetchBackgroundWithClient(testClient(server), 1920, 1080)
 	if err != nil {
 		t.Fatalf("FetchBackground error: %v", err)
 	}
@@ -109,9 +85,7 @@ func TestFetchBackground_NoResults_Error(t *testing.T) {
 	}))
 	defer server.Close()
 
-	withHTTPRedirectToServer(t, server.URL)
-
-	img, err := FetchBackground(1920, 1080)
+	img, _, err := FetchBackgroundWithClient(testClient(server), 1920, 1080)
 	if err == nil {
 		t.Fatalf("expected error")
 	}
@@ -132,13 +106,11 @@ func TestFetchBackground_MalformedJSON_Error(t *testing.T) {
 	}))
 	defer server.Close()
 
-	withHTTPRedirectToServer(t, server.URL)
-
-	_, err := FetchBackground(1920, 1080)
+	_, _, err := FetchBackgroundWithClient(testClient(server), 1920, 1080)
 	if err == nil {
 		t.Fatalf("expected error")
 	}
-	if !strings.Contains(err.Error(), "decode search failed") {
+	if !strings.Contains(err.Error(), "decode response") {
 		t.Fatalf("unexpected error: %q", err.Error())
 	}
 }
@@ -163,9 +135,7 @@ func TestFetchBackground_ImageDecodeFails_Error(t *testing.T) {
 	}))
 	defer server.Close()
 
-	withHTTPRedirectToServer(t, server.URL)
-
-	_, err := FetchBackground(1920, 1080)
+	_, _, err := FetchBackgroundWithClient(testClient(server), 1920, 1080)
 	if err == nil {
 		t.Fatalf("expected error")
 	}
@@ -174,6 +144,37 @@ func TestFetchBackground_ImageDecodeFails_Error(t *testing.T) {
 	}
 }
 
+// TestFetchBackground_DecoderPanic_ErrDecode expects data that makes a decoder panic to fail like undecodable data,
+// with ErrDecode and the panic as a *diag.PanicError. The test fails if the panic escapes or the fetch error is not
+// classified as a decode failure.
+func TestFetchBackground_DecoderPanic_ErrDecode(t *testing.T) {
+	image.RegisterFormat("tspanic", "TSPANIC", func(io.Reader) (image.Image, error) { panic("index out of range") },
+		func(io.Reader) (image.Config, error) { return image.Config{}, nil })
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/v1/search"):
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"data":[{"path":"` + server.URL + `/img"}]}`))
+		case r.URL.Path == "/img":
+			_, _ = w.Write([]byte("TSPANIC malformed"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	_, _, err := FetchBackgroundWithClient(testClient(server), 1920, 1080)
+	var panicErr *diag.PanicError
+	if !errors.Is(err, ErrDecode) || !errors.As(err, &panicErr) || panicErr.Op != "decode" {
+		t.Fatalf("expected ErrDecode with the decoder panic, got %v", err)
+	}
+	var fetchErr *FetchError
+	if !errors.As(ClassifyFetchError(err), &fetchErr) || fetchErr.Kind != KindDecode {
+		t.Fatalf("expected a decode fetch error, got %v", ClassifyFetchError(err))
+	}
+}
+
 // TestFetchBackground_InvalidSize_Error expects an error for invalid target dimensions.
 // This prevents pointless requests and documents the validation behavior.
 func TestFetchBackground_InvalidSize_Error(t *testing.T) {
@@ -185,3 +186,265 @@ func TestFetchBackground_InvalidSize_Error(t *testing.T) {
 		t.Fatalf("unexpected error: %q", err.Error())
 	}
 }
+
+// TestFetchBackgroundWithURL_ReturnsSourceURL expects the downloaded image URL to be returned alongside the image.
+// The URL is embedded into artifact metadata, so it must match the search result exactly.
+func TestFetchBackgroundWithURL_ReturnsSourceURL(t *testing.T) {
+	pngBytes := mustPNGBytes(t)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/v1/search"):
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"data":[{"path":"` + server.URL + `/img"}]}`))
+		case r.URL.Path == "/img":
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(pngBytes)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	img, sourceURL, err := FetchBackgroundWithClient(testClient(server), 1920, 1080)
+	if err != nil {
+		t.Fatalf("FetchBackgroundWithURL error: %v", err)
+	}
+	if img == nil {
+		t.Fatalf("expected non-nil image")
+	}
+	if sourceURL != server.URL+"/img" {
+		t.Fatalf("source URL: got %q want %q", sourceURL, server.URL+"/img")
+	}
+}
+
+// TestFetchWallpaper_PinnedID expects a pinned wallpaper to be fetched through the detail API without searching and
+// the search result ID to be reported, so a later release can pin it. The test fails if the detail API is bypassed
+// or an ID is lost.
+func TestFetchWallpaper_PinnedID(t *testing.T) {
+	pngBytes := mustPNGBytes(t)
+
+	var paths []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/api/v1/w/94x38z":
+			_, _ = w.Write([]byte(`{"data":{"id":"94x38z","path":"` + server.URL + `/img"}}`))
+		case "/api/v1/search":
+			_, _ = w.Write([]byte(`{"data":[{"id":"k7q1vd","path":"` + server.URL + `/img"}]}`))
+		case "/img":
+			_, _ = w.Write(pngBytes)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	bg, err := FetchWallpaper(testClient(server), "94x38z")
+	if err != nil {
+		t.Fatalf("FetchWallpaper error: %v", err)
+	}
+	if bg.Image == nil || bg.WallpaperID != "94x38z" || bg.URL != server.URL+"/img" {
+		t.Fatalf("got %+v", bg)
+	}
+	if strings.Join(paths, " ") != "/api/v1/w/94x38z /img" {
+		t.Fatalf("requests: %v", paths)
+	}
+
+	if bg, err = SearchBackground(testClient(server), 1920, 1080); err != nil || bg.WallpaperID != "k7q1vd" {
+		t.Fatalf("SearchBackground: got %+v, %v", bg, err)
+	}
+	if _, err := FetchWallpaper(testClient(server), "000000"); err == nil || !errors.Is(err, wallhaven.ErrNotFound) {
+		t.Fatalf("unknown ID: got %v, want ErrNotFound", err)
+	}
+}
+
+// TestFileSource_StdinAndFile expects FileSource to decode images from standard input and files by sniffing the
+// data, to name the content it got when it is not an image, and to stop reading standard input at its limit.
+// The test fails if the file name decides the format, a non-image error hides what was received, or standard input
+// beyond the limit is accepted.
+func TestFileSource_StdinAndFile(t *testing.T) {
+	pngBytes := mustPNGBytes(t)
+
+	bg, err := FileSource{Path: "-", Stdin: bytes.NewReader(pngBytes)}.Fetch(0, 0)
+	if err != nil || bg.Image == nil || bg.Source != SourceStdin || bg.URL != "" {
+		t.Fatalf("stdin: got %+v, %v", bg, err)
+	}
+
+	// The extension lies; the data decides.
+	path := filepath.Join(t.TempDir(), "background.jpg")
+	if err := os.WriteFile(path, pngBytes, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bg, err = FileSource{Path: path}.Fetch(0, 0)
+	if err != nil || bg.Image == nil || bg.Source != SourceFile || bg.URL != "file://"+filepath.ToSlash(path) {
+		t.Fatalf("file: got %+v, %v", bg, err)
+	}
+
+	_, err = FileSource{Path: "-", Stdin: strings.NewReader("<html><body>502 Bad Gateway</body></html>")}.Fetch(0, 0)
+	if err == nil || !strings.Contains(err.Error(), "text/html") {
+		t.Fatalf("html on stdin: got %v, want an error naming text/html", err)
+	}
+	if _, err := (FileSource{Path: "-", Stdin: strings.NewReader("")}).Fetch(0, 0); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Fatalf("empty stdin: got %v", err)
+	}
+	_, err = FileSource{Path: "-", Stdin: bytes.NewReader(pngBytes), MaxStdinSize: int64(len(pngBytes)) - 1}.Fetch(0, 0)
+	if !errors.Is(err, ErrTooLarge) || !strings.Contains(err.Error(), "standard input is larger than") {
+		t.Fatalf("stdin beyond the limit: got %v, want ErrTooLarge", err)
+	}
+}
+
+// TestGenerate_UsesInjectedClient expects Generate to fetch through Options.Wallhaven and search at the output size.
+// The test fails if Generate bypasses the injected client, which would reach the real network.
+func TestGenerate_UsesInjectedClient(t *testing.T) {
+	pngBytes := mustPNGBytes(t)
+
+	var resolutions string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/v1/search":
+			resolutions = r.URL.Query().Get("resolutions")
+			_, _ = w.Write([]byte(`{"data":[{"path":"` + server.URL + `/img"}]}`))
+		case r.URL.Path == "/img":
+			_, _ = w.Write(pngBytes)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	img, sourceURL, err := Generate("target", "b", Options{Width: 64, Height: 36, Wallhaven: testClient(server)})
+	if err != nil {
+		t.Fatalf("Generate error: %v", err)
+	}
+	if img.Bounds().Dx() != 64 || sourceURL != server.URL+"/img" || resolutions != "64x36" {
+		t.Fatalf("got %v from %q searching %q", img.Bounds(), sourceURL, resolutions)
+	}
+}
+
+// TestDecodeSRGB_ConvertsEmbeddedProfile expects backgrounds with a non-sRGB profile to be converted to sRGB and
+// untagged or sRGB-tagged backgrounds to keep their pixels.
+// The test fails if embedded profiles are ignored, which shifts colors of wide-gamut or linear images.
+func TestDecodeSRGB_ConvertsEmbeddedProfile(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for i := range img.Pix {
+		img.Pix[i] = 128
+		if i%4 == 3 {
+			img.Pix[i] = 255
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png encode: %v", err)
+	}
+
+	// A linear-light profile: sRGB colorants with a gamma 1.0 curve shared by all channels.
+	linear := append([]byte(nil), icc.SRGB()...)
+	for i := 0; i < int(binary.BigEndian.Uint32(linear[128:])); i++ {
+		entry := linear[132+12*i:]
+		if string(entry[:4]) == "rTRC" {
+			curve := linear[binary.BigEndian.Uint32(entry[4:]):]
+			binary.BigEndian.PutUint32(curve[8:], 1)
+			binary.BigEndian.PutUint16(curve[12:], 256)
+		}
+	}
+
+	for _, c := range []struct {
+		name    string
+		profile []byte
+		want    uint8
+	}{
+		{"untagged", nil, 128},
+		{"srgb", icc.SRGB(), 128},
+		// Linear 128/255 is about 50% light, which sRGB encodes as 188.
+		{"linear", linear, 188},
+	} {
+		data := buf.Bytes()
+		if c.profile != nil {
+			var err error
+			if data, err = icc.EmbedPNG(data, "test", c.profile); err != nil {
+				t.Fatalf("%s: EmbedPNG error: %v", c.name, err)
+			}
+		}
+		got, err := decodeSRGB(data, Limits{})
+		if err != nil {
+			t.Fatalf("%s: decodeSRGB error: %v", c.name, err)
+		}
+		if r, _, _, _ := got.At(0, 0).RGBA(); uint8(r>>8) < c.want-1 || uint8(r>>8) > c.want+1 {
+			t.Fatalf("%s: red = %d, want %d", c.name, r>>8, c.want)
+		}
+	}
+}
+
+// TestDecodeSRGB_AnimatedPolicy expects an animated GIF to be decoded as its first frame or as its middle frame
+// composed over the earlier ones, or rejected with an *AnimatedError, and each use to be reported; APNGs are detected
+// by their acTL chunk. The test fails if animated backgrounds are used silently or the wrong frame is picked.
+func TestDecodeSRGB_AnimatedPolicy(t *testing.T) {
+	red, green, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}, color.RGBA{0, 0, 255, 255}
+	palette := color.Palette{red, green, blue}
+	// The second frame covers the right half only, so its composed frame shows the first one on the left.
+	anim := &gif.GIF{Delay: []int{10, 10, 10}}
+	for i, r := range []image.Rectangle{image.Rect(0, 0, 4, 4), image.Rect(2, 0, 4, 4), image.Rect(0, 0, 4, 4)} {
+		frame := image.NewPaletted(r, palette)
+		for j := range frame.Pix {
+			frame.Pix[j] = uint8(i)
+		}
+		anim.Image = append(anim.Image, frame)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatalf("gif encode: %v", err)
+	}
+
+	for _, c := range []struct {
+		policy      AnimatedPolicy
+		left, right color.RGBA
+		warning     string
+	}{
+		{"", red, red, "background is an animated GIF with 3 frames; using the first frame"},
+		{AnimatedMiddle, red, green, "background is an animated GIF with 3 frames; using frame 2"},
+	} {
+		var warnings []string
+		img, err := decodeSRGB(buf.Bytes(), Limits{Animated: c.policy, Warn: func(msg string) { warnings = append(warnings, msg) }})
+		if err != nil {
+			t.Fatalf("%q: decode: %v", c.policy, err)
+		}
+		if got := color.RGBAModel.Convert(img.At(0, 0)); got != c.left {
+			t.Fatalf("%q: left pixel %v, want %v", c.policy, got, c.left)
+		}
+		if got := color.RGBAModel.Convert(img.At(3, 3)); got != c.right {
+			t.Fatalf("%q: right pixel %v, want %v", c.policy, got, c.right)
+		}
+		if len(warnings) != 1 || warnings[0] != c.warning {
+			t.Fatalf("%q: warnings %q, want %q", c.policy, warnings, c.warning)
+		}
+	}
+	_, err := decodeSRGB(buf.Bytes(), Limits{Animated: AnimatedReject})
+	var animated *AnimatedError
+	if !errors.As(err, &animated) || !errors.Is(err, ErrDecode) || animated.Format != "GIF" || animated.Frames != 3 {
+		t.Fatalf("expected an *AnimatedError for 3 GIF frames, got %v", err)
+	}
+
+	// An acTL chunk after IHDR declaring 2 frames makes a PNG an APNG.
+	still := mustPNGBytes(t)
+	acTL := []byte("\x00\x00\x00\x08acTL\x00\x00\x00\x02\x00\x00\x00\x00")
+	acTL = binary.BigEndian.AppendUint32(acTL, crc32.ChecksumIEEE(acTL[4:]))
+	apng := append(append(append([]byte(nil), still[:33]...), acTL...), still[33:]...)
+	var warnings []string
+	if _, err := decodeSRGB(apng, Limits{Animated: AnimatedMiddle, Warn: func(msg string) { warnings = append(warnings, msg) }}); err != nil {
+		t.Fatalf("apng: decode: %v", err)
+	}
+	if want := "background is an animated APNG with 2 frames; using its default image"; len(warnings) != 1 || warnings[0] != want {
+		t.Fatalf("apng: warnings %q, want %q", warnings, want)
+	}
+	if _, err := decodeSRGB(apng, Limits{Animated: AnimatedReject}); !errors.As(err, &animated) || animated.Format != "APNG" {
+		t.Fatalf("expected an *AnimatedError for the APNG, got %v", err)
+	}
+	warnings = nil
+	if _, err := decodeSRGB(still, Limits{Animated: AnimatedReject, Warn: func(msg string) { warnings = append(warnings, msg) }}); err != nil || len(warnings) != 0 {
+		t.Fatalf("expected a still PNG to decode without warnings, got %v, %q", err, warnings)
+	}
+}
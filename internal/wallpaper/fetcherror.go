@@ -0,0 +1,135 @@
+package wallpaper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/nickhildebrandt/ts-release/internal/bucket"
+	"github.com/nickhildebrandt/ts-release/internal/pexels"
+	"github.com/nickhildebrandt/ts-release/internal/unsplash"
+	"github.com/nickhildebrandt/ts-release/internal/wallhaven"
+)
+
+// Errors wrapped by background sources for failures that are not specific to one service.
+var (
+	// ErrNoResults is wrapped when a search, prefix, or list yields no usable image.
+	ErrNoResults = errors.New("no results")
+	// ErrDecode is wrapped when downloaded or supplied data is not a decodable image.
+	ErrDecode = errors.New("decode failed")
+	// ErrTooLarge is wrapped when supplied data exceeds its size limit before it is decoded; images whose header
+	// claims a size beyond the Limits fail with a *TooLargeError instead.
+	ErrTooLarge = errors.New("too large")
+)
+
+// ErrorKind categorizes why fetching a background failed.
+type ErrorKind string
+
+const (
+	// KindNetwork covers connection failures, timeouts, truncated responses, and server errors.
+	KindNetwork ErrorKind = "network"
+	// KindDNS covers host names that cannot be resolved.
+	KindDNS ErrorKind = "dns"
+	// KindTLS covers certificates that cannot be verified and failed TLS handshakes.
+	KindTLS ErrorKind = "tls"
+	// KindRateLimit covers requests the service rejected because of its rate limit.
+	KindRateLimit ErrorKind = "rate-limit"
+	// KindNoResults covers searches and lists without a usable image.
+	KindNoResults ErrorKind = "no-results"
+	// KindDecode covers data that is no decodable image, including downloads beyond their size limit.
+	KindDecode ErrorKind = "decode"
+)
+
+// FetchError is a background fetch failure with its category, so callers can print remediation hints and decide
+// whether trying again may help. It wraps the original error.
+type FetchError struct {
+	Kind ErrorKind
+	Err  error
+
+	// temporary marks DNS failures that may resolve on their own, as opposed to unknown hosts.
+	temporary bool
+}
+
+// Error returns the message of the wrapped error.
+func (e *FetchError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether the same fetch may succeed later without changing the configuration: network
+// failures, rate limits, and temporary DNS failures are; TLS, empty results, and undecodable images are not.
+func (e *FetchError) IsRetryable() bool {
+	switch e.Kind {
+	case KindNetwork, KindRateLimit:
+		return true
+	case KindDNS:
+		return e.temporary
+	default:
+		return false
+	}
+}
+
+// Hint returns a one-line remediation for the category.
+func (e *FetchError) Hint() string {
+	switch e.Kind {
+	case KindNetwork:
+		return "check the network connection and --proxy, or try again later"
+	case KindDNS:
+		if e.temporary {
+			return "the DNS lookup failed temporarily; try again later"
+		}
+		return "check the host name in the source URL and the resolver configuration"
+	case KindTLS:
+		return "trust the server's or proxy's CA with --ca-cert or --ca-bundle"
+	case KindRateLimit:
+		return "wait before the next build, lower --rate-limit, or use --cache-dir"
+	case KindNoResults:
+		return "loosen the search, or check the collection, prefix, or list"
+	case KindDecode:
+		return "make sure the source delivers a JPEG or PNG image"
+	default:
+		return ""
+	}
+}
+
+// ClassifyFetchError wraps err in a *FetchError if its category can be determined and returns it unchanged
+// otherwise, e.g. for nil, for configuration errors, or if it already is a *FetchError.
+func ClassifyFetchError(err error) error {
+	var fetchErr *FetchError
+	if err == nil || errors.As(err, &fetchErr) {
+		return err
+	}
+	var dnsErr *net.DNSError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var verification *tls.CertificateVerificationError
+	var record tls.RecordHeaderError
+	var opErr *net.OpError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, wallhaven.ErrRateLimited), errors.Is(err, unsplash.ErrRateLimited), errors.Is(err, pexels.ErrRateLimited):
+		return &FetchError{Kind: KindRateLimit, Err: err}
+	case errors.Is(err, ErrNoResults):
+		return &FetchError{Kind: KindNoResults, Err: err}
+	case errors.Is(err, ErrDecode), errors.Is(err, ErrTooLarge), errors.Is(err, wallhaven.ErrTooLarge),
+		errors.Is(err, unsplash.ErrTooLarge), errors.Is(err, pexels.ErrTooLarge), errors.Is(err, bucket.ErrTooLarge):
+		return &FetchError{Kind: KindDecode, Err: err}
+	case errors.As(err, &dnsErr):
+		return &FetchError{Kind: KindDNS, Err: err, temporary: dnsErr.IsTemporary || dnsErr.IsTimeout}
+	case errors.As(err, &unknownAuthority), errors.As(err, &hostname), errors.As(err, &invalid),
+		errors.As(err, &verification), errors.As(err, &record):
+		return &FetchError{Kind: KindTLS, Err: err}
+	case errors.Is(err, wallhaven.ErrServer), errors.Is(err, unsplash.ErrServer), errors.Is(err, pexels.ErrServer),
+		errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &opErr), errors.As(err, &netErr) && netErr.Timeout():
+		return &FetchError{Kind: KindNetwork, Err: err}
+	default:
+		return err
+	}
+}
@@ -0,0 +1,65 @@
+package wallpaper
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+
+	"github.com/nickhildebrandt/ts-release/internal/bucket"
+	"github.com/nickhildebrandt/ts-release/internal/pexels"
+	"github.com/nickhildebrandt/ts-release/internal/wallhaven"
+)
+
+// TestClassifyFetchError_KindsAndRetry expects fetch failures to be categorized through their wrapped errors, with
+// only transient categories retryable, and unrelated errors to pass through unchanged.
+// The test fails if a category, its retry decision, or the wrapped error is lost.
+func TestClassifyFetchError_KindsAndRetry(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	// The default client does not trust the test server's certificate.
+	_, tlsErr := http.Get(server.URL)
+	_, decodeErr := decodeSRGB([]byte("<html><body>502 Bad Gateway</body></html>"), Limits{})
+
+	for _, tc := range []struct {
+		name      string
+		err       error
+		kind      ErrorKind
+		retryable bool
+	}{
+		{"rate limit", fmt.Errorf("fetch background: %w", fmt.Errorf("wallhaven: search: http 429: %w", wallhaven.ErrRateLimited)), KindRateLimit, true},
+		{"pexels rate limit", pexels.ErrRateLimited, KindRateLimit, true},
+		{"server error", fmt.Errorf("wallhaven: search: http 503: %w", wallhaven.ErrServer), KindNetwork, true},
+		{"refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, KindNetwork, true},
+		{"unknown host", &net.DNSError{Name: "wallhaven.invalid", IsNotFound: true}, KindDNS, false},
+		{"resolver down", &net.DNSError{Name: "wallhaven.cc", IsTemporary: true}, KindDNS, true},
+		{"untrusted certificate", tlsErr, KindTLS, false},
+		{"no results", fmt.Errorf("fetch background: %w: no usable image for 1x1", ErrNoResults), KindNoResults, false},
+		{"decode", decodeErr, KindDecode, false},
+		{"download too large", fmt.Errorf("fetch background: %w", fmt.Errorf("bucket: download: %w: larger than 4 bytes", bucket.ErrTooLarge)), KindDecode, false},
+		{"stdin too large", fmt.Errorf("read background: %w: standard input is larger than 4 bytes", ErrTooLarge), KindDecode, false},
+	} {
+		err := ClassifyFetchError(tc.err)
+		var fetchErr *FetchError
+		if !errors.As(err, &fetchErr) {
+			t.Fatalf("%s: %v was not classified", tc.name, tc.err)
+		}
+		if fetchErr.Kind != tc.kind || fetchErr.IsRetryable() != tc.retryable || fetchErr.Hint() == "" {
+			t.Fatalf("%s: got kind %s, retryable %v, hint %q; want %s, %v", tc.name, fetchErr.Kind, fetchErr.IsRetryable(), fetchErr.Hint(), tc.kind, tc.retryable)
+		}
+		if !errors.Is(err, tc.err) || err.Error() != tc.err.Error() {
+			t.Fatalf("%s: classification changed the error: %v", tc.name, err)
+		}
+		if ClassifyFetchError(err) != err {
+			t.Fatalf("%s: classifying twice wrapped again", tc.name)
+		}
+	}
+
+	plain := errors.New("read theme: no such file")
+	if ClassifyFetchError(plain) != plain || ClassifyFetchError(nil) != nil {
+		t.Fatalf("unrelated errors must pass through unchanged")
+	}
+}
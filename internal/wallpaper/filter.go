@@ -0,0 +1,76 @@
+package wallpaper
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	stddraw "image/draw"
+	"image/png"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Hook points of the render pipeline where Filters run.
+const (
+	HookPreComposite  = "pre-composite"
+	HookPostComposite = "post-composite"
+)
+
+// Filter transforms an image of the render pipeline in place, e.g. to paint a brand gradient or a seasonal overlay
+// that a theme cannot express. It must keep to the bounds of img and must not retain it.
+type Filter func(img *image.RGBA) error
+
+// Filters are user-supplied transformations that Options runs in order at the hook points of the render pipeline.
+type Filters struct {
+	// PreComposite transforms the background after it is scaled to the output and treated by the theme, before the
+	// panel, text, badge, and watermark are drawn over it. Scenes without a background layer skip it.
+	PreComposite []Filter
+	// PostComposite transforms the composed image before film grain; animations run it on every frame.
+	PostComposite []Filter
+}
+
+// runFilters runs filters on img in order and names the hook and the failing filter in its error.
+func runFilters(hook string, filters []Filter, img *image.RGBA) error {
+	for i, f := range filters {
+		if err := f(img); err != nil {
+			return fmt.Errorf("render: %s filter %d: %w", hook, i+1, err)
+		}
+	}
+	return nil
+}
+
+// CommandFilter returns a Filter that runs an external program, such as a script or a WebAssembly runtime with a
+// module, for the given hook. The program reads the image as PNG from standard input and writes the transformed
+// image of the same size as PNG to standard output. It finds the hook and the image size in the environment
+// variables TSSH_HOOK, TSSH_WIDTH, and TSSH_HEIGHT. The filter returns an error if the program fails or its output
+// is not a PNG of that size.
+func CommandFilter(hook string, argv []string) Filter {
+	return func(img *image.RGBA) error {
+		if len(argv) == 0 {
+			return fmt.Errorf("empty command")
+		}
+		var stdin bytes.Buffer
+		if err := png.Encode(&stdin, img); err != nil {
+			return fmt.Errorf("%s: encode: %w", argv[0], err)
+		}
+		size := img.Bounds().Size()
+		cmd := exec.Command(argv[0], argv[1:]...)
+		cmd.Env = append(os.Environ(), "TSSH_HOOK="+hook, "TSSH_WIDTH="+strconv.Itoa(size.X), "TSSH_HEIGHT="+strconv.Itoa(size.Y))
+		var stdout, stderr bytes.Buffer
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = &stdin, &stdout, &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s: %w: %s", argv[0], err, strings.TrimSpace(stderr.String()))
+		}
+		out, err := png.Decode(&stdout)
+		if err != nil {
+			return fmt.Errorf("%s: decode output: %w", argv[0], err)
+		}
+		if got := out.Bounds().Size(); got != size {
+			return fmt.Errorf("%s: output is %dx%d, want %dx%d", argv[0], got.X, got.Y, size.X, size.Y)
+		}
+		stddraw.Draw(img, img.Bounds(), out, out.Bounds().Min, stddraw.Src)
+		return nil
+	}
+}
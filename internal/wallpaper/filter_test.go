@@ -0,0 +1,102 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// fillFilter returns a Filter that paints the whole image in c and counts its calls.
+func fillFilter(c color.RGBA, calls *int) Filter {
+	return func(img *image.RGBA) error {
+		*calls++
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+		return nil
+	}
+}
+
+// TestFilters_RunAtHookPoints expects pre-composite filters to replace the background under the panel and text, and
+// post-composite filters to see the finished image, once per render, animation frame, and spanning canvas, and a
+// failing filter to name its hook. The test fails if a filter runs at the wrong point, too often, or its error is
+// lost.
+func TestFilters_RunAtHookPoints(t *testing.T) {
+	const w, h = 640, 360
+	bg := solidBG(w, h, color.RGBA{20, 30, 40, 255})
+	red := color.RGBA{255, 0, 0, 255}
+
+	var pre int
+	opts := Options{Width: w, Height: h, Filters: Filters{PreComposite: []Filter{fillFilter(red, &pre)}}}
+	img, l, err := RenderWithLayout(bg, "TSSH lab", "2026-01-04", opts)
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	if pre != 1 || img.RGBAAt(1, 1) != red {
+		t.Fatalf("pre-composite ran %d times, corner %v", pre, img.RGBAAt(1, 1))
+	}
+	if inBox := img.RGBAAt((l.BoxX0+l.BoxX1)/2, l.BoxY0+2); inBox == red {
+		t.Fatal("expected the panel to be drawn over the pre-composite background")
+	}
+
+	var post int
+	opts = Options{Width: w, Height: h, Filters: Filters{PostComposite: []Filter{fillFilter(red, &post)}}}
+	img, l, err = RenderWithLayout(bg, "TSSH lab", "2026-01-04", opts)
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	if post != 1 || img.RGBAAt((l.BoxX0+l.BoxX1)/2, l.BoxY0+2) != red {
+		t.Fatalf("post-composite ran %d times and left the panel", post)
+	}
+
+	pre, post = 0, 0
+	opts.Filters.PreComposite = []Filter{fillFilter(red, &pre)}
+	if _, err := RenderFrames(bg, "TSSH lab", "2026-01-04", opts, Animation{Frames: 4}, func(int, *image.RGBA) error { return nil }); err != nil {
+		t.Fatalf("RenderFrames error: %v", err)
+	}
+	if pre != 1 || post != 4 {
+		t.Fatalf("animation ran pre-composite %d and post-composite %d times, want 1 and 4", pre, post)
+	}
+
+	pre, post = 0, 0
+	monitors := []Monitor{{Width: w, Height: h}, {X: w, Width: w, Height: h}}
+	canvas, _, err := RenderSpan(bg, "TSSH lab", "2026-01-04", opts, monitors, 0)
+	if err != nil {
+		t.Fatalf("RenderSpan error: %v", err)
+	}
+	if pre != 1 || post != 1 || canvas.RGBAAt(2*w-1, h-1) != red {
+		t.Fatalf("spanning ran pre-composite %d and post-composite %d times", pre, post)
+	}
+
+	opts = Options{Width: w, Height: h, Filters: Filters{PostComposite: []Filter{CommandFilter(HookPostComposite, []string{"false"})}}}
+	if _, err := Render(bg, "TSSH lab", "2026-01-04", opts); err == nil || !strings.Contains(err.Error(), "post-composite filter 1: false") {
+		t.Fatalf("expected the failing command to be named, got %v", err)
+	}
+}
+
+// TestCommandFilter_RoundTripsThroughProgram expects a program that copies its input to leave the image unchanged,
+// programs to find the hook and size in the environment, and output that is not a PNG to be rejected. The test fails
+// if a pixel changes, the environment is missing, or bad output is accepted.
+func TestCommandFilter_RoundTripsThroughProgram(t *testing.T) {
+	img := solidBG(7, 5, color.RGBA{10, 200, 30, 255}).(*image.RGBA)
+	img.SetRGBA(3, 2, color.RGBA{1, 2, 3, 255})
+	want := append([]uint8(nil), img.Pix...)
+	if err := CommandFilter(HookPreComposite, []string{"cat"})(img); err != nil {
+		t.Fatalf("cat filter error: %v", err)
+	}
+	if string(img.Pix) != string(want) {
+		t.Fatal("expected cat to leave the image unchanged")
+	}
+	for _, argv := range [][]string{
+		{"sh", "-c", "head -c 20"},
+		{"sh", "-c", `test "$TSSH_HOOK" = pre-composite && test "$TSSH_WIDTH" = 7`},
+	} {
+		if err := CommandFilter(HookPreComposite, argv)(img); err == nil || !strings.Contains(err.Error(), "decode output") {
+			t.Fatalf("%q: expected undecodable output to be rejected, got %v", argv, err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// Fit selects how a background whose aspect ratio differs a lot from the canvas's is fitted to it.
+type Fit string
+
+const (
+	// FitCrop scales the background to cover the canvas and crops it as Options.Crop selects; this matches the
+	// historical output.
+	FitCrop Fit = "crop"
+	// FitExtendBlur shows the whole background and fills the bars with a blurred copy stretched to cover the canvas.
+	FitExtendBlur Fit = "extend-blur"
+	// FitExtendMirror shows the whole background and fills the bars with its edges mirrored, which continues
+	// textures such as sky, sand, or water without a seam.
+	FitExtendMirror Fit = "extend-mirror"
+	// FitExtendColor shows the whole background and fills the bars with its dominant color.
+	FitExtendColor Fit = "extend-color"
+)
+
+// Fits returns all fit modes in a stable order for help output and validation.
+func Fits() []Fit {
+	return []Fit{FitCrop, FitExtendBlur, FitExtendMirror, FitExtendColor}
+}
+
+// ParseFit converts a user-supplied fit name into a Fit; empty selects FitCrop.
+func ParseFit(name string) (Fit, error) {
+	if name == "" {
+		return FitCrop, nil
+	}
+	for _, f := range Fits() {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	return FitCrop, fmt.Errorf("render: unknown fit %q", name)
+}
+
+const (
+	// extendRatio is how many times wider or taller in proportion than the canvas a background must be for the extend
+	// fits to apply; closer aspect ratios lose little to a crop and are cropped as usual.
+	extendRatio = 1.25
+	// blurCells is the longer side of the grid the blurred bars are stretched from; fewer cells blur more.
+	blurCells = 24
+)
+
+// extends reports whether f extends a background of bounds src instead of cropping it to width × height.
+func (f Fit) extends(src image.Rectangle, width, height int) bool {
+	if f == "" || f == FitCrop {
+		return false
+	}
+	r := float64(src.Dx()) * float64(height) / (float64(src.Dy()) * float64(width))
+	return r > extendRatio || r < 1/extendRatio
+}
+
+// extend scales src to fit inside width × height, sharpens it by amount if it was downscaled, centers it, and fills the
+// bars beside it as fit selects. The blur and color bars fade into the mirrored edge of the image over a few pixels, so
+// the seam does not show as a hard line.
+func extend(src image.Image, width, height int, fit Fit, amount float64) *image.RGBA {
+	b := src.Bounds()
+	scale := math.Min(float64(width)/float64(b.Dx()), float64(height)/float64(b.Dy()))
+	w := min(width, max(1, int(math.Round(float64(b.Dx())*scale))))
+	h := min(height, max(1, int(math.Round(float64(b.Dy())*scale))))
+	inner := image.Rect((width-w)/2, (height-h)/2, (width-w)/2+w, (height-h)/2+h)
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(out, inner, src, b, draw.Src, nil)
+	sharpen(out.SubImage(inner).(*image.RGBA), sharpenStrength(amount, scale))
+
+	var bars *image.RGBA
+	switch fit {
+	case FitExtendBlur:
+		bars = blurredCover(src, width, height)
+	case FitExtendColor:
+		bars = image.NewRGBA(out.Rect)
+		draw.Draw(bars, bars.Rect, image.NewUniform(dominantColor(src)), image.Point{}, draw.Src)
+	}
+	feather := max(2, min(width, height)/40)
+	for y := range height {
+		for x := range width {
+			if (image.Point{X: x, Y: y}).In(inner) {
+				continue
+			}
+			mirrored := out.Pix[out.PixOffset(mirrorIndex(x, inner.Min.X, inner.Max.X), mirrorIndex(y, inner.Min.Y, inner.Max.Y)):][:4]
+			dst := out.Pix[out.PixOffset(x, y):][:4]
+			if bars == nil {
+				copy(dst, mirrored)
+				continue
+			}
+			d := max(inner.Min.X-x, x-inner.Max.X+1, inner.Min.Y-y, y-inner.Max.Y+1)
+			weight := max(0, 1-float64(d)/float64(feather))
+			bar := bars.Pix[bars.PixOffset(x, y):][:4]
+			for i := range dst {
+				dst[i] = uint8(math.Round(weight*float64(mirrored[i]) + (1-weight)*float64(bar[i])))
+			}
+		}
+	}
+	return out
+}
+
+// mirrorIndex maps v onto [lo, hi) by mirroring at the bounds, repeatedly for bars wider than the range.
+func mirrorIndex(v, lo, hi int) int {
+	n := hi - lo
+	t := ((v-lo)%(2*n) + 2*n) % (2 * n)
+	if t >= n {
+		t = 2*n - 1 - t
+	}
+	return lo + t
+}
+
+// blurredCover returns the centered part of src with the canvas's aspect ratio, scaled down to a coarse grid and back
+// up to width × height, which blurs it without scaling the whole background up first.
+func blurredCover(src image.Image, width, height int) *image.RGBA {
+	b := src.Bounds()
+	crop := b
+	if b.Dx()*height > b.Dy()*width {
+		cw := max(1, b.Dy()*width/height)
+		crop.Min.X += (b.Dx() - cw) / 2
+		crop.Max.X = crop.Min.X + cw
+	} else {
+		ch := max(1, b.Dx()*height/width)
+		crop.Min.Y += (b.Dy() - ch) / 2
+		crop.Max.Y = crop.Min.Y + ch
+	}
+	scale := float64(blurCells) / float64(max(width, height))
+	small := image.NewRGBA(image.Rect(0, 0, max(1, int(math.Round(float64(width)*scale))), max(1, int(math.Round(float64(height)*scale)))))
+	draw.BiLinear.Scale(small, small.Rect, src, crop, draw.Src, nil)
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.BiLinear.Scale(out, out.Rect, small, small.Rect, draw.Src, nil)
+	return out
+}
+
+// dominantColor returns the mean of the most common color of src, with each channel quantized to 16 levels, measured
+// on a copy at most 64 pixels on its longer side.
+func dominantColor(src image.Image) color.RGBA {
+	b := src.Bounds()
+	scale := math.Min(1, 64/float64(max(b.Dx(), b.Dy())))
+	small := image.NewRGBA(image.Rect(0, 0, max(1, int(math.Round(float64(b.Dx())*scale))), max(1, int(math.Round(float64(b.Dy())*scale)))))
+	draw.BiLinear.Scale(small, small.Rect, src, b, draw.Src, nil)
+	var counts [4096]int
+	var sums [4096][4]int
+	best := 0
+	for i := 0; i < len(small.Pix); i += 4 {
+		p := small.Pix[i : i+4]
+		bucket := int(p[0]>>4)<<8 | int(p[1]>>4)<<4 | int(p[2]>>4)
+		counts[bucket]++
+		for c := range 4 {
+			sums[bucket][c] += int(p[c])
+		}
+		if counts[bucket] > counts[best] {
+			best = bucket
+		}
+	}
+	n := max(1, counts[best])
+	return color.RGBA{uint8(sums[best][0] / n), uint8(sums[best][1] / n), uint8(sums[best][2] / n), uint8(sums[best][3] / n)}
+}
@@ -0,0 +1,80 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// TestResizeAndCrop_ExtendFits expects the extend fits to show a much wider background whole, centered between bars
+// that mirror its edges, blur a stretched copy, or take its dominant color, and backgrounds of a close aspect ratio to
+// be cropped as before. A 400x100 background fits a 160x90 canvas as 160x40 at rows 25..64.
+// The test fails if an extend fit crops, leaves the bars empty, or fills them with the wrong content.
+func TestResizeAndCrop_ExtendFits(t *testing.T) {
+	const w, h = 160, 90
+	// Red on the left, green on the right, with a blue bottom row for the mirror to reflect.
+	bg := image.NewRGBA(image.Rect(0, 0, 400, 100))
+	for y := range 100 {
+		for x := range 400 {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= 200 {
+				c = color.RGBA{0, 255, 0, 255}
+			}
+			if y >= 90 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			bg.SetRGBA(x, y, c)
+		}
+	}
+	near := func(got, want color.RGBA) bool {
+		d := func(a, b uint8) int { return max(int(a)-int(b), int(b)-int(a)) }
+		return d(got.R, want.R) <= 24 && d(got.G, want.G) <= 24 && d(got.B, want.B) <= 24
+	}
+	red, green, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}, color.RGBA{0, 0, 255, 255}
+
+	for _, c := range []struct {
+		fit    Fit
+		checks map[image.Point]color.RGBA
+	}{
+		// The image rows are unchanged; the bottom bar mirrors the blue bottom rows, and further out the green rows above
+		// them, the top bar the red top rows.
+		{FitExtendMirror, map[image.Point]color.RGBA{{20, 45}: red, {20, 68}: blue, {140, 89}: green, {20, 2}: red}},
+		// The blurred cover shows the red half on the left and the green half on the right.
+		{FitExtendBlur, map[image.Point]color.RGBA{{20, 45}: red, {10, 2}: red, {150, 2}: green}},
+		// Red and green cover 90% of the background in equal shares; red comes first.
+		{FitExtendColor, map[image.Point]color.RGBA{{150, 45}: green, {150, 2}: red, {10, 87}: red}},
+	} {
+		out, err := resizeAndCrop(bg, w, h, cropRule{fit: c.fit})
+		if err != nil {
+			t.Fatalf("%s: %v", c.fit, err)
+		}
+		if out.Bounds() != image.Rect(0, 0, w, h) {
+			t.Fatalf("%s: bounds %v", c.fit, out.Bounds())
+		}
+		for p, want := range c.checks {
+			if got := out.RGBAAt(p.X, p.Y); !near(got, want) {
+				t.Fatalf("%s: pixel %v is %v, want about %v", c.fit, p, got, want)
+			}
+		}
+	}
+
+	// A 400x250 background is within extendRatio of 16:9, so it is cropped to cover whatever the fit.
+	tall := image.NewRGBA(image.Rect(0, 0, 400, 250))
+	for i := range tall.Pix {
+		tall.Pix[i] = 200
+	}
+	out, err := resizeAndCrop(tall, w, h, cropRule{fit: FitExtendColor})
+	if err != nil {
+		t.Fatalf("close aspect ratio: %v", err)
+	}
+	if got := out.RGBAAt(80, 0); got != (color.RGBA{200, 200, 200, 200}) {
+		t.Fatalf("close aspect ratio was extended: top pixel %v", got)
+	}
+	// A strip too extreme to crop can be extended.
+	if _, err := resizeAndCrop(image.NewRGBA(image.Rect(0, 0, 1, 10_000)), w, h, cropRule{fit: FitExtendMirror}); err != nil {
+		t.Fatalf("extending a 1x10000 strip: %v", err)
+	}
+	if _, err := ParseFit("stretch"); err == nil {
+		t.Fatalf("expected error for unknown fit")
+	}
+}
@@ -0,0 +1,130 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	stddraw "image/draw"
+	"math"
+
+	"github.com/nickhildebrandt/ts-release/internal/diag"
+)
+
+// Animation configures the boot splash frame sequence rendered by RenderFrames.
+// The overlay (box, text, badge, and watermark) fades in over the background, then a soft highlight sweeps across
+// it; the sweep frames form a seamless loop.
+type Animation struct {
+	// Frames is the total number of frames, at least 2.
+	Frames int
+}
+
+const (
+	// fadeFraction is the share of frames spent fading the overlay in.
+	fadeFraction = 0.4
+	// shimmerStrength is the peak opacity of the white highlight on fully opaque overlay pixels.
+	shimmerStrength = 0.18
+	// shimmerWidth is the highlight's half-width relative to the image width.
+	shimmerWidth = 0.06
+	// shimmerSlant shifts the highlight horizontally per row, relative to the row index, so it sweeps diagonally.
+	shimmerSlant = 0.35
+)
+
+// LoopStart returns the index of the first looping frame; earlier frames fade the overlay in and play once.
+func (a Animation) LoopStart() int {
+	return max(1, min(a.Frames-1, int(math.Round(float64(a.Frames)*fadeFraction))))
+}
+
+// RenderFrames renders the boot splash animation for the same inputs as Render and passes each frame to emit in
+// order. Frames are rendered one at a time and reuse the same image, so emit must not retain it.
+// It returns the layout and the errors of Render, an error for fewer than 2 frames, and any error from emit.
+func RenderFrames(bg image.Image, targetName string, buildID string, opts Options, anim Animation, emit func(index int, frame *image.RGBA) error) (_ Layout, err error) {
+	defer diag.Recover("render", &err)
+	if bg == nil {
+		return Layout{}, fmt.Errorf("render: background is nil")
+	}
+	if anim.Frames < 2 {
+		return Layout{}, fmt.Errorf("render: animation needs at least 2 frames, got %d", anim.Frames)
+	}
+	scene, layout, err := prepareScene(targetName, buildID, opts)
+	if err != nil {
+		return Layout{}, err
+	}
+
+	// The background layers form the static base; everything else, including badge and watermark, is the overlay.
+	base := Scene{Width: scene.Width, Height: scene.Height}
+	over := base
+	for _, l := range scene.Layers {
+		if l.Type == LayerBackground {
+			base.Layers = append(base.Layers, l)
+		} else {
+			over.Layers = append(over.Layers, l)
+		}
+	}
+	rect := image.Rect(0, 0, layout.Width, layout.Height)
+	baseImg := image.NewRGBA(rect)
+	baseOpts := opts
+	baseOpts.Channel, baseOpts.Watermark = ChannelNone, Watermark{}
+	if err := composeScene(baseImg, bg, base, layout, baseOpts); err != nil {
+		return Layout{}, err
+	}
+	overlay := image.NewRGBA(rect)
+	if err := composeScene(overlay, bg, over, layout, opts); err != nil {
+		return Layout{}, err
+	}
+
+	frame := image.NewRGBA(rect)
+	shimmer := image.NewAlpha(rect)
+	white := image.NewUniform(color.White)
+	loopStart := anim.LoopStart()
+	for i := 0; i < anim.Frames; i++ {
+		copy(frame.Pix, baseImg.Pix)
+		if i < loopStart {
+			opacity := smoothstep(float64(i+1) / float64(loopStart))
+			mask := image.NewUniform(color.Alpha{A: uint8(math.Round(opacity * 255))})
+			stddraw.DrawMask(frame, rect, overlay, image.Point{}, mask, image.Point{}, stddraw.Over)
+		} else {
+			stddraw.Draw(frame, rect, overlay, image.Point{}, stddraw.Over)
+			progress := float64(i-loopStart) / float64(anim.Frames-loopStart)
+			shimmerMask(shimmer, overlay, progress)
+			stddraw.DrawMask(frame, rect, white, image.Point{}, shimmer, image.Point{}, stddraw.Over)
+		}
+		if err := runFilters(HookPostComposite, opts.Filters.PostComposite, frame); err != nil {
+			return Layout{}, err
+		}
+		// The grain is the same in every frame, like the background it hides banding in.
+		if err := applyGrain(frame, opts.Grain); err != nil {
+			return Layout{}, err
+		}
+		if err := emit(i, frame); err != nil {
+			return Layout{}, err
+		}
+	}
+	return layout, nil
+}
+
+// shimmerMask fills mask with a diagonal highlight band at progress in [0, 1), weighted by the overlay's opacity so
+// the highlight only brightens the overlay. The band starts and ends fully outside the image, so the loop is seamless.
+func shimmerMask(mask *image.Alpha, overlay *image.RGBA, progress float64) {
+	b := mask.Bounds()
+	w, h := float64(b.Dx()), float64(b.Dy())
+	halfWidth := shimmerWidth * w
+	// The band's diagonal coordinate u = x + slant*y ranges over [0, w + slant*h]; start and end beyond it.
+	span := w + shimmerSlant*h + 4*halfWidth
+	center := -2*halfWidth + progress*span
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			d := (float64(x) + shimmerSlant*float64(y) - center) / halfWidth
+			v := 0.0
+			if d > -2 && d < 2 {
+				v = shimmerStrength * math.Exp(-d*d) * float64(overlay.Pix[overlay.PixOffset(x, y)+3])
+			}
+			mask.Pix[mask.PixOffset(x, y)] = uint8(v + 0.5)
+		}
+	}
+}
+
+// smoothstep eases t in [0, 1] in and out.
+func smoothstep(t float64) float64 {
+	t = math.Max(0, math.Min(1, t))
+	return t * t * (3 - 2*t)
+}
@@ -0,0 +1,81 @@
+package wallpaper
+
+import (
+	"image"
+	"strings"
+	"testing"
+)
+
+// TestRenderFrames_FadeThenShimmer expects the overlay to fade in until it matches the static render at the loop
+// start, and the looping frames to brighten only pixels covered by the overlay.
+// The test fails if frames are missing, out of order, or the animation drifts from the static wallpaper.
+func TestRenderFrames_FadeThenShimmer(t *testing.T) {
+	bg := goldenBackground(80, 45, goldenSeed)
+	opts := Options{Width: 320, Height: 180, Channel: ChannelBeta}
+	static, err := Render(bg, "golden", "build 42", opts)
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	plain, err := Render(bg, "golden", "build 42", Options{Width: 320, Height: 180, Scene: &Scene{Layers: []Layer{{Type: LayerBackground}}}})
+	if err != nil {
+		t.Fatalf("Render background error: %v", err)
+	}
+
+	anim := Animation{Frames: 10}
+	loopStart := anim.LoopStart()
+	var frames []*image.RGBA
+	if _, err := RenderFrames(bg, "golden", "build 42", opts, anim, func(i int, frame *image.RGBA) error {
+		if i != len(frames) {
+			t.Fatalf("frame %d emitted out of order", i)
+		}
+		frames = append(frames, image.NewRGBA(frame.Rect))
+		copy(frames[i].Pix, frame.Pix)
+		return nil
+	}); err != nil {
+		t.Fatalf("RenderFrames error: %v", err)
+	}
+	if len(frames) != anim.Frames || loopStart != 4 {
+		t.Fatalf("got %d frames with loop start %d, want %d and 4", len(frames), loopStart, anim.Frames)
+	}
+
+	// diff returns the largest channel difference and the number of differing pixels.
+	diff := func(a, b *image.RGBA) (maxDelta, pixels int) {
+		for i := 0; i < len(a.Pix); i += 4 {
+			changed := false
+			for c := 0; c < 3; c++ {
+				d := int(a.Pix[i+c]) - int(b.Pix[i+c])
+				d = max(d, -d)
+				maxDelta = max(maxDelta, d)
+				changed = changed || d > 0
+			}
+			if changed {
+				pixels++
+			}
+		}
+		return maxDelta, pixels
+	}
+
+	_, early := diff(frames[0], static)
+	if early == 0 {
+		t.Fatalf("first frame already shows the full overlay")
+	}
+	if d, _ := diff(frames[loopStart-1], static); d > 2 {
+		t.Fatalf("last fade frame differs from the static render by %d", d)
+	}
+	for i := loopStart; i < anim.Frames; i++ {
+		for p := 0; p < len(static.Pix); p += 4 {
+			// Pixels the overlay does not cover keep the plain background.
+			if static.Pix[p] == plain.Pix[p] && static.Pix[p+1] == plain.Pix[p+1] && static.Pix[p+2] == plain.Pix[p+2] &&
+				frames[i].Pix[p] != static.Pix[p] {
+				t.Fatalf("frame %d: shimmer changed an uncovered pixel", i)
+			}
+		}
+	}
+	if _, moved := diff(frames[loopStart+2], frames[loopStart+3]); moved == 0 {
+		t.Fatalf("shimmer does not move between loop frames")
+	}
+
+	if _, err := RenderFrames(bg, "golden", "", opts, Animation{Frames: 1}, nil); err == nil || !strings.Contains(err.Error(), "at least 2 frames") {
+		t.Fatalf("expected frame count error, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package wallpaper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/nickhildebrandt/ts-release/internal/diag"
+)
+
+// fuzzSeeds returns small valid JPEG, PNG, and GIF files, truncated copies of them, and a PNG header that claims
+// more than DefaultMaxPixels, as the corpus of the decode fuzzers.
+func fuzzSeeds(t testing.TB) [][]byte {
+	img := image.NewRGBA(image.Rect(0, 0, 12, 7))
+	for y := range 7 {
+		for x := range 12 {
+			img.Set(x, y, color.RGBA{uint8(x * 20), uint8(y * 30), 128, 255})
+		}
+	}
+	var jpg, pngData, gifData bytes.Buffer
+	if err := jpeg.Encode(&jpg, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(&pngData, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := gif.Encode(&gifData, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	seeds := [][]byte{jpg.Bytes(), pngData.Bytes(), gifData.Bytes(), hugePNGHeader(100_000, 100_000)}
+	for _, data := range seeds[:3] {
+		seeds = append(seeds, data[:len(data)/2], data[:len(data)-3])
+	}
+	return seeds
+}
+
+// hugePNGHeader returns a PNG signature and IHDR chunk for an 8-bit RGBA image of the given size, without pixels.
+func hugePNGHeader(width, height uint32) []byte {
+	ihdr := binary.BigEndian.AppendUint32([]byte("IHDR"), width)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, height)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0)
+	data := binary.BigEndian.AppendUint32([]byte("\x89PNG\r\n\x1a\n"), uint32(len(ihdr)-4))
+	data = append(data, ihdr...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+}
+
+// fuzzLimits bound the images of the decode fuzzers, so they also exercise the checks and stay fast; the middle frame
+// of animated GIFs takes them through frame counting and composition.
+var fuzzLimits = Limits{MaxPixels: 1 << 20, MaxDimension: 4096, Animated: AnimatedMiddle}
+
+// TestDecodeSRGB_EnforcesLimits expects headers that claim more pixels or a larger dimension than the limits to fail
+// with a *TooLargeError wrapping ErrDecode before any pixel is decoded, and the zero Limits to allow DefaultMaxPixels.
+// The test fails if an image beyond the limits decodes, an error is not typed, or a 1×10000 strip is scaled up.
+func TestDecodeSRGB_EnforcesLimits(t *testing.T) {
+	cases := []struct {
+		name          string
+		width, height uint32
+		limits        Limits
+		want          string
+	}{
+		{"default pixels", 20_000, 20_000, Limits{}, "20000x20000 image has more than 100000000 pixels"},
+		{"pixels", 2_000, 1_000, Limits{MaxPixels: 1_000_000}, "2000x1000 image has more than 1000000 pixels"},
+		{"dimension", 9_000, 100, Limits{MaxDimension: 8_192}, "9000x100 image exceeds the maximum dimension of 8192 pixels"},
+	}
+	for _, c := range cases {
+		_, err := decodeSRGB(hugePNGHeader(c.width, c.height), c.limits)
+		var tooLarge *TooLargeError
+		if !errors.As(err, &tooLarge) || !errors.Is(err, ErrDecode) || err.Error() != "decode failed: "+c.want {
+			t.Fatalf("%s: expected a *TooLargeError %q, got %v", c.name, c.want, err)
+		}
+		if tooLarge.Width != int(c.width) || tooLarge.Height != int(c.height) {
+			t.Fatalf("%s: error reports %dx%d", c.name, tooLarge.Width, tooLarge.Height)
+		}
+	}
+	// Within the limits, the header alone passes the check and the missing pixels fail to decode.
+	if _, err := decodeSRGB(hugePNGHeader(100, 100), fuzzLimits); err == nil || errors.As(err, new(*TooLargeError)) {
+		t.Fatalf("expected a plain decode error for a small header without pixels, got %v", err)
+	}
+	if _, err := resizeAndCrop(image.NewRGBA(image.Rect(0, 0, 1, 10_000)), TargetWidth, TargetHeight, cropRule{}); err == nil {
+		t.Fatalf("expected a 1x10000 strip to be rejected instead of scaled to cover %dx%d", TargetWidth, TargetHeight)
+	}
+}
+
+// TestDecodeSRGB_UncheckedHeader_ErrDecode expects data whose header does not decode to fail with ErrDecode without
+// reaching the pixel decoder, since its size cannot be checked against the limits. The test fails if the pixels are
+// decoded or the error does not wrap ErrDecode.
+func TestDecodeSRGB_UncheckedHeader_ErrDecode(t *testing.T) {
+	decoded := false
+	image.RegisterFormat("tsnoconfig", "TSNOCONFIG", func(io.Reader) (image.Image, error) {
+		decoded = true
+		return image.NewRGBA(image.Rect(0, 0, 20_000, 20_000)), nil
+	}, func(io.Reader) (image.Config, error) { return image.Config{}, errors.New("truncated header") })
+	_, err := decodeSRGB([]byte("TSNOCONFIG"), fuzzLimits)
+	if !errors.Is(err, ErrDecode) || !strings.Contains(err.Error(), "truncated header") {
+		t.Fatalf("expected ErrDecode with the header error, got %v", err)
+	}
+	if decoded {
+		t.Fatalf("decoded the pixels of an image whose size was not checked")
+	}
+}
+
+// FuzzDecodeSRGB feeds corrupted JPEG, PNG, and GIF data through the decoder of every downloaded background. It
+// fails if a decoder panics, which decodeSRGB would turn into an error, or an image beyond the limits decodes.
+func FuzzDecodeSRGB(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		img, err := decodeSRGB(data, fuzzLimits)
+		var panicErr *diag.PanicError
+		if errors.As(err, &panicErr) {
+			t.Fatalf("decoder panicked: %v\n%s", panicErr.Value, panicErr.Stack)
+		}
+		if err != nil {
+			if !errors.Is(err, ErrDecode) && !strings.Contains(err.Error(), "color profile") {
+				t.Fatalf("error is neither a decode nor a color profile error: %v", err)
+			}
+			return
+		}
+		if b := img.Bounds(); fuzzLimits.check(b.Dx(), b.Dy()) != nil {
+			t.Fatalf("decoded %v beyond %+v", b, fuzzLimits)
+		}
+	})
+}
+
+// FuzzResizeAndCrop scales decodable fuzzed images to small targets of fuzzed sizes with each fit, cropping them with
+// the rule of thirds around a quiet rectangle. It fails if scaling, cropping, or extending panics or the result does
+// not have the target size.
+func FuzzResizeAndCrop(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed, uint8(64), uint8(36))
+	}
+	f.Fuzz(func(t *testing.T, data []byte, width, height uint8) {
+		img, err := decodeSRGB(data, fuzzLimits)
+		if err != nil {
+			return
+		}
+		w, h := int(width)%96+1, int(height)%96+1
+		fit := Fits()[(w+h)%len(Fits())]
+		out, err := resizeAndCrop(img, w, h, cropRule{fit: fit, mode: CropThirds, quiet: []image.Rectangle{image.Rect(w/4, h/4, w*3/4, h*3/4)}, sharpen: MaxSharpen})
+		if err != nil {
+			return
+		}
+		if b := out.Bounds(); b.Dx() != w || b.Dy() != h {
+			t.Fatalf("resizeAndCrop to %dx%d returned %v", w, h, b)
+		}
+	})
+}
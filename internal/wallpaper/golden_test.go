@@ -0,0 +1,166 @@
+package wallpaper
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nickhildebrandt/ts-release/internal/imagediff"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden images in testdata/golden instead of comparing against them")
+
+// goldenMaxDiffRatio is the fraction of pixels allowed to exceed the perceptual threshold, absorbing rasterizer
+// rounding differences between architectures without hiding moved or missing text.
+const goldenMaxDiffRatio = 0.001
+
+// goldenSeed fixes the procedural background so golden images never depend on the network or the clock.
+const goldenSeed = 3593
+
+// checkGolden compares img against testdata/golden/<name>.png, or rewrites that file when -update is set.
+// The test fails on a size mismatch or when more than goldenMaxDiffRatio of the pixels differ perceptually;
+// the actual image is then written next to the golden file as <name>.actual.png for inspection.
+func checkGolden(t *testing.T, name string, img *image.RGBA) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name+".png")
+
+	if *updateGolden {
+		writeGoldenPNG(t, path, img)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open golden file (run go test -update to create it): %v", err)
+	}
+	defer f.Close()
+	want, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("decode golden file: %v", err)
+	}
+
+	res, err := imagediff.Compare(img, want, imagediff.Options{})
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if res.Ratio() > goldenMaxDiffRatio {
+		actual := filepath.Join("testdata", "golden", name+".actual.png")
+		writeGoldenPNG(t, actual, img)
+		t.Fatalf("%s: %d pixels (%.3f%%) differ from golden image, max delta %.3f; actual image: %s; run go test -update if the change is intended",
+			name, res.DiffPixels, 100*res.Ratio(), res.MaxDelta, actual)
+	}
+}
+
+// writeGoldenPNG encodes img to path, creating parent directories as needed.
+func writeGoldenPNG(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("create golden dir: %v", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create golden file: %v", err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatalf("encode golden file: %v", err)
+	}
+}
+
+// goldenBackground draws a smooth, seeded landscape-like background: a vertical gradient with soft color blobs.
+// Smooth content keeps golden PNGs small while still exercising scaling and overlay blending.
+func goldenBackground(w, h int, seed uint64) *image.RGBA {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	type blob struct {
+		x, y, r float64
+		c       [3]float64
+	}
+	blobs := make([]blob, 6)
+	for i := range blobs {
+		blobs[i] = blob{
+			x: rng.Float64() * float64(w),
+			y: rng.Float64() * float64(h),
+			r: (0.15 + 0.25*rng.Float64()) * float64(w),
+			c: [3]float64{rng.Float64() * 255, rng.Float64() * 255, rng.Float64() * 255},
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		t := float64(y) / float64(h)
+		for x := 0; x < w; x++ {
+			c := [3]float64{40 + 60*t, 90 + 40*t, 150 - 70*t}
+			for _, b := range blobs {
+				d := math.Hypot(float64(x)-b.x, float64(y)-b.y) / b.r
+				k := math.Exp(-d * d)
+				for i := range c {
+					c[i] = c[i]*(1-k) + b.c[i]*k
+				}
+			}
+			img.SetRGBA(x, y, color.RGBA{R: uint8(c[0]), G: uint8(c[1]), B: uint8(c[2]), A: 255})
+		}
+	}
+	return img
+}
+
+// TestGolden_Render compares full renders at several resolutions, decoration styles, and layouts against golden images.
+// The test fails on visual regressions in layout, typography, badge, watermark, or RTL handling;
+// run with -update to accept intended changes.
+func TestGolden_Render(t *testing.T) {
+	cases := []struct {
+		name    string
+		target  string
+		buildID string
+		opts    Options
+	}{
+		{name: "render_640x360_plain", target: "golden", buildID: "2026-01-04", opts: Options{Width: 640, Height: 360}},
+		{name: "render_1280x720_plain", target: "golden", buildID: "2026-01-04", opts: Options{Width: 1280, Height: 720}},
+		{name: "render_1280x1024_plain", target: "golden", buildID: "2026-01-04", opts: Options{Width: 1280, Height: 1024}},
+		{name: "render_640x360_nightly_watermark", target: "golden", buildID: "v2.3.1 (a1b2c3d)", opts: Options{
+			Width: 640, Height: 360, Channel: ChannelNightly,
+			Watermark: Watermark{Text: "INTERNAL", Opacity: 0.15, Angle: DefaultWatermarkAngle},
+		}},
+		{name: "render_640x360_rtl", target: "مرحبا-lab", buildID: "v2.3.1 (a1b2c3d)", opts: Options{Width: 640, Height: 360, Channel: ChannelBeta}},
+		{name: "render_640x360_hinting_full", target: "AVATAR To", buildID: "build 42", opts: Options{Width: 640, Height: 360, Hinting: HintingFull}},
+		{name: "render_640x360_optical_grid", target: "GOLDEN", buildID: "BUILD 42", opts: Options{Width: 640, Height: 360, Theme: Theme{VerticalAlign: VerticalAlignOptical, BaselineGrid: 48}}},
+		{name: "render_640x360_tracking_smallcaps", target: "golden", buildID: "build 42", opts: Options{Width: 640, Height: 360, Theme: Theme{
+			Title:    TextStyle{Tracking: 0.08, Transform: TextTransformUppercase},
+			Subtitle: TextStyle{Tracking: 0.05, Transform: TextTransformSmallCaps},
+		}}},
+		{name: "render_640x360_gradient_stroke", target: "golden", buildID: "build 42", opts: Options{Width: 640, Height: 360, Theme: Theme{
+			Title: TextStyle{
+				Gradient: &Gradient{Angle: 90, Stops: []GradientStop{{Offset: 0, Color: "#ffd86b"}, {Offset: 1, Color: "#ff6a3d"}}},
+				Stroke:   &Stroke{Width: 8},
+			},
+		}}},
+		{name: "render_640x360_background_treatment", target: "golden", buildID: "build 42", opts: Options{Width: 640, Height: 360, Theme: Theme{
+			Background: BackgroundTreatment{Vignette: 0.6, DarkenBottom: 0.4, Desaturate: 50, Brightness: -0.05, Contrast: 0.1},
+		}}},
+		{name: "render_640x360_hq_compositing", target: "golden", buildID: "build 42", opts: Options{Width: 640, Height: 360, Channel: ChannelBeta, HQCompositing: true}},
+		{name: "render_640x360_hq_dither_ordered", target: "golden", buildID: "build 42", opts: Options{Width: 640, Height: 360, HQCompositing: true, Dither: DitherOrdered}},
+		{name: "layout_bottom_bar", target: "golden", buildID: "v2.3.1 (a1b2c3d)", opts: Options{Width: 640, Height: 360, Layout: bottomBarLayout{}}},
+		{name: "layout_corner_card", target: "golden", buildID: "v2.3.1 (a1b2c3d)", opts: Options{Width: 640, Height: 360, Layout: cornerCardLayout{}}},
+		{name: "layout_corner_card_rtl", target: "مرحبا-lab", buildID: "v2.3.1 (a1b2c3d)", opts: Options{Width: 640, Height: 360, Channel: ChannelBeta, Layout: cornerCardLayout{}}},
+		{name: "layout_minimal", target: "golden", buildID: "2026-01-04", opts: Options{Width: 640, Height: 360, Layout: minimalLayout{}}},
+		{name: "layout_full_bleed", target: "golden", buildID: "2026-01-04", opts: Options{Width: 640, Height: 360, Channel: ChannelNightly, Layout: fullBleedLayout{}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w, h := c.opts.size()
+			img, err := Render(goldenBackground(w/2, h/2, goldenSeed), c.target, c.buildID, c.opts)
+			if err != nil {
+				t.Fatalf("Render error: %v", err)
+			}
+			if img.Bounds().Dx() != w || img.Bounds().Dy() != h {
+				t.Fatalf("unexpected size %v", img.Bounds())
+			}
+			checkGolden(t, c.name, img)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+)
+
+// Grain configures a subtle monochrome film grain added to the finished image. It breaks up the steps that JPEG
+// compression leaves in smooth dark areas such as the translucent panel. A zero Strength disables the grain.
+type Grain struct {
+	// Strength is the largest change of a pixel in 8-bit levels, in [0, MaxGrainStrength].
+	Strength float64
+	// Seed selects the noise pattern; equal seeds give equal grain, so builds stay reproducible.
+	Seed uint64
+}
+
+// MaxGrainStrength bounds Grain.Strength; stronger grain is visible as noise rather than hiding banding.
+const MaxGrainStrength = 16
+
+// grainCell returns the side of the square cells that share a noise value: one pixel up to TargetHeight, and a pixel
+// more per further TargetHeight, so the grain keeps its size relative to the image at higher resolutions.
+func grainCell(height int) int {
+	return max(1, height/TargetHeight)
+}
+
+// applyGrain adds the grain to every pixel of img. The noise depends only on the seed and the pixel position
+// relative to img's origin, not on the image content, and is the same on all three channels so it adds no color.
+// It returns an error for a strength out of range.
+func applyGrain(img *image.RGBA, g Grain) error {
+	if g.Strength < 0 || g.Strength > MaxGrainStrength {
+		return fmt.Errorf("render: grain strength %v out of range [0, %d]", g.Strength, MaxGrainStrength)
+	}
+	if g.Strength == 0 {
+		return nil
+	}
+	b := img.Bounds()
+	cell := grainCell(b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, y):]
+		for x := b.Min.X; x < b.Max.X; x++ {
+			p := row[4*(x-b.Min.X):]
+			a := int(p[3])
+			if a == 0 {
+				continue
+			}
+			// Premultiplied channels change in proportion to the pixel's alpha and stay within it.
+			d := int(grainNoise(g.Seed, (x-b.Min.X)/cell, (y-b.Min.Y)/cell) * g.Strength * float64(a) / 255)
+			for c := 0; c < 3; c++ {
+				p[c] = uint8(min(a, max(0, int(p[c])+d)))
+			}
+		}
+	}
+	return nil
+}
+
+// grainNoise returns a triangular-distributed value in (-1, 1) for cell (x, y), the sum of two uniform values hashed
+// from seed and the cell; it clusters near zero, so most pixels change little.
+func grainNoise(seed uint64, x, y int) float64 {
+	h := splitmix64(seed ^ splitmix64(uint64(uint32(x))<<32|uint64(uint32(y))))
+	u1 := float64(h>>40) / (1 << 24)
+	u2 := float64(h&(1<<24-1)) / (1 << 24)
+	return u1 + u2 - 1
+}
+
+// splitmix64 is the finalizer of the SplitMix64 generator, a fast hash with well-mixed output bits.
+func splitmix64(v uint64) uint64 {
+	v += 0x9e3779b97f4a7c15
+	v = (v ^ v>>30) * 0xbf58476d1ce4e5b9
+	v = (v ^ v>>27) * 0x94d049bb133111eb
+	return v ^ v>>31
+}
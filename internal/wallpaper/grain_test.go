@@ -0,0 +1,74 @@
+package wallpaper
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// TestApplyGrain_DeterministicPerSeed verifies that the grain depends only on the seed, stays within its strength, adds
+// no color, and averages out. The test fails if equal seeds differ, different seeds match, a pixel changes by more
+// than the strength or differently per channel, the mean shifts, or an out-of-range strength is accepted.
+func TestApplyGrain_DeterministicPerSeed(t *testing.T) {
+	gray := func() *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 40, G: 40, B: 40, A: 255}), image.Point{}, draw.Src)
+		return img
+	}
+	grained := func(g Grain) *image.RGBA {
+		img := gray()
+		if err := applyGrain(img, g); err != nil {
+			t.Fatalf("applyGrain error: %v", err)
+		}
+		return img
+	}
+
+	a, b := grained(Grain{Strength: 4, Seed: 7}), grained(Grain{Strength: 4, Seed: 7})
+	if !bytes.Equal(a.Pix, b.Pix) {
+		t.Fatal("expected equal grain for equal seeds")
+	}
+	if bytes.Equal(a.Pix, grained(Grain{Strength: 4, Seed: 8}).Pix) {
+		t.Fatal("expected different grain for different seeds")
+	}
+	if !bytes.Equal(grained(Grain{}).Pix, gray().Pix) {
+		t.Fatal("expected no change without strength")
+	}
+
+	sum, changed := 0, 0
+	for i := 0; i < len(a.Pix); i += 4 {
+		p := a.Pix[i : i+4]
+		if p[0] != p[1] || p[1] != p[2] || p[3] != 255 {
+			t.Fatalf("pixel %d = %v, want equal channels and opaque", i/4, p)
+		}
+		if d := int(p[0]) - 40; d < -4 || d > 4 {
+			t.Fatalf("pixel %d changed by %d, want at most 4", i/4, d)
+		} else if d != 0 {
+			changed++
+		}
+		sum += int(p[0])
+	}
+	n := len(a.Pix) / 4
+	if mean := float64(sum) / float64(n); mean < 39.8 || mean > 40.2 {
+		t.Fatalf("mean = %v, want about 40", mean)
+	}
+	if changed < n/2 {
+		t.Fatalf("only %d of %d pixels changed", changed, n)
+	}
+
+	for _, s := range []float64{-1, MaxGrainStrength + 1} {
+		if err := applyGrain(gray(), Grain{Strength: s}); err == nil {
+			t.Errorf("expected strength %v to be rejected", s)
+		}
+	}
+}
+
+// TestGrainCell_GrowsWithResolution verifies that the grain is per pixel up to TargetHeight and coarser above it.
+func TestGrainCell_GrowsWithResolution(t *testing.T) {
+	for height, want := range map[int]int{360: 1, TargetHeight: 1, 2 * TargetHeight: 2, 3*TargetHeight - 1: 2} {
+		if got := grainCell(height); got != want {
+			t.Errorf("grainCell(%d) = %d, want %d", height, got, want)
+		}
+	}
+}
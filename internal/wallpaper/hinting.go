@@ -0,0 +1,93 @@
+package wallpaper
+
+import (
+	"fmt"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// Hinting selects how glyph metrics and kerning are snapped to the pixel grid.
+type Hinting string
+
+const (
+	// HintingNone keeps fractional advances and kerning; this matches the historical output.
+	HintingNone     Hinting = "none"
+	HintingVertical Hinting = "vertical"
+	HintingFull     Hinting = "full"
+)
+
+// Hintings returns all selectable hinting modes in a stable order for help output and validation.
+func Hintings() []Hinting {
+	return []Hinting{HintingNone, HintingVertical, HintingFull}
+}
+
+// ParseHinting converts a user-supplied hinting name into a Hinting.
+// An empty name selects HintingNone; unknown names return an error.
+func ParseHinting(name string) (Hinting, error) {
+	if name == "" {
+		return HintingNone, nil
+	}
+	for _, h := range Hintings() {
+		if string(h) == name {
+			return h, nil
+		}
+	}
+	return HintingNone, fmt.Errorf("render: unknown hinting %q", name)
+}
+
+// fontHinting maps h to the golang.org/x/image/font constant; the zero value means no hinting.
+func (h Hinting) fontHinting() font.Hinting {
+	switch h {
+	case HintingVertical:
+		return font.HintingVertical
+	case HintingFull:
+		return font.HintingFull
+	default:
+		return font.HintingNone
+	}
+}
+
+// kernedFace wraps an opentype face and scales kerning to the face size.
+// opentype.Face.Kern passes units-per-em as the ppem, so GPOS and kern table values come back in font units/64
+// instead of pixels, which makes kerning all but disappear at display sizes.
+type kernedFace struct {
+	font.Face
+	parsed  *sfnt.Font
+	ppem    fixed.Int26_6
+	hinting font.Hinting
+	buf     sfnt.Buffer
+}
+
+// newFace constructs a face for parsed at size pixels (72 DPI) with correctly scaled kerning.
+func newFace(parsed *sfnt.Font, size float64, hinting font.Hinting) (*kernedFace, error) {
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: hinting})
+	if err != nil {
+		return nil, err
+	}
+	return &kernedFace{
+		Face:    face,
+		parsed:  parsed,
+		ppem:    fixed.Int26_6(size*64 + 0.5),
+		hinting: hinting,
+	}, nil
+}
+
+// Kern implements font.Face using the font size as ppem. Missing pairs and lookup errors kern by zero.
+func (f *kernedFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	x0, err := f.parsed.GlyphIndex(&f.buf, r0)
+	if err != nil {
+		return 0
+	}
+	x1, err := f.parsed.GlyphIndex(&f.buf, r1)
+	if err != nil {
+		return 0
+	}
+	k, err := f.parsed.Kern(&f.buf, x0, x1, f.ppem, f.hinting)
+	if err != nil {
+		return 0
+	}
+	return k
+}
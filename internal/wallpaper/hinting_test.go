@@ -0,0 +1,80 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// TestKernedFace_KerningScalesWithSize expects kerning in pixels proportional to the font size, applied by MeasureString.
+// The test fails if kerning is reported in font units/64 (the opentype.Face.Kern behavior) or skipped when measuring.
+func TestKernedFace_KerningScalesWithSize(t *testing.T) {
+	small, err := loadFace(boldFontData, 100, font.HintingNone)
+	if err != nil {
+		t.Fatalf("loadFace error: %v", err)
+	}
+	large, err := loadFace(boldFontData, 200, font.HintingNone)
+	if err != nil {
+		t.Fatalf("loadFace error: %v", err)
+	}
+
+	// DejaVu Sans Bold kerns A/V by -139 of 2048 units, about -6.8px at 100px.
+	k100, k200 := small.Kern('A', 'V'), large.Kern('A', 'V')
+	if k100 > -fixed.I(6) || k100 < -fixed.I(8) {
+		t.Fatalf("Kern(A, V) at 100px: got %v, want about -6.8px", k100)
+	}
+	if diff := k200 - 2*k100; diff < -2 || diff > 2 {
+		t.Fatalf("kerning does not scale with size: %v at 100px, %v at 200px", k100, k200)
+	}
+
+	advA, _ := small.GlyphAdvance('A')
+	advV, _ := small.GlyphAdvance('V')
+	if got, want := font.MeasureString(small, "AV"), advA+advV+k100; got != want {
+		t.Fatalf("MeasureString(AV): got %v want %v", got, want)
+	}
+}
+
+// TestHinting_FullSnapsKerningToPixels expects full hinting to quantize kerning and advances to whole pixels.
+func TestHinting_FullSnapsKerningToPixels(t *testing.T) {
+	face, err := loadFace(boldFontData, 100, HintingFull.fontHinting())
+	if err != nil {
+		t.Fatalf("loadFace error: %v", err)
+	}
+	if k := face.Kern('A', 'V'); k == 0 || k%64 != 0 {
+		t.Fatalf("expected non-zero whole-pixel kerning, got %v", k)
+	}
+	if adv, _ := face.GlyphAdvance('A'); adv%64 != 0 {
+		t.Fatalf("expected whole-pixel advance, got %v", adv)
+	}
+
+	if h, err := ParseHinting(""); err != nil || h != HintingNone {
+		t.Fatalf("ParseHinting(\"\") = %q, %v", h, err)
+	}
+	if _, err := ParseHinting("slight"); err == nil || !strings.Contains(err.Error(), "unknown hinting") {
+		t.Fatalf("expected unknown hinting error, got %v", err)
+	}
+}
+
+// TestGolden_KerningPairs compares known kerning pairs drawn at display size against a golden image.
+// The test fails if pair spacing changes, e.g. when kerning is dropped or mis-scaled; run with -update to accept changes.
+func TestGolden_KerningPairs(t *testing.T) {
+	face, err := loadFace(boldFontData, 72, font.HintingNone)
+	if err != nil {
+		t.Fatalf("loadFace error: %v", err)
+	}
+	lines := []string{"AVA", "To Ty", "LT Wa", "Yo VA"}
+	img := image.NewRGBA(image.Rect(0, 0, 300, 90*len(lines)))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	for i, line := range lines {
+		if err := drawText(img, face, line, 10, 70+90*i, color.NRGBA{A: 255}); err != nil {
+			t.Fatalf("drawText error: %v", err)
+		}
+	}
+	checkGolden(t, "kerning_pairs", img)
+}
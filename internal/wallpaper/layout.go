@@ -2,30 +2,61 @@ package wallpaper
 
 import (
 	"fmt"
+	"math"
 
 	"golang.org/x/image/font"
 )
 
 // Layout defines all geometry and font sizing used during rendering.
+// The JSON form is what --dump-layout exports for external tooling; all values are in output pixels.
 type Layout struct {
-	Width, Height int
-
-	BoxX0, BoxY0 int
-	BoxX1, BoxY1 int
-	BoxWidth     int
-	BoxHeight    int
-	BoxRadius    int
-	BoxOpacity   uint8
-	Padding      int
-
-	TitleX, TitleY       int
-	SubtitleX, SubtitleY int
-
-	SeparatorY         int
-	SeparatorThickness int
-
-	TitleFontSize    float64
-	SubtitleFontSize float64
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	BoxX0      int   `json:"box_x0"`
+	BoxY0      int   `json:"box_y0"`
+	BoxX1      int   `json:"box_x1"`
+	BoxY1      int   `json:"box_y1"`
+	BoxWidth   int   `json:"box_width"`
+	BoxHeight  int   `json:"box_height"`
+	BoxRadius  int   `json:"box_radius"`
+	BoxOpacity uint8 `json:"box_opacity"`
+	Padding    int   `json:"padding"`
+
+	// TitleX/TitleY and SubtitleX/SubtitleY are the left end of each line's baseline.
+	TitleX    int `json:"title_x"`
+	TitleY    int `json:"title_y"`
+	SubtitleX int `json:"subtitle_x"`
+	SubtitleY int `json:"subtitle_y"`
+
+	SeparatorY         int `json:"separator_y"`
+	SeparatorThickness int `json:"separator_thickness"`
+
+	// RowX/RowY is the top-left corner of the tag row below the subtitle and RowWidth/RowHeight its size; all are zero
+	// without tags.
+	RowX      int `json:"row_x,omitempty"`
+	RowY      int `json:"row_y,omitempty"`
+	RowWidth  int `json:"row_width,omitempty"`
+	RowHeight int `json:"row_height,omitempty"`
+
+	// ProgressX/ProgressY is the top-left corner of the empty region reserved for a boot progress bar and
+	// ProgressWidth/ProgressHeight its size; all are zero without Options.ProgressBar.
+	ProgressX      int `json:"progress_x,omitempty"`
+	ProgressY      int `json:"progress_y,omitempty"`
+	ProgressWidth  int `json:"progress_width,omitempty"`
+	ProgressHeight int `json:"progress_height,omitempty"`
+
+	TitleFontSize    float64 `json:"title_font_size"`
+	SubtitleFontSize float64 `json:"subtitle_font_size"`
+
+	// BadgeX0/BadgeY0 and BadgeX1/BadgeY1 are the corners of the channel badge; all are zero without a badge.
+	BadgeX0 int `json:"badge_x0,omitempty"`
+	BadgeY0 int `json:"badge_y0,omitempty"`
+	BadgeX1 int `json:"badge_x1,omitempty"`
+	BadgeY1 int `json:"badge_y1,omitempty"`
+
+	// Direction is the resolved base direction of the title; right-to-left layouts mirror the badge to the top-left.
+	Direction Direction `json:"direction"`
 }
 
 const (
@@ -43,6 +74,26 @@ const (
 // ComputeLayoutForText computes all layout geometry from the image size and measured text widths using font metrics.
 // It falls back to default dimensions for non-positive sizes and returns an error for nil font faces.
 func ComputeLayoutForText(width, height int, titleFace, subtitleFace font.Face, title, subtitle string) (Layout, error) {
+	return ComputeLayoutForTextDirection(width, height, titleFace, subtitleFace, title, subtitle, DirectionLTR, DirectionLTR)
+}
+
+// ComputeLayoutForTextDirection is ComputeLayoutForText for lines in visual order with resolved base directions.
+// Lines stay centered; right-to-left lines are measured from the right edge of the box.
+func ComputeLayoutForTextDirection(width, height int, titleFace, subtitleFace font.Face, title, subtitle string, titleDir, subtitleDir Direction) (Layout, error) {
+	return computeCenteredLayout(LayoutInput{
+		Width: width, Height: height,
+		TitleFace: titleFace, SubtitleFace: subtitleFace,
+		Title: title, Subtitle: subtitle,
+		TitleDir: titleDir, SubtitleDir: subtitleDir,
+	})
+}
+
+// computeCenteredLayout is ComputeLayoutForTextDirection for in, with room for its tag row below the subtitle.
+func computeCenteredLayout(in LayoutInput) (Layout, error) {
+	width, height := in.Width, in.Height
+	titleFace, subtitleFace := in.TitleFace, in.SubtitleFace
+	title, subtitle := in.Title, in.Subtitle
+	titleDir, subtitleDir := in.TitleDir, in.SubtitleDir
 	if width <= 0 || height <= 0 {
 		width = TargetWidth
 		height = TargetHeight
@@ -60,7 +111,7 @@ func ComputeLayoutForText(width, height int, titleFace, subtitleFace font.Face,
 	subtitleHeight := (subMetrics.Ascent + subMetrics.Descent).Ceil()
 
 	padding := maxInt(14, minInt(width, height)*paddingPercent/100)
-	contentWidth := maxInt(titleAdvance, subAdvance)
+	contentWidth := maxInt(maxInt(titleAdvance, subAdvance), in.RowWidth)
 	defaultBoxWidth := width * boxWidthPercent / 100
 	boxWidth := maxInt(defaultBoxWidth, contentWidth+padding*2)
 
@@ -68,7 +119,7 @@ func ComputeLayoutForText(width, height int, titleFace, subtitleFace font.Face,
 	gapAfterTitle := maxInt(padding/3, lineThickness)
 	gapAfterSeparator := padding / 2
 
-	boxHeight := padding + titleHeight + gapAfterTitle + lineThickness + gapAfterSeparator + subtitleHeight + padding
+	boxHeight := padding + titleHeight + gapAfterTitle + lineThickness + gapAfterSeparator + subtitleHeight + in.rowBlock(padding) + padding
 	boxX0 := (width - boxWidth) / 2
 	boxY0 := (height - boxHeight) / 2
 	boxX1 := boxX0 + boxWidth
@@ -76,13 +127,13 @@ func ComputeLayoutForText(width, height int, titleFace, subtitleFace font.Face,
 
 	radius := maxInt(10, minInt(boxWidth, boxHeight)/radiusDivisor)
 
-	titleX := boxX0 + (boxWidth-titleAdvance)/2
+	titleX := lineX(boxX0, boxX1, titleAdvance, titleDir)
 	titleY := boxY0 + padding + titleMetrics.Ascent.Ceil()
 	separatorY := boxY0 + padding + titleHeight + gapAfterTitle + lineThickness/2
-	subtitleX := boxX0 + (boxWidth-subAdvance)/2
+	subtitleX := lineX(boxX0, boxX1, subAdvance, subtitleDir)
 	subtitleY := separatorY + lineThickness/2 + gapAfterSeparator + subMetrics.Ascent.Ceil()
 
-	return Layout{
+	l := Layout{
 		Width:  width,
 		Height: height,
 
@@ -106,7 +157,56 @@ func ComputeLayoutForText(width, height int, titleFace, subtitleFace font.Face,
 
 		TitleFontSize:    float64(titleMetrics.Ascent.Ceil() + titleMetrics.Descent.Ceil()),
 		SubtitleFontSize: float64(subMetrics.Ascent.Ceil() + subMetrics.Descent.Ceil()),
-	}, nil
+
+		Direction: resolveDirection("", titleDir, DirectionLTR),
+	}
+	return in.placeRow(l, lineX(boxX0, boxX1, in.RowWidth, titleDir), subtitleY-subMetrics.Ascent.Ceil()+subtitleHeight, padding), nil
+}
+
+// alignLayout applies the theme's vertical alignment and baseline grid to a computed layout.
+// Both move the title, separator, subtitle, and tag row together vertically and leave the box unchanged; the grid is applied last.
+func alignLayout(l Layout, in LayoutInput, theme Theme) Layout {
+	if theme.VerticalAlign == VerticalAlignOptical {
+		titleInk, _ := font.BoundString(in.TitleFace, in.Title)
+		subInk, _ := font.BoundString(in.SubtitleFace, in.Subtitle)
+		inkTop := minInt(l.TitleY+titleInk.Min.Y.Floor(), l.SubtitleY+subInk.Min.Y.Floor())
+		inkBottom := maxInt(l.TitleY+titleInk.Max.Y.Ceil(), l.SubtitleY+subInk.Max.Y.Ceil())
+		if l.RowHeight > 0 {
+			inkBottom = maxInt(inkBottom, l.RowY+l.RowHeight)
+		}
+		shift := (l.BoxY0 + l.BoxY1 - inkTop - inkBottom) / 2
+		l.TitleY += shift
+		l.SubtitleY += shift
+		l.SeparatorY += shift
+		l.RowY += shift
+	}
+
+	if theme.BaselineGrid > 0 {
+		grid := maxInt(1, int(math.Round(theme.BaselineGrid*float64(l.Height)/TargetHeight)))
+		titleShift := snapToGrid(l.TitleY, grid) - l.TitleY
+		subShift := snapToGrid(l.SubtitleY, grid) - l.SubtitleY
+		l.TitleY += titleShift
+		l.SubtitleY += subShift
+		// The separator keeps its place between the lines.
+		l.SeparatorY += (titleShift + subShift) / 2
+		// The tag row stays below the subtitle.
+		l.RowY += subShift
+	}
+	return l
+}
+
+// snapToGrid rounds y to the nearest multiple of grid.
+func snapToGrid(y, grid int) int {
+	return (y + grid/2) / grid * grid
+}
+
+// lineX returns the x position of a centered line between boxX0 and boxX1.
+// Left-to-right lines are measured from the left edge and right-to-left lines from the right edge.
+func lineX(boxX0, boxX1, advance int, dir Direction) int {
+	if dir == DirectionRTL {
+		return boxX1 - (boxX1-boxX0-advance)/2 - advance
+	}
+	return boxX0 + (boxX1-boxX0-advance)/2
 }
 
 // minInt returns the smaller of two integers.
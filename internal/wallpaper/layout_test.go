@@ -1,6 +1,7 @@
 package wallpaper
 
 import (
+	"image/color"
 	"strings"
 	"testing"
 
@@ -15,11 +16,11 @@ func mustFacesForHeight(t *testing.T, height int) (titleFace font.Face, subtitle
 	titleSize := float64(height) * 0.06
 	subtitleSize := float64(height) * 0.036
 
-	bold, err := loadFace(boldFontData, titleSize)
+	bold, err := loadFace(boldFontData, titleSize, font.HintingNone)
 	if err != nil {
 		t.Fatalf("load bold face: %v", err)
 	}
-	regular, err := loadFace(regularFontData, subtitleSize)
+	regular, err := loadFace(regularFontData, subtitleSize, font.HintingNone)
 	if err != nil {
 		t.Fatalf("load regular face: %v", err)
 	}
@@ -207,3 +208,122 @@ func TestComputeLayoutForText_ErrorsOnNilFaces(t *testing.T) {
 		t.Fatalf("unexpected error: %q", got)
 	}
 }
+
+// TestComputeLayoutForTextDirection_RTLAnchorsRight expects right-to-left lines to be centered from the right edge
+// and the layout to record the title direction, including a mixed RTL title with an LTR subtitle.
+func TestComputeLayoutForTextDirection_RTLAnchorsRight(t *testing.T) {
+	titleFace, subtitleFace := mustFacesForHeight(t, TargetHeight)
+	title := prepareLine("TSSH שלום", DirectionRTL)
+	subtitle := "build 42"
+
+	l, err := ComputeLayoutForTextDirection(TargetWidth, TargetHeight, titleFace, subtitleFace, title, subtitle, DirectionRTL, DirectionLTR)
+	if err != nil {
+		t.Fatalf("ComputeLayoutForTextDirection error: %v", err)
+	}
+	if l.Direction != DirectionRTL {
+		t.Fatalf("expected RTL layout, got %q", l.Direction)
+	}
+	titleAdvance := font.MeasureString(titleFace, title).Ceil()
+	if right := l.BoxX1 - (l.TitleX + titleAdvance); right != (l.BoxWidth-titleAdvance)/2 {
+		t.Fatalf("RTL title not anchored right: right margin %d want %d", right, (l.BoxWidth-titleAdvance)/2)
+	}
+	subAdvance := font.MeasureString(subtitleFace, subtitle).Ceil()
+	if left := l.SubtitleX - l.BoxX0; left != (l.BoxWidth-subAdvance)/2 {
+		t.Fatalf("LTR subtitle not anchored left: left margin %d want %d", left, (l.BoxWidth-subAdvance)/2)
+	}
+
+	ltr, err := ComputeLayoutForText(TargetWidth, TargetHeight, titleFace, subtitleFace, title, subtitle)
+	if err != nil {
+		t.Fatalf("ComputeLayoutForText error: %v", err)
+	}
+	if ltr.Direction != DirectionLTR || ltr.BoxX0 != l.BoxX0 || ltr.BoxWidth != l.BoxWidth {
+		t.Fatalf("direction must not change the box: %+v vs %+v", ltr, l)
+	}
+}
+
+// TestRenderWithLayout_ReturnsDrawnGeometry expects RenderWithLayout to return the layout used for drawing.
+// The test fails if the returned geometry differs from ComputeLayoutForText for the same text and faces.
+func TestRenderWithLayout_ReturnsDrawnGeometry(t *testing.T) {
+	const w, h = 1280, 720
+	img, got, err := RenderWithLayout(solidBG(w, h, color.RGBA{R: 40, G: 90, B: 150, A: 255}), "lab", "b1", Options{Width: w, Height: h})
+	if err != nil {
+		t.Fatalf("RenderWithLayout error: %v", err)
+	}
+	if img.Bounds().Dx() != w {
+		t.Fatalf("unexpected image size %v", img.Bounds())
+	}
+	titleFace, subtitleFace := mustFacesForHeight(t, h)
+	want, err := ComputeLayoutForText(w, h, titleFace, subtitleFace, "TSSH lab", "b1")
+	if err != nil {
+		t.Fatalf("ComputeLayoutForText error: %v", err)
+	}
+	if got != want {
+		t.Fatalf("layout mismatch:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+// centeredInput measures title and subtitle with the centered layout's faces at the given size.
+func centeredInput(t *testing.T, w, h int, title, subtitle string) LayoutInput {
+	t.Helper()
+	titleFace, subtitleFace := mustFacesForHeight(t, h)
+	return LayoutInput{
+		Width: w, Height: h,
+		TitleFace: titleFace, SubtitleFace: subtitleFace,
+		Title: title, Subtitle: subtitle,
+		TitleDir: DirectionLTR, SubtitleDir: DirectionLTR,
+	}
+}
+
+// TestAlignLayout_OpticalCentersInk expects optical alignment to equalize the space above and below the inked glyphs.
+// The test fails if metrics mode changes the layout, or if optical mode leaves unequal margins for an all-caps block.
+func TestAlignLayout_OpticalCentersInk(t *testing.T) {
+	in := centeredInput(t, 1280, 720, "TSSH LAB", "BUILD 42")
+	base, err := centeredLayout{}.Compute(in)
+	if err != nil {
+		t.Fatalf("Compute error: %v", err)
+	}
+	if got := alignLayout(base, in, Theme{VerticalAlign: VerticalAlignMetrics}); got != base {
+		t.Fatalf("metrics alignment changed the layout:\ngot  %+v\nwant %+v", got, base)
+	}
+
+	margins := func(l Layout) (int, int) {
+		titleInk, _ := font.BoundString(in.TitleFace, in.Title)
+		subInk, _ := font.BoundString(in.SubtitleFace, in.Subtitle)
+		return l.TitleY + titleInk.Min.Y.Floor() - l.BoxY0, l.BoxY1 - (l.SubtitleY + subInk.Max.Y.Ceil())
+	}
+	top, bottom := margins(base)
+	if top-bottom >= -1 && top-bottom <= 1 {
+		t.Fatalf("expected metrics centering to leave unequal ink margins for all caps, got %d/%d", top, bottom)
+	}
+
+	optical := alignLayout(base, in, Theme{VerticalAlign: VerticalAlignOptical})
+	top, bottom = margins(optical)
+	if d := top - bottom; d < -1 || d > 1 {
+		t.Fatalf("optical ink margins differ: top %d, bottom %d", top, bottom)
+	}
+	if optical.SeparatorY-optical.TitleY != base.SeparatorY-base.TitleY || optical.BoxY0 != base.BoxY0 {
+		t.Fatalf("optical alignment must move the text block as a whole and keep the box: %+v", optical)
+	}
+}
+
+// TestAlignLayout_BaselineGridSnaps expects both baselines on multiples of the grid scaled to the output height.
+// The test fails if a baseline is off the grid, moves by more than half a grid step, or the separator leaves the gap.
+func TestAlignLayout_BaselineGridSnaps(t *testing.T) {
+	in := centeredInput(t, 1280, 720, "TSSH lab", "2026-01-04")
+	base, err := centeredLayout{}.Compute(in)
+	if err != nil {
+		t.Fatalf("Compute error: %v", err)
+	}
+	// 48px at QHD is 16px at 720p.
+	got := alignLayout(base, in, Theme{VerticalAlign: VerticalAlignOptical, BaselineGrid: 48})
+	if got.TitleY%16 != 0 || got.SubtitleY%16 != 0 {
+		t.Fatalf("baselines not on the 16px grid: title %d, subtitle %d", got.TitleY, got.SubtitleY)
+	}
+	optical := alignLayout(base, in, Theme{VerticalAlign: VerticalAlignOptical})
+	if d := got.TitleY - optical.TitleY; d < -8 || d > 8 {
+		t.Fatalf("title moved by %d, more than half a grid step", d)
+	}
+	if got.SeparatorY <= got.TitleY || got.SeparatorY >= got.SubtitleY {
+		t.Fatalf("separator %d not between baselines %d and %d", got.SeparatorY, got.TitleY, got.SubtitleY)
+	}
+}
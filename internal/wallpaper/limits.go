@@ -0,0 +1,59 @@
+package wallpaper
+
+import "fmt"
+
+// DefaultMaxPixels is the largest width × height of a background image under the zero Limits. 100 megapixels, about
+// 400 MB decoded, admit 8K and 10K ultrawide images; 16K images (15360×8640) are rejected.
+const DefaultMaxPixels = 100_000_000
+
+// Limits bounds the background images a source decodes. Sources check the size against the size in the image header
+// before decoding any pixels, so a small hostile or mis-tagged file that claims a huge size cannot exhaust memory. The
+// zero value allows DefaultMaxPixels and any width and height within it, and uses the first frame of animated images.
+type Limits struct {
+	// MaxPixels bounds width × height; zero selects DefaultMaxPixels.
+	MaxPixels int64
+	// MaxDimension bounds the width and the height each; zero leaves them bounded by MaxPixels only.
+	MaxDimension int
+	// Animated selects the frame of animated GIF and APNG backgrounds; empty selects AnimatedFirst.
+	Animated AnimatedPolicy
+	// Warn receives a message naming the frame used of each animated background; nil discards them.
+	Warn func(msg string)
+}
+
+// TooLargeError is returned for a background whose header claims a size beyond the Limits of its source. It wraps
+// ErrDecode, so fallbacks and ClassifyFetchError treat it like undecodable data.
+type TooLargeError struct {
+	Width, Height int
+	// Limits are the limits it exceeds, with MaxPixels resolved.
+	Limits Limits
+}
+
+func (e *TooLargeError) Error() string {
+	if d := e.Limits.MaxDimension; d > 0 && (e.Width > d || e.Height > d) {
+		return fmt.Sprintf("%v: %dx%d image exceeds the maximum dimension of %d pixels", ErrDecode, e.Width, e.Height, d)
+	}
+	return fmt.Sprintf("%v: %dx%d image has more than %d pixels", ErrDecode, e.Width, e.Height, e.Limits.MaxPixels)
+}
+
+// Unwrap returns ErrDecode.
+func (e *TooLargeError) Unwrap() error {
+	return ErrDecode
+}
+
+// check returns a *TooLargeError if an image of width × height exceeds l.
+func (l Limits) check(width, height int) error {
+	l = l.resolved()
+	tooWide := l.MaxDimension > 0 && (width > l.MaxDimension || height > l.MaxDimension)
+	if tooWide || int64(width)*int64(height) > l.MaxPixels {
+		return &TooLargeError{Width: width, Height: height, Limits: l}
+	}
+	return nil
+}
+
+// resolved returns l with MaxPixels set.
+func (l Limits) resolved() Limits {
+	if l.MaxPixels <= 0 {
+		l.MaxPixels = DefaultMaxPixels
+	}
+	return l
+}
@@ -0,0 +1,205 @@
+package wallpaper
+
+import (
+	"image"
+	stddraw "image/draw"
+	"math"
+	"sync"
+)
+
+// compositor routes layer drawing either straight onto the 8-bit sRGB canvas or through a transparent scratch
+// layer that is blended into a 16-bit accumulator, optionally in linear light, and quantized once at the end.
+type compositor struct {
+	canvas  *image.RGBA
+	deep    *deepCanvas
+	scratch *image.RGBA
+	dither  Dither
+}
+
+// newCompositor returns a compositor for canvas. linear blends in linear light; linear or dithering enables the
+// 16-bit accumulator.
+func newCompositor(canvas *image.RGBA, linear bool, dither Dither) *compositor {
+	c := &compositor{canvas: canvas, dither: dither}
+	if linear || dither.enabled() {
+		c.deep = newDeepCanvas(canvas.Bounds(), linear)
+		c.scratch = image.NewRGBA(canvas.Bounds())
+	}
+	return c
+}
+
+// bounds returns the canvas rectangle.
+func (c *compositor) bounds() image.Rectangle {
+	return c.canvas.Bounds()
+}
+
+// replace copies img over the whole canvas, as the background does.
+func (c *compositor) replace(img *image.RGBA) {
+	if c.deep == nil {
+		stddraw.Draw(c.canvas, c.canvas.Bounds(), img, img.Bounds().Min, stddraw.Src)
+		return
+	}
+	c.deep.load(img)
+}
+
+// paint runs fn on a drawing surface and composites the result over everything drawn so far.
+// fn must only draw with Over semantics, so a transparent scratch layer blended afterwards gives the same image.
+func (c *compositor) paint(fn func(dst *image.RGBA) error) error {
+	if c.deep == nil {
+		return fn(c.canvas)
+	}
+	clear(c.scratch.Pix)
+	if err := fn(c.scratch); err != nil {
+		return err
+	}
+	c.deep.over(c.scratch)
+	return nil
+}
+
+// finish quantizes the accumulator to 8-bit sRGB on the canvas; it is a no-op for the 8-bit pipeline.
+func (c *compositor) finish() {
+	if c.deep != nil {
+		c.deep.encode(c.canvas, c.dither)
+	}
+}
+
+// deepCanvas holds premultiplied RGBA with 16 bits per channel, in sRGB or linear light.
+// Blending translucent layers there avoids the banding that repeated 8-bit rounding leaves in smooth gradients.
+type deepCanvas struct {
+	rect image.Rectangle
+	pix  []uint16
+	// toWork maps 8-bit sRGB to the 16-bit working space; fromWork maps back to sRGB in 8.8 fixed point.
+	toWork   *[256]uint16
+	fromWork []uint16
+}
+
+// newDeepCanvas returns a transparent accumulator covering rect; linear selects linear light as working space.
+func newDeepCanvas(rect image.Rectangle, linear bool) *deepCanvas {
+	linearTables()
+	c := &deepCanvas{rect: rect, pix: make([]uint16, 4*rect.Dx()*rect.Dy()), toWork: &srgbTo16, fromWork: srgb16To88}
+	if linear {
+		c.toWork, c.fromWork = &srgbToLinear16, linear16To88
+	}
+	return c
+}
+
+var (
+	linearTablesOnce sync.Once
+	// srgbTo16 and srgbToLinear16 map an 8-bit sRGB value to 16-bit sRGB or linear light.
+	srgbTo16, srgbToLinear16 [256]uint16
+	// srgb16To88 and linear16To88 map 16-bit sRGB or linear light to sRGB in 8.8 fixed point.
+	srgb16To88, linear16To88 []uint16
+)
+
+// linearTables initializes the conversion tables on first use.
+func linearTables() {
+	linearTablesOnce.Do(func() {
+		for i := range srgbToLinear16 {
+			srgbTo16[i] = uint16(i * 257)
+			srgbToLinear16[i] = uint16(math.Round(srgbToLinear(float64(i)/255) * 65535))
+		}
+		srgb16To88 = make([]uint16, 65536)
+		linear16To88 = make([]uint16, 65536)
+		for i := range linear16To88 {
+			srgb16To88[i] = uint16(math.Round(float64(i) / 65535 * 255 * 256))
+			linear16To88[i] = uint16(math.Round(linearToSRGB(float64(i)/65535) * 255 * 256))
+		}
+	})
+}
+
+// srgbToLinear decodes an sRGB value in [0, 1] to linear light.
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB encodes linear light in [0, 1] as sRGB.
+func linearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// load replaces the accumulator with img, which must cover the same rectangle.
+func (l *deepCanvas) load(img *image.RGBA) {
+	for y := l.rect.Min.Y; y < l.rect.Max.Y; y++ {
+		src := img.Pix[img.PixOffset(l.rect.Min.X, y):]
+		dst := l.pix[4*(y-l.rect.Min.Y)*l.rect.Dx():]
+		for x := 0; x < l.rect.Dx(); x++ {
+			s, d := src[4*x:4*x+4:4*x+4], dst[4*x:4*x+4:4*x+4]
+			a := uint32(s[3])
+			if a == 0 {
+				d[0], d[1], d[2], d[3] = 0, 0, 0, 0
+				continue
+			}
+			for c := 0; c < 3; c++ {
+				d[c] = uint16(uint32(l.toWork[unpremultiply(s[c], s[3])]) * a / 255)
+			}
+			d[3] = uint16(a * 257)
+		}
+	}
+}
+
+// over blends the premultiplied 8-bit sRGB layer onto the accumulator in its working space.
+func (l *deepCanvas) over(layer *image.RGBA) {
+	for y := l.rect.Min.Y; y < l.rect.Max.Y; y++ {
+		src := layer.Pix[layer.PixOffset(l.rect.Min.X, y):]
+		dst := l.pix[4*(y-l.rect.Min.Y)*l.rect.Dx():]
+		for x := 0; x < l.rect.Dx(); x++ {
+			s := src[4*x : 4*x+4 : 4*x+4]
+			a := uint32(s[3])
+			if a == 0 {
+				continue
+			}
+			d := dst[4*x : 4*x+4 : 4*x+4]
+			for c := 0; c < 3; c++ {
+				lin := uint32(l.toWork[unpremultiply(s[c], s[3])])
+				d[c] = uint16((lin*a + uint32(d[c])*(255-a) + 127) / 255)
+			}
+			d[3] = uint16((a*65535 + uint32(d[3])*(255-a) + 127) / 255)
+		}
+	}
+}
+
+// encode quantizes the accumulator to dst as premultiplied 8-bit sRGB with the given dithering.
+// Fully opaque pixels, which make up the background-covered canvas, are dithered; translucent pixels are rounded.
+func (l *deepCanvas) encode(dst *image.RGBA, dither Dither) {
+	w := l.rect.Dx()
+	q := newQuantizer(dither, w)
+	for y := l.rect.Min.Y; y < l.rect.Max.Y; y++ {
+		src := l.pix[4*(y-l.rect.Min.Y)*w:]
+		out := dst.Pix[dst.PixOffset(l.rect.Min.X, y):]
+		for x := 0; x < w; x++ {
+			s, d := src[4*x:4*x+4:4*x+4], out[4*x:4*x+4:4*x+4]
+			a := uint32(s[3])
+			switch {
+			case a == 0:
+				d[0], d[1], d[2], d[3] = 0, 0, 0, 0
+			case a == 65535:
+				for c := 0; c < 3; c++ {
+					d[c] = q.quantize(l.fromWork[s[c]], x, y, c)
+				}
+				d[3] = 255
+			default:
+				a8 := uint8((a + 128) / 257)
+				for c := 0; c < 3; c++ {
+					// Undo the premultiplication in the working space, encode, and premultiply again in 8 bits.
+					v := l.fromWork[min(65535, uint32(s[c])*65535/a)]
+					d[c] = uint8((uint32(clampByte((int32(v)+128)>>8))*uint32(a8) + 127) / 255)
+				}
+				d[3] = a8
+			}
+		}
+		q.nextRow()
+	}
+}
+
+// unpremultiply returns the straight 8-bit value of a premultiplied channel.
+func unpremultiply(c, a uint8) uint8 {
+	if a == 255 {
+		return c
+	}
+	return uint8(min(255, (uint32(c)*255+uint32(a)/2)/uint32(a)))
+}
@@ -0,0 +1,49 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// TestCompositor_HQBlendsInLinearLight expects the high-quality pipeline to blend a translucent panel over a smooth
+// gradient within one level of an exact linear-light reference, and the 8-bit pipeline to keep blending in sRGB.
+// The test fails if the accumulator loses precision or blends in the wrong color space.
+func TestCompositor_HQBlendsInLinearLight(t *testing.T) {
+	const width = 256
+	bg := image.NewRGBA(image.Rect(0, 0, width, 1))
+	for x := 0; x < width; x++ {
+		bg.SetRGBA(x, 0, color.RGBA{R: uint8(x), G: uint8(x), B: uint8(x), A: 255})
+	}
+	panel := color.NRGBA{R: 12, G: 16, B: 24, A: 150}
+	render := func(hq bool) *image.RGBA {
+		canvas := image.NewRGBA(bg.Bounds())
+		comp := newCompositor(canvas, hq, DitherNone)
+		comp.replace(bg)
+		if err := comp.paint(func(dst *image.RGBA) error {
+			drawRoundedRect(dst, dst.Bounds(), 0, panel)
+			return nil
+		}); err != nil {
+			t.Fatalf("paint error: %v", err)
+		}
+		comp.finish()
+		return canvas
+	}
+
+	hq, plain := render(true), render(false)
+	alpha := float64(panel.A) / 255
+	for x := 0; x < width; x++ {
+		ref := linearToSRGB(srgbToLinear(float64(panel.R)/255)*alpha+srgbToLinear(float64(x)/255)*(1-alpha)) * 255
+		if got := float64(hq.RGBAAt(x, 0).R); math.Abs(got-ref) > 1 {
+			t.Fatalf("hq x=%d: red %v, want %.1f", x, got, ref)
+		}
+		if got := hq.RGBAAt(x, 0).A; got != 255 {
+			t.Fatalf("hq x=%d: alpha %d, want opaque", x, got)
+		}
+	}
+	// Blending mid-gray in sRGB darkens it more than blending in linear light.
+	if p, h := plain.RGBAAt(128, 0).R, hq.RGBAAt(128, 0).R; p >= h {
+		t.Fatalf("sRGB blend %d should be darker than linear blend %d", p, h)
+	}
+}
@@ -0,0 +1,104 @@
+package wallpaper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	stddraw "image/draw"
+)
+
+// exifHeader starts the APP1 segment of a JPEG that holds EXIF data.
+const exifHeader = "Exif\x00\x00"
+
+// exifOrientation returns the EXIF orientation of JPEG data, 1 (upright) through 8, or 1 if the data has none or is
+// not a JPEG. Cameras store portrait photos sideways and tag how to turn them.
+func exifOrientation(data []byte) int {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for pos := 2; pos+4 <= len(data); {
+		marker := data[pos+1]
+		if data[pos] != 0xFF || marker == 0xD9 || marker == 0xDA {
+			// Invalid marker, end of image, or start of scan: no more metadata segments.
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if size < 2 || pos+2+size > len(data) {
+			return 1
+		}
+		if payload := data[pos+4 : pos+2+size]; marker == 0xE1 && bytes.HasPrefix(payload, []byte(exifHeader)) {
+			return tiffOrientation(payload[len(exifHeader):])
+		}
+		pos += 2 + size
+	}
+	return 1
+}
+
+// tiffOrientation returns the orientation tag of the first IFD of the TIFF structure of EXIF data, or 1.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := order.Uint32(tiff[4:])
+	if offset < 8 || uint64(offset)+2 > uint64(len(tiff)) {
+		return 1
+	}
+	ifd := int(offset)
+	for i := range int(order.Uint16(tiff[ifd:])) {
+		entry := tiff[min(len(tiff), ifd+2+12*i):]
+		if len(entry) < 12 {
+			return 1
+		}
+		// Tag 0x0112 is the orientation, a single SHORT (type 3) stored in the value field.
+		if order.Uint16(entry) == 0x0112 && order.Uint16(entry[2:]) == 3 {
+			if o := int(order.Uint16(entry[8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orient returns img turned upright as the EXIF orientation asks: 2 mirrors it horizontally, 3 rotates it by 180°, 4
+// mirrors it vertically, 5 transposes it, 6 rotates it by 90° clockwise, 7 transverses it, and 8 rotates it by 90°
+// counterclockwise. Orientation 1 and invalid values return img itself.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	stddraw.Draw(src, src.Rect, img, b.Min, stddraw.Src)
+	// to maps a source pixel to its upright position.
+	to := map[int]func(x, y int) (int, int){
+		2: func(x, y int) (int, int) { return w - 1 - x, y },
+		3: func(x, y int) (int, int) { return w - 1 - x, h - 1 - y },
+		4: func(x, y int) (int, int) { return x, h - 1 - y },
+		5: func(x, y int) (int, int) { return y, x },
+		6: func(x, y int) (int, int) { return h - 1 - y, x },
+		7: func(x, y int) (int, int) { return h - 1 - y, w - 1 - x },
+		8: func(x, y int) (int, int) { return y, w - 1 - x },
+	}[orientation]
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if orientation >= 5 {
+		dst = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+	for y := range h {
+		for x := range w {
+			dx, dy := to(x, y)
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(x, y):][:4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,88 @@
+package wallpaper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// withOrientation returns JPEG data with an EXIF APP1 segment directly after SOI whose first IFD holds orientation,
+// in the byte order of order.
+func withOrientation(data []byte, orientation uint16, order binary.AppendByteOrder) []byte {
+	tiff := []byte("MM\x00\x2a")
+	if order == binary.LittleEndian {
+		tiff = []byte("II\x2a\x00")
+	}
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, 1)
+	tiff = order.AppendUint16(tiff, 0x0112)
+	tiff = order.AppendUint16(tiff, 3)
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = order.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	payload := append([]byte(exifHeader), tiff...)
+	segment := binary.BigEndian.AppendUint16([]byte{0xFF, 0xE1}, uint16(len(payload)+2))
+	return append(append(append([]byte{0xFF, 0xD8}, segment...), payload...), data[2:]...)
+}
+
+// TestDecodeSRGB_ExifOrientation expects JPEGs to be turned upright for all 8 EXIF orientations before scaling.
+// The stored image is 3×2 cells of distinct grays; each case lists the cells of the upright image row by row.
+// The test fails if portrait photos stay sideways or a rotation or flip goes the wrong way.
+func TestDecodeSRGB_ExifOrientation(t *testing.T) {
+	const cell = 16
+	stored := image.NewGray(image.Rect(0, 0, 3*cell, 2*cell))
+	for y := range 2 * cell {
+		for x := range 3 * cell {
+			stored.SetGray(x, y, color.Gray{Y: uint8(40 * (1 + x/cell + 3*(y/cell)))})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, stored, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("jpeg encode: %v", err)
+	}
+
+	for _, c := range []struct {
+		orientation uint16
+		cells       [][]int
+	}{
+		{1, [][]int{{1, 2, 3}, {4, 5, 6}}},
+		{2, [][]int{{3, 2, 1}, {6, 5, 4}}},
+		{3, [][]int{{6, 5, 4}, {3, 2, 1}}},
+		{4, [][]int{{4, 5, 6}, {1, 2, 3}}},
+		{5, [][]int{{1, 4}, {2, 5}, {3, 6}}},
+		{6, [][]int{{4, 1}, {5, 2}, {6, 3}}},
+		{7, [][]int{{6, 3}, {5, 2}, {4, 1}}},
+		{8, [][]int{{3, 6}, {2, 5}, {1, 4}}},
+	} {
+		for _, order := range []binary.AppendByteOrder{binary.BigEndian, binary.LittleEndian} {
+			img, err := decodeSRGB(withOrientation(buf.Bytes(), c.orientation, order), Limits{})
+			if err != nil {
+				t.Fatalf("orientation %d: decode: %v", c.orientation, err)
+			}
+			if got, want := img.Bounds().Size(), image.Pt(len(c.cells[0])*cell, len(c.cells)*cell); got != want {
+				t.Fatalf("orientation %d %v: size %v, want %v", c.orientation, order, got, want)
+			}
+			for row, cells := range c.cells {
+				for col, want := range cells {
+					got := color.GrayModel.Convert(img.At(col*cell+cell/2, row*cell+cell/2)).(color.Gray).Y
+					if diff := int(got) - 40*want; diff < -4 || diff > 4 {
+						t.Fatalf("orientation %d %v: cell (%d,%d) is gray %d, want %d", c.orientation, order, col, row, got, 40*want)
+					}
+				}
+			}
+		}
+	}
+
+	// Unknown orientations and malformed EXIF data leave the image as stored.
+	for _, data := range [][]byte{
+		withOrientation(buf.Bytes(), 9, binary.BigEndian),
+		append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x0A}, append([]byte(exifHeader+"MM"), buf.Bytes()[2:]...)...),
+	} {
+		if o := exifOrientation(data); o != 1 {
+			t.Fatalf("expected orientation 1 for invalid EXIF data, got %d", o)
+		}
+	}
+}
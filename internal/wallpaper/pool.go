@@ -0,0 +1,187 @@
+package wallpaper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nickhildebrandt/ts-release/internal/wallhaven"
+)
+
+// maxCollectionPages bounds how many pages of a collection are listed, so a huge collection cannot stall a build.
+const maxCollectionPages = 50
+
+// PoolEntry is one approved wallpaper of a curated pool.
+type PoolEntry struct {
+	// ID is the Wallhaven wallpaper ID.
+	ID string
+	// Weight is the relative chance or share of builds; it is at least 1.
+	Weight int
+	// Focal is the point of the wallpaper its crop keeps in frame; nil leaves the choice to the crop mode.
+	Focal *FocalPoint
+}
+
+// Pick selects how a pool entry is chosen for a build.
+type Pick string
+
+const (
+	// PickRandom draws a weighted random entry per build.
+	PickRandom Pick = "random"
+	// PickRoundRobin cycles through the entries, giving each its weight's share of consecutive builds.
+	PickRoundRobin Pick = "round-robin"
+)
+
+// Picks returns all supported pick strategies in a stable order for help output and validation.
+func Picks() []Pick {
+	return []Pick{PickRandom, PickRoundRobin}
+}
+
+// ParsePick converts a user-supplied strategy name into a Pick; empty selects PickRandom.
+// It returns an error for unknown names.
+func ParsePick(name string) (Pick, error) {
+	if name == "" {
+		return PickRandom, nil
+	}
+	for _, p := range Picks() {
+		if string(p) == name {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("pool: unknown pick strategy %q", name)
+}
+
+// LoadPool reads a curated list file; see ParsePool for the syntax.
+func LoadPool(path string) ([]PoolEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("pool: %w", err)
+	}
+	defer f.Close()
+	pool, err := ParsePool(f)
+	if err != nil {
+		return nil, fmt.Errorf("pool: %s: %w", path, err)
+	}
+	return pool, nil
+}
+
+// ParsePool parses a curated list: one wallpaper ID or URL per line, optionally followed by a positive weight and a
+// focal point, e.g. "k7q1vd 2 focal=0.3,0.6". Blank lines and lines starting with '#' are ignored. It returns an error
+// for malformed lines or an empty list.
+func ParsePool(r io.Reader) ([]PoolEntry, error) {
+	var pool []PoolEntry
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		id, err := wallhaven.ParseWallpaperID(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entry := PoolEntry{ID: id, Weight: 1}
+		if len(fields) > 1 {
+			if focal, ok := strings.CutPrefix(fields[len(fields)-1], "focal="); ok {
+				if entry.Focal, err = ParseFocalPoint(focal); err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				fields = fields[:len(fields)-1]
+			}
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("line %d: want <id-or-url> [weight] [focal=x,y]", line)
+		}
+		if len(fields) == 2 {
+			if entry.Weight, err = strconv.Atoi(fields[1]); err != nil || entry.Weight < 1 {
+				return nil, fmt.Errorf("line %d: weight %q must be a positive integer", line, fields[1])
+			}
+		}
+		pool = append(pool, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(pool) == 0 {
+		return nil, fmt.Errorf("no wallpapers listed")
+	}
+	return pool, nil
+}
+
+// CollectionPool lists the wallpapers of a Wallhaven collection with weight 1, following its pages.
+// Purity filters like in SearchParams. It returns an error for API failures or an empty collection.
+func CollectionPool(client *wallhaven.Client, username string, id int, purity string) ([]PoolEntry, error) {
+	var pool []PoolEntry
+	for page := 1; page <= maxCollectionPages; page++ {
+		result, err := client.CollectionWallpapers(username, id, purity, page)
+		if err != nil {
+			return nil, fmt.Errorf("pool: collection %s/%d: %w", username, id, err)
+		}
+		for _, w := range result.Data {
+			pool = append(pool, PoolEntry{ID: w.ID, Weight: 1})
+		}
+		if page >= result.Meta.LastPage {
+			break
+		}
+	}
+	if len(pool) == 0 {
+		return nil, fmt.Errorf("pool: collection %s/%d has no wallpapers", username, id)
+	}
+	return pool, nil
+}
+
+// ChooseRandom returns a pool entry with a probability proportional to its weight; a nil r uses the global source.
+func ChooseRandom(pool []PoolEntry, r *rand.Rand) PoolEntry {
+	total := 0
+	for _, e := range pool {
+		total += e.Weight
+	}
+	n := intN(r, total)
+	for _, e := range pool {
+		if n < e.Weight {
+			return e
+		}
+		n -= e.Weight
+	}
+	return pool[len(pool)-1]
+}
+
+// ChooseRoundRobin returns the entry for the build at position n (starting at 0).
+// It uses smooth weighted round-robin, so within each cycle of the summed weights every entry is chosen weight
+// times and heavy entries are spread out instead of repeated back to back.
+func ChooseRoundRobin(pool []PoolEntry, n uint64) PoolEntry {
+	total := 0
+	for _, e := range pool {
+		total += e.Weight
+	}
+	current := make([]int, len(pool))
+	best := 0
+	for step := uint64(0); step <= n%uint64(total); step++ {
+		best = 0
+		for i, e := range pool {
+			current[i] += e.Weight
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+	}
+	return pool[best]
+}
+
+// ParsePoolPosition returns the round-robin position persisted as position; empty starts at the first entry and
+// non-numeric content is an error. The caller persists the position after the chosen one once the build succeeded.
+func ParsePoolPosition(position string) (uint64, error) {
+	position = strings.TrimSpace(position)
+	if position == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(position, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("pool: invalid position %q: %w", position, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,105 @@
+package wallpaper
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParsePool_IDsURLsAndWeights expects IDs and URLs with optional weights and focal points to parse, comments and
+// blank lines to be skipped, and malformed lines to be rejected with their line number.
+// The test fails if an entry is parsed wrong or an invalid list is accepted.
+func TestParsePool_IDsURLsAndWeights(t *testing.T) {
+	pool, err := ParsePool(strings.NewReader("# approved 2026-Q4\n94x38z 3\n\nhttps://wallhaven.cc/w/k7q1vd\n  https://w.wallhaven.cc/full/zy/wallhaven-zy8x1o.png 2\np2d5q9 focal=0.25,0.75\n94x38z 2 focal=1,0\n"))
+	if err != nil {
+		t.Fatalf("ParsePool error: %v", err)
+	}
+	want := []PoolEntry{{ID: "94x38z", Weight: 3}, {ID: "k7q1vd", Weight: 1}, {ID: "zy8x1o", Weight: 2},
+		{ID: "p2d5q9", Weight: 1, Focal: &FocalPoint{X: 0.25, Y: 0.75}}, {ID: "94x38z", Weight: 2, Focal: &FocalPoint{X: 1}}}
+	if !reflect.DeepEqual(pool, want) {
+		t.Fatalf("got %+v want %+v", pool, want)
+	}
+
+	for list, wantErr := range map[string]string{
+		"":                     "no wallpapers",
+		"# only a comment":     "no wallpapers",
+		"94x38z 0":             "line 1: weight",
+		"94x38z\n94x38z x":     "line 2: weight",
+		"94x38z 1 2":           "line 1: want",
+		"94x38z focal=0.5":     "line 1: render: invalid focal point",
+		"94x38z focal=1.5,0":   "line 1: render: invalid focal point",
+		"94x38z focal=0,0 2":   "line 1: want",
+		"https://example.com/": "line 1:",
+	} {
+		if _, err := ParsePool(strings.NewReader(list)); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Fatalf("ParsePool(%q): got %v, want error containing %q", list, err, wantErr)
+		}
+	}
+}
+
+// TestChoose_WeightedRandomAndRoundRobin expects random picks to follow the weights and round-robin to give every
+// entry its weight's share of each cycle, spread out, continuing from a persisted position.
+// The test fails if a weight is ignored, a cycle is unbalanced, or a position is parsed wrong.
+func TestChoose_WeightedRandomAndRoundRobin(t *testing.T) {
+	pool := []PoolEntry{{ID: "a", Weight: 3}, {ID: "b", Weight: 1}, {ID: "c", Weight: 1}}
+
+	counts := map[string]int{}
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 5000; i++ {
+		counts[ChooseRandom(pool, r).ID]++
+	}
+	if counts["a"] < 2700 || counts["a"] > 3300 || counts["b"] < 800 || counts["c"] < 800 {
+		t.Fatalf("weighted random counts off: %v", counts)
+	}
+
+	var got []string
+	for n := uint64(0); n < 10; n++ {
+		got = append(got, ChooseRoundRobin(pool, n).ID)
+	}
+	if want := "a b a c a a b a c a"; strings.Join(got, " ") != want {
+		t.Fatalf("round-robin order: got %q want %q", strings.Join(got, " "), want)
+	}
+
+	for position, want := range map[string]uint64{"": 0, "3\n": 3} {
+		if got, err := ParsePoolPosition(position); err != nil || got != want {
+			t.Fatalf("ParsePoolPosition(%q) = %d, %v; want %d", position, got, err, want)
+		}
+	}
+	if _, err := ParsePoolPosition("x"); err == nil {
+		t.Fatalf("expected error for an invalid position")
+	}
+}
+
+// TestCollectionPool_FollowsPages expects all pages of a collection to be listed with the purity filter.
+// The test fails if a page is skipped or the filter is not sent.
+func TestCollectionPool_FollowsPages(t *testing.T) {
+	var purity string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/collections/alice/7" {
+			http.NotFound(w, r)
+			return
+		}
+		purity = r.URL.Query().Get("purity")
+		switch r.URL.Query().Get("page") {
+		case "1":
+			_, _ = w.Write([]byte(`{"data":[{"id":"aaaaaa"},{"id":"bbbbbb"}],"meta":{"current_page":1,"last_page":2}}`))
+		default:
+			_, _ = w.Write([]byte(`{"data":[{"id":"cccccc"}],"meta":{"current_page":2,"last_page":2}}`))
+		}
+	}))
+	defer server.Close()
+
+	pool, err := CollectionPool(testClient(server), "alice", 7, "100")
+	if err != nil {
+		t.Fatalf("CollectionPool error: %v", err)
+	}
+	if len(pool) != 3 || pool[2] != (PoolEntry{ID: "cccccc", Weight: 1}) || purity != "100" {
+		t.Fatalf("got %+v with purity %q", pool, purity)
+	}
+	if _, err := CollectionPool(testClient(server), "bob", 1, ""); err == nil {
+		t.Fatalf("expected error for an unknown collection")
+	}
+}
@@ -0,0 +1,51 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// ProgressBarHeight is the height of the region Options.ProgressBar reserves, in pixels at TargetHeight.
+const ProgressBarHeight = 12
+
+// panelRect returns the panel drawn behind the text of layout. Layouts without a box record the text bounds in it,
+// which a themed panel surrounds with a margin of half the padding.
+func panelRect(layout Layout) image.Rectangle {
+	box := image.Rect(layout.BoxX0, layout.BoxY0, layout.BoxX1, layout.BoxY1)
+	if layout.BoxOpacity == 0 {
+		box = box.Inset(-layout.Padding / 2).Intersect(image.Rect(0, 0, layout.Width, layout.Height))
+	}
+	return box
+}
+
+// placeProgressBar reserves the progress bar region in layout, as wide as the text area of the panel and a third of
+// the padding below it. A panel too close to the bottom edge, such as the bottom bar, gets the region above it, and a
+// panel covering the whole image gets it below textBottom, the lowest pixel row of the text, centered and
+// textWidth wide. It returns an error if the region fits nowhere.
+func placeProgressBar(layout Layout, textBottom, textWidth int) (Layout, error) {
+	gap := layout.Padding / 3
+	h := maxInt(2, int(math.Round(ProgressBarHeight*float64(layout.Height)/TargetHeight)))
+	x0, x1 := layout.BoxX0, layout.BoxX1
+	if layout.BoxOpacity > 0 {
+		x0, x1 = x0+layout.Padding, x1-layout.Padding
+	}
+	panel := panelRect(layout)
+	var y int
+	switch {
+	case panel == image.Rect(0, 0, layout.Width, layout.Height):
+		x0 = (layout.Width - textWidth) / 2
+		x1 = x0 + textWidth
+		y = textBottom + gap
+	case panel.Max.Y+gap+h <= layout.Height-gap:
+		y = panel.Max.Y + gap
+	default:
+		y = panel.Min.Y - gap - h
+	}
+	if x1 <= x0 || y < gap || y+h > layout.Height-gap {
+		return Layout{}, fmt.Errorf("render: no room for a progress bar around the panel of %dx%d px", panel.Dx(), panel.Dy())
+	}
+	layout.ProgressX, layout.ProgressY = x0, y
+	layout.ProgressWidth, layout.ProgressHeight = x1-x0, h
+	return layout, nil
+}
@@ -0,0 +1,57 @@
+package wallpaper
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// TestLayouts_ProgressBarRegion reserves a progress bar region with every built-in layout. The test fails if the
+// region is missing, leaves the image, overlaps the panel of a layout whose panel does not fill the image, is not
+// below the panel or text where there is room, or changes the rendered pixels.
+func TestLayouts_ProgressBarRegion(t *testing.T) {
+	const w, h = 1280, 720
+	bg := solidBG(w, h, color.RGBA{20, 30, 40, 255})
+	for _, name := range []string{LayoutCentered, LayoutBottomBar, LayoutCornerCard, LayoutMinimal, LayoutFullBleed} {
+		engine, err := ParseLayout(name)
+		if err != nil {
+			t.Fatalf("ParseLayout(%q) error: %v", name, err)
+		}
+		opts := Options{Layout: engine, Width: w, Height: h}
+		plain, _, err := RenderWithLayout(bg, "TSSH lab", "2026-01-04", opts)
+		if err != nil {
+			t.Fatalf("%s: Render error: %v", name, err)
+		}
+		opts.ProgressBar = true
+		img, l, err := RenderWithLayout(bg, "TSSH lab", "2026-01-04", opts)
+		if err != nil {
+			t.Fatalf("%s: Render with progress bar error: %v", name, err)
+		}
+		region := image.Rect(l.ProgressX, l.ProgressY, l.ProgressX+l.ProgressWidth, l.ProgressY+l.ProgressHeight)
+		if region.Empty() || !region.In(image.Rect(0, 0, w, h)) {
+			t.Fatalf("%s: progress bar region %v outside image", name, region)
+		}
+		if wantH := ProgressBarHeight * h / TargetHeight; region.Dy() != wantH {
+			t.Fatalf("%s: progress bar %d px high, want %d", name, region.Dy(), wantH)
+		}
+		panel := panelRect(l)
+		switch name {
+		case LayoutFullBleed:
+			if region.Min.Y <= l.SubtitleY {
+				t.Fatalf("%s: progress bar at y=%d, want below the subtitle baseline %d", name, region.Min.Y, l.SubtitleY)
+			}
+		case LayoutBottomBar:
+			if region.Max.Y > panel.Min.Y {
+				t.Fatalf("%s: progress bar %v, want above the bar %v", name, region, panel)
+			}
+		default:
+			if region.Min.Y < panel.Max.Y {
+				t.Fatalf("%s: progress bar %v, want below the panel %v", name, region, panel)
+			}
+		}
+		if !bytes.Equal(plain.Pix, img.Pix) {
+			t.Fatalf("%s: expected the reserved region to leave the image unchanged", name)
+		}
+	}
+}
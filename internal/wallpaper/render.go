@@ -2,6 +2,7 @@ package wallpaper
 
 import (
 	_ "embed"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
@@ -9,6 +10,9 @@ import (
 	"math"
 	"strings"
 
+	"github.com/nickhildebrandt/ts-release/internal/diag"
+	"github.com/nickhildebrandt/ts-release/internal/locale"
+	"github.com/nickhildebrandt/ts-release/internal/wallhaven"
 	"golang.org/x/image/draw"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/opentype"
@@ -21,129 +25,438 @@ var regularFontData []byte
 //go:embed fonts/DejaVuSans-Bold.ttf
 var boldFontData []byte
 
+// Colors of the built-in overlay; the box takes its opacity from the layout.
+var (
+	overlayBoxColor    = color.NRGBA{R: 12, G: 16, B: 24}
+	secondaryTextColor = color.NRGBA{R: 210, G: 214, B: 222, A: 255}
+)
+
+// DefaultBrand leads the title unless Options.Brand is set.
+const DefaultBrand = "TSSH"
+
+// Options controls optional decorations added on top of the base title/subtitle overlay.
+// The zero value renders the plain overlay.
+type Options struct {
+	// Brand leads the title, followed by the target name; empty selects DefaultBrand.
+	Brand string
+	// Channel adds a colored release channel badge in the top-right corner.
+	Channel Channel
+	// Watermark tiles a faint diagonal text across the entire image.
+	Watermark Watermark
+	// Tags draws a row of small text badges or icons below the subtitle of the layout engine's output.
+	Tags []Tag
+	// ProgressBar keeps an empty region for a boot progress bar next to the panel and reports it in the Layout, so a
+	// boot splash can draw a live bar that lines up with the image.
+	ProgressBar bool
+	// Filters transform the background and the composed image with user-supplied code, e.g. CommandFilter.
+	Filters Filters
+	// Grain adds film grain to the finished image to hide banding after JPEG compression.
+	Grain Grain
+	// Locale translates fixed strings such as the placeholder for a missing build ID.
+	Locale locale.Catalog
+	// FallbackFonts are TrueType/OpenType fonts consulted in order for runes the embedded fonts cannot draw.
+	FallbackFonts [][]byte
+	// EmojiFont is a color bitmap font (CBDT/CBLC) consulted after FallbackFonts, e.g. Noto Color Emoji.
+	EmojiFont []byte
+	// Direction forces the base direction of the title and subtitle; the zero value behaves like DirectionAuto.
+	Direction Direction
+	// Hinting snaps title, subtitle, and badge glyphs to the pixel grid; the zero value disables hinting.
+	Hinting Hinting
+	// Width and Height set the output resolution; non-positive values select TargetWidth x TargetHeight.
+	Width, Height int
+	// Layout positions the text and overlay; nil selects the centered box (see ParseLayout).
+	Layout LayoutEngine
+	// Scene replaces the layout engine with a custom composition; its text layers must already be expanded.
+	Scene *Scene
+	// Theme adjusts the styling of the layout engine's output; only its background treatment applies to Scene.
+	Theme Theme
+	// FontScale multiplies the font sizes of title and subtitle, on top of Theme.FontScale; zero keeps them.
+	FontScale float64
+	// HQCompositing blends all layers in 16-bit linear light before re-encoding to 8-bit sRGB, which removes
+	// banding in translucent overlays at the cost of roughly a third more render time.
+	HQCompositing bool
+	// Dither quantizes the 16-bit compositing buffer to 8 bits with dithering; any mode other than DitherNone
+	// composites at 16 bits even without HQCompositing.
+	Dither Dither
+	// Crop selects the part of the scaled background that is kept; the zero value behaves like CropCenter.
+	Crop Crop
+	// Focal is a point of the background the crop centers as far as the canvas allows, e.g. a face the automatic crop
+	// cuts; it overrides Crop.
+	Focal *FocalPoint
+	// Fit extends backgrounds whose aspect ratio differs a lot from the canvas's instead of cropping them; the zero
+	// value behaves like FitCrop.
+	Fit Fit
+	// SafeArea keeps the info box and the channel badge clear of the panels of a desktop shell; the zero value checks
+	// nothing.
+	SafeArea SafeArea
+	// Wallhaven fetches the background in Generate, e.g. with a custom http.Client for proxies or extra CAs;
+	// nil selects the public API over http.DefaultClient.
+	Wallhaven *wallhaven.Client
+}
+
+// layoutEngine returns the configured layout engine or the centered default.
+func (o Options) layoutEngine() LayoutEngine {
+	if o.Layout == nil {
+		return centeredLayout{}
+	}
+	return o.Layout
+}
+
+// fontScale returns the product of the font scales of the options and the theme.
+func (o Options) fontScale() float64 {
+	scale := 1.0
+	for _, s := range []float64{o.FontScale, o.Theme.FontScale} {
+		if s > 0 {
+			scale *= s
+		}
+	}
+	return scale
+}
+
+// size returns the output resolution, falling back to the QHD target for non-positive dimensions.
+func (o Options) size() (int, int) {
+	if o.Width <= 0 || o.Height <= 0 {
+		return TargetWidth, TargetHeight
+	}
+	return o.Width, o.Height
+}
+
+// fontChain returns the fonts consulted after the embedded DejaVu font, in lookup order.
+func (o Options) fontChain() [][]byte {
+	if len(o.EmojiFont) == 0 {
+		return o.FallbackFonts
+	}
+	return append(append([][]byte(nil), o.FallbackFonts...), o.EmojiFont)
+}
+
 // Render composes the final wallpaper from the background image and the text labels derived from target/build ID.
 // It returns errors for a nil background, font loading failures, invalid source images (e.g. zero area), or text that is too wide for the target resolution.
-func Render(bg image.Image, targetName string, buildID string) (*image.RGBA, error) {
+func Render(bg image.Image, targetName string, buildID string, opts Options) (*image.RGBA, error) {
+	img, _, err := RenderWithLayout(bg, targetName, buildID, opts)
+	return img, err
+}
+
+// RenderWithLayout is Render that also returns the computed layout, e.g. for aligning external elements with the box.
+// With Options.Scene the layout only carries the size, padding, and title direction. A panic while rendering, e.g. on
+// a background with unusual bounds, is returned as a *diag.PanicError.
+func RenderWithLayout(bg image.Image, targetName string, buildID string, opts Options) (_ *image.RGBA, _ Layout, err error) {
+	defer diag.Recover("render", &err)
 	if bg == nil {
-		return nil, fmt.Errorf("render: background is nil")
+		return nil, Layout{}, fmt.Errorf("render: background is nil")
+	}
+	scene, layout, err := prepareScene(targetName, buildID, opts)
+	if err != nil {
+		return nil, Layout{}, err
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, layout.Width, layout.Height))
+	if err := composeScene(canvas, bg, scene, layout, opts); err != nil {
+		return nil, Layout{}, err
 	}
+	if err := runFilters(HookPostComposite, opts.Filters.PostComposite, canvas); err != nil {
+		return nil, Layout{}, err
+	}
+	if err := applyGrain(canvas, opts.Grain); err != nil {
+		return nil, Layout{}, err
+	}
+	return canvas, layout, nil
+}
+
+// ErrTextTooLong is wrapped by the errors of Render and CheckFit for a title or subtitle that does not fit the image.
+var ErrTextTooLong = errors.New("text is too long for the selected image resolution")
+
+// CheckFit lays out the title and subtitle derived from targetName and buildID at the size of opts with its layout
+// engine and theme, as Render does, but without a background. It returns the error Render would return for text that
+// does not fit, so callers can fail before they fetch a background.
+func CheckFit(targetName string, buildID string, opts Options) error {
+	_, _, err := prepareScene(targetName, buildID, opts)
+	return err
+}
 
+// prepareScene derives title and subtitle from target and build ID and returns the scene to draw with its layout:
+// Options.Scene if set, otherwise the layout engine's composition.
+func prepareScene(targetName string, buildID string, opts Options) (Scene, Layout, error) {
 	// Build text first to measure with the actual faces.
-	title := strings.TrimSpace(targetName)
+	// The title direction follows the target name rather than the brand, which leads the line either way.
+	name := strings.TrimSpace(targetName)
+	titleDir := resolveDirection(name, opts.Direction, DirectionLTR)
+	title := opts.Brand
 	if title == "" {
-		title = "TSSH"
-	} else {
-		title = "TSSH " + title
+		title = DefaultBrand
+	}
+	if name != "" {
+		title += " " + name
 	}
 
 	subtitle := strings.TrimSpace(buildID)
 	if subtitle == "" {
-		subtitle = "build unknown"
+		subtitle = opts.Locale.T("build unknown")
 	}
+	// A subtitle without strong characters (e.g. a bare date) follows the title direction.
+	subtitleDir := resolveDirection(subtitle, opts.Direction, titleDir)
 
-	titleSize := float64(TargetHeight) * 0.06
-	subtitleSize := float64(TargetHeight) * 0.036
+	lang := opts.Locale.Language()
+	title = prepareLine(opts.Theme.Title.transformText(title, lang), titleDir)
+	subtitle = prepareLine(opts.Theme.Subtitle.transformText(subtitle, lang), subtitleDir)
 
-	titleFace, err := loadFace(boldFontData, titleSize)
-	if err != nil {
-		return nil, fmt.Errorf("render: load title font: %w", err)
+	width, height := opts.size()
+	if opts.Scene != nil {
+		layout, err := fitSafeArea(Layout{Width: width, Height: height, Padding: layoutPadding(width, height), Direction: titleDir}, opts)
+		return *opts.Scene, layout, err
 	}
+	return layoutScene(width, height, title, subtitle, titleDir, subtitleDir, opts)
+}
 
-	subtitleFace, err := loadFace(regularFontData, subtitleSize)
-	if err != nil {
-		return nil, fmt.Errorf("render: load subtitle font: %w", err)
+// composeScene draws the scene, channel badge, and watermark onto canvas.
+func composeScene(canvas *image.RGBA, bg image.Image, scene Scene, layout Layout, opts Options) error {
+	comp := newCompositor(canvas, opts.HQCompositing, opts.Dither)
+	if err := drawScene(comp, bg, scene, opts); err != nil {
+		return err
 	}
 
-	layout, err := ComputeLayoutForText(TargetWidth, TargetHeight, titleFace, subtitleFace, title, subtitle)
-	if err != nil {
-		return nil, err
+	if err := comp.paint(func(dst *image.RGBA) error {
+		return drawBadge(dst, layout, opts.Channel, opts.Theme.Badge, opts.Hinting.fontHinting())
+	}); err != nil {
+		return err
 	}
 
-	backgroundLayer, err := resizeAndCrop(bg, layout.Width, layout.Height)
-	if err != nil {
-		return nil, err
+	if err := comp.paint(func(dst *image.RGBA) error {
+		return drawWatermark(dst, opts.Watermark, opts.fontChain()...)
+	}); err != nil {
+		return err
 	}
 
-	canvas := image.NewRGBA(image.Rect(0, 0, layout.Width, layout.Height))
-	stddraw.Draw(canvas, canvas.Bounds(), backgroundLayer, image.Point{}, stddraw.Src)
+	comp.finish()
+	return nil
+}
 
-	boxColor := color.NRGBA{R: 12, G: 16, B: 24, A: layout.BoxOpacity}
-	overlay := image.NewRGBA(canvas.Bounds())
-	drawRoundedRect(overlay, image.Rect(layout.BoxX0, layout.BoxY0, layout.BoxX1, layout.BoxY1), layout.BoxRadius, boxColor)
-	stddraw.Draw(canvas, overlay.Bounds(), overlay, image.Point{}, stddraw.Over)
+// layoutScene measures title and subtitle with the layout engine's font sizes and builds the equivalent scene:
+// background, box, separator, and both lines at the computed positions.
+// It returns errors for font loading, layout failures, and text that is too wide for the resolution.
+func layoutScene(width, height int, title, subtitle string, titleDir, subtitleDir Direction, opts Options) (Scene, Layout, error) {
+	engine := opts.layoutEngine()
+	fallbacks := opts.fontChain()
+	titleSize, subtitleSize := engine.FontSizes(width, height)
+	scale := opts.fontScale()
+	titleSize, subtitleSize = titleSize*scale, subtitleSize*scale
+
+	loadTitle := func(size float64) (font.Face, error) {
+		return loadFaceChain(size, opts.Hinting.fontHinting(), boldFontData, fallbacks...)
+	}
+	loadSubtitle := func(size float64) (font.Face, error) {
+		return loadFaceChain(size, opts.Hinting.fontHinting(), regularFontData, fallbacks...)
+	}
 
-	lineColor := color.NRGBA{R: 255, G: 255, B: 255, A: 140}
-	titleWidth := font.MeasureString(titleFace, title).Ceil()
-	subtitleWidth := font.MeasureString(subtitleFace, subtitle).Ceil()
-	longestTextWidth := maxInt(titleWidth, subtitleWidth)
-	drawSeparator(canvas, layout, lineColor, longestTextWidth)
+	titleFace, err := loadTitle(titleSize)
+	if err == nil {
+		titleFace, err = opts.Theme.Title.styledFace(titleFace, titleSize, loadTitle)
+	}
+	if err != nil {
+		return Scene{}, Layout{}, fmt.Errorf("render: load title font: %w", err)
+	}
 
-	textColor := color.NRGBA{R: 241, G: 243, B: 246, A: 255}
-	secondaryText := color.NRGBA{R: 210, G: 214, B: 222, A: 255}
+	subtitleFace, err := loadSubtitle(subtitleSize)
+	if err == nil {
+		subtitleFace, err = opts.Theme.Subtitle.styledFace(subtitleFace, subtitleSize, loadSubtitle)
+	}
+	if err != nil {
+		return Scene{}, Layout{}, fmt.Errorf("render: load subtitle font: %w", err)
+	}
 
-	maxTextWidth, err := maxTextWidthForImage(layout.Width)
+	tags, err := measureTags(opts.Tags, subtitleSize, titleDir, opts)
+	if err != nil {
+		return Scene{}, Layout{}, err
+	}
+	in := LayoutInput{
+		Width: width, Height: height,
+		TitleFace: titleFace, SubtitleFace: subtitleFace,
+		Title: title, Subtitle: subtitle,
+		TitleDir: titleDir, SubtitleDir: subtitleDir,
+	}
+	if tags != nil {
+		in.RowWidth, in.RowHeight = tags.width, tags.height
+	}
+	layout, err := engine.Compute(in)
 	if err != nil {
-		return nil, err
+		return Scene{}, Layout{}, err
+	}
+	if tags != nil && layout.RowHeight == 0 {
+		return Scene{}, Layout{}, fmt.Errorf("render: layout %s has no place for tags", engine.Name())
 	}
+	layout = alignLayout(layout, in, opts.Theme)
 
-	if err := validateTextWidth("title", titleFace, title, maxTextWidth); err != nil {
-		return nil, err
+	maxTextWidth, err := maxTextWidthForImage(layout.Width)
+	if err != nil {
+		return Scene{}, Layout{}, err
 	}
-	if err := drawText(canvas, titleFace, title, layout.TitleX, layout.TitleY, textColor); err != nil {
-		return nil, err
+	if err := validateTextWidth("title", titleFace, title, maxTextWidth); err != nil {
+		return Scene{}, Layout{}, err
 	}
 	if err := validateTextWidth("subtitle", subtitleFace, subtitle, maxTextWidth); err != nil {
-		return nil, err
+		return Scene{}, Layout{}, err
 	}
-	if err := drawText(canvas, subtitleFace, subtitle, layout.SubtitleX, layout.SubtitleY, secondaryText); err != nil {
-		return nil, err
+	if tags != nil && tags.width > maxTextWidth {
+		return Scene{}, Layout{}, fmt.Errorf("render: tags %w (%d px wide, at most %d px fit), please remove some", ErrTextTooLong, tags.width, maxTextWidth)
+	}
+	titleWidth := font.MeasureString(titleFace, title).Ceil()
+	subtitleWidth := font.MeasureString(subtitleFace, subtitle).Ceil()
+	if opts.ProgressBar {
+		textBottom := layout.SubtitleY + subtitleFace.Metrics().Descent.Ceil()
+		textWidth := maxInt(maxInt(titleWidth, subtitleWidth), layout.RowWidth)
+		if layout.RowHeight > 0 {
+			textBottom = maxInt(textBottom, layout.RowY+layout.RowHeight)
+		}
+		if layout, err = placeProgressBar(layout, textBottom, textWidth); err != nil {
+			return Scene{}, Layout{}, err
+		}
 	}
 
-	return canvas, nil
+	if layout, err = fitSafeArea(layout, opts); err != nil {
+		return Scene{}, Layout{}, err
+	}
+
+	scene := Scene{Width: layout.Width, Height: layout.Height, Layers: []Layer{{Type: LayerBackground}}}
+	// Theme radii are in pixels at TargetHeight, like stroke widths.
+	corners := opts.Theme.Panel.Corners.scaled(float64(height) / TargetHeight)
+	switch {
+	case opts.Theme.Panel.Color != "":
+		box := panelRect(layout)
+		scene.Layers = append(scene.Layers, Layer{
+			Type: LayerRect, Color: opts.Theme.Panel.Color, Radius: float64(layout.BoxRadius), Corners: corners,
+			X: float64(box.Min.X), Y: float64(box.Min.Y), W: float64(box.Dx()), H: float64(box.Dy()),
+		})
+	case layout.BoxOpacity > 0:
+		boxColor := overlayBoxColor
+		boxColor.A = layout.BoxOpacity
+		scene.Layers = append(scene.Layers, Layer{
+			Type: LayerRect, Color: hexColor(boxColor), Radius: float64(layout.BoxRadius), Corners: corners,
+			X: float64(layout.BoxX0), Y: float64(layout.BoxY0),
+			W: float64(layout.BoxX1 - layout.BoxX0), H: float64(layout.BoxY1 - layout.BoxY0),
+		})
+	}
+	if layout.SeparatorThickness > 0 {
+		thickness := int(math.Round(opts.Theme.Separator.Thickness * float64(height) / TargetHeight))
+		if opts.Theme.Separator.Thickness > 0 {
+			thickness = maxInt(1, thickness)
+		}
+		separator := separatorLayer(layout, maxInt(titleWidth, subtitleWidth), opts.Theme.Separator.Style, thickness)
+		// The translucent white default would vanish on a light panel, so the separator follows a themed title.
+		separator.Color = opts.Theme.Separator.Color
+		if separator.Color == "" {
+			separator.Color = opts.Theme.Title.Color
+		}
+		scene.Layers = append(scene.Layers, separator)
+	}
+	subtitleColor := opts.Theme.Subtitle.Color
+	if subtitleColor == "" {
+		subtitleColor = hexColor(secondaryTextColor)
+	}
+
+	// The built-in scene is in output pixels, while theme stroke widths are in pixels at TargetHeight.
+	strokeScale := float64(height) / TargetHeight
+	scene.Layers = append(scene.Layers,
+		Layer{
+			Type:     LayerText,
+			Text:     title,
+			X:        float64(layout.TitleX),
+			Y:        float64(layout.TitleY),
+			Color:    opts.Theme.Title.Color,
+			Gradient: opts.Theme.Title.Gradient,
+			Stroke:   opts.Theme.Title.Stroke.scaled(strokeScale),
+			face:     titleFace,
+			visual:   true,
+		},
+		Layer{
+			Type:     LayerText,
+			Text:     subtitle,
+			X:        float64(layout.SubtitleX),
+			Y:        float64(layout.SubtitleY),
+			Color:    subtitleColor,
+			Gradient: opts.Theme.Subtitle.Gradient,
+			Stroke:   opts.Theme.Subtitle.Stroke.scaled(strokeScale),
+			face:     subtitleFace,
+			visual:   true,
+		},
+	)
+	if tags != nil {
+		// Tags follow the subtitle color.
+		tagColor, err := parseHexColor(subtitleColor, secondaryTextColor)
+		if err != nil {
+			return Scene{}, Layout{}, fmt.Errorf("render: tags: %w", err)
+		}
+		scene.Layers = append(scene.Layers, tags.layers(layout, tagColor)...)
+	}
+	return scene, layout, nil
+}
+
+// hexColor formats col as "#rrggbbaa" for scene layers.
+func hexColor(col color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x%02x", col.R, col.G, col.B, col.A)
 }
 
 // Generate is the public entry point that wires background fetching and rendering for the target resolution.
-// Network/decode failures and rendering validation errors are propagated to the caller.
-func Generate(targetName string, buildID string) (*image.RGBA, error) {
-	bg, err := FetchBackground(TargetWidth, TargetHeight)
+// It also returns the background source URL; network/decode failures and rendering validation errors are propagated to the caller.
+func Generate(targetName string, buildID string, opts Options) (*image.RGBA, string, error) {
+	img, _, sourceURL, err := GenerateWithLayout(targetName, buildID, opts)
+	return img, sourceURL, err
+}
+
+// GenerateWithLayout is Generate that also returns the computed layout (see RenderWithLayout).
+func GenerateWithLayout(targetName string, buildID string, opts Options) (*image.RGBA, Layout, string, error) {
+	width, height := opts.size()
+	client := opts.Wallhaven
+	if client == nil {
+		client = &wallhaven.Client{}
+	}
+	bg, sourceURL, err := FetchBackgroundWithClient(client, width, height)
+	if err != nil {
+		return nil, Layout{}, "", err
+	}
+	img, layout, err := RenderWithLayout(bg, targetName, buildID, opts)
 	if err != nil {
-		return nil, err
+		return nil, Layout{}, "", err
 	}
-	return Render(bg, targetName, buildID)
+	return img, layout, sourceURL, nil
 }
 
-// resizeAndCrop scales the source image to fully cover the target area and then center-crops to the requested size.
-// It returns an error when the source image has zero width or height.
-func resizeAndCrop(src image.Image, width, height int) (*image.RGBA, error) {
+// resizeAndCrop scales the source image to fully cover the target area and then crops it to the requested size as rule
+// selects (see cropOffset), or extends it if the rule's fit asks for that. Downscaled backgrounds get the rule's unsharp
+// mask (see sharpenStrength). It returns an error when the source image
+// has zero width or height, or when it is cropped and its aspect ratio is so far from the target's that the scaled image would exceed both DefaultMaxPixels and four times the target area, e.g. a 1×10000 strip.
+func resizeAndCrop(src image.Image, width, height int, rule cropRule) (*image.RGBA, error) {
 	bounds := src.Bounds()
 	if bounds.Dx() == 0 || bounds.Dy() == 0 {
 		return nil, fmt.Errorf("render: background has zero area")
 	}
+	if rule.fit.extends(bounds, width, height) {
+		return extend(src, width, height, rule.fit, rule.sharpen), nil
+	}
 
 	scale := math.Max(float64(width)/float64(bounds.Dx()), float64(height)/float64(bounds.Dy()))
-	scaledW := int(math.Ceil(float64(bounds.Dx()) * scale))
-	scaledH := int(math.Ceil(float64(bounds.Dy()) * scale))
+	scaledW := math.Ceil(float64(bounds.Dx()) * scale)
+	scaledH := math.Ceil(float64(bounds.Dy()) * scale)
+	if limit := math.Max(DefaultMaxPixels, 4*float64(width)*float64(height)); scaledW*scaledH > limit {
+		return nil, fmt.Errorf("render: %dx%d background would scale to %.0fx%.0f to cover %dx%d; its aspect ratio is too extreme",
+			bounds.Dx(), bounds.Dy(), scaledW, scaledH, width, height)
+	}
 
-	scaled := image.NewRGBA(image.Rect(0, 0, scaledW, scaledH))
+	scaled := image.NewRGBA(image.Rect(0, 0, int(scaledW), int(scaledH)))
 	draw.CatmullRom.Scale(scaled, scaled.Bounds(), src, bounds, draw.Src, nil)
 
-	offsetX := (scaledW - width) / 2
-	offsetY := (scaledH - height) / 2
-
 	cropped := image.NewRGBA(image.Rect(0, 0, width, height))
-	stddraw.Draw(cropped, cropped.Bounds(), scaled, image.Point{X: offsetX, Y: offsetY}, stddraw.Src)
+	stddraw.Draw(cropped, cropped.Bounds(), scaled, cropOffset(scaled, width, height, rule), stddraw.Src)
+	sharpen(cropped, sharpenStrength(rule.sharpen, scale))
 	return cropped, nil
 }
 
-// loadFace parses TrueType/OpenType font bytes and constructs a font.Face at the requested size.
+// loadFace parses TrueType/OpenType font bytes and constructs a font.Face at the requested size and hinting.
 // It returns an error if the font data is invalid or a face cannot be created.
-func loadFace(fontData []byte, size float64) (font.Face, error) {
+func loadFace(fontData []byte, size float64, hinting font.Hinting) (font.Face, error) {
 	parsed, err := opentype.Parse(fontData)
 	if err != nil {
 		return nil, fmt.Errorf("render: parse font: %w", err)
 	}
 
-	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72})
+	face, err := newFace(parsed, size, hinting)
 	if err != nil {
 		return nil, fmt.Errorf("render: construct font face: %w", err)
 	}
@@ -153,36 +466,7 @@ func loadFace(fontData []byte, size float64) (font.Face, error) {
 // drawRoundedRect draws a (optionally) rounded, semi-transparent rectangle into the destination image.
 // For radius <= 0 it draws a plain rectangle, and large radii are clamped to the box dimensions.
 func drawRoundedRect(dst *image.RGBA, rect image.Rectangle, radius int, col color.NRGBA) {
-	if radius <= 0 {
-		stddraw.Draw(dst, rect, image.NewUniform(col), image.Point{}, stddraw.Over)
-		return
-	}
-
-	radius = minInt(radius, minInt(rect.Dx()/2, rect.Dy()/2))
-	// Build a zero-based mask the size of the box to avoid affecting pixels outside the box bounds.
-	maskRect := image.Rect(0, 0, rect.Dx(), rect.Dy())
-	mask := image.NewAlpha(maskRect)
-	fillRoundedMask(mask, radius)
-	stddraw.DrawMask(dst, rect, image.NewUniform(col), image.Point{}, mask, image.Point{}, stddraw.Over)
-}
-
-// drawSeparator draws the horizontal separator line inside the text box and sizes it to the wider text.
-// Overly large widths are clamped so the line never extends beyond the box.
-func drawSeparator(dst *image.RGBA, layout Layout, col color.NRGBA, textWidth int) {
-	lineHeight := layout.SeparatorThickness
-	maxWidth := layout.BoxWidth - 2*layout.Padding
-	if textWidth > maxWidth {
-		textWidth = maxWidth
-	}
-	extra := maxInt(layout.Padding/4, 10)
-	desiredWidth := textWidth + extra
-	if desiredWidth > maxWidth {
-		desiredWidth = maxWidth
-	}
-	startX := layout.BoxX0 + (layout.BoxWidth-desiredWidth)/2
-	endX := startX + desiredWidth
-	lineRect := image.Rect(startX, layout.SeparatorY-lineHeight/2, endX, layout.SeparatorY+lineHeight/2)
-	stddraw.Draw(dst, lineRect, image.NewUniform(col), image.Point{}, stddraw.Over)
+	drawShapedRect(dst, rect, [4]int{radius, radius, radius, radius}, 2, col)
 }
 
 // drawText renders text at a fixed pixel position into the destination image.
@@ -191,13 +475,31 @@ func drawText(dst *image.RGBA, face font.Face, text string, x, y int, col color.
 	if face == nil {
 		return fmt.Errorf("render: font face is nil")
 	}
-	drawer := &font.Drawer{
-		Dst:  dst,
-		Src:  image.NewUniform(col),
-		Face: face,
-		Dot:  fixed.Point26_6{X: fixed.I(x), Y: fixed.I(y)},
+	src := image.NewUniform(col)
+	dot := fixed.Point26_6{X: fixed.I(x), Y: fixed.I(y)}
+	colorFace, ok := face.(colorGlyphFace)
+	if !ok {
+		drawer := &font.Drawer{Dst: dst, Src: src, Face: face, Dot: dot}
+		drawer.DrawString(text)
+		return nil
+	}
+
+	// Color glyphs keep their own colors and only take the text opacity.
+	opacity := image.NewUniform(color.Alpha{A: col.A})
+	prev := rune(-1)
+	for _, r := range text {
+		if prev >= 0 {
+			dot.X += face.Kern(prev, r)
+		}
+		prev = r
+		advance, _ := face.GlyphAdvance(r)
+		if dr, img, ok := colorFace.ColorGlyph(dot, r); ok {
+			stddraw.DrawMask(dst, dr, img, image.Point{}, opacity, image.Point{}, stddraw.Over)
+		} else if dr, mask, maskp, _, ok := face.Glyph(dot, r); ok {
+			stddraw.DrawMask(dst, dr, src, image.Point{}, mask, maskp, stddraw.Over)
+		}
+		dot.X += advance
 	}
-	drawer.DrawString(text)
 	return nil
 }
 
@@ -205,11 +507,11 @@ func drawText(dst *image.RGBA, face font.Face, text string, x, y int, col color.
 // It returns a user-facing error when the width is invalid or the text exceeds the limit.
 func validateTextWidth(label string, face font.Face, text string, maxWidth int) error {
 	if maxWidth <= 0 {
-		return fmt.Errorf("render: %s text is too long for the selected image resolution, please reduce the text", label)
+		return fmt.Errorf("render: %s %w, please reduce the text", label, ErrTextTooLong)
 	}
 	advance := font.MeasureString(face, text)
 	if advance.Ceil() > maxWidth {
-		return fmt.Errorf("render: %s text is too long for the selected image resolution, please reduce the text", label)
+		return fmt.Errorf("render: %s %w (%d px wide, at most %d px fit), please reduce the text", label, ErrTextTooLong, advance.Ceil(), maxWidth)
 	}
 	return nil
 }
@@ -223,7 +525,7 @@ func maxTextWidthForImage(imageWidth int) (int, error) {
 	margin := maxInt(24, int(math.Round(float64(imageWidth)*0.15)))
 	maxWidth := imageWidth - 2*margin
 	if maxWidth <= 0 {
-		return 0, fmt.Errorf("render: text is too long for the selected image resolution, please reduce the text")
+		return 0, fmt.Errorf("render: %w, please reduce the text", ErrTextTooLong)
 	}
 	return maxWidth, nil
 }
@@ -1,11 +1,13 @@
 package wallpaper
 
 import (
+	"errors"
 	"image"
 	"image/color"
 	"strings"
 	"testing"
 
+	"github.com/nickhildebrandt/ts-release/internal/diag"
 	"golang.org/x/image/font"
 )
 
@@ -44,11 +46,11 @@ func mustRenderFaces(t *testing.T) (font.Face, font.Face) {
 	titleSize := float64(TargetHeight) * 0.06
 	subtitleSize := float64(TargetHeight) * 0.036
 
-	titleFace, err := loadFace(boldFontData, titleSize)
+	titleFace, err := loadFace(boldFontData, titleSize, font.HintingNone)
 	if err != nil {
 		t.Fatalf("load title face: %v", err)
 	}
-	subtitleFace, err := loadFace(regularFontData, subtitleSize)
+	subtitleFace, err := loadFace(regularFontData, subtitleSize, font.HintingNone)
 	if err != nil {
 		t.Fatalf("load subtitle face: %v", err)
 	}
@@ -119,7 +121,7 @@ func findTooLongText(t *testing.T, label string, face font.Face, prefix string,
 // The test fails if scaling/cropping or canvas creation produces incorrect bounds.
 func TestRender_ReturnsTargetResolution(t *testing.T) {
 	bg := solidBG(64, 64, color.RGBA{0, 0, 0, 255})
-	img, err := Render(bg, "test", "build-1")
+	img, err := Render(bg, "test", "build-1", Options{})
 	if err != nil {
 		t.Fatalf("Render error: %v", err)
 	}
@@ -136,7 +138,7 @@ func TestRender_ReturnsTargetResolution(t *testing.T) {
 // The test fails if Render does not handle empty strings robustly.
 func TestRender_EmptyTargetNameAndSubtitle_DefaultsAndNoPanic(t *testing.T) {
 	bg := solidBG(16, 16, color.RGBA{0, 0, 0, 255})
-	img, err := Render(bg, "   ", "")
+	img, err := Render(bg, "   ", "", Options{})
 	if err != nil {
 		t.Fatalf("Render error: %v", err)
 	}
@@ -145,10 +147,30 @@ func TestRender_EmptyTargetNameAndSubtitle_DefaultsAndNoPanic(t *testing.T) {
 	}
 }
 
+// panickyImage is a background whose pixels cannot be read, standing in for a decoder bug.
+type panickyImage struct{ image.Image }
+
+func (panickyImage) At(x, y int) color.Color { panic("pixel out of range") }
+
+// TestRender_PanicBecomesError expects a panic while rendering to be returned as a *diag.PanicError with its stack by
+// Render, RenderSpan, and RenderFrames. The test fails if the panic escapes.
+func TestRender_PanicBecomesError(t *testing.T) {
+	bg := panickyImage{image.NewRGBA(image.Rect(0, 0, 16, 16))}
+	_, renderErr := Render(bg, "x", "y", Options{})
+	_, _, spanErr := RenderSpan(bg, "x", "y", Options{}, []Monitor{{Width: 64, Height: 32}}, 0)
+	_, framesErr := RenderFrames(bg, "x", "y", Options{}, Animation{Frames: 2}, func(int, *image.RGBA) error { return nil })
+	for _, err := range []error{renderErr, spanErr, framesErr} {
+		var panicErr *diag.PanicError
+		if !errors.As(err, &panicErr) || err.Error() != "render: internal error: pixel out of range" || len(panicErr.Stack) == 0 {
+			t.Fatalf("expected the panic as an error, got %v", err)
+		}
+	}
+}
+
 // TestRender_ErrorsOnNilBackground expects an error when no background image is provided.
 // This ensures Render validates early and does not panic later.
 func TestRender_ErrorsOnNilBackground(t *testing.T) {
-	img, err := Render(nil, "x", "y")
+	img, err := Render(nil, "x", "y", Options{})
 	if err == nil {
 		t.Fatalf("expected error")
 	}
@@ -183,7 +205,7 @@ func TestRender_TextTooLong_Boundaries_26vs27(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		img, err := Render(bg, c.target, c.buildID)
+		img, err := Render(bg, c.target, c.buildID, Options{})
 		if c.wantError {
 			if err == nil {
 				t.Fatalf("%s: expected error", c.name)
@@ -205,6 +227,41 @@ func TestRender_TextTooLong_Boundaries_26vs27(t *testing.T) {
 	}
 }
 
+// TestCheckFit_AgreesWithRenderWithoutBackground verifies the early text check at several sizes and layouts.
+// The test fails if CheckFit accepts text that Render rejects or the other way round, or if its error does not wrap
+// ErrTextTooLong.
+func TestCheckFit_AgreesWithRenderWithoutBackground(t *testing.T) {
+	bg := solidBG(32, 32, color.RGBA{0, 0, 0, 255})
+	titleFace, _ := mustRenderFaces(t)
+	_, tooLong := findLenBoundary(t, "title", titleFace, "TSSH ", 26, mustMaxTextWidth(t))
+
+	cases := []struct {
+		name    string
+		target  string
+		opts    Options
+		wantErr bool
+	}{
+		{name: "fits", target: "alpha-beta-gamma", opts: Options{}},
+		{name: "too long", target: tooLong, opts: Options{}, wantErr: true},
+		{name: "portrait panel", target: "alpha-beta-gamma", opts: Options{Width: 480, Height: 800}, wantErr: true},
+		{name: "bottom bar", target: "alpha-beta-gamma", opts: Options{Layout: bottomBarLayout{}}},
+		{name: "bottom bar on a portrait panel", target: "alpha-beta-gamma", opts: Options{Layout: bottomBarLayout{}, Width: 800, Height: 1280}, wantErr: true},
+	}
+	for _, c := range cases {
+		fitErr := CheckFit(c.target, "2026-01-04T13:35:13Z", c.opts)
+		_, renderErr := Render(bg, c.target, "2026-01-04T13:35:13Z", c.opts)
+		if (fitErr != nil) != c.wantErr || (renderErr != nil) != c.wantErr {
+			t.Fatalf("%s: CheckFit error %v, Render error %v, want error %v", c.name, fitErr, renderErr, c.wantErr)
+		}
+		if fitErr != nil && !errors.Is(fitErr, ErrTextTooLong) {
+			t.Fatalf("%s: error does not wrap ErrTextTooLong: %v", c.name, fitErr)
+		}
+	}
+	if err := CheckFit(tooLong, "id", Options{}); err == nil || !strings.Contains(err.Error(), " px fit") {
+		t.Fatalf("expected the error to report the widths, got %v", err)
+	}
+}
+
 // TestDrawSeparator_WidthUsesWiderOfTitleOrSubtitle verifies the separator line width follows the wider text line.
 // The test fails if the line is too short/long or drawn outside the box.
 func TestDrawSeparator_WidthUsesWiderOfTitleOrSubtitle(t *testing.T) {
@@ -222,7 +279,7 @@ func TestDrawSeparator_WidthUsesWiderOfTitleOrSubtitle(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		img, err := Render(bg, c.target, c.buildID)
+		img, err := Render(bg, c.target, c.buildID, Options{})
 		if err != nil {
 			t.Fatalf("%s: Render error: %v", c.name, err)
 		}
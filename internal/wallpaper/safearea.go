@@ -0,0 +1,235 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// Shell names a desktop shell whose panels cover parts of the wallpaper.
+type Shell string
+
+const (
+	// ShellNone assumes no panels; nothing is checked.
+	ShellNone Shell = "none"
+	// ShellGNOME has the top bar.
+	ShellGNOME Shell = "gnome"
+	// ShellKDE has the Plasma panel along the bottom edge, with the clock and system tray at its end.
+	ShellKDE Shell = "kde"
+	// ShellWindows has the taskbar along the bottom edge.
+	ShellWindows Shell = "windows"
+	// ShellMacOS has the menu bar and the Dock.
+	ShellMacOS Shell = "macos"
+)
+
+// Shells returns all shell profiles in a stable order for help output and validation.
+func Shells() []Shell {
+	return []Shell{ShellNone, ShellGNOME, ShellKDE, ShellWindows, ShellMacOS}
+}
+
+// ParseShell converts a user-supplied shell name into a Shell; empty selects ShellNone.
+func ParseShell(name string) (Shell, error) {
+	if name == "" {
+		return ShellNone, nil
+	}
+	for _, s := range Shells() {
+		if string(s) == name {
+			return s, nil
+		}
+	}
+	return ShellNone, fmt.Errorf("render: unknown shell %q", name)
+}
+
+// SafeAreaMode selects what happens when the info box or the channel badge collides with a panel of the shell.
+type SafeAreaMode string
+
+const (
+	// SafeAreaWarn leaves the layout alone; callers report the collisions of SafeAreaCollisions.
+	SafeAreaWarn SafeAreaMode = "warn"
+	// SafeAreaNudge moves the box and the badge vertically clear of the panels where the canvas leaves room.
+	SafeAreaNudge SafeAreaMode = "nudge"
+	// SafeAreaFail fails rendering, and CheckFit, on a collision.
+	SafeAreaFail SafeAreaMode = "fail"
+)
+
+// SafeAreaModes returns all safe area modes in a stable order for help output and validation.
+func SafeAreaModes() []SafeAreaMode {
+	return []SafeAreaMode{SafeAreaWarn, SafeAreaNudge, SafeAreaFail}
+}
+
+// ParseSafeAreaMode converts a user-supplied mode name into a SafeAreaMode; empty selects SafeAreaWarn.
+func ParseSafeAreaMode(name string) (SafeAreaMode, error) {
+	if name == "" {
+		return SafeAreaWarn, nil
+	}
+	for _, m := range SafeAreaModes() {
+		if string(m) == name {
+			return m, nil
+		}
+	}
+	return SafeAreaWarn, fmt.Errorf("render: unknown safe area mode %q", name)
+}
+
+// SafeArea keeps the info box and the channel badge clear of the panels of a desktop shell. The zero value checks
+// nothing.
+type SafeArea struct {
+	Shell Shell
+	Mode  SafeAreaMode
+}
+
+// ShellRegion is a part of the screen a shell panel covers.
+type ShellRegion struct {
+	Name string
+	Rect image.Rectangle
+}
+
+// shellPanel is a panel along the top or bottom edge in logical pixels, over the middle widthPercent of the screen.
+type shellPanel struct {
+	name         string
+	bottom       bool
+	size         int
+	widthPercent int
+}
+
+// shellPanels lists the panels of the default configuration of each shell.
+var shellPanels = map[Shell][]shellPanel{
+	ShellGNOME:   {{name: "GNOME top bar", size: 32, widthPercent: 100}},
+	ShellKDE:     {{name: "KDE panel", bottom: true, size: 48, widthPercent: 100}},
+	ShellWindows: {{name: "Windows taskbar", bottom: true, size: 48, widthPercent: 100}},
+	ShellMacOS: {
+		{name: "macOS menu bar", size: 24, widthPercent: 100},
+		{name: "macOS Dock", bottom: true, size: 80, widthPercent: 60},
+	},
+}
+
+// uiScale returns the integer scale a shell picks for a screen of the given height: 1 up to 1440p, 2 at 2160p.
+func uiScale(height int) int {
+	return max(1, height/1080)
+}
+
+// Regions returns the parts of a width × height screen the panels of s cover, scaled as the shell scales its UI.
+func (s Shell) Regions(width, height int) []ShellRegion {
+	scale := uiScale(height)
+	var regions []ShellRegion
+	for _, p := range shellPanels[s] {
+		w := width * p.widthPercent / 100
+		r := image.Rect((width-w)/2, 0, (width+w)/2, p.size*scale)
+		if p.bottom {
+			r = r.Add(image.Pt(0, height-p.size*scale))
+		}
+		regions = append(regions, ShellRegion{Name: p.name, Rect: r})
+	}
+	return regions
+}
+
+// boxRect returns the info box of l as the safe area checks it: empty for scenes, and for a box covering the whole
+// canvas as in full-bleed layouts, which no move could clear.
+func (l Layout) boxRect() image.Rectangle {
+	r := image.Rect(l.BoxX0, l.BoxY0, l.BoxX1, l.BoxY1)
+	if r == image.Rect(0, 0, l.Width, l.Height) {
+		return image.Rectangle{}
+	}
+	return r
+}
+
+// badgeRect returns the channel badge of l, which is empty without a badge.
+func (l Layout) badgeRect() image.Rectangle {
+	return image.Rect(l.BadgeX0, l.BadgeY0, l.BadgeX1, l.BadgeY1)
+}
+
+// SafeAreaCollisions describes each collision of the info box or the channel badge of layout with a panel of shell,
+// e.g. "info box collides with the KDE panel".
+func SafeAreaCollisions(layout Layout, shell Shell) []string {
+	var out []string
+	for _, r := range shell.Regions(layout.Width, layout.Height) {
+		for _, e := range []struct {
+			name string
+			rect image.Rectangle
+		}{{"info box", layout.boxRect()}, {"channel badge", layout.badgeRect()}} {
+			if e.rect.Overlaps(r.Rect) {
+				out = append(out, fmt.Sprintf("%s collides with the %s", e.name, r.Name))
+			}
+		}
+	}
+	return out
+}
+
+// fitSafeArea places the channel badge in layout and applies the safe area of opts: it nudges the box and the badge
+// clear of the shell's panels, or returns an error for a collision in SafeAreaFail mode.
+func fitSafeArea(layout Layout, opts Options) (Layout, error) {
+	if opts.Channel != ChannelNone {
+		rect, err := placeBadge(layout, opts.Channel, opts.Hinting.fontHinting())
+		if err != nil {
+			return Layout{}, err
+		}
+		layout.BadgeX0, layout.BadgeY0, layout.BadgeX1, layout.BadgeY1 = rect.Min.X, rect.Min.Y, rect.Max.X, rect.Max.Y
+	}
+	shell := opts.SafeArea.Shell
+	switch opts.SafeArea.Mode {
+	case SafeAreaNudge:
+		regions := shell.Regions(layout.Width, layout.Height)
+		gap := layout.Padding / 2
+		if dy, ok := nudge(layout.boxRect(), regions, layout.Height, gap); ok && dy != 0 {
+			layout = layout.shiftBox(dy)
+		}
+		if dy, ok := nudge(layout.badgeRect(), regions, layout.Height, gap); ok && dy != 0 {
+			layout.BadgeY0 += dy
+			layout.BadgeY1 += dy
+		}
+	case SafeAreaFail:
+		if c := SafeAreaCollisions(layout, shell); len(c) > 0 {
+			return Layout{}, fmt.Errorf("render: %s", strings.Join(c, "; "))
+		}
+	}
+	return layout, nil
+}
+
+// nudge returns how far r must move down (positive) or up (negative) to keep gap pixels clear of the top and bottom
+// panels it collides with. It reports false if no such move keeps r on a canvas of the given height and clear of all
+// regions.
+func nudge(r image.Rectangle, regions []ShellRegion, height, gap int) (int, bool) {
+	if r.Empty() {
+		return 0, true
+	}
+	down, up := 0, 0
+	for _, region := range regions {
+		if !r.Overlaps(region.Rect) {
+			continue
+		}
+		if region.Rect.Min.Y == 0 {
+			down = max(down, region.Rect.Max.Y+gap-r.Min.Y)
+		} else {
+			up = min(up, region.Rect.Min.Y-gap-r.Max.Y)
+		}
+	}
+	if down != 0 && up != 0 {
+		return 0, false
+	}
+	dy := down + up
+	moved := r.Add(image.Pt(0, dy))
+	if moved.Min.Y < 0 || moved.Max.Y > height {
+		return 0, false
+	}
+	for _, region := range regions {
+		if moved.Overlaps(region.Rect) {
+			return 0, false
+		}
+	}
+	return dy, true
+}
+
+// shiftBox moves the box and everything laid out in it down by dy pixels.
+func (l Layout) shiftBox(dy int) Layout {
+	l.BoxY0 += dy
+	l.BoxY1 += dy
+	l.TitleY += dy
+	l.SubtitleY += dy
+	l.SeparatorY += dy
+	if l.RowHeight > 0 {
+		l.RowY += dy
+	}
+	if l.ProgressHeight > 0 {
+		l.ProgressY += dy
+	}
+	return l
+}
@@ -0,0 +1,73 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestSafeArea_ShellPanels expects the panels of each shell to scale with the UI scale of the screen, a bottom bar at
+// 1080p to collide with the KDE panel, and the nudge mode to move it clear while the fail mode refuses it.
+// The test fails if a panel is misplaced, a collision goes unnoticed, or the nudged box still collides.
+func TestSafeArea_ShellPanels(t *testing.T) {
+	for _, c := range []struct {
+		shell         Shell
+		width, height int
+		want          []ShellRegion
+	}{
+		{ShellNone, 1920, 1080, nil},
+		{ShellKDE, 3840, 2160, []ShellRegion{{"KDE panel", image.Rect(0, 2064, 3840, 2160)}}},
+		{ShellGNOME, 2560, 1440, []ShellRegion{{"GNOME top bar", image.Rect(0, 0, 2560, 32)}}},
+		{ShellMacOS, 1920, 1080, []ShellRegion{
+			{"macOS menu bar", image.Rect(0, 0, 1920, 24)},
+			{"macOS Dock", image.Rect(384, 1000, 1536, 1080)},
+		}},
+	} {
+		if got := c.shell.Regions(c.width, c.height); !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%s at %dx%d: regions %v, want %v", c.shell, c.width, c.height, got, c.want)
+		}
+	}
+
+	bottomBar, err := ParseLayout(LayoutBottomBar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bg := solidBG(32, 32, color.RGBA{0, 0, 0, 255})
+	opts := Options{Width: 1920, Height: 1080, Layout: bottomBar, Channel: ChannelBeta, SafeArea: SafeArea{Shell: ShellKDE}}
+	_, layout, err := RenderWithLayout(bg, "t", "b", opts)
+	if err != nil {
+		t.Fatalf("warn: %v", err)
+	}
+	if got := SafeAreaCollisions(layout, ShellKDE); !reflect.DeepEqual(got, []string{"info box collides with the KDE panel"}) {
+		t.Fatalf("collisions %q, want the info box and the KDE panel", got)
+	}
+	if layout.BadgeX1 != 1920-layout.Padding || layout.BadgeY0 != layout.Padding || layout.BadgeY1 <= layout.BadgeY0 {
+		t.Fatalf("badge at (%d,%d)-(%d,%d), want in the top-right corner", layout.BadgeX0, layout.BadgeY0, layout.BadgeX1, layout.BadgeY1)
+	}
+
+	opts.SafeArea.Mode = SafeAreaNudge
+	_, nudged, err := RenderWithLayout(bg, "t", "b", opts)
+	if err != nil {
+		t.Fatalf("nudge: %v", err)
+	}
+	if got := SafeAreaCollisions(nudged, ShellKDE); len(got) != 0 {
+		t.Fatalf("nudged layout still collides: %q", got)
+	}
+	if shift := nudged.BoxY0 - layout.BoxY0; shift >= 0 || nudged.TitleY-layout.TitleY != shift || nudged.BoxY1 != 1080-48-layout.Padding/2 {
+		t.Fatalf("box moved from %d..%d to %d..%d with the title from %d to %d", layout.BoxY0, layout.BoxY1, nudged.BoxY0, nudged.BoxY1, layout.TitleY, nudged.TitleY)
+	}
+
+	opts.SafeArea.Mode = SafeAreaFail
+	if err := CheckFit("t", "b", opts); err == nil || !strings.Contains(err.Error(), "info box collides with the KDE panel") {
+		t.Fatalf("fail: expected a collision error, got %v", err)
+	}
+	// A badge under the GNOME top bar collides as well, and the full-bleed box is not checked.
+	if got := SafeAreaCollisions(Layout{Width: 1920, Height: 1080, BoxX1: 1920, BoxY1: 1080, BadgeX0: 1800, BadgeY0: 10, BadgeX1: 1900, BadgeY1: 40}, ShellGNOME); !reflect.DeepEqual(got, []string{"channel badge collides with the GNOME top bar"}) {
+		t.Fatalf("collisions %q, want the badge and the GNOME top bar", got)
+	}
+	if _, err := ParseShell("cinnamon"); err == nil {
+		t.Fatalf("expected error for unknown shell")
+	}
+}
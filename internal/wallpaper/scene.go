@@ -0,0 +1,361 @@
+package wallpaper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	stddraw "image/draw"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+)
+
+// Scene is a declarative wallpaper composition: layers drawn in order onto a canvas.
+// Coordinates and font sizes are in design units of Width x Height and scaled to the output resolution.
+type Scene struct {
+	// Width and Height define the design space; zero values select TargetWidth x TargetHeight.
+	Width  int     `json:"width,omitempty"`
+	Height int     `json:"height,omitempty"`
+	Layers []Layer `json:"layers"`
+}
+
+// LayerType selects what a scene layer draws.
+type LayerType string
+
+const (
+	// LayerBackground draws the background photo, scaled to cover the canvas.
+	LayerBackground LayerType = "background"
+	// LayerRect draws a filled, optionally rounded rectangle.
+	LayerRect LayerType = "rect"
+	// LayerSeparator draws a horizontal line whose top edge is at Y.
+	LayerSeparator LayerType = "separator"
+	// LayerText draws a single line of text whose baseline is at Y.
+	LayerText LayerType = "text"
+	// LayerImage draws an image file scaled into the X, Y, W, H rectangle.
+	LayerImage LayerType = "image"
+)
+
+// Layer is one element of a Scene; which fields apply depends on Type.
+type Layer struct {
+	Type LayerType `json:"type"`
+
+	X float64 `json:"x,omitempty"`
+	Y float64 `json:"y,omitempty"`
+	W float64 `json:"w,omitempty"`
+	H float64 `json:"h,omitempty"`
+
+	// Radius rounds the corners of rect layers.
+	Radius float64 `json:"radius,omitempty"`
+	// Corners shapes the corners of rect layers, e.g. as squircles or with a radius per corner in design units.
+	Corners *Corners `json:"corners,omitempty"`
+	// Thickness is the height of separator layers; zero selects max(2px, output height/160).
+	Thickness float64 `json:"thickness,omitempty"`
+	// Style draws separator layers "solid" (default), "fade", or "dotted"; see SeparatorStyle.
+	Style SeparatorStyle `json:"style,omitempty"`
+	// Color is "#rrggbb" or "#rrggbbaa"; empty selects the layer type's default.
+	Color string `json:"color,omitempty"`
+
+	// Text is a template expanded by Scene.Expand before rendering, e.g. "TSSH {{.TargetName}}".
+	Text string `json:"text,omitempty"`
+	// Font is "regular" (default) or "bold"; fallback and emoji fonts from Options apply to both.
+	Font string `json:"font,omitempty"`
+	// Size is the font size in design units.
+	Size float64 `json:"size,omitempty"`
+	// Align places text relative to X: "left" (default), "center", or "right".
+	Align string `json:"align,omitempty"`
+	// Gradient fills text layers with a linear gradient instead of Color.
+	Gradient *Gradient `json:"gradient,omitempty"`
+	// Stroke outlines text layers; its width is in design units.
+	Stroke *Stroke `json:"stroke,omitempty"`
+
+	// Src is the image file of image layers, relative to the scene file.
+	Src string `json:"src,omitempty"`
+
+	img image.Image
+	// face and visual are set for the built-in scene, whose text is measured and reordered up front.
+	face   font.Face
+	visual bool
+}
+
+// Default layer colors, matching the centered layout.
+var (
+	defaultRectColor      = color.NRGBA{R: 12, G: 16, B: 24, A: boxOpacityDefault}
+	defaultSeparatorColor = color.NRGBA{R: 255, G: 255, B: 255, A: 140}
+	defaultTextColor      = color.NRGBA{R: 241, G: 243, B: 246, A: 255}
+)
+
+// LoadScene reads a JSON scene file and loads the images it references relative to the file's directory.
+// It returns an error for unreadable files, invalid JSON, unknown fields, and invalid layers.
+func LoadScene(path string) (Scene, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Scene{}, fmt.Errorf("scene: read %s: %w", path, err)
+	}
+	return ParseScene(data, filepath.Dir(path))
+}
+
+// ParseScene decodes a JSON scene, validates every layer, and loads image layers relative to dir.
+// Unknown fields are rejected so typos do not silently fall back to defaults.
+func ParseScene(data []byte, dir string) (Scene, error) {
+	var scene Scene
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&scene); err != nil {
+		return Scene{}, fmt.Errorf("scene: decode: %w", err)
+	}
+	if scene.Width < 0 || scene.Height < 0 {
+		return Scene{}, fmt.Errorf("scene: negative size %dx%d", scene.Width, scene.Height)
+	}
+	for i := range scene.Layers {
+		l := &scene.Layers[i]
+		if err := l.validate(); err != nil {
+			return Scene{}, fmt.Errorf("scene: layer %d: %w", i, err)
+		}
+		if l.Type == LayerImage {
+			img, err := loadSceneImage(filepath.Join(dir, l.Src))
+			if err != nil {
+				return Scene{}, fmt.Errorf("scene: layer %d: %w", i, err)
+			}
+			l.img = img
+		}
+	}
+	return scene, nil
+}
+
+// validate checks the fields required by the layer type.
+func (l Layer) validate() error {
+	if _, err := parseHexColor(l.Color, color.NRGBA{}); err != nil {
+		return err
+	}
+	switch l.Type {
+	case LayerBackground:
+	case LayerRect, LayerImage:
+		if l.W <= 0 || l.H <= 0 {
+			return fmt.Errorf("%s needs positive w and h", l.Type)
+		}
+		if l.Type == LayerImage && l.Src == "" {
+			return fmt.Errorf("image needs src")
+		}
+		if err := l.Corners.validate(); err != nil {
+			return err
+		}
+	case LayerSeparator:
+		if l.W <= 0 {
+			return fmt.Errorf("separator needs positive w")
+		}
+		// An accent bar is a solid separator placed by the layout; in a scene, x and w place the line.
+		if err := validateSeparatorStyle(l.Style, []SeparatorStyle{SeparatorSolid, SeparatorFade, SeparatorDotted}); err != nil {
+			return fmt.Errorf("separator: %w", err)
+		}
+	case LayerText:
+		if l.Size <= 0 {
+			return fmt.Errorf("text needs positive size")
+		}
+		if l.Font != "" && l.Font != "regular" && l.Font != "bold" {
+			return fmt.Errorf("unknown font %q", l.Font)
+		}
+		if l.Align != "" && l.Align != "left" && l.Align != "center" && l.Align != "right" {
+			return fmt.Errorf("unknown align %q", l.Align)
+		}
+		if err := l.Gradient.validate(); err != nil {
+			return err
+		}
+		if err := l.Stroke.validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown layer type %q", l.Type)
+	}
+	return nil
+}
+
+// Expand returns a copy of the scene with every text layer passed through expand, e.g. release.Info.Expand.
+func (s Scene) Expand(expand func(string) (string, error)) (Scene, error) {
+	layers := append([]Layer(nil), s.Layers...)
+	for i := range layers {
+		if layers[i].Type != LayerText {
+			continue
+		}
+		text, err := expand(layers[i].Text)
+		if err != nil {
+			return Scene{}, fmt.Errorf("scene: layer %d: %w", i, err)
+		}
+		layers[i].Text = text
+	}
+	s.Layers = layers
+	return s, nil
+}
+
+// loadSceneImage decodes an image file referenced by an image layer and converts it to sRGB.
+func loadSceneImage(path string) (image.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("open image: %w", err)
+	}
+	img, err := decodeSRGB(data, Limits{})
+	if err != nil {
+		return nil, fmt.Errorf("image %s: %w", path, err)
+	}
+	return img, nil
+}
+
+// parseHexColor parses "#rrggbb" or "#rrggbbaa"; an empty string returns def.
+func parseHexColor(s string, def color.NRGBA) (color.NRGBA, error) {
+	if s == "" {
+		return def, nil
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 8 || !strings.HasPrefix(s, "#") || err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q, want #rrggbb or #rrggbbaa", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// sceneRenderer interprets a scene onto a canvas, scaling design units to pixels.
+type sceneRenderer struct {
+	comp      *compositor
+	bg        image.Image
+	sx, sy    float64
+	hinting   font.Hinting
+	fallbacks [][]byte
+	direction Direction
+	treatment BackgroundTreatment
+	filters   []Filter
+	// crop keeps the pixel rectangles of the rect layers quiet.
+	crop cropRule
+}
+
+// drawScene draws all layers of scene through comp in order.
+// It returns errors for background scaling, font loading, and drawing failures.
+func drawScene(comp *compositor, bg image.Image, scene Scene, opts Options) error {
+	w, h := comp.bounds().Dx(), comp.bounds().Dy()
+	designW, designH := scene.Width, scene.Height
+	if designW <= 0 || designH <= 0 {
+		designW, designH = TargetWidth, TargetHeight
+	}
+	r := sceneRenderer{
+		comp:      comp,
+		bg:        bg,
+		sx:        float64(w) / float64(designW),
+		sy:        float64(h) / float64(designH),
+		hinting:   opts.Hinting.fontHinting(),
+		fallbacks: opts.fontChain(),
+		direction: opts.Direction,
+		treatment: opts.Theme.Background,
+		filters:   opts.Filters.PreComposite,
+		crop:      cropRule{fit: opts.Fit, mode: opts.Crop, focal: opts.Focal, sharpen: opts.Theme.Background.Sharpen},
+	}
+	for _, l := range scene.Layers {
+		if l.Type == LayerRect {
+			r.crop.quiet = append(r.crop.quiet, r.rect(l.X, l.Y, l.W, l.H))
+		}
+	}
+	for i, l := range scene.Layers {
+		if err := r.draw(l); err != nil {
+			return fmt.Errorf("render: scene layer %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// rect converts a design-space rectangle to pixels.
+func (r *sceneRenderer) rect(x, y, w, h float64) image.Rectangle {
+	x0, y0 := int(math.Round(x*r.sx)), int(math.Round(y*r.sy))
+	return image.Rect(x0, y0, x0+int(math.Round(w*r.sx)), y0+int(math.Round(h*r.sy)))
+}
+
+// draw renders a single layer.
+func (r *sceneRenderer) draw(l Layer) error {
+	switch l.Type {
+	case LayerBackground:
+		b := r.comp.bounds()
+		layer, err := resizeAndCrop(r.bg, b.Dx(), b.Dy(), r.crop)
+		if err != nil {
+			return err
+		}
+		r.treatment.apply(layer)
+		if err := runFilters(HookPreComposite, r.filters, layer); err != nil {
+			return err
+		}
+		r.comp.replace(layer)
+	case LayerRect:
+		col, err := parseHexColor(l.Color, defaultRectColor)
+		if err != nil {
+			return err
+		}
+		return r.comp.paint(func(dst *image.RGBA) error {
+			overlay := image.NewRGBA(dst.Bounds())
+			var radii [4]int
+			for i, radius := range l.Corners.radii(l.Radius) {
+				radii[i] = int(math.Round(radius * r.sy))
+			}
+			drawShapedRect(overlay, r.rect(l.X, l.Y, l.W, l.H), radii, l.Corners.exponent(), col)
+			stddraw.Draw(dst, overlay.Bounds(), overlay, image.Point{}, stddraw.Over)
+			return nil
+		})
+	case LayerSeparator:
+		col, err := parseHexColor(l.Color, defaultSeparatorColor)
+		if err != nil {
+			return err
+		}
+		rect := r.rect(l.X, l.Y, l.W, l.Thickness)
+		if l.Thickness <= 0 {
+			rect.Max.Y = rect.Min.Y + maxInt(2, r.comp.bounds().Dy()/lineThicknessDiv)
+		}
+		return r.comp.paint(func(dst *image.RGBA) error {
+			drawSeparator(dst, rect, l.Style, col)
+			return nil
+		})
+	case LayerImage:
+		return r.comp.paint(func(dst *image.RGBA) error {
+			draw.CatmullRom.Scale(dst, r.rect(l.X, l.Y, l.W, l.H), l.img, l.img.Bounds(), draw.Over, nil)
+			return nil
+		})
+	case LayerText:
+		return r.drawText(l)
+	}
+	return nil
+}
+
+// drawText shapes, measures, aligns, and draws a text layer.
+func (r *sceneRenderer) drawText(l Layer) error {
+	col, err := parseHexColor(l.Color, defaultTextColor)
+	if err != nil {
+		return err
+	}
+	face, text := l.face, l.Text
+	if face == nil {
+		fontData := regularFontData
+		if l.Font == "bold" {
+			fontData = boldFontData
+		}
+		if face, err = loadFaceChain(l.Size*r.sy, r.hinting, fontData, r.fallbacks...); err != nil {
+			return err
+		}
+	}
+	if !l.visual {
+		text = prepareLine(text, resolveDirection(text, r.direction, DirectionLTR))
+	}
+
+	x := int(math.Round(l.X * r.sx))
+	switch l.Align {
+	case "center":
+		x -= font.MeasureString(face, text).Ceil() / 2
+	case "right":
+		x -= font.MeasureString(face, text).Ceil()
+	}
+	return r.comp.paint(func(dst *image.RGBA) error {
+		return drawStyledText(dst, face, text, x, int(math.Round(l.Y*r.sy)), col, l.Gradient, l.Stroke.scaled(r.sy))
+	})
+}
@@ -0,0 +1,85 @@
+package wallpaper
+
+import (
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/release"
+)
+
+// TestParseScene_InvalidLayers_Error expects descriptive errors for malformed scenes instead of silent defaults.
+func TestParseScene_InvalidLayers_Error(t *testing.T) {
+	cases := []struct {
+		json string
+		want string
+	}{
+		{`{"layers": [{"type": "circle"}]}`, `layer 0: unknown layer type "circle"`},
+		{`{"layers": [{"type": "background"}, {"type": "rect", "w": 10}]}`, "layer 1: rect needs positive w and h"},
+		{`{"layers": [{"type": "text", "text": "x"}]}`, "text needs positive size"},
+		{`{"layers": [{"type": "text", "size": 10, "font": "italic"}]}`, `unknown font "italic"`},
+		{`{"layers": [{"type": "separator", "w": 10, "color": "red"}]}`, `invalid color "red"`},
+		{`{"layers": [{"type": "image", "w": 10, "h": 10, "src": "missing.png"}]}`, "open image"},
+		{`{"layers": [{"type": "rect", "width": 10}]}`, `unknown field "width"`},
+		{`{"width": -1, "layers": []}`, "negative size"},
+	}
+	for _, c := range cases {
+		_, err := ParseScene([]byte(c.json), t.TempDir())
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("ParseScene(%s): got %v, want error containing %q", c.json, err, c.want)
+		}
+	}
+
+	if col, err := parseHexColor("#0c101880", color.NRGBA{}); err != nil || col != (color.NRGBA{R: 12, G: 16, B: 24, A: 128}) {
+		t.Fatalf("parseHexColor = %v, %v", col, err)
+	}
+}
+
+// TestScene_Expand_OnlyTextLayers expects templates in text layers to be expanded and other layers left untouched.
+func TestScene_Expand_OnlyTextLayers(t *testing.T) {
+	scene := Scene{Layers: []Layer{
+		{Type: LayerText, Size: 10, Text: "TSSH {{.TargetName}}"},
+		{Type: LayerImage, Src: "{{.TargetName}}.png"},
+	}}
+	rel := release.Info{TargetName: "lab"}
+	got, err := scene.Expand(rel.Expand)
+	if err != nil {
+		t.Fatalf("Expand error: %v", err)
+	}
+	if got.Layers[0].Text != "TSSH lab" || got.Layers[1].Src != "{{.TargetName}}.png" {
+		t.Fatalf("unexpected expansion: %+v", got.Layers)
+	}
+	if scene.Layers[0].Text != "TSSH {{.TargetName}}" {
+		t.Fatalf("Expand modified the original scene")
+	}
+	if _, err := (Scene{Layers: []Layer{{Type: LayerText, Text: "{{.Nope}}"}}}).Expand(rel.Expand); err == nil || !strings.Contains(err.Error(), "layer 0") {
+		t.Fatalf("expected template error with layer index, got %v", err)
+	}
+}
+
+// TestGolden_Scene renders the example sidebar scene (background, rect, image, text, separator) at two resolutions.
+// The test fails if scene interpretation, scaling of design units, or template bindings change.
+func TestGolden_Scene(t *testing.T) {
+	scene, err := LoadScene(filepath.Join("testdata", "scenes", "sidebar.json"))
+	if err != nil {
+		t.Fatalf("LoadScene error: %v", err)
+	}
+	rel := release.Info{TargetName: "golden", BuildID: "2026-01-04", Channel: "beta", Time: time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC)}
+	if scene, err = scene.Expand(rel.Expand); err != nil {
+		t.Fatalf("Expand error: %v", err)
+	}
+	for _, size := range []struct {
+		name string
+		w, h int
+	}{{"scene_sidebar_640x360", 640, 360}, {"scene_sidebar_1280x720", 1280, 720}} {
+		t.Run(size.name, func(t *testing.T) {
+			img, err := Render(goldenBackground(size.w/2, size.h/2, goldenSeed), "golden", "", Options{Width: size.w, Height: size.h, Scene: &scene})
+			if err != nil {
+				t.Fatalf("Render error: %v", err)
+			}
+			checkGolden(t, size.name, img)
+		})
+	}
+}
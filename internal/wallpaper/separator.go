@@ -0,0 +1,147 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	stddraw "image/draw"
+	"math"
+)
+
+// SeparatorStyle selects how the line between title and subtitle is drawn.
+type SeparatorStyle string
+
+const (
+	// SeparatorSolid is a solid line a little wider than the wider text line, centered in the box.
+	SeparatorSolid SeparatorStyle = "solid"
+	// SeparatorFade spans the whole text area of the box and fades out linearly over a quarter of its width at each
+	// end.
+	SeparatorFade SeparatorStyle = "fade"
+	// SeparatorDotted is a row of round dots as wide as the line is thick, one dot apart, over the width of the solid
+	// line rounded down to whole dots.
+	SeparatorDotted SeparatorStyle = "dotted"
+	// SeparatorAccent is a short solid bar, twice the padding long, aligned with the start of the wider text line: its
+	// left edge, or its right edge for right-to-left titles.
+	SeparatorAccent SeparatorStyle = "accent"
+)
+
+// SeparatorStyles returns all selectable separator styles in a stable order for help output and validation.
+func SeparatorStyles() []SeparatorStyle {
+	return []SeparatorStyle{SeparatorSolid, SeparatorFade, SeparatorDotted, SeparatorAccent}
+}
+
+// Separator styles the line between title and subtitle of layouts that draw one. The zero value keeps the solid
+// line.
+type Separator struct {
+	// Style is the shape of the line; empty selects SeparatorSolid.
+	Style SeparatorStyle `json:"style,omitempty"`
+	// Color is "#rrggbb" or "#rrggbbaa"; empty follows the title color, or the translucent white default.
+	Color string `json:"color,omitempty"`
+	// Thickness is in pixels at TargetHeight and scales with the output height; zero keeps the layout's thickness.
+	Thickness float64 `json:"thickness,omitempty"`
+}
+
+// validate checks the style, color, and thickness.
+func (s Separator) validate() error {
+	if err := validateSeparatorStyle(s.Style, SeparatorStyles()); err != nil {
+		return fmt.Errorf("theme: separator: %w", err)
+	}
+	if _, err := parseHexColor(s.Color, color.NRGBA{}); err != nil {
+		return fmt.Errorf("theme: separator: %w", err)
+	}
+	if s.Thickness < 0 {
+		return fmt.Errorf("theme: separator: thickness %v must not be negative", s.Thickness)
+	}
+	return nil
+}
+
+// validateSeparatorStyle returns an error unless style is empty or one of known.
+func validateSeparatorStyle(style SeparatorStyle, known []SeparatorStyle) error {
+	if style == "" {
+		return nil
+	}
+	for _, k := range known {
+		if k == style {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown style %q", style)
+}
+
+// separatorLayer returns the separator inside the text box for text lines at most textWidth wide, drawn in the given
+// style and thickness in pixels; a non-positive thickness keeps the layout's. Widths are clamped so the line never
+// extends beyond the box's text area. An accent bar becomes a solid layer; the other styles keep theirs.
+func separatorLayer(layout Layout, textWidth int, style SeparatorStyle, thickness int) Layer {
+	if thickness <= 0 {
+		thickness = 2 * (layout.SeparatorThickness / 2)
+	}
+	maxWidth := layout.BoxWidth - 2*layout.Padding
+	textWidth = minInt(textWidth, maxWidth)
+	extra := maxInt(layout.Padding/4, 10)
+	width := minInt(textWidth+extra, maxWidth)
+	startX := layout.BoxX0 + (layout.BoxWidth-width)/2
+
+	switch style {
+	case SeparatorFade:
+		width = maxWidth
+		startX = layout.BoxX0 + layout.Padding
+	case SeparatorDotted:
+		width = dottedWidth(width, thickness)
+		startX = layout.BoxX0 + (layout.BoxWidth-width)/2
+	case SeparatorAccent:
+		style = SeparatorSolid
+		width = minInt(2*layout.Padding, maxWidth)
+		startX = layout.BoxX0 + (layout.BoxWidth-textWidth)/2
+		if layout.Direction == DirectionRTL {
+			startX += textWidth - width
+		}
+	}
+	if style == SeparatorSolid {
+		style = ""
+	}
+	return Layer{
+		Type:      LayerSeparator,
+		X:         float64(startX),
+		Y:         float64(layout.SeparatorY - thickness/2),
+		W:         float64(width),
+		Thickness: float64(thickness),
+		Style:     style,
+	}
+}
+
+// dottedWidth returns the width of the longest row of dots of the given size, each followed by a gap of the same
+// size except the last, that fits into width; at least one dot.
+func dottedWidth(width, dot int) int {
+	if dot <= 0 {
+		return width
+	}
+	n := maxInt(1, (width+dot)/(2*dot))
+	return 2*n*dot - dot
+}
+
+// drawSeparator draws a separator of the given style into rect.
+func drawSeparator(dst *image.RGBA, rect image.Rectangle, style SeparatorStyle, col color.NRGBA) {
+	switch style {
+	case SeparatorFade:
+		// Each column takes the color's alpha times its distance from the nearer end over a quarter of the width.
+		mask := image.NewAlpha(image.Rect(0, 0, rect.Dx(), 1))
+		ramp := float64(rect.Dx()) / 4
+		for x := 0; x < rect.Dx(); x++ {
+			d := math.Min(float64(x)+0.5, float64(rect.Dx()-x)-0.5)
+			mask.Pix[x] = uint8(math.Round(255 * math.Min(1, d/ramp)))
+		}
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			row := image.Rect(rect.Min.X, y, rect.Max.X, y+1)
+			stddraw.DrawMask(dst, row, image.NewUniform(col), image.Point{}, mask, image.Point{}, stddraw.Over)
+		}
+	case SeparatorDotted:
+		dot := rect.Dy()
+		width := dottedWidth(rect.Dx(), dot)
+		x0 := rect.Min.X + (rect.Dx()-width)/2
+		for x := x0; x+dot <= x0+width; x += 2 * dot {
+			drawRoundedRect(dst, image.Rect(x, rect.Min.Y, x+dot, rect.Max.Y), dot/2, col)
+		}
+	default:
+		stddraw.Draw(dst, rect, image.NewUniform(col), image.Point{}, stddraw.Over)
+	}
+}
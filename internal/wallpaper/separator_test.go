@@ -0,0 +1,97 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// TestSeparatorLayer_WidthPerStyle checks the geometry of each separator style in a 800 px wide box with 40 px
+// padding around 500 px of text. The test fails if a style's position, width, thickness, or drawn style differs.
+func TestSeparatorLayer_WidthPerStyle(t *testing.T) {
+	layout := Layout{BoxX0: 100, BoxWidth: 800, Padding: 40, SeparatorY: 300, SeparatorThickness: 9}
+	rtl := layout
+	rtl.Direction = DirectionRTL
+	for _, tc := range []struct {
+		name      string
+		layout    Layout
+		textWidth int
+		style     SeparatorStyle
+		thickness int
+		want      Layer
+	}{
+		// Text plus max(padding/4, 10), centered, at the layout's even thickness.
+		{"solid", layout, 500, "", 0, Layer{X: 245, Y: 296, W: 510, Thickness: 8}},
+		{"solid too wide", layout, 900, SeparatorSolid, 0, Layer{X: 140, Y: 296, W: 720, Thickness: 8}},
+		{"solid thick", layout, 500, "", 12, Layer{X: 245, Y: 294, W: 510, Thickness: 12}},
+		// The whole text area between the paddings.
+		{"fade", layout, 500, SeparatorFade, 0, Layer{X: 140, Y: 296, W: 720, Thickness: 8, Style: SeparatorFade}},
+		// 32 dots of 8 px with 31 gaps fit into 510 px.
+		{"dotted", layout, 500, SeparatorDotted, 0, Layer{X: 248, Y: 296, W: 504, Thickness: 8, Style: SeparatorDotted}},
+		{"dotted short", layout, 0, SeparatorDotted, 20, Layer{X: 490, Y: 290, W: 20, Thickness: 20, Style: SeparatorDotted}},
+		// Twice the padding at the start of the text.
+		{"accent", layout, 500, SeparatorAccent, 0, Layer{X: 250, Y: 296, W: 80, Thickness: 8}},
+		{"accent rtl", rtl, 500, SeparatorAccent, 0, Layer{X: 670, Y: 296, W: 80, Thickness: 8}},
+	} {
+		tc.want.Type = LayerSeparator
+		got := separatorLayer(tc.layout, tc.textWidth, tc.style, tc.thickness)
+		if got.X != tc.want.X || got.Y != tc.want.Y || got.W != tc.want.W || got.Thickness != tc.want.Thickness ||
+			got.Style != tc.want.Style || got.Type != tc.want.Type {
+			t.Errorf("%s: got x=%v y=%v w=%v thickness=%v style=%q, want x=%v y=%v w=%v thickness=%v style=%q", tc.name,
+				got.X, got.Y, got.W, got.Thickness, got.Style, tc.want.X, tc.want.Y, tc.want.W, tc.want.Thickness, tc.want.Style)
+		}
+	}
+}
+
+// TestDrawSeparator_FadeAndDots expects a fading line to be transparent at its ends and opaque in its middle, and a
+// dotted line to alternate dots and gaps. The test fails if either is drawn as a solid line.
+func TestDrawSeparator_FadeAndDots(t *testing.T) {
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	alpha := func(img *image.RGBA, x, y int) uint8 { return img.RGBAAt(x, y).A }
+
+	fade := image.NewRGBA(image.Rect(0, 0, 100, 4))
+	drawSeparator(fade, fade.Bounds(), SeparatorFade, white)
+	if a0, a50, a99 := alpha(fade, 0, 2), alpha(fade, 50, 2), alpha(fade, 99, 2); a0 > 10 || a99 > 10 || a50 != 255 {
+		t.Fatalf("fade alpha at 0, 50, 99 = %d, %d, %d", a0, a50, a99)
+	}
+	if a12 := alpha(fade, 12, 2); a12 < 100 || a12 > 155 {
+		t.Fatalf("fade alpha halfway through the ramp = %d", a12)
+	}
+
+	dots := image.NewRGBA(image.Rect(0, 0, 36, 4))
+	drawSeparator(dots, dots.Bounds(), SeparatorDotted, white)
+	// Five 4 px dots with 4 px gaps span 36 px.
+	for x := 0; x < 36; x++ {
+		if on := alpha(dots, x, 2) != 0; on != (x/4%2 == 0) {
+			t.Fatalf("dotted pixel %d drawn = %v", x, on)
+		}
+	}
+}
+
+// TestParseTheme_Separator verifies the separator options of themes and scene separator layers. The test fails if a
+// valid separator is rejected, or an unknown style, a bad color, a negative thickness, or an accent scene layer is
+// accepted.
+func TestParseTheme_Separator(t *testing.T) {
+	for _, style := range SeparatorStyles() {
+		data := `{"separator": {"style": "` + string(style) + `", "color": "#ffcc00", "thickness": 6}}`
+		if _, err := ParseTheme([]byte(data)); err != nil {
+			t.Errorf("%s: %v", data, err)
+		}
+	}
+	for data, want := range map[string]string{
+		`{"separator": {"style": "wavy"}}`:   "unknown style",
+		`{"separator": {"color": "yellow"}}`: "invalid color",
+		`{"separator": {"thickness": -1}}`:   "must not be negative",
+	} {
+		if _, err := ParseTheme([]byte(data)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", data, want, err)
+		}
+	}
+	if _, err := ParseScene([]byte(`{"width": 100, "height": 100, "layers": [{"type": "separator", "w": 10, "style": "fade"}]}`), ""); err != nil {
+		t.Errorf("fade scene separator: %v", err)
+	}
+	if _, err := ParseScene([]byte(`{"width": 100, "height": 100, "layers": [{"type": "separator", "w": 10, "style": "accent"}]}`), ""); err == nil {
+		t.Error("expected an accent scene separator to be rejected")
+	}
+}
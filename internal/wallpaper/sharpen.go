@@ -0,0 +1,86 @@
+package wallpaper
+
+import (
+	"image"
+	"math"
+)
+
+// MaxSharpen bounds BackgroundTreatment.Sharpen; stronger unsharp masks draw halos around every edge.
+const MaxSharpen = 2
+
+const (
+	// sharpenSigma is the standard deviation in pixels of the blur the unsharp mask subtracts at TargetHeight; it
+	// scales with the canvas height so the mask keeps its size relative to the image.
+	sharpenSigma = 1.0
+	// sharpenThreshold is the luminance difference in 8-bit levels below which pixels are left alone, so smooth
+	// areas such as sky keep their noise and JPEG blocks hidden.
+	sharpenThreshold = 2.0
+)
+
+// sharpenStrength returns the share of amount applied after scaling a background by scale: none when it is not
+// downscaled, growing to all of it at half size and below, where CatmullRom softens the most detail.
+func sharpenStrength(amount, scale float64) float64 {
+	if amount <= 0 || scale >= 1 {
+		return 0
+	}
+	return amount * math.Min(1, 1/scale-1)
+}
+
+// sharpen applies an unsharp mask of amount to the luminance of img in place: each pixel gains amount times its
+// difference from a Gaussian blur of radius scaled to img's height. Working on luminance only adds no color fringes.
+func sharpen(img *image.RGBA, amount float64) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if amount <= 0 || w == 0 || h == 0 {
+		return
+	}
+	sigma := math.Max(0.5, sharpenSigma*float64(h)/TargetHeight)
+	radius := int(math.Ceil(3 * sigma))
+	kernel := make([]float32, 2*radius+1)
+	var total float32
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = float32(math.Exp(-d * d / (2 * sigma * sigma)))
+		total += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= total
+	}
+
+	luma := make([]float32, w*h)
+	for y := range h {
+		for x := range w {
+			p := img.Pix[img.PixOffset(b.Min.X+x, b.Min.Y+y):][:3]
+			luma[y*w+x] = 0.2126*float32(p[0]) + 0.7152*float32(p[1]) + 0.0722*float32(p[2])
+		}
+	}
+	// The separable blur clamps at the edges, so the border pixels are not darkened towards a black surround.
+	rows := make([]float32, w*h)
+	for y := range h {
+		for x := range w {
+			var s float32
+			for k, weight := range kernel {
+				s += weight * luma[y*w+min(w-1, max(0, x+k-radius))]
+			}
+			rows[y*w+x] = s
+		}
+	}
+	for y := range h {
+		for x := range w {
+			var blurred float32
+			for k, weight := range kernel {
+				blurred += weight * rows[min(h-1, max(0, y+k-radius))*w+x]
+			}
+			diff := float64(luma[y*w+x] - blurred)
+			if math.Abs(diff) < sharpenThreshold {
+				continue
+			}
+			p := img.Pix[img.PixOffset(b.Min.X+x, b.Min.Y+y):][:4]
+			// Channels and luminance are premultiplied, so the shifted channels are clamped to alpha.
+			shift := amount * diff
+			for c := range 3 {
+				p[c] = uint8(math.Round(math.Min(float64(p[3]), math.Max(0, float64(p[c])+shift))))
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package wallpaper
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// edgeBackground returns a size×size background, dark gray left of the middle column and light gray right of it.
+func edgeBackground(size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := range size {
+		for x := range size {
+			c := color.RGBA{64, 64, 64, 255}
+			if x >= size/2 {
+				c = color.RGBA{192, 192, 192, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// TestResizeAndCrop_Sharpen expects the unsharp mask to steepen edges of downscaled backgrounds, to leave flat areas
+// and backgrounds that are not downscaled alone, and to grow with the downscale factor up to half size.
+// The test fails if sharpening is missing after a downscale, applies to upscales, or shifts flat areas.
+func TestResizeAndCrop_Sharpen(t *testing.T) {
+	const size = 200
+	soft, err := resizeAndCrop(edgeBackground(2*size), size, size, cropRule{})
+	if err != nil {
+		t.Fatalf("resize: %v", err)
+	}
+	sharp, err := resizeAndCrop(edgeBackground(2*size), size, size, cropRule{sharpen: 1})
+	if err != nil {
+		t.Fatalf("resize sharpened: %v", err)
+	}
+	y := size / 2
+	if got, was := sharp.RGBAAt(size/2-1, y).R, soft.RGBAAt(size/2-1, y).R; got >= was {
+		t.Fatalf("dark side of the edge is %d, want darker than %d", got, was)
+	}
+	if got, was := sharp.RGBAAt(size/2, y).R, soft.RGBAAt(size/2, y).R; got <= was {
+		t.Fatalf("light side of the edge is %d, want lighter than %d", got, was)
+	}
+	if got := sharp.RGBAAt(10, y); got != soft.RGBAAt(10, y) {
+		t.Fatalf("flat area changed from %v to %v", soft.RGBAAt(10, y), got)
+	}
+	if got := sharp.RGBAAt(size/2, y); got.R != got.G || got.G != got.B || got.A != 255 {
+		t.Fatalf("sharpening shifted the color or alpha: %v", got)
+	}
+
+	// An upscaled background is not sharpened.
+	up, err := resizeAndCrop(edgeBackground(size/2), size, size, cropRule{sharpen: MaxSharpen})
+	if err != nil {
+		t.Fatalf("upscale: %v", err)
+	}
+	plain, err := resizeAndCrop(edgeBackground(size/2), size, size, cropRule{})
+	if err != nil {
+		t.Fatalf("upscale: %v", err)
+	}
+	if !bytes.Equal(up.Pix, plain.Pix) {
+		t.Fatalf("upscaled background was sharpened")
+	}
+
+	for _, c := range []struct {
+		scale, want float64
+	}{{1, 0}, {2, 0}, {0.8, 0.25}, {0.5, 1}, {0.25, 1}} {
+		if got := sharpenStrength(1, c.scale); got != c.want {
+			t.Fatalf("sharpenStrength(1, %v) = %v, want %v", c.scale, got, c.want)
+		}
+	}
+	if err := (BackgroundTreatment{Sharpen: MaxSharpen + 1}).validate(); err == nil {
+		t.Fatalf("expected error for sharpen out of range")
+	}
+}
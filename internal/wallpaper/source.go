@@ -0,0 +1,280 @@
+package wallpaper
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/nickhildebrandt/ts-release/internal/bucket"
+	"github.com/nickhildebrandt/ts-release/internal/offline"
+	"github.com/nickhildebrandt/ts-release/internal/pexels"
+	"github.com/nickhildebrandt/ts-release/internal/unsplash"
+	"github.com/nickhildebrandt/ts-release/internal/wallhaven"
+)
+
+// BackgroundSource provides background images, e.g. from a hosted image service.
+// Implementations return images of roughly the requested size; Render scales and crops them to fit.
+type BackgroundSource interface {
+	// Fetch returns a background for a width x height canvas.
+	Fetch(width, height int) (Background, error)
+}
+
+// Source names for Background.Source and the --source flag.
+const (
+	SourceWallhaven = "wallhaven"
+	SourceUnsplash  = "unsplash"
+	SourcePexels    = "pexels"
+	SourceS3        = "s3"
+	SourceURLList   = "url-list"
+	SourceOffline   = "offline"
+	// SourceWASM generates backgrounds with a WebAssembly module, see package wasm.
+	SourceWASM = "wasm"
+)
+
+// Sources returns the source names in a stable order for help output and validation.
+func Sources() []string {
+	return []string{SourceWallhaven, SourceUnsplash, SourcePexels, SourceS3, SourceURLList, SourceOffline, SourceWASM}
+}
+
+// WallhavenSource searches Wallhaven, by default like SearchBackground, or fetches a pinned wallpaper. All settings
+// are fields, so sources with different searches can fetch concurrently.
+type WallhavenSource struct {
+	Client *wallhaven.Client
+	// ID pins a wallpaper, fetched like FetchWallpaper whatever its size; empty searches.
+	ID string
+	// Params is the search, copied for each fetch; nil selects DefaultSearchParams. Its resolutions are replaced by
+	// the size.
+	Params *wallhaven.SearchParams
+	// Query replaces the search text of Params; empty keeps it.
+	Query  string
+	Limits Limits
+}
+
+// Fetch implements BackgroundSource.
+func (s WallhavenSource) Fetch(width, height int) (Background, error) {
+	if s.ID != "" {
+		return fetchWallpaper(s.Client, s.ID, s.Limits)
+	}
+	params := DefaultSearchParams()
+	if s.Params != nil {
+		params = *s.Params
+	}
+	if s.Query != "" {
+		params.Query = s.Query
+	}
+	return searchBackground(s.Client, params, width, height, s.Limits)
+}
+
+// UnsplashSource fetches a random Unsplash photo for Query in the canvas's orientation, cropped to size by the
+// Unsplash image service. Only photos passing the high content filter are used.
+type UnsplashSource struct {
+	Client *unsplash.Client
+	Query  string
+	Limits Limits
+}
+
+// Fetch implements BackgroundSource.
+func (s UnsplashSource) Fetch(width, height int) (Background, error) {
+	if width <= 0 || height <= 0 {
+		return Background{}, fmt.Errorf("fetch background: invalid target size %dx%d", width, height)
+	}
+	photo, err := s.Client.Random(unsplash.RandomParams{
+		Query:         s.Query,
+		Orientation:   orientation(width, height, unsplash.Landscape, unsplash.Portrait, unsplash.Squarish),
+		ContentFilter: "high",
+	})
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: %w", err)
+	}
+	if photo.URLs.Raw == "" {
+		return Background{}, fmt.Errorf("fetch background: unsplash photo %s has no image URL", photo.ID)
+	}
+	imageURL := photo.SizedURL(width, height)
+	data, err := s.Client.Download(photo, imageURL)
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: %w", err)
+	}
+	img, err := decodeSRGB(data, s.Limits)
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: %w", err)
+	}
+	return Background{
+		Image:       img,
+		URL:         imageURL,
+		Source:      SourceUnsplash,
+		PageURL:     photo.Links.HTML,
+		Attribution: photo.Attribution(),
+	}, nil
+}
+
+// PexelsSource fetches a random photo among the first search results for Query in the canvas's orientation and
+// at least its size class, cropped to size by the Pexels image service.
+type PexelsSource struct {
+	Client *pexels.Client
+	Query  string
+	// Rand chooses among the results; nil uses the global source.
+	Rand   *rand.Rand
+	Limits Limits
+}
+
+// pexelsPerPage is how many search results a random photo is chosen from; 80 is the API maximum.
+const pexelsPerPage = 80
+
+// Fetch implements BackgroundSource.
+func (s PexelsSource) Fetch(width, height int) (Background, error) {
+	if width <= 0 || height <= 0 {
+		return Background{}, fmt.Errorf("fetch background: invalid target size %dx%d", width, height)
+	}
+	result, err := s.Client.Search(pexels.SearchParams{
+		Query:       s.Query,
+		Orientation: orientation(width, height, pexels.Landscape, pexels.Portrait, pexels.Square),
+		Size:        pexels.SizeFor(width, height),
+		PerPage:     pexelsPerPage,
+	})
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: %w", err)
+	}
+	if len(result.Photos) == 0 {
+		return Background{}, fmt.Errorf("fetch background: %w: no usable pexels photo for %dx%d", ErrNoResults, width, height)
+	}
+	photo := result.Photos[intN(s.Rand, len(result.Photos))]
+	imageURL := photo.SizedURL(width, height)
+	data, err := s.Client.Download(imageURL)
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: %w", err)
+	}
+	img, err := decodeSRGB(data, s.Limits)
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: %w", err)
+	}
+	return Background{
+		Image:       img,
+		URL:         imageURL,
+		Source:      SourcePexels,
+		PageURL:     photo.URL,
+		Attribution: photo.Attribution(),
+	}, nil
+}
+
+// S3Source fetches a pre-approved background from an S3-compatible bucket: the object at Location, or a random
+// image below it if Location is a prefix. Objects are verified against their SHA-256 checksum.
+type S3Source struct {
+	Client   *bucket.Client
+	Location bucket.Location
+	// Rand chooses among the images of a prefix; nil uses the global source.
+	Rand   *rand.Rand
+	Limits Limits
+}
+
+// Fetch implements BackgroundSource; the size is ignored because approved images are used as they are.
+func (s S3Source) Fetch(width, height int) (Background, error) {
+	key := s.Location.Key
+	if s.Location.IsPrefix() {
+		keys, err := s.Client.Keys(s.Location.Bucket, key)
+		if err != nil {
+			return Background{}, fmt.Errorf("fetch background: %w", err)
+		}
+		if len(keys) == 0 {
+			return Background{}, fmt.Errorf("fetch background: %w: no images below %s", ErrNoResults, s.Location)
+		}
+		key = keys[intN(s.Rand, len(keys))]
+	}
+	data, err := s.Client.Get(s.Location.Bucket, key)
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: %w", err)
+	}
+	img, err := decodeSRGB(data, s.Limits)
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: %w", err)
+	}
+	location := bucket.Location{Bucket: s.Location.Bucket, Key: key}
+	return Background{Image: img, URL: location.String(), Source: SourceS3}, nil
+}
+
+// URLListSource fetches a random entry of a list of approved HTTPS URLs and verifies its SHA-256 checksum.
+type URLListSource struct {
+	// HTTPClient downloads the images; nil selects http.DefaultClient.
+	HTTPClient *http.Client
+	Entries    []bucket.Entry
+	// Rand chooses the entry; nil uses the global source.
+	Rand *rand.Rand
+	// MaxDownloadSize bounds the bytes of the image download; zero selects bucket.DefaultMaxDownloadSize.
+	MaxDownloadSize int64
+	Limits          Limits
+}
+
+// Fetch implements BackgroundSource; the size is ignored because approved images are used as they are.
+func (s URLListSource) Fetch(width, height int) (Background, error) {
+	if len(s.Entries) == 0 {
+		return Background{}, fmt.Errorf("fetch background: %w: URL list is empty", ErrNoResults)
+	}
+	entry := s.Entries[intN(s.Rand, len(s.Entries))]
+	data, err := entry.Download(s.HTTPClient, s.MaxDownloadSize)
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: %w", err)
+	}
+	img, err := decodeSRGB(data, s.Limits)
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: %w", err)
+	}
+	return Background{Image: img, URL: entry.URL, Source: SourceURLList}, nil
+}
+
+// OfflineSource uses a random background of a directory unpacked by `ts-release bundle use`, keeping the source
+// URL, wallpaper ID, and attribution recorded when the archive was created.
+type OfflineSource struct {
+	Dir string
+	// Rand chooses the background; nil uses the global source.
+	Rand   *rand.Rand
+	Limits Limits
+}
+
+// Fetch implements BackgroundSource; the size is ignored because approved images are used as they are.
+func (s OfflineSource) Fetch(width, height int) (Background, error) {
+	manifest, err := offline.Load(s.Dir)
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: %w", err)
+	}
+	backgrounds := manifest.Backgrounds()
+	if len(backgrounds) == 0 {
+		return Background{}, fmt.Errorf("fetch background: %w: no backgrounds in %s", ErrNoResults, s.Dir)
+	}
+	f := backgrounds[intN(s.Rand, len(backgrounds))]
+	data, err := os.ReadFile(filepath.Join(s.Dir, filepath.FromSlash(f.Path())))
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: %w", err)
+	}
+	img, err := decodeSRGB(data, s.Limits)
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: %s: %w", f.Path(), err)
+	}
+	return Background{
+		Image:       img,
+		URL:         f.SourceURL,
+		WallpaperID: f.WallpaperID,
+		Source:      SourceOffline,
+		Attribution: f.Attribution,
+	}, nil
+}
+
+// intN returns a random index below n from r, or from the global source if r is nil.
+func intN(r *rand.Rand, n int) int {
+	if r == nil {
+		return rand.IntN(n)
+	}
+	return r.IntN(n)
+}
+
+// orientation returns the API's orientation name for a width x height canvas.
+func orientation(width, height int, landscape, portrait, square string) string {
+	switch {
+	case width > height:
+		return landscape
+	case width < height:
+		return portrait
+	default:
+		return square
+	}
+}
@@ -0,0 +1,163 @@
+package wallpaper
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/nickhildebrandt/ts-release/internal/bucket"
+	"github.com/nickhildebrandt/ts-release/internal/pexels"
+	"github.com/nickhildebrandt/ts-release/internal/unsplash"
+)
+
+// TestSources_UnsplashAndPexels expects both sources to request the canvas's orientation, download a photo cropped
+// to the canvas, and report source, page, and attribution.
+// The test fails if a filter is wrong, the image is not decoded, or the attribution is lost.
+func TestSources_UnsplashAndPexels(t *testing.T) {
+	pngBytes := mustPNGBytes(t)
+
+	var orientations []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/unsplash/photos/random":
+			orientations = append(orientations, r.URL.Query().Get("orientation"))
+			_, _ = w.Write([]byte(`{"id":"p1","urls":{"raw":"` + server.URL + `/img"},"links":{"html":"https://unsplash.com/photos/p1"},"user":{"name":"Jane"}}`))
+		case "/pexels/search":
+			orientations = append(orientations, r.URL.Query().Get("orientation"))
+			_, _ = w.Write([]byte(`{"photos":[{"id":1,"url":"https://www.pexels.com/photo/1/","photographer":"Joe","src":{"original":"` + server.URL + `/img"}}]}`))
+		case "/img":
+			if r.URL.Query().Get("w") != "1440" || r.URL.Query().Get("h") != "2560" {
+				http.Error(w, "unexpected size", http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write(pngBytes)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	sources := []BackgroundSource{
+		UnsplashSource{Client: &unsplash.Client{HTTPClient: server.Client(), BaseURL: server.URL + "/unsplash"}, Query: "nature"},
+		PexelsSource{Client: &pexels.Client{HTTPClient: server.Client(), BaseURL: server.URL + "/pexels"}, Query: "nature", Rand: rand.New(rand.NewPCG(1, 1))},
+	}
+	want := []Background{
+		{Source: SourceUnsplash, PageURL: "https://unsplash.com/photos/p1", Attribution: "Photo by Jane on Unsplash"},
+		{Source: SourcePexels, PageURL: "https://www.pexels.com/photo/1/", Attribution: "Photo by Joe on Pexels"},
+	}
+	for i, source := range sources {
+		bg, err := source.Fetch(1440, 2560)
+		if err != nil {
+			t.Fatalf("%T: Fetch error: %v", source, err)
+		}
+		if bg.Image == nil || bg.Source != want[i].Source || bg.PageURL != want[i].PageURL || bg.Attribution != want[i].Attribution {
+			t.Fatalf("%T: got %+v", source, bg)
+		}
+		if _, err := source.Fetch(0, 10); err == nil {
+			t.Fatalf("%T: expected error for an invalid size", source)
+		}
+	}
+	if len(orientations) != 2 || orientations[0] != unsplash.Portrait || orientations[1] != pexels.Portrait {
+		t.Fatalf("orientations: %v", orientations)
+	}
+}
+
+// TestSources_S3Prefix expects an S3 prefix to be listed and one of its images fetched, verified, and reported as
+// an s3:// URL. The test fails if non-image objects are chosen or the object location is lost.
+func TestSources_S3Prefix(t *testing.T) {
+	pngBytes := mustPNGBytes(t)
+	sum := sha256.Sum256(pngBytes)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/art":
+			if r.URL.Query().Get("prefix") != "q4/" {
+				_, _ = w.Write([]byte(`<ListBucketResult></ListBucketResult>`))
+				return
+			}
+			_, _ = w.Write([]byte(`<ListBucketResult><Contents><Key>q4/readme.txt</Key></Contents><Contents><Key>q4/a.png</Key></Contents></ListBucketResult>`))
+		case "/art/q4/a.png":
+			w.Header().Set("X-Amz-Checksum-Sha256", base64.StdEncoding.EncodeToString(sum[:]))
+			_, _ = w.Write(pngBytes)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	source := S3Source{Client: &bucket.Client{HTTPClient: server.Client(), Endpoint: server.URL}, Location: bucket.Location{Bucket: "art", Key: "q4/"}}
+	bg, err := source.Fetch(1920, 1080)
+	if err != nil {
+		t.Fatalf("Fetch error: %v", err)
+	}
+	if bg.Image == nil || bg.URL != "s3://art/q4/a.png" || bg.Source != SourceS3 {
+		t.Fatalf("got %+v", bg)
+	}
+	source.Location.Key = "empty/"
+	if _, err := source.Fetch(1920, 1080); err == nil {
+		t.Fatalf("expected error for a prefix without images")
+	}
+}
+
+// TestWallhavenSource_ConcurrentFetchesKeepTheirParams expects sources with different searches fetching at the same
+// time, with one shared client, to each send only their own parameters, and DefaultSearchParams to hand out copies.
+// Run with -race, the test also fails on unsynchronized package state.
+func TestWallhavenSource_ConcurrentFetchesKeepTheirParams(t *testing.T) {
+	pngBytes := mustPNGBytes(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/img" {
+			_, _ = w.Write(pngBytes)
+			return
+		}
+		// The wallpaper ID echoes the search, so each fetch can check what was sent for it.
+		q := r.URL.Query()
+		id := q.Get("q") + "." + q.Get("purity") + "." + q.Get("resolutions")
+		fmt.Fprintf(w, `{"data":[{"id":%q,"path":"http://%s/img"}]}`, id, r.Host)
+	}))
+	defer server.Close()
+	client := testClient(server)
+
+	changed := DefaultSearchParams()
+	changed.Query, changed.Purity = "changed", "111"
+	if DefaultSearchParams().Query != "nature" || DefaultSearchParams().Purity != "100" {
+		t.Fatalf("DefaultSearchParams shares state: %+v", DefaultSearchParams())
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			source := WallhavenSource{Client: client}
+			want := fmt.Sprintf("nature.100.%dx100", 100+i)
+			switch i % 3 {
+			case 1:
+				source.Query = fmt.Sprintf("lake%d", i)
+				want = fmt.Sprintf("lake%d.100.%dx100", i, 100+i)
+			case 2:
+				params := DefaultSearchParams()
+				params.Query, params.Purity = fmt.Sprintf("forest%d", i), "110"
+				source.Params = &params
+				want = fmt.Sprintf("forest%d.110.%dx100", i, 100+i)
+			}
+			bg, err := source.Fetch(100+i, 100)
+			if err == nil && bg.WallpaperID != want {
+				err = fmt.Errorf("fetch %d got %s, want %s", i, bg.WallpaperID, want)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package wallpaper
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"math"
+
+	"github.com/nickhildebrandt/ts-release/internal/bundle"
+	"github.com/nickhildebrandt/ts-release/internal/wallhaven"
+)
+
+// SourceCache and SourceDefault name backgrounds that FallbackSource took from the download cache or the binary.
+const (
+	SourceCache   = "cache"
+	SourceDefault = "default"
+)
+
+// defaultBackgrounds are the backgrounds built into the binary for when no source is reachable.
+// Their aspect ratios (16:9, 16:10, 5:4) cover common displays, so DefaultSource rarely crops much.
+//
+//go:embed defaults/*.jpg
+var defaultBackgrounds embed.FS
+
+// DefaultBackgrounds returns the names of the built-in backgrounds in a stable order.
+func DefaultBackgrounds() []string {
+	return imageNames(builtinBackgrounds())
+}
+
+// builtinBackgrounds returns the built-in backgrounds with the images at the root.
+func builtinBackgrounds() fs.FS {
+	sub, _ := fs.Sub(defaultBackgrounds, "defaults")
+	return sub
+}
+
+// imageNames returns the .jpg, .jpeg, and .png files at the root of fsys in a stable order.
+func imageNames(fsys fs.FS) []string {
+	entries, _ := fs.ReadDir(fsys, ".")
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && bundle.IsImageName(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+// FallbackSource tries its sources in order and returns the first background fetched. Only fetch failures that
+// ClassifyFetchError categorizes (e.g. the service is down) move on to the next source; others, like checksum
+// mismatches or unknown wallpaper IDs, are returned at once because a fallback would hide them.
+type FallbackSource struct {
+	Sources []BackgroundSource
+	// Warn receives each failure that is covered by a later source; nil discards them.
+	Warn func(err error)
+}
+
+// Fetch implements BackgroundSource. If all sources fail, the error joins their failures.
+func (s FallbackSource) Fetch(width, height int) (Background, error) {
+	var errs []error
+	for i, source := range s.Sources {
+		bg, err := source.Fetch(width, height)
+		if err == nil {
+			return bg, nil
+		}
+		errs = append(errs, err)
+		var fetchErr *FetchError
+		if i == len(s.Sources)-1 || !errors.As(ClassifyFetchError(err), &fetchErr) {
+			break
+		}
+		if s.Warn != nil {
+			s.Warn(err)
+		}
+	}
+	if len(errs) == 0 {
+		return Background{}, fmt.Errorf("fetch background: no sources")
+	}
+	return Background{}, errors.Join(errs...)
+}
+
+// CacheSource returns the most recent image of a download cache, e.g. the background of an earlier build.
+type CacheSource struct {
+	Cache  *wallhaven.Cache
+	Limits Limits
+}
+
+// Fetch implements BackgroundSource; the size is ignored because the image is scaled like any other background.
+// A Wallhaven image keeps its wallpaper ID, so the release can still be pinned to it.
+func (s CacheSource) Fetch(width, height int) (Background, error) {
+	imageURL, data, err := s.Cache.Latest()
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: %w: %w", ErrNoResults, err)
+	}
+	img, err := decodeSRGB(data, s.Limits)
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: cached %s: %w", imageURL, err)
+	}
+	bg := Background{Image: img, URL: imageURL, Source: SourceCache}
+	if id, err := wallhaven.ParseWallpaperID(imageURL); err == nil {
+		bg.WallpaperID = id
+	}
+	return bg, nil
+}
+
+// DefaultSource returns the built-in background whose aspect ratio is closest to the canvas.
+// It needs neither network nor disk, so it ends every fallback chain.
+type DefaultSource struct {
+	// Backgrounds replaces the built-in set, e.g. with a bundle.Load result; images are read from its root.
+	// Nil selects the built-in set.
+	Backgrounds fs.FS
+}
+
+// Fetch implements BackgroundSource.
+func (s DefaultSource) Fetch(width, height int) (Background, error) {
+	if width <= 0 || height <= 0 {
+		return Background{}, fmt.Errorf("fetch background: invalid target size %dx%d", width, height)
+	}
+	backgrounds := s.Backgrounds
+	if backgrounds == nil {
+		backgrounds = builtinBackgrounds()
+	}
+	best, bestDiff := "", math.Inf(1)
+	for _, name := range imageNames(backgrounds) {
+		f, err := backgrounds.Open(name)
+		if err != nil {
+			return Background{}, fmt.Errorf("fetch background: %w", err)
+		}
+		config, _, err := image.DecodeConfig(f)
+		f.Close()
+		if err != nil {
+			return Background{}, fmt.Errorf("fetch background: default %s: %w: %w", name, ErrDecode, err)
+		}
+		diff := math.Abs(math.Log(float64(config.Width*height) / float64(config.Height*width)))
+		if diff < bestDiff {
+			best, bestDiff = name, diff
+		}
+	}
+	if best == "" {
+		return Background{}, fmt.Errorf("fetch background: no default backgrounds")
+	}
+	data, err := fs.ReadFile(backgrounds, best)
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: %w", err)
+	}
+	img, err := decodeSRGB(data, Limits{})
+	if err != nil {
+		return Background{}, fmt.Errorf("fetch background: default %s: %w", best, err)
+	}
+	return Background{Image: img, URL: "embedded:" + best, Source: SourceDefault}, nil
+}
@@ -0,0 +1,110 @@
+package wallpaper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/nickhildebrandt/ts-release/internal/bucket"
+	"github.com/nickhildebrandt/ts-release/internal/wallhaven"
+)
+
+// failingSource is a BackgroundSource that always returns err.
+type failingSource struct{ err error }
+
+// Fetch implements BackgroundSource.
+func (s failingSource) Fetch(width, height int) (Background, error) {
+	return Background{}, s.err
+}
+
+// TestFallbackSource_CacheThenDefault expects an unreachable source to fall back to the newest cached image, keeping
+// its wallpaper ID, and then to the built-in background closest to the canvas's aspect ratio.
+// The test fails if a fallback is skipped, not reported, or hides an error that must not be covered.
+func TestFallbackSource_CacheThenDefault(t *testing.T) {
+	pngBytes := mustPNGBytes(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write(pngBytes)
+	}))
+	defer server.Close()
+	cache := &wallhaven.Cache{Dir: filepath.Join(t.TempDir(), "cache")}
+	imageURL := server.URL + "/full/k7/wallhaven-k7q1vd.png"
+	if _, err := (&wallhaven.Client{HTTPClient: server.Client(), Cache: cache}).Download(imageURL); err != nil {
+		t.Fatal(err)
+	}
+
+	down := failingSource{fmt.Errorf("fetch background: wallhaven: search: http 503: %w", wallhaven.ErrServer)}
+	var warnings []error
+	chain := FallbackSource{
+		Sources: []BackgroundSource{down, CacheSource{Cache: cache}, DefaultSource{}},
+		Warn:    func(err error) { warnings = append(warnings, err) },
+	}
+	bg, err := chain.Fetch(TargetWidth, TargetHeight)
+	if err != nil || bg.Source != SourceCache || bg.URL != imageURL || bg.WallpaperID != "k7q1vd" || len(warnings) != 1 {
+		t.Fatalf("cache fallback: got %+v, %v, warnings %v", bg, err, warnings)
+	}
+
+	chain.Sources[1] = CacheSource{Cache: &wallhaven.Cache{Dir: t.TempDir()}}
+	for _, tc := range []struct {
+		width, height int
+		want          string
+	}{
+		{3840, 2160, "embedded:dawn.jpg"},
+		{1920, 1200, "embedded:lake.jpg"},
+		{1280, 1024, "embedded:forest.jpg"},
+	} {
+		bg, err := chain.Fetch(tc.width, tc.height)
+		if err != nil || bg.Source != SourceDefault || bg.URL != tc.want || bg.Image == nil {
+			t.Fatalf("%dx%d: got %+v, %v, want %s", tc.width, tc.height, bg, err, tc.want)
+		}
+	}
+
+	tampered := failingSource{fmt.Errorf("bucket: get q4.jpg: %w", bucket.ErrChecksumMismatch)}
+	chain.Sources[0] = tampered
+	if _, err := chain.Fetch(TargetWidth, TargetHeight); !errors.Is(err, bucket.ErrChecksumMismatch) {
+		t.Fatalf("checksum mismatch: got %v, want no fallback", err)
+	}
+	if _, err := (FallbackSource{Sources: []BackgroundSource{down}}).Fetch(TargetWidth, TargetHeight); !errors.Is(err, wallhaven.ErrServer) {
+		t.Fatalf("single source: got %v", err)
+	}
+}
+
+// TestDefaultSource_CustomBackgrounds expects a custom set to replace the built-in backgrounds and the image closest
+// to the canvas's aspect ratio to be chosen. The test fails if non-images are considered or the built-ins leak in.
+func TestDefaultSource_CustomBackgrounds(t *testing.T) {
+	encode := func(w, h int) []byte {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	custom := fstest.MapFS{
+		"wide.png":   {Data: encode(21, 9)},
+		"tall.png":   {Data: encode(9, 16)},
+		"README.txt": {Data: []byte("not an image")},
+	}
+	for _, tc := range []struct {
+		width, height int
+		want          string
+	}{
+		{3440, 1440, "embedded:wide.png"},
+		{1920, 1080, "embedded:wide.png"},
+		{1080, 1920, "embedded:tall.png"},
+	} {
+		bg, err := DefaultSource{Backgrounds: custom}.Fetch(tc.width, tc.height)
+		if err != nil || bg.URL != tc.want || bg.Source != SourceDefault {
+			t.Fatalf("%dx%d: got %+v, %v, want %s", tc.width, tc.height, bg, err, tc.want)
+		}
+	}
+	if _, err := (DefaultSource{Backgrounds: fstest.MapFS{}}).Fetch(TargetWidth, TargetHeight); err == nil {
+		t.Fatalf("expected an error for an empty set")
+	}
+}
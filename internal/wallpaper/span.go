@@ -0,0 +1,151 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	stddraw "image/draw"
+	"strconv"
+	"strings"
+
+	"github.com/nickhildebrandt/ts-release/internal/diag"
+)
+
+// maxSpanSide limits the spanning canvas so a typo in a monitor geometry cannot allocate gigabytes.
+const maxSpanSide = 16384
+
+// Monitor is the geometry of one display within a spanning canvas, in pixels.
+type Monitor struct {
+	X, Y          int
+	Width, Height int
+}
+
+// Rect returns the monitor's rectangle on the spanning canvas.
+func (m Monitor) Rect() image.Rectangle {
+	return image.Rect(m.X, m.Y, m.X+m.Width, m.Y+m.Height)
+}
+
+// String formats the monitor in the X geometry syntax accepted by ParseMonitors.
+func (m Monitor) String() string {
+	return fmt.Sprintf("%dx%d+%d+%d", m.Width, m.Height, m.X, m.Y)
+}
+
+// ParseMonitors parses a comma-separated list of monitor geometries.
+// Each entry is WIDTHxHEIGHT+X+Y, WIDTHxHEIGHT to place it right of the previous monitor, or COUNTxWIDTHxHEIGHT for
+// COUNT identical monitors side by side, e.g. "2x2560x1440". It returns an error for malformed entries, fewer than
+// two monitors, overlapping monitors, and canvases larger than 16384 pixels per side.
+func ParseMonitors(spec string) ([]Monitor, error) {
+	var monitors []Monitor
+	next := image.Point{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		size, offset, placed := strings.Cut(entry, "+")
+		dims, err := parseInts(strings.Split(size, "x"))
+		if err != nil || len(dims) < 2 || len(dims) > 3 || (placed && len(dims) == 3) {
+			return nil, fmt.Errorf("render: invalid monitor geometry %q", entry)
+		}
+		count := 1
+		if len(dims) == 3 {
+			count, dims = dims[0], dims[1:]
+		}
+		pos := next
+		if placed {
+			xy, err := parseInts(strings.Split(offset, "+"))
+			if err != nil || len(xy) != 2 {
+				return nil, fmt.Errorf("render: invalid monitor geometry %q", entry)
+			}
+			pos = image.Point{X: xy[0], Y: xy[1]}
+		}
+		if count < 1 || dims[0] < 1 || dims[1] < 1 {
+			return nil, fmt.Errorf("render: invalid monitor geometry %q", entry)
+		}
+		for i := 0; i < count; i++ {
+			monitors = append(monitors, Monitor{X: pos.X, Y: pos.Y, Width: dims[0], Height: dims[1]})
+			pos.X += dims[0]
+		}
+		next = pos
+	}
+
+	if len(monitors) < 2 {
+		return nil, fmt.Errorf("render: spanning needs at least 2 monitors, got %d", len(monitors))
+	}
+	bounds := SpanBounds(monitors)
+	if bounds.Dx() > maxSpanSide || bounds.Dy() > maxSpanSide {
+		return nil, fmt.Errorf("render: spanning canvas %dx%d exceeds %d pixels per side", bounds.Dx(), bounds.Dy(), maxSpanSide)
+	}
+	for i := range monitors {
+		for j := i + 1; j < len(monitors); j++ {
+			if monitors[i].Rect().Overlaps(monitors[j].Rect()) {
+				return nil, fmt.Errorf("render: monitors %s and %s overlap", monitors[i], monitors[j])
+			}
+		}
+	}
+	return monitors, nil
+}
+
+// parseInts converts non-negative decimal fields.
+func parseInts(fields []string) ([]int, error) {
+	out := make([]int, len(fields))
+	for i, f := range fields {
+		v, err := strconv.Atoi(f)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid number %q", f)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// SpanBounds returns the canvas covering all monitors, anchored at the origin.
+func SpanBounds(monitors []Monitor) image.Rectangle {
+	var r image.Rectangle
+	for _, m := range monitors {
+		r = r.Union(m.Rect())
+	}
+	return image.Rect(0, 0, r.Max.X, r.Max.Y)
+}
+
+// RenderSpan renders one canvas covering all monitors. The background spans the whole canvas; the overlay, badge,
+// and watermark are rendered for the primary monitor's resolution and placed on it, so the other monitors show
+// only the background. Crop the per-monitor images with SubImage(monitor.Rect()).
+// It returns the layout relative to the primary monitor, and an error for an invalid primary index or the errors
+// of Render.
+func RenderSpan(bg image.Image, targetName string, buildID string, opts Options, monitors []Monitor, primary int) (_ *image.RGBA, _ Layout, err error) {
+	defer diag.Recover("render", &err)
+	if bg == nil {
+		return nil, Layout{}, fmt.Errorf("render: background is nil")
+	}
+	if primary < 0 || primary >= len(monitors) {
+		return nil, Layout{}, fmt.Errorf("render: primary monitor %d out of range [1, %d]", primary+1, len(monitors))
+	}
+	bounds := SpanBounds(monitors)
+	canvas, err := resizeAndCrop(bg, bounds.Dx(), bounds.Dy(), cropRule{fit: opts.Fit, mode: opts.Crop, focal: opts.Focal, sharpen: opts.Theme.Background.Sharpen})
+	if err != nil {
+		return nil, Layout{}, err
+	}
+	// The treatment runs once over the whole canvas so vignettes and gradients continue across the bezels.
+	opts.Theme.Background.apply(canvas)
+	opts.Theme.Background = BackgroundTreatment{}
+	if err := runFilters(HookPreComposite, opts.Filters.PreComposite, canvas); err != nil {
+		return nil, Layout{}, err
+	}
+	// So do the post-composite filters and the grain, after the primary monitor is drawn.
+	filters, grain := opts.Filters, opts.Grain
+	opts.Filters, opts.Grain = Filters{}, Grain{}
+
+	m := monitors[primary]
+	opts.Width, opts.Height = m.Width, m.Height
+	// The crop already has the monitor's size, so Render places it without rescaling.
+	crop := canvas.SubImage(m.Rect())
+	img, layout, err := RenderWithLayout(crop, targetName, buildID, opts)
+	if err != nil {
+		return nil, Layout{}, err
+	}
+	stddraw.Draw(canvas, m.Rect(), img, image.Point{}, stddraw.Src)
+	if err := runFilters(HookPostComposite, filters.PostComposite, canvas); err != nil {
+		return nil, Layout{}, err
+	}
+	if err := applyGrain(canvas, grain); err != nil {
+		return nil, Layout{}, err
+	}
+	return canvas, layout, nil
+}
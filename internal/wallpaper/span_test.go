@@ -0,0 +1,74 @@
+package wallpaper
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+// TestParseMonitors_Geometries expects explicit, side-by-side, and repeated geometries to parse into monitor
+// rectangles and malformed, single, overlapping, or oversized layouts to be rejected.
+// The test fails if a geometry parses differently or an invalid layout is accepted.
+func TestParseMonitors_Geometries(t *testing.T) {
+	for spec, want := range map[string][]Monitor{
+		"2x2560x1440":                      {{0, 0, 2560, 1440}, {2560, 0, 2560, 1440}},
+		"1920x1080, 2560x1440":             {{0, 0, 1920, 1080}, {1920, 0, 2560, 1440}},
+		"1920x1080+0+360,2560x1440+1920+0": {{0, 360, 1920, 1080}, {1920, 0, 2560, 1440}},
+		"1920x1080+0+0,1920x1080+0+1080":   {{0, 0, 1920, 1080}, {0, 1080, 1920, 1080}},
+	} {
+		got, err := ParseMonitors(spec)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", spec, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q: got %v want %v", spec, got, want)
+		}
+	}
+	for _, spec := range []string{"", "2560x1440", "2560", "axb,1x1", "0x1440,1x1", "2560x1440+0,1x1", "2x1x1+0+0", "100x100+0+0,100x100+50+0", "3x8000x1000"} {
+		if _, err := ParseMonitors(spec); err == nil {
+			t.Fatalf("%q: expected error", spec)
+		}
+	}
+}
+
+// TestRenderSpan_OverlayOnPrimaryOnly expects a canvas covering all monitors whose background is continuous and
+// whose overlay is drawn only on the primary monitor.
+// The test fails if the canvas size is wrong, secondary monitors differ from the plain background, or the primary
+// monitor lacks the overlay.
+func TestRenderSpan_OverlayOnPrimaryOnly(t *testing.T) {
+	bg := goldenBackground(80, 45, goldenSeed)
+	monitors := []Monitor{{0, 0, 320, 180}, {320, 0, 320, 180}}
+	canvas, layout, err := RenderSpan(bg, "golden", "build 42", Options{}, monitors, 1)
+	if err != nil {
+		t.Fatalf("RenderSpan error: %v", err)
+	}
+	if canvas.Bounds() != image.Rect(0, 0, 640, 180) || layout.Width != 320 || layout.Height != 180 {
+		t.Fatalf("got canvas %v and layout %dx%d", canvas.Bounds(), layout.Width, layout.Height)
+	}
+
+	plain, err := resizeAndCrop(bg, 640, 180, cropRule{})
+	if err != nil {
+		t.Fatalf("resizeAndCrop error: %v", err)
+	}
+	for y := 0; y < 180; y++ {
+		for x := 0; x < 640; x++ {
+			i := canvas.PixOffset(x, y)
+			same := reflect.DeepEqual(canvas.Pix[i:i+4], plain.Pix[i:i+4])
+			switch {
+			case x < 320 && !same:
+				t.Fatalf("secondary monitor differs from the background at (%d,%d)", x, y)
+			case x == 321 && y == 1 && !same:
+				// The primary monitor's corner is outside the box, so its background is placed without rescaling.
+				t.Fatalf("primary background was resampled at (%d,%d)", x, y)
+			}
+		}
+	}
+	center := canvas.PixOffset(320+layout.BoxX0+layout.Padding/2, (layout.BoxY0+layout.BoxY1)/2)
+	if reflect.DeepEqual(canvas.Pix[center:center+4], plain.Pix[center:center+4]) {
+		t.Fatalf("primary monitor has no overlay box")
+	}
+
+	if _, _, err := RenderSpan(bg, "golden", "", Options{}, monitors, 2); err == nil {
+		t.Fatalf("expected error for out-of-range primary monitor")
+	}
+}
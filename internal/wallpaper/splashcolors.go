@@ -0,0 +1,70 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+)
+
+// SplashColors are the flat colors a boot splash such as psplash draws around the wallpaper, derived from the
+// rendered image and the overlay so its progress bar and messages match the branding.
+type SplashColors struct {
+	// Background fills the screen around the image: the mean color of the image's outermost pixels.
+	Background color.NRGBA
+	// Text is the color of boot messages, that of the subtitle.
+	Text color.NRGBA
+	// Bar is the accent of the progress bar: the first stop of the title gradient, the channel badge color, or the
+	// title's white, in that order.
+	Bar color.NRGBA
+	// BarBackground is the unfilled part of the progress bar, the overlay box color.
+	BarBackground color.NRGBA
+}
+
+// DeriveSplashColors returns the splash colors for img rendered with opts. A title gradient stop or badge color that
+// does not parse is skipped, as the theme was validated when it was loaded.
+func DeriveSplashColors(img image.Image, opts Options) SplashColors {
+	colors := SplashColors{
+		Background:    borderMean(img),
+		Text:          secondaryTextColor,
+		Bar:           color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		BarBackground: overlayBoxColor,
+	}
+	colors.BarBackground.A = 255
+	if badges, err := opts.Theme.Badge.colors(); err == nil {
+		if badge, ok := badges[opts.Channel]; ok {
+			colors.Bar = badge
+		}
+	}
+	if g := opts.Theme.Title.Gradient; g != nil && len(g.Stops) > 0 {
+		if stop, err := parseHexColor(g.Stops[0].Color, colors.Bar); err == nil {
+			colors.Bar = stop
+		}
+	}
+	colors.Bar.A = 255
+	return colors
+}
+
+// borderMean returns the mean color of the outermost row and column of pixels on each side of img.
+func borderMean(img image.Image) color.NRGBA {
+	b := img.Bounds()
+	if b.Empty() {
+		return color.NRGBA{A: 255}
+	}
+	var r, g, bl, n uint64
+	add := func(x, y int) {
+		c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+		r, g, bl, n = r+uint64(c.R), g+uint64(c.G), bl+uint64(c.B), n+1
+	}
+	for x := b.Min.X; x < b.Max.X; x++ {
+		add(x, b.Min.Y)
+		if b.Dy() > 1 {
+			add(x, b.Max.Y-1)
+		}
+	}
+	for y := b.Min.Y + 1; y < b.Max.Y-1; y++ {
+		add(b.Min.X, y)
+		if b.Dx() > 1 {
+			add(b.Max.X-1, y)
+		}
+	}
+	return color.NRGBA{R: uint8((r + n/2) / n), G: uint8((g + n/2) / n), B: uint8((bl + n/2) / n), A: 255}
+}
@@ -0,0 +1,34 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// TestDeriveSplashColors_FollowsThemeAndBorder expects the bar color to come from the title gradient before the channel
+// badge and the white title, and the background from the image border only. The test fails if a color differs.
+func TestDeriveSplashColors_FollowsThemeAndBorder(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 20, G: 40, B: 60, A: 255}), image.Point{}, draw.Src)
+	// The center pixels must not tint the background.
+	draw.Draw(img, image.Rect(1, 1, 3, 3), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+
+	plain := DeriveSplashColors(img, Options{})
+	if plain.Background != (color.NRGBA{R: 20, G: 40, B: 60, A: 255}) {
+		t.Fatalf("background = %v", plain.Background)
+	}
+	if plain.Bar != (color.NRGBA{R: 255, G: 255, B: 255, A: 255}) || plain.Text != secondaryTextColor ||
+		plain.BarBackground != (color.NRGBA{R: 12, G: 16, B: 24, A: 255}) {
+		t.Fatalf("default colors = %+v", plain)
+	}
+	if got := DeriveSplashColors(img, Options{Channel: ChannelStable}).Bar; got != (color.NRGBA{R: 46, G: 160, B: 67, A: 255}) {
+		t.Fatalf("channel bar = %v", got)
+	}
+	themed := Options{Channel: ChannelStable}
+	themed.Theme.Title.Gradient = &Gradient{Stops: []GradientStop{{Offset: 0, Color: "#ff8800"}, {Offset: 1, Color: "#0088ff"}}}
+	if got := DeriveSplashColors(img, themed).Bar; got != (color.NRGBA{R: 255, G: 136, A: 255}) {
+		t.Fatalf("gradient bar = %v", got)
+	}
+}
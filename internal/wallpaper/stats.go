@@ -0,0 +1,75 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+)
+
+// ImageStats summarizes the tones of a finished wallpaper, as an automated check that a background did not leave it
+// too dark, too bright, blown out, or the text hard to read.
+type ImageStats struct {
+	// MeanLuminance is the mean Rec. 709 luma of the gamma-encoded channels, from 0 for black to 1 for white; 0.5 is
+	// perceived as mid-gray.
+	MeanLuminance float64 `json:"mean_luminance"`
+	// BoxContrast is the WCAG contrast ratio, from 1 to 21, between the text and the box behind it, measured as the
+	// median luma of the box and the 1st or 99th percentile, whichever is farther from it. It is 0 if the layout has
+	// no box in the image.
+	BoxContrast float64 `json:"box_contrast,omitempty"`
+	// ClippedBlack and ClippedWhite are the percentages of pixels with all channels at 0 or at 255.
+	ClippedBlack float64 `json:"clipped_black_percent"`
+	ClippedWhite float64 `json:"clipped_white_percent"`
+}
+
+// MeasureImage returns the statistics of img, whose box is at the coordinates of layout relative to img's top-left
+// corner, as for the primary monitor of a span.
+func MeasureImage(img *image.RGBA, layout Layout) ImageStats {
+	b := img.Bounds()
+	box := image.Rect(layout.BoxX0, layout.BoxY0, layout.BoxX1, layout.BoxY1).Add(b.Min).Intersect(b)
+	var stats ImageStats
+	var lumaSum float64
+	var black, white int
+	var boxLuma [256]int
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			p := img.Pix[img.PixOffset(x, y):][:3]
+			luma := 0.2126*float64(p[0]) + 0.7152*float64(p[1]) + 0.0722*float64(p[2])
+			lumaSum += luma
+			switch {
+			case p[0] == 0 && p[1] == 0 && p[2] == 0:
+				black++
+			case p[0] == 255 && p[1] == 255 && p[2] == 255:
+				white++
+			}
+			if (image.Point{X: x, Y: y}).In(box) {
+				boxLuma[min(255, int(luma+0.5))]++
+			}
+		}
+	}
+	if n := float64(b.Dx() * b.Dy()); n > 0 {
+		stats.MeanLuminance = lumaSum / 255 / n
+		stats.ClippedBlack = 100 * float64(black) / n
+		stats.ClippedWhite = 100 * float64(white) / n
+	}
+	if n := box.Dx() * box.Dy(); n > 0 {
+		median, dark, light := lumaPercentile(boxLuma, n, 0.5), lumaPercentile(boxLuma, n, 0.01), lumaPercentile(boxLuma, n, 0.99)
+		text := light
+		if median-dark > light-median {
+			text = dark
+		}
+		gray := func(v int) color.NRGBA { return color.NRGBA{uint8(v), uint8(v), uint8(v), 255} }
+		stats.BoxContrast = ContrastRatio(gray(text), gray(median))
+	}
+	return stats
+}
+
+// lumaPercentile returns the smallest luma level at or below which at least the fraction p of the n counted pixels lie.
+func lumaPercentile(hist [256]int, n int, p float64) int {
+	seen := 0
+	for level, count := range hist {
+		seen += count
+		if float64(seen) >= p*float64(n) {
+			return level
+		}
+	}
+	return 255
+}
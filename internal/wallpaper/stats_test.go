@@ -0,0 +1,74 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// TestMeasureImage_Stats expects the mean luminance and clipping percentages of the whole image, and the contrast
+// between the text and the box behind it for light text on a dark box, dark text on a light box, and a box inside a
+// sub-image, as for the primary monitor of a span.
+// The test fails if a statistic is off or the box is measured at the wrong place.
+func TestMeasureImage_Stats(t *testing.T) {
+	gray := func(v uint8) color.RGBA { return color.RGBA{v, v, v, 255} }
+	// A 100x100 image, black above row 50 and white below, with a 20x20 box at 10,10 whose top row is text.
+	build := func(box, text uint8) *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+		for y := range 100 {
+			for x := range 100 {
+				c := gray(0)
+				if y >= 50 {
+					c = gray(255)
+				}
+				if x >= 10 && x < 30 && y >= 10 && y < 30 {
+					c = gray(box)
+					if y == 10 {
+						c = gray(text)
+					}
+				}
+				img.SetRGBA(x, y, c)
+			}
+		}
+		return img
+	}
+	layout := Layout{BoxX0: 10, BoxY0: 10, BoxX1: 30, BoxY1: 30}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+	for _, c := range []struct {
+		box, text uint8
+	}{{40, 240}, {220, 20}} {
+		stats := MeasureImage(build(c.box, c.text), layout)
+		wantMean := (380*float64(c.box) + 20*float64(c.text) + 5000*255) / 255 / 10000
+		if !near(stats.MeanLuminance, wantMean) {
+			t.Fatalf("box %d: mean luminance %v, want %v", c.box, stats.MeanLuminance, wantMean)
+		}
+		if !near(stats.ClippedBlack, 46) || !near(stats.ClippedWhite, 50) {
+			t.Fatalf("box %d: clipped %v%% black and %v%% white, want 46%% and 50%%", c.box, stats.ClippedBlack, stats.ClippedWhite)
+		}
+		want := ContrastRatio(color.NRGBA{c.text, c.text, c.text, 255}, color.NRGBA{c.box, c.box, c.box, 255})
+		if !near(stats.BoxContrast, want) {
+			t.Fatalf("box %d: contrast %v, want %v", c.box, stats.BoxContrast, want)
+		}
+	}
+
+	// The layout of a sub-image is relative to its top-left corner.
+	whole := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for y := range 100 {
+		for x := range 200 {
+			whole.SetRGBA(x, y, gray(0))
+		}
+	}
+	src := build(40, 240)
+	for y := range 100 {
+		copy(whole.Pix[whole.PixOffset(100, y):][:400], src.Pix[y*400:][:400])
+	}
+	sub := whole.SubImage(image.Rect(100, 0, 200, 100)).(*image.RGBA)
+	if got, want := MeasureImage(sub, layout), MeasureImage(src, layout); got != want {
+		t.Fatalf("sub-image stats %+v, want %+v", got, want)
+	}
+	if stats := MeasureImage(src, Layout{}); stats.BoxContrast != 0 {
+		t.Fatalf("contrast %v without a box, want 0", stats.BoxContrast)
+	}
+}
@@ -0,0 +1,128 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/font"
+)
+
+// Tag is one entry of the row of small badges below the subtitle that shows key properties of the image at a glance,
+// such as "secure-boot" or "A/B".
+type Tag struct {
+	// Text is drawn on a translucent pill in the subtitle color.
+	Text string
+	// Icon replaces Text: its alpha channel is drawn in the subtitle color, scaled to the row height, so any colors
+	// of the image are ignored.
+	Icon image.Image
+}
+
+// tagFontScale is the size of tag labels relative to the subtitle font size.
+const tagFontScale = 0.6
+
+// tagRow holds the measured tags and the row they form.
+type tagRow struct {
+	face  font.Face
+	tags  []Tag
+	texts []string
+	// widths holds the width of each tag; pad is the horizontal padding inside text tags and the gap between tags.
+	widths        []int
+	width, height int
+	ascent, padY  int
+	pad           int
+}
+
+// measureTags measures tags for a subtitle of subtitleSize pixels, with labels prepared for the given direction.
+// Tags without text or icon are skipped; it returns nil if none is left.
+func measureTags(tags []Tag, subtitleSize float64, dir Direction, opts Options) (*tagRow, error) {
+	var kept []Tag
+	for _, t := range tags {
+		if t.Text != "" || t.Icon != nil {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, nil
+	}
+	face, err := loadFaceChain(subtitleSize*tagFontScale, opts.Hinting.fontHinting(), regularFontData, opts.fontChain()...)
+	if err != nil {
+		return nil, fmt.Errorf("render: load tag font: %w", err)
+	}
+	m := face.Metrics()
+	textHeight := (m.Ascent + m.Descent).Ceil()
+	row := &tagRow{face: face, ascent: m.Ascent.Ceil(), padY: textHeight / 4, pad: textHeight / 2}
+	row.height = textHeight + 2*row.padY
+	for i, t := range kept {
+		var text string
+		w := 0
+		if t.Icon != nil {
+			b := t.Icon.Bounds()
+			if b.Empty() {
+				return nil, fmt.Errorf("render: tag %d: icon has no pixels", i+1)
+			}
+			w = int(math.Round(float64(row.height) * float64(b.Dx()) / float64(b.Dy())))
+		} else {
+			text = prepareLine(t.Text, resolveDirection(t.Text, opts.Direction, dir))
+			w = font.MeasureString(face, text).Ceil() + 2*row.pad
+		}
+		if i > 0 {
+			row.width += row.pad
+		}
+		row.width += w
+		row.tags, row.texts, row.widths = append(row.tags, t), append(row.texts, text), append(row.widths, w)
+	}
+	return row, nil
+}
+
+// layers returns the scene layers of the row placed in layout, in the given color; right-to-left titles reverse the
+// order, so the first tag is read first.
+func (r *tagRow) layers(layout Layout, col color.NRGBA) []Layer {
+	fill := col
+	fill.A = col.A / 5
+	var layers []Layer
+	x := layout.RowX
+	for k := range r.tags {
+		i := k
+		if layout.Direction == DirectionRTL {
+			i = len(r.tags) - 1 - k
+		}
+		w := r.widths[i]
+		if icon := r.tags[i].Icon; icon != nil {
+			layers = append(layers, Layer{
+				Type: LayerImage, X: float64(x), Y: float64(layout.RowY), W: float64(w), H: float64(r.height),
+				img: tintIcon(icon, col),
+			})
+		} else {
+			layers = append(layers,
+				Layer{
+					Type: LayerRect, Color: hexColor(fill), Radius: float64(r.height / 2),
+					X: float64(x), Y: float64(layout.RowY), W: float64(w), H: float64(r.height),
+				},
+				Layer{
+					Type: LayerText, Text: r.texts[i], Color: hexColor(col),
+					X: float64(x + r.pad), Y: float64(layout.RowY + r.padY + r.ascent),
+					face: r.face, visual: true,
+				},
+			)
+		}
+		x += w + r.pad
+	}
+	return layers
+}
+
+// tintIcon returns icon with every pixel in col, at the opacity of col times the pixel's alpha.
+func tintIcon(icon image.Image, col color.NRGBA) *image.NRGBA {
+	b := icon.Bounds()
+	out := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			_, _, _, a := icon.At(x, y).RGBA()
+			c := col
+			c.A = uint8(uint32(col.A) * a / 0xffff)
+			out.SetNRGBA(x-b.Min.X, y-b.Min.Y, c)
+		}
+	}
+	return out
+}
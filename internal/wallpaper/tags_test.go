@@ -0,0 +1,111 @@
+package wallpaper
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// TestLayouts_TagRowBelowSubtitle renders a tag row with every built-in layout for left-to-right and right-to-left
+// titles. The test fails if the row does not lie below the subtitle and inside the box and image, if the box does not
+// grow to hold it, unless it already fills the image, or if the row width differs from the tags and their gaps.
+func TestLayouts_TagRowBelowSubtitle(t *testing.T) {
+	const w, h = 1280, 720
+	bg := solidBG(w, h, color.RGBA{20, 30, 40, 255})
+	icon := image.NewAlpha(image.Rect(0, 0, 16, 8))
+	for i := range icon.Pix {
+		icon.Pix[i] = 255
+	}
+	tags := []Tag{{Text: "secure-boot"}, {}, {Text: "A/B"}, {Icon: icon}}
+	for _, name := range []string{LayoutCentered, LayoutBottomBar, LayoutCornerCard, LayoutMinimal, LayoutFullBleed} {
+		engine, err := ParseLayout(name)
+		if err != nil {
+			t.Fatalf("ParseLayout(%q) error: %v", name, err)
+		}
+		for _, dir := range []Direction{DirectionLTR, DirectionRTL} {
+			opts := Options{Layout: engine, Direction: dir, Width: w, Height: h}
+			_, plain, err := RenderWithLayout(bg, "TSSH lab", "2026-01-04", opts)
+			if err != nil {
+				t.Fatalf("%s/%s: Render error: %v", name, dir, err)
+			}
+			opts.Tags = tags
+			_, l, err := RenderWithLayout(bg, "TSSH lab", "2026-01-04", opts)
+			if err != nil {
+				t.Fatalf("%s/%s: Render with tags error: %v", name, dir, err)
+			}
+			if l.RowHeight <= 0 || l.RowY <= l.SubtitleY {
+				t.Fatalf("%s/%s: row at y=%d height %d, want below the subtitle baseline %d", name, dir, l.RowY, l.RowHeight, l.SubtitleY)
+			}
+			row := image.Rect(l.RowX, l.RowY, l.RowX+l.RowWidth, l.RowY+l.RowHeight)
+			if !row.In(image.Rect(0, 0, w, h)) {
+				t.Fatalf("%s/%s: row %v outside image", name, dir, row)
+			}
+			if box := image.Rect(l.BoxX0, l.BoxY0, l.BoxX1, l.BoxY1); !box.Empty() {
+				if !row.In(box) {
+					t.Fatalf("%s/%s: row %v outside box %v", name, dir, row, box)
+				}
+				// The full-bleed box already covers the whole image.
+				if box.Dy() <= plain.BoxY1-plain.BoxY0 && name != LayoutFullBleed {
+					t.Fatalf("%s/%s: box height %d with tags, want more than %d", name, dir, box.Dy(), plain.BoxY1-plain.BoxY0)
+				}
+			}
+		}
+	}
+
+	measured, err := measureTags(tags, 40, DirectionLTR, Options{})
+	if err != nil {
+		t.Fatalf("measureTags error: %v", err)
+	}
+	if len(measured.tags) != 3 {
+		t.Fatalf("measured %d tags, want the empty one skipped", len(measured.tags))
+	}
+	want := 2 * measured.pad
+	for _, width := range measured.widths {
+		want += width
+	}
+	if measured.width != want {
+		t.Fatalf("row width %d, want %d for widths %v and gap %d", measured.width, want, measured.widths, measured.pad)
+	}
+	if iconWidth := measured.widths[2]; iconWidth != 2*measured.height {
+		t.Fatalf("icon width %d, want twice the row height %d", iconWidth, measured.height)
+	}
+}
+
+// TestRender_TagsDrawnOrRejected checks that tags are drawn in the subtitle color, and that an engine without a place
+// for them or a row wider than the image is rejected. The test fails if tags leave the image unchanged or any of the
+// errors is missing.
+func TestRender_TagsDrawnOrRejected(t *testing.T) {
+	bg := solidBG(1280, 720, color.RGBA{0, 0, 0, 255})
+	opts := Options{Tags: []Tag{{Text: "encrypted"}}}
+	opts.Theme.Subtitle.Color = "#ff0000"
+	img, l, err := RenderWithLayout(bg, "t", "b", opts)
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	red := 0
+	for y := l.RowY; y < l.RowY+l.RowHeight; y++ {
+		for x := l.RowX; x < l.RowX+l.RowWidth; x++ {
+			if c := img.RGBAAt(x, y); int(c.R) > 2*maxInt(int(c.G), 20) {
+				red++
+			}
+		}
+	}
+	if red == 0 {
+		t.Fatalf("no red pixels in the tag row %+v", l)
+	}
+
+	if _, err := Render(bg, "t", "b", Options{Layout: fixedLayout{}, Tags: opts.Tags}); err == nil ||
+		!strings.Contains(err.Error(), "has no place for tags") {
+		t.Fatalf("expected a layout without a tag row to be rejected, got %v", err)
+	}
+
+	many := make([]Tag, 40)
+	for i := range many {
+		many[i] = Tag{Text: "secure-boot"}
+	}
+	if _, err := Render(bg, "t", "b", Options{Tags: many}); !errors.Is(err, ErrTextTooLong) {
+		t.Fatalf("expected %d tags to be too long, got %v", len(many), err)
+	}
+}
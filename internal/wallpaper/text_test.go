@@ -0,0 +1,114 @@
+package wallpaper
+
+import (
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// TestShapeArabic_ContextualFormsAndLigature expects initial/final forms for joined letters and the lam-alef ligature.
+// The test fails if letters stay in their nominal form or lam-alef is not merged.
+func TestShapeArabic_ContextualFormsAndLigature(t *testing.T) {
+	cases := []struct {
+		name string
+		in   []rune
+		want []rune
+	}{
+		{name: "beh beh", in: []rune{0x0628, 0x0628}, want: []rune{0xFE91, 0xFE90}},
+		{name: "beh beh beh", in: []rune{0x0628, 0x0628, 0x0628}, want: []rune{0xFE91, 0xFE92, 0xFE90}},
+		{name: "alef breaks join", in: []rune{0x0627, 0x0628}, want: []rune{0xFE8D, 0xFE8F}},
+		{name: "lam alef", in: []rune{0x0644, 0x0627}, want: []rune{0xFEFB}},
+		{name: "beh lam alef", in: []rune{0x0628, 0x0644, 0x0627}, want: []rune{0xFE91, 0xFEFC}},
+		{name: "latin untouched", in: []rune("TSSH"), want: []rune("TSSH")},
+	}
+	for _, c := range cases {
+		if got := shapeArabic(c.in); string(got) != string(c.want) {
+			t.Fatalf("%s: got %U want %U", c.name, got, c.want)
+		}
+	}
+}
+
+// TestVisualOrder_MixedDirection checks reordering for pure RTL, RTL embedded in LTR, and numbers inside RTL text.
+// The test fails if right-to-left runs are not reversed or numbers lose their left-to-right order.
+func TestVisualOrder_MixedDirection(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{in: "build 2026-01-04", want: "build 2026-01-04"},
+		{in: "שלום", want: "םולש"},
+		{in: "TSSH שלום", want: "TSSH םולש"},
+		{in: "TSSH שלום עולם", want: "TSSH םלוע םולש"},
+		{in: "גרסה 42", want: "42 הסרג"},
+		{in: "v1.2 שלום world", want: "v1.2 םולש world"},
+	}
+	for _, c := range cases {
+		if got := string(visualOrder([]rune(c.in))); got != c.want {
+			t.Fatalf("visualOrder(%q): got %q want %q", c.in, got, c.want)
+		}
+	}
+}
+
+// TestFallbackFace_PicksFontWithGlyph expects runes missing from the primary font to come from the fallback font.
+// The test fails if the chain uses the primary font for Hebrew or the fallback for Latin.
+func TestFallbackFace_PicksFontWithGlyph(t *testing.T) {
+	face, err := loadFaceChain(24, font.HintingNone, goregular.TTF, regularFontData)
+	if err != nil {
+		t.Fatalf("loadFaceChain error: %v", err)
+	}
+	defer face.Close()
+
+	chain, ok := face.(*fallbackFace)
+	if !ok {
+		t.Fatalf("expected *fallbackFace, got %T", face)
+	}
+	if chain.faceFor('A') != chain.faces[0] {
+		t.Fatalf("expected primary face for 'A'")
+	}
+	if chain.faceFor('ש') != chain.faces[1] {
+		t.Fatalf("expected fallback face for 'ש'")
+	}
+	if _, ok := face.GlyphAdvance('ש'); !ok {
+		t.Fatalf("expected glyph advance for 'ש'")
+	}
+
+	if _, err := loadFaceChain(24, font.HintingNone, goregular.TTF, []byte("not a font")); err == nil {
+		t.Fatalf("expected error for invalid fallback font")
+	}
+}
+
+// TestPrepareLine_RTLBaseDirectionAndMirroring checks title composition, bracket mirroring, and direction resolution
+// for right-to-left and mixed-direction lines.
+// The test fails if the "TSSH" prefix does not lead an RTL line, brackets point the wrong way, or a neutral subtitle
+// does not inherit the title direction.
+func TestPrepareLine_RTLBaseDirectionAndMirroring(t *testing.T) {
+	cases := []struct {
+		in   string
+		dir  Direction
+		want string
+	}{
+		{in: "TSSH שלום", dir: DirectionRTL, want: "םולש TSSH"},
+		{in: "TSSH שלום", dir: DirectionLTR, want: "TSSH םולש"},
+		{in: "גרסה (בטא)", dir: DirectionRTL, want: "(אטב) הסרג"},
+		{in: "גרסה v2.3.1 (a1b2c3d)", dir: DirectionRTL, want: "v2.3.1 (a1b2c3d) הסרג"},
+		{in: "2026-01-04", dir: DirectionRTL, want: "2026-01-04"},
+	}
+	for _, c := range cases {
+		if got := prepareLine(c.in, c.dir); got != c.want {
+			t.Fatalf("prepareLine(%q, %s): got %q want %q", c.in, c.dir, got, c.want)
+		}
+	}
+
+	if d := resolveDirection("שלום", DirectionAuto, DirectionLTR); d != DirectionRTL {
+		t.Fatalf("expected RTL for Hebrew text, got %s", d)
+	}
+	if d := resolveDirection("2026-01-04", DirectionAuto, DirectionRTL); d != DirectionRTL {
+		t.Fatalf("expected neutral text to inherit RTL, got %s", d)
+	}
+	if d := resolveDirection("שלום", DirectionLTR, DirectionRTL); d != DirectionLTR {
+		t.Fatalf("expected explicit LTR to win, got %s", d)
+	}
+	if _, err := ParseDirection("ttb"); err == nil {
+		t.Fatalf("expected error for unknown direction")
+	}
+}
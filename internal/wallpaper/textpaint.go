@@ -0,0 +1,223 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	stddraw "image/draw"
+	"math"
+
+	"golang.org/x/image/font"
+)
+
+// Gradient fills text with a linear gradient spanning the inked bounds of the line.
+type Gradient struct {
+	// Angle is the gradient direction in degrees: 0 runs left to right, 90 top to bottom.
+	Angle float64        `json:"angle,omitempty"`
+	Stops []GradientStop `json:"stops"`
+}
+
+// GradientStop is a color at a relative position along the gradient.
+type GradientStop struct {
+	// Offset is the position in [0, 1]; offsets must not decrease from one stop to the next.
+	Offset float64 `json:"offset"`
+	// Color is "#rrggbb" or "#rrggbbaa".
+	Color string `json:"color"`
+}
+
+// Stroke outlines text, e.g. for readability on bright backgrounds.
+type Stroke struct {
+	// Width is the outline width in pixels at TargetHeight for themes, or in design units for scene layers.
+	Width float64 `json:"width"`
+	// Color is "#rrggbb" or "#rrggbbaa"; empty selects defaultStrokeColor.
+	Color string `json:"color,omitempty"`
+}
+
+// defaultStrokeColor is a translucent black that separates light text from bright backgrounds.
+var defaultStrokeColor = color.NRGBA{A: 192}
+
+// validate checks the number, order, and colors of the stops.
+func (g *Gradient) validate() error {
+	if g == nil {
+		return nil
+	}
+	if len(g.Stops) < 2 {
+		return fmt.Errorf("gradient needs at least two stops")
+	}
+	for i, s := range g.Stops {
+		if s.Offset < 0 || s.Offset > 1 || (i > 0 && s.Offset < g.Stops[i-1].Offset) {
+			return fmt.Errorf("gradient stop %d: offset %v must be in [0, 1] and not decrease", i, s.Offset)
+		}
+		if s.Color == "" {
+			return fmt.Errorf("gradient stop %d: missing color", i)
+		}
+		if _, err := parseHexColor(s.Color, color.NRGBA{}); err != nil {
+			return fmt.Errorf("gradient stop %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// validate checks the width and color.
+func (s *Stroke) validate() error {
+	if s == nil {
+		return nil
+	}
+	if s.Width <= 0 {
+		return fmt.Errorf("stroke width %v must be positive", s.Width)
+	}
+	if _, err := parseHexColor(s.Color, defaultStrokeColor); err != nil {
+		return fmt.Errorf("stroke: %w", err)
+	}
+	return nil
+}
+
+// scaled returns a copy of s with the width multiplied by scale, or nil for a nil stroke.
+func (s *Stroke) scaled(scale float64) *Stroke {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	c.Width *= scale
+	return &c
+}
+
+// drawStyledText draws text like drawText, optionally filled with a gradient and outlined with a stroke.
+// The stroke width is in pixels. Color glyphs are filled with the gradient like any other glyph.
+func drawStyledText(dst *image.RGBA, face font.Face, text string, x, y int, col color.NRGBA, gradient *Gradient, stroke *Stroke) error {
+	if gradient == nil && stroke == nil {
+		return drawText(dst, face, text, x, y, col)
+	}
+	if face == nil {
+		return fmt.Errorf("render: font face is nil")
+	}
+
+	bounds, _ := font.BoundString(face, text)
+	ink := image.Rect(x+bounds.Min.X.Floor(), y+bounds.Min.Y.Floor(), x+bounds.Max.X.Ceil(), y+bounds.Max.Y.Ceil())
+	margin := 1
+	if stroke != nil {
+		margin += int(math.Ceil(stroke.Width))
+	}
+	area := ink.Inset(-margin)
+
+	// Draw opaque text into a scratch layer and keep its alpha as the glyph coverage mask.
+	layer := image.NewRGBA(area)
+	if err := drawText(layer, face, text, x, y, color.NRGBA{R: 255, G: 255, B: 255, A: 255}); err != nil {
+		return err
+	}
+	mask := image.NewAlpha(area)
+	for i := range mask.Pix {
+		mask.Pix[i] = layer.Pix[4*i+3]
+	}
+
+	if stroke != nil {
+		strokeColor, err := parseHexColor(stroke.Color, defaultStrokeColor)
+		if err != nil {
+			return err
+		}
+		outline := dilate(mask, stroke.Width)
+		stddraw.DrawMask(dst, area, image.NewUniform(strokeColor), image.Point{}, outline, area.Min, stddraw.Over)
+	}
+
+	var src image.Image = image.NewUniform(col)
+	if gradient != nil {
+		fill, err := gradientImage(area, ink, gradient)
+		if err != nil {
+			return err
+		}
+		src = fill
+	}
+	stddraw.DrawMask(dst, area, src, area.Min, mask, area.Min, stddraw.Over)
+	return nil
+}
+
+// dilate grows the coverage of mask by radius pixels with an anti-aliased circular pen.
+func dilate(mask *image.Alpha, radius float64) *image.Alpha {
+	b := mask.Bounds()
+	out := image.NewAlpha(b)
+	r := int(math.Ceil(radius))
+	type tap struct {
+		dx, dy int
+		weight float64
+	}
+	var taps []tap
+	for dy := -r; dy <= r; dy++ {
+		for dx := -r; dx <= r; dx++ {
+			// Full weight inside the radius, fading out over the boundary pixel.
+			w := math.Min(1, math.Max(0, radius+0.5-math.Hypot(float64(dx), float64(dy))))
+			if w > 0 {
+				taps = append(taps, tap{dx, dy, w})
+			}
+		}
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			var best float64
+			for _, t := range taps {
+				px, py := x+t.dx, y+t.dy
+				if px < b.Min.X || px >= b.Max.X || py < b.Min.Y || py >= b.Max.Y {
+					continue
+				}
+				if v := float64(mask.Pix[mask.PixOffset(px, py)]) * t.weight; v > best {
+					best = v
+				}
+			}
+			out.Pix[out.PixOffset(x, y)] = uint8(best + 0.5)
+		}
+	}
+	return out
+}
+
+// gradientImage renders g over area, with offsets 0 and 1 at the extremes of span along the gradient direction.
+func gradientImage(area, span image.Rectangle, g *Gradient) (*image.RGBA, error) {
+	colors := make([]color.NRGBA, len(g.Stops))
+	for i, s := range g.Stops {
+		c, err := parseHexColor(s.Color, color.NRGBA{})
+		if err != nil {
+			return nil, err
+		}
+		colors[i] = c
+	}
+
+	rad := g.Angle * math.Pi / 180
+	dx, dy := math.Cos(rad), math.Sin(rad)
+	// Project the span's corners onto the direction to find where offsets 0 and 1 lie.
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, p := range []image.Point{span.Min, {X: span.Max.X, Y: span.Min.Y}, {X: span.Min.X, Y: span.Max.Y}, span.Max} {
+		proj := float64(p.X)*dx + float64(p.Y)*dy
+		lo, hi = math.Min(lo, proj), math.Max(hi, proj)
+	}
+
+	img := image.NewRGBA(area)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			t := 0.0
+			if hi > lo {
+				t = ((float64(x)+0.5)*dx + (float64(y)+0.5)*dy - lo) / (hi - lo)
+			}
+			img.Set(x, y, gradientAt(g.Stops, colors, t))
+		}
+	}
+	return img, nil
+}
+
+// gradientAt interpolates the stop colors at t, clamping outside the first and last stop.
+func gradientAt(stops []GradientStop, colors []color.NRGBA, t float64) color.NRGBA {
+	if t <= stops[0].Offset {
+		return colors[0]
+	}
+	for i := 1; i < len(stops); i++ {
+		if t > stops[i].Offset {
+			continue
+		}
+		span := stops[i].Offset - stops[i-1].Offset
+		if span <= 0 {
+			return colors[i]
+		}
+		k := (t - stops[i-1].Offset) / span
+		a, b := colors[i-1], colors[i]
+		lerp := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*k + 0.5) }
+		return color.NRGBA{R: lerp(a.R, b.R), G: lerp(a.G, b.G), B: lerp(a.B, b.B), A: lerp(a.A, b.A)}
+	}
+	return colors[len(colors)-1]
+}
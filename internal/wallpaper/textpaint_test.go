@@ -0,0 +1,58 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/font"
+)
+
+// TestDrawStyledText_GradientAndStroke expects the gradient to run from the first to the last stop across the ink
+// and the stroke to paint pixels just outside the glyphs that plain text leaves untouched.
+// The test fails if the fill ignores the gradient or the outline is not drawn around the glyphs.
+func TestDrawStyledText_GradientAndStroke(t *testing.T) {
+	face, err := loadFace(boldFontData, 40, font.HintingNone)
+	if err != nil {
+		t.Fatalf("loadFace error: %v", err)
+	}
+	const text = "IIII"
+	rect := image.Rect(0, 0, 200, 60)
+	white := color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+
+	plain := image.NewRGBA(rect)
+	if err := drawText(plain, face, text, 10, 45, white); err != nil {
+		t.Fatalf("drawText error: %v", err)
+	}
+	gradient := &Gradient{Angle: 90, Stops: []GradientStop{{Offset: 0, Color: "#ff0000"}, {Offset: 1, Color: "#0000ff"}}}
+	styled := image.NewRGBA(rect)
+	if err := drawStyledText(styled, face, text, 10, 45, white, gradient, &Stroke{Width: 3, Color: "#00ff00"}); err != nil {
+		t.Fatalf("drawStyledText error: %v", err)
+	}
+
+	var top, bottom, outline color.RGBA
+	topY, bottomY := rect.Max.Y, -1
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			p := plain.RGBAAt(x, y)
+			s := styled.RGBAAt(x, y)
+			if p.A == 255 {
+				if y < topY {
+					topY, top = y, s
+				}
+				if y > bottomY {
+					bottomY, bottom = y, s
+				}
+			}
+			if p.A == 0 && s.A > 0 {
+				outline = s
+			}
+		}
+	}
+	if top.R <= top.B || bottom.B <= bottom.R {
+		t.Fatalf("gradient not applied top to bottom: top %v, bottom %v", top, bottom)
+	}
+	if outline.G == 0 || outline.R != 0 || outline.B != 0 {
+		t.Fatalf("expected green stroke outside the glyphs, got %v", outline)
+	}
+}
@@ -0,0 +1,172 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// TextStyle configures letter spacing, case, fill, and outline for one line of text.
+// The zero value draws the text unchanged.
+type TextStyle struct {
+	// Tracking adds letter spacing between glyphs as a fraction of the font size, e.g. 0.05 for +5%.
+	Tracking float64 `json:"tracking,omitempty"`
+	// Transform changes the letter case before shaping.
+	Transform TextTransform `json:"transform,omitempty"`
+	// Gradient fills the glyphs with a linear gradient instead of the line's solid color.
+	Gradient *Gradient `json:"gradient,omitempty"`
+	// Stroke outlines the glyphs; its width is in pixels at TargetHeight and scales with the output height.
+	Stroke *Stroke `json:"stroke,omitempty"`
+	// Color is the solid color of the line, "#rrggbb" or "#rrggbbaa"; empty keeps the layout's color.
+	Color string `json:"color,omitempty"`
+}
+
+// TextTransform selects a letter case transformation.
+type TextTransform string
+
+const (
+	TextTransformNone      TextTransform = "none"
+	TextTransformUppercase TextTransform = "uppercase"
+	// TextTransformSmallCaps draws lowercase letters as capitals at smallCapsScale of the font size.
+	TextTransformSmallCaps TextTransform = "smallcaps"
+)
+
+// TextTransforms returns all selectable text transforms in a stable order for help output and validation.
+func TextTransforms() []TextTransform {
+	return []TextTransform{TextTransformNone, TextTransformUppercase, TextTransformSmallCaps}
+}
+
+// smallCapsScale is the size of synthesized small capitals relative to the font size, close to the x-height of
+// DejaVu Sans so small caps line up with lowercase text of the same size.
+const smallCapsScale = 0.75
+
+// validate checks the transform name, the tracking range, the gradient stops, and the stroke.
+func (s TextStyle) validate(field string) error {
+	if s.Transform != "" {
+		known := false
+		for _, t := range TextTransforms() {
+			known = known || t == s.Transform
+		}
+		if !known {
+			return fmt.Errorf("theme: %s: unknown transform %q", field, s.Transform)
+		}
+	}
+	if s.Tracking < -0.5 || s.Tracking > 1 {
+		return fmt.Errorf("theme: %s: tracking %v out of range [-0.5, 1]", field, s.Tracking)
+	}
+	if _, err := parseHexColor(s.Color, color.NRGBA{}); err != nil {
+		return fmt.Errorf("theme: %s: %w", field, err)
+	}
+	if err := s.Gradient.validate(); err != nil {
+		return fmt.Errorf("theme: %s: %w", field, err)
+	}
+	if err := s.Stroke.validate(); err != nil {
+		return fmt.Errorf("theme: %s: %w", field, err)
+	}
+	return nil
+}
+
+// transformText applies an uppercase transform using the casing rules of lang, e.g. dotted capital I for Turkish.
+// Small caps are applied by the face instead, so the text keeps its lowercase letters for smallCapsFace.
+func (s TextStyle) transformText(text, lang string) string {
+	if s.Transform != TextTransformUppercase {
+		return text
+	}
+	return cases.Upper(language.Make(lang)).String(text)
+}
+
+// styledFace wraps face with the style's small caps and tracking.
+// loadSmall loads the same font chain at another size and is only called for small caps.
+func (s TextStyle) styledFace(face font.Face, size float64, loadSmall func(size float64) (font.Face, error)) (font.Face, error) {
+	if s.Transform == TextTransformSmallCaps {
+		small, err := loadSmall(size * smallCapsScale)
+		if err != nil {
+			return nil, err
+		}
+		face = &smallCapsFace{Face: face, small: small}
+	}
+	if s.Tracking != 0 {
+		face = &trackedFace{Face: face, tracking: fixed.Int26_6(math.Round(s.Tracking * size * 64))}
+	}
+	return face, nil
+}
+
+// trackedFace adds a fixed letter spacing between glyphs by extending Kern.
+// Measurement (font.MeasureString, font.BoundString) and drawing both use Kern, so they stay consistent.
+type trackedFace struct {
+	font.Face
+	tracking fixed.Int26_6
+}
+
+// Kern implements font.Face.
+func (f *trackedFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	return f.Face.Kern(r0, r1) + f.tracking
+}
+
+// ColorGlyph forwards to the wrapped face so color emoji survive tracking.
+func (f *trackedFace) ColorGlyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, bool) {
+	if cf, ok := f.Face.(colorGlyphFace); ok {
+		return cf.ColorGlyph(dot, r)
+	}
+	return image.Rectangle{}, nil, false
+}
+
+// smallCapsFace synthesizes small capitals: lowercase letters are drawn as capitals from a smaller face.
+// Fonts rarely ship small-cap glyphs reachable without OpenType feature support, so they are scaled capitals.
+type smallCapsFace struct {
+	font.Face
+	small font.Face
+}
+
+// pick returns the face and rune used to draw r.
+func (f *smallCapsFace) pick(r rune) (font.Face, rune) {
+	if unicode.IsLower(r) {
+		if upper := unicode.ToUpper(r); upper != r {
+			return f.small, upper
+		}
+	}
+	return f.Face, r
+}
+
+// Glyph implements font.Face.
+func (f *smallCapsFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	face, r := f.pick(r)
+	return face.Glyph(dot, r)
+}
+
+// GlyphBounds implements font.Face.
+func (f *smallCapsFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	face, r := f.pick(r)
+	return face.GlyphBounds(r)
+}
+
+// GlyphAdvance implements font.Face.
+func (f *smallCapsFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	face, r := f.pick(r)
+	return face.GlyphAdvance(r)
+}
+
+// Kern implements font.Face; pairs drawn from different sizes are not kerned.
+func (f *smallCapsFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	face0, r0 := f.pick(r0)
+	face1, r1 := f.pick(r1)
+	if face0 != face1 {
+		return 0
+	}
+	return face0.Kern(r0, r1)
+}
+
+// ColorGlyph forwards to the full-size face so color emoji survive small caps.
+func (f *smallCapsFace) ColorGlyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, bool) {
+	if cf, ok := f.Face.(colorGlyphFace); ok {
+		return cf.ColorGlyph(dot, r)
+	}
+	return image.Rectangle{}, nil, false
+}
@@ -0,0 +1,93 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// TestTextStyle_TrackingMeasuredAndDrawnConsistently expects tracking to widen measurement by one step per glyph gap
+// and the drawn ink to end where the measurement says.
+// The test fails if layout measurement and drawText disagree about tracked text.
+func TestTextStyle_TrackingMeasuredAndDrawnConsistently(t *testing.T) {
+	plain, err := loadFace(boldFontData, 40, font.HintingNone)
+	if err != nil {
+		t.Fatalf("loadFace error: %v", err)
+	}
+	tracked, err := TextStyle{Tracking: 0.25}.styledFace(plain, 40, nil)
+	if err != nil {
+		t.Fatalf("styledFace error: %v", err)
+	}
+
+	const text = "HHHH"
+	gaps := fixed.I(10) * 3 // 0.25 * 40px between each of the four glyphs
+	if got, want := font.MeasureString(tracked, text), font.MeasureString(plain, text)+gaps; got != want {
+		t.Fatalf("tracked width %v, want %v", got, want)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 300, 60))
+	if err := drawText(img, tracked, text, 10, 45, color.NRGBA{A: 255}); err != nil {
+		t.Fatalf("drawText error: %v", err)
+	}
+	right := 0
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 300; x++ {
+			if img.RGBAAt(x, y).A > 0 && x > right {
+				right = x
+			}
+		}
+	}
+	// H has side bearings of a few pixels, so the ink ends shortly before the measured advance.
+	if end := 10 + font.MeasureString(tracked, text).Ceil(); right > end || right < end-8 {
+		t.Fatalf("ink ends at x=%d, measured end %d", right, end)
+	}
+}
+
+// TestTextStyle_UppercaseAndSmallCaps expects locale-aware uppercasing and small caps drawn as reduced capitals.
+func TestTextStyle_UppercaseAndSmallCaps(t *testing.T) {
+	upper := TextStyle{Transform: TextTransformUppercase}
+	if got := upper.transformText("istanbul", "tr"); got != "İSTANBUL" {
+		t.Fatalf("Turkish uppercase: got %q", got)
+	}
+	if got := upper.transformText("istanbul", "en"); got != "ISTANBUL" {
+		t.Fatalf("English uppercase: got %q", got)
+	}
+	if got := (TextStyle{Transform: TextTransformSmallCaps}).transformText("build", "en"); got != "build" {
+		t.Fatalf("small caps must not change the text, got %q", got)
+	}
+
+	load := func(size float64) (font.Face, error) { return loadFace(regularFontData, size, font.HintingNone) }
+	full, err := load(40)
+	if err != nil {
+		t.Fatalf("loadFace error: %v", err)
+	}
+	face, err := TextStyle{Transform: TextTransformSmallCaps}.styledFace(full, 40, load)
+	if err != nil {
+		t.Fatalf("styledFace error: %v", err)
+	}
+	small, _ := load(40 * smallCapsScale)
+	if got, _ := face.GlyphAdvance('a'); got != mustAdvance(t, small, 'A') {
+		t.Fatalf("small cap advance %v, want advance of A at %.0fpx", got, 40*smallCapsScale)
+	}
+	if got, _ := face.GlyphAdvance('A'); got != mustAdvance(t, full, 'A') {
+		t.Fatalf("capital advance %v changed", got)
+	}
+	lower, _ := font.BoundString(face, "x")
+	capital, _ := font.BoundString(face, "X")
+	if lower.Min.Y <= capital.Min.Y || lower.Max.Y != capital.Max.Y {
+		t.Fatalf("small cap should be a shorter capital on the same baseline: %v vs. %v", lower, capital)
+	}
+}
+
+// mustAdvance returns the advance of r in face and fails the test if the glyph is missing.
+func mustAdvance(t *testing.T, face font.Face, r rune) fixed.Int26_6 {
+	t.Helper()
+	adv, ok := face.GlyphAdvance(r)
+	if !ok {
+		t.Fatalf("missing glyph %q", r)
+	}
+	return adv
+}
@@ -0,0 +1,172 @@
+package wallpaper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+)
+
+// Theme holds styling options shared by all layouts, loaded from a JSON file or selected by preset name via --theme.
+// The zero value reproduces the default look.
+type Theme struct {
+	// VerticalAlign selects how the text block is centered vertically within its box.
+	VerticalAlign VerticalAlign `json:"vertical_align,omitempty"`
+	// BaselineGrid snaps text baselines to multiples of this many pixels at TargetHeight, scaled to the output
+	// height; zero disables the grid.
+	BaselineGrid float64 `json:"baseline_grid,omitempty"`
+	// FontScale multiplies the layout's font sizes, e.g. 1.25 for large text; zero keeps them.
+	FontScale float64 `json:"font_scale,omitempty"`
+	// Panel fills the box behind the text.
+	Panel Panel `json:"panel"`
+	// Separator styles the line between title and subtitle of layouts that draw one.
+	Separator Separator `json:"separator"`
+	// Title and Subtitle set letter spacing, case, and color for each line.
+	Title    TextStyle `json:"title"`
+	Subtitle TextStyle `json:"subtitle"`
+	// Background adjusts the background photo before the overlay is drawn.
+	Background BackgroundTreatment `json:"background"`
+	// Badge selects the colors of the channel badge.
+	Badge BadgeStyle `json:"badge"`
+}
+
+// Panel styles the box behind the text.
+type Panel struct {
+	// Color is "#rrggbb" for an opaque panel or "#rrggbbaa"; empty keeps the layout's translucent box. Layouts that
+	// draw no box, such as minimal, get one around the text when it is set, so the text never sits on the photo.
+	Color string `json:"color,omitempty"`
+	// Corners shapes the corners of the panel, or of the layout's box without Color. Its radii are in pixels at
+	// TargetHeight, scaled to the output height; without them the layout's radius applies.
+	Corners *Corners `json:"corners,omitempty"`
+}
+
+// MaxFontScale bounds Theme.FontScale and Options.FontScale; text at a larger scale no longer fits any layout.
+const MaxFontScale = 4
+
+// VerticalAlign selects the vertical centering strategy for the text block.
+type VerticalAlign string
+
+const (
+	// VerticalAlignMetrics centers by font ascent and descent, so the result does not depend on the text.
+	VerticalAlignMetrics VerticalAlign = "metrics"
+	// VerticalAlignOptical centers the inked glyph bounds, so all-caps titles without descenders do not sit low.
+	VerticalAlignOptical VerticalAlign = "optical"
+)
+
+// VerticalAligns returns all selectable vertical alignment modes in a stable order for help output and validation.
+func VerticalAligns() []VerticalAlign {
+	return []VerticalAlign{VerticalAlignMetrics, VerticalAlignOptical}
+}
+
+// LoadTheme reads a JSON theme file.
+// It returns an error for unreadable files, invalid JSON, unknown fields, and invalid values.
+func LoadTheme(path string) (Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Theme{}, fmt.Errorf("theme: read %s: %w", path, err)
+	}
+	return ParseTheme(data)
+}
+
+// ParseTheme decodes and validates a JSON theme.
+// Unknown fields are rejected so typos do not silently fall back to defaults.
+func ParseTheme(data []byte) (Theme, error) {
+	var theme Theme
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&theme); err != nil {
+		return Theme{}, fmt.Errorf("theme: decode: %w", err)
+	}
+	if err := theme.validate(); err != nil {
+		return Theme{}, err
+	}
+	return theme, nil
+}
+
+// validate checks enumerations and ranges.
+func (t Theme) validate() error {
+	if t.VerticalAlign != "" {
+		known := false
+		for _, v := range VerticalAligns() {
+			known = known || v == t.VerticalAlign
+		}
+		if !known {
+			return fmt.Errorf("theme: unknown vertical_align %q", t.VerticalAlign)
+		}
+	}
+	if t.BaselineGrid < 0 {
+		return fmt.Errorf("theme: baseline_grid %v must not be negative", t.BaselineGrid)
+	}
+	if t.FontScale < 0 || t.FontScale > MaxFontScale {
+		return fmt.Errorf("theme: font_scale %v out of range (0, %d]", t.FontScale, MaxFontScale)
+	}
+	if _, err := parseHexColor(t.Panel.Color, color.NRGBA{}); err != nil {
+		return fmt.Errorf("theme: panel: %w", err)
+	}
+	if err := t.Panel.Corners.validate(); err != nil {
+		return fmt.Errorf("theme: panel: %w", err)
+	}
+	if err := t.Separator.validate(); err != nil {
+		return err
+	}
+	if err := t.Title.validate("title"); err != nil {
+		return err
+	}
+	if err := t.Subtitle.validate("subtitle"); err != nil {
+		return err
+	}
+	if err := t.Background.validate(); err != nil {
+		return err
+	}
+	return t.Badge.validate()
+}
+
+// Built-in theme presets for products that must meet accessibility requirements. Both draw an opaque panel behind
+// text enlarged by a quarter, with colors that keep a contrast ratio of at least 7:1 (WCAG level AAA) to it.
+const (
+	ThemeHighContrastDark  = "hc-dark"
+	ThemeHighContrastLight = "hc-light"
+)
+
+// themePresets holds the themes selected by name.
+var themePresets = map[string]Theme{
+	ThemeHighContrastDark: {
+		FontScale: 1.25,
+		Panel:     Panel{Color: "#000000"},
+		Title:     TextStyle{Color: "#ffffff"},
+		Subtitle:  TextStyle{Color: "#e6e6e6"},
+	},
+	ThemeHighContrastLight: {
+		FontScale: 1.25,
+		Panel:     Panel{Color: "#ffffff"},
+		Title:     TextStyle{Color: "#000000"},
+		Subtitle:  TextStyle{Color: "#1a1a1a"},
+	},
+}
+
+// ThemePresets returns the names of the built-in themes in a stable order for help output.
+func ThemePresets() []string {
+	return []string{ThemeHighContrastDark, ThemeHighContrastLight}
+}
+
+// PresetTheme returns the built-in theme of the given name; ok is false for other names.
+func PresetTheme(name string) (theme Theme, ok bool) {
+	theme, ok = themePresets[name]
+	return theme, ok
+}
+
+// ContrastRatio returns the WCAG contrast ratio of two opaque sRGB colors, from 1 for equal luminance to 21 for black
+// on white.
+func ContrastRatio(a, b color.NRGBA) float64 {
+	la, lb := relativeLuminance(a), relativeLuminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// relativeLuminance returns the WCAG relative luminance of c, ignoring its alpha.
+func relativeLuminance(c color.NRGBA) float64 {
+	return 0.2126*srgbToLinear(float64(c.R)/255) + 0.7152*srgbToLinear(float64(c.G)/255) + 0.0722*srgbToLinear(float64(c.B)/255)
+}
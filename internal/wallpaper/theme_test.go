@@ -0,0 +1,97 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseTheme_ValidAndInvalid expects valid themes to decode and invalid values or unknown fields to be rejected.
+func TestParseTheme_ValidAndInvalid(t *testing.T) {
+	theme, err := ParseTheme([]byte(`{"vertical_align": "optical", "baseline_grid": 24, "title": {"tracking": 0.05, "transform": "uppercase"}}`))
+	want := Theme{VerticalAlign: VerticalAlignOptical, BaselineGrid: 24, Title: TextStyle{Tracking: 0.05, Transform: TextTransformUppercase}}
+	if err != nil || !reflect.DeepEqual(theme, want) {
+		t.Fatalf("ParseTheme = %+v, %v", theme, err)
+	}
+	for json, want := range map[string]string{
+		`{"vertical_align": "middle"}`:      `unknown vertical_align "middle"`,
+		`{"baseline_grid": -4}`:             "must not be negative",
+		`{"baseline": 4}`:                   `unknown field "baseline"`,
+		`{"title": {"transform": "lower"}}`: `title: unknown transform "lower"`,
+		`{"subtitle": {"tracking": 2}}`:     "subtitle: tracking 2 out of range",
+		`{"title": {"gradient": {"stops": [{"offset": 0, "color": "#ffffff"}]}}}`:                                        "title: gradient needs at least two stops",
+		`{"title": {"gradient": {"stops": [{"offset": 0.5, "color": "#ffffff"}, {"offset": 0.2, "color": "#000000"}]}}}`: "gradient stop 1: offset 0.2",
+		`{"background": {"vignette": 1.5}}`:                     "background: vignette 1.5 out of range [0, 1]",
+		`{"background": {"desaturate": -10}}`:                   "background: desaturate -10 out of range [0, 100]",
+		`{"title": {"stroke": {"width": 0}}}`:                   "title: stroke width 0 must be positive",
+		`{"title": {"stroke": {"width": 4, "color": "black"}}}`: `invalid color "black"`,
+		`{"font_scale": 5}`:                                     "font_scale 5 out of range",
+		`{"panel": {"color": "#000"}}`:                          `panel: invalid color "#000"`,
+		`{"subtitle": {"color": "white"}}`:                      `subtitle: invalid color "white"`,
+	} {
+		if _, err := ParseTheme([]byte(json)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("ParseTheme(%s): got %v, want error containing %q", json, err, want)
+		}
+	}
+}
+
+// TestPresetThemes_OpaquePanelAndAAAContrast expects the high-contrast presets to keep a contrast ratio of at least
+// 7:1 between panel and text, to draw an opaque panel even for the minimal layout, and to enlarge the text with
+// --font-scale on top. The test fails if a color falls short of WCAG AAA, the photo shows through the panel, or the
+// fonts are not scaled.
+func TestPresetThemes_OpaquePanelAndAAAContrast(t *testing.T) {
+	if got := ContrastRatio(color.NRGBA{A: 255}, color.NRGBA{R: 255, G: 255, B: 255, A: 255}); math.Abs(got-21) > 1e-9 {
+		t.Fatalf("ContrastRatio(black, white) = %v, want 21", got)
+	}
+	bg := image.NewRGBA(image.Rect(0, 0, 64, 36))
+	draw.Draw(bg, bg.Bounds(), image.NewUniform(color.RGBA{R: 128, G: 96, B: 64, A: 255}), image.Point{}, draw.Src)
+	_, plain, err := RenderWithLayout(bg, "target", "build", Options{Width: 1280, Height: 720})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range ThemePresets() {
+		theme, ok := PresetTheme(name)
+		if !ok {
+			t.Fatalf("PresetTheme(%q) not found", name)
+		}
+		if err := theme.validate(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		panel, _ := parseHexColor(theme.Panel.Color, color.NRGBA{})
+		if panel.A != 255 {
+			t.Fatalf("%s: panel %s is not opaque", name, theme.Panel.Color)
+		}
+		for _, text := range []string{theme.Title.Color, theme.Subtitle.Color} {
+			col, _ := parseHexColor(text, color.NRGBA{})
+			if ratio := ContrastRatio(panel, col); ratio < 7 {
+				t.Fatalf("%s: contrast of %s on %s is %.2f:1, want at least 7:1", name, text, theme.Panel.Color, ratio)
+			}
+		}
+
+		for _, layoutName := range []string{LayoutCentered, LayoutMinimal} {
+			engine, _ := ParseLayout(layoutName)
+			img, layout, err := RenderWithLayout(bg, "target", "build", Options{Width: 1280, Height: 720, Layout: engine, Theme: theme, FontScale: 1.2})
+			if err != nil {
+				t.Fatalf("%s %s: %v", name, layoutName, err)
+			}
+			// Just inside the panel's corner, clear of the rounded edge and of the text.
+			x, y := layout.BoxX0+layout.BoxRadius, layout.BoxY0+2
+			if layout.BoxOpacity == 0 {
+				x, y = layout.BoxX0-layout.Padding/4, layout.BoxY0-layout.Padding/4
+			}
+			if got := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA); got != panel {
+				t.Fatalf("%s %s: pixel (%d, %d) = %v, want panel %v", name, layoutName, x, y, got, panel)
+			}
+			if layoutName == LayoutCentered && layout.TitleFontSize < plain.TitleFontSize*1.4 {
+				t.Fatalf("%s: title font size %v, want at least 1.4 times %v", name, layout.TitleFontSize, plain.TitleFontSize)
+			}
+		}
+	}
+	if _, ok := PresetTheme("hc"); ok {
+		t.Fatal("expected an unknown preset to be missing")
+	}
+}
@@ -0,0 +1,90 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// BackgroundTreatment adjusts the background photo before the overlay is composited, so busy or bright photos
+// still carry readable text. The zero value leaves the background unchanged.
+type BackgroundTreatment struct {
+	// Vignette darkens towards the corners; 1 turns the corners black.
+	Vignette float64 `json:"vignette,omitempty"`
+	// DarkenBottom darkens linearly from nothing at the top edge to this fraction at the bottom edge.
+	DarkenBottom float64 `json:"darken_bottom,omitempty"`
+	// Desaturate blends towards grayscale by this percentage; 100 removes all color.
+	Desaturate float64 `json:"desaturate,omitempty"`
+	// Brightness shifts all channels by this fraction of full scale, in [-1, 1].
+	Brightness float64 `json:"brightness,omitempty"`
+	// Contrast scales channels around mid-gray by 1+Contrast, in [-1, 1]; -1 flattens to gray.
+	Contrast float64 `json:"contrast,omitempty"`
+	// Sharpen is the amount of an unsharp mask applied to downscaled backgrounds, in [0, MaxSharpen]; it takes full
+	// effect from half size down and none on backgrounds that are not downscaled.
+	Sharpen float64 `json:"sharpen,omitempty"`
+}
+
+// validate checks the strength ranges.
+func (t BackgroundTreatment) validate() error {
+	for _, r := range []struct {
+		name     string
+		v        float64
+		min, max float64
+	}{
+		{"vignette", t.Vignette, 0, 1},
+		{"darken_bottom", t.DarkenBottom, 0, 1},
+		{"desaturate", t.Desaturate, 0, 100},
+		{"brightness", t.Brightness, -1, 1},
+		{"contrast", t.Contrast, -1, 1},
+		{"sharpen", t.Sharpen, 0, MaxSharpen},
+	} {
+		if r.v < r.min || r.v > r.max {
+			return fmt.Errorf("theme: background: %s %v out of range [%v, %v]", r.name, r.v, r.min, r.max)
+		}
+	}
+	return nil
+}
+
+// apply adjusts img in place: desaturation, brightness, and contrast per pixel first, then the positional
+// darken-from-bottom and vignette. Sharpening is left to resizeAndCrop, which knows the scale.
+func (t BackgroundTreatment) apply(img *image.RGBA) {
+	if t == (BackgroundTreatment{Sharpen: t.Sharpen}) {
+		return
+	}
+	b := img.Bounds()
+	w, h := float64(b.Dx()), float64(b.Dy())
+	cx, cy := float64(b.Min.X)+w/2, float64(b.Min.Y)+h/2
+	desaturate := t.Desaturate / 100
+	brightness := t.Brightness * 255
+	contrast := 1 + t.Contrast
+
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		// Darkening grows linearly from the top edge to the bottom edge.
+		rowFactor := 1 - t.DarkenBottom*(float64(y-b.Min.Y)+0.5)/h
+		for x := b.Min.X; x < b.Max.X; x++ {
+			i := img.PixOffset(x, y)
+			px := img.Pix[i : i+4 : i+4]
+			alpha := float64(px[3])
+			r, g, bl := float64(px[0]), float64(px[1]), float64(px[2])
+
+			if desaturate > 0 {
+				luma := 0.2126*r + 0.7152*g + 0.0722*bl
+				r, g, bl = r+(luma-r)*desaturate, g+(luma-g)*desaturate, bl+(luma-bl)*desaturate
+			}
+
+			factor := rowFactor
+			if t.Vignette > 0 {
+				// Normalized distance from the center: 0 in the middle, 1 in the corners.
+				dx, dy := (float64(x)+0.5-cx)/(w/2), (float64(y)+0.5-cy)/(h/2)
+				factor *= 1 - t.Vignette*(dx*dx+dy*dy)/2
+			}
+
+			// Channels are premultiplied, so the mid-gray pivot and the brightness shift scale with alpha.
+			adjust := func(v float64) uint8 {
+				v = (v+brightness*alpha/255-128*alpha/255)*contrast + 128*alpha/255
+				return uint8(math.Max(0, math.Min(alpha, v*factor)) + 0.5)
+			}
+			px[0], px[1], px[2] = adjust(r), adjust(g), adjust(bl)
+		}
+	}
+}
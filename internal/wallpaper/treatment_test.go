@@ -0,0 +1,59 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// TestBackgroundTreatment_Apply expects each treatment to move pixels in its documented direction and the zero
+// treatment to leave the image untouched.
+// The test fails if a treatment is skipped, inverted, or applied to the wrong region.
+func TestBackgroundTreatment_Apply(t *testing.T) {
+	fill := func(c color.RGBA) *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+		for y := 0; y < 20; y++ {
+			for x := 0; x < 40; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+		return img
+	}
+	orange := color.RGBA{R: 220, G: 140, B: 40, A: 255}
+
+	img := fill(orange)
+	BackgroundTreatment{}.apply(img)
+	if got := img.RGBAAt(5, 5); got != orange {
+		t.Fatalf("zero treatment changed pixel to %v", got)
+	}
+
+	img = fill(orange)
+	BackgroundTreatment{Desaturate: 100}.apply(img)
+	if got := img.RGBAAt(5, 5); got.R != got.G || got.G != got.B {
+		t.Fatalf("full desaturation left color %v", got)
+	}
+
+	img = fill(orange)
+	BackgroundTreatment{Brightness: -0.2}.apply(img)
+	if got := img.RGBAAt(5, 5); got.R >= orange.R || got.B >= orange.B {
+		t.Fatalf("negative brightness did not darken: %v", got)
+	}
+
+	img = fill(orange)
+	BackgroundTreatment{Contrast: 0.5}.apply(img)
+	if got := img.RGBAAt(5, 5); got.R <= orange.R || got.B >= orange.B {
+		t.Fatalf("contrast did not spread channels away from mid-gray: %v", got)
+	}
+
+	img = fill(orange)
+	BackgroundTreatment{DarkenBottom: 0.8}.apply(img)
+	if top, bottom := img.RGBAAt(5, 0), img.RGBAAt(5, 19); top.R <= bottom.R || bottom.R > orange.R/4 {
+		t.Fatalf("darken_bottom: top %v, bottom %v", top, bottom)
+	}
+
+	img = fill(orange)
+	BackgroundTreatment{Vignette: 1}.apply(img)
+	if center, corner := img.RGBAAt(20, 10), img.RGBAAt(0, 0); center.R < orange.R-2 || corner.R > orange.R/8 {
+		t.Fatalf("vignette: center %v, corner %v", center, corner)
+	}
+}
@@ -0,0 +1,29 @@
+package wallpaper
+
+import "math"
+
+// TimeOfDay is a wallpaper variant for a part of the day, used for the GNOME background slideshow.
+type TimeOfDay struct {
+	// Name identifies the variant in file names.
+	Name string
+	// Hour is the local hour at which the variant becomes current.
+	Hour int
+	// Brightness is added to the theme's background brightness; the overlay is left unchanged.
+	Brightness float64
+}
+
+// TimesOfDay returns the built-in variants in chronological order; "day" matches the regular wallpaper.
+func TimesOfDay() []TimeOfDay {
+	return []TimeOfDay{
+		{Name: "morning", Hour: 6, Brightness: -0.06},
+		{Name: "day", Hour: 9, Brightness: 0},
+		{Name: "evening", Hour: 18, Brightness: -0.12},
+		{Name: "night", Hour: 21, Brightness: -0.28},
+	}
+}
+
+// Apply returns opts with the variant's brightness shift added to the background treatment, clamped to [-1, 1].
+func (t TimeOfDay) Apply(opts Options) Options {
+	opts.Theme.Background.Brightness = math.Max(-1, math.Min(1, opts.Theme.Background.Brightness+t.Brightness))
+	return opts
+}
@@ -0,0 +1,46 @@
+package wallpaper
+
+import "testing"
+
+// TestTimeOfDay_DarkensBackgroundOnly expects the variants in chronological order, the "day" variant to match the
+// regular render, and the night variant to darken the background while keeping the overlay text.
+// The test fails if the order is broken, "day" differs, or the night variant does not darken the corner.
+func TestTimeOfDay_DarkensBackgroundOnly(t *testing.T) {
+	variants := TimesOfDay()
+	for i := 1; i < len(variants); i++ {
+		if variants[i].Hour <= variants[i-1].Hour {
+			t.Fatalf("variants not in chronological order: %+v", variants)
+		}
+	}
+
+	bg := goldenBackground(80, 45, goldenSeed)
+	opts := Options{Width: 320, Height: 180}
+	base, err := Render(bg, "golden", "build 42", opts)
+	if err != nil {
+		t.Fatalf("Render error: %v", err)
+	}
+	renders := map[string][]uint8{}
+	for _, v := range variants {
+		img, err := Render(bg, "golden", "build 42", v.Apply(opts))
+		if err != nil {
+			t.Fatalf("%s: Render error: %v", v.Name, err)
+		}
+		renders[v.Name] = img.Pix
+	}
+	for i := range base.Pix {
+		if renders["day"][i] != base.Pix[i] {
+			t.Fatalf("day variant differs from the regular render at byte %d", i)
+		}
+	}
+
+	// The top-left corner shows only the background; the overlay box sits in the center.
+	corner := base.PixOffset(2, 2)
+	if renders["night"][corner] >= base.Pix[corner] {
+		t.Fatalf("night corner %d not darker than day %d", renders["night"][corner], base.Pix[corner])
+	}
+
+	opts.Theme.Background.Brightness = -0.9
+	if got := (TimeOfDay{Brightness: -0.5}).Apply(opts).Theme.Background.Brightness; got != -1 {
+		t.Fatalf("brightness not clamped, got %v", got)
+	}
+}
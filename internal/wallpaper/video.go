@@ -0,0 +1,62 @@
+//go:build ffmpeg
+
+package wallpaper
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SourceVideo names backgrounds grabbed from a video by VideoSource.
+const SourceVideo = "video"
+
+// VideoSource grabs a single frame of a local video, e.g. a motion loop supplied instead of a still, by running an
+// external ffmpeg. It is only built with the "ffmpeg" build tag, so default builds need no ffmpeg on the PATH.
+type VideoSource struct {
+	Path string
+	// At is the timestamp of the frame from the start of the video.
+	At time.Duration
+	// FFmpeg is the ffmpeg executable; empty selects "ffmpeg" from the PATH.
+	FFmpeg string
+	Limits Limits
+}
+
+// Fetch implements BackgroundSource; the size is ignored because the frame is scaled like any other background.
+// It returns an error if ffmpeg fails or the video has no frame at At, e.g. because it is shorter.
+func (s VideoSource) Fetch(width, height int) (Background, error) {
+	if s.At < 0 {
+		return Background{}, fmt.Errorf("grab video frame: negative timestamp %s", s.At)
+	}
+	ffmpeg := s.FFmpeg
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+	// Seeking before -i is fast and frame-accurate for re-encoded output; PNG keeps the frame lossless.
+	cmd := exec.Command(ffmpeg, "-hide_banner", "-loglevel", "error", "-nostdin",
+		"-ss", strconv.FormatFloat(s.At.Seconds(), 'f', -1, 64), "-i", s.Path,
+		"-frames:v", "1", "-f", "image2pipe", "-c:v", "png", "-")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return Background{}, fmt.Errorf("grab video frame: %s: %w: %s", s.Path, err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return Background{}, fmt.Errorf("grab video frame: %s has no frame at %s", s.Path, s.At)
+	}
+	img, err := decodeSRGB(stdout.Bytes(), s.Limits)
+	if err != nil {
+		return Background{}, fmt.Errorf("grab video frame: %w", err)
+	}
+	abs, err := filepath.Abs(s.Path)
+	if err != nil {
+		abs = s.Path
+	}
+	return Background{Image: img, URL: (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String(), Source: SourceVideo}, nil
+}
@@ -0,0 +1,52 @@
+//go:build ffmpeg
+
+package wallpaper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestVideoSource_GrabsFrameWithFFmpeg expects VideoSource to ask ffmpeg for one PNG frame at the timestamp and to
+// decode it, using a stand-in script for ffmpeg. The test fails if the timestamp or input is not passed, or if
+// ffmpeg errors and missing frames are not reported.
+func TestVideoSource_GrabsFrameWithFFmpeg(t *testing.T) {
+	dir := t.TempDir()
+	frame := filepath.Join(dir, "frame.png")
+	if err := os.WriteFile(frame, mustPNGBytes(t), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	args := filepath.Join(dir, "args")
+	script := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("#!/bin/sh\necho \"$@\" > "+args+"\n"+body+"\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	src := VideoSource{Path: "loop.mp4", At: 1500 * time.Millisecond, FFmpeg: script("ffmpeg", "cat "+frame)}
+	bg, err := src.Fetch(0, 0)
+	if err != nil || bg.Image == nil || bg.Source != SourceVideo || !strings.HasSuffix(bg.URL, "/loop.mp4") {
+		t.Fatalf("got %+v, %v", bg, err)
+	}
+	got, err := os.ReadFile(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "-ss 1.5 -i loop.mp4 -frames:v 1") {
+		t.Fatalf("ffmpeg arguments: %s", got)
+	}
+
+	src.FFmpeg = script("ffmpeg-short", "exit 0")
+	if _, err := src.Fetch(0, 0); err == nil || !strings.Contains(err.Error(), "no frame at 1.5s") {
+		t.Fatalf("short video: got %v", err)
+	}
+	src.FFmpeg = script("ffmpeg-broken", "echo 'loop.mp4: Invalid data found' >&2; exit 1")
+	if _, err := src.Fetch(0, 0); err == nil || !strings.Contains(err.Error(), "Invalid data found") {
+		t.Fatalf("broken video: got %v, want ffmpeg's message", err)
+	}
+}
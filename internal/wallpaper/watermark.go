@@ -0,0 +1,80 @@
+package wallpaper
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/f64"
+)
+
+// Watermark configures a faint text repeated diagonally across the whole wallpaper.
+// An empty Text disables the watermark.
+type Watermark struct {
+	Text string
+	// Opacity is the text alpha in the range (0, 1].
+	Opacity float64
+	// Angle is the counter-clockwise rotation in degrees.
+	Angle float64
+}
+
+const (
+	DefaultWatermarkOpacity = 0.08
+	DefaultWatermarkAngle   = 30.0
+
+	watermarkFontScale = 0.04 // relative to image height
+)
+
+// drawWatermark tiles the watermark text in staggered rows on a square layer and rotates it onto the canvas.
+// The layer covers the canvas diagonal so no corner is left unmarked at any angle.
+func drawWatermark(dst *image.RGBA, wm Watermark, fallbacks ...[]byte) error {
+	if wm.Text == "" {
+		return nil
+	}
+	if wm.Opacity <= 0 || wm.Opacity > 1 {
+		return fmt.Errorf("render: watermark opacity %v out of range (0, 1]", wm.Opacity)
+	}
+
+	b := dst.Bounds()
+	fontSize := float64(b.Dy()) * watermarkFontScale
+	face, err := loadFaceChain(fontSize, font.HintingNone, boldFontData, fallbacks...)
+	if err != nil {
+		return fmt.Errorf("render: load watermark font: %w", err)
+	}
+	text := prepareText(wm.Text)
+
+	textWidth := font.MeasureString(face, text).Ceil()
+	stepX := textWidth + int(2*fontSize)
+	stepY := int(4 * fontSize)
+	if stepX <= 0 || stepY <= 0 {
+		return fmt.Errorf("render: invalid watermark spacing")
+	}
+
+	side := int(math.Ceil(math.Hypot(float64(b.Dx()), float64(b.Dy()))))
+	layer := image.NewRGBA(image.Rect(0, 0, side, side))
+	col := color.NRGBA{R: 255, G: 255, B: 255, A: uint8(math.Round(wm.Opacity * 255))}
+	for row, y := 0, stepY; y < side+stepY; row, y = row+1, y+stepY {
+		offset := (row % 2) * stepX / 2
+		for x := -offset; x < side; x += stepX {
+			if err := drawText(layer, face, text, x, y, col); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Image y grows downwards, so a counter-clockwise angle is a negative rotation.
+	theta := -wm.Angle * math.Pi / 180
+	cos, sin := math.Cos(theta), math.Sin(theta)
+	half := float64(side) / 2
+	cx := float64(b.Min.X) + float64(b.Dx())/2
+	cy := float64(b.Min.Y) + float64(b.Dy())/2
+	m := f64.Aff3{
+		cos, -sin, cx - (cos*half - sin*half),
+		sin, cos, cy - (sin*half + cos*half),
+	}
+	draw.ApproxBiLinear.Transform(dst, m, layer, layer.Bounds(), draw.Over, nil)
+	return nil
+}
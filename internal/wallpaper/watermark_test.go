@@ -0,0 +1,63 @@
+package wallpaper
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// TestDrawWatermark_CoversCorners expects faint watermark pixels in every quadrant of the image.
+// The test fails if the rotated tile layer leaves a quadrant unmarked or draws at full opacity.
+func TestDrawWatermark_CoversCorners(t *testing.T) {
+	w, h := 640, 360
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	wm := Watermark{Text: "INTERNAL", Opacity: 0.5, Angle: DefaultWatermarkAngle}
+	if err := drawWatermark(dst, wm); err != nil {
+		t.Fatalf("drawWatermark error: %v", err)
+	}
+
+	quadrants := []image.Rectangle{
+		image.Rect(0, 0, w/2, h/2),
+		image.Rect(w/2, 0, w, h/2),
+		image.Rect(0, h/2, w/2, h),
+		image.Rect(w/2, h/2, w, h),
+	}
+	for _, q := range quadrants {
+		marked := false
+		for y := q.Min.Y; y < q.Max.Y && !marked; y++ {
+			for x := q.Min.X; x < q.Max.X; x++ {
+				a := dst.RGBAAt(x, y).A
+				if a > 200 {
+					t.Fatalf("watermark pixel at (%d,%d) exceeds configured opacity: alpha %d", x, y, a)
+				}
+				if a > 0 {
+					marked = true
+					break
+				}
+			}
+		}
+		if !marked {
+			t.Fatalf("expected watermark pixels in quadrant %v", q)
+		}
+	}
+}
+
+// TestDrawWatermark_DisabledAndInvalidOpacity expects no drawing for empty text and an error for out-of-range opacity.
+func TestDrawWatermark_DisabledAndInvalidOpacity(t *testing.T) {
+	dst := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	if err := drawWatermark(dst, Watermark{Opacity: 2}); err != nil {
+		t.Fatalf("expected no error for disabled watermark, got %v", err)
+	}
+	if dst.RGBAAt(32, 32) != (color.RGBA{}) {
+		t.Fatalf("expected untouched image for disabled watermark")
+	}
+
+	err := drawWatermark(dst, Watermark{Text: "x", Opacity: 1.5})
+	if err == nil {
+		t.Fatalf("expected error for opacity > 1")
+	}
+	if !strings.Contains(err.Error(), "opacity") {
+		t.Fatalf("unexpected error: %q", err.Error())
+	}
+}
@@ -0,0 +1,27 @@
+;; Source of effects.wasm: filter inverts the color of every pixel, generate fills the image with opaque #804020.
+(module
+  (memory (export "memory") 1)
+  (func (export "alloc") (param $size i32) (result i32)
+    (i32.shl
+      (memory.grow (i32.shr_u (i32.add (local.get $size) (i32.const 65535)) (i32.const 16)))
+      (i32.const 16)))
+  (func (export "filter") (param $ptr i32) (param $width i32) (param $height i32) (param $hook i32) (result i32)
+    (local $end i32)
+    (local.set $end (i32.add (local.get $ptr) (i32.shl (i32.mul (local.get $width) (local.get $height)) (i32.const 2))))
+    (block $done
+      (loop $next
+        (br_if $done (i32.ge_u (local.get $ptr) (local.get $end)))
+        (i32.store (local.get $ptr) (i32.xor (i32.load (local.get $ptr)) (i32.const 0x00ffffff)))
+        (local.set $ptr (i32.add (local.get $ptr) (i32.const 4)))
+        (br $next)))
+    (i32.const 0))
+  (func (export "generate") (param $ptr i32) (param $width i32) (param $height i32) (result i32)
+    (local $end i32)
+    (local.set $end (i32.add (local.get $ptr) (i32.shl (i32.mul (local.get $width) (local.get $height)) (i32.const 2))))
+    (block $done
+      (loop $next
+        (br_if $done (i32.ge_u (local.get $ptr) (local.get $end)))
+        (i32.store (local.get $ptr) (i32.const 0xff204080))
+        (local.set $ptr (i32.add (local.get $ptr) (i32.const 4)))
+        (br $next)))
+    (i32.const 0)))
@@ -0,0 +1,8 @@
+;; Source of imports.wasm: a module that asks for a WASI function, which the sandbox does not provide.
+(module
+  (import "wasi_snapshot_preview1" "proc_exit" (func (param i32) (result i32)))
+  (memory (export "memory") 1)
+  (func (export "alloc") (param $size i32) (result i32)
+    (i32.shl
+      (memory.grow (i32.shr_u (i32.add (local.get $size) (i32.const 65535)) (i32.const 16)))
+      (i32.const 16))))
@@ -0,0 +1,12 @@
+;; Source of spin.wasm: filter never returns and generate fails with error code 5.
+(module
+  (memory (export "memory") 1)
+  (func (export "alloc") (param $size i32) (result i32)
+    (i32.shl
+      (memory.grow (i32.shr_u (i32.add (local.get $size) (i32.const 65535)) (i32.const 16)))
+      (i32.const 16)))
+  (func (export "filter") (param i32 i32 i32 i32) (result i32)
+    (loop $forever (br $forever))
+    (i32.const 0))
+  (func (export "generate") (param i32 i32 i32) (result i32)
+    (i32.const 5)))
@@ -0,0 +1,227 @@
+// Package wasm runs WebAssembly modules as background sources and render filters, so teams can distribute custom
+// generators and effects without recompiling ts-release. Modules run in a sandbox: they get no imports, so no file
+// system, network, clock, or environment, their memory is capped at MemoryLimitPages, and every call is stopped after
+// the module's timeout.
+//
+// A module exports its linear memory as "memory" and a function "alloc(size i32) i32" that returns the address of
+// size free bytes. A render filter exports "filter(ptr, width, height, hook i32) i32", which transforms the
+// width x height pixels at ptr in place, with hook 0 for pre-composite and 1 for post-composite. A background source
+// exports "generate(ptr, width, height i32) i32", which fills them. Pixels are stored row by row without padding as
+// R, G, B, and A bytes with alpha-premultiplied color, like image.RGBA. Both return 0 on success and any other value
+// as an error code.
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/wallpaper"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// MemoryLimitPages caps the linear memory of a module at 4096 pages of 64 KiB, 256 MiB, enough for the pixels of a
+// 7680x4320 image and the module's own data.
+const MemoryLimitPages = 4096
+
+// DefaultTimeout is the time a call may run when Module.Timeout is zero.
+const DefaultTimeout = 30 * time.Second
+
+// Names of the exports of a module.
+const (
+	exportMemory   = "memory"
+	exportAlloc    = "alloc"
+	exportFilter   = "filter"
+	exportGenerate = "generate"
+)
+
+// signatures are the i32 parameter counts of the exported functions; each returns one i32.
+var signatures = map[string]int{exportAlloc: 1, exportFilter: 4, exportGenerate: 3}
+
+// hooks are the values passed as the hook parameter of filter.
+var hooks = map[string]uint64{wallpaper.HookPreComposite: 0, wallpaper.HookPostComposite: 1}
+
+// cache holds the compiled code of every module of the process, so the runtime of each call does not compile the
+// module again.
+var cache = wazero.NewCompilationCache()
+
+// Module is a validated WebAssembly module. Every call runs in a new instance, so calls are independent and may run
+// concurrently.
+type Module struct {
+	// Path is the file the module was loaded from.
+	Path string
+	// Timeout stops a call that runs longer; zero selects DefaultTimeout.
+	Timeout time.Duration
+
+	code    []byte
+	exports map[string]bool
+}
+
+// Load reads and compiles the module at path. It returns an error if the file is not a WebAssembly module, imports
+// anything, or lacks the memory or alloc export.
+func Load(path string) (*Module, error) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("wasm: %w", err)
+	}
+	ctx := context.Background()
+	rt := newRuntime(ctx)
+	defer rt.Close(ctx)
+	compiled, err := rt.CompileModule(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("wasm: %s: %w", path, err)
+	}
+	if imports := compiled.ImportedFunctions(); len(imports) > 0 {
+		module, name, _ := imports[0].Import()
+		return nil, fmt.Errorf("wasm: %s imports %s.%s; modules run without host functions", path, module, name)
+	}
+	if len(compiled.ImportedMemories()) > 0 {
+		return nil, fmt.Errorf("wasm: %s imports its memory; it must define and export it as %q", path, exportMemory)
+	}
+	if _, ok := compiled.ExportedMemories()[exportMemory]; !ok {
+		return nil, fmt.Errorf("wasm: %s does not export its memory as %q", path, exportMemory)
+	}
+	m := &Module{Path: path, code: code, exports: map[string]bool{}}
+	for name, def := range compiled.ExportedFunctions() {
+		if params, ok := signatures[name]; ok {
+			m.exports[name] = slices.Equal(def.ParamTypes(), slices.Repeat([]api.ValueType{api.ValueTypeI32}, params)) &&
+				slices.Equal(def.ResultTypes(), []api.ValueType{api.ValueTypeI32})
+		}
+	}
+	if !m.exports[exportAlloc] {
+		return nil, fmt.Errorf("wasm: %s does not export %s(size i32) i32", path, exportAlloc)
+	}
+	return m, nil
+}
+
+// newRuntime returns a runtime with the sandbox limits that closes its modules when ctx is done.
+func newRuntime(ctx context.Context) wazero.Runtime {
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(MemoryLimitPages).
+		WithCloseOnContextDone(true).
+		WithCompilationCache(cache)
+	return wazero.NewRuntimeWithConfig(ctx, config)
+}
+
+// Filter returns a render filter for hook that runs the module's filter export. It returns an error if the module has
+// no such export or the hook is unknown.
+func (m *Module) Filter(hook string) (wallpaper.Filter, error) {
+	hookValue, ok := hooks[hook]
+	if !ok {
+		return nil, fmt.Errorf("wasm: unknown hook %q", hook)
+	}
+	if !m.exports[exportFilter] {
+		return nil, fmt.Errorf("wasm: %s does not export %s(ptr, width, height, hook i32) i32", m.Path, exportFilter)
+	}
+	return func(img *image.RGBA) error {
+		b := img.Bounds()
+		pix := make([]byte, 0, 4*b.Dx()*b.Dy())
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			i := img.PixOffset(b.Min.X, y)
+			pix = append(pix, img.Pix[i:i+4*b.Dx()]...)
+		}
+		if err := m.call(exportFilter, pix, uint64(b.Dx()), uint64(b.Dy()), hookValue); err != nil {
+			return err
+		}
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			i := img.PixOffset(b.Min.X, y)
+			copy(img.Pix[i:i+4*b.Dx()], pix[4*b.Dx()*(y-b.Min.Y):])
+		}
+		return nil
+	}, nil
+}
+
+// Source returns a background source that runs the module's generate export at the requested size. It returns an
+// error if the module has no such export.
+func (m *Module) Source() (wallpaper.BackgroundSource, error) {
+	if !m.exports[exportGenerate] {
+		return nil, fmt.Errorf("wasm: %s does not export %s(ptr, width, height i32) i32", m.Path, exportGenerate)
+	}
+	return source{m}, nil
+}
+
+// source is the BackgroundSource of Module.Source.
+type source struct {
+	m *Module
+}
+
+// Fetch implements wallpaper.BackgroundSource.
+func (s source) Fetch(width, height int) (wallpaper.Background, error) {
+	if width <= 0 || height <= 0 {
+		return wallpaper.Background{}, fmt.Errorf("fetch background: invalid target size %dx%d", width, height)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if err := s.m.call(exportGenerate, img.Pix, uint64(width), uint64(height)); err != nil {
+		return wallpaper.Background{}, fmt.Errorf("fetch background: %w", err)
+	}
+	abs, err := filepath.Abs(s.m.Path)
+	if err != nil {
+		abs = s.m.Path
+	}
+	return wallpaper.Background{
+		Image:  img,
+		URL:    (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String(),
+		Source: wallpaper.SourceWASM,
+	}, nil
+}
+
+// call runs the export fn in a new instance of the module with pix copied to memory from alloc, followed by args,
+// and copies the pixels back. It returns an error if the call fails, times out, or returns non-zero.
+func (m *Module) call(fn string, pix []byte, args ...uint64) error {
+	if len(pix) > MemoryLimitPages*65536 {
+		return fmt.Errorf("wasm: %s: %d bytes of pixels exceed the memory limit of %d pages", m.Path, len(pix), MemoryLimitPages)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout())
+	defer cancel()
+	rt := newRuntime(ctx)
+	defer rt.Close(context.Background())
+	mod, err := rt.InstantiateWithConfig(ctx, m.code, wazero.NewModuleConfig().WithStartFunctions())
+	if err != nil {
+		return m.callError(ctx, "instantiate", err)
+	}
+	res, err := mod.ExportedFunction(exportAlloc).Call(ctx, uint64(len(pix)))
+	if err != nil {
+		return m.callError(ctx, exportAlloc, err)
+	}
+	ptr := uint32(res[0])
+	mem := mod.ExportedMemory(exportMemory)
+	if !mem.Write(ptr, pix) {
+		return fmt.Errorf("wasm: %s: %s(%d) returned %#x, outside its memory", m.Path, exportAlloc, len(pix), ptr)
+	}
+	res, err = mod.ExportedFunction(fn).Call(ctx, append([]uint64{uint64(ptr)}, args...)...)
+	if err != nil {
+		return m.callError(ctx, fn, err)
+	}
+	if code := int32(res[0]); code != 0 {
+		return fmt.Errorf("wasm: %s: %s returned error code %d", m.Path, fn, code)
+	}
+	out, ok := mem.Read(ptr, uint32(len(pix)))
+	if !ok {
+		return fmt.Errorf("wasm: %s: %s left its pixels outside its memory", m.Path, fn)
+	}
+	copy(pix, out)
+	return nil
+}
+
+// timeout returns Timeout, or DefaultTimeout if it is zero.
+func (m *Module) timeout() time.Duration {
+	if m.Timeout == 0 {
+		return DefaultTimeout
+	}
+	return m.Timeout
+}
+
+// callError wraps the error of the step of a call, naming the timeout if the call was stopped by it.
+func (m *Module) callError(ctx context.Context, step string, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("wasm: %s: %s: stopped after the timeout of %s", m.Path, step, m.timeout())
+	}
+	return fmt.Errorf("wasm: %s: %s: %w", m.Path, step, err)
+}
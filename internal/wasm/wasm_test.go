@@ -0,0 +1,96 @@
+package wasm
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/wallpaper"
+)
+
+// TestModule_FilterAndSource runs the filter of testdata/effects.wasm on part of an image and generates a background
+// with it. The test fails if pixels outside the part change, the colors are not inverted, or the background is not the
+// module's solid color at the requested size.
+func TestModule_FilterAndSource(t *testing.T) {
+	m, err := Load("testdata/effects.wasm")
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	filter, err := m.Filter(wallpaper.HookPostComposite)
+	if err != nil {
+		t.Fatalf("Filter error: %v", err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 6, 4))
+	for i := range img.Pix {
+		img.Pix[i] = 200
+	}
+	if err := filter(img.SubImage(image.Rect(1, 1, 4, 3)).(*image.RGBA)); err != nil {
+		t.Fatalf("filter error: %v", err)
+	}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 6; x++ {
+			want := color.RGBA{200, 200, 200, 200}
+			if image.Pt(x, y).In(image.Rect(1, 1, 4, 3)) {
+				want = color.RGBA{55, 55, 55, 200}
+			}
+			if got := img.RGBAAt(x, y); got != want {
+				t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+
+	src, err := m.Source()
+	if err != nil {
+		t.Fatalf("Source error: %v", err)
+	}
+	bg, err := src.Fetch(320, 180)
+	if err != nil {
+		t.Fatalf("Fetch error: %v", err)
+	}
+	if bg.Source != wallpaper.SourceWASM || !strings.HasSuffix(bg.URL, "/testdata/effects.wasm") {
+		t.Fatalf("background source %q, URL %q", bg.Source, bg.URL)
+	}
+	if size := bg.Image.Bounds().Size(); size != image.Pt(320, 180) {
+		t.Fatalf("background is %v, want 320x180", size)
+	}
+	if got := bg.Image.(*image.RGBA).RGBAAt(319, 179); got != (color.RGBA{0x80, 0x40, 0x20, 0xff}) {
+		t.Fatalf("background pixel %v, want #804020", got)
+	}
+}
+
+// TestModule_Sandbox expects modules with imports to be rejected, calls to stop at the timeout, and error codes and
+// missing exports to be reported. The test fails if a module reaches outside the sandbox, runs on, or fails silently.
+func TestModule_Sandbox(t *testing.T) {
+	if _, err := Load("testdata/imports.wasm"); err == nil || !strings.Contains(err.Error(), "imports wasi_snapshot_preview1.proc_exit") {
+		t.Fatalf("expected the import to be rejected, got %v", err)
+	}
+
+	m, err := Load("testdata/spin.wasm")
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	m.Timeout = 100 * time.Millisecond
+	filter, err := m.Filter(wallpaper.HookPreComposite)
+	if err != nil {
+		t.Fatalf("Filter error: %v", err)
+	}
+	start := time.Now()
+	if err := filter(image.NewRGBA(image.Rect(0, 0, 8, 8))); err == nil || !strings.Contains(err.Error(), "stopped after the timeout of 100ms") {
+		t.Fatalf("expected the endless filter to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("endless filter stopped after %s", elapsed)
+	}
+	src, err := m.Source()
+	if err != nil {
+		t.Fatalf("Source error: %v", err)
+	}
+	if _, err := src.Fetch(8, 8); err == nil || !strings.Contains(err.Error(), "generate returned error code 5") {
+		t.Fatalf("expected the error code, got %v", err)
+	}
+	if _, err := m.Filter("mid-composite"); err == nil {
+		t.Fatal("expected an unknown hook to be rejected")
+	}
+}
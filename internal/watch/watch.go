@@ -0,0 +1,135 @@
+// Package watch collects the triggers that make a long-running process regenerate: changes of a file, signals, and
+// D-Bus signals observed with dbus-monitor. Triggers that arrive while one is pending are merged into it, so a burst
+// of events causes a single regeneration.
+package watch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Triggers is a queue holding at most one pending trigger. The zero value is not usable; call NewTriggers.
+type Triggers struct {
+	ch chan string
+}
+
+// NewTriggers returns an empty trigger queue.
+func NewTriggers() *Triggers {
+	return &Triggers{ch: make(chan string, 1)}
+}
+
+// Fire queues a trigger with the reason for it, unless one is pending already. It never blocks.
+func (t *Triggers) Fire(reason string) {
+	select {
+	case t.ch <- reason:
+	default:
+	}
+}
+
+// C returns the channel the pending trigger's reason is received from.
+func (t *Triggers) C() <-chan string {
+	return t.ch
+}
+
+// File fires when the file at path changes, checking its modification time and size every interval until ctx is
+// done. Creating, removing, and replacing the file count as changes, so editors that save through a rename work.
+func File(ctx context.Context, path string, interval time.Duration, t *Triggers) {
+	last := stat(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if current := stat(path); current != last {
+				last = current
+				t.Fire(path + " changed")
+			}
+		}
+	}
+}
+
+// fileState is what File compares between checks; the zero value stands for a missing file.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// stat returns the state of path.
+func stat(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size()}
+}
+
+// DBus selects the D-Bus signal that fires a trigger.
+type DBus struct {
+	// Signal is the interface and member, e.g. "org.example.Nightly.Rebuild".
+	Signal string
+	// Session selects the session bus instead of the system bus.
+	Session bool
+	// Monitor is the dbus-monitor executable; empty selects "dbus-monitor" from the PATH.
+	Monitor string
+}
+
+// ParseSignal splits "interface.Member" into interface and member. It returns an error unless both are present.
+func ParseSignal(signal string) (iface, member string, err error) {
+	i := strings.LastIndexByte(signal, '.')
+	if i <= 0 || i == len(signal)-1 || !strings.Contains(signal[:i], ".") {
+		return "", "", fmt.Errorf("watch: D-Bus signal %q: want interface.Member, e.g. org.example.Nightly.Rebuild", signal)
+	}
+	return signal[:i], signal[i+1:], nil
+}
+
+// Watch runs dbus-monitor for the signal and fires for every emission until ctx is done, which returns nil. It
+// returns an error if dbus-monitor cannot be started or exits on its own, e.g. because the bus is unreachable.
+func (d DBus) Watch(ctx context.Context, t *Triggers) error {
+	iface, member, err := ParseSignal(d.Signal)
+	if err != nil {
+		return err
+	}
+	monitor := d.Monitor
+	if monitor == "" {
+		monitor = "dbus-monitor"
+	}
+	bus := "--system"
+	if d.Session {
+		bus = "--session"
+	}
+	cmd := exec.CommandContext(ctx, monitor, bus, fmt.Sprintf("type='signal',interface='%s',member='%s'", iface, member))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("watch: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("watch: %w", err)
+	}
+	// dbus-monitor also reports its own NameAcquired and NameLost signals, so lines are matched again here.
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "signal ") && strings.Contains(line, "interface="+iface+";") && strings.HasSuffix(line, "member="+member) {
+			t.Fire("D-Bus signal " + d.Signal)
+		}
+	}
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err == nil {
+		err = errors.New("exited")
+	}
+	return fmt.Errorf("watch: %s: %w: %s", monitor, err, strings.TrimSpace(stderr.String()))
+}
@@ -0,0 +1,115 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestFile_FiresOnChangesAndCoalesces expects a changed, removed, and recreated file to fire, and triggers that
+// arrive while one is pending to be merged. The test fails if a change is missed or a burst queues several triggers.
+func TestFile_FiresOnChangesAndCoalesces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "target.conf")
+	if err := os.WriteFile(path, []byte("layout = centered\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	triggers := NewTriggers()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go File(ctx, path, 10*time.Millisecond, triggers)
+
+	expect := func(what string) {
+		t.Helper()
+		select {
+		case reason := <-triggers.C():
+			if !strings.Contains(reason, "target.conf changed") {
+				t.Fatalf("%s: reason %q", what, reason)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: no trigger", what)
+		}
+	}
+	time.Sleep(30 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("layout = bottom-bar\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expect("write")
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	expect("remove")
+
+	triggers.Fire("SIGHUP")
+	triggers.Fire("SIGHUP again")
+	if reason := <-triggers.C(); reason != "SIGHUP" {
+		t.Fatalf("coalesced reason: got %q", reason)
+	}
+	select {
+	case reason := <-triggers.C():
+		t.Fatalf("burst queued a second trigger %q", reason)
+	default:
+	}
+}
+
+// TestDBus_FiresForMatchingSignals expects dbus-monitor, here a stand-in script, to be run with a match rule for the
+// signal and only the matching signal lines to fire. The test fails if the bus or rule is wrong, dbus-monitor's own
+// signals fire, or an exiting monitor is not reported.
+func TestDBus_FiresForMatchingSignals(t *testing.T) {
+	dir := t.TempDir()
+	args := filepath.Join(dir, "args")
+	script := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("#!/bin/sh\necho \"$@\" > "+args+"\n"+body+"\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	monitor := script("dbus-monitor", `echo "signal time=1.0 sender=org.freedesktop.DBus -> destination=:1.7 serial=2 path=/org/freedesktop/DBus; interface=org.freedesktop.DBus; member=NameAcquired"
+echo "signal time=2.0 sender=:1.5 -> destination=(null destination) serial=3 path=/nightly; interface=org.example.Nightly; member=Rebuild"
+echo "   string \"payload\""
+exec sleep 60`)
+
+	triggers := NewTriggers()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- DBus{Signal: "org.example.Nightly.Rebuild", Session: true, Monitor: monitor}.Watch(ctx, triggers)
+	}()
+	select {
+	case reason := <-triggers.C():
+		if reason != "D-Bus signal org.example.Nightly.Rebuild" {
+			t.Fatalf("reason %q", reason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no trigger for the signal")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Watch after cancel: %v", err)
+	}
+	got, err := os.ReadFile(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "--session type='signal',interface='org.example.Nightly',member='Rebuild'"; strings.TrimSpace(string(got)) != want {
+		t.Fatalf("dbus-monitor arguments: got %q, want %q", got, want)
+	}
+	select {
+	case reason := <-triggers.C():
+		t.Fatalf("NameAcquired or payload fired %q", reason)
+	default:
+	}
+
+	broken := script("dbus-broken", "echo 'Failed to open connection to system bus' >&2; exit 1")
+	if err := (DBus{Signal: "org.example.Nightly.Rebuild", Monitor: broken}).Watch(context.Background(), triggers); err == nil || !strings.Contains(err.Error(), "Failed to open connection") {
+		t.Fatalf("broken monitor: got %v", err)
+	}
+	for _, bad := range []string{"Rebuild", "org.Rebuild", "org.example."} {
+		if _, _, err := ParseSignal(bad); err == nil {
+			t.Fatalf("ParseSignal(%q): expected an error", bad)
+		}
+	}
+}
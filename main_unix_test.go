@@ -0,0 +1,51 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// TestMain_OwnershipDB_RecordsRootOwnership builds with --ownership-db in both formats. The test fails if the fakeroot
+// database misses the background, the pseudo file does not define root ownership, or an unknown format is accepted.
+func TestMain_OwnershipDB_RecordsRootOwnership(t *testing.T) {
+	bin := buildBinary(t)
+	rootFS := t.TempDir()
+	db := filepath.Join(t.TempDir(), "fakeroot.db")
+
+	code, _, stderr := runWithServer(t, bin, "--ownership-db", db, "target", rootFS)
+	if code != 0 {
+		t.Fatalf("expected success, got exit %d\nstderr: %s", code, stderr)
+	}
+	info, err := os.Stat(filepath.Join(rootFS, "usr", "share", "backgrounds", "tssh", "background.jpg"))
+	if err != nil {
+		t.Fatalf("stat background: %v", err)
+	}
+	data, err := os.ReadFile(db)
+	if err != nil {
+		t.Fatalf("read database: %v", err)
+	}
+	want := fmt.Sprintf(",ino=%d,mode=100644,uid=0,gid=0,", info.Sys().(*syscall.Stat_t).Ino)
+	if !strings.Contains(string(data), want) {
+		t.Fatalf("expected %q in fakeroot database:\n%s", want, data)
+	}
+
+	pseudo := filepath.Join(t.TempDir(), "pseudo")
+	code, _, stderr = runWithServer(t, bin, "--ownership-db", pseudo, "--ownership-format", "squashfs", "target", rootFS)
+	if code != 0 {
+		t.Fatalf("expected success, got exit %d\nstderr: %s", code, stderr)
+	}
+	if data, err := os.ReadFile(pseudo); err != nil || !strings.Contains(string(data), "usr/share/backgrounds/tssh/background.jpg m 644 0 0\n") {
+		t.Fatalf("unexpected pseudo file (%v):\n%s", err, data)
+	}
+
+	code, _, stderr = runCmd(t, bin, "--ownership-format", "pseudo", "target", t.TempDir())
+	if code == 0 || !strings.Contains(stderr, "unknown ownership format") {
+		t.Fatalf("expected an unknown format to be rejected, got exit %d\nstderr: %s", code, stderr)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/metrics"
+	"github.com/nickhildebrandt/ts-release/internal/wallpaper"
+)
+
+// metricsRegistry holds the metrics of this process, served on /metrics by serve and watch and written by
+// --metrics-file. Like wallhaven.DefaultLimiter it is shared by all builds of the process.
+var metricsRegistry = metrics.NewRegistry()
+
+var (
+	renderDuration = metricsRegistry.Histogram("ts_release_render_duration_seconds",
+		"Duration of builds and served renders, including the background fetch.",
+		[]float64{0.5, 1, 2.5, 5, 10, 20, 40, 80}, "operation", "result")
+	fetchFailures = metricsRegistry.Counter("ts_release_fetch_failures_total",
+		"Failed fetches from the selected background source by category, including failures a fallback covered.", "kind")
+	cacheRequests = metricsRegistry.Counter("ts_release_cache_requests_total",
+		"Downloads through --cache-dir by result: hit if the cached image was revalidated, miss if it was transferred.", "result")
+	downloadedBytes = metricsRegistry.Counter("ts_release_downloaded_bytes_total",
+		"Response body bytes read by background downloads and API calls.")
+)
+
+// Operations of ts_release_render_duration_seconds.
+const (
+	operationBuild  = "build"
+	operationRender = "render"
+)
+
+// observeDuration records an operation that started at start and ended with err.
+func observeDuration(operation string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	renderDuration.Observe(time.Since(start).Seconds(), operation, result)
+}
+
+// observeCache counts a download through the cache.
+func observeCache(hit bool) {
+	if hit {
+		cacheRequests.Inc("hit")
+	} else {
+		cacheRequests.Inc("miss")
+	}
+}
+
+// observedSource counts the failures of the source it wraps by category before a fallback may cover them.
+type observedSource struct {
+	wallpaper.BackgroundSource
+}
+
+// Fetch implements wallpaper.BackgroundSource.
+func (s observedSource) Fetch(width, height int) (wallpaper.Background, error) {
+	bg, err := s.BackgroundSource.Fetch(width, height)
+	if err != nil {
+		kind := "other"
+		var fetchErr *wallpaper.FetchError
+		if errors.As(wallpaper.ClassifyFetchError(err), &fetchErr) {
+			kind = string(fetchErr.Kind)
+		}
+		fetchFailures.Inc(kind)
+	}
+	return bg, err
+}
+
+// countingTransport counts the response body bytes read through it in ts_release_downloaded_bytes_total.
+type countingTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err == nil {
+		resp.Body = countingBody{resp.Body}
+	}
+	return resp, err
+}
+
+// countingBody counts the bytes read from a response body.
+type countingBody struct {
+	io.ReadCloser
+}
+
+func (b countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	downloadedBytes.Add(float64(n))
+	return n, err
+}
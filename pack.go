@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/artifact"
+	"github.com/nickhildebrandt/ts-release/internal/install"
+	"github.com/nickhildebrandt/ts-release/internal/offline"
+	"github.com/nickhildebrandt/ts-release/internal/trace"
+)
+
+// packOptions holds the parsed command line of the pack subcommand before the generation flags.
+type packOptions struct {
+	key string
+}
+
+// newPackFlagSet returns the flag set of the pack subcommand.
+func newPackFlagSet(opts *packOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release pack", flag.ContinueOnError)
+	fs.StringVar(&opts.key, "key", "", "PEM file with the Ed25519 private key that signs the bundle (required)")
+	return fs
+}
+
+// runPack builds like a regular run, but into a temporary directory, and packs the generated files into a signed
+// .tsrelease bundle that unpack applies to rootfs trees later. The pack flags come first, then the bundle path, and
+// then the generation flags and the target name.
+func runPack(args []string) error {
+	var packOpts packOptions
+	packFS := newPackFlagSet(&packOpts)
+	if err := parseSubcommand(packFS, args); err != nil {
+		return err
+	}
+	if packOpts.key == "" || packFS.NArg() < 2 {
+		return errUsage
+	}
+	output := packFS.Arg(0)
+	if !strings.HasSuffix(output, artifact.Extension) {
+		return fmt.Errorf("pack: bundle %s must end in %s", output, artifact.Extension)
+	}
+	key, err := offline.LoadPrivateKey(packOpts.key)
+	if err != nil {
+		return err
+	}
+	opts, positional, err := parseFlags(packFS.Args()[1:])
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || positional[0] == "" {
+		return errUsage
+	}
+	// Links, alternatives, ownership databases, and what is read from the rootfs depend on the rootfs the build goes
+	// into.
+	if len(opts.links) > 0 || len(opts.alternatives) > 0 || opts.ownershipDB != "" {
+		return fmt.Errorf("pack: --link, --alternative, and --ownership-db depend on the target rootfs; pass --ownership-db to unpack instead")
+	}
+	if opts.rootFSIdentity || opts.recordKernel {
+		return fmt.Errorf("pack: --rootfs-identity and --record-kernel read the target rootfs, which pack does not have")
+	}
+	if opts.targetName, err = checkText(opts, "target name", positional[0]); err != nil {
+		return err
+	}
+	staging, err := os.MkdirTemp("", "ts-release-pack-")
+	if err != nil {
+		return fmt.Errorf("pack: %w", err)
+	}
+	defer os.RemoveAll(staging)
+	opts.rootFS = staging
+
+	if err := run(trace.FromEnvironment(context.Background(), os.Getenv), opts); err != nil {
+		return err
+	}
+	files, err := stagedFiles(staging)
+	if err != nil {
+		return err
+	}
+	buildID, err := os.ReadFile(filepath.Join(staging, filepath.FromSlash(opts.installLayout().Build())))
+	if err != nil {
+		return fmt.Errorf("pack: %w", err)
+	}
+	manifest := artifact.Manifest{
+		Created:          opts.now(),
+		TargetName:       opts.targetName,
+		BuildID:          strings.TrimSuffix(string(buildID), "\n"),
+		GeneratorVersion: version,
+		Profile:          string(opts.installProfile),
+		PackagesSHA256:   releaseValue(staging, opts.installLayout(), "TSSH_PACKAGES_SHA256"),
+	}
+	var out bytes.Buffer
+	if err := artifact.Write(&out, key, manifest, files); err != nil {
+		return err
+	}
+	if err := os.WriteFile(output, out.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("pack: %w", err)
+	}
+	fmt.Fprintf(os.Stdout, "packed %d files of build %s to %s\n", len(files), manifest.BuildID, output)
+	return nil
+}
+
+// stagedFiles reads the regular files below dir in lexical order as bundle files with rootfs-relative paths.
+func stagedFiles(dir string) ([]artifact.File, error) {
+	var files []artifact.File
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("pack: %s is not a regular file", path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("pack: %w", err)
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return fmt.Errorf("pack: %w", err)
+		}
+		files = append(files, artifact.File{Path: filepath.ToSlash(rel), Data: data})
+		return nil
+	})
+	return files, err
+}
+
+// unpackOptions holds the parsed command line of the unpack subcommand.
+type unpackOptions struct {
+	pubKey          string
+	rootFS          string
+	resolver        string
+	fsync           bool
+	skipSpaceCheck  bool
+	ownershipDB     string
+	ownershipFormat string
+	backup          bool
+	backupDir       string
+}
+
+// newUnpackFlagSet returns the flag set of the unpack subcommand.
+func newUnpackFlagSet(opts *unpackOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release unpack", flag.ContinueOnError)
+	fs.StringVar(&opts.pubKey, "pubkey", "", "PEM file with the Ed25519 public key the bundle must be signed with (required)")
+	fs.StringVar(&opts.rootFS, "rootfs", "", "rootfs directory to write the files into; empty only verifies the bundle and lists its files")
+	fs.StringVar(&opts.resolver, "resolver", string(install.ResolverAuto), "how paths are resolved inside the rootfs: "+strings.Join(install.Resolvers(), ", "))
+	fs.BoolVar(&opts.fsync, "fsync", false, "write files under a temporary name, flush them and their directories to disk, and rename them into place")
+	fs.BoolVar(&opts.skipSpaceCheck, "skip-space-check", false, "write without first checking that the rootfs file system has space for the files")
+	fs.StringVar(&opts.ownershipDB, "ownership-db", "", "record root ownership of everything written in this database")
+	fs.StringVar(&opts.ownershipFormat, "ownership-format", string(install.OwnershipFakeroot), "format of --ownership-db: "+strings.Join(install.OwnershipFormats(), ", "))
+	fs.BoolVar(&opts.backup, "backup", false, "save every file before overwriting it next to itself as <file>.bak, keeping an existing .bak")
+	fs.StringVar(&opts.backupDir, "backup-dir", "", "like --backup, but save the files below this rootfs-relative directory in a subdirectory named by the time")
+	return fs
+}
+
+// runUnpack verifies a .tsrelease bundle and writes its files into a rootfs. Nothing is written unless the signature
+// and every file's checksum verified.
+func runUnpack(args []string) error {
+	var opts unpackOptions
+	fs := newUnpackFlagSet(&opts)
+	if err := parseSubcommand(fs, args); err != nil {
+		return err
+	}
+	if opts.pubKey == "" || fs.NArg() != 1 {
+		return errUsage
+	}
+	resolver, err := install.ParseResolver(opts.resolver)
+	if err != nil {
+		return err
+	}
+	format, err := install.ParseOwnershipFormat(opts.ownershipFormat)
+	if err != nil {
+		return err
+	}
+	pub, err := offline.LoadPublicKey(opts.pubKey)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("unpack: %w", err)
+	}
+	defer f.Close()
+	manifest, files, err := artifact.Read(f, pub)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "verified %s: build %s of %s, created %s\n", fs.Arg(0), manifest.BuildID, manifest.TargetName, manifest.Created.Format(time.RFC3339))
+	if opts.rootFS == "" {
+		for _, f := range files {
+			fmt.Fprintf(os.Stdout, "  %s  %s\n", f.SHA256, f.Path)
+		}
+		return nil
+	}
+
+	applyOpts := install.ApplyOptions{Resolver: resolver, Sync: opts.fsync, SkipSpaceCheck: opts.skipSpaceCheck}
+	if opts.ownershipDB != "" {
+		applyOpts.Ownership = &install.Ownership{Format: format, Path: opts.ownershipDB}
+	}
+	if opts.backup || opts.backupDir != "" {
+		applyOpts.Backup = &install.Backup{Dir: opts.backupDir}
+	}
+	installFiles := make([]install.File, 0, len(files))
+	for _, f := range files {
+		installFiles = append(installFiles, install.File{Path: f.Path, Data: f.Data})
+	}
+	if err := install.Apply(opts.rootFS, installFiles, applyOpts); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "wrote %d files to %s\n", len(files), opts.rootFS)
+	return nil
+}
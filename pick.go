@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/config"
+	"github.com/nickhildebrandt/ts-release/internal/review"
+	"github.com/nickhildebrandt/ts-release/internal/wallhaven"
+	"github.com/nickhildebrandt/ts-release/internal/wallpaper"
+)
+
+// pickOptions holds the parsed command line of the pick subcommand.
+type pickOptions struct {
+	query   string
+	count   int
+	out     string
+	approve string
+	curated string
+	config  string
+	net     options
+}
+
+// newPickFlagSet returns the flag set of the pick subcommand.
+func newPickFlagSet(opts *pickOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release pick", flag.ContinueOnError)
+	fs.StringVar(&opts.query, "query", wallpaper.DefaultSearchParams().Query, "Wallhaven search text for the candidates")
+	fs.IntVar(&opts.count, "count", 8, "number of candidates to download")
+	fs.StringVar(&opts.out, "out", "candidates", "directory for the candidates and their index.html contact sheet")
+	fs.StringVar(&opts.approve, "approve", "", "approve this candidate ID or URL from --out instead of downloading")
+	fs.StringVar(&opts.curated, "curated", "approved.txt", "curated list that --approve adds the wallpaper to, for builds with --curated")
+	fs.StringVar(&opts.config, "config", "", "config file that --approve pins the wallpaper into with wallpaper-id, instead of adding it to --curated")
+	addNetFlags(fs, &opts.net)
+	return fs
+}
+
+// runPick downloads wallpaper candidates with a contact sheet for review, or, with --approve, adds a reviewed
+// candidate to the curated list that builds pick from or pins it in a config file.
+func runPick(args []string) error {
+	var opts pickOptions
+	fs := newPickFlagSet(&opts)
+	if err := parseSubcommand(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+	if opts.approve != "" {
+		return approveCandidate(opts)
+	}
+	if opts.count < 1 {
+		return fmt.Errorf("pick: --count %d must be positive", opts.count)
+	}
+
+	httpClient, err := newHTTPClient(opts.net)
+	if err != nil {
+		return err
+	}
+	client := newWallhavenClient(opts.net, httpClient)
+	params := wallpaper.DefaultSearchParams()
+	params.Query = opts.query
+	var results []wallhaven.Wallpaper
+	for params.Page = 1; len(results) < opts.count; params.Page++ {
+		page, err := client.Search(params)
+		if err != nil {
+			return fmt.Errorf("pick: %w", err)
+		}
+		for _, w := range page.Data {
+			if w.Path != "" && len(results) < opts.count {
+				results = append(results, w)
+			}
+		}
+		// Random sorting keeps later pages consistent with the first only with its seed.
+		params.Seed = page.Meta.Seed
+		if page.Meta.CurrentPage >= page.Meta.LastPage {
+			break
+		}
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("pick: %w for %q", wallpaper.ErrNoResults, opts.query)
+	}
+
+	if err := os.MkdirAll(opts.out, 0o755); err != nil {
+		return fmt.Errorf("pick: %w", err)
+	}
+	sheet := review.Sheet{Query: opts.query, Created: time.Now()}
+	for _, w := range results {
+		data, err := client.Download(w.Path)
+		if err != nil {
+			return fmt.Errorf("pick: %w", err)
+		}
+		if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("pick: %s: %w", w.Path, err)
+		}
+		name := wallhavenFileName(&w)
+		if err := os.WriteFile(filepath.Join(opts.out, name), data, 0o644); err != nil {
+			return fmt.Errorf("pick: %w", err)
+		}
+		sheet.Candidates = append(sheet.Candidates, review.Candidate{
+			ID: w.ID, File: name, PageURL: w.URL, ImageURL: w.Path, Resolution: w.Resolution,
+			FileSize: int64(len(data)), Views: w.Views, Favorites: w.Favorites,
+		})
+	}
+	if err := review.Write(opts.out, sheet); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "downloaded %d candidates for %q; review %s\n", len(sheet.Candidates), opts.query, filepath.Join(opts.out, review.IndexName))
+	fmt.Fprintf(os.Stdout, "approve with: ts-release pick --out %s --approve <id>\n", opts.out)
+	return nil
+}
+
+// pinnedSettings are the config settings that choose the background instead of a pinned wallpaper; pinning one
+// removes them, since builds reject them next to --wallpaper-id.
+var pinnedSettings = []string{"wallpaper-url", "collection", "curated", "background", "video", "source"}
+
+// approveCandidate adds the --approve candidate of the review in --out to the --curated list, or pins it in the
+// --config file.
+func approveCandidate(opts pickOptions) error {
+	sheet, err := review.Load(opts.out)
+	if err != nil {
+		return err
+	}
+	c, err := sheet.Candidate(opts.approve)
+	if err != nil {
+		return err
+	}
+	if opts.config != "" {
+		changes := []config.Setting{{Name: "wallpaper-id", Value: c.ID}}
+		for _, name := range pinnedSettings {
+			changes = append(changes, config.Setting{Name: name})
+		}
+		if err := config.Update(opts.config, changes); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "pinned %s in %s; build with: --config %s\n", c.ID, opts.config, opts.config)
+		return nil
+	}
+	added, err := review.Approve(opts.curated, c, sheet.Query, time.Now())
+	if err != nil {
+		return err
+	}
+	if !added {
+		fmt.Fprintf(os.Stdout, "%s is already approved in %s\n", c.ID, opts.curated)
+		return nil
+	}
+	fmt.Fprintf(os.Stdout, "approved %s in %s; build with: --curated %s\n", c.ID, opts.curated, opts.curated)
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/install"
+	"github.com/nickhildebrandt/ts-release/internal/wallpaper"
+)
+
+// runReport is the JSON report --report writes after a build.
+type runReport struct {
+	TargetName string           `json:"target_name"`
+	BuildID    string           `json:"build_id"`
+	Artifacts  []reportArtifact `json:"artifacts"`
+	Warnings   []string         `json:"warnings,omitempty"`
+	// Image holds the tone statistics of the wallpaper that --min-luminance and the other image checks test.
+	Image wallpaper.ImageStats `json:"image"`
+	// BootSplashCandidates is set with --compare-boot-splash.
+	BootSplashCandidates []bootSplashCandidate `json:"boot_splash_candidates,omitempty"`
+}
+
+// bootSplashCandidate is the size and decode time of the boot splash in one format.
+type bootSplashCandidate struct {
+	Format       string  `json:"format"`
+	Size         int     `json:"size"`
+	DecodeMillis float64 `json:"decode_ms"`
+}
+
+// reportArtifact is a written file with its rootfs-relative path and its encoded size in bytes.
+type reportArtifact struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// imageChecks holds the thresholds --min-luminance, --max-luminance, --min-box-contrast, and --max-clipping set for the
+// statistics of the wallpaper; zero disables a check.
+type imageChecks struct {
+	minLuminance, maxLuminance float64
+	minBoxContrast             float64
+	maxClipping                float64
+}
+
+// validate checks the threshold ranges.
+func (c imageChecks) validate() error {
+	for _, r := range []struct {
+		name     string
+		v        float64
+		min, max float64
+	}{
+		{"min luminance", c.minLuminance, 0, 1},
+		{"max luminance", c.maxLuminance, 0, 1},
+		{"min box contrast", c.minBoxContrast, 0, 21},
+		{"max clipping", c.maxClipping, 0, 100},
+	} {
+		if r.v < r.min || r.v > r.max {
+			return fmt.Errorf("%s %v out of range [%v, %v]", r.name, r.v, r.min, r.max)
+		}
+	}
+	if c.minLuminance > 0 && c.maxLuminance > 0 && c.minLuminance > c.maxLuminance {
+		return fmt.Errorf("min luminance %v is above max luminance %v", c.minLuminance, c.maxLuminance)
+	}
+	return nil
+}
+
+// warnings returns a message for each threshold stats fails.
+func (c imageChecks) warnings(stats wallpaper.ImageStats) []string {
+	var out []string
+	if c.minLuminance > 0 && stats.MeanLuminance < c.minLuminance {
+		out = append(out, fmt.Sprintf("mean luminance %.3f is below the --min-luminance of %v", stats.MeanLuminance, c.minLuminance))
+	}
+	if c.maxLuminance > 0 && stats.MeanLuminance > c.maxLuminance {
+		out = append(out, fmt.Sprintf("mean luminance %.3f is above the --max-luminance of %v", stats.MeanLuminance, c.maxLuminance))
+	}
+	if c.minBoxContrast > 0 && stats.BoxContrast > 0 && stats.BoxContrast < c.minBoxContrast {
+		out = append(out, fmt.Sprintf("text contrast %.2f:1 in the box is below the --min-box-contrast of %v", stats.BoxContrast, c.minBoxContrast))
+	}
+	for _, clipped := range []struct {
+		to      string
+		percent float64
+	}{{"black", stats.ClippedBlack}, {"white", stats.ClippedWhite}} {
+		if c.maxClipping > 0 && clipped.percent > c.maxClipping {
+			out = append(out, fmt.Sprintf("%.2f%% of pixels are clipped to %s, over the --max-clipping of %v%%", clipped.percent, clipped.to, c.maxClipping))
+		}
+	}
+	return out
+}
+
+// newRunReport lists the artifacts of a build with the statistics of the wallpaper img and checks background.jpg
+// against --jpeg-budget, the statistics against the image checks, and layout against the --shell panels, printing a
+// warning for each problem to stderr and keeping it in the report.
+func newRunReport(opts options, buildID string, artifacts []install.Artifact, img *image.RGBA, layout wallpaper.Layout) runReport {
+	stats := wallpaper.MeasureImage(img, layout)
+	report := runReport{TargetName: opts.targetName, BuildID: buildID, Artifacts: []reportArtifact{}, Image: stats}
+	jpegPath := path.Join(opts.installLayout().BackgroundDir, "background.jpg")
+	var warnings []string
+	for _, a := range artifacts {
+		report.Artifacts = append(report.Artifacts, reportArtifact{Path: a.Path, Size: a.Size})
+		if opts.jpegBudget > 0 && a.Path == jpegPath && a.Size > opts.jpegBudget {
+			warnings = append(warnings, fmt.Sprintf("%s is %d bytes, over the --jpeg-budget of %d", a.Path, a.Size, opts.jpegBudget))
+		}
+	}
+	warnings = append(warnings, opts.imageChecks.warnings(stats)...)
+	for _, warning := range append(warnings, wallpaper.SafeAreaCollisions(layout, opts.safeArea.Shell)...) {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+		report.Warnings = append(report.Warnings, warning)
+	}
+	return report
+}
+
+// newBootSplashCandidates converts the candidates of install.CompareBootSplash for the report.
+func newBootSplashCandidates(candidates []install.BootSplashCandidate) []bootSplashCandidate {
+	out := make([]bootSplashCandidate, 0, len(candidates))
+	for _, c := range candidates {
+		out = append(out, bootSplashCandidate{Format: c.Format, Size: c.Size, DecodeMillis: c.Decode.Seconds() * 1000})
+	}
+	return out
+}
+
+// writeBootSplashComparison prints the candidates as a table, with the decode time relative to the fastest and the
+// selected format marked.
+func writeBootSplashComparison(w io.Writer, selected string, candidates []install.BootSplashCandidate) error {
+	fastest := time.Duration(0)
+	for _, c := range candidates {
+		if fastest == 0 || c.Decode < fastest {
+			fastest = c.Decode
+		}
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FORMAT\tSIZE\tDECODE\tVS FASTEST")
+	for _, c := range candidates {
+		mark := ""
+		if c.Format == selected {
+			mark = " (selected)"
+		}
+		ratio := 1.0
+		if fastest > 0 {
+			ratio = float64(c.Decode) / float64(fastest)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%.1fx%s\n", c.Format, c.Size, c.Decode.Round(time.Microsecond), ratio, mark)
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("compare boot splash: %w", err)
+	}
+	return nil
+}
+
+// writeReport writes report as indented JSON to path, or to stdout for "-".
+func writeReport(path string, report runReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = os.WriteFile(path, data, 0o644)
+	}
+	if err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+	return nil
+}
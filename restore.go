@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/install"
+)
+
+// restoreOptions holds the parsed command line of the restore subcommand.
+type restoreOptions struct {
+	backupDir string
+	from      string
+	profile   string
+	product   string
+	resolver  string
+	fsync     bool
+}
+
+// newRestoreFlagSet returns the flag set of the restore subcommand.
+func newRestoreFlagSet(opts *restoreOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release restore", flag.ContinueOnError)
+	fs.StringVar(&opts.backupDir, "backup-dir", "", "rootfs-relative directory the files were saved in with --backup-dir; empty restores the <file>.bak copies of --backup")
+	fs.StringVar(&opts.from, "from", "", "run of --backup-dir to restore, named like 20260104T133513Z (default: the latest)")
+	fs.StringVar(&opts.profile, "install-profile", string(install.ProfileLinux), "directory layout whose manifest lists the files saved with --backup: "+strings.Join(install.Profiles(), ", "))
+	fs.StringVar(&opts.product, "product", install.DefaultProduct, "product whose manifest lists the files saved with --backup")
+	fs.StringVar(&opts.resolver, "resolver", string(install.ResolverAuto), "how paths are resolved inside the rootfs: "+strings.Join(install.Resolvers(), ", "))
+	fs.BoolVar(&opts.fsync, "fsync", false, "write files under a temporary name, flush them and their directories to disk, and rename them into place")
+	return fs
+}
+
+// runRestore puts the files that a run with --backup or --backup-dir saved back into a rootfs.
+func runRestore(args []string) error {
+	var opts restoreOptions
+	fs := newRestoreFlagSet(&opts)
+	if err := parseSubcommand(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	profile, err := install.ParseProfile(opts.profile)
+	if err != nil {
+		return err
+	}
+	product, err := install.ParseProduct(opts.product)
+	if err != nil {
+		return err
+	}
+	resolver, err := install.ParseResolver(opts.resolver)
+	if err != nil {
+		return err
+	}
+	backup := install.Backup{Dir: opts.backupDir}
+	if opts.from != "" {
+		if opts.backupDir == "" {
+			return fmt.Errorf("restore: --from needs --backup-dir")
+		}
+		if backup.Time, err = time.Parse(install.BackupTimeLayout, opts.from); err != nil {
+			return fmt.Errorf("restore: run %q is not named like %s", opts.from, install.BackupTimeLayout)
+		}
+	}
+	restored, err := install.Restore(fs.Arg(0), install.RestoreOptions{Profile: profile, Product: product, Backup: backup, Resolver: resolver, Sync: opts.fsync})
+	if err != nil {
+		return err
+	}
+	for _, p := range restored {
+		fmt.Fprintf(os.Stdout, "restored %s\n", p)
+	}
+	return nil
+}
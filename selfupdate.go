@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/buildid"
+	"github.com/nickhildebrandt/ts-release/internal/bundle"
+	"github.com/nickhildebrandt/ts-release/internal/offline"
+	"github.com/nickhildebrandt/ts-release/internal/selfupdate"
+)
+
+// selfUpdateOptions holds the parsed command line of the self-update subcommand.
+type selfUpdateOptions struct {
+	url     string
+	pubkey  string
+	check   bool
+	force   bool
+	timeout time.Duration
+	// net carries the proxy and CA flags of the downloads.
+	net options
+}
+
+// newSelfUpdateFlagSet returns the flag set of the self-update subcommand.
+func newSelfUpdateFlagSet(opts *selfUpdateOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release self-update", flag.ContinueOnError)
+	fs.StringVar(&opts.url, "url", "", "release endpoint serving release.json, its signature, and the executables (required)")
+	fs.StringVar(&opts.pubkey, "pubkey", "", "PEM file with the Ed25519 public key releases must be signed with (required)")
+	fs.BoolVar(&opts.check, "check", false, "only report whether a newer release is available")
+	fs.BoolVar(&opts.force, "force", false, "install the release even if it is not newer than the running version")
+	fs.DurationVar(&opts.timeout, "timeout", 5*time.Minute, "time the check and download may take")
+	addProxyFlags(fs, &opts.net)
+	return fs
+}
+
+// runSelfUpdate replaces the running executable with the release of the endpoint for its platform if that release is
+// newer and signed with the given key, keeping the backgrounds of `bundle embed`. `self-update sign` publishes releases
+// instead.
+func runSelfUpdate(args []string) error {
+	if len(args) > 0 && args[0] == "sign" {
+		return runSelfUpdateSign(args[1:])
+	}
+	var opts selfUpdateOptions
+	fs := newSelfUpdateFlagSet(&opts)
+	if err := parseSubcommand(fs, args); err != nil {
+		return err
+	}
+	if opts.url == "" || opts.pubkey == "" || fs.NArg() != 0 {
+		return errUsage
+	}
+	pub, err := offline.LoadPublicKey(opts.pubkey)
+	if err != nil {
+		return err
+	}
+	client, err := newHTTPClient(opts.net)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+
+	release, err := selfupdate.Check(ctx, client, opts.url, pub)
+	if err != nil {
+		return err
+	}
+	binary, ok := release.Binary(selfupdate.Platform())
+	if !ok {
+		return fmt.Errorf("self-update: release %s has no executable for %s", release.Version, selfupdate.Platform())
+	}
+	// Only newer versions are installed, so an old signed release served again cannot downgrade the executable.
+	order, err := buildid.Compare(buildid.CompareSemver, release.Version, version)
+	if err != nil && !opts.force {
+		return fmt.Errorf("self-update: cannot order release %s and the running version %s; pass --force to install it anyway", release.Version, version)
+	}
+	if order <= 0 && !opts.force {
+		fmt.Fprintf(os.Stdout, "ts-release %s is up to date (latest release %s)\n", version, release.Version)
+		return nil
+	}
+	if opts.check {
+		fmt.Fprintf(os.Stdout, "ts-release %s is available (running %s)\n", release.Version, version)
+		return nil
+	}
+
+	data, err := selfupdate.Download(ctx, client, opts.url, binary)
+	if err != nil {
+		return err
+	}
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		return fmt.Errorf("self-update: %w", err)
+	}
+	// The release is a plain executable, so the bundle of the running one is appended to it again.
+	current, err := os.ReadFile(exe)
+	if err != nil {
+		return fmt.Errorf("self-update: %w", err)
+	}
+	data, carried := bundle.Carry(data, current)
+	if err := selfupdate.Replace(exe, data); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "updated %s from %s to %s\n", exe, version, release.Version)
+	if carried {
+		fmt.Fprintf(os.Stdout, "kept the bundled backgrounds\n")
+	}
+	return nil
+}
+
+// selfUpdateSignOptions holds the parsed command line of the self-update sign subcommand.
+type selfUpdateSignOptions struct {
+	key     string
+	version string
+	dir     string
+}
+
+// newSelfUpdateSignFlagSet returns the flag set of the self-update sign subcommand.
+func newSelfUpdateSignFlagSet(opts *selfUpdateSignOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release self-update sign", flag.ContinueOnError)
+	fs.StringVar(&opts.key, "key", "", "PEM file with the Ed25519 private key that signs the release (required)")
+	fs.StringVar(&opts.version, "version", "", "semantic version of the release, e.g. 1.5.0 (required)")
+	fs.StringVar(&opts.dir, "d", "", "directory to write the release endpoint into, to be served over HTTPS (required)")
+	return fs
+}
+
+// runSelfUpdateSign publishes the executables given as <GOOS>/<GOARCH>=<file> arguments as a signed release.
+func runSelfUpdateSign(args []string) error {
+	var opts selfUpdateSignOptions
+	fs := newSelfUpdateSignFlagSet(&opts)
+	if err := parseSubcommand(fs, args); err != nil {
+		return err
+	}
+	if opts.key == "" || opts.version == "" || opts.dir == "" || fs.NArg() == 0 {
+		return errUsage
+	}
+	if _, err := buildid.Compare(buildid.CompareSemver, opts.version, opts.version); err != nil {
+		return fmt.Errorf("self-update sign: version %q is not a semantic version", opts.version)
+	}
+	executables := map[string]string{}
+	for _, arg := range fs.Args() {
+		platform, file, ok := strings.Cut(arg, "=")
+		if !ok || file == "" {
+			return fmt.Errorf("self-update sign: %q: want <GOOS>/<GOARCH>=<file>", arg)
+		}
+		if _, dup := executables[platform]; dup {
+			return fmt.Errorf("self-update sign: %s is given twice", platform)
+		}
+		executables[platform] = file
+	}
+	key, err := offline.LoadPrivateKey(opts.key)
+	if err != nil {
+		return err
+	}
+	if err := selfupdate.Publish(opts.dir, key, opts.version, time.Now(), executables); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "wrote release %s with %d executables to %s\n", opts.version, len(executables), opts.dir)
+	return nil
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/buildid"
+	"github.com/nickhildebrandt/ts-release/internal/diag"
+	"github.com/nickhildebrandt/ts-release/internal/release"
+	"github.com/nickhildebrandt/ts-release/internal/renderapi"
+	"github.com/nickhildebrandt/ts-release/internal/trace"
+	"github.com/nickhildebrandt/ts-release/internal/wallhaven"
+	"github.com/nickhildebrandt/ts-release/internal/wallpaper"
+)
+
+// serveOptions holds the parsed command line of the serve subcommand.
+type serveOptions struct {
+	listen          string
+	config          string
+	maxBody         int64
+	maxConcurrent   int
+	maxWidth        int
+	maxHeight       int
+	renderTimeout   time.Duration
+	shutdownTimeout time.Duration
+	installRoot     string
+}
+
+// newServeFlagSet returns the flag set of the serve subcommand.
+func newServeFlagSet(opts *serveOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release serve", flag.ContinueOnError)
+	fs.StringVar(&opts.listen, "listen", ":8080", "address to serve the HTTP API on")
+	fs.StringVar(&opts.config, "config", "", "config file with the build flags every render starts from")
+	fs.Int64Var(&opts.maxBody, "max-body", 1<<20, "maximum size of a request body in bytes")
+	fs.IntVar(&opts.maxConcurrent, "max-concurrent", 2, "renders run at the same time; further requests get 503")
+	fs.IntVar(&opts.maxWidth, "max-width", 7680, "largest width a render may request")
+	fs.IntVar(&opts.maxHeight, "max-height", 4320, "largest height a render may request")
+	fs.DurationVar(&opts.renderTimeout, "render-timeout", 2*time.Minute, "time a request may take from reading the body to writing the image")
+	fs.DurationVar(&opts.shutdownTimeout, "shutdown-timeout", 30*time.Second, "time running renders get to finish after SIGINT or SIGTERM")
+	fs.StringVar(&opts.installRoot, "install-root", "", "directory holding the rootfs trees the gRPC Install and Verify calls may use (empty disables them)")
+	return fs
+}
+
+// renderRequest is the JSON body of POST /render. Empty fields keep the config file's settings.
+type renderRequest struct {
+	TargetName  string          `json:"target_name"`
+	BuildID     string          `json:"build_id"`
+	Subtitle    string          `json:"subtitle"`
+	Channel     string          `json:"channel"`
+	Layout      string          `json:"layout"`
+	Theme       json.RawMessage `json:"theme"`
+	Width       int             `json:"width"`
+	Height      int             `json:"height"`
+	Format      string          `json:"format"`
+	WallpaperID string          `json:"wallpaper_id"`
+}
+
+// renderServer answers the HTTP API with the base options of the config file.
+type renderServer struct {
+	opts  serveOptions
+	base  options
+	slots chan struct{}
+}
+
+// runServe serves the HTTP API until SIGINT or SIGTERM, then stops accepting connections and waits for running
+// renders up to --shutdown-timeout.
+func runServe(args []string) error {
+	var opts serveOptions
+	fs := newServeFlagSet(&opts)
+	if err := parseSubcommand(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+	if opts.maxBody <= 0 || opts.maxConcurrent <= 0 || opts.maxWidth <= 0 || opts.maxHeight <= 0 {
+		return fmt.Errorf("serve: --max-body, --max-concurrent, --max-width, and --max-height must be positive")
+	}
+	var baseArgs []string
+	if opts.config != "" {
+		baseArgs = []string{"--config", opts.config}
+	}
+	base, positional, err := parseFlags(baseArgs)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return fmt.Errorf("config: %s: unexpected arguments %q", opts.config, positional)
+	}
+	if opts.installRoot != "" {
+		if info, err := os.Stat(opts.installRoot); err != nil || !info.IsDir() {
+			return fmt.Errorf("serve: install root %s is no directory", opts.installRoot)
+		}
+	}
+
+	s := &renderServer{opts: opts, base: base, slots: make(chan struct{}, opts.maxConcurrent)}
+	mux := http.NewServeMux()
+	mux.Handle("/render", trace.Handler(http.HandlerFunc(s.render)))
+	mux.Handle(renderapi.Service, trace.Handler(s.rpcServer()))
+	mux.Handle("/metrics", metricsRegistry)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, "ok")
+	})
+	// gRPC clients connect with unencrypted HTTP/2, which plain HTTP clients can use too.
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{
+		Protocols:         protocols,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		WriteTimeout:      opts.renderTimeout,
+		IdleTimeout:       2 * time.Minute,
+	}
+	ln, err := net.Listen("tcp", opts.listen)
+	if err != nil {
+		return fmt.Errorf("serve: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "serve: listening on http://%s\n", ln.Addr())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go flushTracesEvery(ctx)
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+	select {
+	case err := <-served:
+		return fmt.Errorf("serve: %w", err)
+	case <-ctx.Done():
+	}
+	fmt.Fprintln(os.Stderr, "serve: shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("serve: shutdown: %w", err)
+	}
+	return nil
+}
+
+// render answers POST /render with the encoded wallpaper of the request.
+func (s *renderServer) render(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	if !s.acquire() {
+		w.Header().Set("Retry-After", "5")
+		writeError(w, http.StatusServiceUnavailable, fmt.Sprintf("%d renders are running", s.opts.maxConcurrent))
+		return
+	}
+	defer s.release()
+
+	var req renderRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.opts.maxBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("body exceeds %d bytes", s.opts.maxBody))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	job, err := s.prepare(req, "")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	out, err := renderImage(r.Context(), job)
+	if err != nil {
+		status := http.StatusInternalServerError
+		var fetchErr *wallpaper.FetchError
+		if errors.Is(err, wallpaper.ErrTextTooLong) {
+			status = http.StatusBadRequest
+		} else if errors.As(err, &fetchErr) {
+			status = http.StatusBadGateway
+			err = fmt.Errorf("%w; hint (%s error): %s", err, fetchErr.Kind, fetchErr.Hint())
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", out.contentType)
+	w.Header().Set("X-Build-Id", out.buildID)
+	if out.background.WallpaperID != "" {
+		w.Header().Set("X-Wallpaper-Id", out.background.WallpaperID)
+	}
+	if out.background.Source != "" {
+		w.Header().Set("X-Background-Source", out.background.Source)
+	}
+	w.Write(out.data)
+}
+
+// acquire takes one of the --max-concurrent render slots and reports whether one was free.
+func (s *renderServer) acquire() bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// release returns a slot taken by acquire.
+func (s *renderServer) release() {
+	<-s.slots
+}
+
+// renderJob is a validated render request.
+type renderJob struct {
+	opts          options
+	width, height int
+	format        string
+}
+
+// prepare applies a request to the base options for a render, or for an install into rootFS if it is not empty, and
+// validates it. Every error it returns is the client's fault.
+func (s *renderServer) prepare(req renderRequest, rootFS string) (renderJob, error) {
+	opts := s.base
+	opts.rootFS = rootFS
+	var err error
+	if req.TargetName == "" {
+		return renderJob{}, errors.New("target_name is required")
+	}
+	// Checking here reports a stray newline as the client's fault rather than a failed render.
+	if opts.targetName, err = release.CheckText(opts.textPolicy, "target name", req.TargetName); err != nil {
+		return renderJob{}, err
+	}
+	format := req.Format
+	if format == "" {
+		format = "png"
+	}
+	if format != "png" && format != "jpeg" {
+		return renderJob{}, fmt.Errorf("unknown format %q (available: png, jpeg)", format)
+	}
+	width, height := req.Width, req.Height
+	if width == 0 && height == 0 {
+		width, height = wallpaper.TargetWidth, wallpaper.TargetHeight
+	}
+	if width <= 0 || height <= 0 || width > s.opts.maxWidth || height > s.opts.maxHeight {
+		return renderJob{}, fmt.Errorf("resolution %dx%d out of range [1x1, %dx%d]", width, height, s.opts.maxWidth, s.opts.maxHeight)
+	}
+	if req.BuildID != "" {
+		if opts.buildID, err = release.CheckText(opts.textPolicy, "build ID", req.BuildID); err != nil {
+			return renderJob{}, err
+		}
+	}
+	if opts.buildID == "" && opts.buildIDFormat == buildid.FormatCounter && rootFS == "" {
+		return renderJob{}, fmt.Errorf("build ID format %s needs a rootfs; send build_id", buildid.FormatCounter)
+	}
+	if req.Subtitle != "" {
+		opts.subtitle = req.Subtitle
+	}
+	if req.Channel != "" {
+		if opts.channel, err = wallpaper.ParseChannel(req.Channel); err != nil {
+			return renderJob{}, err
+		}
+	}
+	if req.Layout != "" {
+		if opts.layout, err = wallpaper.ParseLayout(req.Layout); err != nil {
+			return renderJob{}, err
+		}
+	}
+	if len(req.Theme) > 0 && string(req.Theme) != "null" {
+		theme, err := wallpaper.ParseTheme(req.Theme)
+		if err != nil {
+			return renderJob{}, err
+		}
+		opts.inlineTheme = &theme
+	}
+	if req.WallpaperID != "" {
+		if opts.wallpaperID, err = wallhaven.ParseWallpaperID(req.WallpaperID); err != nil {
+			return renderJob{}, err
+		}
+		opts.curated, opts.collectionUser, opts.collectionID = "", "", 0
+	}
+	return renderJob{opts: opts, width: width, height: height, format: format}, nil
+}
+
+// rendered is an encoded wallpaper with the details reported to the client.
+type rendered struct {
+	data        []byte
+	contentType string
+	buildID     string
+	background  wallpaper.Background
+}
+
+// renderImage fetches the background of a job, renders the wallpaper at its size, and encodes it, reporting each
+// stage to the job's progress function and recording it in a span below the span in ctx.
+func renderImage(ctx context.Context, job renderJob) (out rendered, err error) {
+	start := time.Now()
+	defer func() { observeDuration(operationRender, start, err) }()
+	rel, err := releaseInfo(job.opts, job.opts.now())
+	if err != nil {
+		return rendered{}, err
+	}
+	subtitle, wpOpts, err := renderOptions(job.opts, rel)
+	if err != nil {
+		return rendered{}, err
+	}
+	wpOpts.Width, wpOpts.Height = job.width, job.height
+	if err := wallpaper.CheckFit(rel.TargetName, subtitle, wpOpts); err != nil {
+		return rendered{}, err
+	}
+	job.opts.report(stageFetch)
+	fetched, client, err := fetchBackground(ctx, job.opts, job.width, job.height)
+	if err != nil {
+		return rendered{}, err
+	}
+	wpOpts.Wallhaven = client
+	if fetched.Focal != nil {
+		wpOpts.Focal = fetched.Focal
+	}
+	job.opts.report(stageRender)
+	_, span := trace.Start(ctx, stageRender)
+	img, _, err := wallpaper.RenderWithLayout(fetched.Image, rel.TargetName, subtitle, wpOpts)
+	endSpan(span, &err)
+	if err != nil {
+		return rendered{}, err
+	}
+
+	// Encoding into a buffer first keeps a failed encode from sending a truncated image as a success.
+	job.opts.report(stageEncode)
+	_, span = trace.Start(ctx, stageEncode, trace.String("ts_release.format", job.format))
+	defer endSpan(span, &err)
+	out = rendered{contentType: "image/png", buildID: rel.BuildID, background: fetched}
+	if job.format == "jpeg" {
+		out.contentType = "image/jpeg"
+	}
+	if out.data, err = encodeImage(img, job.format); err != nil {
+		return rendered{}, err
+	}
+	return out, nil
+}
+
+// encodeImage encodes img as PNG or, for format "jpeg", as JPEG. A panic of the encoder is returned as an error, so
+// it fails the request instead of the server.
+func encodeImage(img image.Image, format string) (_ []byte, err error) {
+	defer diag.Recover(stageEncode, &err)
+	var body bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&body, img, &jpeg.Options{Quality: 92})
+	} else {
+		err = png.Encode(&body, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+// writeError answers with status and a JSON body holding the message.
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/trace"
+)
+
+// traceFlushInterval is how often serve and watch export spans, as OTEL_BSP_SCHEDULE_DELAY defaults to.
+const traceFlushInterval = 5 * time.Second
+
+// endSpan ends span with the error err points to, for deferred calls. Spans ended before are left unchanged.
+func endSpan(span *trace.Span, err *error) {
+	span.SetError(*err)
+	span.End()
+}
+
+// flushTraces exports the spans ended so far. Failing to export only warns, so a missing collector never fails a
+// build.
+func flushTraces() {
+	if err := trace.Default.Flush(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+}
+
+// flushTracesEvery exports spans every traceFlushInterval until ctx is done, for commands that run until stopped.
+func flushTracesEvery(ctx context.Context) {
+	ticker := time.NewTicker(traceFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			flushTraces()
+		}
+	}
+}
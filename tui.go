@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nickhildebrandt/ts-release/internal/config"
+	"github.com/nickhildebrandt/ts-release/internal/install"
+	"github.com/nickhildebrandt/ts-release/internal/preview"
+	"github.com/nickhildebrandt/ts-release/internal/wallpaper"
+)
+
+// tuiSettings are the config file settings the tui subcommand edits, in display order.
+var tuiSettings = []string{"query", "theme", "layout", "subtitle"}
+
+// tuiOptions holds the parsed command line of the tui subcommand.
+type tuiOptions struct {
+	config   string
+	protocol string
+}
+
+// newTUIFlagSet returns the flag set of the tui subcommand.
+func newTUIFlagSet(opts *tuiOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release tui", flag.ContinueOnError)
+	fs.StringVar(&opts.config, "config", "ts-release.conf", "config file to start from and save to; created on save if missing")
+	fs.StringVar(&opts.protocol, "preview", string(preview.ProtocolAuto), "inline preview protocol: "+joinNames(preview.Protocols()))
+	return fs
+}
+
+// tuiSession is the state of an interactive tui session.
+type tuiSession struct {
+	opts     tuiOptions
+	protocol preview.Protocol
+	out      io.Writer
+	// clear redraws the screen from the top; it is set when the output is a terminal.
+	clear bool
+	title string
+	// values are the edited settings; changed marks those to write on save.
+	values  map[string]string
+	changed map[string]bool
+	// pinned keeps the background of the last preview until the query changes or "next" is given.
+	pinned  string
+	rootFS  string
+	preview image.Image
+	message string
+}
+
+// runTUI lets a user adjust the query, theme, layout, and texts of a target in the terminal, previews each change
+// inline, and saves the settings to the config file that builds read with --config. Commands are read line by line
+// from standard input.
+func runTUI(args []string) error {
+	var opts tuiOptions
+	fs := newTUIFlagSet(&opts)
+	if err := parseSubcommand(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || fs.Arg(0) == "" {
+		return errUsage
+	}
+	protocol, err := preview.ParseProtocol(opts.protocol)
+	if err != nil {
+		return err
+	}
+	if protocol == preview.ProtocolAuto {
+		protocol = preview.Detect(os.Getenv)
+	}
+	s := &tuiSession{
+		opts:     opts,
+		protocol: protocol,
+		out:      os.Stdout,
+		title:    fs.Arg(0),
+		values:   map[string]string{},
+		changed:  map[string]bool{},
+	}
+	if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		s.clear = true
+	}
+	settings, err := config.Load(opts.config)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for _, setting := range settings {
+		s.values[setting.Name] = setting.Value
+	}
+	if s.rootFS, err = os.MkdirTemp("", "ts-release-tui-"); err != nil {
+		return fmt.Errorf("tui: %w", err)
+	}
+	defer os.RemoveAll(s.rootFS)
+
+	s.render()
+	s.draw()
+	input := bufio.NewScanner(os.Stdin)
+	for input.Scan() {
+		if quit := s.handle(input.Text()); quit {
+			return nil
+		}
+		s.draw()
+	}
+	return input.Err()
+}
+
+// handle executes one command line and reports whether the session ends.
+func (s *tuiSession) handle(line string) (quit bool) {
+	command, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+	s.message = ""
+	switch command {
+	case "":
+	case "query", "theme", "layout", "subtitle":
+		old, had := s.values[command]
+		s.values[command] = arg
+		if _, err := s.options(); err != nil {
+			// Invalid values never reach the config file.
+			if had {
+				s.values[command] = old
+			} else {
+				delete(s.values, command)
+			}
+			s.message = err.Error()
+			return false
+		}
+		s.changed[command] = true
+		if command == "query" {
+			s.pinned = ""
+		}
+		s.render()
+	case "title":
+		if arg == "" {
+			s.message = "title needs a text"
+			return false
+		}
+		s.title = arg
+		s.render()
+	case "next":
+		s.pinned = ""
+		s.render()
+	case "save":
+		var changes []config.Setting
+		for _, name := range tuiSettings {
+			if s.changed[name] {
+				changes = append(changes, config.Setting{Name: name, Value: s.values[name]})
+			}
+		}
+		if err := config.Update(s.opts.config, changes); err != nil {
+			s.message = err.Error()
+			return false
+		}
+		clear(s.changed)
+		s.message = fmt.Sprintf("saved %d settings to %s; build with: ts-release --config %s %q <rootfs-dir>", len(changes), s.opts.config, s.opts.config, s.title)
+	case "quit", "q":
+		if len(s.changed) > 0 {
+			fmt.Fprintln(s.out, "discarded unsaved changes")
+		}
+		return true
+	default:
+		s.message = fmt.Sprintf("unknown command %q", command)
+	}
+	return false
+}
+
+// options returns the build options of the current settings: the config file's, overridden by the session's values,
+// rendering into the session's scratch rootfs without splash and slideshow.
+func (s *tuiSession) options() (options, error) {
+	var args []string
+	if _, err := os.Stat(s.opts.config); err == nil {
+		args = append(args, "--config", s.opts.config)
+	}
+	for _, name := range tuiSettings {
+		if value, ok := s.values[name]; ok {
+			args = append(args, "--"+name+"="+value)
+		}
+	}
+	args = append(args, "--splash-frames=0", "--slideshow=false", s.title, s.rootFS)
+	opts, err := parseArgs(args)
+	if errors.Is(err, errUsage) {
+		return options{}, fmt.Errorf("invalid settings")
+	}
+	return opts, err
+}
+
+// render builds the wallpaper of the current settings into the scratch rootfs and keeps it as the preview. The
+// Wallhaven background stays pinned across renders unless the config file selects another source.
+func (s *tuiSession) render() {
+	opts, err := s.options()
+	if err != nil {
+		s.message = err.Error()
+		return
+	}
+	if s.pinned != "" && opts.source == wallpaper.SourceWallhaven && opts.wallpaperID == "" && opts.curated == "" &&
+		opts.collectionUser == "" && opts.background == "" && opts.video == "" {
+		opts.wallpaperID = s.pinned
+	}
+	if err := run(context.Background(), opts); err != nil {
+		s.message = "preview failed: " + err.Error()
+		return
+	}
+	f, err := os.Open(filepath.Join(s.rootFS, filepath.FromSlash(opts.installLayout().Background())))
+	if err != nil {
+		s.message = "preview failed: " + err.Error()
+		return
+	}
+	defer f.Close()
+	if s.preview, _, err = image.Decode(f); err != nil {
+		s.message = "preview failed: " + err.Error()
+		return
+	}
+	s.pinned = releaseValue(s.rootFS, opts.installLayout(), "TSSH_WALLPAPER_ID")
+}
+
+// draw prints the settings, the preview, the last message, and the prompt.
+func (s *tuiSession) draw() {
+	if s.clear {
+		fmt.Fprint(s.out, "\x1b[H\x1b[2J")
+	}
+	fmt.Fprintf(s.out, "ts-release tui: %s\n\n", s.opts.config)
+	fmt.Fprintf(s.out, "  %-9s %s\n", "title", s.title)
+	for _, name := range tuiSettings {
+		value, ok := s.values[name]
+		if !ok || value == "" {
+			value = "(default)"
+		}
+		mark := " "
+		if s.changed[name] {
+			mark = "*"
+		}
+		fmt.Fprintf(s.out, "%s %-9s %s\n", mark, name, value)
+	}
+	if s.pinned != "" {
+		fmt.Fprintf(s.out, "  %-9s %s\n", "wallpaper", s.pinned)
+	}
+	fmt.Fprintln(s.out)
+	if s.preview != nil {
+		if err := preview.Write(s.out, s.preview, s.protocol, preview.Options{}); err != nil {
+			fmt.Fprintln(s.out, err)
+		}
+	}
+	if s.message != "" {
+		fmt.Fprintln(s.out, s.message)
+	}
+	fmt.Fprintf(s.out, "\ncommands: query|theme|layout|subtitle|title <value>, next, save, quit (layouts: %s)\n> ", strings.Join(wallpaper.Layouts(), ", "))
+}
+
+// releaseValue returns the value of key in the release file of layout in rootFS, or empty if it is not set.
+func releaseValue(rootFS string, layout install.Layout, key string) string {
+	data, err := os.ReadFile(filepath.Join(rootFS, filepath.FromSlash(layout.Release())))
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, key+"="); ok {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,10 @@
+//go:build ffmpeg
+
+package main
+
+import "github.com/nickhildebrandt/ts-release/internal/wallpaper"
+
+// newVideoSource returns the frame grabber for --video and --video-at.
+func newVideoSource(opts options) (wallpaper.BackgroundSource, error) {
+	return wallpaper.VideoSource{Path: opts.video, At: opts.videoAt, Limits: opts.limits}, nil
+}
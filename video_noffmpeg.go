@@ -0,0 +1,15 @@
+//go:build !ffmpeg
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/nickhildebrandt/ts-release/internal/wallpaper"
+)
+
+// newVideoSource reports that --video needs a build with the "ffmpeg" tag, which default builds leave out so they
+// do not depend on an external ffmpeg.
+func newVideoSource(opts options) (wallpaper.BackgroundSource, error) {
+	return nil, fmt.Errorf("--video needs a build with -tags ffmpeg")
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/nickhildebrandt/ts-release/internal/wallpaper"
+	"github.com/nickhildebrandt/ts-release/internal/watch"
+)
+
+// watchOptions holds the parsed command line of the watch subcommand.
+type watchOptions struct {
+	config      string
+	rootFS      string
+	target      string
+	interval    time.Duration
+	dbusSignal  string
+	dbusSession bool
+	metrics     string
+}
+
+// newWatchFlagSet returns the flag set of the watch subcommand.
+func newWatchFlagSet(opts *watchOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("ts-release watch", flag.ContinueOnError)
+	fs.StringVar(&opts.config, "config", "", "config file with the build flags; changes regenerate (required)")
+	fs.StringVar(&opts.rootFS, "rootfs", "", "rootfs directory to regenerate into (required)")
+	fs.StringVar(&opts.target, "target", "", "target name shown on the wallpaper (required)")
+	fs.DurationVar(&opts.interval, "interval", 2*time.Second, "how often the config file is checked for changes")
+	fs.StringVar(&opts.dbusSignal, "dbus-signal", "", "also regenerate on this D-Bus signal, as interface.Member, observed with dbus-monitor")
+	fs.BoolVar(&opts.dbusSession, "dbus-session", false, "listen for --dbus-signal on the session bus instead of the system bus")
+	fs.StringVar(&opts.metrics, "metrics-listen", "", "serve Prometheus metrics of the builds on /metrics at this address, e.g. :9464")
+	return fs
+}
+
+// runWatch generates the wallpaper once and then stays running, regenerating when the config file changes, on
+// SIGHUP, or on the D-Bus signal, until SIGINT or SIGTERM. Failed builds are reported and leave the previous
+// artifacts in place; the next trigger tries again.
+func runWatch(args []string) error {
+	var opts watchOptions
+	fs := newWatchFlagSet(&opts)
+	if err := parseSubcommand(fs, args); err != nil {
+		return err
+	}
+	if opts.config == "" || opts.rootFS == "" || opts.target == "" || fs.NArg() != 0 {
+		return errUsage
+	}
+	if opts.interval <= 0 {
+		return fmt.Errorf("watch: --interval %s must be positive", opts.interval)
+	}
+	if info, err := os.Stat(opts.rootFS); err != nil || !info.IsDir() {
+		return fmt.Errorf("watch: rootfs %s is no directory", opts.rootFS)
+	}
+	if opts.dbusSignal != "" {
+		if _, _, err := watch.ParseSignal(opts.dbusSignal); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	triggers := watch.NewTriggers()
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				triggers.Fire("SIGHUP")
+			}
+		}
+	}()
+	go watch.File(ctx, opts.config, opts.interval, triggers)
+	dbusErr := make(chan error, 1)
+	if opts.dbusSignal != "" {
+		go func() {
+			dbusErr <- watch.DBus{Signal: opts.dbusSignal, Session: opts.dbusSession}.Watch(ctx, triggers)
+		}()
+	}
+
+	if opts.metrics != "" {
+		ln, err := net.Listen("tcp", opts.metrics)
+		if err != nil {
+			return fmt.Errorf("watch: %w", err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metricsRegistry)
+		srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go srv.Serve(ln)
+		defer srv.Close()
+		logWatch("serving metrics on http://%s/metrics", ln.Addr())
+	}
+
+	go flushTracesEvery(ctx)
+	regenerate(opts, "start")
+	for {
+		select {
+		case <-ctx.Done():
+			logWatch("stopping")
+			return nil
+		case reason := <-triggers.C():
+			regenerate(opts, reason)
+		case err := <-dbusErr:
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// regenerate builds into the rootfs with the current config file and logs the outcome.
+func regenerate(opts watchOptions, reason string) {
+	start := time.Now()
+	buildOpts, err := parseArgs([]string{"--config", opts.config, opts.target, opts.rootFS})
+	if errors.Is(err, errUsage) {
+		err = fmt.Errorf("config: %s: invalid settings", opts.config)
+	}
+	if err == nil {
+		err = run(context.Background(), buildOpts)
+	}
+	var fetchErr *wallpaper.FetchError
+	switch {
+	case errors.As(err, &fetchErr):
+		logWatch("build failed (%s): %v; hint (%s error): %s", reason, err, fetchErr.Kind, fetchErr.Hint())
+	case err != nil:
+		logWatch("build failed (%s): %v", reason, err)
+	default:
+		logWatch("regenerated %s (%s) in %s", opts.rootFS, reason, time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// logWatch prints a timestamped watch message to stderr.
+func logWatch(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "%s watch: %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}